@@ -4,20 +4,30 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 	"smart-log-analyser/pkg/analyser"
+	"smart-log-analyser/pkg/blocklist"
 	"smart-log-analyser/pkg/charts"
 	"smart-log-analyser/pkg/config"
+	"smart-log-analyser/pkg/entries"
 	"smart-log-analyser/pkg/html"
 	"smart-log-analyser/pkg/parser"
 	"smart-log-analyser/pkg/query"
+	"smart-log-analyser/pkg/reports"
+	"smart-log-analyser/pkg/security"
+	"smart-log-analyser/pkg/siem"
 	"smart-log-analyser/pkg/trends"
+	"smart-log-analyser/pkg/xlsx"
 )
 
 var (
@@ -25,21 +35,121 @@ var (
 	until         string
 	topIPs        int
 	topURLs       int
+	topN          string
 	exportJSON    string
 	exportCSV     string
+	exportXLSX    string
+	exportEntries string
+	templateName  string
+	templateOutput string
+	templateFormats []string
 	exportHTML    string
+	comparisonHTML string
 	htmlTitle     string
 	interactiveHTML bool
+	htmlEmbedData bool
+	htmlOffline   bool
+	htmlAssetsDir string
+	htmlSecurity  bool
 	showDetails   bool
 	asciiCharts   bool
 	chartWidth    int
+	chartBucket   time.Duration
+	brailleCharts bool
+	chartThemeName  string
+	chartColorSpecs []string
+	chartTheme      = charts.DefaultTheme()
+	histogramScaleName string
+	histogramScale     charts.HistogramScale
+	topCountries       int
+	chartDataDir       string
 	noColors      bool
 	trendAnalysis bool
+	recordTrends  bool
+	trendHistoryFile string
+	trendWindow   int
+	trendRetention int
+	trendProfile  string
+	exportTrends  string
+	maxRPS        float64
+	maxBandwidth  string
 	comparePeriod string
+	compareLogs   []string
 	queryString   string
 	queryFormat   string
 	presetName    string
 	analyseConfigDir string
+	showEndpoints bool
+	endpointSort  string
+	topEndpoints  int
+	botConfigFile string
+	securityRulesFile string
+	customSecurityRules []security.CustomRule
+	cveSignaturesFile string
+	cveSignatures []security.CVESignature
+	securityScoring security.ScoringConfig
+	securityHistoryFile string
+	recordSecurityHistory bool
+	securityBehavior *security.BehaviorStore
+	ipReputationFile string
+	recordReputation bool
+	ipReputation *security.ReputationStore
+	securityAnalysis bool
+	securityDetails bool
+	failOnSeverity string
+	failSeverity  security.ThreatSeverity
+	lastSecurityAnalysis *securityAnalysisRun
+	showBrokenLinks bool
+	siteHosts     []string
+	showRateLimits bool
+	rateLimitRPM  int
+	rateLimitSustained int
+	exportNginxLimits string
+	exportBlocklist string
+	blocklistFormat string
+	blockMinScore int
+	blocklistExpiry int
+	exportWAFRules string
+	wafFormat     string
+	exportSIEM    string
+	siemFormat    string
+	siemHostname  string
+	siemAggregate bool
+	exportSigma   string
+	exportSTIX    string
+	exportTimeline string
+	sigmaMinHits  int
+	compareSince  string
+	compareUntil  string
+	focusIP       string
+	streamMode    bool
+	sizeBuckets   string
+	showCrawlBudget bool
+	verifyCrawlers bool
+	showEgressCost bool
+	egressConfigFile string
+	costPerGB     float64
+	baselineLog   string
+	saveBaselines bool
+	countryFilter []string
+	showCountries bool
+	geoIPDatabase string
+	geoIPDB       *analyser.GeoIPDatabase
+	showStaticSplit bool
+	showCapacity  bool
+	serviceTime   time.Duration
+	exfilBytes    string
+	exfilUploads  int
+	exfilZScore   float64
+	groupSpecs    []string
+	showLatencyHeatmap bool
+	lookupSpecs   []string
+	queryOutput   string
+	queryChart    bool
+	queryParamSpecs []string
+	queryMaxGroups int
+	errorLogFiles []string
+	presetParams  map[string]string
 )
 
 var analyseCmd = &cobra.Command{
@@ -76,14 +186,24 @@ You can use SQL-like queries to filter and analyze your logs. Examples:
   
   # Time-based analysis
   --query "SELECT HOUR(timestamp), COUNT() FROM logs GROUP BY HOUR(timestamp)"
+  --query "SELECT url, status FROM logs WHERE timestamp >= NOW() - INTERVAL '24h' AND status >= 500"
   
   # Complex filtering
   --query "SELECT url, method FROM logs WHERE status >= 400 AND url LIKE '/api*'"
+  
+  # Show how a query runs and how many entries each filter keeps
+  --query "EXPLAIN SELECT url FROM logs WHERE status >= 400 AND url LIKE '/api*'"
 
 Available fields: ip, timestamp, method, url, protocol, status, size, referer, user_agent
-Available functions: COUNT(), SUM(), AVG(), MIN(), MAX(), HOUR(), DAY(), UPPER(), LOWER()
-Available operators: =, !=, <, >, <=, >=, LIKE, CONTAINS, STARTS_WITH, ENDS_WITH, IN, BETWEEN`,
-	Args:  cobra.MinimumNArgs(1),
+Available functions: COUNT(), SUM(), AVG(), MIN(), MAX(), HOUR(), DAY(), NOW(), UPPER(), LOWER()
+Available operators: =, !=, <, >, <=, >=, LIKE, CONTAINS, STARTS_WITH, ENDS_WITH, IN, BETWEEN
+Available tables: logs, errors (nginx error.log via --error-log), sessions, threats`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && len(groupSpecs) == 0 {
+			return fmt.Errorf("requires at least 1 log file or --group")
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// Handle preset loading if specified
 		if presetName != "" {
@@ -93,30 +213,51 @@ Available operators: =, !=, <, >, <=, >=, LIKE, CONTAINS, STARTS_WITH, ENDS_WITH
 			}
 		}
 		
-		p := parser.New()
-		var allLogs []*parser.LogEntry
+		theme, err := resolveChartTheme()
+		if err != nil {
+			fmt.Printf("❌ Failed to set up chart colors: %v\n", err)
+			os.Exit(1)
+		}
+		chartTheme = theme
 		
-		fmt.Printf("📂 Analysing %d log file(s)...\n\n", len(args))
+		histogramScale, err = charts.ParseHistogramScale(histogramScaleName)
+		if err != nil {
+			log.Fatalf("Invalid --histogram-scale: %v", err)
+		}
 		
-		for i, logFile := range args {
-			fmt.Printf("  [%d/%d] Processing: %s\n", i+1, len(args), logFile)
-			
-			logs, err := p.ParseFile(logFile)
+		// Bind :name placeholders from --param and the preset defaults
+		if queryString != "" {
+			bound, err := bindQueryParameters(queryString, queryParamSpecs, presetParams)
 			if err != nil {
-				fmt.Printf("    ❌ Failed to parse %s: %v\n", logFile, err)
-				continue
+				log.Fatalf("Invalid query parameters: %v", err)
 			}
-			
-			fmt.Printf("    ✅ Parsed %d entries\n", len(logs))
-			allLogs = append(allLogs, logs...)
+			queryString = bound
+		} else if len(queryParamSpecs) > 0 {
+			log.Fatal("--param requires --query or a preset with a query")
 		}
 		
-		if len(allLogs) == 0 {
-			log.Fatal("No valid log entries found in any files")
+		if topN != "" {
+			n, err := analyser.ParseTopN(topN)
+			if err != nil {
+				log.Fatalf("Invalid --top: %v", err)
+			}
+			topIPs, topURLs = n, n
+		}
+		
+		// Grouped runs take their files from the --group definitions
+		groups, err := analyser.ParseLogGroups(groupSpecs)
+		if err != nil {
+			log.Fatalf("Invalid --group: %v", err)
+		}
+		if len(groups) > 0 {
+			if len(args) > 0 {
+				log.Fatal("Give log files either as arguments or with --group, not both")
+			}
+			for _, group := range groups {
+				args = append(args, group.Files...)
+			}
 		}
 		
-		fmt.Printf("\n📊 Combined Analysis Results (%d total entries):\n", len(allLogs))
-
 		var sinceTime, untilTime *time.Time
 		if since != "" {
 			t, err := time.Parse("2006-01-02 15:04:05", since)
@@ -132,80 +273,462 @@ Available operators: =, !=, <, >, <=, >=, LIKE, CONTAINS, STARTS_WITH, ENDS_WITH
 			}
 			untilTime = &t
 		}
+		
+		var compareSinceTime, compareUntilTime *time.Time
+		if compareSince != "" {
+			t, err := time.Parse("2006-01-02 15:04:05", compareSince)
+			if err != nil {
+				log.Fatalf("Invalid compare-since time format: %v", err)
+			}
+			compareSinceTime = &t
+		}
+		if compareUntil != "" {
+			t, err := time.Parse("2006-01-02 15:04:05", compareUntil)
+			if err != nil {
+				log.Fatalf("Invalid compare-until time format: %v", err)
+			}
+			compareUntilTime = &t
+		}
+		if comparisonHTML != "" && compareSinceTime == nil && compareUntilTime == nil {
+			log.Fatal("--export-comparison-html requires --compare-since and/or --compare-until")
+		}
+		if comparePeriod != "" {
+			if _, err := trends.ResolvePeriod(comparePeriod, time.Now()); err != nil {
+				log.Fatalf("Invalid --compare-period: %v", err)
+			}
+		} else if len(compareLogs) > 0 {
+			log.Fatal("--compare-logs requires --compare-period")
+		}
+		if exportTrends != "" {
+			if !trendAnalysis && comparePeriod == "" {
+				log.Fatal("--export-trends requires --trend-analysis or --compare-period")
+			}
+			if _, err := trends.ExportFormat(exportTrends); err != nil {
+				log.Fatalf("Invalid --export-trends: %v", err)
+			}
+		}
+		if exportBlocklist != "" {
+			if blocklistFormat == "" {
+				format, err := blocklist.FormatFromFilename(exportBlocklist)
+				if err != nil {
+					log.Fatalf("Invalid --export-blocklist: %v", err)
+				}
+				blocklistFormat = format
+			} else if !containsString(blocklist.Formats, blocklistFormat) {
+				log.Fatalf("Invalid --blocklist-format %q (use %s)", blocklistFormat, strings.Join(blocklist.Formats, ", "))
+			}
+			if blocklistExpiry < 0 {
+				log.Fatal("--blocklist-expiry must not be negative")
+			}
+		}
+		if exportWAFRules != "" {
+			if streamMode {
+				log.Fatal("--export-waf-rules needs the parsed entries in memory and cannot be combined with --stream")
+			}
+			if !containsString(security.WAFFormats, wafFormat) {
+				log.Fatalf("Invalid --waf-format %q (use %s)", wafFormat, strings.Join(security.WAFFormats, ", "))
+			}
+		}
+		if exportSIEM != "" {
+			if streamMode {
+				log.Fatal("--export-siem needs the parsed entries in memory and cannot be combined with --stream")
+			}
+			if siemFormat == "" {
+				format, err := siem.FormatFromFilename(exportSIEM)
+				if err != nil {
+					log.Fatalf("Invalid --export-siem: %v", err)
+				}
+				siemFormat = format
+			} else if !containsString(siem.Formats, siemFormat) {
+				log.Fatalf("Invalid --siem-format %q (use %s)", siemFormat, strings.Join(siem.Formats, ", "))
+			}
+		}
+		if exportSTIX != "" && streamMode {
+			log.Fatal("--export-stix needs the parsed entries in memory and cannot be combined with --stream")
+		}
+		if exportTimeline != "" && streamMode {
+			log.Fatal("--export-timeline needs the parsed entries in memory and cannot be combined with --stream")
+		}
+		if exportSigma != "" {
+			if streamMode {
+				log.Fatal("--export-sigma needs the parsed entries in memory and cannot be combined with --stream")
+			}
+			if sigmaMinHits < 1 {
+				log.Fatal("--sigma-min-hits must be at least 1")
+			}
+		}
+		if (securityAnalysis || securityDetails) && streamMode {
+			log.Fatal("--security-analysis needs the parsed entries in memory and cannot be combined with --stream")
+		}
+		if failOnSeverity != "" {
+			if streamMode || queryString != "" || focusIP != "" {
+				log.Fatal("--fail-on-severity needs the full analysis and cannot be combined with --stream, --query or --focus-ip")
+			}
+			if failSeverity, err = security.ParseSeverity(failOnSeverity); err != nil {
+				log.Fatalf("Invalid --fail-on-severity: %v", err)
+			}
+		}
+		if err := loadSecurityRules(); err != nil {
+			log.Fatalf("Invalid --security-rules: %v", err)
+		}
+		if err := loadCVESignatures(); err != nil {
+			log.Fatalf("Invalid --cve-signatures: %v", err)
+		}
+		if err := loadSecurityScoring(); err != nil {
+			log.Fatalf("Invalid security scoring: %v", err)
+		}
+		if recordSecurityHistory && streamMode {
+			log.Fatal("--record-security-history needs the parsed entries in memory and cannot be combined with --stream")
+		}
+		if behavior, err := security.LoadBehaviorStore(securityHistoryPath()); err != nil {
+			log.Fatalf("Invalid --security-history: %v", err)
+		} else {
+			securityBehavior = behavior
+		}
+		if recordReputation && streamMode {
+			log.Fatal("--record-reputation needs the parsed entries in memory and cannot be combined with --stream")
+		}
+		if reputation, err := security.LoadReputationStore(ipReputationPath()); err != nil {
+			log.Fatalf("Invalid --ip-reputation: %v", err)
+		} else {
+			ipReputation = reputation
+		}
+		if maxRPS < 0 {
+			log.Fatal("--max-rps must not be negative")
+		}
+		if maxBandwidth != "" {
+			if _, err := trends.ParseBandwidth(maxBandwidth); err != nil {
+				log.Fatalf("Invalid --max-bandwidth: %v", err)
+			}
+		}
+		if exportEntries != "" {
+			if _, err := entries.FormatFromFilename(exportEntries); err != nil {
+				log.Fatalf("Invalid --export-entries: %v", err)
+			}
+		}
+		for _, format := range templateFormats {
+			if !containsString(config.TemplateFormats, format) {
+				log.Fatalf("Invalid --template-format %q (use %s)", format, strings.Join(config.TemplateFormats, ", "))
+			}
+		}
+		var reportTemplate *config.ReportTemplate
+		if templateName != "" {
+			if streamMode {
+				log.Fatal("--template needs the parsed entries in memory and cannot be combined with --stream")
+			}
+			reportTemplate, err = loadReportTemplate(templateName)
+			if err != nil {
+				log.Fatalf("Invalid --template: %v", err)
+			}
+		}
 
-		// Execute query if provided
-		if queryString != "" {
-			fmt.Printf("🔍 Executing query: %s\n", queryString)
+		var allLogs []*parser.LogEntry
+		var a *analyser.Analyser
+		var results *analyser.Results
+		var groupResults []analyser.GroupResult
+		
+		// Streaming mode analyses each file in parallel without keeping the
+		// parsed entries in memory, then merges the per-file results
+		if streamMode {
+			if focusIP != "" || trendAnalysis || compareSinceTime != nil || compareUntilTime != nil {
+				log.Fatal("--stream cannot be combined with --focus-ip, --trend-analysis or --compare-since/--compare-until")
+			}
 			
-			// Filter logs by time if specified
-			var filteredLogs []*parser.LogEntry
-			if sinceTime != nil || untilTime != nil {
-				a := analyser.New()
-				filteredLogs = a.FilterByTime(allLogs, sinceTime, untilTime)
+			// Queries run over the entries as they are read
+			if queryString != "" {
+				streamQuery(args, sinceTime, untilTime)
+				return
+			}
+			
+			// Entries are exported as they are streamed, not parsed twice
+			var export *entryExport
+			if exportEntries != "" {
+				export, err = newEntryExport(exportEntries)
+				if err != nil {
+					log.Fatalf("Failed to create %s: %v", exportEntries, err)
+				}
+			}
+			
+			a = newConfiguredAnalyser()
+			if len(groups) > 0 {
+				for _, group := range groups {
+					fmt.Printf("🏷️  Group: %s\n", group.Name)
+					groupResults = append(groupResults, analyser.NewGroupResult(group, streamAnalyse(a, group.Files, sinceTime, untilTime, export)))
+					fmt.Println()
+				}
+				results = analyser.RollUp(groupResults)
 			} else {
-				filteredLogs = allLogs
+				results = streamAnalyse(a, args, sinceTime, untilTime, export)
 			}
 			
-			// Execute the query
-			engine := query.NewQueryEngine(filteredLogs)
-			result, err := engine.Query(queryString, queryFormat)
-			if err != nil {
-				fmt.Printf("❌ Query error: %v\n", err)
-				helper := query.NewQueryHelper()
-				fmt.Printf("💡 %s\n", helper.SuggestCorrection(err))
+			if export != nil {
+				export.close()
+			}
+		} else {
+			p := parser.New()
+		
+			fmt.Printf("📂 Analysing %d log file(s)...\n\n", len(args))
+		
+			fileLogs := make([][]*parser.LogEntry, len(args))
+			for i, logFile := range args {
+				fmt.Printf("  [%d/%d] Processing: %s\n", i+1, len(args), logFile)
+			
+				logs, err := p.ParseFile(logFile)
+				if err != nil {
+					fmt.Printf("    ❌ Failed to parse %s: %v\n", logFile, err)
+					continue
+				}
+			
+				fmt.Printf("    ✅ Parsed %d entries\n", len(logs))
+				fileLogs[i] = logs
+				allLogs = append(allLogs, logs...)
+			}
+		
+			if len(allLogs) == 0 {
+				log.Fatal("No valid log entries found in any files")
+			}
+		
+			fmt.Printf("\n📊 Combined Analysis Results (%d total entries):\n", len(allLogs))
+			
+			// Restrict every analysis below to the requested countries
+			if len(countryFilter) > 0 {
+				ca := analyser.New()
+				if err := applyGeoIP(ca); err != nil {
+					log.Fatalf("Failed to apply country filter: %v", err)
+				}
+				allLogs = ca.FilterByCountry(allLogs)
+				fmt.Printf("🌍 Country filter %s: %d matching entries\n", strings.Join(countryFilter, ", "), len(allLogs))
+				if len(allLogs) == 0 {
+					log.Fatal("No log entries match the country filter")
+				}
+			}
+
+			// Execute query if provided
+			if queryString != "" {
+				fmt.Printf("🔍 Executing query: %s\n", queryString)
+			
+				// Filter logs by time if specified
+				var filteredLogs []*parser.LogEntry
+				if sinceTime != nil || untilTime != nil {
+					a := analyser.New()
+					filteredLogs = a.FilterByTime(allLogs, sinceTime, untilTime)
+				} else {
+					filteredLogs = allLogs
+				}
+			
+				// Execute the query
+				engine, err := newQueryEngine(filteredLogs)
+				if err != nil {
+					fmt.Printf("❌ Error loading lookup: %v\n", err)
+					return
+				}
+				result, err := engine.ExecuteQuery(queryString)
+				if err != nil {
+					fmt.Printf("❌ Query error: %v\n", err)
+					helper := query.NewQueryHelper()
+					fmt.Printf("💡 %s\n", helper.SuggestCorrection(err))
+					return
+				}
+			
+				if err := outputQueryResult(result, queryString, queryFormat, queryOutput, queryChart); err != nil {
+					fmt.Printf("❌ %v\n", err)
+				}
 				return
 			}
+
+			// Produce a single-IP drill-down profile if requested
+			if focusIP != "" {
+				a := analyser.New()
+				profile := a.ProfileIP(allLogs, focusIP, sinceTime, untilTime)
+				if profile == nil {
+					fmt.Printf("❌ No requests found from %s in the selected time range\n", focusIP)
+					return
+				}
 			
-			fmt.Printf("📊 Query Results:\n")
-			fmt.Printf("%s", result)
-			return
+				printIPProfile(profile)
+			
+				if exportJSON != "" {
+					if err := exportValueToJSON(profile, exportJSON); err != nil {
+						fmt.Printf("❌ Failed to export JSON: %v\n", err)
+					} else {
+						fmt.Printf("📄 Exported IP profile to: %s\n", exportJSON)
+					}
+				}
+				return
+			}
+		
+			a = newConfiguredAnalyser()
+			results = a.Analyse(allLogs, sinceTime, untilTime)
+			
+			// Analyse each group on its own; the roll-up above covers all files
+			offset := 0
+			for _, group := range groups {
+				var groupLogs []*parser.LogEntry
+				for _, logs := range fileLogs[offset : offset+len(group.Files)] {
+					groupLogs = append(groupLogs, logs...)
+				}
+				offset += len(group.Files)
+				groupResults = append(groupResults, analyser.NewGroupResult(group, a.Analyse(groupLogs, sinceTime, untilTime)))
+			}
+		}
+		
+		if err := analyser.SortEndpointStats(results.EndpointStats, endpointSort); err != nil {
+			log.Fatalf("Invalid --endpoint-sort: %v", err)
 		}
-
-		a := analyser.New()
-		results := a.Analyse(allLogs, sinceTime, untilTime)
 		
 		// Perform trend analysis if requested
-		if trendAnalysis {
+		var trendResults *trends.TrendAnalysis
+		if comparePeriod != "" {
+			fmt.Printf("🔍 Comparing with %s...\n", comparePeriod)
+			var err error
+			trendResults, err = compareWithPeriod(a, results)
+			if err != nil {
+				fmt.Printf("❌ Failed to compare with %s: %v\n", comparePeriod, err)
+			} else {
+				addCapacityProjections(trendResults, results)
+				printTrendAnalysis(trendResults)
+				if asciiCharts {
+					fmt.Printf("\n")
+					fmt.Print(trends.RenderTrendCharts(trendResults, resolvedChartWidth(), !noColors))
+				}
+			}
+		} else if trendAnalysis {
 			fmt.Printf("🔍 Performing trend analysis...\n")
-			ta := trends.New()
-			trendResults, err := ta.DetectDegradation(allLogs)
+			var err error
+			trendResults, err = analyseTrends(results, allLogs)
 			if err != nil {
 				fmt.Printf("❌ Failed to perform trend analysis: %v\n", err)
 			} else {
 				fmt.Printf("📈 Trend analysis completed\n")
+				addCapacityProjections(trendResults, results)
 				printTrendAnalysis(trendResults)
 				
 				// Display trend charts if ASCII charts are enabled
 				if asciiCharts {
 					fmt.Printf("\n")
-					fmt.Print(trends.RenderTrendCharts(trendResults, chartWidth, !noColors))
+					fmt.Print(trends.RenderTrendCharts(trendResults, resolvedChartWidth(), !noColors))
 				}
 			}
 		}
 		
+		if exportTrends != "" && trendResults != nil {
+			if err := trends.WriteFile(trendResults, exportTrends); err != nil {
+				fmt.Printf("❌ Failed to export trend analysis: %v\n", err)
+			} else {
+				fmt.Printf("📈 Exported trend analysis to: %s\n", exportTrends)
+			}
+		}
+		
+		if recordTrends {
+			if err := recordTrendSnapshot(results, args); err != nil {
+				fmt.Printf("❌ Failed to record trend history: %v\n", err)
+			}
+		}
+		
+		if recordSecurityHistory {
+			if err := recordSecurityBehavior(a.FilterByTime(allLogs, sinceTime, untilTime)); err != nil {
+				fmt.Printf("❌ Failed to record security history: %v\n", err)
+			}
+		}
+		
+		if recordReputation {
+			if err := recordIPReputation(a.FilterByTime(allLogs, sinceTime, untilTime)); err != nil {
+				fmt.Printf("❌ Failed to record IP reputation: %v\n", err)
+			}
+		}
+		
 		// Export to files if requested
 		if exportJSON != "" {
-			if err := exportToJSON(results, exportJSON); err != nil {
+			var err error
+			if len(groupResults) > 0 {
+				err = exportGroupsToJSON(results, groupResults, exportJSON)
+			} else {
+				err = exportToJSON(results, exportJSON)
+			}
+			if err != nil {
 				fmt.Printf("❌ Failed to export JSON: %v\n", err)
 			} else {
 				fmt.Printf("📄 Exported detailed results to: %s\n", exportJSON)
 			}
 		}
 		
+		if exportNginxLimits != "" {
+			nginxConfig := analyser.GenerateNginxRateLimitConfig(results.RateLimitCandidates, a.RateLimitThresholds())
+			if err := os.WriteFile(exportNginxLimits, []byte(nginxConfig), 0644); err != nil {
+				fmt.Printf("❌ Failed to export nginx rate limits: %v\n", err)
+			} else {
+				fmt.Printf("🚦 Exported suggested nginx rate limits to: %s\n", exportNginxLimits)
+			}
+		}
+		
+		if exportBlocklist != "" {
+			blocked := blocklist.FromResults(results, blockMinScore)
+			options := blocklist.Options{ExpiryDays: blocklistExpiry, GeneratedAt: time.Now(), MinScore: blockMinScore}
+			if err := blocklist.WriteFile(exportBlocklist, blocklistFormat, blocked, options); err != nil {
+				fmt.Printf("❌ Failed to export blocklist: %v\n", err)
+			} else {
+				fmt.Printf("🛑 Exported %s blocklist of %d IP(s) to: %s\n", blocklistFormat, len(blocked), exportBlocklist)
+			}
+		}
+		
+		if exportWAFRules != "" {
+			threats, _ := security.NewThreatDetector(securityConfig()).DetectWebAttacks(a.FilterByTime(allLogs, sinceTime, untilTime))
+			suggestions := security.SuggestWAFRules(threats)
+			if err := security.WriteWAFRulesFile(exportWAFRules, wafFormat, suggestions, time.Now()); err != nil {
+				fmt.Printf("❌ Failed to export WAF rules: %v\n", err)
+			} else {
+				fmt.Printf("🧱 Exported %d %s rule suggestion(s) to: %s (review before deploying)\n", len(suggestions), wafFormat, exportWAFRules)
+			}
+		}
+		
+		if exportSIEM != "" || exportSTIX != "" || exportTimeline != "" {
+			exportSecurityIntel(a.FilterByTime(allLogs, sinceTime, untilTime))
+		}
+		
+		if exportSigma != "" {
+			detector := security.NewThreatDetector(securityConfig())
+			logs := a.FilterByTime(allLogs, sinceTime, untilTime)
+			threats, _ := detector.DetectWebAttacks(logs)
+			infraThreats, _ := detector.DetectInfrastructureAttacks(logs)
+			rules := security.GenerateSigmaRules(append(threats, infraThreats...), sigmaMinHits, time.Now())
+			if err := security.WriteSigmaRules(exportSigma, rules); err != nil {
+				fmt.Printf("❌ Failed to export Sigma rules: %v\n", err)
+			} else {
+				fmt.Printf("🧬 Exported %d Sigma rule(s) to: %s\n", len(rules), exportSigma)
+			}
+		}
+		
 		if exportCSV != "" {
-			if err := exportToCSV(results, exportCSV); err != nil {
+			if err := exportToCSV(results, groupResults, exportCSV); err != nil {
 				fmt.Printf("❌ Failed to export CSV: %v\n", err)
 			} else {
 				fmt.Printf("📊 Exported detailed results to: %s\n", exportCSV)
 			}
 		}
 		
+		if exportXLSX != "" {
+			if err := exportToXLSX(results, groupResults, exportXLSX); err != nil {
+				fmt.Printf("❌ Failed to export XLSX: %v\n", err)
+			} else {
+				fmt.Printf("📊 Exported detailed results to: %s\n", exportXLSX)
+			}
+		}
+		
+		if exportEntries != "" && !streamMode {
+			logs := a.FilterByTime(allLogs, sinceTime, untilTime)
+			if err := entries.WriteFile(exportEntries, logs); err != nil {
+				fmt.Printf("❌ Failed to export entries: %v\n", err)
+			} else {
+				fmt.Printf("📦 Exported %d parsed entries to: %s\n", len(logs), exportEntries)
+			}
+		}
+		
 		if exportHTML != "" {
 			title := htmlTitle
 			if title == "" {
 				title = "Log Analysis Report"
 			}
-			if err := exportToHTML(results, exportHTML, title, interactiveHTML); err != nil {
+			if err := exportToHTML(a, results, a.FilterByTime(allLogs, sinceTime, untilTime), trendResults, exportHTML, title, interactiveHTML); err != nil {
 				fmt.Printf("❌ Failed to export HTML: %v\n", err)
 			} else {
 				reportType := "standard"
@@ -216,7 +739,44 @@ Available operators: =, !=, <, >, <=, >=, LIKE, CONTAINS, STARTS_WITH, ENDS_WITH
 			}
 		}
 		
+		if reportTemplate != nil {
+			if err := generateTemplateReport(reportTemplate, a.FilterByTime(allLogs, sinceTime, untilTime)); err != nil {
+				fmt.Printf("❌ Failed to generate template report: %v\n", err)
+			}
+		}
+		
 		printResults(results)
+		
+		if len(groupResults) > 0 {
+			printGroupSummary(groupResults)
+		}
+		
+		if securityAnalysis || securityDetails {
+			printSecurityAnalysis(a.FilterByTime(allLogs, sinceTime, untilTime))
+		}
+		
+		// Compare against a second time window if requested
+		if compareSinceTime != nil || compareUntilTime != nil {
+			compareResults := a.Analyse(allLogs, compareSinceTime, compareUntilTime)
+			printComparison(analyser.Compare(results, compareResults))
+			
+			if comparisonHTML != "" {
+				title := htmlTitle
+				if title == "" {
+					title = "Window Comparison Report"
+				}
+				if err := exportComparisonHTML(results, compareResults, "Window A", "Window B", comparisonHTML, title); err != nil {
+					fmt.Printf("❌ Failed to export comparison HTML: %v\n", err)
+				} else {
+					fmt.Printf("⚖️  Exported comparison HTML report to: %s\n", comparisonHTML)
+				}
+			}
+		}
+		
+		// Gate scripted runs on the security findings, after every output is written
+		if failOnSeverity != "" {
+			failOnSecurityFindings(a.FilterByTime(allLogs, sinceTime, untilTime))
+		}
 	},
 }
 
@@ -225,21 +785,110 @@ func init() {
 	analyseCmd.Flags().StringVar(&until, "until", "", "End time (YYYY-MM-DD HH:MM:SS)")
 	analyseCmd.Flags().IntVar(&topIPs, "top-ips", 10, "Number of top IPs to show")
 	analyseCmd.Flags().IntVar(&topURLs, "top-urls", 10, "Number of top URLs to show")
+	analyseCmd.Flags().StringVar(&topN, "top", "", "Entries per ranked list for display and CSV/JSON/HTML exports: a number or 'all' (overrides --top-ips/--top-urls)")
 	analyseCmd.Flags().StringVar(&exportJSON, "export-json", "", "Export detailed results to JSON file")
 	analyseCmd.Flags().StringVar(&exportCSV, "export-csv", "", "Export detailed results to CSV file")
+	analyseCmd.Flags().StringVar(&exportXLSX, "export-xlsx", "", "Export detailed results to an Excel workbook with one worksheet per section")
+	analyseCmd.Flags().StringVar(&templateName, "template", "", "Generate a report from a report template (e.g. security-report, traffic-report); see 'config --list templates'")
+	analyseCmd.Flags().StringVar(&templateOutput, "template-output", "", "Output path of the template report without extension (default: output/<template>)")
+	analyseCmd.Flags().StringSliceVar(&templateFormats, "template-format", nil, "Formats of the template report: html, json, csv (default: the template's formats, or html)")
+	analyseCmd.Flags().StringVar(&exportEntries, "export-entries", "", "Export the parsed log entries to a .parquet or .ndjson file for DuckDB, Spark or a data lake")
 	analyseCmd.Flags().StringVar(&exportHTML, "export-html", "", "Export HTML report")
 	analyseCmd.Flags().StringVar(&htmlTitle, "html-title", "", "Custom title for HTML report")
 	analyseCmd.Flags().BoolVar(&interactiveHTML, "interactive-html", true, "Generate interactive HTML report with tabs and drill-down (default: true)")
+	analyseCmd.Flags().BoolVar(&htmlEmbedData, "html-embed-data", true, "Embed the full results as JSON in interactive HTML reports for download and drill-downs")
+	analyseCmd.Flags().BoolVar(&htmlSecurity, "html-security", true, "Run the full security analysis for the security tab of interactive HTML reports: threat distribution, high-risk IPs, incident timeline and recommendations (not with --stream)")
+	analyseCmd.Flags().BoolVar(&htmlOffline, "html-offline", false, "Inline all styles and scripts in HTML reports instead of loading them from a CDN, for air-gapped servers and email attachments")
+	analyseCmd.Flags().StringVar(&htmlAssetsDir, "html-assets-dir", "", "Directory with local copies of "+html.BootstrapCSSFile+", "+html.BootstrapJSFile+" and "+html.ChartJSFile+" to inline with --html-offline (default: built-in lightweight versions)")
 	analyseCmd.Flags().BoolVar(&showDetails, "details", false, "Show detailed breakdown (individual status codes, etc.)")
 	analyseCmd.Flags().BoolVar(&asciiCharts, "ascii-charts", false, "Display ASCII charts with analysis results")
-	analyseCmd.Flags().IntVar(&chartWidth, "chart-width", 80, "Width of ASCII charts (default: 80)")
+	analyseCmd.Flags().IntVar(&chartWidth, "chart-width", 0, "Width of ASCII charts (default: terminal width, or 80 when not a terminal)")
+	analyseCmd.Flags().BoolVar(&brailleCharts, "braille-charts", false, "Draw line charts with Braille dots for higher resolution (needs a Unicode terminal font)")
+	analyseCmd.Flags().StringVar(&chartThemeName, "chart-theme", "", "Chart color theme: "+strings.Join(charts.ThemeNames(), ", ")+" (default: chart_theme from the config, or default)")
+	analyseCmd.Flags().StringArrayVar(&chartColorSpecs, "chart-color", nil, "Override a chart color as element=color, e.g. 5xx=magenta, primary=33 or series1=bright-blue (repeatable)")
+	analyseCmd.Flags().StringVar(&chartDataDir, "chart-data", "", "Directory to write the data series of each chart to as JSON and CSV files")
+	analyseCmd.Flags().IntVar(&topCountries, "top-countries", 10, "Number of countries in the geographic chart")
+	analyseCmd.Flags().StringVar(&histogramScaleName, "histogram-scale", "linear", "Bar lengths of histogram charts: linear or log (keeps small buckets visible)")
+	analyseCmd.Flags().DurationVar(&chartBucket, "chart-bucket", 0, "Bucket size of the requests over time chart, e.g. 1m, 5m or 1h (default: fitted to --chart-width)")
 	analyseCmd.Flags().BoolVar(&noColors, "no-colors", false, "Disable colors in ASCII charts")
 	analyseCmd.Flags().BoolVar(&trendAnalysis, "trend-analysis", false, "Perform historical trend analysis and degradation detection")
-	analyseCmd.Flags().StringVar(&comparePeriod, "compare-period", "", "Compare with specific period (e.g., 'previous-day', '2024-08-20')")
+	analyseCmd.Flags().BoolVar(&recordTrends, "record-trends", false, "Record this run's aggregate metrics in the trend history, so later --trend-analysis runs compare against it")
+	analyseCmd.Flags().StringVar(&trendHistoryFile, "trend-history", "", "Trend history file (default: "+trends.DefaultHistoryFile+" in the config directory)")
+	analyseCmd.Flags().IntVar(&trendWindow, "trend-window", 28, "Days of trend history that --trend-analysis compares against")
+	analyseCmd.Flags().IntVar(&trendRetention, "trend-retention", 90, "Days of trend history to keep when recording a run (0 keeps everything)")
+	analyseCmd.Flags().StringVar(&exportTrends, "export-trends", "", "Export the trend analysis (period metrics, comparisons, alerts and endpoint trends) to a .json or .csv file")
+	analyseCmd.Flags().Float64Var(&maxRPS, "max-rps", 0, "Sustained requests per second the servers can handle, to project the days until capacity in trend analysis (overrides analysis.capacity.max_rps)")
+	analyseCmd.Flags().StringVar(&maxBandwidth, "max-bandwidth", "", "Bandwidth per second the servers can send, e.g. 100MB, to project the days until capacity in trend analysis (overrides analysis.capacity.max_bandwidth)")
+	analyseCmd.Flags().StringVar(&trendProfile, "trend-profile", "", "Trend alert threshold profile from analysis.trend_alerts.profiles in the config, e.g. production")
+	analyseCmd.Flags().StringVar(&comparePeriod, "compare-period", "", "Compare with a prior period from the trend history or --compare-logs: previous-day, previous-week or a date (e.g. 2024-08-20)")
+	analyseCmd.Flags().StringArrayVar(&compareLogs, "compare-logs", nil, "Log file of the --compare-period period to compare with instead of the trend history (repeatable)")
 	analyseCmd.Flags().StringVar(&queryString, "query", "", "Execute a custom SQL-like query on log data")
-	analyseCmd.Flags().StringVar(&queryFormat, "query-format", "table", "Output format for query results (table, csv, json)")
+	analyseCmd.Flags().StringVar(&queryFormat, "query-format", "table", "Output format for query results (table, csv, json, html)")
+	analyseCmd.Flags().StringArrayVar(&lookupSpecs, "lookup", nil, "Lookup table for query JOINs as name=file.csv or name=file.json (repeatable), e.g. --lookup customers=ips.csv")
+	analyseCmd.Flags().StringVar(&queryOutput, "query-output", "", "Write query results to a file; format from the extension (.csv, .json, .html)")
+	analyseCmd.Flags().BoolVar(&queryChart, "query-chart", false, "Render aggregate query results as an ASCII bar chart")
+	analyseCmd.Flags().StringArrayVar(&queryParamSpecs, "param", nil, "Value for a :name placeholder in --query or the preset query as name=value (repeatable), e.g. --param ip=1.2.3.4")
+	analyseCmd.Flags().IntVar(&queryMaxGroups, "query-max-groups", query.DefaultMaxGroups, "GROUP BY groups kept in memory before query state spills to temporary files")
+	analyseCmd.Flags().StringArrayVar(&errorLogFiles, "error-log", nil, "nginx error log to query as the errors table (repeatable), e.g. --query \"SELECT level, COUNT() FROM errors GROUP BY level\"")
 	analyseCmd.Flags().StringVar(&presetName, "preset", "", "Use a predefined analysis preset (security, performance, traffic)")
 	analyseCmd.Flags().StringVar(&analyseConfigDir, "config-dir", "config", "Configuration directory path")
+	analyseCmd.Flags().BoolVar(&showEndpoints, "endpoints", false, "Show per-endpoint request count, error rate and size percentiles")
+	analyseCmd.Flags().StringVar(&endpointSort, "endpoint-sort", "requests", "Sort endpoint table by: requests, errors, error-rate, p50, p95, p99, bytes")
+	analyseCmd.Flags().IntVar(&topEndpoints, "top-endpoints", 15, "Number of endpoints to show in the endpoint table")
+	analyseCmd.Flags().BoolVar(&showBrokenLinks, "broken-links", false, "Show 404 broken-link report with referring pages")
+	analyseCmd.Flags().StringSliceVar(&siteHosts, "site-host", nil, "Host name(s) treated as internal referers (default: inferred from logs)")
+	analyseCmd.Flags().BoolVar(&showRateLimits, "rate-limits", false, "Show IPs/endpoints exceeding the rate-limit thresholds with suggested nginx limit_req settings")
+	analyseCmd.Flags().IntVar(&rateLimitRPM, "rate-limit-rpm", 60, "Per-client requests per minute considered excessive")
+	analyseCmd.Flags().IntVar(&rateLimitSustained, "rate-limit-sustained", 3, "Consecutive minutes above --rate-limit-rpm before reporting")
+	analyseCmd.Flags().StringVar(&exportNginxLimits, "export-nginx-limits", "", "Export suggested nginx limit_req configuration to file")
+	analyseCmd.Flags().StringVar(&exportBlocklist, "export-blocklist", "", "Export blocking rules for the suspicious IPs to file (.conf nginx deny include, .sh iptables, .nft nftables or .csv)")
+	analyseCmd.Flags().StringVar(&blocklistFormat, "blocklist-format", "", "Blocklist format: nginx, iptables, nftables, cloudflare or csv (default: from the --export-blocklist extension)")
+	analyseCmd.Flags().IntVar(&blockMinScore, "block-min-score", blocklist.DefaultMinScore, "Threat score from which --export-blocklist blocks an IP")
+	analyseCmd.Flags().IntVar(&blocklistExpiry, "blocklist-expiry", 0, "Days the --export-blocklist rules should stay in place, noted in their comments (nftables rules time out on their own); 0 for no expiry")
+	analyseCmd.Flags().StringVar(&exportWAFRules, "export-waf-rules", "", "Export candidate WAF rules for the detected attack payloads to file, to review before deploying (not with --stream)")
+	analyseCmd.Flags().StringVar(&wafFormat, "waf-format", "modsecurity", "WAF rule format: modsecurity (SecRule chains) or cloudflare (custom rule expressions)")
+	analyseCmd.Flags().StringVar(&exportSIEM, "export-siem", "", "Export detected threats, anomalies and incidents as SIEM events to file (.cef, .leef, or .json/.ndjson for Elastic Common Schema; not with --stream)")
+	analyseCmd.Flags().StringVar(&siemFormat, "siem-format", "", "SIEM event format: cef, leef or ecs (default: from the --export-siem extension)")
+	analyseCmd.Flags().StringVar(&siemHostname, "siem-hostname", "", "Host the logs came from, reported as the device host of --export-siem events")
+	analyseCmd.Flags().BoolVar(&siemAggregate, "siem-aggregate", false, "Export one --export-siem event per group of near-identical threats (same IP, attack type and pattern), with its count and first and last sighting")
+	analyseCmd.Flags().StringVar(&exportSigma, "export-sigma", "", "Export Sigma rules for the attack payloads that recur in the logs: a .yml/.yaml file, or a directory with a file per rule (not with --stream)")
+	analyseCmd.Flags().IntVar(&sigmaMinHits, "sigma-min-hits", security.DefaultSigmaMinHits, "Times a payload must be seen before --export-sigma includes it")
+	analyseCmd.Flags().StringVar(&exportSTIX, "export-stix", "", "Export the IOCs of the detected incidents (IPs, user agents, payload patterns) as a STIX 2.1 bundle to file (not with --stream)")
+	analyseCmd.Flags().StringVar(&exportTimeline, "export-timeline", "", "Export the timelines of the detected incidents, with their attack phases and events, as JSON to file (not with --stream)")
+	analyseCmd.Flags().StringVar(&compareSince, "compare-since", "", "Start of comparison window compared against --since/--until (YYYY-MM-DD HH:MM:SS)")
+	analyseCmd.Flags().StringVar(&compareUntil, "compare-until", "", "End of comparison window compared against --since/--until (YYYY-MM-DD HH:MM:SS)")
+	analyseCmd.Flags().StringVar(&comparisonHTML, "export-comparison-html", "", "Export a side-by-side HTML report of the --since/--until window (A) and the comparison window (B)")
+	analyseCmd.Flags().StringVar(&focusIP, "focus-ip", "", "Produce a full drill-down profile for a single IP address")
+	analyseCmd.Flags().BoolVar(&showCrawlBudget, "crawl-budget", false, "Show search engine crawl budget report (crawl per section, 404/redirect crawls, wasted budget, new URLs per day)")
+	analyseCmd.Flags().BoolVar(&verifyCrawlers, "verify-crawlers", false, "Verify search engine crawler IPs with reverse DNS before including them in the crawl budget report")
+	analyseCmd.Flags().BoolVar(&showEgressCost, "egress-cost", false, "Estimate data transfer cost by client region and endpoint")
+	analyseCmd.Flags().StringVar(&egressConfigFile, "egress-config", "", "Egress pricing file (default: <config-dir>/egress.yaml, built-in prices if missing)")
+	analyseCmd.Flags().Float64Var(&costPerGB, "cost-per-gb", 0, "Flat egress price per GB, overriding the regional prices (implies --egress-cost)")
+	analyseCmd.Flags().StringVar(&baselineLog, "baseline-log", "", "Learn anomaly baselines (expected error and 404 rates) from a known-good reference log")
+	analyseCmd.Flags().BoolVar(&saveBaselines, "save-baselines", false, "Save the baselines learned with --baseline-log to the configuration file")
+	analyseCmd.Flags().StringSliceVar(&countryFilter, "country", nil, "Only analyse requests from these countries (code or name, e.g. NZ,US)")
+	analyseCmd.Flags().BoolVar(&showCountries, "countries", false, "Show per-country requests, error rate and bandwidth")
+	analyseCmd.Flags().StringVar(&geoIPDatabase, "geoip-db", "", "GeoIP country CSV database (start,end,country[,name] ranges; e.g. DB-IP or IP2Location LITE)")
+	analyseCmd.Flags().BoolVar(&showStaticSplit, "static-split", false, "Show static asset vs dynamic request metrics and static assets likely missing cache headers")
+	analyseCmd.Flags().BoolVar(&showCapacity, "capacity", false, "Show requests-per-second percentiles and estimated concurrency for capacity planning")
+	analyseCmd.Flags().DurationVar(&serviceTime, "service-time", analyser.DefaultServiceTime, "Assumed average request duration used to estimate concurrency")
+	analyseCmd.Flags().StringVar(&exfilBytes, "exfil-bytes", "1GB", "Bytes downloaded by one IP that are always flagged as a possible exfiltration (0 disables)")
+	analyseCmd.Flags().IntVar(&exfilUploads, "exfil-uploads", 500, "Upload (POST/PUT/PATCH) requests from one IP that are always flagged (0 disables)")
+	analyseCmd.Flags().Float64Var(&exfilZScore, "exfil-zscore", 3.0, "Flag IPs whose download bytes or upload requests are this many standard deviations above the mean (0 disables)")
+	analyseCmd.Flags().StringArrayVar(&groupSpecs, "group", nil, "Analyse a labelled group of files, e.g. --group site1=a.log,b.log (repeatable); reports each group and a combined roll-up")
+	analyseCmd.Flags().BoolVar(&showLatencyHeatmap, "latency-heatmap", false, "Show an hour × endpoint P95 latency heatmap (requires $request_time in the log format)")
+	analyseCmd.Flags().StringVar(&sizeBuckets, "size-buckets", "", "Response size histogram bucket bounds, e.g. '1KB,10KB,100KB,1MB,10MB' (default)")
+	analyseCmd.Flags().BoolVar(&streamMode, "stream", false, "Analyse files in parallel without loading all entries into memory (disables --query, --focus-ip, --trend-analysis and comparisons)")
+	analyseCmd.Flags().StringVar(&botConfigFile, "bot-config", "", "Bot signature file (default: <config-dir>/bots.yaml, built-in list if missing)")
+	analyseCmd.Flags().StringVar(&failOnSeverity, "fail-on-severity", "", "Exit non-zero when threats at or above this severity are found ("+strings.Join(security.Severities, ", ")+"): 2 Info, 3 Low, 4 Medium, 5 High, 6 Critical by the highest one, 1 when the check fails")
+	analyseCmd.Flags().BoolVar(&securityAnalysis, "security-analysis", false, "Run the full security analysis (threats, anomalies, incidents and risk score) and show its dashboard")
+	analyseCmd.Flags().BoolVar(&securityDetails, "security-details", false, "With the security dashboard, also show the detailed threat, anomaly and recommendation reports (implies --security-analysis)")
+	analyseCmd.Flags().StringVar(&securityRulesFile, "security-rules", "", "Custom threat detection rules file (default: <config-dir>/security-rules.yaml, if present)")
+	analyseCmd.Flags().StringVar(&cveSignaturesFile, "cve-signatures", "", "Known exploit signature file (default: <config-dir>/"+security.CVESignaturesFilename+", built-in set if missing)")
+	analyseCmd.Flags().BoolVar(&recordSecurityHistory, "record-security-history", false, "Record this run's failed logins per IP in the security history, so later runs detect low-and-slow brute force across runs")
+	analyseCmd.Flags().StringVar(&securityHistoryFile, "security-history", "", "Security history file (default: "+security.DefaultBehaviorStoreFile+" in the config directory)")
+	analyseCmd.Flags().BoolVar(&recordReputation, "record-reputation", false, "Record this run's threats and IP behavior in the IP reputation store, so later runs start known attackers with elevated risk")
+	analyseCmd.Flags().StringVar(&ipReputationFile, "ip-reputation", "", "IP reputation store file (default: "+security.DefaultReputationStoreFile+" in the config directory)")
 }
 
 func printResults(results *analyser.Results) {
@@ -258,6 +907,14 @@ func printResults(results *analyser.Results) {
 		results.TimeRange.Start.Format("2006-01-02 15:04:05"),
 		results.TimeRange.End.Format("2006-01-02 15:04:05"))
 
+	// Activity over time
+	if sparklines, ok := charts.NewTimelineSparklines(results.Timeline, 48); ok {
+		fmt.Printf("📈 Activity (%s)\n", sparklines.Describe())
+		fmt.Printf("├─ Requests:   %s\n", sparklines.Traffic)
+		fmt.Printf("├─ Error Rate: %s\n", sparklines.ErrorRate)
+		fmt.Printf("└─ Bandwidth:  %s\n\n", sparklines.Bandwidth)
+	}
+
 	// Traffic Analysis (Bot vs Human)
 	if results.BotRequests > 0 || results.HumanRequests > 0 {
 		fmt.Printf("🤖 Traffic Analysis\n")
@@ -329,6 +986,7 @@ func printResults(results *analyser.Results) {
 		for i, peak := range results.TrafficPeaks {
 			fmt.Printf("├─ Peak #%d: %s - %s requests (%s)\n", 
 				i+1, peak.Time, formatNumber(peak.RequestCount), peak.Duration)
+			printSpikeAttribution(peak.Attribution)
 		}
 		fmt.Println()
 	}
@@ -342,6 +1000,14 @@ func printResults(results *analyser.Results) {
 		fmt.Printf("├─ 99th Percentile: %s\n", formatBytes(results.ResponseTimeStats.P99Size))
 		fmt.Printf("├─ Range: %s - %s\n", formatBytes(results.ResponseTimeStats.MinSize), formatBytes(results.ResponseTimeStats.MaxSize))
 		
+		if len(results.SizeHistogram) > 0 {
+			fmt.Printf("├─ Size Distribution:\n")
+			for _, bucket := range results.SizeHistogram {
+				percentage := float64(bucket.Count) / float64(results.TotalRequests) * 100
+				fmt.Printf("│  ├─ %-12s %s requests (%.1f%%)\n", bucket.Label+":", formatNumber(bucket.Count), percentage)
+			}
+		}
+		
 		if len(results.ResponseTimeStats.SlowRequests) > 0 {
 			fmt.Printf("├─ Slowest Endpoints (by size):\n")
 			for i, req := range results.ResponseTimeStats.SlowRequests {
@@ -411,9 +1077,38 @@ func printResults(results *analyser.Results) {
 	// HTTP Methods
 	if len(results.HTTPMethods) > 0 {
 		fmt.Printf("🔧 HTTP Methods\n")
+		details := make(map[string]analyser.MethodDetail)
+		for _, detail := range results.MethodDetails {
+			details[detail.Method] = detail
+		}
 		for _, method := range results.HTTPMethods {
 			percentage := float64(method.Count) / float64(results.TotalRequests) * 100
-			fmt.Printf("├─ %s: %s (%.1f%%)\n", method.Method, formatNumber(method.Count), percentage)
+			detail, known := details[method.Method]
+			if !known {
+				fmt.Printf("├─ %s: %s (%.1f%%)\n", method.Method, formatNumber(method.Count), percentage)
+				continue
+			}
+			warning := ""
+			if detail.Unusual {
+				warning = fmt.Sprintf(" ⚠️  unusual (%s) from %s IPs", detail.Category, formatNumber(detail.UniqueIPs))
+			}
+			fmt.Printf("├─ %s: %s (%.1f%%), %.1f%% errors%s\n", method.Method, formatNumber(method.Count), percentage, detail.ErrorRate, warning)
+		}
+		fmt.Println()
+	}
+
+	// HTTP Protocol Versions
+	if len(results.Protocols) > 0 {
+		fmt.Printf("📡 HTTP Protocol Versions\n")
+		for _, protocol := range results.Protocols {
+			percentage := float64(protocol.Count) / float64(results.TotalRequests) * 100
+			legacy := ""
+			if analyser.IsLegacyProtocol(protocol.Protocol) {
+				legacy = " [legacy]"
+			}
+			fmt.Printf("├─ %s: %s (%.1f%%) - %.1f%% errors, %s avg%s\n",
+				protocol.Protocol, formatNumber(protocol.Count), percentage,
+				protocol.ErrorRate, formatBytes(protocol.AverageSize), legacy)
 		}
 		fmt.Println()
 	}
@@ -440,10 +1135,10 @@ func printResults(results *analyser.Results) {
 	fmt.Println()
 
 	// Top IPs
-	fmt.Printf("🌐 Top %d IP Addresses\n", topIPs)
+	fmt.Printf("🌐 %s IP Addresses\n", topLabel(topIPs))
 	count := 0
 	for _, ip := range results.TopIPs {
-		if count >= topIPs {
+		if topIPs != analyser.TopAll && count >= topIPs {
 			break
 		}
 		percentage := float64(ip.Count) / float64(results.TotalRequests) * 100
@@ -453,10 +1148,10 @@ func printResults(results *analyser.Results) {
 	fmt.Println()
 
 	// Top URLs
-	fmt.Printf("🔗 Top %d URLs\n", topURLs)
+	fmt.Printf("🔗 %s URLs\n", topLabel(topURLs))
 	count = 0
 	for _, url := range results.TopURLs {
-		if count >= topURLs {
+		if topURLs != analyser.TopAll && count >= topURLs {
 			break
 		}
 		percentage := float64(url.Count) / float64(results.TotalRequests) * 100
@@ -470,6 +1165,46 @@ func printResults(results *analyser.Results) {
 	}
 	fmt.Println()
 	
+	// Endpoint table (only show if requested)
+	if showEndpoints && len(results.EndpointStats) > 0 {
+		printEndpointStats(results.EndpointStats)
+	}
+	
+	// Broken link report (only show if requested)
+	if showBrokenLinks {
+		printBrokenLinks(results.BrokenLinks)
+	}
+	
+	// Rate-limit candidates (only show if requested)
+	if showRateLimits {
+		printRateLimitCandidates(results.RateLimitCandidates)
+	}
+	
+	// Crawl budget report (only show if requested)
+	if showCrawlBudget {
+		printCrawlBudget(results.CrawlBudget)
+	}
+	
+	// Egress cost estimate (only present when pricing is configured)
+	if results.EgressCost != nil {
+		printEgressCost(results.EgressCost)
+	}
+	
+	// Per-country breakdown
+	if showCountries && len(results.CountryStats) > 0 {
+		printCountryStats(results.CountryStats)
+	}
+	
+	// Static asset vs dynamic request split
+	if showStaticSplit {
+		printStaticDynamic(results.StaticDynamic)
+	}
+	
+	// Throughput and concurrency estimates
+	if showCapacity && results.Capacity.PeakRPS > 0 {
+		printCapacity(results.Capacity)
+	}
+	
 	// Error Analysis (only show if there are errors and details are requested)
 	if showDetails && len(results.ErrorURLs) > 0 {
 		fmt.Printf("⚠️  Error Analysis\n")
@@ -500,7 +1235,7 @@ func printResults(results *analyser.Results) {
 	}
 	
 	// Security Analysis - show when details are requested or threats detected
-	if showDetails || results.SecurityAnalysis.TotalThreats > 0 {
+	if showDetails || results.SecurityAnalysis.TotalThreats > 0 || len(results.DataVolume.Anomalies) > 0 {
 		threatEmoji := getThreatEmoji(results.SecurityAnalysis.ThreatLevel)
 		fmt.Printf("%s Security Analysis (Threat Level: %s, Score: %d/100)\n", 
 			threatEmoji, 
@@ -517,7 +1252,8 @@ func printResults(results *analyser.Results) {
 		   results.SecurityAnalysis.XSSAttempts > 0 ||
 		   results.SecurityAnalysis.DirectoryTraversal > 0 ||
 		   results.SecurityAnalysis.BruteForceAttempts > 0 ||
-		   results.SecurityAnalysis.ScanningActivity > 0 {
+		   results.SecurityAnalysis.ScanningActivity > 0 ||
+		   results.SecurityAnalysis.MethodProbing > 0 {
 			fmt.Printf("├─ Attack Breakdown:\n")
 			
 			if results.SecurityAnalysis.SQLInjectionAttempts > 0 {
@@ -535,6 +1271,9 @@ func printResults(results *analyser.Results) {
 			if results.SecurityAnalysis.ScanningActivity > 0 {
 				fmt.Printf("│  ├─ Scanning Activity: %s instances\n", formatNumber(results.SecurityAnalysis.ScanningActivity))
 			}
+			if results.SecurityAnalysis.MethodProbing > 0 {
+				fmt.Printf("│  ├─ Unusual HTTP Methods: %s requests\n", formatNumber(results.SecurityAnalysis.MethodProbing))
+			}
 		}
 		
 		// Show top attackers
@@ -551,6 +1290,9 @@ func printResults(results *analyser.Results) {
 						if len(suspiciousIP.ThreatCategories) > 0 {
 							fmt.Printf(", %s", strings.Join(suspiciousIP.ThreatCategories, ", "))
 						}
+						if len(suspiciousIP.MITRETechniques) > 0 {
+							fmt.Printf(", ATT&CK %s", strings.Join(suspiciousIP.MITRETechniques, " "))
+						}
 						fmt.Printf(")")
 						break
 					}
@@ -587,6 +1329,21 @@ func printResults(results *analyser.Results) {
 			}
 		}
 		
+		// Show abnormal transfer volumes (possible data exfiltration)
+		if len(results.DataVolume.Anomalies) > 0 {
+			fmt.Printf("├─ Abnormal Data Volumes:\n")
+			for i, anomaly := range results.DataVolume.Anomalies {
+				if i >= 5 { break } // Show top 5 IPs
+				if anomaly.Direction == analyser.VolumeUpload {
+					fmt.Printf("│  ├─ %s made %s upload requests (z-score %.1f, %s)\n",
+						anomaly.IP, formatNumber(anomaly.UploadRequests), anomaly.ZScore, anomaly.Reason)
+				} else {
+					fmt.Printf("│  ├─ %s downloaded %s in %s requests (z-score %.1f, %s)\n",
+						anomaly.IP, formatBytes(anomaly.Bytes), formatNumber(anomaly.Requests), anomaly.ZScore, anomaly.Reason)
+				}
+			}
+		}
+		
 		// Show anomalies if detected
 		if len(results.SecurityAnalysis.AnomaliesDetected) > 0 {
 			fmt.Printf("└─ Anomalies Detected:\n")
@@ -609,50 +1366,489 @@ func printResults(results *analyser.Results) {
 		fmt.Printf("═══════════════\n\n")
 		
 		// Generate charts
-		generator := charts.NewChartGenerator()
-		generator.SetWidth(chartWidth)
-		generator.SetColors(!noColors && charts.SupportsColor())
+		generator := newChartGenerator()
 		
 		// Display selected charts
 		fmt.Print(generator.GenerateStatusCodeChart(results))
 		fmt.Println()
 		
-		fmt.Print(generator.GenerateTopIPsChart(results, topIPs))
+		fmt.Print(generator.GenerateTopIPsChart(results, chartLimit(topIPs, len(results.TopIPs))))
 		fmt.Println()
 		
-		fmt.Print(generator.GenerateTopURLsChart(results, topURLs))
+		fmt.Print(generator.GenerateTopURLsChart(results, chartLimit(topURLs, len(results.TopURLs))))
 		fmt.Println()
 		
 		fmt.Print(generator.GenerateBotTrafficChart(results))
 		fmt.Println()
 		
-		fmt.Print(generator.GenerateGeographicChart(results))
+		fmt.Print(generator.GenerateGeographicChart(results, topCountries))
+		fmt.Println()
+		
+		fmt.Print(generator.GenerateResponseSizeChart(results))
+		fmt.Println()
+		
+		fmt.Print(generator.GenerateSessionLengthChart(results))
+		fmt.Println()
+		
+		fmt.Print(generator.GenerateTrafficHeatmap(results))
+		fmt.Println()
+		
+		fmt.Print(generator.GenerateTimeSeriesChart(results, chartBucket))
+		fmt.Println()
+		
+		if results.LatencyHeatmap != nil {
+			fmt.Print(generator.GenerateLatencyHeatmap(results))
+			fmt.Println()
+			
+			fmt.Print(generator.GenerateLatencyHistogram(results))
+			fmt.Println()
+		}
+		
+		writeChartData(generator)
+	}
+	
+	// Latency heatmap on its own when charts are not requested
+	if showLatencyHeatmap && !asciiCharts {
+		generator := newChartGenerator()
+		fmt.Print(generator.GenerateLatencyHeatmap(results))
 		fmt.Println()
+		writeChartData(generator)
 	}
 }
 
-// Helper function to format numbers with commas
-func formatNumber(num int) string {
-	str := fmt.Sprintf("%d", num)
-	if len(str) <= 3 {
-		return str
+// printSpikeAttribution lists the top contributors to a traffic peak
+func printSpikeAttribution(attribution *analyser.SpikeAttribution) {
+	if attribution == nil {
+		return
 	}
 	
-	result := ""
-	for i, char := range str {
-		if i > 0 && (len(str)-i)%3 == 0 {
-			result += ","
+	dimensions := []struct {
+		name         string
+		contributors []analyser.SpikeContributor
+	}{
+		{"IPs", attribution.IPs},
+		{"URLs", attribution.URLs},
+		{"User Agents", attribution.UserAgents},
+		{"Referers", attribution.Referers},
+	}
+	
+	for _, dimension := range dimensions {
+		if len(dimension.contributors) == 0 {
+			continue
+		}
+		fmt.Printf("│  ├─ Top %s vs baseline:\n", dimension.name)
+		for i, contributor := range dimension.contributors {
+			if i >= 3 { break } // Show top 3 per dimension
+			key := contributor.Key
+			if len(key) > 50 {
+				key = key[:47] + "..."
+			}
+			fmt.Printf("│  │  ├─ %s: %s requests (baseline %.1f, %.0f%% of spike)\n",
+				key, formatNumber(contributor.PeakCount), contributor.BaselineCount, contributor.Share)
 		}
-		result += string(char)
 	}
-	return result
 }
 
-// Helper function to format bytes in human-readable format
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// printEndpointStats displays the per-endpoint performance table
+func printEndpointStats(stats []analyser.EndpointStat) {
+	fmt.Printf("🎯 Endpoint Performance (sorted by %s)\n", endpointSort)
+	fmt.Printf("%-7s %-40s %9s %7s %10s %10s %10s %10s\n",
+		"METHOD", "ENDPOINT", "REQUESTS", "ERR%", "P50", "P95", "P99", "BYTES")
+	for i, stat := range stats {
+		if i >= topEndpoints {
+			break
+		}
+		displayURL := stat.Endpoint
+		if len(displayURL) > 40 {
+			displayURL = displayURL[:37] + "..."
+		}
+		fmt.Printf("%-7s %-40s %9s %6.1f%% %10s %10s %10s %10s\n",
+			stat.Method, displayURL, formatNumber(stat.Count), stat.ErrorRate,
+			formatBytes(stat.P50Size), formatBytes(stat.P95Size), formatBytes(stat.P99Size),
+			formatBytes(stat.TotalBytes))
+	}
+	fmt.Println()
+}
+
+// printBrokenLinks displays 404 URLs with the pages that linked to them
+func printBrokenLinks(links []analyser.BrokenLink) {
+	fmt.Printf("🔗 Broken Links (404)\n")
+	if len(links) == 0 {
+		fmt.Printf("└─ No 404 responses found\n\n")
+		return
+	}
+	
+	for _, link := range links {
+		displayURL := link.URL
+		if len(displayURL) > 60 {
+			displayURL = displayURL[:57] + "..."
+		}
+		fmt.Printf("├─ %s: %s hits (internal: %d, external: %d, direct: %d)\n",
+			displayURL, formatNumber(link.Count), link.InternalReferers, link.ExternalReferers, link.DirectHits)
+		for i, referer := range link.Referers {
+			if i >= 5 { break } // Show top 5 referring pages
+			source := "external"
+			if referer.Internal {
+				source = "internal"
+			}
+			displayReferer := referer.Referer
+			if len(displayReferer) > 60 {
+				displayReferer = displayReferer[:57] + "..."
+			}
+			fmt.Printf("│  ├─ [%s] %s (%d)\n", source, displayReferer, referer.Count)
+		}
+	}
+	fmt.Println()
+}
+
+// printRateLimitCandidates displays clients and endpoints exceeding the rate-limit thresholds
+func printRateLimitCandidates(candidates []analyser.RateLimitCandidate) {
+	fmt.Printf("🚦 Rate-Limit Candidates (>%d req/min for %d+ minutes)\n", rateLimitRPM, rateLimitSustained)
+	if len(candidates) == 0 {
+		fmt.Printf("└─ No clients exceeded the threshold\n\n")
+		return
+	}
+	
+	for i, candidate := range candidates {
+		if i >= 10 { break } // Show top 10 candidates
+		fmt.Printf("├─ [%s] %s: peak %d req/min at %s, avg %.1f req/min, sustained %d min\n",
+			candidate.Type, candidate.Key, candidate.PeakPerMinute,
+			candidate.PeakTime.Format("2006-01-02 15:04"), candidate.AveragePerMinute, candidate.SustainedMinutes)
+	}
+	fmt.Printf("└─ Use --export-nginx-limits to generate suggested limit_req zones\n\n")
+}
+
+// printCrawlBudget displays how search engine crawlers spent their requests
+func printCrawlBudget(report analyser.CrawlBudgetReport) {
+	fmt.Printf("🕷️  Search Engine Crawl Budget\n")
+	if report.VerificationEnabled {
+		fmt.Printf("├─ Crawler IPs verified by reverse DNS (%s unverified requests excluded)\n", formatNumber(report.UnverifiedRequests))
+	}
+	if report.TotalRequests == 0 {
+		fmt.Printf("└─ No search engine crawler requests found\n\n")
+		return
+	}
+	
+	fmt.Printf("├─ Crawler Requests: %s\n", formatNumber(report.TotalRequests))
+	for _, crawler := range report.Crawlers {
+		fmt.Printf("│  ├─ %s: %s requests, %s unique URLs, %s errors, %s redirects\n",
+			crawler.Name, formatNumber(crawler.Requests), formatNumber(crawler.UniqueURLs),
+			formatNumber(crawler.Errors), formatNumber(crawler.Redirects))
+	}
+	
+	fmt.Printf("├─ Wasted Crawl Budget: %.1f%% (redirects %s, errors %s, parameterised URLs %s)\n",
+		report.WastedPercent, formatNumber(report.WastedOnRedirects),
+		formatNumber(report.WastedOnErrors), formatNumber(report.WastedOnParameters))
+	
+	fmt.Printf("├─ Crawl Frequency by Section:\n")
+	for i, section := range report.Sections {
+		if i >= 10 { break } // Show top 10 sections
+		fmt.Printf("│  ├─ %s: %s requests (%.1f/day), %s URLs, %s wasted\n",
+			section.Section, formatNumber(section.Requests), section.CrawlsPerDay,
+			formatNumber(section.UniqueURLs), formatNumber(section.WastedRequests))
+	}
+	
+	if len(report.NotFoundURLs) > 0 {
+		fmt.Printf("├─ Crawled 404/410 URLs:\n")
+		for i, url := range report.NotFoundURLs {
+			if i >= 5 { break } // Show top 5
+			fmt.Printf("│  ├─ %s: %s crawls\n", url.URL, formatNumber(url.Count))
+		}
+	}
+	
+	if len(report.RedirectURLs) > 0 {
+		fmt.Printf("├─ Crawled Redirects:\n")
+		for i, url := range report.RedirectURLs {
+			if i >= 5 { break } // Show top 5
+			fmt.Printf("│  ├─ %s: %s crawls\n", url.URL, formatNumber(url.Count))
+		}
+	}
+	
+	fmt.Printf("└─ Newly Discovered URLs per Day:\n")
+	for _, discovery := range report.Discoveries {
+		fmt.Printf("   ├─ %s: %s new URLs (%s crawler requests)\n",
+			discovery.Date, formatNumber(discovery.NewURLs), formatNumber(discovery.Requests))
+	}
+	fmt.Println()
+}
+
+// printEgressCost displays the estimated data transfer cost
+func printEgressCost(report *analyser.EgressCostReport) {
+	fmt.Printf("💰 Egress Cost Estimate\n")
+	fmt.Printf("├─ Total: %.2f %s for %s\n", report.TotalCost, report.Currency, formatBytes(report.TotalBytes))
+	
+	if len(report.Regions) > 0 {
+		fmt.Printf("├─ By Region:\n")
+		for _, region := range report.Regions {
+			fmt.Printf("│  ├─ %s: %.2f %s (%s at %.3f/GB)\n",
+				region.Region, region.Cost, report.Currency, formatBytes(region.Bytes), region.RatePerGB)
+		}
+	}
+	
+	if len(report.Endpoints) > 0 {
+		fmt.Printf("└─ Most Expensive Endpoints:\n")
+		for i, endpoint := range report.Endpoints {
+			if i >= 10 { break } // Show top 10 endpoints
+			displayEndpoint := endpoint.Endpoint
+			if len(displayEndpoint) > 50 {
+				displayEndpoint = displayEndpoint[:47] + "..."
+			}
+			fmt.Printf("   ├─ %s: %.4f %s (%s over %s requests)\n",
+				displayEndpoint, endpoint.Cost, report.Currency, formatBytes(endpoint.Bytes), formatNumber(endpoint.Requests))
+		}
+	}
+	fmt.Println()
+}
+
+// printCountryStats displays requests, error rate and bandwidth per country
+func printCountryStats(stats []analyser.CountryStat) {
+	fmt.Printf("🌍 Traffic by Country\n")
+	fmt.Printf("   %-24s %10s %8s %8s %10s %8s\n", "Country", "Requests", "Errors", "Err %", "Bandwidth", "IPs")
+	for i, stat := range stats {
+		if i >= 20 { break } // Show top 20 countries
+		country := stat.Country
+		if len(country) > 24 {
+			country = country[:21] + "..."
+		}
+		fmt.Printf("   %-24s %10s %8s %7.1f%% %10s %8s\n",
+			country, formatNumber(stat.Requests), formatNumber(stat.Errors), stat.ErrorRate,
+			formatBytes(stat.Bytes), formatNumber(stat.UniqueIPs))
+	}
+	fmt.Println()
+}
+
+// printStaticDynamic displays static vs dynamic metrics and cache header candidates
+func printStaticDynamic(report analyser.StaticDynamicReport) {
+	fmt.Printf("🗂️  Static vs Dynamic Requests\n")
+	fmt.Printf("   %-8s %10s %8s %10s %10s %10s %10s\n", "Class", "Requests", "Err %", "Bandwidth", "Avg Size", "Median", "P95")
+	for _, stat := range []analyser.ContentClassStat{report.Static, report.Dynamic} {
+		fmt.Printf("   %-8s %10s %7.1f%% %10s %10s %10s %10s\n",
+			stat.Class, formatNumber(stat.Requests), stat.ErrorRate, formatBytes(stat.Bytes),
+			formatBytes(stat.AverageSize), formatBytes(stat.MedianSize), formatBytes(stat.P95Size))
+	}
+	
+	if len(report.CacheCandidates) > 0 {
+		fmt.Printf("└─ Static Assets Possibly Missing Cache Headers (repeat full downloads by the same IP):\n")
+		for i, candidate := range report.CacheCandidates {
+			if i >= 10 { break } // Show top 10 assets
+			displayURL := candidate.URL
+			if len(displayURL) > 50 {
+				displayURL = displayURL[:47] + "..."
+			}
+			fmt.Printf("   ├─ %s: %d repeat downloads by %d IPs (%s wasted)\n",
+				displayURL, candidate.RepeatRequests, candidate.RepeatClients, formatBytes(candidate.WastedBytes))
+		}
+	}
+	fmt.Println()
+}
+
+// printCapacity displays requests-per-second percentiles and concurrency estimates
+func printCapacity(stats analyser.CapacityStats) {
+	fmt.Printf("📈 Capacity Metrics\n")
+	fmt.Printf("├─ Peak: %d req/s at %s\n", stats.PeakRPS, stats.PeakTime.Format("2006-01-02 15:04:05"))
+	fmt.Printf("├─ Per Second: avg %.2f, P50 %.0f, P95 %.0f, P99 %.0f req/s\n",
+		stats.AverageRPS, stats.P50RPS, stats.P95RPS, stats.P99RPS)
+	fmt.Printf("├─ 10s Windows: peak %.1f, P99 %.1f req/s\n", stats.Peak10sRPS, stats.P99RPS10s)
+	fmt.Printf("├─ Active Seconds: %s\n", formatNumber(stats.ActiveSeconds))
+	fmt.Printf("└─ Estimated Concurrency (at %s per request): avg %.1f, P99 %.1f, peak %.1f\n",
+		stats.ServiceTime, stats.AverageConcurrency, stats.P99Concurrency, stats.PeakConcurrency)
+	fmt.Println()
+}
+
+// printGroupSummary displays the key metrics of each log group side by side
+func printGroupSummary(groups []analyser.GroupResult) {
+	fmt.Printf("🏷️  Per-Group Breakdown\n")
+	fmt.Printf("   %-20s %6s %10s %10s %8s %10s %8s\n", "Group", "Files", "Requests", "Unique IPs", "Err %", "Bandwidth", "Bots %")
+	for _, group := range groups {
+		name := group.Name
+		if len(name) > 20 {
+			name = name[:17] + "..."
+		}
+		botShare := 0.0
+		if group.Results.TotalRequests > 0 {
+			botShare = float64(group.Results.BotRequests) / float64(group.Results.TotalRequests) * 100
+		}
+		fmt.Printf("   %-20s %6d %10s %10s %7.1f%% %10s %7.1f%%\n",
+			name, len(group.Files), formatNumber(group.Results.TotalRequests), formatNumber(group.Results.UniqueIPs),
+			group.ErrorRate, formatBytes(group.Results.TotalBytes), botShare)
+	}
+	fmt.Println()
+}
+
+// printComparison displays metric deltas between the analysis window and the comparison window
+func printComparison(comparison *analyser.Comparison) {
+	fmt.Printf("╔════════════════════════════════════════════════════════════════╗\n")
+	fmt.Printf("║                      Window Comparison                         ║\n")
+	fmt.Printf("╚════════════════════════════════════════════════════════════════╝\n\n")
+	
+	fmt.Printf("📅 Window A: %s to %s\n",
+		comparison.Baseline.Start.Format("2006-01-02 15:04:05"),
+		comparison.Baseline.End.Format("2006-01-02 15:04:05"))
+	fmt.Printf("📅 Window B: %s to %s\n\n",
+		comparison.Current.Start.Format("2006-01-02 15:04:05"),
+		comparison.Current.End.Format("2006-01-02 15:04:05"))
+	
+	fmt.Printf("%-18s %15s %15s %15s %9s\n", "METRIC", "A", "B", "CHANGE", "%")
+	for _, metric := range comparison.Metrics {
+		percent := "n/a"
+		if metric.Before != 0 {
+			percent = fmt.Sprintf("%+.1f%%", metric.PercentChange)
+		}
+		fmt.Printf("%-18s %15.1f %15.1f %+15.1f %9s\n",
+			metric.Name, metric.Before, metric.After, metric.Change, percent)
+	}
+	fmt.Println()
+	
+	if len(comparison.URLMovers) > 0 {
+		fmt.Printf("🔗 Top URL Movers\n")
+		for _, mover := range comparison.URLMovers {
+			displayURL := mover.Key
+			if len(displayURL) > 50 {
+				displayURL = displayURL[:47] + "..."
+			}
+			fmt.Printf("├─ %s: %s → %s (%+d)\n", displayURL, formatNumber(mover.Before), formatNumber(mover.After), mover.Change)
+		}
+		fmt.Println()
+	}
+	
+	if len(comparison.IPMovers) > 0 {
+		fmt.Printf("🌐 Top IP Movers\n")
+		for _, mover := range comparison.IPMovers {
+			fmt.Printf("├─ %s: %s → %s (%+d)\n", mover.Key, formatNumber(mover.Before), formatNumber(mover.After), mover.Change)
+		}
+		fmt.Println()
+	}
+}
+
+// printIPProfile displays the drill-down profile for a single IP address
+func printIPProfile(profile *analyser.IPProfile) {
+	fmt.Printf("╔════════════════════════════════════════════════════════════════╗\n")
+	fmt.Printf("║                        IP Profile Report                       ║\n")
+	fmt.Printf("╚════════════════════════════════════════════════════════════════╝\n\n")
+	
+	fmt.Printf("🔎 %s\n", profile.IP)
+	fmt.Printf("├─ Total Requests: %s\n", formatNumber(profile.TotalRequests))
+	fmt.Printf("├─ Data Transferred: %s\n", formatBytes(profile.TotalBytes))
+	fmt.Printf("├─ Location: %s (%s)\n", profile.Country, profile.Region)
+	fmt.Printf("├─ First Seen: %s\n", profile.FirstSeen.Format("2006-01-02 15:04:05"))
+	fmt.Printf("└─ Last Seen: %s\n\n", profile.LastSeen.Format("2006-01-02 15:04:05"))
+	
+	fmt.Printf("📈 Status Codes\n")
+	for _, status := range profile.StatusCodes {
+		percentage := float64(status.Count) / float64(profile.TotalRequests) * 100
+		fmt.Printf("├─ %d: %s (%.1f%%)\n", status.Code, formatNumber(status.Count), percentage)
+	}
+	fmt.Println()
+	
+	fmt.Printf("🔧 HTTP Methods\n")
+	for _, method := range profile.Methods {
+		fmt.Printf("├─ %s: %s\n", method.Method, formatNumber(method.Count))
+	}
+	fmt.Println()
+	
+	fmt.Printf("🤖 User Agents\n")
+	for i, agent := range profile.UserAgents {
+		if i >= 5 { break } // Show top 5 user agents
+		displayAgent := agent.UserAgent
+		if displayAgent == "" {
+			displayAgent = "(none)"
+		} else if len(displayAgent) > 70 {
+			displayAgent = displayAgent[:67] + "..."
+		}
+		fmt.Printf("├─ %s: %s\n", displayAgent, formatNumber(agent.Count))
+	}
+	fmt.Println()
+	
+	fmt.Printf("🔗 URLs Accessed (%d unique)\n", len(profile.URLs))
+	for i, url := range profile.URLs {
+		if i >= 20 { break } // Show top 20 URLs
+		displayURL := url.URL
+		if len(displayURL) > 60 {
+			displayURL = displayURL[:57] + "..."
+		}
+		fmt.Printf("├─ %s: %s requests [%s]\n", displayURL, formatNumber(url.Count), url.FormatStatusCodes())
+	}
+	fmt.Println()
+	
+	fmt.Printf("🕒 Timeline\n")
+	for _, bucket := range profile.Timeline {
+		fmt.Printf("├─ %s: %s requests, %d errors, %s\n",
+			bucket.Hour.Format("2006-01-02 15:00"), formatNumber(bucket.Requests), bucket.Errors, formatBytes(bucket.Bytes))
+	}
+	fmt.Println()
+	
+	if profile.ThreatSummary != nil {
+		fmt.Printf("%s Threat Findings (Score: %d, %s)\n", getThreatEmoji("high"),
+			profile.ThreatSummary.ThreatScore, strings.Join(profile.ThreatSummary.ThreatCategories, ", "))
+		for i, threat := range profile.Threats {
+			if i >= 10 { break } // Show first 10 threats
+			fmt.Printf("├─ [%s] %s (%s): %s\n",
+				threat.Timestamp.Format("2006-01-02 15:04:05"), threat.Type, threat.Severity, threat.Pattern)
+		}
+	} else {
+		fmt.Printf("🔐 No threat findings for this IP\n")
+	}
+	fmt.Println()
+}
+
+// topLabel returns the heading prefix for a ranked list of n entries
+func topLabel(n int) string {
+	if n == analyser.TopAll {
+		return "All"
+	}
+	return fmt.Sprintf("Top %d", n)
+}
+
+// chartLimit converts a list limit into a chart bar count
+func chartLimit(n, available int) int {
+	if n == analyser.TopAll {
+		return available
+	}
+	return n
+}
+
+// exportTopN returns the --top list limit for exports, or def when it is not set
+func exportTopN(def int) int {
+	if topN == "" {
+		return def
+	}
+	n, err := analyser.ParseTopN(topN)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// percentOfCost returns cost as a percentage of total, or 0 when nothing was charged
+func percentOfCost(cost, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return cost / total * 100
+}
+
+// Helper function to format numbers with commas
+func formatNumber(num int) string {
+	str := fmt.Sprintf("%d", num)
+	if len(str) <= 3 {
+		return str
+	}
+	
+	result := ""
+	for i, char := range str {
+		if i > 0 && (len(str)-i)%3 == 0 {
+			result += ","
+		}
+		result += string(char)
+	}
+	return result
+}
+
+// Helper function to format bytes in human-readable format
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
 	}
 	
 	div, exp := int64(unit), 0
@@ -699,6 +1895,23 @@ func createSimpleBar(percentage float64, maxWidth int) string {
 }
 
 func exportToJSON(results *analyser.Results, filename string) error {
+	results = results.LimitTop(exportTopN(analyser.TopAll))
+	return exportValueToJSON(results, filename)
+}
+
+// exportGroupsToJSON writes the combined roll-up and each group's results
+func exportGroupsToJSON(combined *analyser.Results, groups []analyser.GroupResult, filename string) error {
+	limit := exportTopN(analyser.TopAll)
+	var limited []analyser.GroupResult
+	for _, group := range groups {
+		group.Results = group.Results.LimitTop(limit)
+		limited = append(limited, group)
+	}
+	return exportValueToJSON(analyser.NewGroupedResults(combined.LimitTop(limit), limited), filename)
+}
+
+// exportValueToJSON writes any value as indented JSON
+func exportValueToJSON(value interface{}, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -707,10 +1920,12 @@ func exportToJSON(results *analyser.Results, filename string) error {
 	
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(results)
+	return encoder.Encode(value)
 }
 
-func exportToCSV(results *analyser.Results, filename string) error {
+func exportToCSV(results *analyser.Results, groups []analyser.GroupResult, filename string) error {
+	results = results.LimitTop(exportTopN(analyser.DefaultExportTopN))
+	
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -730,6 +1945,13 @@ func exportToCSV(results *analyser.Results, filename string) error {
 	writer.Write([]string{"Overview", "Human Requests", strconv.Itoa(results.HumanRequests), fmt.Sprintf("%.1f", float64(results.HumanRequests)/float64(results.TotalRequests)*100)})
 	writer.Write([]string{"Overview", "Bot Requests", strconv.Itoa(results.BotRequests), fmt.Sprintf("%.1f", float64(results.BotRequests)/float64(results.TotalRequests)*100)})
 	
+	// Write activity sparklines
+	if sparklines, ok := charts.NewTimelineSparklines(results.Timeline, 60); ok {
+		writer.Write([]string{"Activity", "Requests (" + sparklines.Describe() + ")", sparklines.Traffic, ""})
+		writer.Write([]string{"Activity", "Error Rate (" + sparklines.Describe() + ")", sparklines.ErrorRate, ""})
+		writer.Write([]string{"Activity", "Bandwidth (" + sparklines.Describe() + ")", sparklines.Bandwidth, ""})
+	}
+	
 	// Write status codes
 	for status, count := range results.StatusCodes {
 		percentage := float64(count) / float64(results.TotalRequests) * 100
@@ -743,15 +1965,13 @@ func exportToCSV(results *analyser.Results, filename string) error {
 	}
 	
 	// Write top IPs
-	for i, ip := range results.TopIPs {
-		if i >= 20 { break } // Limit to top 20 for CSV
+	for _, ip := range results.TopIPs {
 		percentage := float64(ip.Count) / float64(results.TotalRequests) * 100
 		writer.Write([]string{"Top IPs", ip.IP, strconv.Itoa(ip.Count), fmt.Sprintf("%.1f", percentage)})
 	}
 	
 	// Write top URLs
-	for i, url := range results.TopURLs {
-		if i >= 20 { break } // Limit to top 20 for CSV
+	for _, url := range results.TopURLs {
 		percentage := float64(url.Count) / float64(results.TotalRequests) * 100
 		writer.Write([]string{"Top URLs", url.URL, strconv.Itoa(url.Count), fmt.Sprintf("%.1f", percentage)})
 	}
@@ -781,6 +2001,230 @@ func exportToCSV(results *analyser.Results, filename string) error {
 		writer.Write([]string{"Large Requests", url.URL, strconv.Itoa(url.Count), ""}) // Count field contains size
 	}
 	
+	// Write protocol versions
+	for _, protocol := range results.Protocols {
+		percentage := float64(protocol.Count) / float64(results.TotalRequests) * 100
+		writer.Write([]string{"Protocols", protocol.Protocol, strconv.Itoa(protocol.Count), fmt.Sprintf("%.1f", percentage)})
+		writer.Write([]string{"Protocol Error Rate", protocol.Protocol, strconv.Itoa(protocol.ErrorCount), fmt.Sprintf("%.1f", protocol.ErrorRate)})
+	}
+	
+	// Write HTTP methods with error rates
+	for _, method := range results.MethodDetails {
+		percentage := float64(method.Requests) / float64(results.TotalRequests) * 100
+		writer.Write([]string{"HTTP Methods", method.Method, strconv.Itoa(method.Requests), fmt.Sprintf("%.1f", percentage)})
+		writer.Write([]string{"HTTP Method Error Rate", method.Method, strconv.Itoa(method.Errors), fmt.Sprintf("%.1f", method.ErrorRate)})
+		if method.Unusual {
+			writer.Write([]string{"Unusual HTTP Methods", method.Method, strconv.Itoa(method.Requests), method.Category})
+		}
+	}
+	
+	// Write broken links with their referring pages
+	for _, link := range results.BrokenLinks {
+		writer.Write([]string{"Broken Links", link.URL, strconv.Itoa(link.Count), ""})
+		for _, referer := range link.Referers {
+			source := "External Referer"
+			if referer.Internal {
+				source = "Internal Referer"
+			}
+			writer.Write([]string{"Broken Link " + source, link.URL + " <- " + referer.Referer, strconv.Itoa(referer.Count), ""})
+		}
+	}
+	
+	// Write rate-limit candidates
+	for _, candidate := range results.RateLimitCandidates {
+		writer.Write([]string{"Rate Limit Candidates", candidate.Type + " " + candidate.Key, strconv.Itoa(candidate.PeakPerMinute), ""})
+	}
+	
+	// Write traffic peaks with their top contributors
+	for _, peak := range results.TrafficPeaks {
+		writer.Write([]string{"Traffic Peaks", peak.Time, strconv.Itoa(peak.RequestCount), ""})
+		if peak.Attribution == nil {
+			continue
+		}
+		for _, contributor := range peak.Attribution.IPs {
+			writer.Write([]string{"Peak Contributor IP", peak.Time + " " + contributor.Key, strconv.Itoa(contributor.PeakCount), fmt.Sprintf("%.1f", contributor.Share)})
+		}
+		for _, contributor := range peak.Attribution.URLs {
+			writer.Write([]string{"Peak Contributor URL", peak.Time + " " + contributor.Key, strconv.Itoa(contributor.PeakCount), fmt.Sprintf("%.1f", contributor.Share)})
+		}
+		for _, contributor := range peak.Attribution.UserAgents {
+			writer.Write([]string{"Peak Contributor User Agent", peak.Time + " " + contributor.Key, strconv.Itoa(contributor.PeakCount), fmt.Sprintf("%.1f", contributor.Share)})
+		}
+		for _, contributor := range peak.Attribution.Referers {
+			writer.Write([]string{"Peak Contributor Referer", peak.Time + " " + contributor.Key, strconv.Itoa(contributor.PeakCount), fmt.Sprintf("%.1f", contributor.Share)})
+		}
+	}
+	
+	// Write crawl budget report
+	if report := results.CrawlBudget; report.TotalRequests > 0 {
+		writer.Write([]string{"Crawl Budget", "Crawler Requests", strconv.Itoa(report.TotalRequests), ""})
+		writer.Write([]string{"Crawl Budget", "Wasted Requests", strconv.Itoa(report.WastedOnRedirects + report.WastedOnErrors + report.WastedOnParameters), fmt.Sprintf("%.1f", report.WastedPercent)})
+		for _, crawler := range report.Crawlers {
+			writer.Write([]string{"Crawlers", crawler.Name, strconv.Itoa(crawler.Requests), ""})
+		}
+		for _, section := range report.Sections {
+			writer.Write([]string{"Crawl Sections", section.Section, strconv.Itoa(section.Requests), fmt.Sprintf("%.1f", section.CrawlsPerDay)})
+		}
+		for _, url := range report.NotFoundURLs {
+			writer.Write([]string{"Crawled 404 URLs", url.URL, strconv.Itoa(url.Count), ""})
+		}
+		for _, url := range report.RedirectURLs {
+			writer.Write([]string{"Crawled Redirects", url.URL, strconv.Itoa(url.Count), ""})
+		}
+		for _, discovery := range report.Discoveries {
+			writer.Write([]string{"Crawl Discoveries", discovery.Date, strconv.Itoa(discovery.NewURLs), ""})
+		}
+	}
+	
+	// Write egress cost estimate
+	if report := results.EgressCost; report != nil {
+		writer.Write([]string{"Egress Cost", "Total " + report.Currency, fmt.Sprintf("%.4f", report.TotalCost), ""})
+		for _, region := range report.Regions {
+			writer.Write([]string{"Egress Cost by Region", region.Region, fmt.Sprintf("%.4f", region.Cost), fmt.Sprintf("%.1f", percentOfCost(region.Cost, report.TotalCost))})
+		}
+		for _, endpoint := range report.Endpoints {
+			writer.Write([]string{"Egress Cost by Endpoint", endpoint.Endpoint, fmt.Sprintf("%.4f", endpoint.Cost), fmt.Sprintf("%.1f", percentOfCost(endpoint.Cost, report.TotalCost))})
+		}
+	}
+	
+	// Write per-country breakdown
+	for _, stat := range results.CountryStats {
+		writer.Write([]string{"Country Requests", stat.Country, strconv.Itoa(stat.Requests), fmt.Sprintf("%.1f", float64(stat.Requests)/float64(results.TotalRequests)*100)})
+		writer.Write([]string{"Country Errors", stat.Country, strconv.Itoa(stat.Errors), fmt.Sprintf("%.1f", stat.ErrorRate)})
+		writer.Write([]string{"Country Bandwidth", stat.Country, strconv.FormatInt(stat.Bytes, 10), ""})
+	}
+	
+	// Write per-group breakdown
+	for _, group := range groups {
+		writer.Write([]string{"Group Requests", group.Name, strconv.Itoa(group.Results.TotalRequests), fmt.Sprintf("%.1f", float64(group.Results.TotalRequests)/float64(results.TotalRequests)*100)})
+		writer.Write([]string{"Group Errors", group.Name, strconv.Itoa(group.Results.StatusCodes["4xx Client Error"] + group.Results.StatusCodes["5xx Server Error"]), fmt.Sprintf("%.1f", group.ErrorRate)})
+		writer.Write([]string{"Group Bandwidth", group.Name, strconv.FormatInt(group.Results.TotalBytes, 10), ""})
+	}
+	
+	// Write abnormal data volumes
+	for _, anomaly := range results.DataVolume.Anomalies {
+		value := strconv.FormatInt(anomaly.Bytes, 10)
+		if anomaly.Direction == analyser.VolumeUpload {
+			value = strconv.Itoa(anomaly.UploadRequests)
+		}
+		writer.Write([]string{"Abnormal Data Volume (" + anomaly.Direction + ")", anomaly.IP, value, fmt.Sprintf("%.1f", anomaly.ZScore)})
+	}
+	
+	// Write latency heatmap regressions
+	if heatmap := results.LatencyHeatmap; heatmap != nil {
+		writer.Write([]string{"Latency", "Overall P95 (ms)", strconv.FormatInt(heatmap.OverallP95.Milliseconds(), 10), ""})
+		for _, hour := range heatmap.RegressedHours {
+			writer.Write([]string{"Latency Regressed Hour", fmt.Sprintf("%02d:00", hour), strconv.FormatInt(heatmap.HourP95[hour].Milliseconds(), 10), ""})
+		}
+		for i, endpoint := range heatmap.Endpoints {
+			for hour, cell := range heatmap.Cells[i] {
+				if cell.Regressed {
+					writer.Write([]string{"Latency Regressed Cell", endpoint, strconv.FormatInt(cell.P95.Milliseconds(), 10), fmt.Sprintf("%02d:00", hour)})
+				}
+			}
+		}
+	}
+	
+	// Write capacity metrics
+	if results.Capacity.PeakRPS > 0 {
+		capacity := results.Capacity
+		writer.Write([]string{"Capacity", "Peak RPS", strconv.Itoa(capacity.PeakRPS), ""})
+		writer.Write([]string{"Capacity", "Average RPS", fmt.Sprintf("%.2f", capacity.AverageRPS), ""})
+		writer.Write([]string{"Capacity", "P50 RPS", fmt.Sprintf("%.0f", capacity.P50RPS), ""})
+		writer.Write([]string{"Capacity", "P95 RPS", fmt.Sprintf("%.0f", capacity.P95RPS), ""})
+		writer.Write([]string{"Capacity", "P99 RPS", fmt.Sprintf("%.0f", capacity.P99RPS), ""})
+		writer.Write([]string{"Capacity", "Peak 10s RPS", fmt.Sprintf("%.1f", capacity.Peak10sRPS), ""})
+		writer.Write([]string{"Capacity", "P99 10s RPS", fmt.Sprintf("%.1f", capacity.P99RPS10s), ""})
+		writer.Write([]string{"Capacity", "Peak Concurrency", fmt.Sprintf("%.1f", capacity.PeakConcurrency), ""})
+	}
+	
+	// Write static vs dynamic split
+	for _, stat := range []analyser.ContentClassStat{results.StaticDynamic.Static, results.StaticDynamic.Dynamic} {
+		writer.Write([]string{"Content Class Requests", stat.Class, strconv.Itoa(stat.Requests), fmt.Sprintf("%.1f", stat.ErrorRate)})
+		writer.Write([]string{"Content Class Bandwidth", stat.Class, strconv.FormatInt(stat.Bytes, 10), ""})
+	}
+	for _, candidate := range results.StaticDynamic.CacheCandidates {
+		writer.Write([]string{"Cache Header Candidates", candidate.URL, strconv.Itoa(candidate.RepeatRequests), ""})
+	}
+	
+	// Write response size histogram
+	for _, bucket := range results.SizeHistogram {
+		percentage := float64(bucket.Count) / float64(results.TotalRequests) * 100
+		writer.Write([]string{"Response Sizes", bucket.Label, strconv.Itoa(bucket.Count), fmt.Sprintf("%.1f", percentage)})
+	}
+	
+	// Write endpoint statistics
+	for _, ep := range results.EndpointStats {
+		endpoint := ep.Method + " " + ep.Endpoint
+		writer.Write([]string{"Endpoints", endpoint, strconv.Itoa(ep.Count), fmt.Sprintf("%.1f", ep.ErrorRate)})
+		writer.Write([]string{"Endpoint Sizes", endpoint + " P50/P95/P99", fmt.Sprintf("%d/%d/%d", ep.P50Size, ep.P95Size, ep.P99Size), ""})
+	}
+	
+	return nil
+}
+
+// exportToXLSX exports results to an Excel workbook, one worksheet per section
+func exportToXLSX(results *analyser.Results, groups []analyser.GroupResult, filename string) error {
+	return xlsx.ExportResults(results.LimitTop(exportTopN(analyser.DefaultExportTopN)), groups, filename)
+}
+
+// loadReportTemplate finds a report template in the configuration or the
+// built-in templates
+func loadReportTemplate(name string) (*config.ReportTemplate, error) {
+	configManager := config.NewConfigManager(analyseConfigDir)
+	if err := configManager.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return configManager.ResolveTemplate(name)
+}
+
+// generateTemplateReport runs the sections of a report template over the
+// entries and writes the report in each of its formats
+func generateTemplateReport(template *config.ReportTemplate, logs []*parser.LogEntry) error {
+	engine, err := newQueryEngine(logs)
+	if err != nil {
+		return fmt.Errorf("failed to load lookup: %w", err)
+	}
+	fmt.Printf("📑 Generating '%s' report from %d sections...\n", template.Name, len(template.EnabledSections()))
+	report := reports.Build(*template, engine, len(logs))
+	for _, section := range report.Sections {
+		if section.Error != "" {
+			fmt.Printf("    ⚠️  Section '%s' failed: %s\n", section.Name, section.Error)
+		}
+	}
+	
+	formats := templateFormats
+	if len(formats) == 0 {
+		formats = template.OutputFormats()
+	}
+	base := templateOutput
+	if base == "" {
+		base = filepath.Join("output", template.Name)
+	}
+	
+	for _, format := range formats {
+		filename := base + "." + format
+		var err error
+		switch format {
+		case "html":
+			var generator *html.Generator
+			if generator, err = html.NewGenerator(); err == nil {
+				generator.SetOffline(htmlOffline || htmlAssetsDir != "", htmlAssetsDir)
+				err = generator.GenerateTemplateReport(report, filename)
+			}
+		case "json":
+			err = reports.WriteJSON(report, filename)
+		case "csv":
+			err = reports.WriteCSV(report, filename)
+		default:
+			err = fmt.Errorf("unknown format %q", format)
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to export %s report: %v\n", strings.ToUpper(format), err)
+		} else {
+			fmt.Printf("📑 Exported %s report to: %s\n", strings.ToUpper(format), filename)
+		}
+	}
 	return nil
 }
 
@@ -800,17 +2244,170 @@ func getThreatEmoji(threatLevel string) string {
 	}
 }
 
-// exportToHTML generates an interactive HTML report
-func exportToHTML(results *analyser.Results, filename string, title string, interactive bool) error {
-	generator, err := html.NewGenerator()
-	if err != nil {
-		return fmt.Errorf("failed to create HTML generator: %w", err)
-	}
-	
-	if interactive {
-		return generator.GenerateInteractiveReport(results, filename, title)
-	}
-	return generator.GenerateReport(results, filename, title)
+// securityAnalysisRun is a security analysis and the entries it was run on
+type securityAnalysisRun struct {
+	logs     []*parser.LogEntry
+	analysis *security.EnhancedSecurityAnalysis
+}
+
+// analyseSecurity runs the full security analysis over logs, reusing the last
+// one when it was run on the same entries so the dashboard and the exports of
+// a run share it
+func analyseSecurity(logs []*parser.LogEntry, purpose string) (*security.EnhancedSecurityAnalysis, error) {
+	if last := lastSecurityAnalysis; last != nil && sameEntries(last.logs, logs) {
+		return last.analysis, nil
+	}
+	fmt.Printf("🔍 Performing security analysis for %s...\n", purpose)
+	analysis, err := security.Analyse(logs, securityConfig())
+	if err != nil {
+		return nil, err
+	}
+	lastSecurityAnalysis = &securityAnalysisRun{logs: logs, analysis: analysis}
+	return analysis, nil
+}
+
+// sameEntries reports whether a and b hold the same entries in the same order
+func sameEntries(a, b []*parser.LogEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// printSecurityAnalysis shows the security dashboard of logs and, with
+// --security-details, the detailed threat, anomaly and recommendation reports
+func printSecurityAnalysis(logs []*parser.LogEntry) {
+	if len(logs) == 0 {
+		fmt.Printf("❌ Failed to analyse security: no log entries in the analysed period\n")
+		return
+	}
+	analysis, err := analyseSecurity(logs, "the security dashboard")
+	if err != nil {
+		fmt.Printf("❌ Failed to analyse security: %v\n", err)
+		return
+	}
+
+	visualizer := security.NewSecurityVisualizer(securityConfig())
+	reports := []string{visualizer.GenerateSecurityDashboard(analysis)}
+	if securityDetails {
+		reports = append(reports,
+			visualizer.GenerateDetailedThreatReport(analysis.Threats),
+			visualizer.GenerateOWASPReport(analysis.Threats),
+			visualizer.GenerateAnomalyReport(analysis.Anomalies),
+			visualizer.GenerateSecurityRecommendationReport(analysis.Recommendations))
+	}
+	for _, report := range reports {
+		if noColors || !charts.SupportsColor() {
+			report = charts.StripColors(report)
+		}
+		fmt.Printf("\n")
+		fmt.Print(report)
+	}
+}
+
+// failOnSecurityFindings exits with the security scan exit code of the threat
+// level when threats at or above --fail-on-severity were found in logs
+func failOnSecurityFindings(logs []*parser.LogEntry) {
+	if len(logs) == 0 {
+		return
+	}
+	analysis, err := analyseSecurity(logs, "the --fail-on-severity check")
+	if err != nil {
+		fmt.Printf("❌ Failed to analyse security: %v\n", err)
+		os.Exit(1)
+	}
+	failing := security.FilterBySeverity(analysis, failSeverity)
+	level, found := security.ThreatLevel(failing.Threats)
+	if !found {
+		fmt.Printf("✅ No threats at or above %s severity\n", failSeverity)
+		return
+	}
+	fmt.Printf("🚨 Failing: %d threat(s) at or above %s severity (threat level %s)\n", len(failing.Threats), failSeverity, level)
+	os.Exit(securityExitCode(level))
+}
+
+// exportSecurityIntel runs the full security analysis over logs once and
+// writes the SIEM events, STIX bundle and incident timelines that were asked
+// for
+func exportSecurityIntel(logs []*parser.LogEntry) {
+	if len(logs) == 0 {
+		fmt.Printf("❌ Failed to export security intelligence: no log entries in the analysed period\n")
+		return
+	}
+	analysis, err := analyseSecurity(logs, "the security exports")
+	if err != nil {
+		fmt.Printf("❌ Failed to analyse security: %v\n", err)
+		return
+	}
+
+	if exportSIEM != "" {
+		events := siem.Events(analysis)
+		threats := fmt.Sprintf("%d threats", len(analysis.Threats))
+		if siemAggregate {
+			events = siem.AggregatedEvents(analysis)
+			threats += fmt.Sprintf(" in %d groups", len(events)-len(analysis.Anomalies)-len(analysis.Incidents))
+		}
+		if err := siem.WriteFile(exportSIEM, siemFormat, events, siem.Options{Hostname: siemHostname}); err != nil {
+			fmt.Printf("❌ Failed to export SIEM events: %v\n", err)
+		} else {
+			fmt.Printf("📡 Exported %d %s event(s) (%s, %d anomalies, %d incidents) to: %s\n",
+				len(events), strings.ToUpper(siemFormat), threats, len(analysis.Anomalies), len(analysis.Incidents), exportSIEM)
+		}
+	}
+
+	if exportSTIX != "" {
+		bundle := security.BuildSTIXBundle(analysis.Incidents, time.Now())
+		if err := security.WriteSTIXBundle(exportSTIX, bundle); err != nil {
+			fmt.Printf("❌ Failed to export STIX bundle: %v\n", err)
+		} else {
+			fmt.Printf("🧾 Exported STIX 2.1 bundle of %d object(s) from %d incident(s) to: %s\n", len(bundle.Objects), len(analysis.Incidents), exportSTIX)
+		}
+	}
+
+	if exportTimeline != "" {
+		if err := security.WriteTimelineFile(exportTimeline, analysis.Incidents, time.Now()); err != nil {
+			fmt.Printf("❌ Failed to export incident timelines: %v\n", err)
+		} else {
+			fmt.Printf("🕒 Exported the timelines of %d incident(s) to: %s\n", len(analysis.Incidents), exportTimeline)
+		}
+	}
+}
+
+// exportToHTML generates an interactive HTML report, with the full security
+// analysis of logs in its security tab and detail views of the listed IPs and
+// URLs profiled from logs unless they are not in memory, and a trends tab
+// when a trend analysis is given
+func exportToHTML(a *analyser.Analyser, results *analyser.Results, logs []*parser.LogEntry, trendResults *trends.TrendAnalysis, filename string, title string, interactive bool) error {
+	generator, err := html.NewGenerator()
+	if err != nil {
+		return fmt.Errorf("failed to create HTML generator: %w", err)
+	}
+	if topN != "" {
+		generator.SetTopN(exportTopN(analyser.TopAll))
+	}
+	generator.SetEmbedData(htmlEmbedData)
+	generator.SetOffline(htmlOffline || htmlAssetsDir != "", htmlAssetsDir)
+	if interactive && htmlSecurity && len(logs) > 0 {
+		analysis, err := analyseSecurity(logs, "the HTML report")
+		if err != nil {
+			return fmt.Errorf("failed to analyse security: %w", err)
+		}
+		generator.SetSecurityAnalysis(analysis)
+	}
+	generator.SetTrendAnalysis(trendResults)
+	if interactive {
+		generator.SetDrillDownLogs(a, logs)
+	}
+	
+	if interactive {
+		return generator.GenerateInteractiveReport(results, filename, title)
+	}
+	return generator.GenerateReport(results, filename, title)
 }
 
 // printTrendAnalysis displays trend analysis results
@@ -824,6 +2421,13 @@ func printTrendAnalysis(trendAnalysis *trends.TrendAnalysis) {
 	fmt.Printf("🏥 Overall Health: %s %s\n", healthEmoji, strings.ToUpper(trendAnalysis.OverallHealth))
 	fmt.Printf("📊 Analysis Type: %s\n", trendAnalysis.AnalysisType)
 	fmt.Printf("🕒 Generated: %s\n", trendAnalysis.GeneratedAt.Format("2006-01-02 15:04:05"))
+	if trendAnalysis.AnalysisType == "historical" && len(trendAnalysis.PeriodComparisons) > 0 {
+		baseline := trendAnalysis.PeriodComparisons[0].BaselinePeriod
+		fmt.Printf("📚 Baseline: %s (%s to %s)\n", baseline.Period,
+			baseline.StartTime.Format("2006-01-02"), baseline.EndTime.Format("2006-01-02"))
+	} else if trendAnalysis.AnalysisType == "comparison" && len(trendAnalysis.PeriodComparisons) > 0 {
+		fmt.Printf("📚 Baseline: %s\n", trendAnalysis.PeriodComparisons[0].BaselinePeriod.Period)
+	}
 	
 	// Trend summary
 	fmt.Printf("\n📈 Trend Summary:\n")
@@ -844,6 +2448,44 @@ func printTrendAnalysis(trendAnalysis *trends.TrendAnalysis) {
 			printDegradationAlert(&alert)
 		}
 	}
+	
+	// Endpoint trends
+	if len(trendAnalysis.EndpointTrends) > 0 {
+		fmt.Printf("\n🎯 Endpoint Trends (%d):\n", len(trendAnalysis.EndpointTrends))
+		for i, trend := range trendAnalysis.EndpointTrends {
+			if i >= 10 {
+				fmt.Printf("   ... and %d more\n", len(trendAnalysis.EndpointTrends)-10)
+				break
+			}
+			fmt.Printf("   %s %s\n", getChangeEmoji(trend.Direction), trend.Description)
+		}
+	}
+
+	// Security trends
+	if len(trendAnalysis.PeriodComparisons) > 0 && len(trendAnalysis.PeriodComparisons[0].SecurityChanges) > 0 {
+		comparison := trendAnalysis.PeriodComparisons[0]
+		fmt.Printf("\n🛡️  Security Trends (posture %s):\n", comparison.SecurityTrend.String())
+		for _, change := range comparison.SecurityChanges {
+			fmt.Printf("   %s %-26s %s → %s\n", getChangeEmoji(change.Direction), change.MetricName+":",
+				trends.FormatSecurityValue(change.MetricName, change.OldValue),
+				trends.FormatSecurityValue(change.MetricName, change.NewValue))
+		}
+	}
+
+	// Capacity projection
+	if len(trendAnalysis.CapacityProjections) > 0 {
+		fmt.Printf("\n📦 Capacity Projection:\n")
+		for _, projection := range trendAnalysis.CapacityProjections {
+			days := "-"
+			if projection.DaysUntilLimit >= 0 {
+				days = fmt.Sprintf("%.0f days", math.Ceil(projection.DaysUntilLimit))
+			}
+			fmt.Printf("   %s %-15s %s of %s (%.0f%%), %s until capacity\n", getCapacityEmoji(projection.Status),
+				projection.Resource+":", trends.FormatCapacityValue(projection.Resource, projection.Current),
+				trends.FormatCapacityValue(projection.Resource, projection.Limit), projection.Utilization, days)
+			fmt.Printf("      %s\n", projection.Description)
+		}
+	}
 
 	// Recommendations
 	if len(trendAnalysis.Recommendations) > 0 {
@@ -854,6 +2496,158 @@ func printTrendAnalysis(trendAnalysis *trends.TrendAnalysis) {
 	}
 }
 
+// trendHistoryPath returns the --trend-history file, or the default one in
+// the config directory
+func trendHistoryPath() string {
+	if trendHistoryFile != "" {
+		return trendHistoryFile
+	}
+	return filepath.Join(analyseConfigDir, trends.DefaultHistoryFile)
+}
+
+// newTrendAnalyser creates a trend analyser with the alert thresholds of the
+// configuration and --trend-profile
+func newTrendAnalyser() (*trends.TrendAnalyser, error) {
+	configManager := config.NewConfigManager(analyseConfigDir)
+	if err := configManager.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	thresholds, err := configManager.GetConfig().Analysis.TrendAlerts.Thresholds(trendProfile)
+	if err != nil {
+		return nil, err
+	}
+	if trendProfile != "" {
+		fmt.Printf("🎚️  Using trend profile: %s\n", trendProfile)
+	}
+	return trends.NewWithConfig(trends.ConfigurationFrom(thresholds)), nil
+}
+
+// analyseTrends compares this run with the earlier runs in the trend
+// history, or with the first half of its own logs when there are none
+func analyseTrends(results *analyser.Results, logs []*parser.LogEntry) (*trends.TrendAnalysis, error) {
+	if trendWindow < 0 {
+		return nil, fmt.Errorf("--trend-window must not be negative")
+	}
+	
+	ta, err := newTrendAnalyser()
+	if err != nil {
+		return nil, err
+	}
+	history, err := trends.LoadHistory(trendHistoryPath())
+	if err != nil {
+		return nil, err
+	}
+	
+	window := time.Duration(trendWindow) * 24 * time.Hour
+	if earlier := history.Before(results.TimeRange.Start, window); len(earlier) > 0 {
+		fmt.Printf("📚 Comparing with %d earlier run(s) from the trend history\n", len(earlier))
+		return ta.CompareWithHistory(results, earlier)
+	}
+	return ta.DetectDegradation(logs)
+}
+
+// compareWithPeriod compares this run with the --compare-period period
+// before it, taken from the --compare-logs files or the trend history
+func compareWithPeriod(a *analyser.Analyser, results *analyser.Results) (*trends.TrendAnalysis, error) {
+	if results.TotalRequests == 0 {
+		return nil, fmt.Errorf("no log entries to compare")
+	}
+	period, err := trends.ResolvePeriod(comparePeriod, results.TimeRange.Start)
+	if err != nil {
+		return nil, err
+	}
+	
+	ta, err := newTrendAnalyser()
+	if err != nil {
+		return nil, err
+	}
+	if len(compareLogs) > 0 {
+		until := period.End.Add(-time.Nanosecond)
+		baseline := streamAnalyse(a, compareLogs, &period.Start, &until, nil)
+		return ta.CompareWithResults(results, baseline, period)
+	}
+	
+	history, err := trends.LoadHistory(trendHistoryPath())
+	if err != nil {
+		return nil, err
+	}
+	return ta.CompareWithSnapshots(results, history.Between(period), period)
+}
+
+// capacityLimits returns the capacity limits from the config, overridden by
+// --max-rps and --max-bandwidth
+func capacityLimits() (trends.CapacityLimits, error) {
+	configManager := config.NewConfigManager(analyseConfigDir)
+	if err := configManager.Load(); err != nil {
+		return trends.CapacityLimits{}, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	capacity := configManager.GetConfig().Analysis.Capacity
+	if maxRPS > 0 {
+		capacity.MaxRPS = maxRPS
+	}
+	if maxBandwidth != "" {
+		capacity.MaxBandwidth = maxBandwidth
+	}
+	
+	limits := trends.CapacityLimits{MaxRPS: capacity.MaxRPS}
+	if capacity.MaxBandwidth != "" {
+		bandwidth, err := trends.ParseBandwidth(capacity.MaxBandwidth)
+		if err != nil {
+			return limits, fmt.Errorf("analysis.capacity.max_bandwidth: %w", err)
+		}
+		limits.MaxBandwidth = bandwidth
+	}
+	return limits, nil
+}
+
+// addCapacityProjections projects the peak load of the trend history and
+// this run to the capacity limits, when any are configured
+func addCapacityProjections(trendAnalysis *trends.TrendAnalysis, results *analyser.Results) {
+	limits, err := capacityLimits()
+	if err == nil && limits.MaxRPS == 0 && limits.MaxBandwidth == 0 {
+		return
+	}
+	var history *trends.History
+	if err == nil {
+		history, err = trends.LoadHistory(trendHistoryPath())
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to project capacity: %v\n", err)
+		return
+	}
+	trendAnalysis.AddCapacityProjections(trends.ProjectCapacity(history.Snapshots, results, limits))
+}
+
+// recordTrendSnapshot adds this run's metrics to the trend history and
+// drops runs older than --trend-retention
+func recordTrendSnapshot(results *analyser.Results, sources []string) error {
+	if trendRetention < 0 {
+		return fmt.Errorf("--trend-retention must not be negative")
+	}
+	if results.TotalRequests == 0 {
+		return fmt.Errorf("no log entries to record")
+	}
+	
+	filename := trendHistoryPath()
+	history, err := trends.LoadHistory(filename)
+	if err != nil {
+		return err
+	}
+	
+	history.Record(trends.NewSnapshot(results, sources))
+	dropped := history.Prune(time.Duration(trendRetention) * 24 * time.Hour)
+	if err := history.Save(); err != nil {
+		return fmt.Errorf("failed to save trend history: %w", err)
+	}
+	
+	fmt.Printf("📚 Recorded this run in the trend history (%d runs", len(history.Snapshots))
+	if dropped > 0 {
+		fmt.Printf(", %d expired", dropped)
+	}
+	fmt.Printf("): %s\n", filename)
+	return nil
+}
+
 // printPeriodComparison displays period comparison details
 func printPeriodComparison(comparison *trends.PeriodComparison) {
 	trendEmoji := getTrendEmoji(comparison.OverallTrend)
@@ -909,6 +2703,20 @@ func getTrendEmoji(trend trends.TrendDirection) string {
 	}
 }
 
+// getCapacityEmoji returns an emoji for a capacity projection status
+func getCapacityEmoji(status string) string {
+	switch status {
+	case "exceeded", "critical":
+		return "🚨"
+	case "warning":
+		return "⚠️"
+	case "ok", "not growing":
+		return "✅"
+	default:
+		return "❓"
+	}
+}
+
 func getChangeEmoji(direction trends.TrendDirection) string {
 	switch direction {
 	case trends.TrendImproving:
@@ -937,6 +2745,626 @@ func getSeverityEmoji(severity string) string {
 	}
 }
 
+// resolvedChartWidth returns --chart-width, or the terminal width when it is
+// not set
+func resolvedChartWidth() int {
+	if chartWidth > 0 {
+		return chartWidth
+	}
+	return charts.GetTerminalWidth()
+}
+
+// newChartGenerator creates a chart generator set up from the chart flags
+func newChartGenerator() *charts.ChartGenerator {
+	generator := charts.NewChartGenerator()
+	generator.SetWidth(resolvedChartWidth())
+	generator.SetColors(!noColors && charts.SupportsColor())
+	generator.SetTheme(chartTheme)
+	generator.SetHistogramScale(histogramScale)
+	generator.SetBraille(brailleCharts)
+	generator.SetCollectData(chartDataDir != "")
+	return generator
+}
+
+// writeChartData writes the data of the charts a generator drew to the
+// --chart-data directory
+func writeChartData(generator *charts.ChartGenerator) {
+	if chartDataDir == "" || len(generator.Data()) == 0 {
+		return
+	}
+	paths, err := charts.WriteChartData(chartDataDir, generator.Data())
+	if err != nil {
+		fmt.Printf("❌ Failed to export chart data: %v\n", err)
+		return
+	}
+	fmt.Printf("📁 Exported chart data (%d files) to: %s\n", len(paths), chartDataDir)
+}
+
+// resolveChartTheme returns the chart theme named by --chart-theme, or by the
+// config when the flag is not given, with the config's and then the
+// --chart-color overrides applied
+func resolveChartTheme() (charts.Theme, error) {
+	name := charts.DefaultThemeName
+	colors := make(map[string]string)
+
+	// Only read an existing config, Load would create one
+	configManager := config.NewConfigManager(analyseConfigDir)
+	if _, err := os.Stat(configManager.ConfigFile()); err == nil {
+		if err := configManager.Load(); err != nil {
+			return charts.Theme{}, err
+		}
+		analysisConfig := configManager.GetConfig().Analysis
+		if analysisConfig.ChartTheme != "" {
+			name = analysisConfig.ChartTheme
+		}
+		for element, color := range analysisConfig.ChartColors {
+			colors[element] = color
+		}
+	}
+
+	if chartThemeName != "" {
+		name = chartThemeName
+	}
+	for _, spec := range chartColorSpecs {
+		element, color, found := strings.Cut(spec, "=")
+		if !found {
+			return charts.Theme{}, fmt.Errorf("--chart-color %q must be element=color", spec)
+		}
+		colors[element] = color
+	}
+
+	theme, err := charts.LookupTheme(name)
+	if err != nil {
+		return charts.Theme{}, err
+	}
+	return theme.WithColors(colors)
+}
+
+// newConfiguredAnalyser creates an analyser with the bot, site host and
+// rate-limit settings taken from the command line
+func newConfiguredAnalyser() *analyser.Analyser {
+	a := analyser.New()
+	if err := applyBotSignatures(a); err != nil {
+		log.Fatalf("Failed to load bot signatures: %v", err)
+	}
+	a.SetSiteHosts(siteHosts)
+	a.SetRateLimitThresholds(analyser.RateLimitThresholds{
+		RequestsPerMinute: rateLimitRPM,
+		SustainedMinutes:  rateLimitSustained,
+	})
+	a.SetCrawlerVerification(verifyCrawlers)
+	a.SetServiceTime(serviceTime)
+	if err := applyVolumeThresholds(a); err != nil {
+		log.Fatalf("Invalid data volume thresholds: %v", err)
+	}
+	if err := applyGeoIP(a); err != nil {
+		log.Fatalf("Failed to apply country filter: %v", err)
+	}
+	if err := applyAnomalyBaselines(a); err != nil {
+		log.Fatalf("Failed to apply anomaly baselines: %v", err)
+	}
+	if err := applyEgressPricing(a); err != nil {
+		log.Fatalf("Failed to load egress pricing: %v", err)
+	}
+	if sizeBuckets != "" {
+		bounds, err := analyser.ParseSizeBuckets(sizeBuckets)
+		if err != nil {
+			log.Fatalf("Invalid --size-buckets: %v", err)
+		}
+		a.SetSizeBuckets(bounds)
+	}
+	return a
+}
+
+// streamAnalyse parses each file in its own goroutine, feeding entries
+// straight into a per-file stream, and merges the per-file results. Entries
+// inside the window are also written to export when it is set.
+func streamAnalyse(a *analyser.Analyser, files []string, sinceTime, untilTime *time.Time, export *entryExport) *analyser.Results {
+	type fileResult struct {
+		results *analyser.Results
+		entries int
+		err     error
+	}
+	
+	fmt.Printf("📂 Streaming %d log file(s)...\n\n", len(files))
+	
+	fileResults := make([]fileResult, len(files))
+	var wg sync.WaitGroup
+	for i, logFile := range files {
+		wg.Add(1)
+		go func(i int, logFile string) {
+			defer wg.Done()
+			
+			stream := a.NewStream(sinceTime, untilTime)
+			entries := 0
+			err := parser.New().StreamFile(logFile, func(entry *parser.LogEntry) {
+				entries++
+				if stream.Add(entry) && export != nil {
+					export.write(entry)
+				}
+			})
+			fileResults[i] = fileResult{results: stream.Results(), entries: entries, err: err}
+		}(i, logFile)
+	}
+	wg.Wait()
+	
+	results := analyser.MergeResults()
+	totalEntries := 0
+	for i, logFile := range files {
+		fmt.Printf("  [%d/%d] Processed: %s\n", i+1, len(files), logFile)
+		if fileResults[i].err != nil {
+			fmt.Printf("    ❌ Failed to parse %s: %v\n", logFile, fileResults[i].err)
+			continue
+		}
+		
+		fmt.Printf("    ✅ Streamed %d entries\n", fileResults[i].entries)
+		totalEntries += fileResults[i].entries
+		results.Merge(fileResults[i].results)
+	}
+	
+	if totalEntries == 0 {
+		log.Fatal("No valid log entries found in any files")
+	}
+	
+	fmt.Printf("\n📊 Combined Analysis Results (%d total entries):\n", totalEntries)
+	return results
+}
+
+// entryExport writes the entries of --export-entries as the log files are
+// streamed in parallel
+type entryExport struct {
+	mu       sync.Mutex
+	filename string
+	writer   entries.Writer
+	count    int
+	err      error
+}
+
+// newEntryExport creates the export file in the format of its extension
+func newEntryExport(filename string) (*entryExport, error) {
+	format, err := entries.FormatFromFilename(filename)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := entries.Create(filename, format)
+	if err != nil {
+		return nil, err
+	}
+	return &entryExport{filename: filename, writer: writer}, nil
+}
+
+// write writes an entry, keeping the first error for close to report
+func (e *entryExport) write(entry *parser.LogEntry) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return
+	}
+	if e.err = e.writer.Write(entry); e.err == nil {
+		e.count++
+	}
+}
+
+// close finishes the export file and reports the outcome
+func (e *entryExport) close() {
+	if err := e.writer.Close(); e.err == nil {
+		e.err = err
+	}
+	if e.err != nil {
+		fmt.Printf("❌ Failed to export entries: %v\n", e.err)
+		return
+	}
+	fmt.Printf("📦 Exported %d parsed entries to: %s\n", e.count, e.filename)
+}
+
+// newQueryEngine creates a query engine with the --lookup tables loaded
+func newQueryEngine(logs []*parser.LogEntry) (*query.QueryEngine, error) {
+	engine := query.NewQueryEngine(logs)
+	engine.SetMaxGroups(queryMaxGroups)
+	registerQueryTables(engine, logs, errorLogFiles)
+	for _, spec := range lookupSpecs {
+		name, filename, err := query.ParseLookupSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		table, err := query.LoadLookupTable(name, filename)
+		if err != nil {
+			return nil, err
+		}
+		engine.AddLookupTable(table)
+	}
+	return engine, nil
+}
+
+// streamQuery runs --query over the log files one entry at a time, so memory
+// stays bounded by the query's rows and groups rather than the input size
+func streamQuery(files []string, sinceTime, untilTime *time.Time) {
+	engine, err := newQueryEngine(nil)
+	if err != nil {
+		fmt.Printf("❌ Error loading lookup: %v\n", err)
+		return
+	}
+	
+	// Other tables are not read from the log stream, so run those queries directly
+	if stmt, err := query.ParseQuery(queryString); err == nil && !strings.EqualFold(stmt.From, query.LogsTable) {
+		fmt.Printf("🔍 Executing query: %s\n", queryString)
+		result, err := engine.ExecuteQuery(queryString)
+		if err != nil {
+			fmt.Printf("❌ Query error: %v\n", err)
+			return
+		}
+		if err := outputQueryResult(result, queryString, queryFormat, queryOutput, queryChart); err != nil {
+			fmt.Printf("❌ %v\n", err)
+		}
+		return
+	}
+	
+	stream, err := engine.NewStream(queryString)
+	if err != nil {
+		fmt.Printf("❌ Query error: %v\n", err)
+		helper := query.NewQueryHelper()
+		fmt.Printf("💡 %s\n", helper.SuggestCorrection(err))
+		return
+	}
+	defer stream.Close()
+	
+	ca := analyser.New()
+	if len(countryFilter) > 0 {
+		if err := applyGeoIP(ca); err != nil {
+			log.Fatalf("Failed to apply country filter: %v", err)
+		}
+	}
+	
+	fmt.Printf("🔍 Streaming query over %d log file(s): %s\n", len(files), queryString)
+	totalEntries := 0
+	for i, logFile := range files {
+		entries := 0
+		err := parser.New().StreamFile(logFile, func(entry *parser.LogEntry) {
+			entries++
+			if sinceTime != nil && entry.Timestamp.Before(*sinceTime) {
+				return
+			}
+			if untilTime != nil && entry.Timestamp.After(*untilTime) {
+				return
+			}
+			if !ca.MatchesCountryFilter(entry.IP) {
+				return
+			}
+			stream.Add(entry)
+		})
+		
+		fmt.Printf("  [%d/%d] Processed: %s\n", i+1, len(files), logFile)
+		if err != nil {
+			fmt.Printf("    ❌ Failed to parse %s: %v\n", logFile, err)
+			continue
+		}
+		fmt.Printf("    ✅ Streamed %d entries\n", entries)
+		totalEntries += entries
+		
+		if stream.Done() {
+			break
+		}
+	}
+	
+	if totalEntries == 0 {
+		log.Fatal("No valid log entries found in any files")
+	}
+	
+	result, err := stream.Result()
+	if err != nil {
+		fmt.Printf("❌ Query error: %v\n", err)
+		return
+	}
+	if err := outputQueryResult(result, queryString, queryFormat, queryOutput, queryChart); err != nil {
+		fmt.Printf("❌ %v\n", err)
+	}
+}
+
+// applyAnomalyBaselines sets anomaly detection baselines from the
+// configuration file, or learns them from --baseline-log
+func applyAnomalyBaselines(a *analyser.Analyser) error {
+	configManager := config.NewConfigManager(analyseConfigDir)
+	if _, err := os.Stat(configManager.ConfigFile()); err == nil {
+		if err := configManager.Load(); err != nil {
+			return err
+		}
+		configured := configManager.GetConfig().Analysis.AnomalyBaselines
+		a.SetAnomalyBaselines(analyser.AnomalyBaselines{
+			ErrorRate:             configured.ErrorRate,
+			ErrorRateThreshold:    configured.ErrorRateThreshold,
+			NotFoundRate:          configured.NotFoundRate,
+			NotFoundRateThreshold: configured.NotFoundRateThreshold,
+		})
+	}
+	
+	if baselineLog == "" {
+		if saveBaselines {
+			return fmt.Errorf("--save-baselines requires --baseline-log")
+		}
+		return nil
+	}
+	
+	referenceLogs, err := parser.New().ParseFile(baselineLog)
+	if err != nil {
+		return fmt.Errorf("failed to parse baseline log: %w", err)
+	}
+	if len(referenceLogs) == 0 {
+		return fmt.Errorf("baseline log %s contains no entries", baselineLog)
+	}
+	
+	learned := analyser.LearnAnomalyBaselines(referenceLogs)
+	current := a.AnomalyBaselines()
+	learned.ErrorRateThreshold = current.ErrorRateThreshold
+	learned.NotFoundRateThreshold = current.NotFoundRateThreshold
+	a.SetAnomalyBaselines(learned)
+	
+	fmt.Printf("📐 Learned anomaly baselines from %s (%s entries): error rate %.2f%%, 404 rate %.2f%%\n",
+		baselineLog, formatNumber(len(referenceLogs)), learned.ErrorRate, learned.NotFoundRate)
+	
+	if saveBaselines {
+		analysisConfig := configManager.GetConfig().Analysis
+		analysisConfig.AnomalyBaselines = config.AnomalyBaselineConfig{
+			ErrorRate:             learned.ErrorRate,
+			ErrorRateThreshold:    learned.ErrorRateThreshold,
+			NotFoundRate:          learned.NotFoundRate,
+			NotFoundRateThreshold: learned.NotFoundRateThreshold,
+			LearnedFrom:           baselineLog,
+		}
+		if err := configManager.UpdateAnalysisConfig(analysisConfig); err != nil {
+			return fmt.Errorf("failed to save baselines: %w", err)
+		}
+		fmt.Printf("💾 Saved anomaly baselines to %s\n", configManager.ConfigFile())
+	}
+	
+	return nil
+}
+
+// applyEgressPricing enables egress cost estimation when requested, using the
+// pricing file or a flat --cost-per-gb rate
+func applyEgressPricing(a *analyser.Analyser) error {
+	if !showEgressCost && costPerGB == 0 {
+		return nil
+	}
+	
+	filename := egressConfigFile
+	if filename == "" {
+		filename = filepath.Join(analyseConfigDir, analyser.DefaultEgressConfigFile)
+	}
+	
+	pricing, err := analyser.LoadEgressPricing(filename)
+	if err != nil {
+		return err
+	}
+	
+	if costPerGB < 0 {
+		return fmt.Errorf("--cost-per-gb must not be negative")
+	}
+	if costPerGB > 0 {
+		pricing.DefaultPerGB = costPerGB
+		pricing.Regions = nil
+	}
+	
+	a.SetEgressPricing(pricing)
+	return nil
+}
+
+// applyVolumeThresholds configures data exfiltration detection from the command line
+func applyVolumeThresholds(a *analyser.Analyser) error {
+	thresholds := analyser.VolumeThresholds{
+		UploadRequests: exfilUploads,
+		ZScore:         exfilZScore,
+	}
+	
+	if exfilBytes != "" && exfilBytes != "0" {
+		bytes, err := analyser.ParseByteSize(exfilBytes)
+		if err != nil {
+			return fmt.Errorf("--exfil-bytes: %w", err)
+		}
+		thresholds.DownloadBytes = bytes
+	}
+	if exfilUploads < 0 || exfilZScore < 0 {
+		return fmt.Errorf("--exfil-uploads and --exfil-zscore must not be negative")
+	}
+	
+	a.SetVolumeThresholds(thresholds)
+	return nil
+}
+
+// loadGeoIPDatabase loads the --geoip-db database once
+func loadGeoIPDatabase() error {
+	if geoIPDatabase == "" || geoIPDB != nil {
+		return nil
+	}
+	db, err := analyser.LoadGeoIPCSV(geoIPDatabase)
+	if err != nil {
+		return err
+	}
+	geoIPDB = db
+	return nil
+}
+
+// applyGeoIP loads the GeoIP database and country filter into the analyser.
+// The database is loaded once and shared with the security analysis.
+func applyGeoIP(a *analyser.Analyser) error {
+	if err := loadGeoIPDatabase(); err != nil {
+		return err
+	}
+	if geoIPDB != nil {
+		a.SetGeoIPDatabase(geoIPDB)
+	}
+	
+	a.SetCountryFilter(countryFilter)
+	return nil
+}
+
+// applyBotSignatures loads the user-editable bot signature list into the analyser
+func applyBotSignatures(a *analyser.Analyser) error {
+	filename := botConfigFile
+	if filename == "" {
+		filename = filepath.Join(analyseConfigDir, analyser.DefaultBotConfigFile)
+	}
+
+	signatures, err := analyser.LoadBotSignatures(filename)
+	if err != nil {
+		return err
+	}
+
+	a.SetBotSignatures(signatures)
+	return nil
+}
+
+// loadSecurityRules loads the custom threat detection rules; the default
+// rules file is optional
+func loadSecurityRules() error {
+	filename := securityRulesFile
+	if filename == "" {
+		filename = filepath.Join(analyseConfigDir, security.CustomRulesFilename)
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	rules, err := security.LoadCustomRules(filename)
+	if err != nil {
+		return err
+	}
+
+	customSecurityRules = rules
+	return nil
+}
+
+// loadCVESignatures loads the known exploit signatures; the built-in set is
+// used when the signature file is missing
+func loadCVESignatures() error {
+	filename := cveSignaturesFile
+	if filename == "" {
+		filename = filepath.Join(analyseConfigDir, security.CVESignaturesFilename)
+	}
+
+	signatures, err := security.LoadCVESignatures(filename)
+	if err != nil {
+		return err
+	}
+
+	cveSignatures = signatures
+	return nil
+}
+
+// loadSecurityScoring loads the security scoring weights and risk levels of
+// the configuration file; the built-in scoring is used when there is none
+func loadSecurityScoring() error {
+	configManager := config.NewConfigManager(analyseConfigDir)
+	if _, err := os.Stat(configManager.ConfigFile()); err != nil {
+		securityScoring = security.DefaultScoringConfig()
+		return nil
+	}
+	if err := configManager.Load(); err != nil {
+		return err
+	}
+	
+	securityScoring = security.ScoringConfigFrom(configManager.GetConfig().Analysis.SecurityScoring)
+	return nil
+}
+
+// securityConfig returns the security configuration with the custom rules,
+// the CVE signatures, the scoring, the security history, the IP reputation
+// and the GeoIP database
+func securityConfig() security.SecurityConfig {
+	securityCfg := security.DefaultSecurityConfig()
+	securityCfg.CustomRules = customSecurityRules
+	securityCfg.CVESignatures = cveSignatures
+	if securityScoring.SeverityWeights != nil {
+		securityCfg.Scoring = securityScoring
+	}
+	securityCfg.Behavior = securityBehavior
+	securityCfg.Reputation = ipReputation
+	if geoIPDB != nil {
+		securityCfg.GeoIP = geoIPDB
+	}
+	return securityCfg
+}
+
+// securityHistoryPath returns the --security-history file, or the default one
+// in the config directory
+func securityHistoryPath() string {
+	if securityHistoryFile != "" {
+		return securityHistoryFile
+	}
+	return filepath.Join(analyseConfigDir, security.DefaultBehaviorStoreFile)
+}
+
+// recordSecurityBehavior adds the failed logins of logs to the security
+// history, keeping the low-and-slow window of it
+func recordSecurityBehavior(logs []*parser.LogEntry) error {
+	if len(logs) == 0 {
+		return fmt.Errorf("no log entries to record")
+	}
+	
+	store, err := security.LoadBehaviorStore(securityHistoryPath())
+	if err != nil {
+		return err
+	}
+	
+	store.Record(logs)
+	dropped := store.Prune(security.LowAndSlowWindow)
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("failed to save security history: %w", err)
+	}
+	
+	fmt.Printf("📚 Recorded this run's failed logins in the security history (%d IPs", len(store.IPs))
+	if dropped > 0 {
+		fmt.Printf(", %d expired", dropped)
+	}
+	fmt.Printf("): %s\n", securityHistoryPath())
+	return nil
+}
+
+// ipReputationPath returns the --ip-reputation file, or the default one in
+// the config directory
+func ipReputationPath() string {
+	if ipReputationFile != "" {
+		return ipReputationFile
+	}
+	return filepath.Join(analyseConfigDir, security.DefaultReputationStoreFile)
+}
+
+// recordIPReputation adds the threats and IP behavior of logs to the IP
+// reputation store, keeping its retention period
+func recordIPReputation(logs []*parser.LogEntry) error {
+	if len(logs) == 0 {
+		return fmt.Errorf("no log entries to record")
+	}
+	
+	analysis, err := analyseSecurity(logs, "the IP reputation")
+	if err != nil {
+		return err
+	}
+	
+	return saveIPReputation(logs, analysis, os.Stdout)
+}
+
+// saveIPReputation records an analysis of logs in the IP reputation store and
+// saves it, reporting to w
+func saveIPReputation(logs []*parser.LogEntry, analysis *security.EnhancedSecurityAnalysis, w io.Writer) error {
+	store, err := security.LoadReputationStore(ipReputationPath())
+	if err != nil {
+		return err
+	}
+	
+	store.Record(logs, analysis)
+	dropped := store.Prune(security.ReputationRetention)
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("failed to save IP reputation: %w", err)
+	}
+	
+	fmt.Fprintf(w, "🛡️  Recorded this run's threats in the IP reputation store (%d IPs", len(store.IPs))
+	if dropped > 0 {
+		fmt.Fprintf(w, ", %d expired", dropped)
+	}
+	fmt.Fprintf(w, "): %s\n", ipReputationPath())
+	return nil
+}
+
 // applyPreset loads and applies a configuration preset
 func applyPreset(presetName string) error {
 	// Load configuration
@@ -957,6 +3385,7 @@ func applyPreset(presetName string) error {
 	// Apply preset query if available
 	if preset.Query != "" {
 		queryString = preset.Query
+		presetParams = preset.ParameterValues(nil)
 		fmt.Printf("🔍 Using query: %s\n\n", preset.Query)
 	}
 
@@ -983,6 +3412,12 @@ func applyPreset(presetName string) error {
 			} else {
 				exportCSV = fmt.Sprintf("output/%s.csv", presetName)
 			}
+		case "xlsx":
+			if exportConfig.Filename != "" {
+				exportXLSX = exportConfig.Filename
+			} else {
+				exportXLSX = fmt.Sprintf("output/%s.xlsx", presetName)
+			}
 		case "html":
 			if exportConfig.Filename != "" {
 				exportHTML = exportConfig.Filename
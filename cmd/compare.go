@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"smart-log-analyser/pkg/analyser"
+	"smart-log-analyser/pkg/html"
+)
+
+var (
+	compareOutput      string
+	compareTitle       string
+	compareBeforeLabel string
+	compareAfterLabel  string
+)
+
+var compareReportCmd = &cobra.Command{
+	Use:   "compare-report [before.json] [after.json]",
+	Short: "Create a side-by-side HTML report comparing two JSON exports",
+	Long: `Create an HTML report comparing two analyses exported with --export-json,
+e.g. last week against this week. The first export is the baseline.
+
+The report shows both datasets side by side with the change of each key
+metric, highlights metrics and endpoints that regressed or improved by 10%
+or more, and lists the URLs and IPs that gained or lost the most requests.
+Exports written with older schema versions are upgraded first; for grouped
+exports the combined roll-up is compared.
+
+Examples:
+  # Compare last week with this week
+  ./smart-log-analyser analyse last-week.log --export-json last-week.json
+  ./smart-log-analyser analyse this-week.log --export-json this-week.json
+  ./smart-log-analyser compare-report last-week.json this-week.json -o comparison.html
+
+  # Name the datasets in the report
+  ./smart-log-analyser compare-report a.json b.json --before-label "Before deploy" --after-label "After deploy"`,
+	Args: cobra.ExactArgs(2),
+	Run:  runCompareReport,
+}
+
+func init() {
+	rootCmd.AddCommand(compareReportCmd)
+
+	compareReportCmd.Flags().StringVarP(&compareOutput, "output", "o", "output/comparison.html", "HTML file to write the report to")
+	compareReportCmd.Flags().StringVar(&compareTitle, "title", "Log Comparison Report", "Title of the report")
+	compareReportCmd.Flags().StringVar(&compareBeforeLabel, "before-label", "", "Name of the baseline dataset (default: its file name)")
+	compareReportCmd.Flags().StringVar(&compareAfterLabel, "after-label", "", "Name of the compared dataset (default: its file name)")
+	compareReportCmd.Flags().BoolVar(&htmlOffline, "offline", false, "Inline all styles and scripts instead of loading them from a CDN")
+	compareReportCmd.Flags().StringVar(&htmlAssetsDir, "assets-dir", "", "Directory with local copies of Bootstrap and Chart.js to inline (implies --offline)")
+	compareReportCmd.Flags().StringVar(&topN, "top", "", "Movers and regressed endpoints per table: a number or 'all' (default 10)")
+}
+
+func runCompareReport(cmd *cobra.Command, args []string) {
+	if topN != "" {
+		if _, err := analyser.ParseTopN(topN); err != nil {
+			log.Fatalf("Invalid --top: %v", err)
+		}
+	}
+
+	var datasets [2]*analyser.Results
+	for i, filename := range args {
+		results, err := analyser.LoadResultsJSON(filename)
+		if err != nil {
+			fmt.Printf("❌ Error reading %s: %v\n", filename, err)
+			os.Exit(1)
+		}
+		datasets[i] = results
+	}
+
+	beforeLabel, afterLabel := compareBeforeLabel, compareAfterLabel
+	if beforeLabel == "" {
+		beforeLabel = exportLabel(args[0])
+	}
+	if afterLabel == "" {
+		afterLabel = exportLabel(args[1])
+	}
+
+	if err := exportComparisonHTML(datasets[0], datasets[1], beforeLabel, afterLabel, compareOutput, compareTitle); err != nil {
+		fmt.Printf("❌ Failed to create comparison report: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("⚖️  Exported comparison HTML report to: %s\n", compareOutput)
+}
+
+// exportComparisonHTML writes the side-by-side report of two results
+func exportComparisonHTML(before, after *analyser.Results, beforeLabel, afterLabel, filename, title string) error {
+	generator, err := html.NewGenerator()
+	if err != nil {
+		return fmt.Errorf("failed to create HTML generator: %w", err)
+	}
+	if topN != "" {
+		generator.SetTopN(exportTopN(analyser.TopAll))
+	}
+	generator.SetOffline(htmlOffline || htmlAssetsDir != "", htmlAssetsDir)
+	return generator.GenerateComparisonReport(before, after, beforeLabel, afterLabel, filename, title)
+}
+
+// exportLabel names a dataset after its export's file name
+func exportLabel(filename string) string {
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+}
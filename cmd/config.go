@@ -257,6 +257,7 @@ func listTemplates(cm *config.ConfigManager) {
 	
 	w.Flush()
 	fmt.Println()
+	fmt.Println("💡 Generate a report with './smart-log-analyser analyse <log-files> --template <name>'")
 }
 
 func listServerProfiles(cm *config.ConfigManager) {
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"smart-log-analyser/pkg/analyser"
+)
+
+var convertOutput string
+
+var convertCmd = &cobra.Command{
+	Use:   "convert-export [json file]",
+	Short: "Upgrade a JSON export to the current results schema",
+	Long: fmt.Sprintf(`Upgrade a JSON export written by an older version of Smart Log Analyser
+to the current results schema (schema_version %d).
+
+Exports written before schema_version existed are treated as version 1.
+Fields that did not exist in the older version are added with empty values,
+so downstream tooling can rely on every documented field being present.
+Grouped exports (--group) are upgraded per group and for the combined roll-up.
+
+Examples:
+  # Print the upgraded export
+  ./smart-log-analyser convert-export old-results.json
+
+  # Write the upgraded export to a file
+  ./smart-log-analyser convert-export old-results.json --output results.json`, analyser.ResultsSchemaVersion),
+	Args: cobra.ExactArgs(1),
+	Run:  runConvertExport,
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringVarP(&convertOutput, "output", "o", "", "Write the upgraded export to this file instead of stdout")
+}
+
+func runConvertExport(cmd *cobra.Command, args []string) {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Printf("❌ Error reading export: %v\n", err)
+		os.Exit(1)
+	}
+
+	upgraded, err := analyser.UpgradeResultsJSON(data)
+	if err != nil {
+		fmt.Printf("❌ Error converting %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	if convertOutput == "" {
+		os.Stdout.Write(upgraded)
+		return
+	}
+
+	if err := os.WriteFile(convertOutput, upgraded, 0644); err != nil {
+		fmt.Printf("❌ Error writing %s: %v\n", convertOutput, err)
+		os.Exit(1)
+	}
+	fmt.Printf("📄 Converted export to schema version %d: %s\n", analyser.ResultsSchemaVersion, convertOutput)
+}
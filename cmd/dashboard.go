@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"smart-log-analyser/pkg/analyser"
+	"smart-log-analyser/pkg/charts"
+	"smart-log-analyser/pkg/parser"
+	"smart-log-analyser/pkg/remote"
+)
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard [log-files...]",
+	Short: "Live-updating terminal dashboard of log traffic",
+	Long: `Follow one or more access logs and redraw a dashboard of stat cards and
+charts in place every few seconds, like top. Lines appended to the files are
+picked up as they are written; truncated or rotated files are read again from
+the start.
+
+With --remote, the log_path of a server in the SSH configuration is followed
+with tail -F over SSH instead, without shipping logs anywhere. A dropped
+connection is retried in the background.
+
+Examples:
+  smart-log-analyser dashboard /var/log/nginx/access.log
+  smart-log-analyser dashboard access.log --interval 5s --width 120
+  smart-log-analyser dashboard access.log --new-only --braille-charts
+  smart-log-analyser dashboard --remote web1.example.com --remote web2.example.com
+
+The dashboard fits the terminal and redraws when it is resized, unless
+--width is given. Press Ctrl+C to quit.`,
+	Args: requireLogsOrRemote,
+	Run:  runDashboard,
+}
+
+var (
+	dashboardInterval time.Duration
+	dashboardWidth    int
+	dashboardNewOnly  bool
+	dashboardBraille  bool
+	dashboardNoColors bool
+
+	followRemotes []string
+)
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+
+	dashboardCmd.Flags().DurationVar(&dashboardInterval, "interval", 2*time.Second, "How often to read new lines and redraw")
+	dashboardCmd.Flags().IntVar(&dashboardWidth, "width", 0, "Width of the dashboard in columns (default: terminal width, following resizes)")
+	dashboardCmd.Flags().BoolVar(&dashboardNewOnly, "new-only", false, "Only count lines written after the dashboard starts")
+	dashboardCmd.Flags().BoolVar(&dashboardBraille, "braille-charts", false, "Draw the requests over time chart with Braille dots")
+	dashboardCmd.Flags().BoolVar(&dashboardNoColors, "no-colors", false, "Disable colors")
+	dashboardCmd.Flags().StringVar(&chartThemeName, "chart-theme", "", "Chart color theme: "+strings.Join(charts.ThemeNames(), ", ")+" (default: chart_theme from the config, or default)")
+	dashboardCmd.Flags().StringArrayVar(&chartColorSpecs, "chart-color", nil, "Override a chart color as element=color, e.g. 5xx=magenta (repeatable)")
+	dashboardCmd.Flags().StringArrayVar(&followRemotes, "remote", nil, "Follow the log_path of this server of --config over SSH (repeatable)")
+	dashboardCmd.Flags().StringVar(&configFile, "config", "servers.json", "Path to SSH configuration file of the --remote servers")
+	dashboardCmd.Flags().StringVar(&knownHostsFile, "known-hosts", "", "known_hosts file to verify server host keys against (default ~/.ssh/known_hosts)")
+	dashboardCmd.Flags().BoolVar(&acceptNewHostKeys, "accept-new-host-keys", false, "Trust and record the host keys of servers not in known_hosts without asking (changed keys are still rejected)")
+	dashboardCmd.Flags().StringVar(&credentialsFile, "credentials-file", "credentials.enc", "Encrypted credentials file of servers with credential_store \"file\"")
+	dashboardCmd.Flags().BoolVar(&kubernetesLogs, "kubernetes", false, "Follow the logs of the running Kubernetes pods of --selector")
+	dashboardCmd.Flags().StringVar(&kubeconfigFile, "kubeconfig", "", "kubeconfig file (default $KUBECONFIG or ~/.kube/config, or the service account in a pod)")
+	dashboardCmd.Flags().StringVar(&kubeContext, "context", "", "kubeconfig context (default the current context)")
+	dashboardCmd.Flags().StringVar(&kubeNamespace, "namespace", "", "Namespace of the pods (default the namespace of the context)")
+	dashboardCmd.Flags().StringVar(&kubeSelector, "selector", "", "Label selector of the pods, e.g. app.kubernetes.io/name=ingress-nginx")
+	dashboardCmd.Flags().StringVar(&kubeContainer, "container", "", "Container of the pods whose logs to read (default all)")
+}
+
+func runDashboard(cmd *cobra.Command, args []string) {
+	if dashboardInterval < 100*time.Millisecond {
+		fmt.Printf("❌ --interval must be at least 100ms\n")
+		os.Exit(1)
+	}
+
+	theme, err := resolveChartTheme()
+	if err != nil {
+		fmt.Printf("❌ Failed to set up chart colors: %v\n", err)
+		os.Exit(1)
+	}
+
+	followers, names, stopFollowing := startFollowers(args, dashboardNewOnly)
+	defer stopFollowing()
+
+	// Modules whose state grows with every client, endpoint and threat seen
+	// are not shown, so they are left out of a long-running view
+	a := analyser.New()
+	a.DisableModules(analyser.ModuleSecurity, analyser.ModuleEndpoints, analyser.ModuleProtocols,
+		analyser.ModuleBrokenLinks, analyser.ModuleRateLimits)
+	stream := a.NewStream(nil, nil)
+	logParser := parser.New()
+
+	// Without --width the dashboard follows the terminal as it is resized
+	var resized <-chan int
+	if dashboardWidth <= 0 {
+		dashboardWidth = charts.GetTerminalWidth()
+		var stopWatching func()
+		resized, stopWatching = charts.WatchTerminalWidth()
+		defer stopWatching()
+	}
+
+	generator := charts.NewChartGenerator()
+	generator.SetWidth(dashboardWidth)
+	generator.SetColors(!dashboardNoColors && charts.SupportsColor())
+	generator.SetBraille(dashboardBraille)
+	generator.SetTheme(theme)
+
+	screen := charts.NewScreen(os.Stdout)
+	defer screen.Close()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+
+	first, lastTick := true, time.Now()
+	for {
+		added := 0
+		var warnings []string
+		for _, follower := range followers {
+			lines, err := follower.poll()
+			if err != nil {
+				warnings = append(warnings, err.Error())
+				continue
+			}
+			for _, line := range lines {
+				entry, err := logParser.ParseLine(line)
+				if err != nil {
+					continue
+				}
+				stream.Add(entry)
+				added++
+			}
+		}
+
+		// The first poll reads the existing contents, which are not a live rate
+		now := time.Now()
+		rate := 0.0
+		if !first {
+			rate = float64(added) / now.Sub(lastTick).Seconds()
+		}
+		first, lastTick = false, now
+		screen.Draw(renderDashboard(generator, stream.Results(), rate, names, warnings))
+
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		case dashboardWidth = <-resized:
+			generator.SetWidth(dashboardWidth)
+			screen.Reset()
+		}
+	}
+}
+
+// renderDashboard builds one frame of the dashboard
+func renderDashboard(generator *charts.ChartGenerator, results *analyser.Results, rate float64, files []string, warnings []string) string {
+	var frame strings.Builder
+
+	title := "📡 Smart Log Analyser — Live Dashboard"
+	status := fmt.Sprintf("%s · every %s · Ctrl+C to quit", time.Now().Format("15:04:05"), dashboardInterval)
+	padding := dashboardWidth - len([]rune(title)) - len([]rune(status)) - 1
+	if padding < 1 {
+		padding = 1
+	}
+	frame.WriteString(title + strings.Repeat(" ", padding) + status + "\n")
+	frame.WriteString(strings.Repeat("═", dashboardWidth) + "\n")
+	frame.WriteString(fmt.Sprintf("Following: %s\n", charts.TruncateString(strings.Join(files, ", "), dashboardWidth-11)))
+	for _, warning := range warnings {
+		frame.WriteString(fmt.Sprintf("⚠️  %s\n", warning))
+	}
+	frame.WriteString("\n")
+
+	if results.TotalRequests == 0 {
+		frame.WriteString("Waiting for log entries...\n")
+		return frame.String()
+	}
+
+	// Stat cards
+	errors := 0
+	for _, status := range results.DetailedStatusCodes {
+		if status.Code >= 400 {
+			errors += status.Count
+		}
+	}
+	cards := []string{
+		"Requests " + formatNumber(results.TotalRequests),
+		fmt.Sprintf("Rate %.1f req/s", rate),
+		fmt.Sprintf("Errors %.1f%%", float64(errors)*100/float64(results.TotalRequests)),
+		"Bandwidth " + formatBytes(results.TotalBytes),
+		"Unique IPs " + formatNumber(results.UniqueIPs),
+	}
+	frame.WriteString(" " + strings.Join(cards, " │ ") + "\n\n")
+
+	if sparklines, ok := charts.NewTimelineSparklines(results.Timeline, dashboardWidth-14); ok {
+		frame.WriteString(fmt.Sprintf("Requests    %s\n", sparklines.Traffic))
+		frame.WriteString(fmt.Sprintf("Error rate  %s\n", sparklines.ErrorRate))
+		frame.WriteString(fmt.Sprintf("Bandwidth   %s\n", sparklines.Bandwidth))
+		frame.WriteString(fmt.Sprintf("            %s\n\n", sparklines.Describe()))
+	}
+
+	frame.WriteString(generator.GenerateTimeSeriesChart(results, 0) + "\n")
+	frame.WriteString(generator.GenerateStatusCodeChart(results) + "\n")
+	frame.WriteString(generator.GenerateTopURLsChart(results, 5) + "\n")
+	frame.WriteString(generator.GenerateTopIPsChart(results, 5))
+
+	return frame.String()
+}
+
+// lineFollower reads the lines appended to a log since it was last polled
+type lineFollower interface {
+	poll() ([]string, error)
+}
+
+// requireLogsOrRemote accepts log files, --remote servers, --kubernetes pods
+// or any mix of them
+func requireLogsOrRemote(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && len(followRemotes) == 0 && !kubernetesLogs {
+		return fmt.Errorf("requires at least 1 log file, --remote server or --kubernetes")
+	}
+	return nil
+}
+
+// startFollowers follows the log files, the log_path of every --remote server
+// and, with --kubernetes, the selected pods, exiting on errors. It returns the
+// followers, the names of the logs they follow and a function that
+// disconnects from the servers.
+func startFollowers(paths []string, fromEnd bool) ([]lineFollower, []string, func()) {
+	var followers []lineFollower
+	names := append([]string(nil), paths...)
+	for _, path := range paths {
+		follower, err := newLogFollower(path, fromEnd)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		followers = append(followers, follower)
+	}
+	var closers []io.Closer
+	stop := func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+	if len(followRemotes) == 0 && !kubernetesLogs {
+		return followers, names, stop
+	}
+
+	var config *remote.Config
+	if len(followRemotes) > 0 {
+		configureSSH()
+		var err error
+		if config, err = remote.LoadConfig(configFile); err != nil {
+			fmt.Printf("❌ Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, host := range followRemotes {
+		var server *remote.SSHConfig
+		for i := range config.Servers {
+			if config.Servers[i].Host == host {
+				server = &config.Servers[i]
+				break
+			}
+		}
+		if server == nil {
+			stop()
+			fmt.Printf("❌ No server %s in %s\n", host, configFile)
+			os.Exit(1)
+		}
+
+		fmt.Printf("🔌 Connecting to %s...\n", host)
+		tail, err := remote.StartTail(server, server.LogPath, fromEnd)
+		if err != nil {
+			stop()
+			fmt.Printf("❌ Failed to follow %s: %v\n", host, err)
+			os.Exit(1)
+		}
+		closers = append(closers, tail)
+		followers = append(followers, remoteFollower{tail})
+		names = append(names, tail.Name())
+	}
+
+	if kubernetesLogs {
+		client, err := remote.NewKubeClient(kubeconfigFile, kubeContext)
+		if err != nil {
+			stop()
+			fmt.Printf("❌ Failed to connect to Kubernetes: %v\n", err)
+			os.Exit(1)
+		}
+		pods, err := remote.FollowPods(client, kubeLogSource(), fromEnd)
+		if err != nil {
+			stop()
+			fmt.Printf("❌ Failed to follow pods: %v\n", err)
+			os.Exit(1)
+		}
+		closers = append(closers, pods)
+		followers = append(followers, remoteFollower{pods})
+		names = append(names, pods.Name())
+	}
+	return followers, names, stop
+}
+
+// remoteFollower reads the lines a remote tail or pod follower received since
+// it was last polled
+type remoteFollower struct {
+	source interface {
+		Poll() ([]string, error)
+	}
+}
+
+func (f remoteFollower) poll() ([]string, error) {
+	return f.source.Poll()
+}
+
+// logFollower reads the lines appended to a log file since it was last polled
+type logFollower struct {
+	path    string
+	offset  int64
+	info    os.FileInfo
+	partial string // Text after the last newline, completed by a later write
+}
+
+// newLogFollower starts following a file from its beginning, or from its end
+// when only new lines are wanted
+func newLogFollower(path string, fromEnd bool) (*logFollower, error) {
+	if strings.HasSuffix(path, ".gz") {
+		return nil, fmt.Errorf("cannot follow compressed file %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot follow %s: %w", path, err)
+	}
+
+	follower := &logFollower{path: path, info: info}
+	if fromEnd {
+		follower.offset = info.Size()
+	}
+	return follower, nil
+}
+
+// poll returns the complete lines written since the last poll. A file that
+// shrank or was replaced, e.g. by log rotation, is read from the start.
+func (f *logFollower) poll() ([]string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", f.path, err)
+	}
+	if !os.SameFile(info, f.info) || info.Size() < f.offset {
+		f.offset = 0
+		f.partial = ""
+	}
+	f.info = info
+	if info.Size() == f.offset {
+		return nil, nil
+	}
+
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", f.path, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(f.offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", f.path, err)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", f.path, err)
+	}
+	f.offset += int64(len(data))
+
+	text := f.partial + string(data)
+	end := strings.LastIndex(text, "\n")
+	f.partial = text[end+1:]
+	if end < 0 {
+		return nil, nil
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text[:end], "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"smart-log-analyser/pkg/analyser"
+	"smart-log-analyser/pkg/charts"
 	"smart-log-analyser/pkg/remote"
 )
 
@@ -22,6 +27,33 @@ var (
 	singleFile   bool
 	listFiles    bool
 	maxFiles     int
+
+	knownHostsFile    string
+	acceptNewHostKeys bool
+
+	credentialsFile  string
+	storeCredentials bool
+
+	parallelServers int
+	filesPerServer  int
+	bandwidthLimit  string
+	syncDownloads   bool
+	downloadRetries int
+	noVerify        bool
+
+	downloadSince string
+	downloadUntil string
+
+	kubernetesLogs bool
+	kubeconfigFile string
+	kubeContext    string
+	kubeNamespace  string
+	kubeSelector   string
+	kubeContainer  string
+
+	bucketURL      string
+	bucketEndpoint string
+	bucketRegion   string
 )
 
 var downloadCmd = &cobra.Command{
@@ -30,11 +62,28 @@ var downloadCmd = &cobra.Command{
 	Long: `Download Nginx access logs from remote servers using SSH credentials.
 Requires a JSON configuration file with server details.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		configureSSH()
+
+		if kubernetesLogs {
+			handleKubernetesDownload()
+			return
+		}
+
+		if bucketURL != "" {
+			handleBucketDownload()
+			return
+		}
+
 		if createConfig {
 			handleCreateConfig()
 			return
 		}
 
+		if storeCredentials {
+			handleStoreCredentials()
+			return
+		}
+
 		if testConn {
 			handleTestConnection()
 			return
@@ -59,6 +108,145 @@ func init() {
 	downloadCmd.Flags().BoolVar(&singleFile, "single", false, "Download only the main configured log file")
 	downloadCmd.Flags().BoolVar(&listFiles, "list", false, "List available log files without downloading")
 	downloadCmd.Flags().IntVar(&maxFiles, "max-files", 10, "Maximum number of files to download (default: 10)")
+	downloadCmd.Flags().StringVar(&knownHostsFile, "known-hosts", "", "known_hosts file to verify server host keys against (default ~/.ssh/known_hosts)")
+	downloadCmd.Flags().BoolVar(&acceptNewHostKeys, "accept-new-host-keys", false, "Trust and record the host keys of servers not in known_hosts without asking (changed keys are still rejected)")
+	downloadCmd.Flags().IntVar(&parallelServers, "parallel", remote.DefaultParallelServers, "Number of servers, or with --kubernetes containers and with --bucket objects, to download from at once")
+	downloadCmd.Flags().IntVar(&filesPerServer, "per-server", remote.DefaultFilesPerServer, "Number of files to download at once from each server")
+	downloadCmd.Flags().StringVar(&bandwidthLimit, "bandwidth-limit", "", "Cap the combined download rate per second, e.g. 10MB or 512KB (default unlimited)")
+	downloadCmd.Flags().IntVar(&downloadRetries, "retries", remote.DefaultRetries, "Number of times to retry a failed file, resuming where it stopped when possible")
+	downloadCmd.Flags().BoolVar(&noVerify, "no-verify", false, "Skip the checksum check of downloaded files against the server's (sizes are still checked)")
+	downloadCmd.Flags().BoolVar(&syncDownloads, "sync", false, "Only download files that are new or changed since the last sync into the output directory")
+	downloadCmd.Flags().StringVar(&downloadSince, "since", "", "Only download lines from this time on (YYYY-MM-DD HH:MM:SS), filtered on the server")
+	downloadCmd.Flags().StringVar(&downloadUntil, "until", "", "Only download lines up to this time (YYYY-MM-DD HH:MM:SS), filtered on the server")
+	downloadCmd.Flags().BoolVar(&kubernetesLogs, "kubernetes", false, "Download the logs of Kubernetes pods instead of SSH servers")
+	downloadCmd.Flags().StringVar(&kubeconfigFile, "kubeconfig", "", "kubeconfig file (default $KUBECONFIG or ~/.kube/config, or the service account in a pod)")
+	downloadCmd.Flags().StringVar(&kubeContext, "context", "", "kubeconfig context (default the current context)")
+	downloadCmd.Flags().StringVar(&kubeNamespace, "namespace", "", "Namespace of the pods (default the namespace of the context)")
+	downloadCmd.Flags().StringVar(&kubeSelector, "selector", "", "Label selector of the pods, e.g. app.kubernetes.io/name=ingress-nginx")
+	downloadCmd.Flags().StringVar(&kubeContainer, "container", "", "Container of the pods whose logs to read (default all)")
+	downloadCmd.Flags().StringVar(&bucketURL, "bucket", "", "Download the log archives under an object storage URL, s3://bucket/prefix, gs://bucket/prefix or az://account/container/prefix, instead of SSH servers")
+	downloadCmd.Flags().StringVar(&bucketEndpoint, "endpoint", "", "Endpoint of an S3-compatible service such as MinIO, e.g. http://localhost:9000, or of a storage emulator")
+	downloadCmd.Flags().StringVar(&bucketRegion, "region", "", "Region of an S3 bucket (default $AWS_REGION, the AWS profile's region or us-east-1)")
+	downloadCmd.Flags().StringVar(&credentialsFile, "credentials-file", "credentials.enc", "Encrypted credentials file of servers with credential_store \"file\"")
+	downloadCmd.Flags().BoolVar(&storeCredentials, "store-credentials", false, "Save the passwords and key passphrases of servers with a credential_store, moving plaintext passwords out of the configuration")
+}
+
+// configureSSH applies the host key and credential flags to the SSH
+// connections of the command
+func configureSSH() {
+	remote.DefaultHostKeyPolicy = remote.HostKeyPolicy{
+		KnownHostsFile: knownHostsFile,
+		AcceptNew:      acceptNewHostKeys,
+		Prompt:         trustHostKey,
+	}
+	remote.DefaultCredentialFile.Path = credentialsFile
+}
+
+// handleStoreCredentials saves the secrets of the servers with a credential
+// store: a plaintext password in the configuration is moved to the store,
+// otherwise the password and key passphrase are asked for
+func handleStoreCredentials() {
+	config, err := remote.LoadConfig(configFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	moved := false
+	for i := range config.Servers {
+		server := &config.Servers[i]
+		if serverName != "" && server.Host != serverName {
+			continue
+		}
+
+		fmt.Printf("🔐 %s@%s:%d\n", server.Username, server.Host, server.Port)
+		if server.CredentialStore == "" {
+			fmt.Printf("   Skipped: no credential_store set (use \"%s\" or \"%s\")\n", remote.CredentialStoreKeyring, remote.CredentialStoreFile)
+			continue
+		}
+
+		if server.Password != "" {
+			if err := server.StoreSecret(remote.SecretPassword, server.Password); err != nil {
+				fmt.Printf("   ❌ %v\n", err)
+				continue
+			}
+			server.Password = ""
+			moved = true
+			fmt.Printf("   ✅ Password moved from %s to the %s store\n", configFile, server.CredentialStore)
+		} else {
+			password, err := remote.ReadSecret("   Password (leave empty to keep the stored one): ")
+			if err != nil {
+				log.Fatalf("Failed to read password: %v", err)
+			}
+			if password != "" {
+				if err := server.StoreSecret(remote.SecretPassword, password); err != nil {
+					fmt.Printf("   ❌ %v\n", err)
+					continue
+				}
+				fmt.Printf("   ✅ Password saved to the %s store\n", server.CredentialStore)
+			}
+		}
+
+		if server.KeyFile == "" {
+			continue
+		}
+		encrypted, err := remote.KeyFileEncrypted(server.KeyFile)
+		if err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			continue
+		}
+		if !encrypted {
+			fmt.Printf("   Key file %s has no passphrase\n", server.KeyFile)
+			continue
+		}
+		passphrase, err := remote.ReadSecret(fmt.Sprintf("   Passphrase of %s (leave empty to keep the stored one): ", server.KeyFile))
+		if err != nil {
+			log.Fatalf("Failed to read passphrase: %v", err)
+		}
+		if passphrase == "" {
+			continue
+		}
+		if err := remote.CheckKeyPassphrase(server.KeyFile, passphrase); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			continue
+		}
+		if err := server.StoreSecret(remote.SecretPassphrase, passphrase); err != nil {
+			fmt.Printf("   ❌ %v\n", err)
+			continue
+		}
+		fmt.Printf("   ✅ Key passphrase saved to the %s store\n", server.CredentialStore)
+	}
+
+	if moved {
+		if err := remote.SaveConfig(configFile, config); err != nil {
+			log.Fatalf("Failed to save config: %v", err)
+		}
+		fmt.Printf("\nRemoved the moved passwords from %s\n", configFile)
+	}
+}
+
+// trustHostKey asks on the terminal whether to trust the host key of a server
+// missing from known_hosts; without a terminal the server is rejected
+func trustHostKey(host, keyType, fingerprint string) bool {
+	// One prompt at a time, below the progress bars, which then start afresh
+	downloadOutput.Lock()
+	defer downloadOutput.Unlock()
+	progressLines = 0
+
+	if stat, err := os.Stdin.Stat(); err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		fmt.Fprintf(os.Stderr, "\n⚠️  Unknown host key for %s (%s %s); run interactively or pass --accept-new-host-keys to trust it\n", host, keyType, fingerprint)
+		return false
+	}
+
+	fmt.Printf("\n⚠️  The authenticity of host %s can't be established.\n", host)
+	fmt.Printf("   %s key fingerprint is %s.\n", keyType, fingerprint)
+	fmt.Print("   Trust this host and add it to known hosts? (y/n): ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println("\n   No answer; pass --accept-new-host-keys to trust new hosts without asking")
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
 func handleCreateConfig() {
@@ -114,7 +302,7 @@ func handleTestConnection() {
 			continue
 		}
 
-		fmt.Printf("Testing connection to %s@%s:%d... ", server.Username, server.Host, server.Port)
+		fmt.Printf("Testing connection to %s@%s:%d (%s)... ", server.Username, server.Host, server.Port, server.AuthDescription())
 		
 		if err := remote.TestConnection(&server); err != nil {
 			fmt.Printf("❌ FAILED: %v\n", err)
@@ -134,7 +322,7 @@ func handleListFiles() {
 		log.Fatal("No servers configured")
 	}
 
-	fmt.Println("Listing available log files...\n")
+	fmt.Print("Listing available log files...\n\n")
 
 	for _, server := range config.Servers {
 		if serverName != "" && server.Host != serverName {
@@ -196,96 +384,518 @@ func handleDownload() {
 		log.Fatal("No servers configured")
 	}
 
+	if parallelServers < 1 || filesPerServer < 1 {
+		log.Fatal("--parallel and --per-server must be at least 1")
+	}
+	bandwidth := downloadBandwidth()
+
+	timeRange := downloadTimeRange()
+	if !timeRange.IsZero() && syncDownloads {
+		log.Fatal("--sync cannot be combined with --since or --until, as filtered files cannot be compared with the remote ones")
+	}
+
+	var servers []remote.SSHConfig
+	for _, server := range config.Servers {
+		if serverName == "" || server.Host == serverName {
+			servers = append(servers, server)
+		}
+	}
+	if len(servers) == 0 {
+		log.Fatalf("No server %s in %s", serverName, configFile)
+	}
+
+	// Ask for the credentials file passphrase before the progress bars start
+	for _, server := range servers {
+		if server.CredentialStore == remote.CredentialStoreFile {
+			if err := remote.DefaultCredentialFile.Unlock(); err != nil {
+				log.Fatalf("Failed to open credentials: %v", err)
+			}
+			break
+		}
+	}
+
 	// Create output directory
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		log.Fatalf("Failed to create output directory: %v", err)
 	}
 
-	fmt.Printf("Downloading log files to: %s\n\n", outputDir)
+	fmt.Printf("Downloading log files to: %s\n", outputDir)
+	fmt.Printf("📡 %d server(s), %d at once, %d file(s) at once per server", len(servers), parallelServers, filesPerServer)
+	if bandwidth > 0 {
+		fmt.Printf(", limited to %s/s", formatBytes(bandwidth))
+	}
+	fmt.Println()
+	if !timeRange.IsZero() {
+		fmt.Printf("🔎 Only lines from %s to %s, filtered on the servers\n", formatTimeBound(timeRange.Since, "the start"), formatTimeBound(timeRange.Until, "the end"))
+	}
+	fmt.Println()
+
+	options := remote.DownloadOptions{
+		OutputDir:      outputDir,
+		Single:         singleFile,
+		MaxFiles:       maxFiles,
+		Parallel:       parallelServers,
+		PerServer:      filesPerServer,
+		BandwidthLimit: bandwidth,
+		TimeRange:      timeRange,
+		Retries:        downloadRetries,
+		NoVerify:       noVerify,
+	}
+	if syncDownloads {
+		options.Sync, err = remote.LoadSyncState(outputDir)
+		if err != nil {
+			log.Fatalf("Failed to load sync state: %v", err)
+		}
+	}
+	start := time.Now()
+	var results []remote.ServerProgress
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		downloader := remote.NewDownloader(options, nil)
+		stop := showDownloadProgress(downloader, start)
+		results = downloader.Run(servers)
+		stop()
+	} else {
+		results = remote.NewDownloader(options, printDownloadEvent).Run(servers)
+	}
 
-	for _, server := range config.Servers {
-		if serverName != "" && server.Host != serverName {
-			continue
+	if options.Sync != nil {
+		if err := options.Sync.Save(); err != nil {
+			log.Fatalf("Failed to save sync state: %v", err)
 		}
+	}
 
-		fmt.Printf("Connecting to %s@%s:%d...\n", server.Username, server.Host, server.Port)
-		
-		client := remote.NewSSHClient(&server)
-		
-		if err := client.Connect(); err != nil {
-			fmt.Printf("❌ Failed to connect: %v\n\n", err)
+	printDownloadResults(results, options.Sync != nil, start)
+	fmt.Println("\nYou can now analyse the downloaded files:")
+	if timeRange.IsZero() {
+		fmt.Printf("  smart-log-analyser analyse %s/*.log\n", outputDir)
+		return
+	}
+	// The servers keep some lines around the range, whatever the zone of the logs
+	hint := fmt.Sprintf("  smart-log-analyser analyse %s/*.gz", outputDir)
+	if downloadSince != "" {
+		hint += fmt.Sprintf(" --since %q", downloadSince)
+	}
+	if downloadUntil != "" {
+		hint += fmt.Sprintf(" --until %q", downloadUntil)
+	}
+	fmt.Println(hint)
+}
+
+// printDownloadResults prints the outcome of each server and the totals
+func printDownloadResults(results []remote.ServerProgress, synced bool, start time.Time) {
+	fmt.Println()
+	totalFiles, totalSynced, totalBytes := 0, 0, int64(0)
+	for _, result := range results {
+		if result.State == remote.StateFailed && result.FilesDone == 0 && result.FilesSynced == 0 {
+			fmt.Printf("❌ %s: %v\n", result.Host, result.Err)
 			continue
 		}
+		fmt.Printf("📊 %s: %d/%d files downloaded successfully (%s)", result.Host, result.FilesDone, result.Files, formatBytes(result.Bytes))
+		if result.FilesSynced > 0 {
+			fmt.Printf(", %d up to date", result.FilesSynced)
+		}
+		if result.Retries > 0 {
+			fmt.Printf(", %s", retryCount(result.Retries))
+		}
+		fmt.Println()
+		totalFiles += result.FilesDone
+		totalSynced += result.FilesSynced
+		totalBytes += result.Bytes
+	}
+	fmt.Printf("\nDownload completed: %d files, %s in %s", totalFiles, formatBytes(totalBytes), time.Since(start).Round(time.Second))
+	if synced {
+		fmt.Printf(" (%d already up to date)", totalSynced)
+	}
+	fmt.Println()
+	fmt.Printf("Files saved to: %s\n", outputDir)
+}
 
-		var filesToDownload []string
-		logDir := filepath.Dir(server.LogPath)
-		if logDir == "." {
-			logDir = "/var/log/nginx"
+// handleBucketDownload downloads the log archives of a bucket, or lists them
+// with --list or --test
+func handleBucketDownload() {
+	if parallelServers < 1 {
+		log.Fatal("--parallel must be at least 1")
+	}
+	bandwidth := downloadBandwidth()
+	timeRange := downloadTimeRange()
+
+	store, prefix, err := remote.OpenBucket(bucketURL, remote.BucketOptions{Endpoint: bucketEndpoint, Region: bucketRegion})
+	if err != nil {
+		log.Fatalf("Failed to open bucket: %v", err)
+	}
+	filter := remote.ObjectFilter{Prefix: prefix, TimeRange: timeRange}
+
+	if testConn || listFiles {
+		listed, err := store.ListObjects(prefix)
+		if err != nil {
+			log.Fatalf("Failed to list objects: %v", err)
 		}
+		objects := filter.Select(listed)
+		fmt.Printf("📋 %d of %d object(s) under %s/%s:\n", len(objects), len(listed), store.Name(), prefix)
+		for _, object := range objects {
+			fmt.Printf("  - %s (%s, %s)\n", object.Path, formatBytes(object.Size), object.ModTime.Local().Format("2006-01-02 15:04:05"))
+		}
+		return
+	}
 
-		if singleFile {
-			// Download single file only (when --single flag is used)
-			filesToDownload = []string{server.LogPath}
-			fmt.Printf("📄 Downloading single log file: %s\n", server.LogPath)
-		} else {
-			// Default behavior: Download all access log files
-			accessFiles, err := client.ListAccessLogFiles(logDir)
-			if err != nil {
-				fmt.Printf("❌ Failed to list files: %v\n", err)
-				client.Close()
-				continue
-			}
-			
-			// Limit number of files
-			if len(accessFiles) > maxFiles {
-				fmt.Printf("⚠️  Found %d files, downloading first %d (use --max-files to change)\n", len(accessFiles), maxFiles)
-				accessFiles = accessFiles[:maxFiles]
-			}
-			
-			filesToDownload = accessFiles
-			fmt.Printf("📦 Downloading %d access log files...\n", len(filesToDownload))
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	fmt.Printf("Downloading %s/%s to: %s\n", store.Name(), prefix, outputDir)
+	fmt.Printf("📡 %d object(s) at once", parallelServers)
+	if bandwidth > 0 {
+		fmt.Printf(", limited to %s/s", formatBytes(bandwidth))
+	}
+	fmt.Println()
+	if !timeRange.IsZero() {
+		fmt.Printf("🔎 Only objects with lines from %s to %s, by the time in their keys\n", formatTimeBound(timeRange.Since, "the start"), formatTimeBound(timeRange.Until, "the end"))
+	}
+	fmt.Println()
+
+	options := remote.DownloadOptions{
+		OutputDir:      outputDir,
+		Parallel:       parallelServers,
+		BandwidthLimit: bandwidth,
+		Retries:        downloadRetries,
+		NoVerify:       noVerify,
+	}
+	if syncDownloads {
+		options.Sync, err = remote.LoadSyncState(outputDir)
+		if err != nil {
+			log.Fatalf("Failed to load sync state: %v", err)
+		}
+	}
+	start := time.Now()
+	var results []remote.ServerProgress
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		downloader := remote.NewDownloader(options, nil)
+		stop := showDownloadProgress(downloader, start)
+		results = downloader.RunStore(store, filter)
+		stop()
+	} else {
+		results = remote.NewDownloader(options, printDownloadEvent).RunStore(store, filter)
+	}
+
+	if options.Sync != nil {
+		if err := options.Sync.Save(); err != nil {
+			log.Fatalf("Failed to save sync state: %v", err)
 		}
+	}
+
+	printDownloadResults(results, options.Sync != nil, start)
+	fmt.Println("\nYou can now analyse the downloaded files:")
+	// Whole objects are kept, with lines around the range
+	hint := fmt.Sprintf("  smart-log-analyser analyse %s/*", outputDir)
+	if downloadSince != "" {
+		hint += fmt.Sprintf(" --since %q", downloadSince)
+	}
+	if downloadUntil != "" {
+		hint += fmt.Sprintf(" --until %q", downloadUntil)
+	}
+	fmt.Println(hint)
+}
 
-		timestamp := time.Now().Format("20060102_150405")
-		totalBytes := int64(0)
-		successCount := 0
+// kubeLogSource returns the pods selected by the Kubernetes flags
+func kubeLogSource() remote.KubeLogSource {
+	return remote.KubeLogSource{Namespace: kubeNamespace, Selector: kubeSelector, Container: kubeContainer}
+}
 
-		for i, remoteFile := range filesToDownload {
-			// Generate local filename
-			baseName := filepath.Base(remoteFile)
-			localFilename := fmt.Sprintf("%s_%s_%s", server.Host, timestamp, baseName)
-			localPath := filepath.Join(outputDir, localFilename)
+// handleKubernetesDownload downloads the logs of the selected pods, up to
+// --parallel at once, or lists the pods with --list or --test
+func handleKubernetesDownload() {
+	if syncDownloads {
+		log.Fatal("--sync cannot be combined with --kubernetes")
+	}
+	if parallelServers < 1 {
+		log.Fatal("--parallel must be at least 1")
+	}
+	timeRange := downloadTimeRange()
 
-			fmt.Printf("  [%d/%d] %s -> %s\n", i+1, len(filesToDownload), remoteFile, localFilename)
-			
-			if err := client.DownloadFile(remoteFile, localPath); err != nil {
-				fmt.Printf("    ❌ Failed: %v\n", err)
-				continue
-			}
+	client, err := remote.NewKubeClient(kubeconfigFile, kubeContext)
+	if err != nil {
+		log.Fatalf("Failed to connect to Kubernetes: %v", err)
+	}
+	source := kubeLogSource()
+	pods, err := client.ListPods(source)
+	if err != nil {
+		log.Fatalf("Failed to list pods: %v", err)
+	}
+	namespace := source.Namespace
+	if namespace == "" {
+		namespace = client.Namespace
+	}
+	where := fmt.Sprintf("%s/%s", client.Context, namespace)
+	if source.Selector != "" {
+		where += " (" + source.Selector + ")"
+	}
+	if len(pods) == 0 {
+		log.Fatalf("No started pods in %s", where)
+	}
 
-			// Check file size
-			if stat, err := os.Stat(localPath); err == nil {
-				fmt.Printf("    ✅ Downloaded (%d bytes)\n", stat.Size())
-				totalBytes += stat.Size()
-				successCount++
-			} else {
-				fmt.Printf("    ✅ Downloaded\n")
-				successCount++
-			}
+	if testConn || listFiles {
+		fmt.Printf("📋 %d pod(s) in %s:\n", len(pods), where)
+		for _, pod := range pods {
+			fmt.Printf("  - %s [%s]: %s\n", pod.Name, pod.Phase, strings.Join(pod.Containers, ", "))
 		}
+		return
+	}
 
-		fmt.Printf("📊 Summary: %d/%d files downloaded successfully", successCount, len(filesToDownload))
-		if totalBytes > 0 {
-			fmt.Printf(" (%d bytes total)", totalBytes)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	fmt.Printf("Downloading logs of %d pod(s) in %s to: %s\n", len(pods), where, outputDir)
+	if !timeRange.IsZero() {
+		fmt.Printf("🔎 Only lines from %s to %s\n", formatTimeBound(timeRange.Since, "the start"), formatTimeBound(timeRange.Until, "the end"))
+	}
+	fmt.Println()
+
+	timestamp := time.Now().Format("20060102_150405")
+	start := time.Now()
+	var (
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+		files      int
+		failed     int
+		totalBytes int64
+	)
+	slots := make(chan struct{}, parallelServers)
+	for _, pod := range pods {
+		for _, container := range pod.Containers {
+			wg.Add(1)
+			go func(pod remote.KubePod, container string) {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				localPath := filepath.Join(outputDir, fmt.Sprintf("%s_%s_%s.log", pod.Name, timestamp, container))
+				written, err := client.DownloadPodLog(pod, container, localPath, timeRange)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed++
+					fmt.Printf("  ❌ %s/%s: %v\n", pod.Name, container, err)
+					return
+				}
+				files++
+				totalBytes += written
+				fmt.Printf("  ✅ %s/%s -> %s (%s)\n", pod.Name, container, filepath.Base(localPath), formatBytes(written))
+			}(pod, container)
 		}
-		fmt.Println()
-
-		client.Close()
-		fmt.Println()
 	}
+	wg.Wait()
 
-	fmt.Println("Download completed!")
+	fmt.Printf("\nDownload completed: %d files, %s in %s", files, formatBytes(totalBytes), time.Since(start).Round(time.Second))
+	if failed > 0 {
+		fmt.Printf(" (%d failed)", failed)
+	}
+	fmt.Println()
 	fmt.Printf("Files saved to: %s\n", outputDir)
 	fmt.Println("\nYou can now analyse the downloaded files:")
 	fmt.Printf("  smart-log-analyser analyse %s/*.log\n", outputDir)
-}
\ No newline at end of file
+}
+
+// downloadOutput serializes the progress bars and the prompts shown while
+// they are drawn; progressLines is the number of lines the bars last took
+var (
+	downloadOutput sync.Mutex
+	progressLines  int
+)
+
+// downloadBarWidth is the width of the progress bars
+const downloadBarWidth = 14
+
+// showDownloadProgress redraws the progress bars of the servers until the
+// returned stop is called, which draws them a last time
+func showDownloadProgress(downloader *remote.Downloader, start time.Time) (stop func()) {
+	draw := func() {
+		downloadOutput.Lock()
+		defer downloadOutput.Unlock()
+		if progressLines > 0 {
+			fmt.Printf("\033[%dA", progressLines)
+		}
+		lines := downloadProgressLines(downloader.Progress(), time.Since(start))
+		for _, line := range lines {
+			fmt.Printf("\033[2K%s\n", line)
+		}
+		progressLines = len(lines)
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			draw()
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+		draw()
+	}
+}
+
+// downloadProgressLines renders a progress bar per server and one for all
+func downloadProgressLines(servers []remote.ServerProgress, elapsed time.Duration) []string {
+	icons := map[string]string{
+		remote.StateWaiting:     "⏳",
+		remote.StateConnecting:  "🔌",
+		remote.StateListing:     "📋",
+		remote.StateDownloading: "📦",
+		remote.StateDone:        "✅",
+		remote.StateFailed:      "❌",
+	}
+
+	var lines []string
+	var bytes, total int64
+	files, filesDone, filesFinished := 0, 0, 0
+	for _, server := range servers {
+		bytes += server.Bytes
+		total += server.TotalBytes
+		files += server.Files
+		filesDone += server.FilesDone
+		filesFinished += server.FilesDone + server.FilesFailed
+		host := server.Host
+		if len([]rune(host)) > 16 {
+			host = string([]rune(host)[:15]) + "…"
+		}
+
+		switch server.State {
+		case remote.StateFailed:
+			lines = append(lines, fmt.Sprintf("%s %-16s %v", icons[server.State], host, server.Err))
+		case remote.StateWaiting, remote.StateConnecting, remote.StateListing:
+			lines = append(lines, fmt.Sprintf("%s %-16s %s", icons[server.State], host, server.State))
+		default:
+			line := fmt.Sprintf("%s %-16s %s %d/%d files  %s", icons[server.State], host,
+				downloadBar(server.Bytes, server.TotalBytes, server.FilesDone+server.FilesFailed, server.Files), server.FilesDone, server.Files, downloadAmount(server.Bytes, server.TotalBytes))
+			if server.FilesSynced > 0 {
+				line += fmt.Sprintf("  %d up to date", server.FilesSynced)
+			}
+			if server.Retries > 0 {
+				line += "  " + retryCount(server.Retries)
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	rate := float64(bytes) / elapsed.Seconds()
+	summary := fmt.Sprintf("   %-16s %s %d/%d files  %s  %s/s", "Total", downloadBar(bytes, total, filesFinished, files), filesDone, files, downloadAmount(bytes, total), formatBytes(int64(rate)))
+	if rate > 0 && total > bytes {
+		summary += fmt.Sprintf("  ETA %s", (time.Duration(float64(total-bytes)/rate) * time.Second).Round(time.Second))
+	}
+	lines = append(lines, summary)
+
+	// Cut to the terminal width, as a wrapped line would throw off the redraw
+	width := charts.GetTerminalWidth() - 1
+	for i, line := range lines {
+		runes := []rune(line)
+		columns := len(runes)
+		if !strings.HasPrefix(line, " ") {
+			columns++ // The icon is two columns wide
+		}
+		if columns > width {
+			lines[i] = string(runes[:len(runes)-(columns-width)-1]) + "…"
+		}
+	}
+	return lines
+}
+
+// retryCount describes the retries of a download
+func retryCount(retries int) string {
+	if retries == 1 {
+		return "1 retry"
+	}
+	return fmt.Sprintf("%d retries", retries)
+}
+
+// downloadBandwidth parses --bandwidth-limit, exiting on errors
+func downloadBandwidth() int64 {
+	if bandwidthLimit == "" {
+		return 0
+	}
+	bandwidth, err := analyser.ParseByteSize(strings.TrimSuffix(strings.ToUpper(bandwidthLimit), "/S"))
+	if err != nil {
+		log.Fatalf("Invalid --bandwidth-limit: %v", err)
+	}
+	return bandwidth
+}
+
+// downloadTimeRange parses --since and --until, exiting on errors
+func downloadTimeRange() remote.TimeRange {
+	var timeRange remote.TimeRange
+	if downloadSince != "" {
+		t, err := time.Parse("2006-01-02 15:04:05", downloadSince)
+		if err != nil {
+			log.Fatalf("Invalid --since: %v", err)
+		}
+		timeRange.Since = &t
+	}
+	if downloadUntil != "" {
+		t, err := time.Parse("2006-01-02 15:04:05", downloadUntil)
+		if err != nil {
+			log.Fatalf("Invalid --until: %v", err)
+		}
+		timeRange.Until = &t
+	}
+	return timeRange
+}
+
+// formatTimeBound formats a bound of a time range, or names an open one
+func formatTimeBound(bound *time.Time, open string) string {
+	if bound == nil {
+		return open
+	}
+	return bound.Format("2006-01-02 15:04:05")
+}
+
+// downloadBar draws the share of bytes downloaded, with its percentage, or
+// the share of files finished when their sizes are not known
+func downloadBar(bytes, total int64, finished, files int) string {
+	ratio := 1.0
+	if total > 0 {
+		ratio = float64(bytes) / float64(total)
+	} else if files > 0 {
+		ratio = float64(finished) / float64(files)
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * downloadBarWidth)
+	return fmt.Sprintf("%s%s %3d%%", strings.Repeat("█", filled), strings.Repeat("░", downloadBarWidth-filled), int(ratio*100))
+}
+
+// downloadAmount formats the bytes downloaded out of the total, when known
+func downloadAmount(bytes, total int64) string {
+	if total <= 0 {
+		return formatBytes(bytes)
+	}
+	return formatBytes(bytes) + "/" + formatBytes(total)
+}
+
+// printDownloadEvent prints a line per download event, for output that is
+// not a terminal
+func printDownloadEvent(event remote.DownloadEvent) {
+	switch {
+	case event.RemotePath != "" && event.Retry > 0:
+		fmt.Printf("  ↻ %s: %s: %v, retrying in %s\n", event.Host, event.RemotePath, event.Err, event.Retry.Round(time.Second))
+	case event.RemotePath != "" && event.Err != nil:
+		fmt.Printf("  ❌ %s: %s: %v\n", event.Host, event.RemotePath, event.Err)
+	case event.RemotePath != "":
+		fmt.Printf("  ✅ %s: %s -> %s (%s)\n", event.Host, event.RemotePath, filepath.Base(event.LocalPath), formatBytes(event.Bytes))
+	case event.State == remote.StateConnecting:
+		fmt.Printf("🔌 %s: connecting\n", event.Host)
+	case event.State == remote.StateDownloading:
+		fmt.Printf("📦 %s: downloading\n", event.Host)
+	case event.State == remote.StateFailed:
+		fmt.Printf("❌ %s: %v\n", event.Host, event.Err)
+	case event.State == remote.StateDone:
+		fmt.Printf("✅ %s: done\n", event.Host)
+	}
+}
@@ -0,0 +1,674 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"smart-log-analyser/pkg/analyser"
+	"smart-log-analyser/pkg/config"
+	"smart-log-analyser/pkg/parser"
+	"smart-log-analyser/pkg/query"
+	"smart-log-analyser/pkg/security"
+)
+
+var (
+	queryConfigDir   string
+	queryDescription string
+	queryDefaults    []string
+	queryParams      []string
+	queryForce       bool
+	savedQueryFormat string
+	savedLookupSpecs []string
+	savedQueryOutput string
+	savedQueryChart  bool
+	savedErrorLogs   []string
+
+	diffBeforeFiles []string
+	diffAfterFiles  []string
+	diffBeforeSince string
+	diffBeforeUntil string
+	diffAfterSince  string
+	diffAfterUntil  string
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Manage and run saved SLAQ queries",
+	Long: `Save SLAQ statements under a name and rerun them against any log files.
+
+Saved queries may contain :name placeholders. Values are supplied with
+--param when the query runs and are always bound as quoted literals, so they
+cannot change the structure of the query. Defaults can be stored with the
+query using --default.
+
+Examples:
+  # Save an investigation with an :ip placeholder
+  ./smart-log-analyser query save ip-activity "SELECT url, COUNT() FROM logs WHERE ip = :ip GROUP BY url ORDER BY COUNT() DESC" --description "Requests made by one client"
+
+  # Save a query with a default value
+  ./smart-log-analyser query save slow-errors "SELECT url, status FROM logs WHERE status >= :min_status" --default min_status=500
+
+  # Run it against log files
+  ./smart-log-analyser query run ip-activity access.log --param ip=192.168.1.100
+
+  # Compare a query before and after a deployment
+  ./smart-log-analyser query diff "SELECT url, COUNT() FROM logs WHERE status >= 500 GROUP BY url" --before old.log --after new.log
+  ./smart-log-analyser query diff slow-errors access.log --before-since "2024-08-20 00:00:00" --before-until "2024-08-20 11:59:59" --after-since "2024-08-20 12:00:00"
+
+  # List and delete saved queries
+  ./smart-log-analyser query list
+  ./smart-log-analyser query delete ip-activity`,
+}
+
+var querySaveCmd = &cobra.Command{
+	Use:   "save <name> <query>",
+	Short: "Save a SLAQ query under a name",
+	Args:  cobra.ExactArgs(2),
+	Run:   runQuerySave,
+}
+
+var queryRunCmd = &cobra.Command{
+	Use:   "run <name> <log-files...>",
+	Short: "Run a saved query against log files",
+	Args:  cobra.MinimumNArgs(2),
+	Run:   runQueryRun,
+}
+
+var queryDiffCmd = &cobra.Command{
+	Use:   "diff <query-or-name> [log-files...]",
+	Short: "Compare a grouped query between two file sets or time ranges",
+	Long: `Run the same GROUP BY query against a "before" and an "after" set of logs
+and show each group's values on both sides with the change and percentage
+change, largest change first.
+
+The query is a SLAQ statement or the name of a saved query. Each side reads
+the files given with --before or --after, or the log files given as
+arguments, and can be narrowed to a time range with --before-since,
+--before-until, --after-since and --after-until. LIMIT and OFFSET apply to
+the diff.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runQueryDiff,
+}
+
+var queryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved queries",
+	Args:  cobra.NoArgs,
+	Run:   runQueryList,
+}
+
+var queryDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a saved query",
+	Args:  cobra.ExactArgs(1),
+	Run:   runQueryDelete,
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.AddCommand(querySaveCmd, queryRunCmd, queryDiffCmd, queryListCmd, queryDeleteCmd)
+
+	queryCmd.PersistentFlags().StringVar(&queryConfigDir, "config-dir", "config", "Configuration directory path")
+
+	querySaveCmd.Flags().StringVar(&queryDescription, "description", "", "Description shown by query list")
+	querySaveCmd.Flags().StringArrayVar(&queryDefaults, "default", nil, "Default value for a placeholder as name=value (repeatable)")
+	querySaveCmd.Flags().BoolVar(&queryForce, "force", false, "Replace an existing saved query with the same name")
+
+	queryRunCmd.Flags().StringArrayVar(&queryParams, "param", nil, "Value for a placeholder as name=value (repeatable)")
+	queryRunCmd.Flags().StringVar(&savedQueryFormat, "query-format", "table", "Output format for query results (table, csv, json, html)")
+	queryRunCmd.Flags().StringArrayVar(&savedLookupSpecs, "lookup", nil, "Lookup table for query JOINs as name=file.csv or name=file.json (repeatable)")
+	queryRunCmd.Flags().StringVar(&savedQueryOutput, "query-output", "", "Write query results to a .csv, .json or .html file instead of stdout")
+	queryRunCmd.Flags().BoolVar(&savedQueryChart, "query-chart", false, "Render aggregate query results as an ASCII bar chart")
+	queryRunCmd.Flags().StringVar(&chartDataDir, "chart-data", "", "Directory to write the data series of the query chart to as JSON and CSV files")
+	queryRunCmd.Flags().StringArrayVar(&savedErrorLogs, "error-log", nil, "nginx error log to query as the errors table (repeatable)")
+
+	queryDiffCmd.Flags().StringArrayVar(&diffBeforeFiles, "before", nil, "Log file for the before side (repeatable, defaults to the log file arguments)")
+	queryDiffCmd.Flags().StringArrayVar(&diffAfterFiles, "after", nil, "Log file for the after side (repeatable, defaults to the log file arguments)")
+	queryDiffCmd.Flags().StringVar(&diffBeforeSince, "before-since", "", "Start of the before time range (YYYY-MM-DD HH:MM:SS)")
+	queryDiffCmd.Flags().StringVar(&diffBeforeUntil, "before-until", "", "End of the before time range (YYYY-MM-DD HH:MM:SS)")
+	queryDiffCmd.Flags().StringVar(&diffAfterSince, "after-since", "", "Start of the after time range (YYYY-MM-DD HH:MM:SS)")
+	queryDiffCmd.Flags().StringVar(&diffAfterUntil, "after-until", "", "End of the after time range (YYYY-MM-DD HH:MM:SS)")
+	queryDiffCmd.Flags().StringArrayVar(&queryParams, "param", nil, "Value for a placeholder as name=value (repeatable)")
+	queryDiffCmd.Flags().StringVar(&savedQueryFormat, "query-format", "table", "Output format for the diff (table, csv, json, html)")
+	queryDiffCmd.Flags().StringArrayVar(&savedLookupSpecs, "lookup", nil, "Lookup table for query JOINs as name=file.csv or name=file.json (repeatable)")
+	queryDiffCmd.Flags().StringVar(&savedQueryOutput, "query-output", "", "Write the diff to a .csv, .json or .html file instead of stdout")
+}
+
+// loadQueryConfig loads the configuration holding the saved queries
+func loadQueryConfig() *config.ConfigManager {
+	configManager := config.NewConfigManager(queryConfigDir)
+	if err := configManager.Load(); err != nil {
+		fmt.Printf("❌ Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+	return configManager
+}
+
+// parseParameterSpecs parses repeated name=value flags
+func parseParameterSpecs(specs []string) (map[string]string, error) {
+	values := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, value, err := query.ParseParameterSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// bindQueryParameters replaces the :name placeholders of a query with the
+// values given as name=value specs, falling back to defaults
+func bindQueryParameters(queryStr string, specs []string, defaults map[string]string) (string, error) {
+	overrides, err := parseParameterSpecs(specs)
+	if err != nil {
+		return "", err
+	}
+
+	placeholders := query.QueryParameters(queryStr)
+	for name := range overrides {
+		if !containsString(placeholders, name) {
+			return "", fmt.Errorf("query has no placeholder :%s", name)
+		}
+	}
+
+	values := make(map[string]string, len(defaults)+len(overrides))
+	for name, value := range defaults {
+		values[name] = value
+	}
+	for name, value := range overrides {
+		values[name] = value
+	}
+	return query.BindParameters(queryStr, values)
+}
+
+func runQuerySave(cmd *cobra.Command, args []string) {
+	name, statement := args[0], strings.TrimSpace(args[1])
+
+	defaults, err := parseParameterSpecs(queryDefaults)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	placeholders := query.QueryParameters(statement)
+	for param := range defaults {
+		if !containsString(placeholders, param) {
+			fmt.Printf("❌ Query has no placeholder :%s\n", param)
+			os.Exit(1)
+		}
+	}
+
+	if err := query.ValidateParameterizedQuery(statement, defaults); err != nil {
+		fmt.Printf("❌ Invalid query: %v\n", err)
+		os.Exit(1)
+	}
+
+	saved := config.SavedQuery{
+		Name:        name,
+		Description: queryDescription,
+		Query:       statement,
+	}
+	for _, param := range placeholders {
+		saved.Parameters = append(saved.Parameters, config.QueryParameter{Name: param, Default: defaults[param]})
+	}
+
+	configManager := loadQueryConfig()
+	if _, err := configManager.GetSavedQuery(name); err == nil {
+		if !queryForce {
+			fmt.Printf("❌ Saved query '%s' already exists (use --force to replace it)\n", name)
+			os.Exit(1)
+		}
+		err = configManager.UpdateSavedQuery(name, saved)
+	} else {
+		err = configManager.AddSavedQuery(saved)
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to save query: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✅ Saved query '%s'\n", name)
+	if len(placeholders) > 0 {
+		fmt.Printf("🔧 Parameters: :%s\n", strings.Join(placeholders, ", :"))
+	}
+}
+
+func runQueryRun(cmd *cobra.Command, args []string) {
+	name, logFiles := args[0], args[1:]
+
+	saved, err := loadQueryConfig().GetSavedQuery(name)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+
+	statement, err := bindQueryParameters(saved.Query, queryParams, saved.ParameterValues(nil))
+	if err != nil {
+		fmt.Printf("❌ %v (use --param name=value)\n", err)
+		os.Exit(1)
+	}
+
+	p := parser.New()
+	var allLogs []*parser.LogEntry
+	for _, logFile := range logFiles {
+		logs, err := p.ParseFile(logFile)
+		if err != nil {
+			fmt.Printf("❌ Failed to parse %s: %v\n", logFile, err)
+			continue
+		}
+		allLogs = append(allLogs, logs...)
+	}
+	if len(allLogs) == 0 {
+		fmt.Println("❌ No valid log entries found in any files")
+		os.Exit(1)
+	}
+
+	engine := query.NewQueryEngine(allLogs)
+	registerQueryTables(engine, allLogs, savedErrorLogs)
+	if err := addLookupTables(engine, savedLookupSpecs); err != nil {
+		fmt.Printf("❌ Error loading lookup: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("🔍 Executing saved query '%s': %s\n", saved.Name, statement)
+	result, err := engine.ExecuteQuery(statement)
+	if err != nil {
+		fmt.Printf("❌ Query error: %v\n", err)
+		helper := query.NewQueryHelper()
+		fmt.Printf("💡 %s\n", helper.SuggestCorrection(err))
+		os.Exit(1)
+	}
+
+	if err := outputQueryResult(result, statement, savedQueryFormat, savedQueryOutput, savedQueryChart); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// addLookupTables loads the lookup tables given as name=file specs into an engine
+func addLookupTables(engine *query.QueryEngine, specs []string) error {
+	for _, spec := range specs {
+		name, filename, err := query.ParseLookupSpec(spec)
+		if err != nil {
+			return err
+		}
+		table, err := query.LoadLookupTable(name, filename)
+		if err != nil {
+			return err
+		}
+		engine.AddLookupTable(table)
+	}
+	return nil
+}
+
+func runQueryDiff(cmd *cobra.Command, args []string) {
+	target, logFiles := strings.TrimSpace(args[0]), args[1:]
+
+	// A statement is run as given, anything else names a saved query
+	statement := target
+	var defaults map[string]string
+	if !strings.HasPrefix(strings.ToUpper(target), "SELECT") {
+		saved, err := loadQueryConfig().GetSavedQuery(target)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		statement, defaults = saved.Query, saved.ParameterValues(nil)
+	}
+	statement, err := bindQueryParameters(statement, queryParams, defaults)
+	if err != nil {
+		fmt.Printf("❌ %v (use --param name=value)\n", err)
+		os.Exit(1)
+	}
+
+	beforeFiles, afterFiles := diffBeforeFiles, diffAfterFiles
+	if len(beforeFiles) == 0 {
+		beforeFiles = logFiles
+	}
+	if len(afterFiles) == 0 {
+		afterFiles = logFiles
+	}
+	if len(beforeFiles) == 0 || len(afterFiles) == 0 {
+		fmt.Println("❌ Give log files as arguments or with --before and --after")
+		os.Exit(1)
+	}
+	sameWindow := diffBeforeSince == diffAfterSince && diffBeforeUntil == diffAfterUntil
+	if sameWindow && strings.Join(beforeFiles, "\x00") == strings.Join(afterFiles, "\x00") {
+		fmt.Println("❌ Before and after are identical: use different --before/--after files or time ranges")
+		os.Exit(1)
+	}
+
+	beforeSince, beforeUntil, err := parseDiffWindow(diffBeforeSince, diffBeforeUntil)
+	if err != nil {
+		fmt.Printf("❌ --before-since/--before-until: %v\n", err)
+		os.Exit(1)
+	}
+	afterSince, afterUntil, err := parseDiffWindow(diffAfterSince, diffAfterUntil)
+	if err != nil {
+		fmt.Printf("❌ --after-since/--after-until: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Files shared by both sides are only parsed once
+	parsed := make(map[string][]*parser.LogEntry)
+	beforeLogs := filterByWindow(loadDiffLogs(beforeFiles, parsed), beforeSince, beforeUntil)
+	afterLogs := filterByWindow(loadDiffLogs(afterFiles, parsed), afterSince, afterUntil)
+
+	fmt.Printf("🔍 Comparing query: %s\n", statement)
+	fmt.Printf("   Before: %d entries from %s%s\n", len(beforeLogs), strings.Join(beforeFiles, ", "), describeWindow(beforeSince, beforeUntil))
+	fmt.Printf("   After:  %d entries from %s%s\n", len(afterLogs), strings.Join(afterFiles, ", "), describeWindow(afterSince, afterUntil))
+
+	var engines []*query.QueryEngine
+	for _, logs := range [][]*parser.LogEntry{beforeLogs, afterLogs} {
+		engine := query.NewQueryEngine(logs)
+		registerQueryTables(engine, logs, nil)
+		if err := addLookupTables(engine, savedLookupSpecs); err != nil {
+			fmt.Printf("❌ Error loading lookup: %v\n", err)
+			os.Exit(1)
+		}
+		engines = append(engines, engine)
+	}
+
+	result, err := query.DiffQuery(statement, engines[0], engines[1])
+	if err != nil {
+		fmt.Printf("❌ Query error: %v\n", err)
+		helper := query.NewQueryHelper()
+		fmt.Printf("💡 %s\n", helper.SuggestCorrection(err))
+		os.Exit(1)
+	}
+
+	if err := outputQueryResult(result, statement, savedQueryFormat, savedQueryOutput, false); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseDiffWindow parses the optional since and until times of one side of a diff
+func parseDiffWindow(since, until string) (*time.Time, *time.Time, error) {
+	var times [2]*time.Time
+	for i, value := range []string{since, until} {
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid time %q (use YYYY-MM-DD HH:MM:SS)", value)
+		}
+		times[i] = &t
+	}
+	return times[0], times[1], nil
+}
+
+// loadDiffLogs parses log files, reusing files already in parsed
+func loadDiffLogs(files []string, parsed map[string][]*parser.LogEntry) []*parser.LogEntry {
+	p := parser.New()
+	var logs []*parser.LogEntry
+	for _, file := range files {
+		entries, exists := parsed[file]
+		if !exists {
+			var err error
+			entries, err = p.ParseFile(file)
+			if err != nil {
+				fmt.Printf("❌ Failed to parse %s: %v\n", file, err)
+			}
+			parsed[file] = entries
+		}
+		logs = append(logs, entries...)
+	}
+	return logs
+}
+
+// filterByWindow keeps the entries between since and until, either of which may be nil
+func filterByWindow(logs []*parser.LogEntry, since, until *time.Time) []*parser.LogEntry {
+	if since == nil && until == nil {
+		return logs
+	}
+	var filtered []*parser.LogEntry
+	for _, entry := range logs {
+		if (since == nil || !entry.Timestamp.Before(*since)) && (until == nil || !entry.Timestamp.After(*until)) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// describeWindow formats the time range of one side of a diff
+func describeWindow(since, until *time.Time) string {
+	const layout = "2006-01-02 15:04:05"
+	switch {
+	case since != nil && until != nil:
+		return fmt.Sprintf(" (%s to %s)", since.Format(layout), until.Format(layout))
+	case since != nil:
+		return fmt.Sprintf(" (from %s)", since.Format(layout))
+	case until != nil:
+		return fmt.Sprintf(" (until %s)", until.Format(layout))
+	}
+	return ""
+}
+
+// outputQueryResult prints a query result, or writes it to outputFile, and
+// optionally renders it as a bar chart
+func outputQueryResult(result *query.QueryResult, statement, format, outputFile string, chart bool) error {
+	if outputFile != "" {
+		if err := query.WriteResultFile(result, statement, outputFile); err != nil {
+			return fmt.Errorf("failed to write query results: %w", err)
+		}
+		fmt.Printf("📄 Exported %d query result rows to: %s\n", result.Count, outputFile)
+	} else {
+		formatted, err := query.FormatResult(result, format)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📊 Query Results:\n")
+		fmt.Printf("%s", formatted)
+	}
+
+	if chart {
+		labels, values, valueColumn, err := query.ChartSeries(result)
+		if err != nil {
+			return fmt.Errorf("cannot chart query results: %w", err)
+		}
+
+		// Shares of a total only make sense for counts and sums
+		upper := strings.ToUpper(valueColumn)
+		showPercent := strings.HasPrefix(upper, "COUNT(") || strings.HasPrefix(upper, "SUM(")
+
+		generator := newChartGenerator()
+		fmt.Println()
+		fmt.Print(generator.GenerateSeriesChart(valueColumn, labels, values, showPercent))
+		writeChartData(generator)
+	}
+
+	return nil
+}
+
+func runQueryList(cmd *cobra.Command, args []string) {
+	queries := loadQueryConfig().ListSavedQueries()
+	if len(queries) == 0 {
+		fmt.Println("No saved queries. Save one with './smart-log-analyser query save <name> <query>'.")
+		return
+	}
+
+	fmt.Printf("🔖 Saved Queries (%d)\n", len(queries))
+	fmt.Println("==================")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Name\tParameters\tDescription\tQuery")
+	fmt.Fprintln(w, "----\t----------\t-----------\t-----")
+	for _, saved := range queries {
+		var params []string
+		for _, param := range saved.Parameters {
+			if param.Default != "" {
+				params = append(params, fmt.Sprintf(":%s=%s", param.Name, param.Default))
+			} else {
+				params = append(params, ":"+param.Name)
+			}
+		}
+
+		statement := saved.Query
+		if len(statement) > 50 {
+			statement = statement[:47] + "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", saved.Name, strings.Join(params, " "), saved.Description, statement)
+	}
+	w.Flush()
+}
+
+func runQueryDelete(cmd *cobra.Command, args []string) {
+	if err := loadQueryConfig().DeleteSavedQuery(args[0]); err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("🗑️  Deleted saved query '%s'\n", args[0])
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// registerQueryTables adds the errors, sessions and threats tables to a query
+// engine. Tables are only built when a query reads them. logs is nil when
+// entries are streamed, so the tables derived from them are unavailable.
+func registerQueryTables(engine *query.QueryEngine, logs []*parser.LogEntry, errorLogs []string) {
+	engine.AddTable(query.NewTable("errors", "nginx error log lines loaded with --error-log", []query.TableColumn{
+		{Name: "level", Type: query.ValueString, Description: "Severity such as error, warn or crit"},
+		{Name: "message", Type: query.ValueString, Description: "Error message without the request context"},
+		{Name: "pid", Type: query.ValueInt, Description: "Worker process ID"},
+		{Name: "tid", Type: query.ValueInt, Description: "Worker thread ID"},
+		{Name: "connection", Type: query.ValueInt, Description: "Connection number, 0 when absent"},
+		{Name: "server", Type: query.ValueString, Description: "Server name handling the request"},
+		{Name: "host", Type: query.ValueString, Description: "Host header of the request"},
+		{Name: "upstream", Type: query.ValueString, Description: "Upstream address"},
+	}, func() ([]*parser.LogEntry, error) {
+		if len(errorLogs) == 0 {
+			return nil, fmt.Errorf("load nginx error logs with --error-log <file>")
+		}
+
+		p := parser.New()
+		var rows []*parser.LogEntry
+		for _, errorLog := range errorLogs {
+			err := p.StreamErrorFile(errorLog, func(entry *parser.ErrorLogEntry) {
+				rows = append(rows, query.NewTableRow(parser.LogEntry{
+					IP:        entry.Client,
+					Timestamp: entry.Timestamp,
+					Method:    entry.Method,
+					URL:       entry.URL,
+					Protocol:  entry.Protocol,
+					Referer:   entry.Referrer,
+				}, map[string]interface{}{
+					"level":      entry.Level,
+					"message":    entry.Message,
+					"pid":        entry.PID,
+					"tid":        entry.TID,
+					"connection": entry.Connection,
+					"server":     entry.Server,
+					"host":       entry.Host,
+					"upstream":   entry.Upstream,
+				}))
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		return rows, nil
+	}))
+
+	engine.AddTable(query.NewTable("sessions", "Visitor sessions reconstructed from the logs", []query.TableColumn{
+		{Name: "session_id", Type: query.ValueString, Description: "Session number in order of start time"},
+		{Name: "end", Type: query.ValueTime, Description: "Time of the last request (timestamp is the first)"},
+		{Name: "duration", Type: query.ValueFloat, Description: "Seconds between the first and last request"},
+		{Name: "requests", Type: query.ValueInt, Description: "Requests in the session"},
+		{Name: "pages", Type: query.ValueInt, Description: "Requests for dynamic pages rather than static assets"},
+		{Name: "errors", Type: query.ValueInt, Description: "4xx and 5xx responses"},
+		{Name: "exit_url", Type: query.ValueString, Description: "URL of the last request (url is the first)"},
+	}, func() ([]*parser.LogEntry, error) {
+		if logs == nil {
+			return nil, fmt.Errorf("sessions are reconstructed from every log entry in memory and cannot be used with --stream")
+		}
+
+		var rows []*parser.LogEntry
+		for _, session := range analyser.ReconstructSessions(logs, analyser.DefaultSessionTimeout) {
+			rows = append(rows, query.NewTableRow(parser.LogEntry{
+				IP:        session.IP,
+				Timestamp: session.Start,
+				URL:       session.EntryURL,
+				Size:      session.Bytes,
+				UserAgent: session.UserAgent,
+			}, map[string]interface{}{
+				"session_id": session.ID,
+				"end":        session.End,
+				"duration":   session.Duration().Seconds(),
+				"requests":   session.Requests,
+				"pages":      session.Pages,
+				"errors":     session.Errors,
+				"exit_url":   session.ExitURL,
+			}))
+		}
+		return rows, nil
+	}))
+
+	engine.AddTable(query.NewTable("threats", "Security findings detected in the logs", []query.TableColumn{
+		{Name: "threat_id", Type: query.ValueString, Description: "Finding identifier"},
+		{Name: "type", Type: query.ValueString, Description: "Attack type, e.g. SQL Injection"},
+		{Name: "category", Type: query.ValueString, Description: "web or infrastructure"},
+		{Name: "severity", Type: query.ValueString, Description: "Info, Low, Medium, High or Critical"},
+		{Name: "severity_level", Type: query.ValueInt, Description: "Severity as a number from 0 (Info) to 4 (Critical)"},
+		{Name: "confidence", Type: query.ValueFloat, Description: "Detection confidence from 0 to 1"},
+		{Name: "attack_vector", Type: query.ValueString, Description: "Where the attack was seen"},
+		{Name: "payload", Type: query.ValueString, Description: "Matched payload"},
+		{Name: "pattern", Type: query.ValueString, Description: "Detection pattern"},
+	}, func() ([]*parser.LogEntry, error) {
+		if logs == nil {
+			return nil, fmt.Errorf("threats are detected from every log entry in memory and cannot be used with --stream")
+		}
+
+		detector := security.NewThreatDetector(securityConfig())
+		webThreats, err := detector.DetectWebAttacks(logs)
+		if err != nil {
+			return nil, err
+		}
+		infraThreats, err := detector.DetectInfrastructureAttacks(logs)
+		if err != nil {
+			return nil, err
+		}
+
+		var rows []*parser.LogEntry
+		for i, threat := range append(webThreats, infraThreats...) {
+			category := "infrastructure"
+			if i < len(webThreats) {
+				category = "web"
+			}
+			rows = append(rows, query.NewTableRow(parser.LogEntry{
+				IP:        threat.IP,
+				Timestamp: threat.Timestamp,
+				Method:    threat.Method,
+				URL:       threat.URL,
+				Status:    threat.StatusCode,
+				Size:      threat.ResponseSize,
+				UserAgent: threat.UserAgent,
+			}, map[string]interface{}{
+				"threat_id":      threat.ID,
+				"type":           fmt.Sprint(threat.Type),
+				"category":       category,
+				"severity":       threat.Severity.String(),
+				"severity_level": int(threat.Severity),
+				"confidence":     threat.Confidence,
+				"attack_vector":  threat.AttackVector,
+				"payload":        threat.Payload,
+				"pattern":        threat.Pattern,
+			}))
+		}
+		return rows, nil
+	}))
+}
@@ -0,0 +1,620 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"smart-log-analyser/pkg/charts"
+	"smart-log-analyser/pkg/parser"
+	"smart-log-analyser/pkg/security"
+)
+
+// securityScanFormats are the output formats of security scan
+var securityScanFormats = []string{"dashboard", "detailed", "timeline", "json"}
+
+// reputationFormats are the output formats of security reputation
+var reputationFormats = []string{"text", "json"}
+
+var (
+	scanSince       string
+	scanUntil       string
+	scanMinSeverity string
+	scanFailOn      string
+	scanFormat      string
+	scanOutput      string
+	scanIOCFile     string
+	scanTimeline    string
+	scanAggregate   bool
+	scanBaseline    string
+	scanSaveBase    string
+
+	reputationFormat string
+
+	watchInterval    time.Duration
+	watchWindow      time.Duration
+	watchNewOnly     bool
+	watchWebhooks    []string
+	watchMinSeverity string
+	watchMinRisk     string
+	watchCooldown    time.Duration
+)
+
+var securityCmd = &cobra.Command{
+	Use:   "security",
+	Short: "Security analysis of log files",
+	Long: `Run the security engine on log files: web and infrastructure attack
+detection, behavioral anomalies, incidents and the security score.
+
+Examples:
+  # Show the security dashboard of a log
+  ./smart-log-analyser security scan access.log
+
+  # Every threat of High severity or above, in detail
+  ./smart-log-analyser security scan access.log --min-severity high --format detailed
+
+  # JSON report and IOC list for a pipeline
+  ./smart-log-analyser security scan /var/log/nginx/access.log* --format json --output scan.json --export-iocs iocs.txt
+
+  # Remember this week's attackers and look one up later
+  ./smart-log-analyser security scan access.log --record-reputation
+  ./smart-log-analyser security reputation 203.0.113.7
+
+  # Follow a live log and POST alerts to a webhook
+  ./smart-log-analyser security watch /var/log/nginx/access.log --webhook https://hooks.example.com/alerts
+
+  # Weekly review: what changed since last week's scan
+  ./smart-log-analyser security scan access.log --baseline weekly.json --save-baseline weekly.json`,
+}
+
+var securityScanCmd = &cobra.Command{
+	Use:   "scan <log-files...>",
+	Short: "Scan log files for threats and report them",
+	Long: `Scan log files for threats, anomalies and incidents and report them as the
+security dashboard, a detailed threat report, a Gantt-style timeline of each
+incident or JSON.
+
+Findings below --min-severity are left out of the report, the IOC export and
+the exit code. The security score and risk level always cover every finding.
+The exit code follows the threat level, the highest severity among the
+reported threats, so cron jobs and CI pipelines can act on it:
+
+  0  no threats
+  1  the scan failed
+  2  Info
+  3  Low
+  4  Medium
+  5  High
+  6  Critical
+
+With --fail-on-severity the scan exits 0 unless a reported threat is at or
+above that severity, so only serious findings fail a pipeline.
+
+With --baseline the report also shows what changed since a stored scan: the
+score delta, new attacker IPs and attack types, and new and resolved incidents.
+--save-baseline stores this scan for the next comparison. Both cover every
+finding, whatever --min-severity.
+
+Progress goes to stderr, so JSON written to stdout can be piped.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runSecurityScan,
+}
+
+var securityReputationCmd = &cobra.Command{
+	Use:   "reputation <ip...>",
+	Short: "Show the stored reputation of IPs",
+	Long: `Show what the IP reputation store knows about IPs: their reputation score
+and risk level, the days they were seen, their threats by attack type and the
+tags of their behavior.
+
+Runs with --record-reputation fill the store. A day's threats count half after
+14 days and are dropped after 90, so the score reflects how recently an IP
+attacked. IPs the store has no record of are reported and skipped.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runSecurityReputation,
+}
+
+var securityWatchCmd = &cobra.Command{
+	Use:   "watch [log-files...]",
+	Short: "Follow log files and alert on threats as they happen",
+	Long: `Follow access logs like tail -f and run the security analysis over the most
+recent --window of entries whenever lines are appended. Alerts are printed and,
+with --webhook, POSTed as JSON to each webhook URL.
+
+An alert is raised per IP and attack type once its threats reach
+--alert-severity, and when the overall risk level rises to --alert-risk. The
+same alert is raised again only when there is new activity after --cooldown,
+or sooner when it escalates to a higher severity or risk level. Webhooks are
+sent in the background, so a slow endpoint does not delay the watch. Failed
+alerts are reported and sent again until they are delivered.
+
+With --remote, the log_path of a server in the SSH configuration is followed
+with tail -F over SSH.
+
+Press Ctrl+C to quit.`,
+	Args: requireLogsOrRemote,
+	Run:  runSecurityWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(securityCmd)
+	securityCmd.AddCommand(securityScanCmd)
+	securityCmd.AddCommand(securityReputationCmd)
+	securityCmd.AddCommand(securityWatchCmd)
+
+	securityCmd.PersistentFlags().StringVar(&analyseConfigDir, "config-dir", "config", "Configuration directory path")
+	securityCmd.PersistentFlags().StringVar(&securityRulesFile, "security-rules", "", "Custom threat detection rules file (default: <config-dir>/security-rules.yaml, if present)")
+	securityCmd.PersistentFlags().StringVar(&cveSignaturesFile, "cve-signatures", "", "Known exploit signature file (default: <config-dir>/"+security.CVESignaturesFilename+", built-in set if missing)")
+	securityCmd.PersistentFlags().StringVar(&securityHistoryFile, "security-history", "", "Security history file for low-and-slow brute force detection (default: "+security.DefaultBehaviorStoreFile+" in the config directory)")
+	securityCmd.PersistentFlags().StringVar(&ipReputationFile, "ip-reputation", "", "IP reputation store file (default: "+security.DefaultReputationStoreFile+" in the config directory)")
+	securityCmd.PersistentFlags().StringVar(&geoIPDatabase, "geoip-db", "", "GeoIP country CSV database, for impossible travel detection")
+
+	securityScanCmd.Flags().StringVar(&scanSince, "since", "", "Start time (YYYY-MM-DD HH:MM:SS)")
+	securityScanCmd.Flags().StringVar(&scanUntil, "until", "", "End time (YYYY-MM-DD HH:MM:SS)")
+	securityScanCmd.Flags().StringVar(&scanMinSeverity, "min-severity", "low", "Lowest severity to report: "+strings.Join(security.Severities, ", "))
+	securityScanCmd.Flags().StringVar(&scanFailOn, "fail-on-severity", "", "Exit non-zero only when reported threats at or above this severity exist: "+strings.Join(security.Severities, ", ")+" (default: any reported threat)")
+	securityScanCmd.Flags().StringVar(&scanFormat, "format", "dashboard", "Output format: "+strings.Join(securityScanFormats, ", "))
+	securityScanCmd.Flags().StringVar(&scanOutput, "output", "", "Write the report to a file instead of stdout")
+	securityScanCmd.Flags().BoolVar(&scanAggregate, "aggregate", false, "Report one JSON threat per group of near-identical threats (same IP, attack type and pattern), with its count and first and last sighting")
+	securityScanCmd.Flags().StringVar(&scanTimeline, "export-timeline", "", "Export the timelines of the reported incidents, with their attack phases and events, as JSON to file")
+	securityScanCmd.Flags().StringVar(&scanIOCFile, "export-iocs", "", "Export the IPs, user agents and payloads of the reported threats to a .json, .csv or .txt (one value per line) file")
+	securityScanCmd.Flags().StringVar(&scanBaseline, "baseline", "", "Compare this scan with a stored JSON security report, such as one written by --save-baseline")
+	securityScanCmd.Flags().StringVar(&scanSaveBase, "save-baseline", "", "Store this scan as a JSON security report, to compare later scans with --baseline")
+	securityScanCmd.Flags().BoolVar(&recordReputation, "record-reputation", false, "Record this scan's threats and IP behavior in the IP reputation store, so later scans start known attackers with elevated risk")
+	securityScanCmd.Flags().BoolVar(&noColors, "no-colors", false, "Disable colors in the dashboard")
+
+	securityReputationCmd.Flags().StringVar(&reputationFormat, "format", "text", "Output format: "+strings.Join(reputationFormats, ", "))
+
+	securityWatchCmd.Flags().DurationVar(&watchInterval, "interval", 10*time.Second, "How often to read new lines")
+	securityWatchCmd.Flags().DurationVar(&watchWindow, "window", 15*time.Minute, "Span of the most recent entries analysed, by their log timestamps")
+	securityWatchCmd.Flags().BoolVar(&watchNewOnly, "new-only", false, "Only analyse lines written after the watch starts")
+	securityWatchCmd.Flags().StringArrayVar(&watchWebhooks, "webhook", nil, "POST alerts as JSON to this http(s) URL (repeatable)")
+	securityWatchCmd.Flags().StringVar(&watchMinSeverity, "alert-severity", "high", "Lowest threat severity to alert on: "+strings.Join(security.Severities, ", "))
+	securityWatchCmd.Flags().StringVar(&watchMinRisk, "alert-risk", "high", "Alert when the overall risk level rises to this level: "+strings.Join(security.RiskLevels, ", ")+", or off")
+	securityWatchCmd.Flags().DurationVar(&watchCooldown, "cooldown", security.DefaultWebhookCooldown, "Time before the same alert is raised again, unless it escalates")
+	securityWatchCmd.Flags().StringArrayVar(&followRemotes, "remote", nil, "Follow the log_path of this server of --config over SSH (repeatable)")
+	securityWatchCmd.Flags().StringVar(&configFile, "config", "servers.json", "Path to SSH configuration file of the --remote servers")
+	securityWatchCmd.Flags().StringVar(&knownHostsFile, "known-hosts", "", "known_hosts file to verify server host keys against (default ~/.ssh/known_hosts)")
+	securityWatchCmd.Flags().BoolVar(&acceptNewHostKeys, "accept-new-host-keys", false, "Trust and record the host keys of servers not in known_hosts without asking (changed keys are still rejected)")
+	securityWatchCmd.Flags().StringVar(&credentialsFile, "credentials-file", "credentials.enc", "Encrypted credentials file of servers with credential_store \"file\"")
+	securityWatchCmd.Flags().BoolVar(&kubernetesLogs, "kubernetes", false, "Follow the logs of the running Kubernetes pods of --selector")
+	securityWatchCmd.Flags().StringVar(&kubeconfigFile, "kubeconfig", "", "kubeconfig file (default $KUBECONFIG or ~/.kube/config, or the service account in a pod)")
+	securityWatchCmd.Flags().StringVar(&kubeContext, "context", "", "kubeconfig context (default the current context)")
+	securityWatchCmd.Flags().StringVar(&kubeNamespace, "namespace", "", "Namespace of the pods (default the namespace of the context)")
+	securityWatchCmd.Flags().StringVar(&kubeSelector, "selector", "", "Label selector of the pods, e.g. app.kubernetes.io/name=ingress-nginx")
+	securityWatchCmd.Flags().StringVar(&kubeContainer, "container", "", "Container of the pods whose logs to read (default all)")
+}
+
+func runSecurityScan(cmd *cobra.Command, args []string) {
+	minSeverity, err := security.ParseSeverity(scanMinSeverity)
+	if err != nil {
+		fmt.Printf("❌ Invalid --min-severity: %v\n", err)
+		os.Exit(1)
+	}
+	failSeverity := security.SeverityInfo
+	if scanFailOn != "" {
+		if failSeverity, err = security.ParseSeverity(scanFailOn); err != nil {
+			fmt.Printf("❌ Invalid --fail-on-severity: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if !containsString(securityScanFormats, scanFormat) {
+		fmt.Printf("❌ Invalid --format %q (use %s)\n", scanFormat, strings.Join(securityScanFormats, ", "))
+		os.Exit(1)
+	}
+	if scanIOCFile != "" {
+		if _, err := security.IOCFormatFromFilename(scanIOCFile); err != nil {
+			fmt.Printf("❌ Invalid --export-iocs: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var baseline *security.Report
+	if scanBaseline != "" {
+		baseline, err = security.LoadReport(scanBaseline)
+		if err != nil {
+			fmt.Printf("❌ Invalid --baseline: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	sinceTime, untilTime, err := parseDiffWindow(scanSince, scanUntil)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		os.Exit(1)
+	}
+	loadSecurityEngine()
+
+	p := parser.New()
+	var allLogs []*parser.LogEntry
+	for _, logFile := range args {
+		logs, err := p.ParseFile(logFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Failed to parse %s: %v\n", logFile, err)
+			continue
+		}
+		allLogs = append(allLogs, logs...)
+	}
+	allLogs = filterByWindow(allLogs, sinceTime, untilTime)
+	if len(allLogs) == 0 {
+		fmt.Println("❌ No valid log entries found in any files")
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "🔍 Scanning %d log entries from %d file(s)...\n", len(allLogs), len(args))
+	analysis, err := security.Analyse(allLogs, securityConfig())
+	if err != nil {
+		fmt.Printf("❌ Failed to analyse security: %v\n", err)
+		os.Exit(1)
+	}
+	reported := security.FilterBySeverity(analysis, minSeverity)
+
+	var diff *security.BaselineDiff
+	if baseline != nil {
+		changes := security.DiffReports(*baseline, security.NewReport(analysis, time.Now()))
+		diff = &changes
+	}
+
+	if recordReputation {
+		if err := saveIPReputation(allLogs, analysis, os.Stderr); err != nil {
+			fmt.Printf("❌ Failed to record IP reputation: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := writeSecurityScan(reported, diff); err != nil {
+		fmt.Printf("❌ Failed to write the report: %v\n", err)
+		os.Exit(1)
+	}
+	if scanOutput != "" {
+		fmt.Fprintf(os.Stderr, "📄 Wrote %s security report to: %s\n", scanFormat, scanOutput)
+	}
+	if diff != nil {
+		fmt.Fprintf(os.Stderr, "📊 Since baseline: score %+d, %d new attacker IP(s), %d new attack type(s), %d new and %d resolved incident(s)\n",
+			diff.ScoreDelta, len(diff.NewIPs), len(diff.NewAttackTypes), len(diff.NewIncidents), len(diff.ResolvedIncidents))
+	}
+	if scanSaveBase != "" {
+		if err := security.SaveReport(scanSaveBase, security.NewAggregatedReport(analysis, time.Now())); err != nil {
+			fmt.Printf("❌ Failed to save the baseline: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "💾 Saved baseline to: %s\n", scanSaveBase)
+	}
+
+	if scanIOCFile != "" {
+		iocs := security.ExtractIOCs(reported.Threats)
+		if err := security.WriteIOCsFile(scanIOCFile, iocs); err != nil {
+			fmt.Printf("❌ Failed to export IOCs: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "🧷 Exported %d IOC(s) to: %s\n", len(iocs), scanIOCFile)
+	}
+
+	if scanTimeline != "" {
+		if err := security.WriteTimelineFile(scanTimeline, reported.Incidents, time.Now()); err != nil {
+			fmt.Printf("❌ Failed to export incident timelines: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "🕒 Exported the timelines of %d incident(s) to: %s\n", len(reported.Incidents), scanTimeline)
+	}
+
+	level, found := security.ThreatLevel(reported.Threats)
+	if !found {
+		fmt.Fprintf(os.Stderr, "✅ No threats at or above %s severity\n", minSeverity)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "🚨 Threat level: %s (%d threat(s) at or above %s severity)\n", level, len(reported.Threats), minSeverity)
+	if level < failSeverity {
+		fmt.Fprintf(os.Stderr, "✅ No threats at or above %s severity, passing\n", failSeverity)
+		return
+	}
+	os.Exit(securityExitCode(level))
+}
+
+func runSecurityReputation(cmd *cobra.Command, args []string) {
+	if !containsString(reputationFormats, reputationFormat) {
+		fmt.Printf("❌ Invalid --format %q (use %s)\n", reputationFormat, strings.Join(reputationFormats, ", "))
+		os.Exit(1)
+	}
+	store, err := security.LoadReputationStore(ipReputationPath())
+	if err != nil {
+		fmt.Printf("❌ Invalid --ip-reputation: %v\n", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	reputations := []security.Reputation{}
+	for _, ip := range args {
+		reputation, found := store.Reputation(ip, now)
+		if !found {
+			fmt.Fprintf(os.Stderr, "❔ No reputation recorded for %s\n", ip)
+			continue
+		}
+		reputations = append(reputations, reputation)
+	}
+
+	if reputationFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reputations); err != nil {
+			fmt.Printf("❌ Failed to write the reputation: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	for i, reputation := range reputations {
+		if i > 0 {
+			fmt.Println()
+		}
+		printReputation(reputation)
+	}
+}
+
+func runSecurityWatch(cmd *cobra.Command, args []string) {
+	thresholds := security.AlertThresholds{Cooldown: watchCooldown, Window: watchWindow}
+	minSeverity, err := security.ParseSeverity(watchMinSeverity)
+	if err != nil {
+		fmt.Printf("❌ Invalid --alert-severity: %v\n", err)
+		os.Exit(1)
+	}
+	thresholds.MinSeverity = minSeverity
+	if !strings.EqualFold(watchMinRisk, "off") {
+		minRisk, err := security.ParseRiskLevel(watchMinRisk)
+		if err != nil {
+			fmt.Printf("❌ Invalid --alert-risk: %v\n", err)
+			os.Exit(1)
+		}
+		thresholds.RiskAlerts, thresholds.MinRisk = true, minRisk
+	}
+	if watchInterval < 100*time.Millisecond {
+		fmt.Printf("❌ --interval must be at least 100ms\n")
+		os.Exit(1)
+	}
+	if watchWindow <= 0 {
+		fmt.Printf("❌ --window must be positive\n")
+		os.Exit(1)
+	}
+	var webhooks []*security.WebhookQueue
+	for _, url := range watchWebhooks {
+		webhook, err := security.NewWebhook(url)
+		if err != nil {
+			fmt.Printf("❌ Invalid --webhook: %v\n", err)
+			os.Exit(1)
+		}
+		queue := security.NewWebhookQueue(webhook, func(err error, retryIn time.Duration) {
+			fmt.Fprintf(os.Stderr, "⚠️  Failed to send alert, retrying in %s: %v\n", retryIn, err)
+		})
+		defer queue.Close()
+		webhooks = append(webhooks, queue)
+	}
+	loadSecurityEngine()
+
+	followers, names, stopFollowing := startFollowers(args, watchNewOnly)
+	defer stopFollowing()
+
+	alerter := security.NewAlerter(thresholds)
+	logParser := parser.New()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	fmt.Printf("👀 Watching %d log(s) for %s+ threats", len(names), minSeverity)
+	if thresholds.RiskAlerts {
+		fmt.Printf(" and %s+ risk", thresholds.MinRisk)
+	}
+	fmt.Printf(", %d webhook(s). Press Ctrl+C to quit.\n", len(webhooks))
+
+	var window []*parser.LogEntry
+	// The last warning of each follower, printed again only once it changes
+	warnings := make([]string, len(followers))
+	for {
+		added := 0
+		for i, follower := range followers {
+			lines, err := follower.poll()
+			if err != nil {
+				if err.Error() != warnings[i] {
+					fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+					warnings[i] = err.Error()
+				}
+				continue
+			}
+			warnings[i] = ""
+			for _, line := range lines {
+				entry, err := logParser.ParseLine(line)
+				if err != nil {
+					continue
+				}
+				window = append(window, entry)
+				added++
+			}
+		}
+
+		if added > 0 {
+			window = recentEntries(window, watchWindow)
+			analysis, err := security.Analyse(window, securityConfig())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Failed to analyse security: %v\n", err)
+			} else {
+				for _, alert := range alerter.Check(analysis, time.Now()) {
+					fmt.Printf("%s 🚨 %s\n", alert.GeneratedAt.Format("15:04:05"), alert.Summary)
+					for i, webhook := range webhooks {
+						if !webhook.Enqueue(alert) {
+							fmt.Fprintf(os.Stderr, "⚠️  Dropped alert for %s: too many alerts waiting to be sent\n", watchWebhooks[i])
+						}
+					}
+				}
+			}
+		}
+
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recentEntries keeps the entries within span of the newest one
+func recentEntries(entries []*parser.LogEntry, span time.Duration) []*parser.LogEntry {
+	var newest time.Time
+	for _, entry := range entries {
+		if entry.Timestamp.After(newest) {
+			newest = entry.Timestamp
+		}
+	}
+	cutoff := newest.Add(-span)
+	recent := entries[:0]
+	for _, entry := range entries {
+		if !entry.Timestamp.Before(cutoff) {
+			recent = append(recent, entry)
+		}
+	}
+	return recent
+}
+
+// loadSecurityEngine loads the detection rules, signatures, scoring, stores
+// and GeoIP database the security commands run with, exiting on errors
+func loadSecurityEngine() {
+	if err := loadSecurityRules(); err != nil {
+		fmt.Printf("❌ Invalid --security-rules: %v\n", err)
+		os.Exit(1)
+	}
+	if err := loadCVESignatures(); err != nil {
+		fmt.Printf("❌ Invalid --cve-signatures: %v\n", err)
+		os.Exit(1)
+	}
+	if err := loadSecurityScoring(); err != nil {
+		fmt.Printf("❌ Invalid security scoring: %v\n", err)
+		os.Exit(1)
+	}
+	behavior, err := security.LoadBehaviorStore(securityHistoryPath())
+	if err != nil {
+		fmt.Printf("❌ Invalid --security-history: %v\n", err)
+		os.Exit(1)
+	}
+	securityBehavior = behavior
+	reputation, err := security.LoadReputationStore(ipReputationPath())
+	if err != nil {
+		fmt.Printf("❌ Invalid --ip-reputation: %v\n", err)
+		os.Exit(1)
+	}
+	ipReputation = reputation
+	if err := loadGeoIPDatabase(); err != nil {
+		fmt.Printf("❌ Failed to load GeoIP database: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printReputation shows the stored reputation of an IP
+func printReputation(reputation security.Reputation) {
+	fmt.Printf("🛡️  IP reputation: %s\n", reputation.IP)
+	fmt.Printf("├─ Score: %.0f/100 (%s)\n", reputation.Score, reputation.RiskLevel)
+	fmt.Printf("├─ Seen: %d day(s), %s to %s\n", reputation.ActiveDays,
+		reputation.FirstSeen.Format("2006-01-02"), reputation.LastSeen.Format("2006-01-02"))
+	if reputation.LastThreat != nil {
+		fmt.Printf("├─ Last threat: %s\n", reputation.LastThreat.Format("2006-01-02"))
+	}
+	fmt.Printf("├─ Requests: %s\n", formatNumber(int(reputation.Requests)))
+	fmt.Printf("├─ Threats: %s\n", formatNumber(reputation.Threats))
+	fmt.Printf("├─ Peak behavior score: %.2f\n", reputation.BehaviorScore)
+
+	attackTypes := make([]string, 0, len(reputation.AttackTypes))
+	for attackType := range reputation.AttackTypes {
+		attackTypes = append(attackTypes, attackType)
+	}
+	sort.Slice(attackTypes, func(i, j int) bool {
+		ci, cj := reputation.AttackTypes[attackTypes[i]], reputation.AttackTypes[attackTypes[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return attackTypes[i] < attackTypes[j]
+	})
+	for i, attackType := range attackTypes {
+		attackTypes[i] = fmt.Sprintf("%s (%d)", attackType, reputation.AttackTypes[attackType])
+	}
+	if len(attackTypes) > 0 {
+		fmt.Printf("├─ Attack types: %s\n", strings.Join(attackTypes, ", "))
+	}
+
+	tags := "none"
+	if len(reputation.Tags) > 0 {
+		tags = strings.Join(reputation.Tags, ", ")
+	}
+	fmt.Printf("└─ Tags: %s\n", tags)
+}
+
+// writeSecurityScan writes the report in the --format to --output or stdout,
+// with the changes since the baseline if there is one
+func writeSecurityScan(analysis *security.EnhancedSecurityAnalysis, diff *security.BaselineDiff) error {
+	var w io.Writer = os.Stdout
+	if scanOutput != "" {
+		if dir := filepath.Dir(scanOutput); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		file, err := os.Create(scanOutput)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if scanFormat == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		report := security.NewReport(analysis, time.Now())
+		if scanAggregate {
+			report = security.NewAggregatedReport(analysis, time.Now())
+		}
+		report.Baseline = diff
+		return encoder.Encode(report)
+	}
+
+	visualizer := security.NewSecurityVisualizer(securityConfig())
+	var reports []string
+	switch scanFormat {
+	case "dashboard":
+		reports = append(reports, visualizer.GenerateSecurityDashboard(analysis))
+	case "timeline":
+		reports = append(reports, visualizer.GenerateIncidentTimelineReport(analysis.Incidents))
+	default:
+		reports = append(reports,
+			visualizer.GenerateDetailedThreatReport(analysis.Threats),
+			visualizer.GenerateOWASPReport(analysis.Threats),
+			visualizer.GenerateAnomalyReport(analysis.Anomalies),
+			visualizer.GenerateSecurityRecommendationReport(analysis.Recommendations))
+	}
+	if diff != nil {
+		reports = append(reports, visualizer.GenerateBaselineDiff(*diff))
+	}
+	for i, report := range reports {
+		if noColors || scanOutput != "" || !charts.SupportsColor() {
+			report = charts.StripColors(report)
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if _, err := fmt.Fprint(w, report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// securityExitCode maps a threat level to the exit code of security scan:
+// 2 for Info, 3 for Low up to 6 for Critical. It is never 0, so every level
+// a threshold lets through fails the run, and never 1, which is a failed
+// scan.
+func securityExitCode(level security.ThreatSeverity) int {
+	return int(level) + 2
+}
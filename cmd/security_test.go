@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+
+	"smart-log-analyser/pkg/security"
+)
+
+func TestSecurityExitCode(t *testing.T) {
+	tests := []struct {
+		level security.ThreatSeverity
+		want  int
+	}{
+		{security.SeverityInfo, 2},
+		{security.SeverityLow, 3},
+		{security.SeverityMedium, 4},
+		{security.SeverityHigh, 5},
+		{security.SeverityCritical, 6},
+	}
+	for _, tt := range tests {
+		if got := securityExitCode(tt.level); got != tt.want {
+			t.Errorf("securityExitCode(%s) = %d, want %d", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestSecurityExitCodeFailsOnInfoThreshold(t *testing.T) {
+	threats := []security.EnhancedThreat{{Severity: security.SeverityInfo}}
+
+	threshold, err := security.ParseSeverity("info")
+	if err != nil {
+		t.Fatalf("ParseSeverity(info): %v", err)
+	}
+	level, found := security.ThreatLevel(threats)
+	if !found || level < threshold {
+		t.Fatalf("ThreatLevel = %s, %v; want an info threat at the info threshold", level, found)
+	}
+	if code := securityExitCode(level); code == 0 || code == 1 {
+		t.Errorf("info threats at the info threshold exit %d, want neither success nor failure", code)
+	}
+}
@@ -2,11 +2,14 @@ package analyser
 
 import (
 	"fmt"
+	"hash/fnv"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"smart-log-analyser/pkg/decode"
+	"smart-log-analyser/pkg/mitre"
 	"smart-log-analyser/pkg/parser"
 )
 
@@ -49,9 +52,11 @@ type HourlyTraffic struct {
 }
 
 type TrafficPeak struct {
-	Time         string // Timestamp of peak
-	RequestCount int    // Number of requests during peak period
-	Duration     string // Peak duration description
+	Time         string            // Timestamp of peak
+	Hour         int               // Hour of day (0-23) of the peak
+	RequestCount int               // Number of requests during peak period
+	Duration     string            // Peak duration description
+	Attribution  *SpikeAttribution // Top contributors compared to the other hours
 }
 
 type ResponseTimeStats struct {
@@ -104,6 +109,7 @@ type IPThreatAnalysis struct {
 	RequestCount     int
 	ThreatScore      int    // 0-100 scale
 	ThreatCategories []string // "brute_force", "scanner", "malicious_patterns", etc.
+	MITRETechniques  []string // MITRE ATT&CK technique IDs of the threat categories
 	FirstSeen        time.Time
 	LastSeen         time.Time
 	UniqueURLs       int
@@ -122,7 +128,9 @@ type SecurityAnalysis struct {
 	XSSAttempts          int
 	DirectoryTraversal   int
 	ScanningActivity     int
+	MethodProbing        int              // Requests using unusual methods (TRACE, PROPFIND, ...)
 	TopAttackers         []IPStat // IPs with most malicious activity
+	Baselines            AnomalyBaselines // Expected rates used for anomaly detection
 }
 
 type DetailedStatusCode struct {
@@ -131,6 +139,7 @@ type DetailedStatusCode struct {
 }
 
 type Results struct {
+	SchemaVersion          int `json:"schema_version"` // ResultsSchemaVersion of the JSON structure
 	TotalRequests          int
 	TimeRange              TimeRange
 	StatusCodes            map[string]int
@@ -138,6 +147,7 @@ type Results struct {
 	TopIPs                 []IPStat
 	TopURLs                []URLStat
 	HTTPMethods            []MethodStat
+	MethodDetails          []MethodDetail // Per-method error rates, flagging unusual verbs
 	TotalBytes             int64
 	AverageSize            int64
 	UniqueIPs              int
@@ -149,6 +159,7 @@ type Results struct {
 	ErrorURLs              []URLStat // URLs that generated errors
 	LargeRequests          []URLStat // Largest requests by size
 	HourlyTraffic          []HourlyTraffic
+	WeekdayHourTraffic     [7][24]int // Requests per day of week (indexed by time.Weekday) and hour of day
 	TrafficPeaks           []TrafficPeak
 	AverageRequestsPerHour float64
 	PeakHour               int
@@ -156,84 +167,109 @@ type Results struct {
 	ResponseTimeStats      ResponseTimeStats
 	GeographicAnalysis     GeographicAnalysis
 	SecurityAnalysis       SecurityAnalysis
+	EndpointStats          []EndpointStat
+	Protocols              []ProtocolStat
+	BrokenLinks            []BrokenLink
+	RateLimitCandidates    []RateLimitCandidate
+	SizeHistogram          []SizeBucket // Response counts per size bucket
+	CrawlBudget            CrawlBudgetReport
+	EgressCost             *EgressCostReport // Nil unless egress pricing is configured
+	CountryStats           []CountryStat
+	StaticDynamic          StaticDynamicReport
+	Capacity               CapacityStats // Requests per second and concurrency estimates
+	DataVolume             VolumeReport  // IPs with abnormal download/upload volumes
+	LatencyHeatmap         *LatencyHeatmap // Nil unless the logs include $request_time
+	Timeline               *RequestTimeline `json:"-"` // Requests per minute for time-series charts; nil when loaded from an export
+	SessionLengths         []time.Duration  `json:"-"` // Length of each visitor session; nil when loaded from an export
+	Extensions             map[string]interface{} // Output of third-party pipeline modules, keyed by module name
 }
 
-type Analyser struct{}
+type Analyser struct {
+	botSignatures []BotSignature
+	siteHosts     []string
+	rateLimits    RateLimitThresholds
+	sizeBuckets   []int64
+	crawlerVerifier *crawlerVerifier
+	egressPricing   *EgressPricing
+	baselines       AnomalyBaselines
+	geoIP           *GeoIPDatabase
+	countryFilter   []string
+	serviceTime     time.Duration
+	volumeThresholds VolumeThresholds
+	disabledModules map[string]bool
+}
 
 func New() *Analyser {
-	return &Analyser{}
+	return &Analyser{
+		botSignatures: DefaultBotSignatures(),
+		rateLimits:    DefaultRateLimitThresholds(),
+		sizeBuckets:   DefaultSizeBucketBounds,
+		baselines:     DefaultAnomalyBaselines(),
+		serviceTime:   DefaultServiceTime,
+		volumeThresholds: DefaultVolumeThresholds(),
+	}
 }
 
 func (a *Analyser) Analyse(logs []*parser.LogEntry, since, until *time.Time) *Results {
 	filtered := a.FilterByTime(logs, since, until)
 	
 	if len(filtered) == 0 {
-		return &Results{
-			TotalRequests:          0,
-			TimeRange:              TimeRange{},
-			StatusCodes:            make(map[string]int),
-			DetailedStatusCodes:    []DetailedStatusCode{},
-			TopIPs:                 []IPStat{},
-			TopURLs:                []URLStat{},
-			HTTPMethods:            []MethodStat{},
-			TotalBytes:             0,
-			AverageSize:            0,
-			UniqueIPs:              0,
-			UniqueURLs:             0,
-			BotRequests:            0,
-			HumanRequests:          0,
-			TopBots:                []BotStat{},
-			FileTypes:              []FileTypeStat{},
-			ErrorURLs:              []URLStat{},
-			LargeRequests:          []URLStat{},
-			HourlyTraffic:          []HourlyTraffic{},
-			TrafficPeaks:           []TrafficPeak{},
-			AverageRequestsPerHour: 0,
-			PeakHour:               -1,
-			QuietestHour:           -1,
-			ResponseTimeStats:      ResponseTimeStats{},
-			GeographicAnalysis:     GeographicAnalysis{},
-			SecurityAnalysis:       SecurityAnalysis{},
-		}
+		return newEmptyResults()
 	}
 
-	botRequests, humanRequests := a.analyseBotTraffic(filtered)
-	hourlyTraffic := a.analyseHourlyTraffic(filtered)
-	trafficPeaks := a.detectTrafficPeaks(hourlyTraffic)
-	avgPerHour, peakHour, quietestHour := a.calculateTrafficStats(hourlyTraffic)
-	responseTimeStats := a.analyseResponseTimes(filtered)
-	geographicAnalysis := a.analyseGeographicDistribution(filtered)
-	securityAnalysis := a.analyseSecurityThreats(filtered)
-	
-	results := &Results{
-		TotalRequests:          len(filtered),
-		TimeRange:              a.calculateTimeRange(filtered),
-		StatusCodes:            a.analyseStatusCodes(filtered),
-		DetailedStatusCodes:    a.analyseDetailedStatusCodes(filtered),
-		TopIPs:                 a.analyseTopIPs(filtered),
-		TopURLs:                a.analyseTopURLs(filtered),
-		HTTPMethods:            a.analyseHTTPMethods(filtered),
-		TotalBytes:             a.calculateTotalBytes(filtered),
-		AverageSize:            a.calculateAverageSize(filtered),
-		UniqueIPs:              a.countUniqueIPs(filtered),
-		UniqueURLs:             a.countUniqueURLs(filtered),
-		BotRequests:            botRequests,
-		HumanRequests:          humanRequests,
-		TopBots:                a.analyseTopBots(filtered),
-		FileTypes:              a.analyseFileTypes(filtered),
-		ErrorURLs:              a.analyseErrorURLs(filtered),
-		LargeRequests:          a.analyseLargeRequests(filtered),
-		HourlyTraffic:          hourlyTraffic,
-		TrafficPeaks:           trafficPeaks,
-		AverageRequestsPerHour: avgPerHour,
-		PeakHour:               peakHour,
-		QuietestHour:           quietestHour,
-		ResponseTimeStats:      responseTimeStats,
-		GeographicAnalysis:     geographicAnalysis,
-		SecurityAnalysis:       securityAnalysis,
-	}
-
-	return results
+	return a.runPipeline(filtered)
+}
+
+// newEmptyResults returns Results with every collection initialised, as
+// reported when no entries fall in the analysed window
+func newEmptyResults() *Results {
+	return &Results{
+		SchemaVersion:          ResultsSchemaVersion,
+		TotalRequests:          0,
+		TimeRange:              TimeRange{},
+		StatusCodes:            make(map[string]int),
+		DetailedStatusCodes:    []DetailedStatusCode{},
+		TopIPs:                 []IPStat{},
+		TopURLs:                []URLStat{},
+		HTTPMethods:            []MethodStat{},
+		MethodDetails:          []MethodDetail{},
+		TotalBytes:             0,
+		AverageSize:            0,
+		UniqueIPs:              0,
+		UniqueURLs:             0,
+		BotRequests:            0,
+		HumanRequests:          0,
+		TopBots:                []BotStat{},
+		FileTypes:              []FileTypeStat{},
+		ErrorURLs:              []URLStat{},
+		LargeRequests:          []URLStat{},
+		HourlyTraffic:          []HourlyTraffic{},
+		TrafficPeaks:           []TrafficPeak{},
+		AverageRequestsPerHour: 0,
+		PeakHour:               -1,
+		QuietestHour:           -1,
+		ResponseTimeStats:      ResponseTimeStats{},
+		GeographicAnalysis:     GeographicAnalysis{},
+		SecurityAnalysis:       SecurityAnalysis{},
+		EndpointStats:          []EndpointStat{},
+		Protocols:              []ProtocolStat{},
+		BrokenLinks:            []BrokenLink{},
+		RateLimitCandidates:    []RateLimitCandidate{},
+		SizeHistogram:          []SizeBucket{},
+		CrawlBudget: CrawlBudgetReport{
+			Crawlers:    []CrawlerStat{},
+			Sections:    []CrawlSection{},
+			Discoveries: []CrawlDiscovery{},
+		},
+		CountryStats:           []CountryStat{},
+		StaticDynamic: StaticDynamicReport{
+			Static:          ContentClassStat{Class: ContentStatic},
+			Dynamic:         ContentClassStat{Class: ContentDynamic},
+			CacheCandidates: []CacheCandidate{},
+		},
+		DataVolume:             VolumeReport{Anomalies: []VolumeAnomaly{}},
+		Extensions:             make(map[string]interface{}),
+	}
 }
 
 func (a *Analyser) FilterByTime(logs []*parser.LogEntry, since, until *time.Time) []*parser.LogEntry {
@@ -272,59 +308,6 @@ func (a *Analyser) calculateTimeRange(logs []*parser.LogEntry) TimeRange {
 	return TimeRange{Start: start, End: end}
 }
 
-func (a *Analyser) analyseStatusCodes(logs []*parser.LogEntry) map[string]int {
-	statusCodes := make(map[string]int)
-
-	for _, log := range logs {
-		status := getStatusClass(log.Status)
-		statusCodes[status]++
-	}
-
-	return statusCodes
-}
-
-func (a *Analyser) analyseTopIPs(logs []*parser.LogEntry) []IPStat {
-	ipCounts := make(map[string]int)
-
-	for _, log := range logs {
-		ipCounts[log.IP]++
-	}
-
-	var ipStats []IPStat
-	for ip, count := range ipCounts {
-		ipStats = append(ipStats, IPStat{IP: ip, Count: count})
-	}
-
-	sort.Slice(ipStats, func(i, j int) bool {
-		return ipStats[i].Count > ipStats[j].Count
-	})
-
-	return ipStats
-}
-
-func (a *Analyser) analyseTopURLs(logs []*parser.LogEntry) []URLStat {
-	urlCounts := make(map[string]int)
-
-	for _, log := range logs {
-		urlCounts[log.URL]++
-	}
-
-	var urlStats []URLStat
-	for url, count := range urlCounts {
-		urlStats = append(urlStats, URLStat{
-			URL:         url, 
-			Count:       count,
-			StatusCodes: nil, // Not applicable for top URLs (not error-specific)
-		})
-	}
-
-	sort.Slice(urlStats, func(i, j int) bool {
-		return urlStats[i].Count > urlStats[j].Count
-	})
-
-	return urlStats
-}
-
 // FormatStatusCodes formats status codes from a URLStat for display
 func (u *URLStat) FormatStatusCodes() string {
 	if u.StatusCodes == nil || len(u.StatusCodes) == 0 {
@@ -353,16 +336,7 @@ func (a *Analyser) analyseHTTPMethods(logs []*parser.LogEntry) []MethodStat {
 		methodCounts[log.Method]++
 	}
 
-	var methodStats []MethodStat
-	for method, count := range methodCounts {
-		methodStats = append(methodStats, MethodStat{Method: method, Count: count})
-	}
-
-	sort.Slice(methodStats, func(i, j int) bool {
-		return methodStats[i].Count > methodStats[j].Count
-	})
-
-	return methodStats
+	return sortMethodCounts(methodCounts)
 }
 
 func (a *Analyser) calculateTotalBytes(logs []*parser.LogEntry) int64 {
@@ -373,147 +347,6 @@ func (a *Analyser) calculateTotalBytes(logs []*parser.LogEntry) int64 {
 	return total
 }
 
-func (a *Analyser) calculateAverageSize(logs []*parser.LogEntry) int64 {
-	if len(logs) == 0 {
-		return 0
-	}
-	return a.calculateTotalBytes(logs) / int64(len(logs))
-}
-
-func (a *Analyser) countUniqueIPs(logs []*parser.LogEntry) int {
-	unique := make(map[string]bool)
-	for _, log := range logs {
-		unique[log.IP] = true
-	}
-	return len(unique)
-}
-
-func (a *Analyser) countUniqueURLs(logs []*parser.LogEntry) int {
-	unique := make(map[string]bool)
-	for _, log := range logs {
-		unique[log.URL] = true
-	}
-	return len(unique)
-}
-
-func (a *Analyser) analyseBotTraffic(logs []*parser.LogEntry) (int, int) {
-	botCount := 0
-	humanCount := 0
-	
-	for _, log := range logs {
-		if isBot(log.UserAgent) {
-			botCount++
-		} else {
-			humanCount++
-		}
-	}
-	
-	return botCount, humanCount
-}
-
-func (a *Analyser) analyseTopBots(logs []*parser.LogEntry) []BotStat {
-	botCounts := make(map[string]int)
-	
-	for _, log := range logs {
-		if botName := getBotName(log.UserAgent); botName != "" {
-			botCounts[botName]++
-		}
-	}
-	
-	var botStats []BotStat
-	for bot, count := range botCounts {
-		botStats = append(botStats, BotStat{BotName: bot, Count: count})
-	}
-	
-	sort.Slice(botStats, func(i, j int) bool {
-		return botStats[i].Count > botStats[j].Count
-	})
-	
-	return botStats
-}
-
-func (a *Analyser) analyseFileTypes(logs []*parser.LogEntry) []FileTypeStat {
-	fileTypeCounts := make(map[string]int)
-	fileTypeSizes := make(map[string]int64)
-	
-	for _, log := range logs {
-		fileType := getFileType(log.URL)
-		fileTypeCounts[fileType]++
-		fileTypeSizes[fileType] += log.Size
-	}
-	
-	var fileTypeStats []FileTypeStat
-	for fileType, count := range fileTypeCounts {
-		fileTypeStats = append(fileTypeStats, FileTypeStat{
-			FileType: fileType,
-			Count:    count,
-			Size:     fileTypeSizes[fileType],
-		})
-	}
-	
-	sort.Slice(fileTypeStats, func(i, j int) bool {
-		return fileTypeStats[i].Count > fileTypeStats[j].Count
-	})
-	
-	return fileTypeStats
-}
-
-func isBot(userAgent string) bool {
-	ua := strings.ToLower(userAgent)
-	botKeywords := []string{
-		"bot", "crawler", "spider", "scraper", "parser",
-		"googlebot", "bingbot", "slurp", "facebookexternalhit",
-		"twitterbot", "linkedinbot", "whatsapp", "telegram",
-		"curl", "wget", "python", "go-http-client", "java",
-		"monitoring", "uptime", "check", "test", "scan",
-	}
-	
-	for _, keyword := range botKeywords {
-		if strings.Contains(ua, keyword) {
-			return true
-		}
-	}
-	
-	return false
-}
-
-func getBotName(userAgent string) string {
-	if !isBot(userAgent) {
-		return ""
-	}
-	
-	ua := strings.ToLower(userAgent)
-	
-	// Common bot patterns
-	botPatterns := map[string]string{
-		"googlebot":              "Googlebot",
-		"bingbot":                "Bingbot", 
-		"slurp":                  "Yahoo Slurp",
-		"facebookexternalhit":    "Facebook Bot",
-		"twitterbot":             "Twitter Bot",
-		"linkedinbot":            "LinkedIn Bot",
-		"whatsapp":               "WhatsApp Bot",
-		"telegram":               "Telegram Bot",
-		"curl":                   "cURL",
-		"wget":                   "Wget",
-		"python":                 "Python Script",
-		"go-http-client":         "Go HTTP Client",
-		"java":                   "Java Client",
-		"monitoring":             "Monitoring Bot",
-		"uptime":                 "Uptime Monitor",
-		"check":                  "Health Check",
-		"scan":                   "Security Scanner",
-	}
-	
-	for pattern, name := range botPatterns {
-		if strings.Contains(ua, pattern) {
-			return name
-		}
-	}
-	
-	return "Unknown Bot"
-}
-
 func getFileType(url string) string {
 	// Remove query parameters
 	url = strings.Split(url, "?")[0]
@@ -560,125 +393,7 @@ func (a *Analyser) analyseDetailedStatusCodes(logs []*parser.LogEntry) []Detaile
 		statusCounts[log.Status]++
 	}
 	
-	var statusStats []DetailedStatusCode
-	for status, count := range statusCounts {
-		statusStats = append(statusStats, DetailedStatusCode{Code: status, Count: count})
-	}
-	
-	sort.Slice(statusStats, func(i, j int) bool {
-		return statusStats[i].Count > statusStats[j].Count
-	})
-	
-	return statusStats
-}
-
-func (a *Analyser) analyseErrorURLs(logs []*parser.LogEntry) []URLStat {
-	// Map from URL to status code counts
-	errorData := make(map[string]map[int]int)
-	
-	for _, log := range logs {
-		if log.Status >= 400 { // 4xx and 5xx errors
-			if errorData[log.URL] == nil {
-				errorData[log.URL] = make(map[int]int)
-			}
-			errorData[log.URL][log.Status]++
-		}
-	}
-	
-	var errorStats []URLStat
-	for url, statusCodes := range errorData {
-		// Calculate total count for this URL
-		totalCount := 0
-		for _, count := range statusCodes {
-			totalCount += count
-		}
-		
-		errorStats = append(errorStats, URLStat{
-			URL:         url,
-			Count:       totalCount,
-			StatusCodes: statusCodes,
-		})
-	}
-	
-	sort.Slice(errorStats, func(i, j int) bool {
-		return errorStats[i].Count > errorStats[j].Count
-	})
-	
-	// Return top 10 error URLs
-	if len(errorStats) > 10 {
-		errorStats = errorStats[:10]
-	}
-	
-	return errorStats
-}
-
-func (a *Analyser) analyseLargeRequests(logs []*parser.LogEntry) []URLStat {
-	type urlSize struct {
-		url  string
-		size int64
-	}
-	
-	var requests []urlSize
-	for _, log := range logs {
-		requests = append(requests, urlSize{url: log.URL, size: log.Size})
-	}
-	
-	sort.Slice(requests, func(i, j int) bool {
-		return requests[i].size > requests[j].size
-	})
-	
-	// Convert to URLStat format (using size as count for sorting)
-	var largeStats []URLStat
-	seen := make(map[string]bool)
-	
-	for _, req := range requests {
-		if !seen[req.url] && len(largeStats) < 10 {
-			largeStats = append(largeStats, URLStat{
-				URL:         req.url,
-				Count:       int(req.size), // Store size in count field for display
-				StatusCodes: nil,           // Not applicable for large requests
-			})
-			seen[req.url] = true
-		}
-	}
-	
-	return largeStats
-}
-
-func (a *Analyser) analyseHourlyTraffic(logs []*parser.LogEntry) []HourlyTraffic {
-	if len(logs) == 0 {
-		return []HourlyTraffic{}
-	}
-	
-	// Count requests per hour
-	hourlyCounts := make(map[int]int)
-	hourTimestamps := make(map[int]string)
-	
-	for _, log := range logs {
-		hour := log.Timestamp.Hour()
-		hourlyCounts[hour]++
-		
-		// Store a representative timestamp for this hour (first occurrence)
-		if _, exists := hourTimestamps[hour]; !exists {
-			hourTimestamps[hour] = log.Timestamp.Format("2006-01-02 15:00")
-		}
-	}
-	
-	// Convert to slice and sort by hour
-	var hourlyTraffic []HourlyTraffic
-	for hour, count := range hourlyCounts {
-		hourlyTraffic = append(hourlyTraffic, HourlyTraffic{
-			Hour:         hour,
-			RequestCount: count,
-			Timestamp:    hourTimestamps[hour],
-		})
-	}
-	
-	sort.Slice(hourlyTraffic, func(i, j int) bool {
-		return hourlyTraffic[i].Hour < hourlyTraffic[j].Hour
-	})
-	
-	return hourlyTraffic
+	return sortDetailedStatusCodes(statusCounts)
 }
 
 func (a *Analyser) detectTrafficPeaks(hourlyTraffic []HourlyTraffic) []TrafficPeak {
@@ -716,6 +431,7 @@ func (a *Analyser) detectTrafficPeaks(hourlyTraffic []HourlyTraffic) []TrafficPe
 			if isPeak {
 				peaks = append(peaks, TrafficPeak{
 					Time:         traffic.Timestamp,
+					Hour:         traffic.Hour,
 					RequestCount: traffic.RequestCount,
 					Duration:     "1 hour", // For now, consider each peak as 1 hour
 				})
@@ -766,118 +482,8 @@ func (a *Analyser) calculateTrafficStats(hourlyTraffic []HourlyTraffic) (float64
 	return avgRequestsPerHour, peakHour, quietestHour
 }
 
-func (a *Analyser) analyseResponseTimes(logs []*parser.LogEntry) ResponseTimeStats {
-	if len(logs) == 0 {
-		return ResponseTimeStats{}
-	}
-	
-	// Collect all response sizes for percentile calculation
-	sizes := make([]int64, len(logs))
-	totalSize := int64(0)
-	minSize := int64(^uint64(0) >> 1) // Max int64
-	maxSize := int64(0)
-	
-	for i, log := range logs {
-		sizes[i] = log.Size
-		totalSize += log.Size
-		
-		if log.Size < minSize {
-			minSize = log.Size
-		}
-		if log.Size > maxSize {
-			maxSize = log.Size
-		}
-	}
-	
-	// Sort sizes for percentile calculation
-	sort.Slice(sizes, func(i, j int) bool {
-		return sizes[i] < sizes[j]
-	})
-	
-	// Calculate percentiles
-	p50Index := len(sizes) * 50 / 100
-	p95Index := len(sizes) * 95 / 100
-	p99Index := len(sizes) * 99 / 100
-	
-	// Ensure indices are within bounds
-	if p50Index >= len(sizes) { p50Index = len(sizes) - 1 }
-	if p95Index >= len(sizes) { p95Index = len(sizes) - 1 }
-	if p99Index >= len(sizes) { p99Index = len(sizes) - 1 }
-	
-	avgSize := totalSize / int64(len(logs))
-	
-	// Find slowest and fastest requests (by size as proxy)
-	slowRequests := a.analyseLargeRequests(logs)  // Reuse existing logic
-	fastRequests := a.analyseSmallRequests(logs)
-	
-	return ResponseTimeStats{
-		AverageSize:  avgSize,
-		MedianSize:   sizes[p50Index],
-		P95Size:      sizes[p95Index],
-		P99Size:      sizes[p99Index],
-		MinSize:      minSize,
-		MaxSize:      maxSize,
-		SlowRequests: slowRequests,
-		FastRequests: fastRequests,
-	}
-}
-
-func (a *Analyser) analyseSmallRequests(logs []*parser.LogEntry) []URLStat {
-	type urlSize struct {
-		url  string
-		size int64
-	}
-	
-	var requests []urlSize
-	for _, log := range logs {
-		requests = append(requests, urlSize{url: log.URL, size: log.Size})
-	}
-	
-	// Sort by size (smallest first)
-	sort.Slice(requests, func(i, j int) bool {
-		return requests[i].size < requests[j].size
-	})
-	
-	// Convert to URLStat format
-	var smallStats []URLStat
-	seen := make(map[string]bool)
-	
-	for _, req := range requests {
-		if !seen[req.url] && len(smallStats) < 10 {
-			smallStats = append(smallStats, URLStat{
-				URL:   req.url,
-				Count: int(req.size), // Store size in count field for display
-			})
-			seen[req.url] = true
-		}
-	}
-	
-	return smallStats
-}
-
-func (a *Analyser) analyseGeographicDistribution(logs []*parser.LogEntry) GeographicAnalysis {
-	countryCounts := make(map[string]int)
-	regionCounts := make(map[string]int)
-	
-	localTraffic := 0
-	cloudTraffic := 0
-	unknownIPs := 0
-	
-	for _, log := range logs {
-		country, region := a.getIPLocation(log.IP)
-		
-		if country == "Local" {
-			localTraffic++
-		} else if country == "Cloud" {
-			cloudTraffic++
-		} else if country == "Unknown" {
-			unknownIPs++
-		} else {
-			countryCounts[country]++
-			regionCounts[region]++
-		}
-	}
-	
+// buildGeographicAnalysis converts location counts into sorted geographic stats
+func (a *Analyser) buildGeographicAnalysis(countryCounts, regionCounts map[string]int, localTraffic, cloudTraffic, unknownIPs int) GeographicAnalysis {
 	// Convert to sorted slices
 	var topCountries []GeographicStat
 	for country, count := range countryCounts {
@@ -899,11 +505,17 @@ func (a *Analyser) analyseGeographicDistribution(logs []*parser.LogEntry) Geogra
 	
 	// Sort by count
 	sort.Slice(topCountries, func(i, j int) bool {
-		return topCountries[i].Count > topCountries[j].Count
+		if topCountries[i].Count != topCountries[j].Count {
+			return topCountries[i].Count > topCountries[j].Count
+		}
+		return topCountries[i].Country < topCountries[j].Country
 	})
 	
 	sort.Slice(topRegions, func(i, j int) bool {
-		return topRegions[i].Count > topRegions[j].Count
+		if topRegions[i].Count != topRegions[j].Count {
+			return topRegions[i].Count > topRegions[j].Count
+		}
+		return topRegions[i].Country < topRegions[j].Country
 	})
 	
 	return GeographicAnalysis{
@@ -926,6 +538,17 @@ func (a *Analyser) getIPLocation(ip string) (string, string) {
 		return "Local", "Private Network"
 	}
 	
+	// Prefer a real GeoIP database when one is loaded
+	if a.geoIP != nil {
+		if code, name, ok := a.geoIP.Lookup(ip); ok {
+			region, known := countryRegions[code]
+			if !known {
+				region = a.getRegionForCountry(name)
+			}
+			return name, region
+		}
+	}
+	
 	// Common cloud/CDN providers (based on known ranges)
 	if strings.HasPrefix(ip, "172.69.") || strings.HasPrefix(ip, "172.71.") ||
 	   strings.HasPrefix(ip, "162.158.") || strings.HasPrefix(ip, "104.") {
@@ -985,125 +608,178 @@ func getStatusClass(status int) string {
 
 // Security Analysis Methods
 func (a *Analyser) analyseSecurityThreats(logs []*parser.LogEntry) SecurityAnalysis {
-	var threats []SecurityThreat
-	var suspiciousIPs []IPThreatAnalysis
-	var anomalies []AnomalyDetection
+	module := newSecurityModule(a)
+	for _, log := range logs {
+		module.Process(log)
+	}
+	return module.analysis()
+}
+
+// securityModule detects threats and tracks how each IP behaves, keeping
+// counts and each IP's distinct URLs as hashes rather than the entries
+type securityModule struct {
+	analyser *Analyser
+	total    int
+	threats  []SecurityThreat
 	
 	// Counters for different attack types
-	sqlInjection := 0
-	xssAttempts := 0
-	directoryTraversal := 0
-	bruteForce := 0
-	scanningActivity := 0
+	sqlInjection       int
+	xssAttempts        int
+	directoryTraversal int
+	bruteForce         int
+	scanningActivity   int
+	methodProbing      int
 	
 	// Track IP behavior for threat analysis
-	ipStats := make(map[string]*IPThreatAnalysis)
-	
-	// Analyze each log entry for security threats
-	for _, log := range logs {
-		// Initialize IP stats if not exists
-		if _, exists := ipStats[log.IP]; !exists {
-			ipStats[log.IP] = &IPThreatAnalysis{
-				IP:               log.IP,
-				RequestCount:     0,
-				ThreatScore:      0,
-				ThreatCategories: []string{},
-				FirstSeen:        log.Timestamp,
-				LastSeen:         log.Timestamp,
-				UniqueURLs:       0,
-				ErrorRate:        0,
-			}
-		}
-		
-		ipStat := ipStats[log.IP]
-		ipStat.RequestCount++
-		ipStat.LastSeen = log.Timestamp
-		
-		// Check for SQL injection patterns
-		if a.detectSQLInjection(log.URL) {
-			threats = append(threats, SecurityThreat{
-				Type:      "sql_injection",
-				Pattern:   a.extractSQLPattern(log.URL),
-				URL:       log.URL,
-				IP:        log.IP,
-				Timestamp: log.Timestamp,
-				Severity:  "high",
-				UserAgent: log.UserAgent,
-			})
-			sqlInjection++
-			a.updateThreatScore(ipStat, "sql_injection", 30)
-		}
-		
-		// Check for XSS attempts
-		if a.detectXSS(log.URL) {
-			threats = append(threats, SecurityThreat{
-				Type:      "xss",
-				Pattern:   a.extractXSSPattern(log.URL),
-				URL:       log.URL,
-				IP:        log.IP,
-				Timestamp: log.Timestamp,
-				Severity:  "medium",
-				UserAgent: log.UserAgent,
-			})
-			xssAttempts++
-			a.updateThreatScore(ipStat, "xss", 20)
-		}
-		
-		// Check for directory traversal
-		if a.detectDirectoryTraversal(log.URL) {
-			threats = append(threats, SecurityThreat{
-				Type:      "directory_traversal",
-				Pattern:   a.extractTraversalPattern(log.URL),
-				URL:       log.URL,
-				IP:        log.IP,
-				Timestamp: log.Timestamp,
-				Severity:  "high",
-				UserAgent: log.UserAgent,
-			})
-			directoryTraversal++
-			a.updateThreatScore(ipStat, "directory_traversal", 25)
-		}
-		
-		// Check for brute force attempts (multiple failed logins)
-		if a.detectBruteForce(log.URL, log.Status) {
-			bruteForce++
-			a.updateThreatScore(ipStat, "brute_force", 15)
-		}
-		
-		// Check for scanning activity
-		if a.detectScanning(log.UserAgent, log.URL) {
-			scanningActivity++
-			a.updateThreatScore(ipStat, "scanner", 10)
-		}
-		
-		// Track error rates for IP reputation
-		if log.Status >= 400 {
-			// Will calculate error rate later
+	ipStats     map[string]*IPThreatAnalysis
+	ipURLs      map[string]map[uint64]bool
+	ipErrors    map[string]int
+	statusCodes map[int]int
+}
+
+func newSecurityModule(a *Analyser) *securityModule {
+	return &securityModule{
+		analyser:    a,
+		ipStats:     make(map[string]*IPThreatAnalysis),
+		ipURLs:      make(map[string]map[uint64]bool),
+		ipErrors:    make(map[string]int),
+		statusCodes: make(map[int]int),
+	}
+}
+
+func (m *securityModule) Name() string { return ModuleSecurity }
+
+func (m *securityModule) Process(entry *parser.LogEntry) {
+	m.total++
+	m.statusCodes[entry.Status]++
+	
+	// Initialize IP stats if not exists
+	if _, exists := m.ipStats[entry.IP]; !exists {
+		m.ipStats[entry.IP] = &IPThreatAnalysis{
+			IP:               entry.IP,
+			RequestCount:     0,
+			ThreatScore:      0,
+			ThreatCategories: []string{},
+			FirstSeen:        entry.Timestamp,
+			LastSeen:         entry.Timestamp,
+			UniqueURLs:       0,
+			ErrorRate:        0,
 		}
+		m.ipURLs[entry.IP] = make(map[uint64]bool)
+	}
+	
+	ipStat := m.ipStats[entry.IP]
+	ipStat.RequestCount++
+	ipStat.LastSeen = entry.Timestamp
+	
+	// Track distinct URLs and error rates for IP reputation
+	urlHash := fnv.New64a()
+	urlHash.Write([]byte(entry.URL))
+	m.ipURLs[entry.IP][urlHash.Sum64()] = true
+	if entry.Status >= 400 {
+		m.ipErrors[entry.IP]++
+	}
+	
+	// Match the attack patterns against the decoded URL as well, so
+	// encoded payloads do not slip through
+	inspected := decode.ForMatching(entry.URL)
+	
+	// Check for SQL injection patterns
+	if m.analyser.detectSQLInjection(inspected) {
+		m.threats = append(m.threats, SecurityThreat{
+			Type:      "sql_injection",
+			Pattern:   m.analyser.extractSQLPattern(inspected),
+			URL:       entry.URL,
+			IP:        entry.IP,
+			Timestamp: entry.Timestamp,
+			Severity:  "high",
+			UserAgent: entry.UserAgent,
+		})
+		m.sqlInjection++
+		m.analyser.updateThreatScore(ipStat, "sql_injection", 30)
+	}
+	
+	// Check for XSS attempts
+	if m.analyser.detectXSS(inspected) {
+		m.threats = append(m.threats, SecurityThreat{
+			Type:      "xss",
+			Pattern:   m.analyser.extractXSSPattern(inspected),
+			URL:       entry.URL,
+			IP:        entry.IP,
+			Timestamp: entry.Timestamp,
+			Severity:  "medium",
+			UserAgent: entry.UserAgent,
+		})
+		m.xssAttempts++
+		m.analyser.updateThreatScore(ipStat, "xss", 20)
+	}
+	
+	// Check for directory traversal
+	if m.analyser.detectDirectoryTraversal(inspected) {
+		m.threats = append(m.threats, SecurityThreat{
+			Type:      "directory_traversal",
+			Pattern:   m.analyser.extractTraversalPattern(inspected),
+			URL:       entry.URL,
+			IP:        entry.IP,
+			Timestamp: entry.Timestamp,
+			Severity:  "high",
+			UserAgent: entry.UserAgent,
+		})
+		m.directoryTraversal++
+		m.analyser.updateThreatScore(ipStat, "directory_traversal", 25)
 	}
 	
-	// Calculate IP threat scores and error rates
-	for ip, stat := range ipStats {
-		errorCount := 0
-		uniqueURLs := make(map[string]bool)
-		
-		for _, log := range logs {
-			if log.IP == ip {
-				uniqueURLs[log.URL] = true
-				if log.Status >= 400 {
-					errorCount++
-				}
-			}
-		}
-		
-		stat.UniqueURLs = len(uniqueURLs)
+	// Check for brute force attempts (multiple failed logins)
+	if m.analyser.detectBruteForce(entry.URL, entry.Status) {
+		m.bruteForce++
+		m.analyser.updateThreatScore(ipStat, "brute_force", 15)
+	}
+	
+	// Check for scanning activity
+	if m.analyser.detectScanning(entry.UserAgent, entry.URL) {
+		m.scanningActivity++
+		m.analyser.updateThreatScore(ipStat, "scanner", 10)
+	}
+	
+	// Check for unusual HTTP methods used for reconnaissance
+	if isUnusualMethod(entry.Method) {
+		category := MethodCategory(entry.Method)
+		m.threats = append(m.threats, SecurityThreat{
+			Type:      "unusual_method",
+			Pattern:   entry.Method + " (" + category + ")",
+			URL:       entry.URL,
+			IP:        entry.IP,
+			Timestamp: entry.Timestamp,
+			Severity:  methodSeverity(category),
+			UserAgent: entry.UserAgent,
+		})
+		m.methodProbing++
+		m.analyser.updateThreatScore(ipStat, "reconnaissance", 10)
+	}
+}
+
+func (m *securityModule) Finalize(results *Results) {
+	results.SecurityAnalysis = m.analysis()
+}
+
+// analysis summarises what has been processed so far, without changing it
+func (m *securityModule) analysis() SecurityAnalysis {
+	a := m.analyser
+	threats := append([]SecurityThreat(nil), m.threats...)
+	var suspiciousIPs []IPThreatAnalysis
+	
+	// Calculate IP error rates
+	for ip, tracked := range m.ipStats {
+		stat := *tracked
+		stat.ThreatCategories = append([]string{}, tracked.ThreatCategories...)
+		stat.UniqueURLs = len(m.ipURLs[ip])
 		if stat.RequestCount > 0 {
-			stat.ErrorRate = float64(errorCount) / float64(stat.RequestCount) * 100
+			stat.ErrorRate = float64(m.ipErrors[ip]) / float64(stat.RequestCount) * 100
 		}
 		
 		// Only include IPs with suspicious activity
 		if stat.ThreatScore > 0 {
-			suspiciousIPs = append(suspiciousIPs, *stat)
+			suspiciousIPs = append(suspiciousIPs, stat)
 		}
 	}
 	
@@ -1113,11 +789,11 @@ func (a *Analyser) analyseSecurityThreats(logs []*parser.LogEntry) SecurityAnaly
 	})
 	
 	// Generate anomaly detection
-	anomalies = a.detectAnomalies(logs)
+	anomalies := a.detectStatusAnomalies(m.total, m.statusCodes, a.baselines)
 	
 	// Calculate overall threat level and security score
 	threatLevel := a.calculateThreatLevel(threats, suspiciousIPs)
-	securityScore := a.calculateSecurityScore(len(logs), len(threats), len(suspiciousIPs))
+	securityScore := a.calculateSecurityScore(m.total, len(threats), len(suspiciousIPs))
 	
 	// Create top attackers list
 	topAttackers := []IPStat{}
@@ -1138,12 +814,14 @@ func (a *Analyser) analyseSecurityThreats(logs []*parser.LogEntry) SecurityAnaly
 		ThreatsDetected:      threats,
 		SuspiciousIPs:        suspiciousIPs,
 		AnomaliesDetected:    anomalies,
-		BruteForceAttempts:   bruteForce,
-		SQLInjectionAttempts: sqlInjection,
-		XSSAttempts:          xssAttempts,
-		DirectoryTraversal:   directoryTraversal,
-		ScanningActivity:     scanningActivity,
+		BruteForceAttempts:   m.bruteForce,
+		SQLInjectionAttempts: m.sqlInjection,
+		XSSAttempts:          m.xssAttempts,
+		DirectoryTraversal:   m.directoryTraversal,
+		ScanningActivity:     m.scanningActivity,
+		MethodProbing:        m.methodProbing,
 		TopAttackers:         topAttackers,
+		Baselines:            a.baselines,
 	}
 }
 
@@ -1278,15 +956,16 @@ func (a *Analyser) extractXSSPattern(url string) string {
 }
 
 func (a *Analyser) extractTraversalPattern(url string) string {
+	// Encoded traversal also shows as ../ once decoded, so check it first
+	if strings.Contains(strings.ToLower(url), "%2e%2e") {
+		return "URL-encoded traversal"
+	}
 	if strings.Contains(url, "../") {
 		return "Unix-style traversal (../)"
 	}
 	if strings.Contains(url, "..\\") {
 		return "Windows-style traversal (..\\)"
 	}
-	if strings.Contains(url, "%2e%2e") {
-		return "URL-encoded traversal"
-	}
 	return "Generic directory traversal"
 }
 
@@ -1304,57 +983,56 @@ func (a *Analyser) updateThreatScore(ipStat *IPThreatAnalysis, threatType string
 	}
 	if !found {
 		ipStat.ThreatCategories = append(ipStat.ThreatCategories, threatType)
+		ipStat.MITRETechniques = mitre.IDs(mitre.ForCategories(ipStat.ThreatCategories))
 	}
 }
 
-// Anomaly detection
-func (a *Analyser) detectAnomalies(logs []*parser.LogEntry) []AnomalyDetection {
+// detectStatusAnomalies compares error and 404 rates against the configured baselines
+func (a *Analyser) detectStatusAnomalies(totalRequests int, statusCodes map[int]int, baselines AnomalyBaselines) []AnomalyDetection {
 	var anomalies []AnomalyDetection
 	
-	if len(logs) == 0 {
+	if totalRequests == 0 {
 		return anomalies
 	}
+	if baselines == (AnomalyBaselines{}) {
+		baselines = DefaultAnomalyBaselines()
+	}
 	
-	// Calculate baseline metrics
-	totalRequests := len(logs)
 	errorCount := 0
-	statusCodes := make(map[int]int)
-	
-	for _, log := range logs {
-		statusCodes[log.Status]++
-		if log.Status >= 400 {
-			errorCount++
+	for status, count := range statusCodes {
+		if status >= 400 {
+			errorCount += count
 		}
 	}
 	
 	// Check for anomalous error rates
 	errorRate := float64(errorCount) / float64(totalRequests) * 100
-	expectedErrorRate := 5.0 // 5% is typical baseline
+	expectedErrorRate := baselines.ErrorRate
 	
-	if errorRate > expectedErrorRate*2 { // 2x expected rate
+	if errorRate > expectedErrorRate*baselines.ErrorRateThreshold {
 		anomalies = append(anomalies, AnomalyDetection{
 			Type:          "high_error_rate",
 			Description:   "Unusually high error rate detected",
 			Value:         errorRate,
 			Expected:      expectedErrorRate,
 			Deviation:     (errorRate - expectedErrorRate) / expectedErrorRate * 100,
-			Significance:  a.getSignificance(errorRate, expectedErrorRate, 2.0),
+			Significance:  a.getSignificance(errorRate, expectedErrorRate, baselines.ErrorRateThreshold),
 		})
 	}
 	
 	// Check for anomalous 404 rates
 	notFoundCount := statusCodes[404]
 	notFoundRate := float64(notFoundCount) / float64(totalRequests) * 100
-	expectedNotFoundRate := 2.0 // 2% is typical
+	expectedNotFoundRate := baselines.NotFoundRate
 	
-	if notFoundRate > expectedNotFoundRate*3 {
+	if notFoundRate > expectedNotFoundRate*baselines.NotFoundRateThreshold {
 		anomalies = append(anomalies, AnomalyDetection{
 			Type:          "high_404_rate",
 			Description:   "Unusually high 404 Not Found rate - possible scanning activity",
 			Value:         notFoundRate,
 			Expected:      expectedNotFoundRate,
 			Deviation:     (notFoundRate - expectedNotFoundRate) / expectedNotFoundRate * 100,
-			Significance:  a.getSignificance(notFoundRate, expectedNotFoundRate, 3.0),
+			Significance:  a.getSignificance(notFoundRate, expectedNotFoundRate, baselines.NotFoundRateThreshold),
 		})
 	}
 	
@@ -0,0 +1,86 @@
+package analyser
+
+import (
+	"smart-log-analyser/pkg/parser"
+)
+
+// AnomalyBaselines are the expected error and 404 rates (in percent) that
+// anomaly detection compares against, and the multiple of each baseline a
+// rate must exceed to be reported
+type AnomalyBaselines struct {
+	ErrorRate             float64 // Expected percentage of 4xx/5xx responses
+	ErrorRateThreshold    float64 // Report when the error rate exceeds ErrorRate times this
+	NotFoundRate          float64 // Expected percentage of 404 responses
+	NotFoundRateThreshold float64 // Report when the 404 rate exceeds NotFoundRate times this
+}
+
+// minLearnedRate keeps learned baselines above zero so that a perfectly
+// clean reference log does not flag every single error as an anomaly
+const minLearnedRate = 0.1
+
+// DefaultAnomalyBaselines returns typical baselines for a healthy site
+func DefaultAnomalyBaselines() AnomalyBaselines {
+	return AnomalyBaselines{
+		ErrorRate:             5.0,
+		ErrorRateThreshold:    2.0,
+		NotFoundRate:          2.0,
+		NotFoundRateThreshold: 3.0,
+	}
+}
+
+// SetAnomalyBaselines sets the baselines used by anomaly detection. Zero
+// fields keep their default values.
+func (a *Analyser) SetAnomalyBaselines(baselines AnomalyBaselines) {
+	defaults := DefaultAnomalyBaselines()
+	if baselines.ErrorRate <= 0 {
+		baselines.ErrorRate = defaults.ErrorRate
+	}
+	if baselines.ErrorRateThreshold <= 0 {
+		baselines.ErrorRateThreshold = defaults.ErrorRateThreshold
+	}
+	if baselines.NotFoundRate <= 0 {
+		baselines.NotFoundRate = defaults.NotFoundRate
+	}
+	if baselines.NotFoundRateThreshold <= 0 {
+		baselines.NotFoundRateThreshold = defaults.NotFoundRateThreshold
+	}
+	a.baselines = baselines
+}
+
+// AnomalyBaselines returns the baselines used by anomaly detection
+func (a *Analyser) AnomalyBaselines() AnomalyBaselines {
+	return a.baselines
+}
+
+// LearnAnomalyBaselines derives baselines from a reference "known good" log.
+// The expected rates are taken from the reference traffic; the thresholds
+// are left at their defaults.
+func LearnAnomalyBaselines(logs []*parser.LogEntry) AnomalyBaselines {
+	baselines := DefaultAnomalyBaselines()
+	if len(logs) == 0 {
+		return baselines
+	}
+
+	errors := 0
+	notFound := 0
+	for _, log := range logs {
+		if log.Status >= 400 {
+			errors++
+		}
+		if log.Status == 404 {
+			notFound++
+		}
+	}
+
+	baselines.ErrorRate = float64(errors) / float64(len(logs)) * 100
+	if baselines.ErrorRate < minLearnedRate {
+		baselines.ErrorRate = minLearnedRate
+	}
+
+	baselines.NotFoundRate = float64(notFound) / float64(len(logs)) * 100
+	if baselines.NotFoundRate < minLearnedRate {
+		baselines.NotFoundRate = minLearnedRate
+	}
+
+	return baselines
+}
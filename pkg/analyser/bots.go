@@ -0,0 +1,130 @@
+package analyser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultBotConfigFile is the bot signature file name inside the config directory
+const DefaultBotConfigFile = "bots.yaml"
+
+// BotSignature maps a user agent substring to a display name. Signatures are
+// matched in order, so specific patterns should come before generic ones.
+type BotSignature struct {
+	Pattern string `yaml:"pattern"`        // Case-insensitive user agent substring
+	Name    string `yaml:"name,omitempty"` // Display name (empty reports as "Unknown Bot")
+}
+
+// BotSignatureConfig is the on-disk format of the bot signature file
+type BotSignatureConfig struct {
+	Signatures []BotSignature `yaml:"signatures"`
+}
+
+// DefaultBotSignatures returns the built-in bot and crawler signature set
+func DefaultBotSignatures() []BotSignature {
+	return []BotSignature{
+		{Pattern: "googlebot", Name: "Googlebot"},
+		{Pattern: "bingbot", Name: "Bingbot"},
+		{Pattern: "slurp", Name: "Yahoo Slurp"},
+		{Pattern: "facebookexternalhit", Name: "Facebook Bot"},
+		{Pattern: "twitterbot", Name: "Twitter Bot"},
+		{Pattern: "linkedinbot", Name: "LinkedIn Bot"},
+		{Pattern: "whatsapp", Name: "WhatsApp Bot"},
+		{Pattern: "telegram", Name: "Telegram Bot"},
+		{Pattern: "curl", Name: "cURL"},
+		{Pattern: "wget", Name: "Wget"},
+		{Pattern: "python", Name: "Python Script"},
+		{Pattern: "go-http-client", Name: "Go HTTP Client"},
+		{Pattern: "java", Name: "Java Client"},
+		{Pattern: "monitoring", Name: "Monitoring Bot"},
+		{Pattern: "uptime", Name: "Uptime Monitor"},
+		{Pattern: "check", Name: "Health Check"},
+		{Pattern: "scan", Name: "Security Scanner"},
+		{Pattern: "bot"},
+		{Pattern: "crawler"},
+		{Pattern: "spider"},
+		{Pattern: "scraper"},
+		{Pattern: "parser"},
+		{Pattern: "test"},
+	}
+}
+
+// LoadBotSignatures reads bot signatures from a YAML file. If the file does
+// not exist the built-in defaults are returned.
+func LoadBotSignatures(filename string) ([]BotSignature, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return DefaultBotSignatures(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bot signature file: %w", err)
+	}
+
+	var config BotSignatureConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse bot signature file: %w", err)
+	}
+
+	var signatures []BotSignature
+	for i, signature := range config.Signatures {
+		pattern := strings.ToLower(strings.TrimSpace(signature.Pattern))
+		if pattern == "" {
+			return nil, fmt.Errorf("bot signature %d has an empty pattern", i+1)
+		}
+		signatures = append(signatures, BotSignature{Pattern: pattern, Name: signature.Name})
+	}
+
+	return signatures, nil
+}
+
+// SaveBotSignatures writes bot signatures to a YAML file
+func SaveBotSignatures(filename string, signatures []BotSignature) error {
+	data, err := yaml.Marshal(BotSignatureConfig{Signatures: signatures})
+	if err != nil {
+		return fmt.Errorf("failed to marshal bot signatures: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("failed to write bot signature file: %w", err)
+	}
+
+	return nil
+}
+
+// SetBotSignatures replaces the signatures used to classify bot traffic
+func (a *Analyser) SetBotSignatures(signatures []BotSignature) {
+	a.botSignatures = signatures
+}
+
+// matchBot returns the signature matching the user agent, if any
+func (a *Analyser) matchBot(userAgent string) (BotSignature, bool) {
+	ua := strings.ToLower(userAgent)
+
+	for _, signature := range a.botSignatures {
+		if strings.Contains(ua, signature.Pattern) {
+			return signature, true
+		}
+	}
+
+	return BotSignature{}, false
+}
+
+func (a *Analyser) isBot(userAgent string) bool {
+	_, matched := a.matchBot(userAgent)
+	return matched
+}
+
+func (a *Analyser) getBotName(userAgent string) string {
+	signature, matched := a.matchBot(userAgent)
+	if !matched {
+		return ""
+	}
+
+	if signature.Name == "" {
+		return "Unknown Bot"
+	}
+	return signature.Name
+}
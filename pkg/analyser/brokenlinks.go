@@ -0,0 +1,194 @@
+package analyser
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// RefererStat counts how often a referring page linked to a URL
+type RefererStat struct {
+	Referer  string
+	Count    int
+	Internal bool // Referer is one of the site's own pages
+}
+
+// BrokenLink describes a URL returning 404 and the pages that linked to it
+type BrokenLink struct {
+	URL              string
+	Count            int
+	InternalReferers int // Hits referred from the site's own pages
+	ExternalReferers int // Hits referred from other sites
+	DirectHits       int // Hits with no referer (typed, bookmarks, bots)
+	Referers         []RefererStat
+}
+
+// SetSiteHosts sets the host names treated as internal when attributing referers.
+// When unset the site host is inferred from referers of successful requests.
+func (a *Analyser) SetSiteHosts(hosts []string) {
+	a.siteHosts = nil
+	for _, host := range hosts {
+		host = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(host)), "www.")
+		if host != "" {
+			a.siteHosts = append(a.siteHosts, host)
+		}
+	}
+}
+
+// brokenLinkModule collects 404s with their referers, and the referer hosts
+// of successful requests to infer the site's own host from
+type brokenLinkModule struct {
+	analyser     *Analyser
+	links        map[string]*BrokenLink
+	referers     map[string]map[string]int
+	refererHosts map[string]int
+}
+
+func newBrokenLinkModule(a *Analyser) *brokenLinkModule {
+	return &brokenLinkModule{
+		analyser:     a,
+		links:        make(map[string]*BrokenLink),
+		referers:     make(map[string]map[string]int),
+		refererHosts: make(map[string]int),
+	}
+}
+
+func (m *brokenLinkModule) Name() string { return ModuleBrokenLinks }
+
+func (m *brokenLinkModule) Process(entry *parser.LogEntry) {
+	if entry.Status < 400 {
+		if host := refererHost(entry.Referer); host != "" {
+			m.refererHosts[host]++
+		}
+		return
+	}
+	if entry.Status != 404 {
+		return
+	}
+
+	link, exists := m.links[entry.URL]
+	if !exists {
+		link = &BrokenLink{URL: entry.URL}
+		m.links[entry.URL] = link
+		m.referers[entry.URL] = make(map[string]int)
+	}
+	link.Count++
+
+	if entry.Referer == "" || entry.Referer == "-" {
+		link.DirectHits++
+		return
+	}
+	m.referers[entry.URL][entry.Referer]++
+}
+
+// Finalize sorts referers into internal and external ones, which needs the
+// site's hosts, known only once every entry is seen when inferred
+func (m *brokenLinkModule) Finalize(results *Results) {
+	siteHosts := m.analyser.siteHosts
+	if len(siteHosts) == 0 {
+		siteHosts = inferSiteHosts(m.refererHosts)
+	}
+
+	var brokenLinks []BrokenLink
+	for url, counted := range m.links {
+		link := *counted
+		link.Referers = nil
+		for referer, count := range m.referers[url] {
+			internal := isInternalReferer(referer, siteHosts)
+			if internal {
+				link.InternalReferers += count
+			} else {
+				link.ExternalReferers += count
+			}
+			link.Referers = append(link.Referers, RefererStat{
+				Referer:  referer,
+				Count:    count,
+				Internal: internal,
+			})
+		}
+
+		sort.Slice(link.Referers, func(i, j int) bool {
+			if link.Referers[i].Count != link.Referers[j].Count {
+				return link.Referers[i].Count > link.Referers[j].Count
+			}
+			return link.Referers[i].Referer < link.Referers[j].Referer
+		})
+
+		// Keep the top 10 referring pages per broken URL
+		if len(link.Referers) > 10 {
+			link.Referers = link.Referers[:10]
+		}
+
+		brokenLinks = append(brokenLinks, link)
+	}
+
+	// Internal broken links are the ones we can fix, so rank them first
+	sort.Slice(brokenLinks, func(i, j int) bool {
+		if brokenLinks[i].InternalReferers != brokenLinks[j].InternalReferers {
+			return brokenLinks[i].InternalReferers > brokenLinks[j].InternalReferers
+		}
+		if brokenLinks[i].Count != brokenLinks[j].Count {
+			return brokenLinks[i].Count > brokenLinks[j].Count
+		}
+		return brokenLinks[i].URL < brokenLinks[j].URL
+	})
+
+	// Return top 20 broken links
+	if len(brokenLinks) > 20 {
+		brokenLinks = brokenLinks[:20]
+	}
+
+	results.BrokenLinks = brokenLinks
+}
+
+// inferSiteHosts guesses the site's own host as the most common referer host
+// among successful requests, since most referrals come from the site itself
+func inferSiteHosts(hostCounts map[string]int) []string {
+	bestHost := ""
+	bestCount := 0
+	for host, count := range hostCounts {
+		if count > bestCount || (count == bestCount && host < bestHost) {
+			bestHost = host
+			bestCount = count
+		}
+	}
+
+	if bestHost == "" {
+		return nil
+	}
+	return []string{bestHost}
+}
+
+func isInternalReferer(referer string, siteHosts []string) bool {
+	// Relative referers can only come from the site itself
+	if strings.HasPrefix(referer, "/") {
+		return true
+	}
+
+	host := refererHost(referer)
+	if host == "" {
+		return false
+	}
+
+	for _, siteHost := range siteHosts {
+		if host == siteHost || strings.HasSuffix(host, "."+siteHost) {
+			return true
+		}
+	}
+	return false
+}
+
+func refererHost(referer string) string {
+	if referer == "" || referer == "-" {
+		return ""
+	}
+
+	parsed, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
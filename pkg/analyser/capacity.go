@@ -0,0 +1,199 @@
+package analyser
+
+import (
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// DefaultServiceTime is the assumed average time to serve a request, used to
+// estimate concurrency since access logs do not record response times
+const DefaultServiceTime = 100 * time.Millisecond
+
+// capacityWindow is the longer window used to smooth out one-second bursts
+const capacityWindow = 10
+
+// CapacityStats estimates throughput and concurrency for capacity planning.
+// Rates are computed over every second of the analysed time range, including
+// idle seconds.
+type CapacityStats struct {
+	PeakRPS            int       // Most requests seen in a single second
+	PeakTime           time.Time // Start of the busiest second
+	AverageRPS         float64
+	P50RPS             float64 // Percentiles of per-second request counts
+	P95RPS             float64
+	P99RPS             float64
+	Peak10sRPS         float64 // Highest average rate over a 10 second window
+	P99RPS10s          float64 // 99th percentile of 10 second window rates
+	ActiveSeconds      int     // Seconds with at least one request
+	ServiceTime        time.Duration
+	PeakConcurrency    float64 // Estimated requests in flight at PeakRPS
+	P99Concurrency     float64 // Estimated requests in flight at P99RPS
+	AverageConcurrency float64 // Estimated requests in flight at AverageRPS
+
+	perSecond map[int64]int // Requests per Unix second, kept for merging
+}
+
+// SetServiceTime sets the assumed average request duration used for
+// concurrency estimates. Non-positive values restore the default.
+func (a *Analyser) SetServiceTime(serviceTime time.Duration) {
+	if serviceTime <= 0 {
+		serviceTime = DefaultServiceTime
+	}
+	a.serviceTime = serviceTime
+}
+
+// capacityModule counts requests per second
+type capacityModule struct {
+	analyser  *Analyser
+	perSecond map[int64]int
+}
+
+func newCapacityModule(a *Analyser) *capacityModule {
+	return &capacityModule{
+		analyser:  a,
+		perSecond: make(map[int64]int),
+	}
+}
+
+func (m *capacityModule) Name() string { return ModuleCapacity }
+
+func (m *capacityModule) Process(entry *parser.LogEntry) {
+	m.perSecond[entry.Timestamp.Unix()]++
+}
+
+func (m *capacityModule) Finalize(results *Results) {
+	serviceTime := m.analyser.serviceTime
+	if serviceTime <= 0 {
+		serviceTime = DefaultServiceTime
+	}
+	results.Capacity = buildCapacityStats(m.perSecond, serviceTime)
+}
+
+// buildCapacityStats derives rate percentiles and concurrency estimates from
+// per-second request counts
+func buildCapacityStats(perSecond map[int64]int, serviceTime time.Duration) CapacityStats {
+	stats := CapacityStats{ServiceTime: serviceTime, perSecond: perSecond}
+	if len(perSecond) == 0 {
+		return stats
+	}
+
+	first, last := int64(-1), int64(-1)
+	counts := make([]int, 0, len(perSecond))
+	windows := make(map[int64]int)
+	total := 0
+	for second, count := range perSecond {
+		if first == -1 || second < first {
+			first = second
+		}
+		if last == -1 || second > last {
+			last = second
+		}
+		if count > stats.PeakRPS || (count == stats.PeakRPS && second < stats.PeakTime.Unix()) {
+			stats.PeakRPS = count
+			stats.PeakTime = time.Unix(second, 0).UTC()
+		}
+		counts = append(counts, count)
+		windows[floorDiv(second, capacityWindow)] += count
+		total += count
+	}
+
+	span := int(last - first + 1)
+	stats.ActiveSeconds = len(perSecond)
+	stats.AverageRPS = float64(total) / float64(span)
+
+	sort.Ints(counts)
+	idle := span - len(counts)
+	stats.P50RPS = float64(percentileWithIdle(counts, idle, 50))
+	stats.P95RPS = float64(percentileWithIdle(counts, idle, 95))
+	stats.P99RPS = float64(percentileWithIdle(counts, idle, 99))
+
+	windowCounts := make([]int, 0, len(windows))
+	for _, count := range windows {
+		windowCounts = append(windowCounts, count)
+	}
+	sort.Ints(windowCounts)
+	windowSpan := int(floorDiv(last, capacityWindow) - floorDiv(first, capacityWindow) + 1)
+	stats.Peak10sRPS = float64(windowCounts[len(windowCounts)-1]) / capacityWindow
+	stats.P99RPS10s = float64(percentileWithIdle(windowCounts, windowSpan-len(windowCounts), 99)) / capacityWindow
+
+	// Little's law: requests in flight = arrival rate x time in system
+	seconds := serviceTime.Seconds()
+	stats.PeakConcurrency = float64(stats.PeakRPS) * seconds
+	stats.P99Concurrency = stats.P99RPS * seconds
+	stats.AverageConcurrency = stats.AverageRPS * seconds
+
+	return stats
+}
+
+// percentileWithIdle returns the p-th percentile of an ascending slice of
+// non-zero counts preceded by idle zero-count periods
+func percentileWithIdle(sorted []int, idle int, p int) int {
+	total := len(sorted) + idle
+	if total == 0 {
+		return 0
+	}
+
+	index := total * p / 100
+	if index >= total {
+		index = total - 1
+	}
+	if index < idle {
+		return 0
+	}
+	return sorted[index-idle]
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(x, y int64) int64 {
+	q := x / y
+	if (x%y != 0) && ((x < 0) != (y < 0)) {
+		q--
+	}
+	return q
+}
+
+// mergeCapacityStats combines capacity statistics. Per-second counts are
+// summed, so overlapping logs from several servers give the combined load.
+// Results without per-second counts (e.g. loaded from an export) keep the
+// larger of each value.
+func mergeCapacityStats(x, y CapacityStats) CapacityStats {
+	serviceTime := x.ServiceTime
+	if serviceTime <= 0 {
+		serviceTime = y.ServiceTime
+	}
+	if serviceTime <= 0 {
+		serviceTime = DefaultServiceTime
+	}
+
+	if x.perSecond != nil && y.perSecond != nil {
+		perSecond := make(map[int64]int, len(x.perSecond)+len(y.perSecond))
+		for second, count := range x.perSecond {
+			perSecond[second] += count
+		}
+		for second, count := range y.perSecond {
+			perSecond[second] += count
+		}
+		return buildCapacityStats(perSecond, serviceTime)
+	}
+
+	merged := x
+	if y.PeakRPS > merged.PeakRPS {
+		merged.PeakRPS = y.PeakRPS
+		merged.PeakTime = y.PeakTime
+		merged.PeakConcurrency = y.PeakConcurrency
+	}
+	if y.P99RPS > merged.P99RPS {
+		merged.P99RPS = y.P99RPS
+		merged.P99Concurrency = y.P99Concurrency
+	}
+	if y.Peak10sRPS > merged.Peak10sRPS {
+		merged.Peak10sRPS = y.Peak10sRPS
+	}
+	if y.P99RPS10s > merged.P99RPS10s {
+		merged.P99RPS10s = y.P99RPS10s
+	}
+	merged.perSecond = nil
+	return merged
+}
@@ -0,0 +1,153 @@
+package analyser
+
+import (
+	"sort"
+)
+
+// MetricDelta describes how a single metric changed between two windows
+type MetricDelta struct {
+	Name          string
+	Before        float64
+	After         float64
+	Change        float64 // After - Before
+	PercentChange float64 // Relative change (0 when Before is 0)
+}
+
+// Mover is a URL or IP whose request count changed between two windows
+type Mover struct {
+	Key    string
+	Before int
+	After  int
+	Change int
+}
+
+// Comparison holds the deltas between a baseline and a comparison window
+type Comparison struct {
+	Baseline  TimeRange
+	Current   TimeRange
+	Metrics   []MetricDelta
+	URLMovers []Mover // Largest absolute changes in URL request counts
+	IPMovers  []Mover // Largest absolute changes in IP request counts
+}
+
+// Compare computes deltas for the key metrics between two result sets.
+// resultsA is treated as the baseline and resultsB as the window being compared.
+func Compare(resultsA, resultsB *Results) *Comparison {
+	comparison := &Comparison{
+		Baseline: resultsA.TimeRange,
+		Current:  resultsB.TimeRange,
+	}
+
+	add := func(name string, before, after float64) {
+		delta := MetricDelta{
+			Name:   name,
+			Before: before,
+			After:  after,
+			Change: after - before,
+		}
+		if before != 0 {
+			delta.PercentChange = (after - before) / before * 100
+		}
+		comparison.Metrics = append(comparison.Metrics, delta)
+	}
+
+	add("Total Requests", float64(resultsA.TotalRequests), float64(resultsB.TotalRequests))
+	add("Unique IPs", float64(resultsA.UniqueIPs), float64(resultsB.UniqueIPs))
+	add("Unique URLs", float64(resultsA.UniqueURLs), float64(resultsB.UniqueURLs))
+	add("Requests/Hour", resultsA.AverageRequestsPerHour, resultsB.AverageRequestsPerHour)
+	add("Total Bytes", float64(resultsA.TotalBytes), float64(resultsB.TotalBytes))
+	add("Average Size", float64(resultsA.AverageSize), float64(resultsB.AverageSize))
+	add("Error Rate %", errorRate(resultsA), errorRate(resultsB))
+	add("4xx Rate %", statusClassRate(resultsA, "4xx Client Error"), statusClassRate(resultsB, "4xx Client Error"))
+	add("5xx Rate %", statusClassRate(resultsA, "5xx Server Error"), statusClassRate(resultsB, "5xx Server Error"))
+	add("Bot Traffic %", percentOf(resultsA.BotRequests, resultsA.TotalRequests), percentOf(resultsB.BotRequests, resultsB.TotalRequests))
+	add("P50 Size", float64(resultsA.ResponseTimeStats.MedianSize), float64(resultsB.ResponseTimeStats.MedianSize))
+	add("P95 Size", float64(resultsA.ResponseTimeStats.P95Size), float64(resultsB.ResponseTimeStats.P95Size))
+	add("P99 Size", float64(resultsA.ResponseTimeStats.P99Size), float64(resultsB.ResponseTimeStats.P99Size))
+	add("Security Threats", float64(resultsA.SecurityAnalysis.TotalThreats), float64(resultsB.SecurityAnalysis.TotalThreats))
+	add("Security Score", float64(resultsA.SecurityAnalysis.SecurityScore), float64(resultsB.SecurityAnalysis.SecurityScore))
+
+	urlsA := make(map[string]int)
+	for _, url := range resultsA.TopURLs {
+		urlsA[url.URL] = url.Count
+	}
+	urlsB := make(map[string]int)
+	for _, url := range resultsB.TopURLs {
+		urlsB[url.URL] = url.Count
+	}
+	comparison.URLMovers = topMovers(urlsA, urlsB, 10)
+
+	ipsA := make(map[string]int)
+	for _, ip := range resultsA.TopIPs {
+		ipsA[ip.IP] = ip.Count
+	}
+	ipsB := make(map[string]int)
+	for _, ip := range resultsB.TopIPs {
+		ipsB[ip.IP] = ip.Count
+	}
+	comparison.IPMovers = topMovers(ipsA, ipsB, 10)
+
+	return comparison
+}
+
+// topMovers returns the keys with the largest absolute change in count
+func topMovers(before, after map[string]int, limit int) []Mover {
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var movers []Mover
+	for key := range keys {
+		change := after[key] - before[key]
+		if change == 0 {
+			continue
+		}
+		movers = append(movers, Mover{
+			Key:    key,
+			Before: before[key],
+			After:  after[key],
+			Change: change,
+		})
+	}
+
+	sort.Slice(movers, func(i, j int) bool {
+		ci, cj := abs(movers[i].Change), abs(movers[j].Change)
+		if ci != cj {
+			return ci > cj
+		}
+		return movers[i].Key < movers[j].Key
+	})
+
+	if len(movers) > limit {
+		movers = movers[:limit]
+	}
+
+	return movers
+}
+
+func errorRate(results *Results) float64 {
+	errors := results.StatusCodes["4xx Client Error"] + results.StatusCodes["5xx Server Error"]
+	return percentOf(errors, results.TotalRequests)
+}
+
+func statusClassRate(results *Results, class string) float64 {
+	return percentOf(results.StatusCodes[class], results.TotalRequests)
+}
+
+func percentOf(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total) * 100
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,425 @@
+package analyser
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// SearchEngineCrawler identifies a search engine crawler by user agent and
+// the reverse-DNS domains its genuine IPs resolve to
+type SearchEngineCrawler struct {
+	Name    string
+	Pattern string   // Lower-case user agent substring
+	Domains []string // Reverse-DNS suffixes of genuine crawler hosts
+}
+
+// SearchEngineCrawlers lists the crawlers included in the crawl budget report
+var SearchEngineCrawlers = []SearchEngineCrawler{
+	{Name: "Googlebot", Pattern: "googlebot", Domains: []string{".googlebot.com", ".google.com", ".googleusercontent.com"}},
+	{Name: "Bingbot", Pattern: "bingbot", Domains: []string{".search.msn.com"}},
+	{Name: "YandexBot", Pattern: "yandex", Domains: []string{".yandex.ru", ".yandex.net", ".yandex.com"}},
+	{Name: "Baiduspider", Pattern: "baiduspider", Domains: []string{".baidu.com", ".baidu.jp"}},
+	{Name: "Applebot", Pattern: "applebot", Domains: []string{".applebot.apple.com"}},
+	{Name: "Yahoo Slurp", Pattern: "slurp", Domains: []string{".crawl.yahoo.net"}},
+}
+
+// CrawlerStat summarises requests made by one search engine
+type CrawlerStat struct {
+	Name       string
+	Requests   int
+	UniqueURLs int
+	Bytes      int64
+	Errors     int // 4xx/5xx responses
+	Redirects  int // 3xx responses other than 304
+}
+
+// CrawlSection summarises crawl activity for a top-level site section
+type CrawlSection struct {
+	Section        string // First path segment, e.g. /blog
+	Requests       int
+	UniqueURLs     int
+	CrawlsPerDay   float64
+	WastedRequests int // Redirect, error and parameterised URL requests
+}
+
+// CrawlDiscovery counts URLs crawled for the first time on a given day
+type CrawlDiscovery struct {
+	Date     string // YYYY-MM-DD
+	NewURLs  int
+	Requests int // All crawler requests on that day
+}
+
+// CrawlBudgetReport describes how search engine crawlers spent their
+// requests on the site
+type CrawlBudgetReport struct {
+	TotalRequests       int
+	Crawlers            []CrawlerStat
+	Sections            []CrawlSection
+	NotFoundURLs        []URLStat // Crawled URLs returning 404/410
+	RedirectURLs        []URLStat // Crawled URLs returning redirects
+	WastedOnRedirects   int
+	WastedOnErrors      int
+	WastedOnParameters  int     // Requests for URLs with query strings
+	WastedPercent       float64 // Share of crawler requests spent on the above
+	Discoveries         []CrawlDiscovery
+	VerificationEnabled bool // Whether crawler IPs were checked with reverse DNS
+	UnverifiedRequests  int  // Requests with a crawler user agent that failed verification
+}
+
+// SetCrawlerVerification enables reverse-DNS verification of search engine
+// crawlers. Requests claiming a crawler user agent from an IP that does not
+// resolve to the crawler's domain are excluded from the crawl budget report.
+func (a *Analyser) SetCrawlerVerification(enabled bool) {
+	if enabled && a.crawlerVerifier == nil {
+		a.crawlerVerifier = newCrawlerVerifier()
+	} else if !enabled {
+		a.crawlerVerifier = nil
+	}
+}
+
+// matchSearchEngine returns the search engine crawler matching a user agent
+func matchSearchEngine(userAgent string) *SearchEngineCrawler {
+	ua := strings.ToLower(userAgent)
+	for i := range SearchEngineCrawlers {
+		if strings.Contains(ua, SearchEngineCrawlers[i].Pattern) {
+			return &SearchEngineCrawlers[i]
+		}
+	}
+	return nil
+}
+
+// crawlerVerifier caches forward-confirmed reverse DNS checks per IP
+type crawlerVerifier struct {
+	mu      sync.Mutex
+	results map[string]bool
+}
+
+func newCrawlerVerifier() *crawlerVerifier {
+	return &crawlerVerifier{results: make(map[string]bool)}
+}
+
+// verify checks that ip reverse-resolves to one of the crawler's domains and
+// that the host name resolves back to the same ip
+func (v *crawlerVerifier) verify(ip string, crawler *SearchEngineCrawler) bool {
+	key := crawler.Name + " " + ip
+
+	v.mu.Lock()
+	if verified, cached := v.results[key]; cached {
+		v.mu.Unlock()
+		return verified
+	}
+	v.mu.Unlock()
+
+	verified := false
+	hosts, err := net.LookupAddr(ip)
+	if err == nil {
+		for _, host := range hosts {
+			host = strings.TrimSuffix(strings.ToLower(host), ".")
+			if !hasDomainSuffix(host, crawler.Domains) {
+				continue
+			}
+
+			addrs, err := net.LookupHost(host)
+			if err != nil {
+				continue
+			}
+			for _, addr := range addrs {
+				if addr == ip {
+					verified = true
+				}
+			}
+		}
+	}
+
+	v.mu.Lock()
+	v.results[key] = verified
+	v.mu.Unlock()
+
+	return verified
+}
+
+func hasDomainSuffix(host string, domains []string) bool {
+	for _, domain := range domains {
+		if strings.HasSuffix(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// crawlSection returns the top-level section of a URL path
+func crawlSection(url string) string {
+	if idx := strings.IndexAny(url, "?#"); idx != -1 {
+		url = url[:idx]
+	}
+
+	trimmed := strings.TrimPrefix(url, "/")
+	if idx := strings.Index(trimmed, "/"); idx != -1 {
+		return "/" + trimmed[:idx]
+	}
+	if trimmed == "" || strings.Contains(trimmed, ".") {
+		return "/"
+	}
+	return "/" + trimmed
+}
+
+// crawlBudgetModule builds the crawl budget report from search engine requests
+type crawlBudgetModule struct {
+	analyser       *Analyser
+	total          int
+	unverified     int
+	crawlers       map[string]*CrawlerStat
+	crawlerURLs    map[string]map[string]bool
+	sections       map[string]*CrawlSection
+	sectionURLs    map[string]map[string]bool
+	notFound       map[string]int
+	redirects      map[string]int
+	wastedRedirect int
+	wastedErrors   int
+	wastedParams   int
+	firstSeen      map[string]string
+	dailyRequests  map[string]int
+}
+
+func newCrawlBudgetModule(a *Analyser) *crawlBudgetModule {
+	return &crawlBudgetModule{
+		analyser:      a,
+		crawlers:      make(map[string]*CrawlerStat),
+		crawlerURLs:   make(map[string]map[string]bool),
+		sections:      make(map[string]*CrawlSection),
+		sectionURLs:   make(map[string]map[string]bool),
+		notFound:      make(map[string]int),
+		redirects:     make(map[string]int),
+		firstSeen:     make(map[string]string),
+		dailyRequests: make(map[string]int),
+	}
+}
+
+func (m *crawlBudgetModule) Name() string { return ModuleCrawlBudget }
+
+func (m *crawlBudgetModule) Process(entry *parser.LogEntry) {
+	crawler := matchSearchEngine(entry.UserAgent)
+	if crawler == nil {
+		return
+	}
+	if verifier := m.analyser.crawlerVerifier; verifier != nil && !verifier.verify(entry.IP, crawler) {
+		m.unverified++
+		return
+	}
+
+	m.total++
+
+	stat, exists := m.crawlers[crawler.Name]
+	if !exists {
+		stat = &CrawlerStat{Name: crawler.Name}
+		m.crawlers[crawler.Name] = stat
+		m.crawlerURLs[crawler.Name] = make(map[string]bool)
+	}
+	stat.Requests++
+	stat.Bytes += entry.Size
+	m.crawlerURLs[crawler.Name][entry.URL] = true
+
+	name := crawlSection(entry.URL)
+	section, exists := m.sections[name]
+	if !exists {
+		section = &CrawlSection{Section: name}
+		m.sections[name] = section
+		m.sectionURLs[name] = make(map[string]bool)
+	}
+	section.Requests++
+	m.sectionURLs[name][entry.URL] = true
+
+	wasted := false
+	switch {
+	case entry.Status >= 300 && entry.Status < 400 && entry.Status != 304:
+		stat.Redirects++
+		m.redirects[entry.URL]++
+		m.wastedRedirect++
+		wasted = true
+	case entry.Status >= 400:
+		stat.Errors++
+		if entry.Status == 404 || entry.Status == 410 {
+			m.notFound[entry.URL]++
+		}
+		m.wastedErrors++
+		wasted = true
+	case strings.Contains(entry.URL, "?"):
+		m.wastedParams++
+		wasted = true
+	}
+	if wasted {
+		section.WastedRequests++
+	}
+
+	day := entry.Timestamp.Format("2006-01-02")
+	m.dailyRequests[day]++
+	if first, seen := m.firstSeen[entry.URL]; !seen || day < first {
+		m.firstSeen[entry.URL] = day
+	}
+}
+
+func (m *crawlBudgetModule) Finalize(results *Results) {
+	report := CrawlBudgetReport{
+		TotalRequests:       m.total,
+		Crawlers:            []CrawlerStat{},
+		Sections:            []CrawlSection{},
+		Discoveries:         []CrawlDiscovery{},
+		WastedOnRedirects:   m.wastedRedirect,
+		WastedOnErrors:      m.wastedErrors,
+		WastedOnParameters:  m.wastedParams,
+		VerificationEnabled: m.analyser.crawlerVerifier != nil,
+		UnverifiedRequests:  m.unverified,
+	}
+
+	for name, stat := range m.crawlers {
+		crawler := *stat
+		crawler.UniqueURLs = len(m.crawlerURLs[name])
+		report.Crawlers = append(report.Crawlers, crawler)
+	}
+
+	days := len(m.dailyRequests)
+	for name, stat := range m.sections {
+		section := *stat
+		section.UniqueURLs = len(m.sectionURLs[name])
+		if days > 0 {
+			section.CrawlsPerDay = float64(section.Requests) / float64(days)
+		}
+		report.Sections = append(report.Sections, section)
+	}
+
+	newURLs := make(map[string]int)
+	for _, day := range m.firstSeen {
+		newURLs[day]++
+	}
+	for day, requests := range m.dailyRequests {
+		report.Discoveries = append(report.Discoveries, CrawlDiscovery{Date: day, NewURLs: newURLs[day], Requests: requests})
+	}
+
+	report.NotFoundURLs = sortURLCounts(m.notFound)
+	report.RedirectURLs = sortURLCounts(m.redirects)
+	finishCrawlBudgetReport(&report)
+
+	results.CrawlBudget = report
+}
+
+// finishCrawlBudgetReport sorts and truncates the report lists and computes
+// the wasted budget share
+func finishCrawlBudgetReport(report *CrawlBudgetReport) {
+	if report.TotalRequests > 0 {
+		wasted := report.WastedOnRedirects + report.WastedOnErrors + report.WastedOnParameters
+		report.WastedPercent = float64(wasted) / float64(report.TotalRequests) * 100
+	}
+
+	sort.Slice(report.Crawlers, func(i, j int) bool {
+		if report.Crawlers[i].Requests != report.Crawlers[j].Requests {
+			return report.Crawlers[i].Requests > report.Crawlers[j].Requests
+		}
+		return report.Crawlers[i].Name < report.Crawlers[j].Name
+	})
+
+	sort.Slice(report.Sections, func(i, j int) bool {
+		if report.Sections[i].Requests != report.Sections[j].Requests {
+			return report.Sections[i].Requests > report.Sections[j].Requests
+		}
+		return report.Sections[i].Section < report.Sections[j].Section
+	})
+
+	sort.Slice(report.Discoveries, func(i, j int) bool {
+		return report.Discoveries[i].Date < report.Discoveries[j].Date
+	})
+
+	if len(report.NotFoundURLs) > 20 {
+		report.NotFoundURLs = report.NotFoundURLs[:20]
+	}
+	if len(report.RedirectURLs) > 20 {
+		report.RedirectURLs = report.RedirectURLs[:20]
+	}
+}
+
+// mergeCrawlBudgets combines crawl budget reports from partial results.
+// Unique URL counts and discoveries can only be summed, so URLs seen in
+// both parts are counted twice.
+func mergeCrawlBudgets(x, y CrawlBudgetReport) CrawlBudgetReport {
+	merged := CrawlBudgetReport{
+		TotalRequests:       x.TotalRequests + y.TotalRequests,
+		WastedOnRedirects:   x.WastedOnRedirects + y.WastedOnRedirects,
+		WastedOnErrors:      x.WastedOnErrors + y.WastedOnErrors,
+		WastedOnParameters:  x.WastedOnParameters + y.WastedOnParameters,
+		VerificationEnabled: x.VerificationEnabled || y.VerificationEnabled,
+		UnverifiedRequests:  x.UnverifiedRequests + y.UnverifiedRequests,
+		Crawlers:            []CrawlerStat{},
+		Sections:            []CrawlSection{},
+		Discoveries:         []CrawlDiscovery{},
+	}
+
+	crawlers := make(map[string]*CrawlerStat)
+	for _, stat := range append(append([]CrawlerStat{}, x.Crawlers...), y.Crawlers...) {
+		existing, exists := crawlers[stat.Name]
+		if !exists {
+			copied := stat
+			crawlers[stat.Name] = &copied
+			continue
+		}
+		existing.Requests += stat.Requests
+		existing.UniqueURLs += stat.UniqueURLs
+		existing.Bytes += stat.Bytes
+		existing.Errors += stat.Errors
+		existing.Redirects += stat.Redirects
+	}
+	for _, stat := range crawlers {
+		merged.Crawlers = append(merged.Crawlers, *stat)
+	}
+
+	discoveries := make(map[string]*CrawlDiscovery)
+	for _, discovery := range append(append([]CrawlDiscovery{}, x.Discoveries...), y.Discoveries...) {
+		existing, exists := discoveries[discovery.Date]
+		if !exists {
+			copied := discovery
+			discoveries[discovery.Date] = &copied
+			continue
+		}
+		existing.NewURLs += discovery.NewURLs
+		existing.Requests += discovery.Requests
+	}
+	for _, discovery := range discoveries {
+		merged.Discoveries = append(merged.Discoveries, *discovery)
+	}
+
+	sections := make(map[string]*CrawlSection)
+	for _, section := range append(append([]CrawlSection{}, x.Sections...), y.Sections...) {
+		existing, exists := sections[section.Section]
+		if !exists {
+			copied := section
+			sections[section.Section] = &copied
+			continue
+		}
+		existing.Requests += section.Requests
+		existing.UniqueURLs += section.UniqueURLs
+		existing.WastedRequests += section.WastedRequests
+	}
+	for _, section := range sections {
+		if len(discoveries) > 0 {
+			section.CrawlsPerDay = float64(section.Requests) / float64(len(discoveries))
+		}
+		merged.Sections = append(merged.Sections, *section)
+	}
+
+	notFound := make(map[string]int)
+	for _, url := range append(append([]URLStat{}, x.NotFoundURLs...), y.NotFoundURLs...) {
+		notFound[url.URL] += url.Count
+	}
+	merged.NotFoundURLs = sortURLCounts(notFound)
+
+	redirects := make(map[string]int)
+	for _, url := range append(append([]URLStat{}, x.RedirectURLs...), y.RedirectURLs...) {
+		redirects[url.URL] += url.Count
+	}
+	merged.RedirectURLs = sortURLCounts(redirects)
+
+	finishCrawlBudgetReport(&merged)
+	return merged
+}
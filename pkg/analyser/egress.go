@@ -0,0 +1,253 @@
+package analyser
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// DefaultEgressConfigFile is the egress pricing file name inside the config directory
+const DefaultEgressConfigFile = "egress.yaml"
+
+// bytesPerGB is the unit egress prices are quoted in
+const bytesPerGB = 1024 * 1024 * 1024
+
+// EgressPricing holds data transfer prices per GB. Regions match the client
+// regions reported by the geographic analysis (e.g. "Europe", "CDN/Cloud");
+// traffic from any other region is charged at DefaultPerGB.
+type EgressPricing struct {
+	Currency     string             `yaml:"currency"`
+	DefaultPerGB float64            `yaml:"default_per_gb"`
+	Regions      map[string]float64 `yaml:"regions,omitempty"`
+}
+
+// RegionCost is the transfer volume and cost for one client region
+type RegionCost struct {
+	Region    string
+	Requests  int
+	Bytes     int64
+	RatePerGB float64
+	Cost      float64
+}
+
+// EndpointCost is the transfer volume and cost for one endpoint
+type EndpointCost struct {
+	Endpoint string // Normalised path
+	Requests int
+	Bytes    int64
+	Cost     float64
+}
+
+// EgressCostReport estimates bandwidth cost for the analysed traffic
+type EgressCostReport struct {
+	Currency   string
+	TotalBytes int64
+	TotalCost  float64
+	Regions    []RegionCost
+	Endpoints  []EndpointCost // Most expensive endpoints first
+}
+
+// DefaultEgressPricing returns typical public cloud internet egress prices in USD
+func DefaultEgressPricing() EgressPricing {
+	return EgressPricing{
+		Currency:     "USD",
+		DefaultPerGB: 0.09,
+		Regions: map[string]float64{
+			"North America":   0.085,
+			"Europe":          0.085,
+			"Asia":            0.12,
+			"Oceania":         0.114,
+			"South America":   0.11,
+			"Africa":          0.11,
+			"CDN/Cloud":       0.02,
+			"Private Network": 0,
+		},
+	}
+}
+
+// LoadEgressPricing reads egress prices from a YAML file. If the file does
+// not exist the built-in defaults are returned.
+func LoadEgressPricing(filename string) (EgressPricing, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return DefaultEgressPricing(), nil
+	}
+	if err != nil {
+		return EgressPricing{}, fmt.Errorf("failed to read egress pricing file: %w", err)
+	}
+
+	var pricing EgressPricing
+	if err := yaml.Unmarshal(data, &pricing); err != nil {
+		return EgressPricing{}, fmt.Errorf("failed to parse egress pricing file: %w", err)
+	}
+
+	if pricing.DefaultPerGB < 0 {
+		return EgressPricing{}, fmt.Errorf("default_per_gb must not be negative")
+	}
+	for region, rate := range pricing.Regions {
+		if rate < 0 {
+			return EgressPricing{}, fmt.Errorf("price for region %q must not be negative", region)
+		}
+	}
+	if pricing.Currency == "" {
+		pricing.Currency = "USD"
+	}
+
+	return pricing, nil
+}
+
+// SetEgressPricing enables egress cost estimation with the given prices
+func (a *Analyser) SetEgressPricing(pricing EgressPricing) {
+	a.egressPricing = &pricing
+}
+
+// rateFor returns the price per GB for a client region
+func (p EgressPricing) rateFor(region string) float64 {
+	if rate, exists := p.Regions[region]; exists {
+		return rate
+	}
+	return p.DefaultPerGB
+}
+
+// egressCostModule prices transferred bytes by client region and endpoint
+type egressCostModule struct {
+	analyser  *Analyser
+	pricing   EgressPricing
+	regions   map[string]*RegionCost
+	endpoints map[string]*EndpointCost
+}
+
+func newEgressCostModule(a *Analyser) *egressCostModule {
+	m := &egressCostModule{
+		analyser:  a,
+		regions:   make(map[string]*RegionCost),
+		endpoints: make(map[string]*EndpointCost),
+	}
+	if a.egressPricing != nil {
+		m.pricing = *a.egressPricing
+	}
+	return m
+}
+
+func (m *egressCostModule) Name() string { return ModuleEgressCost }
+
+func (m *egressCostModule) Process(entry *parser.LogEntry) {
+	if m.analyser.egressPricing == nil {
+		return
+	}
+
+	_, region := m.analyser.getIPLocation(entry.IP)
+	rate := m.pricing.rateFor(region)
+	cost := float64(entry.Size) / bytesPerGB * rate
+
+	regionCost, exists := m.regions[region]
+	if !exists {
+		regionCost = &RegionCost{Region: region, RatePerGB: rate}
+		m.regions[region] = regionCost
+	}
+	regionCost.Requests++
+	regionCost.Bytes += entry.Size
+	regionCost.Cost += cost
+
+	endpoint := NormaliseEndpoint(entry.URL)
+	endpointCost, exists := m.endpoints[endpoint]
+	if !exists {
+		endpointCost = &EndpointCost{Endpoint: endpoint}
+		m.endpoints[endpoint] = endpointCost
+	}
+	endpointCost.Requests++
+	endpointCost.Bytes += entry.Size
+	endpointCost.Cost += cost
+}
+
+func (m *egressCostModule) Finalize(results *Results) {
+	if m.analyser.egressPricing == nil {
+		return
+	}
+
+	report := &EgressCostReport{Currency: m.pricing.Currency}
+	for _, region := range m.regions {
+		report.Regions = append(report.Regions, *region)
+	}
+	for _, endpoint := range m.endpoints {
+		report.Endpoints = append(report.Endpoints, *endpoint)
+	}
+	finishEgressCostReport(report)
+
+	results.EgressCost = report
+}
+
+// finishEgressCostReport totals and sorts the report
+func finishEgressCostReport(report *EgressCostReport) {
+	report.TotalBytes = 0
+	report.TotalCost = 0
+	for _, region := range report.Regions {
+		report.TotalBytes += region.Bytes
+		report.TotalCost += region.Cost
+	}
+
+	sort.Slice(report.Regions, func(i, j int) bool {
+		if report.Regions[i].Cost != report.Regions[j].Cost {
+			return report.Regions[i].Cost > report.Regions[j].Cost
+		}
+		return report.Regions[i].Region < report.Regions[j].Region
+	})
+
+	sort.Slice(report.Endpoints, func(i, j int) bool {
+		if report.Endpoints[i].Cost != report.Endpoints[j].Cost {
+			return report.Endpoints[i].Cost > report.Endpoints[j].Cost
+		}
+		return report.Endpoints[i].Endpoint < report.Endpoints[j].Endpoint
+	})
+}
+
+// mergeEgressCosts combines egress reports from partial results
+func mergeEgressCosts(x, y *EgressCostReport) *EgressCostReport {
+	if x == nil {
+		return y
+	}
+	if y == nil {
+		return x
+	}
+
+	merged := &EgressCostReport{Currency: x.Currency}
+
+	regions := make(map[string]*RegionCost)
+	for _, region := range append(append([]RegionCost{}, x.Regions...), y.Regions...) {
+		existing, exists := regions[region.Region]
+		if !exists {
+			copied := region
+			regions[region.Region] = &copied
+			continue
+		}
+		existing.Requests += region.Requests
+		existing.Bytes += region.Bytes
+		existing.Cost += region.Cost
+	}
+	for _, region := range regions {
+		merged.Regions = append(merged.Regions, *region)
+	}
+
+	endpoints := make(map[string]*EndpointCost)
+	for _, endpoint := range append(append([]EndpointCost{}, x.Endpoints...), y.Endpoints...) {
+		existing, exists := endpoints[endpoint.Endpoint]
+		if !exists {
+			copied := endpoint
+			endpoints[endpoint.Endpoint] = &copied
+			continue
+		}
+		existing.Requests += endpoint.Requests
+		existing.Bytes += endpoint.Bytes
+		existing.Cost += endpoint.Cost
+	}
+	for _, endpoint := range endpoints {
+		merged.Endpoints = append(merged.Endpoints, *endpoint)
+	}
+
+	finishEgressCostReport(merged)
+	return merged
+}
@@ -0,0 +1,169 @@
+package analyser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// EndpointStat summarises traffic for a single method + normalised path
+type EndpointStat struct {
+	Method     string
+	Endpoint   string  // Normalised path (query string removed, IDs collapsed)
+	Count      int     // Total requests
+	ErrorCount int     // Requests with 4xx/5xx status
+	ErrorRate  float64 // Percentage of requests resulting in errors
+	P50Size    int64   // Median response size (proxy for latency)
+	P95Size    int64   // 95th percentile response size
+	P99Size    int64   // 99th percentile response size
+	TotalBytes int64   // Total bytes served by the endpoint
+}
+
+// EndpointSortKeys lists the valid keys accepted by SortEndpointStats
+var EndpointSortKeys = []string{"requests", "errors", "error-rate", "p50", "p95", "p99", "bytes"}
+
+var (
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment     = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// endpointKey identifies an endpoint by method and normalised path
+type endpointKey struct {
+	method   string
+	endpoint string
+}
+
+// endpointCounts accumulates the requests of one endpoint
+type endpointCounts struct {
+	errors int
+	bytes  int64
+	sizes  sizeSketch
+}
+
+// endpointModule computes per-endpoint request counts, error rates and
+// size percentiles
+type endpointModule struct {
+	endpoints map[endpointKey]*endpointCounts
+}
+
+func newEndpointModule() *endpointModule {
+	return &endpointModule{endpoints: make(map[endpointKey]*endpointCounts)}
+}
+
+func (m *endpointModule) Name() string { return ModuleEndpoints }
+
+func (m *endpointModule) Process(entry *parser.LogEntry) {
+	key := endpointKey{method: entry.Method, endpoint: NormaliseEndpoint(entry.URL)}
+	counts, exists := m.endpoints[key]
+	if !exists {
+		counts = &endpointCounts{}
+		m.endpoints[key] = counts
+	}
+	counts.sizes.Add(entry.Size)
+	counts.bytes += entry.Size
+	if entry.Status >= 400 {
+		counts.errors++
+	}
+}
+
+func (m *endpointModule) Finalize(results *Results) {
+	var endpointStats []EndpointStat
+	for key, counts := range m.endpoints {
+		count := counts.sizes.Count()
+		endpointStats = append(endpointStats, EndpointStat{
+			Method:     key.method,
+			Endpoint:   key.endpoint,
+			Count:      count,
+			ErrorCount: counts.errors,
+			ErrorRate:  float64(counts.errors) / float64(count) * 100,
+			P50Size:    counts.sizes.Percentile(50),
+			P95Size:    counts.sizes.Percentile(95),
+			P99Size:    counts.sizes.Percentile(99),
+			TotalBytes: counts.bytes,
+		})
+	}
+
+	SortEndpointStats(endpointStats, "requests")
+
+	results.EndpointStats = endpointStats
+}
+
+// SortEndpointStats sorts endpoint statistics in descending order of the given key
+func SortEndpointStats(stats []EndpointStat, by string) error {
+	var value func(s EndpointStat) float64
+
+	switch strings.ToLower(by) {
+	case "", "requests", "count":
+		value = func(s EndpointStat) float64 { return float64(s.Count) }
+	case "errors":
+		value = func(s EndpointStat) float64 { return float64(s.ErrorCount) }
+	case "error-rate":
+		value = func(s EndpointStat) float64 { return s.ErrorRate }
+	case "p50":
+		value = func(s EndpointStat) float64 { return float64(s.P50Size) }
+	case "p95":
+		value = func(s EndpointStat) float64 { return float64(s.P95Size) }
+	case "p99":
+		value = func(s EndpointStat) float64 { return float64(s.P99Size) }
+	case "bytes":
+		value = func(s EndpointStat) float64 { return float64(s.TotalBytes) }
+	default:
+		return fmt.Errorf("unknown sort key %q (valid: %s)", by, strings.Join(EndpointSortKeys, ", "))
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		vi, vj := value(stats[i]), value(stats[j])
+		if vi != vj {
+			return vi > vj
+		}
+		// Keep output deterministic for ties
+		if stats[i].Endpoint != stats[j].Endpoint {
+			return stats[i].Endpoint < stats[j].Endpoint
+		}
+		return stats[i].Method < stats[j].Method
+	})
+
+	return nil
+}
+
+// NormaliseEndpoint strips query strings and collapses ID-like path segments
+// so that /users/42 and /users/43 are reported as the same endpoint
+func NormaliseEndpoint(url string) string {
+	if idx := strings.IndexAny(url, "?#"); idx != -1 {
+		url = url[:idx]
+	}
+
+	if len(url) > 1 {
+		url = strings.TrimSuffix(url, "/")
+	}
+	if url == "" {
+		return "/"
+	}
+
+	segments := strings.Split(url, "/")
+	for i, segment := range segments {
+		if numericSegment.MatchString(segment) || uuidSegment.MatchString(segment) || hexSegment.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// percentileSize returns the p-th percentile from an ascending slice of sizes
+func percentileSize(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	index := len(sorted) * p / 100
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+
+	return sorted[index]
+}
@@ -0,0 +1,335 @@
+package analyser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// GeoIPDatabase maps IP ranges to countries. It is loaded from a CSV range
+// file such as the free DB-IP or IP2Location LITE country databases.
+type GeoIPDatabase struct {
+	ranges []geoIPRange
+}
+
+type geoIPRange struct {
+	start []byte // 16-byte IP, inclusive
+	end   []byte // 16-byte IP, inclusive
+	code  string // ISO 3166-1 alpha-2 country code
+	name  string // Country name, if the database provides one
+}
+
+// CountryStat summarises traffic and errors for a single country
+type CountryStat struct {
+	Country   string
+	Code      string // ISO 3166-1 alpha-2 code, when known from the GeoIP database
+	Region    string
+	Requests  int
+	Errors    int     // 4xx/5xx responses
+	ErrorRate float64 // Percentage of requests resulting in errors
+	Bytes     int64
+	UniqueIPs int
+}
+
+// LoadGeoIPCSV loads a country range database. Each row holds the first IP,
+// the last IP and the country code, optionally followed by the country name.
+// IPs may be written in dotted/colon notation or as integers.
+func LoadGeoIPCSV(filename string) (*GeoIPDatabase, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	db := &GeoIPDatabase{}
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GeoIP database line %d: %w", line, err)
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("GeoIP database line %d: expected start,end,country", line)
+		}
+
+		start, startErr := parseGeoIPAddress(record[0])
+		end, endErr := parseGeoIPAddress(record[1])
+		if startErr != nil || endErr != nil {
+			// Skip header rows
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("GeoIP database line %d: invalid IP range %s-%s", line, record[0], record[1])
+		}
+
+		code := strings.ToUpper(strings.TrimSpace(record[2]))
+		if code == "" || code == "-" || code == "ZZ" {
+			continue
+		}
+
+		entry := geoIPRange{start: start, end: end, code: code}
+		if len(record) > 3 {
+			entry.name = strings.TrimSpace(record[3])
+		}
+		db.ranges = append(db.ranges, entry)
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+
+	if len(db.ranges) == 0 {
+		return nil, fmt.Errorf("GeoIP database %s contains no ranges", filename)
+	}
+
+	return db, nil
+}
+
+// parseGeoIPAddress parses an IP written as text or as an integer into its
+// 16-byte form
+func parseGeoIPAddress(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if ip := net.ParseIP(value); ip != nil {
+		return ip.To16(), nil
+	}
+
+	number, ok := new(big.Int).SetString(value, 10)
+	if !ok || number.Sign() < 0 {
+		return nil, fmt.Errorf("invalid IP %q", value)
+	}
+
+	if number.BitLen() <= 32 {
+		n := number.Uint64()
+		return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).To16(), nil
+	}
+	if number.BitLen() > 128 {
+		return nil, fmt.Errorf("invalid IP %q", value)
+	}
+
+	ip := make([]byte, 16)
+	number.FillBytes(ip)
+	return ip, nil
+}
+
+// Lookup returns the country code and name for an IP
+func (db *GeoIPDatabase) Lookup(ip string) (string, string, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", "", false
+	}
+	key := parsed.To16()
+
+	// Find the last range starting at or before the IP
+	index := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, key) > 0
+	}) - 1
+	if index < 0 || bytes.Compare(key, db.ranges[index].end) > 0 {
+		return "", "", false
+	}
+
+	entry := db.ranges[index]
+	name := entry.name
+	if name == "" {
+		name = entry.code
+	}
+	return entry.code, name, true
+}
+
+// SetGeoIPDatabase uses a GeoIP database for country lookups instead of the
+// built-in prefix heuristics
+func (a *Analyser) SetGeoIPDatabase(db *GeoIPDatabase) {
+	a.geoIP = db
+}
+
+// SetCountryFilter restricts analysis to requests from the given countries.
+// Countries match by code or name, case-insensitively; an empty list
+// disables the filter.
+func (a *Analyser) SetCountryFilter(countries []string) {
+	a.countryFilter = nil
+	for _, country := range countries {
+		country = strings.ToLower(strings.TrimSpace(country))
+		if country != "" {
+			a.countryFilter = append(a.countryFilter, country)
+		}
+	}
+}
+
+// MatchesCountryFilter reports whether requests from an IP pass the country
+// filter, for callers that filter entries as they stream
+func (a *Analyser) MatchesCountryFilter(ip string) bool {
+	return a.matchesCountryFilter(ip)
+}
+
+// countryCode returns the ISO code of an IP's country, or an empty string
+// without a GeoIP database entry for it
+func (a *Analyser) countryCode(ip string) string {
+	if a.geoIP == nil {
+		return ""
+	}
+	code, _, _ := a.geoIP.Lookup(ip)
+	return code
+}
+
+// matchesCountryFilter reports whether an IP passes the country filter
+func (a *Analyser) matchesCountryFilter(ip string) bool {
+	if len(a.countryFilter) == 0 {
+		return true
+	}
+
+	var candidates []string
+	if a.geoIP != nil {
+		if code, name, ok := a.geoIP.Lookup(ip); ok {
+			candidates = append(candidates, code, name)
+		}
+	}
+	country, _ := a.getIPLocation(ip)
+	candidates = append(candidates, country)
+	// Heuristic locations such as "Australia/NZ" cover several countries
+	candidates = append(candidates, strings.Split(country, "/")...)
+
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(strings.TrimSpace(candidate))
+		for _, wanted := range a.countryFilter {
+			if candidate == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterByCountry returns the entries that pass the country filter
+func (a *Analyser) FilterByCountry(logs []*parser.LogEntry) []*parser.LogEntry {
+	if len(a.countryFilter) == 0 {
+		return logs
+	}
+
+	var filtered []*parser.LogEntry
+	for _, log := range logs {
+		if a.matchesCountryFilter(log.IP) {
+			filtered = append(filtered, log)
+		}
+	}
+	return filtered
+}
+
+// countryRegions maps ISO country codes to the regions used in reports
+var countryRegions = map[string]string{
+	"US": "North America", "CA": "North America", "MX": "North America",
+	"BR": "South America", "AR": "South America", "CL": "South America", "CO": "South America", "PE": "South America",
+	"GB": "Europe", "UK": "Europe", "DE": "Europe", "FR": "Europe", "ES": "Europe", "IT": "Europe", "NL": "Europe",
+	"BE": "Europe", "SE": "Europe", "NO": "Europe", "DK": "Europe", "FI": "Europe", "IE": "Europe", "PL": "Europe",
+	"PT": "Europe", "CH": "Europe", "AT": "Europe", "CZ": "Europe", "RO": "Europe", "UA": "Europe", "RU": "Europe",
+	"CN": "Asia", "JP": "Asia", "KR": "Asia", "IN": "Asia", "SG": "Asia", "HK": "Asia", "TW": "Asia", "ID": "Asia",
+	"TH": "Asia", "VN": "Asia", "MY": "Asia", "PH": "Asia", "PK": "Asia", "BD": "Asia", "IL": "Asia", "TR": "Asia",
+	"AE": "Asia", "SA": "Asia", "IR": "Asia",
+	"AU": "Oceania", "NZ": "Oceania", "FJ": "Oceania",
+	"ZA": "Africa", "NG": "Africa", "EG": "Africa", "KE": "Africa", "MA": "Africa",
+}
+
+// countryStatsModule breaks traffic, errors and bandwidth down by country
+type countryStatsModule struct {
+	analyser *Analyser
+	stats    map[string]*CountryStat
+	ips      map[string]map[string]bool
+}
+
+func newCountryStatsModule(a *Analyser) *countryStatsModule {
+	return &countryStatsModule{
+		analyser: a,
+		stats:    make(map[string]*CountryStat),
+		ips:      make(map[string]map[string]bool),
+	}
+}
+
+func (m *countryStatsModule) Name() string { return ModuleCountries }
+
+func (m *countryStatsModule) Process(entry *parser.LogEntry) {
+	country, region := m.analyser.getIPLocation(entry.IP)
+
+	stat, exists := m.stats[country]
+	if !exists {
+		stat = &CountryStat{Country: country, Code: m.analyser.countryCode(entry.IP), Region: region}
+		m.stats[country] = stat
+		m.ips[country] = make(map[string]bool)
+	}
+
+	stat.Requests++
+	stat.Bytes += entry.Size
+	if entry.Status >= 400 {
+		stat.Errors++
+	}
+	m.ips[country][entry.IP] = true
+}
+
+func (m *countryStatsModule) Finalize(results *Results) {
+	stats := []CountryStat{}
+	for country, stat := range m.stats {
+		countryStat := *stat
+		countryStat.UniqueIPs = len(m.ips[country])
+		stats = append(stats, countryStat)
+	}
+
+	results.CountryStats = finishCountryStats(stats)
+}
+
+// finishCountryStats computes error rates and sorts countries by traffic
+func finishCountryStats(stats []CountryStat) []CountryStat {
+	for i := range stats {
+		if stats[i].Requests > 0 {
+			stats[i].ErrorRate = float64(stats[i].Errors) / float64(stats[i].Requests) * 100
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].Country < stats[j].Country
+	})
+
+	return stats
+}
+
+// mergeCountryStats combines per-country statistics. Unique IPs are summed,
+// so IPs seen in both parts are counted twice.
+func mergeCountryStats(x, y []CountryStat) []CountryStat {
+	stats := make(map[string]*CountryStat)
+	for _, stat := range append(append([]CountryStat{}, x...), y...) {
+		existing, exists := stats[stat.Country]
+		if !exists {
+			copied := stat
+			stats[stat.Country] = &copied
+			continue
+		}
+		if existing.Code == "" {
+			existing.Code = stat.Code
+		}
+		existing.Requests += stat.Requests
+		existing.Errors += stat.Errors
+		existing.Bytes += stat.Bytes
+		existing.UniqueIPs += stat.UniqueIPs
+	}
+
+	merged := []CountryStat{}
+	for _, stat := range stats {
+		merged = append(merged, *stat)
+	}
+	return finishCountryStats(merged)
+}
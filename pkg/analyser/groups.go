@@ -0,0 +1,98 @@
+package analyser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LogGroup is a labelled set of log files, such as all logs of one site
+type LogGroup struct {
+	Name  string
+	Files []string
+}
+
+// GroupResult holds the analysis of one log group
+type GroupResult struct {
+	Name      string
+	Files     []string
+	Results   *Results
+	ErrorRate float64 // Percentage of 4xx/5xx responses
+}
+
+// GroupedResults holds per-group results and their combined roll-up
+type GroupedResults struct {
+	SchemaVersion int `json:"schema_version"`
+	Combined      *Results
+	Groups        []GroupResult
+}
+
+// ParseLogGroup parses a group definition of the form "name=file1,file2"
+func ParseLogGroup(spec string) (LogGroup, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return LogGroup{}, fmt.Errorf("invalid group %q (expected name=file1,file2)", spec)
+	}
+
+	group := LogGroup{Name: strings.TrimSpace(parts[0])}
+	if group.Name == "" {
+		return LogGroup{}, fmt.Errorf("invalid group %q: name is empty", spec)
+	}
+
+	for _, file := range strings.Split(parts[1], ",") {
+		file = strings.TrimSpace(file)
+		if file != "" {
+			group.Files = append(group.Files, file)
+		}
+	}
+	if len(group.Files) == 0 {
+		return LogGroup{}, fmt.Errorf("invalid group %q: no files given", spec)
+	}
+
+	return group, nil
+}
+
+// ParseLogGroups parses several group definitions, rejecting duplicate names
+func ParseLogGroups(specs []string) ([]LogGroup, error) {
+	var groups []LogGroup
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		group, err := ParseLogGroup(spec)
+		if err != nil {
+			return nil, err
+		}
+		if seen[group.Name] {
+			return nil, fmt.Errorf("group %q is defined more than once", group.Name)
+		}
+		seen[group.Name] = true
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// NewGroupResult wraps the results of one group
+func NewGroupResult(group LogGroup, results *Results) GroupResult {
+	return GroupResult{
+		Name:      group.Name,
+		Files:     group.Files,
+		Results:   results,
+		ErrorRate: errorRate(results),
+	}
+}
+
+// NewGroupedResults wraps the combined roll-up and per-group results for export
+func NewGroupedResults(combined *Results, groups []GroupResult) GroupedResults {
+	return GroupedResults{
+		SchemaVersion: ResultsSchemaVersion,
+		Combined:      combined,
+		Groups:        groups,
+	}
+}
+
+// RollUp merges the results of every group into a combined report
+func RollUp(groups []GroupResult) *Results {
+	parts := make([]*Results, 0, len(groups))
+	for _, group := range groups {
+		parts = append(parts, group.Results)
+	}
+	return MergeResults(parts...)
+}
@@ -0,0 +1,200 @@
+package analyser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// SizeBucket counts responses whose size falls in [Min, Max)
+type SizeBucket struct {
+	Label string
+	Min   int64 // Inclusive lower bound in bytes
+	Max   int64 // Exclusive upper bound in bytes, 0 for the open-ended last bucket
+	Count int
+	Bytes int64
+}
+
+// DefaultSizeBucketBounds are the upper bounds of the default histogram buckets
+var DefaultSizeBucketBounds = []int64{1024, 10 * 1024, 100 * 1024, 1024 * 1024, 10 * 1024 * 1024}
+
+// SetSizeBuckets sets the upper bounds of the response size histogram.
+// An empty list restores the defaults.
+func (a *Analyser) SetSizeBuckets(bounds []int64) {
+	if len(bounds) == 0 {
+		a.sizeBuckets = DefaultSizeBucketBounds
+		return
+	}
+
+	unique := make(map[int64]bool)
+	a.sizeBuckets = nil
+	for _, bound := range bounds {
+		if bound > 0 && !unique[bound] {
+			unique[bound] = true
+			a.sizeBuckets = append(a.sizeBuckets, bound)
+		}
+	}
+	sort.Slice(a.sizeBuckets, func(i, j int) bool {
+		return a.sizeBuckets[i] < a.sizeBuckets[j]
+	})
+}
+
+// ParseSizeBuckets parses a comma-separated list of bucket bounds such as
+// "1KB,10KB,100KB,1MB". Plain numbers are treated as bytes.
+func ParseSizeBuckets(spec string) ([]int64, error) {
+	var bounds []int64
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		bound, err := ParseByteSize(part)
+		if err != nil {
+			return nil, err
+		}
+		bounds = append(bounds, bound)
+	}
+
+	if len(bounds) == 0 {
+		return nil, fmt.Errorf("no size bucket bounds given")
+	}
+
+	return bounds, nil
+}
+
+// ParseByteSize parses a size such as "512B", "10KB" or "1.5GB"
+func ParseByteSize(value string) (int64, error) {
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"B", 1},
+	}
+
+	upper := strings.ToUpper(value)
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(upper, 64)
+	if err != nil || number <= 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 512B, 10KB, 1.5MB)", value)
+	}
+
+	return int64(number * float64(multiplier)), nil
+}
+
+// newSizeHistogram creates empty buckets for the given upper bounds, plus an
+// open-ended bucket for anything larger
+func newSizeHistogram(bounds []int64) []SizeBucket {
+	buckets := make([]SizeBucket, 0, len(bounds)+1)
+
+	var min int64
+	for _, bound := range bounds {
+		buckets = append(buckets, SizeBucket{
+			Label: fmt.Sprintf("%s-%s", formatBucketSize(min), formatBucketSize(bound)),
+			Min:   min,
+			Max:   bound,
+		})
+		min = bound
+	}
+	buckets = append(buckets, SizeBucket{
+		Label: fmt.Sprintf("%s+", formatBucketSize(min)),
+		Min:   min,
+	})
+
+	if len(bounds) > 0 {
+		buckets[0].Label = fmt.Sprintf("< %s", formatBucketSize(bounds[0]))
+	}
+
+	return buckets
+}
+
+// formatBucketSize renders a bucket bound compactly (512B, 10KB, 1MB)
+func formatBucketSize(bytes int64) string {
+	switch {
+	case bytes >= 1024*1024*1024 && bytes%(1024*1024*1024) == 0:
+		return fmt.Sprintf("%dGB", bytes/(1024*1024*1024))
+	case bytes >= 1024*1024 && bytes%(1024*1024) == 0:
+		return fmt.Sprintf("%dMB", bytes/(1024*1024))
+	case bytes >= 1024 && bytes%1024 == 0:
+		return fmt.Sprintf("%dKB", bytes/1024)
+	default:
+		return fmt.Sprintf("%dB", bytes)
+	}
+}
+
+// sizeHistogramModule counts responses into the configured size buckets
+type sizeHistogramModule struct {
+	buckets []SizeBucket
+}
+
+func newSizeHistogramModule(a *Analyser) *sizeHistogramModule {
+	bounds := a.sizeBuckets
+	if len(bounds) == 0 {
+		bounds = DefaultSizeBucketBounds
+	}
+	return &sizeHistogramModule{buckets: newSizeHistogram(bounds)}
+}
+
+func (m *sizeHistogramModule) Name() string { return ModuleSizeHistogram }
+
+func (m *sizeHistogramModule) Process(entry *parser.LogEntry) {
+	for i := range m.buckets {
+		if m.buckets[i].Max == 0 || entry.Size < m.buckets[i].Max {
+			m.buckets[i].Count++
+			m.buckets[i].Bytes += entry.Size
+			return
+		}
+	}
+}
+
+func (m *sizeHistogramModule) Finalize(results *Results) {
+	results.SizeHistogram = append([]SizeBucket{}, m.buckets...)
+}
+
+// mergeSizeHistograms sums buckets with matching bounds, keeping them in size order
+func mergeSizeHistograms(x, y []SizeBucket) []SizeBucket {
+	type bucketKey struct {
+		min int64
+		max int64
+	}
+
+	buckets := make(map[bucketKey]*SizeBucket)
+	for _, bucket := range append(append([]SizeBucket{}, x...), y...) {
+		key := bucketKey{min: bucket.Min, max: bucket.Max}
+		if existing, exists := buckets[key]; exists {
+			existing.Count += bucket.Count
+			existing.Bytes += bucket.Bytes
+			continue
+		}
+		copied := bucket
+		buckets[key] = &copied
+	}
+
+	merged := []SizeBucket{}
+	for _, bucket := range buckets {
+		merged = append(merged, *bucket)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Min != merged[j].Min {
+			return merged[i].Min < merged[j].Min
+		}
+		// Open-ended buckets sort last
+		return merged[j].Max == 0 || (merged[i].Max != 0 && merged[i].Max < merged[j].Max)
+	})
+
+	return merged
+}
@@ -0,0 +1,187 @@
+package analyser
+
+import (
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// UserAgentStat counts requests made with a single user agent
+type UserAgentStat struct {
+	UserAgent string
+	Count     int
+}
+
+// IPTimelineBucket counts an IP's, or a URL's, requests within one hour
+type IPTimelineBucket struct {
+	Hour     time.Time
+	Requests int
+	Errors   int
+	Bytes    int64
+}
+
+// IPProfile is a full drill-down of a single client's activity
+type IPProfile struct {
+	IP            string
+	TotalRequests int
+	TotalBytes    int64
+	FirstSeen     time.Time
+	LastSeen      time.Time
+	Country       string
+	Region        string
+	StatusCodes   []DetailedStatusCode
+	Methods       []MethodStat
+	URLs          []URLStat
+	UserAgents    []UserAgentStat
+	Timeline      []IPTimelineBucket
+	Threats       []SecurityThreat
+	ThreatSummary *IPThreatAnalysis // nil when the IP was not flagged as suspicious
+}
+
+// ProfileIP builds a drill-down profile for a single IP address. It returns
+// nil when the IP made no requests in the selected time window.
+func (a *Analyser) ProfileIP(logs []*parser.LogEntry, ip string, since, until *time.Time) *IPProfile {
+	var ipLogs []*parser.LogEntry
+	for _, log := range a.FilterByTime(logs, since, until) {
+		if log.IP == ip {
+			ipLogs = append(ipLogs, log)
+		}
+	}
+
+	if len(ipLogs) == 0 {
+		return nil
+	}
+
+	return a.profileIP(ip, ipLogs)
+}
+
+// ProfileIPs builds drill-down profiles for several IP addresses in a single
+// pass over the logs, keyed by IP. IPs that made no requests are left out.
+func (a *Analyser) ProfileIPs(logs []*parser.LogEntry, ips []string) map[string]*IPProfile {
+	ipLogs := make(map[string][]*parser.LogEntry, len(ips))
+	for _, ip := range ips {
+		ipLogs[ip] = nil
+	}
+	for _, log := range logs {
+		if entries, ok := ipLogs[log.IP]; ok {
+			ipLogs[log.IP] = append(entries, log)
+		}
+	}
+
+	profiles := make(map[string]*IPProfile)
+	for ip, entries := range ipLogs {
+		if len(entries) > 0 {
+			profiles[ip] = a.profileIP(ip, entries)
+		}
+	}
+	return profiles
+}
+
+// profileIP builds the profile of an IP from all of its requests
+func (a *Analyser) profileIP(ip string, ipLogs []*parser.LogEntry) *IPProfile {
+	sort.SliceStable(ipLogs, func(i, j int) bool {
+		return ipLogs[i].Timestamp.Before(ipLogs[j].Timestamp)
+	})
+
+	timeRange := a.calculateTimeRange(ipLogs)
+	country, region := a.getIPLocation(ip)
+
+	profile := &IPProfile{
+		IP:            ip,
+		TotalRequests: len(ipLogs),
+		TotalBytes:    a.calculateTotalBytes(ipLogs),
+		FirstSeen:     timeRange.Start,
+		LastSeen:      timeRange.End,
+		Country:       country,
+		Region:        region,
+		StatusCodes:   a.analyseDetailedStatusCodes(ipLogs),
+		Methods:       a.analyseHTTPMethods(ipLogs),
+		URLs:          a.analyseIPURLs(ipLogs),
+		UserAgents:    analyseUserAgents(ipLogs),
+		Timeline:      buildIPTimeline(ipLogs),
+	}
+
+	security := a.analyseSecurityThreats(ipLogs)
+	profile.Threats = security.ThreatsDetected
+	if len(security.SuspiciousIPs) > 0 {
+		summary := security.SuspiciousIPs[0]
+		profile.ThreatSummary = &summary
+	}
+
+	return profile
+}
+
+// analyseIPURLs lists every URL with its status code breakdown
+func (a *Analyser) analyseIPURLs(logs []*parser.LogEntry) []URLStat {
+	urlStatuses := make(map[string]map[int]int)
+	urlCounts := make(map[string]int)
+
+	for _, log := range logs {
+		if urlStatuses[log.URL] == nil {
+			urlStatuses[log.URL] = make(map[int]int)
+		}
+		urlStatuses[log.URL][log.Status]++
+		urlCounts[log.URL]++
+	}
+
+	var urlStats []URLStat
+	for url, count := range urlCounts {
+		urlStats = append(urlStats, URLStat{
+			URL:         url,
+			Count:       count,
+			StatusCodes: urlStatuses[url],
+		})
+	}
+
+	sort.Slice(urlStats, func(i, j int) bool {
+		if urlStats[i].Count != urlStats[j].Count {
+			return urlStats[i].Count > urlStats[j].Count
+		}
+		return urlStats[i].URL < urlStats[j].URL
+	})
+
+	return urlStats
+}
+
+func analyseUserAgents(logs []*parser.LogEntry) []UserAgentStat {
+	agentCounts := make(map[string]int)
+	for _, log := range logs {
+		agentCounts[log.UserAgent]++
+	}
+
+	var agentStats []UserAgentStat
+	for agent, count := range agentCounts {
+		agentStats = append(agentStats, UserAgentStat{UserAgent: agent, Count: count})
+	}
+
+	sort.Slice(agentStats, func(i, j int) bool {
+		if agentStats[i].Count != agentStats[j].Count {
+			return agentStats[i].Count > agentStats[j].Count
+		}
+		return agentStats[i].UserAgent < agentStats[j].UserAgent
+	})
+
+	return agentStats
+}
+
+// buildIPTimeline buckets time-ordered logs into hourly activity
+func buildIPTimeline(logs []*parser.LogEntry) []IPTimelineBucket {
+	var timeline []IPTimelineBucket
+
+	for _, log := range logs {
+		hour := log.Timestamp.Truncate(time.Hour)
+		if len(timeline) == 0 || !timeline[len(timeline)-1].Hour.Equal(hour) {
+			timeline = append(timeline, IPTimelineBucket{Hour: hour})
+		}
+
+		bucket := &timeline[len(timeline)-1]
+		bucket.Requests++
+		bucket.Bytes += log.Size
+		if log.Status >= 400 {
+			bucket.Errors++
+		}
+	}
+
+	return timeline
+}
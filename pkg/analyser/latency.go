@@ -0,0 +1,205 @@
+package analyser
+
+import (
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Latency heatmap settings
+const (
+	heatmapEndpoints       = 10  // Busiest endpoints shown as heatmap rows
+	heatmapMinSamples      = 5   // Timed requests a cell needs before it can be flagged
+	heatmapRegressionRatio = 1.5 // Cell P95 above the row P95 times this is a regression
+)
+
+// LatencyCell is the P95 request time of one endpoint during one hour of the day
+type LatencyCell struct {
+	Requests  int
+	P95       time.Duration
+	Regressed bool // P95 well above the endpoint's P95 over all hours
+}
+
+// LatencyHeatmap shows P95 request times per endpoint and hour of day, built
+// from $request_time when the log format includes it
+type LatencyHeatmap struct {
+	TimedRequests  int
+	OverallP95     time.Duration
+	Endpoints      []string        // Busiest endpoints by timed requests
+	EndpointP95    []time.Duration // P95 of each endpoint over all hours
+	Cells          [][]LatencyCell // Indexed by endpoint, then hour (0-23)
+	HourP95        []time.Duration // P95 of all timed requests per hour
+	RegressedHours []int           // Hours whose P95 regresses against OverallP95
+
+	samples map[string][][]time.Duration // Endpoint -> hour -> request times, kept for merging
+}
+
+// RequestTimes returns every timed request's duration. It returns nil for a
+// heatmap loaded from an export, which keeps only the percentiles.
+func (h *LatencyHeatmap) RequestTimes() []time.Duration {
+	if h == nil {
+		return nil
+	}
+
+	var times []time.Duration
+	for _, hours := range h.samples {
+		for _, hourTimes := range hours {
+			times = append(times, hourTimes...)
+		}
+	}
+	return times
+}
+
+// latencyHeatmapModule collects request times per endpoint and hour
+type latencyHeatmapModule struct {
+	samples map[string][][]time.Duration
+}
+
+func newLatencyHeatmapModule() *latencyHeatmapModule {
+	return &latencyHeatmapModule{samples: make(map[string][][]time.Duration)}
+}
+
+func (m *latencyHeatmapModule) Name() string { return ModuleLatencyHeatmap }
+
+func (m *latencyHeatmapModule) Process(entry *parser.LogEntry) {
+	if !entry.HasRequestTime {
+		return
+	}
+
+	endpoint := entry.Method + " " + NormaliseEndpoint(entry.URL)
+	hours, exists := m.samples[endpoint]
+	if !exists {
+		hours = make([][]time.Duration, 24)
+		m.samples[endpoint] = hours
+	}
+	hour := entry.Timestamp.Hour()
+	hours[hour] = append(hours[hour], entry.RequestTime)
+}
+
+func (m *latencyHeatmapModule) Finalize(results *Results) {
+	results.LatencyHeatmap = buildLatencyHeatmap(m.samples)
+}
+
+// buildLatencyHeatmap computes P95 request times per endpoint and hour and
+// flags the cells and hours that regress. It returns nil without timing data.
+func buildLatencyHeatmap(samples map[string][][]time.Duration) *LatencyHeatmap {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	heatmap := &LatencyHeatmap{samples: samples}
+
+	type endpointCount struct {
+		endpoint string
+		count    int
+	}
+	var counts []endpointCount
+	var all []time.Duration
+	hourly := make([][]time.Duration, 24)
+	for endpoint, hours := range samples {
+		count := 0
+		for hour, times := range hours {
+			count += len(times)
+			all = append(all, times...)
+			hourly[hour] = append(hourly[hour], times...)
+		}
+		counts = append(counts, endpointCount{endpoint, count})
+	}
+	heatmap.TimedRequests = len(all)
+	heatmap.OverallP95 = percentileDuration(all, 95)
+
+	// Hours where the site as a whole slows down
+	heatmap.HourP95 = make([]time.Duration, 24)
+	for hour, times := range hourly {
+		heatmap.HourP95[hour] = percentileDuration(times, 95)
+		if len(times) >= heatmapMinSamples && float64(heatmap.HourP95[hour]) > float64(heatmap.OverallP95)*heatmapRegressionRatio {
+			heatmap.RegressedHours = append(heatmap.RegressedHours, hour)
+		}
+	}
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].endpoint < counts[j].endpoint
+	})
+	if len(counts) > heatmapEndpoints {
+		counts = counts[:heatmapEndpoints]
+	}
+
+	for _, count := range counts {
+		hours := samples[count.endpoint]
+		var endpointTimes []time.Duration
+		for _, times := range hours {
+			endpointTimes = append(endpointTimes, times...)
+		}
+		endpointP95 := percentileDuration(endpointTimes, 95)
+
+		row := make([]LatencyCell, 24)
+		for hour, times := range hours {
+			row[hour] = LatencyCell{
+				Requests: len(times),
+				P95:      percentileDuration(times, 95),
+			}
+			row[hour].Regressed = len(times) >= heatmapMinSamples &&
+				float64(row[hour].P95) > float64(endpointP95)*heatmapRegressionRatio
+		}
+
+		heatmap.Endpoints = append(heatmap.Endpoints, count.endpoint)
+		heatmap.EndpointP95 = append(heatmap.EndpointP95, endpointP95)
+		heatmap.Cells = append(heatmap.Cells, row)
+	}
+
+	return heatmap
+}
+
+// percentileDuration returns the p-th percentile of request times
+func percentileDuration(times []time.Duration, p int) time.Duration {
+	if len(times) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration{}, times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	index := len(sorted) * p / 100
+	if index >= len(sorted) {
+		index = len(sorted) - 1
+	}
+	return sorted[index]
+}
+
+// mergeLatencyHeatmaps combines heatmaps by pooling their request times.
+// Heatmaps without samples (e.g. loaded from an export) cannot be pooled, so
+// the one covering more timed requests is kept.
+func mergeLatencyHeatmaps(x, y *LatencyHeatmap) *LatencyHeatmap {
+	if x == nil {
+		return y
+	}
+	if y == nil {
+		return x
+	}
+
+	if x.samples == nil || y.samples == nil {
+		if y.TimedRequests > x.TimedRequests {
+			return y
+		}
+		return x
+	}
+
+	samples := make(map[string][][]time.Duration)
+	for _, part := range []map[string][][]time.Duration{x.samples, y.samples} {
+		for endpoint, hours := range part {
+			merged, exists := samples[endpoint]
+			if !exists {
+				merged = make([][]time.Duration, 24)
+				samples[endpoint] = merged
+			}
+			for hour, times := range hours {
+				merged[hour] = append(merged[hour], times...)
+			}
+		}
+	}
+	return buildLatencyHeatmap(samples)
+}
@@ -0,0 +1,506 @@
+package analyser
+
+import (
+	"sort"
+)
+
+// Merge combines another set of results into r, so partial results from
+// parallel workers or separate runs can be reported together.
+//
+// Counts, totals, time ranges and per-key breakdowns are merged exactly.
+// Size percentiles cannot be recombined without the raw sizes, so they are
+// approximated by a request-weighted average of the two inputs. Lists that
+// are truncated during analysis (error URLs, broken links) are merged from
+// their retained entries only, and suspicious IP profiles only cover the
+// parts in which the IP was flagged.
+func (r *Results) Merge(other *Results) {
+	if other == nil || other.TotalRequests == 0 {
+		return
+	}
+	if r.TotalRequests == 0 {
+		*r = *other.clone()
+		return
+	}
+
+	a := &Analyser{}
+	totalBefore := r.TotalRequests
+
+	// Overview
+	if other.TimeRange.Start.Before(r.TimeRange.Start) {
+		r.TimeRange.Start = other.TimeRange.Start
+	}
+	if other.TimeRange.End.After(r.TimeRange.End) {
+		r.TimeRange.End = other.TimeRange.End
+	}
+	r.TotalRequests += other.TotalRequests
+	r.TotalBytes += other.TotalBytes
+	r.AverageSize = r.TotalBytes / int64(r.TotalRequests)
+	r.BotRequests += other.BotRequests
+	r.HumanRequests += other.HumanRequests
+
+	// Status codes
+	if r.StatusCodes == nil {
+		r.StatusCodes = make(map[string]int)
+	}
+	for class, count := range other.StatusCodes {
+		r.StatusCodes[class] += count
+	}
+	codes := make(map[int]int)
+	for _, status := range r.DetailedStatusCodes {
+		codes[status.Code] += status.Count
+	}
+	for _, status := range other.DetailedStatusCodes {
+		codes[status.Code] += status.Count
+	}
+	r.DetailedStatusCodes = sortDetailedStatusCodes(codes)
+
+	// Top lists are complete, so uniques can be recomputed from them
+	ips := make(map[string]int)
+	for _, ip := range append(r.TopIPs, other.TopIPs...) {
+		ips[ip.IP] += ip.Count
+	}
+	r.TopIPs = sortIPCounts(ips)
+	r.UniqueIPs = len(ips)
+
+	urls := make(map[string]int)
+	for _, url := range append(r.TopURLs, other.TopURLs...) {
+		urls[url.URL] += url.Count
+	}
+	r.TopURLs = sortURLCounts(urls)
+	r.UniqueURLs = len(urls)
+
+	methods := make(map[string]int)
+	for _, method := range append(r.HTTPMethods, other.HTTPMethods...) {
+		methods[method.Method] += method.Count
+	}
+	r.HTTPMethods = sortMethodCounts(methods)
+	r.MethodDetails = mergeMethodDetails(r.MethodDetails, other.MethodDetails)
+
+	bots := make(map[string]int)
+	for _, bot := range append(r.TopBots, other.TopBots...) {
+		bots[bot.BotName] += bot.Count
+	}
+	r.TopBots = sortBotCounts(bots)
+
+	fileTypeCounts := make(map[string]int)
+	fileTypeSizes := make(map[string]int64)
+	for _, fileType := range append(r.FileTypes, other.FileTypes...) {
+		fileTypeCounts[fileType.FileType] += fileType.Count
+		fileTypeSizes[fileType.FileType] += fileType.Size
+	}
+	r.FileTypes = sortFileTypeCounts(fileTypeCounts, fileTypeSizes)
+
+	errorData := make(map[string]map[int]int)
+	for _, url := range append(r.ErrorURLs, other.ErrorURLs...) {
+		if errorData[url.URL] == nil {
+			errorData[url.URL] = make(map[int]int)
+		}
+		for status, count := range url.StatusCodes {
+			errorData[url.URL][status] += count
+		}
+	}
+	r.ErrorURLs = sortErrorURLs(errorData, 10)
+
+	// Hourly traffic, peaks and traffic stats
+	hourCounts := make(map[int]int)
+	hourTimestamps := make(map[int]string)
+	for _, hour := range append(r.HourlyTraffic, other.HourlyTraffic...) {
+		hourCounts[hour.Hour] += hour.RequestCount
+		if existing, ok := hourTimestamps[hour.Hour]; !ok || hour.Timestamp < existing {
+			hourTimestamps[hour.Hour] = hour.Timestamp
+		}
+	}
+	for day := range r.WeekdayHourTraffic {
+		for hour := range r.WeekdayHourTraffic[day] {
+			r.WeekdayHourTraffic[day][hour] += other.WeekdayHourTraffic[day][hour]
+		}
+	}
+	previousPeaks := append(append([]TrafficPeak{}, r.TrafficPeaks...), other.TrafficPeaks...)
+	r.HourlyTraffic = buildHourlyTraffic(hourCounts, hourTimestamps)
+	r.TrafficPeaks = a.detectTrafficPeaks(r.HourlyTraffic)
+	for i := range r.TrafficPeaks {
+		peak := &r.TrafficPeaks[i]
+		otherHours := len(r.HourlyTraffic) - 1
+		spikeExcess := float64(peak.RequestCount) - float64(r.TotalRequests-peak.RequestCount)/float64(otherHours)
+		for _, previous := range previousPeaks {
+			if previous.Hour == peak.Hour {
+				peak.Attribution = mergeSpikeAttributions(peak.Attribution, previous.Attribution, spikeExcess)
+			}
+		}
+	}
+	r.AverageRequestsPerHour, r.PeakHour, r.QuietestHour = a.calculateTrafficStats(r.HourlyTraffic)
+
+	// Response sizes
+	r.LargeRequests = mergeURLSizes(r.LargeRequests, other.LargeRequests, true)
+	r.ResponseTimeStats = mergeResponseTimeStats(r.ResponseTimeStats, other.ResponseTimeStats, totalBefore, other.TotalRequests)
+	r.ResponseTimeStats.AverageSize = r.AverageSize
+	r.SizeHistogram = mergeSizeHistograms(r.SizeHistogram, other.SizeHistogram)
+
+	// Geography
+	r.GeographicAnalysis = a.mergeGeographicAnalysis(r.GeographicAnalysis, other.GeographicAnalysis)
+
+	// Security
+	r.SecurityAnalysis = a.mergeSecurityAnalysis(r.SecurityAnalysis, other.SecurityAnalysis, r.TotalRequests, ips)
+	r.SecurityAnalysis.AnomaliesDetected = a.detectStatusAnomalies(r.TotalRequests, codes, r.SecurityAnalysis.Baselines)
+
+	// Extended breakdowns
+	r.EndpointStats = mergeEndpointStats(r.EndpointStats, other.EndpointStats)
+	r.Protocols = mergeProtocolStats(r.Protocols, other.Protocols)
+	r.BrokenLinks = mergeBrokenLinks(r.BrokenLinks, other.BrokenLinks)
+	r.RateLimitCandidates = mergeRateLimitCandidates(r.RateLimitCandidates, other.RateLimitCandidates)
+	r.CrawlBudget = mergeCrawlBudgets(r.CrawlBudget, other.CrawlBudget)
+	r.EgressCost = mergeEgressCosts(r.EgressCost, other.EgressCost)
+	r.CountryStats = mergeCountryStats(r.CountryStats, other.CountryStats)
+	r.StaticDynamic = mergeStaticDynamicReports(r.StaticDynamic, other.StaticDynamic)
+	r.Capacity = mergeCapacityStats(r.Capacity, other.Capacity)
+	r.DataVolume = mergeVolumeReports(r.DataVolume, other.DataVolume)
+	r.LatencyHeatmap = mergeLatencyHeatmaps(r.LatencyHeatmap, other.LatencyHeatmap)
+	r.Timeline = mergeTimelines(r.Timeline, other.Timeline)
+	r.SessionLengths = append(r.SessionLengths, other.SessionLengths...)
+
+	if r.Extensions == nil {
+		r.Extensions = make(map[string]interface{})
+	}
+	for name, value := range other.Extensions {
+		if _, exists := r.Extensions[name]; !exists {
+			r.Extensions[name] = value
+		}
+	}
+}
+
+// MergeResults combines any number of partial results into a new Results
+func MergeResults(parts ...*Results) *Results {
+	merged := newEmptyResults()
+	for _, part := range parts {
+		merged.Merge(part)
+	}
+	return merged
+}
+
+// clone returns a copy of r whose maps can be modified independently
+func (r *Results) clone() *Results {
+	copied := *r
+
+	copied.StatusCodes = make(map[string]int, len(r.StatusCodes))
+	for class, count := range r.StatusCodes {
+		copied.StatusCodes[class] = count
+	}
+
+	copied.Extensions = make(map[string]interface{}, len(r.Extensions))
+	for name, value := range r.Extensions {
+		copied.Extensions[name] = value
+	}
+
+	copied.ErrorURLs = make([]URLStat, len(r.ErrorURLs))
+	for i, url := range r.ErrorURLs {
+		copied.ErrorURLs[i] = url
+		copied.ErrorURLs[i].StatusCodes = make(map[int]int, len(url.StatusCodes))
+		for status, count := range url.StatusCodes {
+			copied.ErrorURLs[i].StatusCodes[status] = count
+		}
+	}
+
+	return &copied
+}
+
+func mergeURLSizes(a, b []URLStat, largest bool) []URLStat {
+	sizes := make(map[string]int64)
+	for _, url := range append(append([]URLStat{}, a...), b...) {
+		current, exists := sizes[url.URL]
+		if !exists || (largest && int64(url.Count) > current) || (!largest && int64(url.Count) < current) {
+			sizes[url.URL] = int64(url.Count)
+		}
+	}
+	return sortURLSizes(sizes, largest, 10)
+}
+
+func mergeResponseTimeStats(a, b ResponseTimeStats, countA, countB int) ResponseTimeStats {
+	weighted := func(x, y int64) int64 {
+		return (x*int64(countA) + y*int64(countB)) / int64(countA+countB)
+	}
+
+	merged := ResponseTimeStats{
+		MedianSize:   weighted(a.MedianSize, b.MedianSize),
+		P95Size:      weighted(a.P95Size, b.P95Size),
+		P99Size:      weighted(a.P99Size, b.P99Size),
+		MinSize:      a.MinSize,
+		MaxSize:      a.MaxSize,
+		SlowRequests: mergeURLSizes(a.SlowRequests, b.SlowRequests, true),
+		FastRequests: mergeURLSizes(a.FastRequests, b.FastRequests, false),
+	}
+	if b.MinSize < merged.MinSize {
+		merged.MinSize = b.MinSize
+	}
+	if b.MaxSize > merged.MaxSize {
+		merged.MaxSize = b.MaxSize
+	}
+
+	return merged
+}
+
+func (a *Analyser) mergeGeographicAnalysis(x, y GeographicAnalysis) GeographicAnalysis {
+	countries := make(map[string]int)
+	for _, country := range append(append([]GeographicStat{}, x.TopCountries...), y.TopCountries...) {
+		countries[country.Country] += country.Count
+	}
+
+	regions := make(map[string]int)
+	for _, region := range append(append([]GeographicStat{}, x.TopRegions...), y.TopRegions...) {
+		regions[region.Country] += region.Count
+	}
+
+	return a.buildGeographicAnalysis(countries, regions,
+		x.LocalTraffic+y.LocalTraffic, x.CloudTraffic+y.CloudTraffic, x.UnknownIPs+y.UnknownIPs)
+}
+
+// mergeSecurityAnalysis combines threat findings. Request counts for
+// suspicious IPs are taken from the merged per-IP totals, since an IP is
+// only profiled in the parts where it triggered a detection.
+func (a *Analyser) mergeSecurityAnalysis(x, y SecurityAnalysis, totalRequests int, ipCounts map[string]int) SecurityAnalysis {
+	merged := SecurityAnalysis{
+		Baselines:            x.Baselines,
+		ThreatsDetected:      append(append([]SecurityThreat{}, x.ThreatsDetected...), y.ThreatsDetected...),
+		BruteForceAttempts:   x.BruteForceAttempts + y.BruteForceAttempts,
+		SQLInjectionAttempts: x.SQLInjectionAttempts + y.SQLInjectionAttempts,
+		XSSAttempts:          x.XSSAttempts + y.XSSAttempts,
+		DirectoryTraversal:   x.DirectoryTraversal + y.DirectoryTraversal,
+		ScanningActivity:     x.ScanningActivity + y.ScanningActivity,
+		MethodProbing:        x.MethodProbing + y.MethodProbing,
+	}
+	merged.TotalThreats = len(merged.ThreatsDetected)
+
+	sort.SliceStable(merged.ThreatsDetected, func(i, j int) bool {
+		return merged.ThreatsDetected[i].Timestamp.Before(merged.ThreatsDetected[j].Timestamp)
+	})
+
+	// Combine per-IP threat profiles
+	ipStats := make(map[string]*IPThreatAnalysis)
+	var order []string
+	for _, ip := range append(append([]IPThreatAnalysis{}, x.SuspiciousIPs...), y.SuspiciousIPs...) {
+		existing, exists := ipStats[ip.IP]
+		if !exists {
+			copied := ip
+			copied.ThreatCategories = append([]string{}, ip.ThreatCategories...)
+			ipStats[ip.IP] = &copied
+			order = append(order, ip.IP)
+			continue
+		}
+
+		errors := existing.ErrorRate*float64(existing.RequestCount) + ip.ErrorRate*float64(ip.RequestCount)
+		existing.RequestCount += ip.RequestCount
+		existing.ErrorRate = errors / float64(existing.RequestCount)
+		existing.ThreatScore += ip.ThreatScore
+		if ip.UniqueURLs > existing.UniqueURLs {
+			existing.UniqueURLs = ip.UniqueURLs
+		}
+		if ip.FirstSeen.Before(existing.FirstSeen) {
+			existing.FirstSeen = ip.FirstSeen
+		}
+		if ip.LastSeen.After(existing.LastSeen) {
+			existing.LastSeen = ip.LastSeen
+		}
+		for _, category := range ip.ThreatCategories {
+			a.updateThreatScore(existing, category, 0)
+		}
+	}
+	for _, ip := range order {
+		if count, ok := ipCounts[ip]; ok {
+			ipStats[ip].RequestCount = count
+		}
+		merged.SuspiciousIPs = append(merged.SuspiciousIPs, *ipStats[ip])
+	}
+	sort.SliceStable(merged.SuspiciousIPs, func(i, j int) bool {
+		return merged.SuspiciousIPs[i].ThreatScore > merged.SuspiciousIPs[j].ThreatScore
+	})
+
+	merged.ThreatLevel = a.calculateThreatLevel(merged.ThreatsDetected, merged.SuspiciousIPs)
+	merged.SecurityScore = a.calculateSecurityScore(totalRequests, merged.TotalThreats, len(merged.SuspiciousIPs))
+
+	merged.TopAttackers = []IPStat{}
+	for i, ip := range merged.SuspiciousIPs {
+		if i >= 10 { // Top 10 attackers
+			break
+		}
+		merged.TopAttackers = append(merged.TopAttackers, IPStat{IP: ip.IP, Count: ip.RequestCount})
+	}
+
+	return merged
+}
+
+func mergeEndpointStats(x, y []EndpointStat) []EndpointStat {
+	type endpointKey struct {
+		method   string
+		endpoint string
+	}
+
+	stats := make(map[endpointKey]*EndpointStat)
+	var order []endpointKey
+	for _, stat := range append(append([]EndpointStat{}, x...), y...) {
+		key := endpointKey{method: stat.Method, endpoint: stat.Endpoint}
+		existing, exists := stats[key]
+		if !exists {
+			copied := stat
+			stats[key] = &copied
+			order = append(order, key)
+			continue
+		}
+
+		weighted := func(a, b int64) int64 {
+			return (a*int64(existing.Count) + b*int64(stat.Count)) / int64(existing.Count+stat.Count)
+		}
+		existing.P50Size = weighted(existing.P50Size, stat.P50Size)
+		existing.P95Size = weighted(existing.P95Size, stat.P95Size)
+		existing.P99Size = weighted(existing.P99Size, stat.P99Size)
+		existing.Count += stat.Count
+		existing.ErrorCount += stat.ErrorCount
+		existing.ErrorRate = float64(existing.ErrorCount) / float64(existing.Count) * 100
+		existing.TotalBytes += stat.TotalBytes
+	}
+
+	merged := []EndpointStat{}
+	for _, key := range order {
+		merged = append(merged, *stats[key])
+	}
+	SortEndpointStats(merged, "requests")
+
+	return merged
+}
+
+func mergeProtocolStats(x, y []ProtocolStat) []ProtocolStat {
+	stats := make(map[string]*ProtocolStat)
+	var order []string
+	for _, stat := range append(append([]ProtocolStat{}, x...), y...) {
+		existing, exists := stats[stat.Protocol]
+		if !exists {
+			copied := stat
+			stats[stat.Protocol] = &copied
+			order = append(order, stat.Protocol)
+			continue
+		}
+
+		totalBytes := existing.AverageSize*int64(existing.Count) + stat.AverageSize*int64(stat.Count)
+		existing.Count += stat.Count
+		existing.ErrorCount += stat.ErrorCount
+		existing.ErrorRate = float64(existing.ErrorCount) / float64(existing.Count) * 100
+		existing.AverageSize = totalBytes / int64(existing.Count)
+	}
+
+	merged := []ProtocolStat{}
+	for _, protocol := range order {
+		merged = append(merged, *stats[protocol])
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Count != merged[j].Count {
+			return merged[i].Count > merged[j].Count
+		}
+		return merged[i].Protocol < merged[j].Protocol
+	})
+
+	return merged
+}
+
+func mergeBrokenLinks(x, y []BrokenLink) []BrokenLink {
+	links := make(map[string]*BrokenLink)
+	var order []string
+	for _, link := range append(append([]BrokenLink{}, x...), y...) {
+		existing, exists := links[link.URL]
+		if !exists {
+			copied := link
+			copied.Referers = append([]RefererStat{}, link.Referers...)
+			links[link.URL] = &copied
+			order = append(order, link.URL)
+			continue
+		}
+
+		existing.Count += link.Count
+		existing.InternalReferers += link.InternalReferers
+		existing.ExternalReferers += link.ExternalReferers
+		existing.DirectHits += link.DirectHits
+		for _, referer := range link.Referers {
+			found := false
+			for i := range existing.Referers {
+				if existing.Referers[i].Referer == referer.Referer {
+					existing.Referers[i].Count += referer.Count
+					found = true
+					break
+				}
+			}
+			if !found {
+				existing.Referers = append(existing.Referers, referer)
+			}
+		}
+	}
+
+	merged := []BrokenLink{}
+	for _, url := range order {
+		link := links[url]
+		sort.Slice(link.Referers, func(i, j int) bool {
+			if link.Referers[i].Count != link.Referers[j].Count {
+				return link.Referers[i].Count > link.Referers[j].Count
+			}
+			return link.Referers[i].Referer < link.Referers[j].Referer
+		})
+		if len(link.Referers) > 10 {
+			link.Referers = link.Referers[:10]
+		}
+		merged = append(merged, *link)
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].InternalReferers != merged[j].InternalReferers {
+			return merged[i].InternalReferers > merged[j].InternalReferers
+		}
+		if merged[i].Count != merged[j].Count {
+			return merged[i].Count > merged[j].Count
+		}
+		return merged[i].URL < merged[j].URL
+	})
+	if len(merged) > 20 {
+		merged = merged[:20]
+	}
+
+	return merged
+}
+
+func mergeRateLimitCandidates(x, y []RateLimitCandidate) []RateLimitCandidate {
+	candidates := make(map[string]*RateLimitCandidate)
+	var order []string
+	for _, candidate := range append(append([]RateLimitCandidate{}, x...), y...) {
+		key := candidate.Type + " " + candidate.Key
+		existing, exists := candidates[key]
+		if !exists {
+			copied := candidate
+			candidates[key] = &copied
+			order = append(order, key)
+			continue
+		}
+
+		existing.TotalRequests += candidate.TotalRequests
+		if candidate.PeakPerMinute > existing.PeakPerMinute {
+			existing.PeakPerMinute = candidate.PeakPerMinute
+			existing.PeakTime = candidate.PeakTime
+		}
+		if candidate.SustainedMinutes > existing.SustainedMinutes {
+			existing.SustainedMinutes = candidate.SustainedMinutes
+		}
+		if candidate.AveragePerMinute > existing.AveragePerMinute {
+			existing.AveragePerMinute = candidate.AveragePerMinute
+		}
+	}
+
+	merged := []RateLimitCandidate{}
+	for _, key := range order {
+		merged = append(merged, *candidates[key])
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].PeakPerMinute != merged[j].PeakPerMinute {
+			return merged[i].PeakPerMinute > merged[j].PeakPerMinute
+		}
+		if merged[i].Type != merged[j].Type {
+			return merged[i].Type < merged[j].Type
+		}
+		return merged[i].Key < merged[j].Key
+	})
+
+	return merged
+}
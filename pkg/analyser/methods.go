@@ -0,0 +1,162 @@
+package analyser
+
+import (
+	"sort"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Method categories used to classify unusual HTTP methods
+const (
+	MethodCategoryStandard = "standard"
+	MethodCategoryTrace    = "trace"   // TRACE/TRACK, used for cross-site tracing
+	MethodCategoryProxy    = "proxy"   // CONNECT, used to probe for open proxies
+	MethodCategoryWebDAV   = "webdav"  // PROPFIND, MKCOL, ... probing WebDAV support
+	MethodCategoryDebug    = "debug"   // DEBUG, used against ASP.NET debugging
+	MethodCategoryUnknown  = "unknown" // Arbitrary or malformed verbs
+)
+
+// MethodDetail reports traffic and errors for one HTTP method
+type MethodDetail struct {
+	Method    string
+	Category  string // One of the MethodCategory constants
+	Unusual   bool   // Not a method a normal site or browser sends
+	Requests  int
+	Errors    int     // 4xx/5xx responses
+	ErrorRate float64 // Percentage of requests resulting in errors
+	UniqueIPs int
+}
+
+// methodCategories classifies well-known non-standard methods
+var methodCategories = map[string]string{
+	"GET":       MethodCategoryStandard,
+	"HEAD":      MethodCategoryStandard,
+	"POST":      MethodCategoryStandard,
+	"PUT":       MethodCategoryStandard,
+	"DELETE":    MethodCategoryStandard,
+	"PATCH":     MethodCategoryStandard,
+	"OPTIONS":   MethodCategoryStandard,
+	"TRACE":     MethodCategoryTrace,
+	"TRACK":     MethodCategoryTrace,
+	"CONNECT":   MethodCategoryProxy,
+	"PROPFIND":  MethodCategoryWebDAV,
+	"PROPPATCH": MethodCategoryWebDAV,
+	"MKCOL":     MethodCategoryWebDAV,
+	"COPY":      MethodCategoryWebDAV,
+	"MOVE":      MethodCategoryWebDAV,
+	"LOCK":      MethodCategoryWebDAV,
+	"UNLOCK":    MethodCategoryWebDAV,
+	"SEARCH":    MethodCategoryWebDAV,
+	"DEBUG":     MethodCategoryDebug,
+}
+
+// MethodCategory classifies an HTTP method
+func MethodCategory(method string) string {
+	if category, exists := methodCategories[strings.ToUpper(method)]; exists {
+		return category
+	}
+	return MethodCategoryUnknown
+}
+
+// isUnusualMethod reports whether a method indicates probing rather than normal use
+func isUnusualMethod(method string) bool {
+	return MethodCategory(method) != MethodCategoryStandard
+}
+
+// methodSeverity returns the threat severity of an unusual method
+func methodSeverity(category string) string {
+	switch category {
+	case MethodCategoryTrace, MethodCategoryProxy, MethodCategoryDebug:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// methodDetailModule tracks requests, errors and clients per HTTP method
+type methodDetailModule struct {
+	details map[string]*MethodDetail
+	ips     map[string]map[string]bool
+}
+
+func newMethodDetailModule() *methodDetailModule {
+	return &methodDetailModule{
+		details: make(map[string]*MethodDetail),
+		ips:     make(map[string]map[string]bool),
+	}
+}
+
+func (m *methodDetailModule) Name() string { return ModuleMethods }
+
+func (m *methodDetailModule) Process(entry *parser.LogEntry) {
+	detail, exists := m.details[entry.Method]
+	if !exists {
+		category := MethodCategory(entry.Method)
+		detail = &MethodDetail{
+			Method:   entry.Method,
+			Category: category,
+			Unusual:  category != MethodCategoryStandard,
+		}
+		m.details[entry.Method] = detail
+		m.ips[entry.Method] = make(map[string]bool)
+	}
+
+	detail.Requests++
+	if entry.Status >= 400 {
+		detail.Errors++
+	}
+	m.ips[entry.Method][entry.IP] = true
+}
+
+func (m *methodDetailModule) Finalize(results *Results) {
+	details := []MethodDetail{}
+	for method, detail := range m.details {
+		methodDetail := *detail
+		methodDetail.UniqueIPs = len(m.ips[method])
+		details = append(details, methodDetail)
+	}
+	results.MethodDetails = finishMethodDetails(details)
+}
+
+// finishMethodDetails computes error rates and sorts methods by traffic
+func finishMethodDetails(details []MethodDetail) []MethodDetail {
+	for i := range details {
+		details[i].ErrorRate = 0
+		if details[i].Requests > 0 {
+			details[i].ErrorRate = float64(details[i].Errors) / float64(details[i].Requests) * 100
+		}
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		if details[i].Requests != details[j].Requests {
+			return details[i].Requests > details[j].Requests
+		}
+		return details[i].Method < details[j].Method
+	})
+
+	return details
+}
+
+// mergeMethodDetails combines per-method statistics. Unique IPs are summed,
+// so IPs seen in both parts are counted twice.
+func mergeMethodDetails(x, y []MethodDetail) []MethodDetail {
+	details := make(map[string]*MethodDetail)
+	for _, detail := range append(append([]MethodDetail{}, x...), y...) {
+		existing, exists := details[detail.Method]
+		if !exists {
+			copied := detail
+			details[detail.Method] = &copied
+			continue
+		}
+		existing.Requests += detail.Requests
+		existing.Errors += detail.Errors
+		existing.UniqueIPs += detail.UniqueIPs
+	}
+
+	merged := []MethodDetail{}
+	for _, detail := range details {
+		merged = append(merged, *detail)
+	}
+	return finishMethodDetails(merged)
+}
@@ -0,0 +1,560 @@
+package analyser
+
+import (
+	"sort"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Built-in module names, in pipeline order
+const (
+	ModuleOverview       = "overview"
+	ModuleStatusCodes    = "status_codes"
+	ModuleTopCounts      = "top_counts"
+	ModuleBots           = "bots"
+	ModuleFileTypes      = "file_types"
+	ModuleErrorURLs      = "error_urls"
+	ModuleHourly         = "hourly_traffic"
+	ModuleSpikes         = "spike_attribution"
+	ModuleResponseSize   = "response_sizes"
+	ModuleSizeHistogram  = "size_histogram"
+	ModuleGeographic     = "geographic"
+	ModuleSecurity       = "security"
+	ModuleEndpoints      = "endpoints"
+	ModuleProtocols      = "protocols"
+	ModuleBrokenLinks    = "broken_links"
+	ModuleRateLimits     = "rate_limits"
+	ModuleCrawlBudget    = "crawl_budget"
+	ModuleEgressCost     = "egress_cost"
+	ModuleCountries      = "countries"
+	ModuleStaticSplit    = "static_split"
+	ModuleCapacity       = "capacity"
+	ModuleVolume         = "data_volume"
+	ModuleMethods        = "methods"
+	ModuleLatencyHeatmap = "latency_heatmap"
+	ModuleTimeline       = "timeline"
+	ModuleSessionLengths = "session_lengths"
+)
+
+func init() {
+	MustRegisterModule(ModuleOverview, func(a *Analyser) Module { return newOverviewModule() })
+	MustRegisterModule(ModuleStatusCodes, func(a *Analyser) Module { return newStatusCodeModule() })
+	MustRegisterModule(ModuleTopCounts, func(a *Analyser) Module { return newTopCountsModule() })
+	MustRegisterModule(ModuleBots, func(a *Analyser) Module { return newBotModule(a) })
+	MustRegisterModule(ModuleFileTypes, func(a *Analyser) Module { return newFileTypeModule() })
+	MustRegisterModule(ModuleErrorURLs, func(a *Analyser) Module { return newErrorURLModule() })
+	MustRegisterModule(ModuleHourly, func(a *Analyser) Module { return newHourlyTrafficModule(a) })
+	MustRegisterModule(ModuleSpikes, func(a *Analyser) Module { return newSpikeAttributionModule() })
+	MustRegisterModule(ModuleResponseSize, func(a *Analyser) Module { return newResponseSizeModule() })
+	MustRegisterModule(ModuleSizeHistogram, func(a *Analyser) Module { return newSizeHistogramModule(a) })
+	MustRegisterModule(ModuleGeographic, func(a *Analyser) Module { return newGeographicModule(a) })
+	MustRegisterModule(ModuleSecurity, func(a *Analyser) Module { return newSecurityModule(a) })
+	MustRegisterModule(ModuleEndpoints, func(a *Analyser) Module { return newEndpointModule() })
+	MustRegisterModule(ModuleProtocols, func(a *Analyser) Module { return newProtocolModule() })
+	MustRegisterModule(ModuleBrokenLinks, func(a *Analyser) Module { return newBrokenLinkModule(a) })
+	MustRegisterModule(ModuleRateLimits, func(a *Analyser) Module { return newRateLimitModule(a) })
+	MustRegisterModule(ModuleCrawlBudget, func(a *Analyser) Module { return newCrawlBudgetModule(a) })
+	MustRegisterModule(ModuleEgressCost, func(a *Analyser) Module { return newEgressCostModule(a) })
+	MustRegisterModule(ModuleCountries, func(a *Analyser) Module { return newCountryStatsModule(a) })
+	MustRegisterModule(ModuleStaticSplit, func(a *Analyser) Module { return newStaticSplitModule() })
+	MustRegisterModule(ModuleCapacity, func(a *Analyser) Module { return newCapacityModule(a) })
+	MustRegisterModule(ModuleVolume, func(a *Analyser) Module { return newVolumeModule(a) })
+	MustRegisterModule(ModuleMethods, func(a *Analyser) Module { return newMethodDetailModule() })
+	MustRegisterModule(ModuleLatencyHeatmap, func(a *Analyser) Module { return newLatencyHeatmapModule() })
+	MustRegisterModule(ModuleTimeline, func(a *Analyser) Module { return newTimelineModule() })
+	MustRegisterModule(ModuleSessionLengths, func(a *Analyser) Module { return newSessionLengthModule() })
+}
+
+// overviewModule computes request totals, bytes, uniques and the time range
+type overviewModule struct {
+	total      int
+	totalBytes int64
+	ips        map[string]bool
+	urls       map[string]bool
+	timeRange  TimeRange
+}
+
+func newOverviewModule() *overviewModule {
+	return &overviewModule{
+		ips:  make(map[string]bool),
+		urls: make(map[string]bool),
+	}
+}
+
+func (m *overviewModule) Name() string { return ModuleOverview }
+
+func (m *overviewModule) Process(entry *parser.LogEntry) {
+	if m.total == 0 || entry.Timestamp.Before(m.timeRange.Start) {
+		m.timeRange.Start = entry.Timestamp
+	}
+	if m.total == 0 || entry.Timestamp.After(m.timeRange.End) {
+		m.timeRange.End = entry.Timestamp
+	}
+
+	m.total++
+	m.totalBytes += entry.Size
+	m.ips[entry.IP] = true
+	m.urls[entry.URL] = true
+}
+
+func (m *overviewModule) Finalize(results *Results) {
+	results.TotalRequests = m.total
+	results.TimeRange = m.timeRange
+	results.TotalBytes = m.totalBytes
+	if m.total > 0 {
+		results.AverageSize = m.totalBytes / int64(m.total)
+	}
+	results.UniqueIPs = len(m.ips)
+	results.UniqueURLs = len(m.urls)
+}
+
+// statusCodeModule computes status class and individual status code counts
+type statusCodeModule struct {
+	classes map[string]int
+	codes   map[int]int
+}
+
+func newStatusCodeModule() *statusCodeModule {
+	return &statusCodeModule{
+		classes: make(map[string]int),
+		codes:   make(map[int]int),
+	}
+}
+
+func (m *statusCodeModule) Name() string { return ModuleStatusCodes }
+
+func (m *statusCodeModule) Process(entry *parser.LogEntry) {
+	m.classes[getStatusClass(entry.Status)]++
+	m.codes[entry.Status]++
+}
+
+func (m *statusCodeModule) Finalize(results *Results) {
+	results.StatusCodes = make(map[string]int, len(m.classes))
+	for class, count := range m.classes {
+		results.StatusCodes[class] = count
+	}
+	results.DetailedStatusCodes = sortDetailedStatusCodes(m.codes)
+}
+
+// topCountsModule counts requests per IP, URL and HTTP method
+type topCountsModule struct {
+	ips     map[string]int
+	urls    map[string]int
+	methods map[string]int
+}
+
+func newTopCountsModule() *topCountsModule {
+	return &topCountsModule{
+		ips:     make(map[string]int),
+		urls:    make(map[string]int),
+		methods: make(map[string]int),
+	}
+}
+
+func (m *topCountsModule) Name() string { return ModuleTopCounts }
+
+func (m *topCountsModule) Process(entry *parser.LogEntry) {
+	m.ips[entry.IP]++
+	m.urls[entry.URL]++
+	m.methods[entry.Method]++
+}
+
+func (m *topCountsModule) Finalize(results *Results) {
+	results.TopIPs = sortIPCounts(m.ips)
+	results.TopURLs = sortURLCounts(m.urls)
+	results.HTTPMethods = sortMethodCounts(m.methods)
+}
+
+// botModule splits bot and human traffic and names the top bots
+type botModule struct {
+	analyser *Analyser
+	bots     int
+	humans   int
+	botNames map[string]int
+}
+
+func newBotModule(a *Analyser) *botModule {
+	return &botModule{
+		analyser: a,
+		botNames: make(map[string]int),
+	}
+}
+
+func (m *botModule) Name() string { return ModuleBots }
+
+func (m *botModule) Process(entry *parser.LogEntry) {
+	if botName := m.analyser.getBotName(entry.UserAgent); botName != "" {
+		m.bots++
+		m.botNames[botName]++
+	} else {
+		m.humans++
+	}
+}
+
+func (m *botModule) Finalize(results *Results) {
+	results.BotRequests = m.bots
+	results.HumanRequests = m.humans
+	results.TopBots = sortBotCounts(m.botNames)
+}
+
+// fileTypeModule groups requests and bytes by file type category
+type fileTypeModule struct {
+	counts map[string]int
+	sizes  map[string]int64
+}
+
+func newFileTypeModule() *fileTypeModule {
+	return &fileTypeModule{
+		counts: make(map[string]int),
+		sizes:  make(map[string]int64),
+	}
+}
+
+func (m *fileTypeModule) Name() string { return ModuleFileTypes }
+
+func (m *fileTypeModule) Process(entry *parser.LogEntry) {
+	fileType := getFileType(entry.URL)
+	m.counts[fileType]++
+	m.sizes[fileType] += entry.Size
+}
+
+func (m *fileTypeModule) Finalize(results *Results) {
+	results.FileTypes = sortFileTypeCounts(m.counts, m.sizes)
+}
+
+// errorURLModule tracks the URLs producing 4xx/5xx responses
+type errorURLModule struct {
+	errors map[string]map[int]int
+}
+
+func newErrorURLModule() *errorURLModule {
+	return &errorURLModule{errors: make(map[string]map[int]int)}
+}
+
+func (m *errorURLModule) Name() string { return ModuleErrorURLs }
+
+func (m *errorURLModule) Process(entry *parser.LogEntry) {
+	if entry.Status < 400 {
+		return
+	}
+	if m.errors[entry.URL] == nil {
+		m.errors[entry.URL] = make(map[int]int)
+	}
+	m.errors[entry.URL][entry.Status]++
+}
+
+func (m *errorURLModule) Finalize(results *Results) {
+	results.ErrorURLs = sortErrorURLs(m.errors, 10)
+}
+
+// hourlyTrafficModule builds the hour-of-day traffic profile and peaks
+type hourlyTrafficModule struct {
+	analyser   *Analyser
+	counts     map[int]int
+	timestamps map[int]string
+	weekdays   [7][24]int // Requests per day of week and hour
+}
+
+func newHourlyTrafficModule(a *Analyser) *hourlyTrafficModule {
+	return &hourlyTrafficModule{
+		analyser:   a,
+		counts:     make(map[int]int),
+		timestamps: make(map[int]string),
+	}
+}
+
+func (m *hourlyTrafficModule) Name() string { return ModuleHourly }
+
+func (m *hourlyTrafficModule) Process(entry *parser.LogEntry) {
+	hour := entry.Timestamp.Hour()
+	m.counts[hour]++
+	m.weekdays[entry.Timestamp.Weekday()][hour]++
+
+	// Store a representative timestamp for this hour (first occurrence)
+	if _, exists := m.timestamps[hour]; !exists {
+		m.timestamps[hour] = entry.Timestamp.Format("2006-01-02 15:00")
+	}
+}
+
+func (m *hourlyTrafficModule) Finalize(results *Results) {
+	hourlyTraffic := buildHourlyTraffic(m.counts, m.timestamps)
+
+	results.HourlyTraffic = hourlyTraffic
+	results.WeekdayHourTraffic = m.weekdays
+	results.TrafficPeaks = m.analyser.detectTrafficPeaks(hourlyTraffic)
+	results.AverageRequestsPerHour, results.PeakHour, results.QuietestHour = m.analyser.calculateTrafficStats(hourlyTraffic)
+}
+
+// responseSizeModule computes size percentiles and the largest/smallest URLs
+type responseSizeModule struct {
+	sizes    sizeSketch
+	total    int64
+	maxByURL map[string]int64
+	minByURL map[string]int64
+}
+
+func newResponseSizeModule() *responseSizeModule {
+	return &responseSizeModule{
+		maxByURL: make(map[string]int64),
+		minByURL: make(map[string]int64),
+	}
+}
+
+func (m *responseSizeModule) Name() string { return ModuleResponseSize }
+
+func (m *responseSizeModule) Process(entry *parser.LogEntry) {
+	m.sizes.Add(entry.Size)
+	m.total += entry.Size
+
+	if current, exists := m.maxByURL[entry.URL]; !exists || entry.Size > current {
+		m.maxByURL[entry.URL] = entry.Size
+	}
+	if current, exists := m.minByURL[entry.URL]; !exists || entry.Size < current {
+		m.minByURL[entry.URL] = entry.Size
+	}
+}
+
+func (m *responseSizeModule) Finalize(results *Results) {
+	results.LargeRequests = sortURLSizes(m.maxByURL, true, 10)
+	results.ResponseTimeStats = buildResponseTimeStats(&m.sizes, m.total, results.LargeRequests, sortURLSizes(m.minByURL, false, 10))
+}
+
+// geographicModule groups traffic by approximate IP location
+type geographicModule struct {
+	analyser  *Analyser
+	countries map[string]int
+	regions   map[string]int
+	local     int
+	cloud     int
+	unknown   int
+}
+
+func newGeographicModule(a *Analyser) *geographicModule {
+	return &geographicModule{
+		analyser:  a,
+		countries: make(map[string]int),
+		regions:   make(map[string]int),
+	}
+}
+
+func (m *geographicModule) Name() string { return ModuleGeographic }
+
+func (m *geographicModule) Process(entry *parser.LogEntry) {
+	country, region := m.analyser.getIPLocation(entry.IP)
+
+	switch country {
+	case "Local":
+		m.local++
+	case "Cloud":
+		m.cloud++
+	case "Unknown":
+		m.unknown++
+	default:
+		m.countries[country]++
+		m.regions[region]++
+	}
+}
+
+func (m *geographicModule) Finalize(results *Results) {
+	results.GeographicAnalysis = m.analyser.buildGeographicAnalysis(m.countries, m.regions, m.local, m.cloud, m.unknown)
+}
+
+// Sorting helpers shared by modules and slice-based analyses
+
+func sortIPCounts(counts map[string]int) []IPStat {
+	ipStats := []IPStat{}
+	for ip, count := range counts {
+		ipStats = append(ipStats, IPStat{IP: ip, Count: count})
+	}
+
+	sort.Slice(ipStats, func(i, j int) bool {
+		if ipStats[i].Count != ipStats[j].Count {
+			return ipStats[i].Count > ipStats[j].Count
+		}
+		return ipStats[i].IP < ipStats[j].IP
+	})
+
+	return ipStats
+}
+
+func sortURLCounts(counts map[string]int) []URLStat {
+	urlStats := []URLStat{}
+	for url, count := range counts {
+		urlStats = append(urlStats, URLStat{
+			URL:         url,
+			Count:       count,
+			StatusCodes: nil, // Not applicable for top URLs (not error-specific)
+		})
+	}
+
+	sort.Slice(urlStats, func(i, j int) bool {
+		if urlStats[i].Count != urlStats[j].Count {
+			return urlStats[i].Count > urlStats[j].Count
+		}
+		return urlStats[i].URL < urlStats[j].URL
+	})
+
+	return urlStats
+}
+
+func sortMethodCounts(counts map[string]int) []MethodStat {
+	methodStats := []MethodStat{}
+	for method, count := range counts {
+		methodStats = append(methodStats, MethodStat{Method: method, Count: count})
+	}
+
+	sort.Slice(methodStats, func(i, j int) bool {
+		if methodStats[i].Count != methodStats[j].Count {
+			return methodStats[i].Count > methodStats[j].Count
+		}
+		return methodStats[i].Method < methodStats[j].Method
+	})
+
+	return methodStats
+}
+
+func sortBotCounts(counts map[string]int) []BotStat {
+	botStats := []BotStat{}
+	for bot, count := range counts {
+		botStats = append(botStats, BotStat{BotName: bot, Count: count})
+	}
+
+	sort.Slice(botStats, func(i, j int) bool {
+		if botStats[i].Count != botStats[j].Count {
+			return botStats[i].Count > botStats[j].Count
+		}
+		return botStats[i].BotName < botStats[j].BotName
+	})
+
+	return botStats
+}
+
+func sortFileTypeCounts(counts map[string]int, sizes map[string]int64) []FileTypeStat {
+	fileTypeStats := []FileTypeStat{}
+	for fileType, count := range counts {
+		fileTypeStats = append(fileTypeStats, FileTypeStat{
+			FileType: fileType,
+			Count:    count,
+			Size:     sizes[fileType],
+		})
+	}
+
+	sort.Slice(fileTypeStats, func(i, j int) bool {
+		if fileTypeStats[i].Count != fileTypeStats[j].Count {
+			return fileTypeStats[i].Count > fileTypeStats[j].Count
+		}
+		return fileTypeStats[i].FileType < fileTypeStats[j].FileType
+	})
+
+	return fileTypeStats
+}
+
+func sortDetailedStatusCodes(counts map[int]int) []DetailedStatusCode {
+	statusStats := []DetailedStatusCode{}
+	for status, count := range counts {
+		statusStats = append(statusStats, DetailedStatusCode{Code: status, Count: count})
+	}
+
+	sort.Slice(statusStats, func(i, j int) bool {
+		if statusStats[i].Count != statusStats[j].Count {
+			return statusStats[i].Count > statusStats[j].Count
+		}
+		return statusStats[i].Code < statusStats[j].Code
+	})
+
+	return statusStats
+}
+
+// sortErrorURLs converts per-URL error status counts into the top limit URLs
+func sortErrorURLs(errorData map[string]map[int]int, limit int) []URLStat {
+	errorStats := []URLStat{}
+	for url, counts := range errorData {
+		// Copy so results are not affected by later updates to errorData
+		statusCodes := make(map[int]int, len(counts))
+		totalCount := 0
+		for status, count := range counts {
+			statusCodes[status] = count
+			totalCount += count
+		}
+
+		errorStats = append(errorStats, URLStat{
+			URL:         url,
+			Count:       totalCount,
+			StatusCodes: statusCodes,
+		})
+	}
+
+	sort.Slice(errorStats, func(i, j int) bool {
+		if errorStats[i].Count != errorStats[j].Count {
+			return errorStats[i].Count > errorStats[j].Count
+		}
+		return errorStats[i].URL < errorStats[j].URL
+	})
+
+	if limit > 0 && len(errorStats) > limit {
+		errorStats = errorStats[:limit]
+	}
+
+	return errorStats
+}
+
+// sortURLSizes returns the limit URLs with the largest (or smallest) size,
+// storing the size in the Count field for display
+func sortURLSizes(sizes map[string]int64, largest bool, limit int) []URLStat {
+	sizeStats := []URLStat{}
+	for url, size := range sizes {
+		sizeStats = append(sizeStats, URLStat{URL: url, Count: int(size)})
+	}
+
+	sort.Slice(sizeStats, func(i, j int) bool {
+		if sizeStats[i].Count != sizeStats[j].Count {
+			if largest {
+				return sizeStats[i].Count > sizeStats[j].Count
+			}
+			return sizeStats[i].Count < sizeStats[j].Count
+		}
+		return sizeStats[i].URL < sizeStats[j].URL
+	})
+
+	if limit > 0 && len(sizeStats) > limit {
+		sizeStats = sizeStats[:limit]
+	}
+
+	return sizeStats
+}
+
+func buildHourlyTraffic(counts map[int]int, timestamps map[int]string) []HourlyTraffic {
+	hourlyTraffic := []HourlyTraffic{}
+	for hour, count := range counts {
+		hourlyTraffic = append(hourlyTraffic, HourlyTraffic{
+			Hour:         hour,
+			RequestCount: count,
+			Timestamp:    timestamps[hour],
+		})
+	}
+
+	sort.Slice(hourlyTraffic, func(i, j int) bool {
+		return hourlyTraffic[i].Hour < hourlyTraffic[j].Hour
+	})
+
+	return hourlyTraffic
+}
+
+// buildResponseTimeStats computes size statistics from a sketch of the sizes
+// and their total
+func buildResponseTimeStats(sizes *sizeSketch, totalSize int64, slowRequests, fastRequests []URLStat) ResponseTimeStats {
+	if sizes.Count() == 0 {
+		return ResponseTimeStats{}
+	}
+
+	return ResponseTimeStats{
+		AverageSize:  totalSize / int64(sizes.Count()),
+		MedianSize:   sizes.Percentile(50),
+		P95Size:      sizes.Percentile(95),
+		P99Size:      sizes.Percentile(99),
+		MinSize:      sizes.min,
+		MaxSize:      sizes.max,
+		SlowRequests: slowRequests,
+		FastRequests: fastRequests,
+	}
+}
@@ -0,0 +1,188 @@
+package analyser
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Module is a single step of the analysis pipeline. Process is called once
+// for every log entry in the analysed window, then Finalize writes the
+// module's metrics into the shared Results.
+type Module interface {
+	Name() string
+	Process(entry *parser.LogEntry)
+	Finalize(results *Results)
+}
+
+// ModuleFactory creates a fresh module instance for an analysis run. The
+// analyser is passed so modules can read its configuration.
+type ModuleFactory func(a *Analyser) Module
+
+type registeredModule struct {
+	name    string
+	factory ModuleFactory
+}
+
+var (
+	moduleRegistry   []registeredModule
+	moduleRegistryMu sync.RWMutex
+)
+
+// RegisterModule adds a module to the analysis pipeline. Modules run in
+// registration order; third-party modules should store their output in
+// Results.Extensions under their own name.
+func RegisterModule(name string, factory ModuleFactory) error {
+	moduleRegistryMu.Lock()
+	defer moduleRegistryMu.Unlock()
+
+	for _, module := range moduleRegistry {
+		if module.name == name {
+			return fmt.Errorf("analyser module %q is already registered", name)
+		}
+	}
+
+	moduleRegistry = append(moduleRegistry, registeredModule{name: name, factory: factory})
+	return nil
+}
+
+// MustRegisterModule is like RegisterModule but panics on duplicate names.
+// It is intended for use in init functions.
+func MustRegisterModule(name string, factory ModuleFactory) {
+	if err := RegisterModule(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// RegisteredModules returns the names of all registered modules in pipeline order
+func RegisteredModules() []string {
+	moduleRegistryMu.RLock()
+	defer moduleRegistryMu.RUnlock()
+
+	names := make([]string, len(moduleRegistry))
+	for i, module := range moduleRegistry {
+		names[i] = module.name
+	}
+	return names
+}
+
+// DisableModules skips the named modules in subsequent analysis runs
+func (a *Analyser) DisableModules(names ...string) {
+	if a.disabledModules == nil {
+		a.disabledModules = make(map[string]bool)
+	}
+	for _, name := range names {
+		a.disabledModules[name] = true
+	}
+}
+
+// newModules instantiates every enabled module for a single analysis run
+func (a *Analyser) newModules() []Module {
+	moduleRegistryMu.RLock()
+	defer moduleRegistryMu.RUnlock()
+
+	var modules []Module
+	for _, module := range moduleRegistry {
+		if a.disabledModules[module.name] {
+			continue
+		}
+		modules = append(modules, module.factory(a))
+	}
+	return modules
+}
+
+// runPipeline feeds every entry through the modules and collects their results
+func (a *Analyser) runPipeline(logs []*parser.LogEntry) *Results {
+	stream := a.NewStream(nil, nil)
+	for _, entry := range logs {
+		stream.Add(entry)
+	}
+
+	return stream.Results()
+}
+
+// bufferedModule adapts analyses that need the complete window of entries
+// (percentiles, cross-entry correlation) to the Module interface
+type bufferedModule struct {
+	name     string
+	logs     []*parser.LogEntry
+	finalize func(logs []*parser.LogEntry, results *Results)
+}
+
+// NewBufferedModule wraps a whole-window analysis function as a pipeline module.
+// The module keeps every entry it processes, in streams too, so the built-in
+// modules compute their results incrementally instead.
+func NewBufferedModule(name string, finalize func(logs []*parser.LogEntry, results *Results)) Module {
+	return &bufferedModule{name: name, finalize: finalize}
+}
+
+func (m *bufferedModule) Name() string { return m.name }
+
+func (m *bufferedModule) Process(entry *parser.LogEntry) {
+	m.logs = append(m.logs, entry)
+}
+
+func (m *bufferedModule) Finalize(results *Results) {
+	m.finalize(m.logs, results)
+}
+
+// Stream computes Results incrementally as entries arrive, so callers do not
+// need the full slice of entries in memory
+type Stream struct {
+	analyser *Analyser
+	modules  []Module
+	since    *time.Time
+	until    *time.Time
+	count    int
+}
+
+// NewStream starts an incremental analysis over the given time window
+func (a *Analyser) NewStream(since, until *time.Time) *Stream {
+	return &Stream{
+		analyser: a,
+		modules:  a.newModules(),
+		since:    since,
+		until:    until,
+	}
+}
+
+// Add feeds a single entry into the analysis, skipping entries outside the
+// window, and reports whether the entry was accepted
+func (s *Stream) Add(entry *parser.LogEntry) bool {
+	if s.since != nil && entry.Timestamp.Before(*s.since) {
+		return false
+	}
+	if s.until != nil && entry.Timestamp.After(*s.until) {
+		return false
+	}
+	if !s.analyser.matchesCountryFilter(entry.IP) {
+		return false
+	}
+
+	s.count++
+	for _, module := range s.modules {
+		module.Process(entry)
+	}
+	return true
+}
+
+// Count returns the number of entries accepted so far
+func (s *Stream) Count() int {
+	return s.count
+}
+
+// Results returns a snapshot of the metrics for all entries added so far.
+// More entries may be added afterwards.
+func (s *Stream) Results() *Results {
+	results := newEmptyResults()
+	if s.count == 0 {
+		return results
+	}
+
+	for _, module := range s.modules {
+		module.Finalize(results)
+	}
+	return results
+}
@@ -0,0 +1,93 @@
+package analyser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// ProtocolStat summarises requests made over a single HTTP protocol version
+type ProtocolStat struct {
+	Protocol    string  // e.g. "HTTP/1.1", "HTTP/2.0"
+	Count       int
+	ErrorCount  int     // Requests with 4xx/5xx status
+	ErrorRate   float64 // Percentage of requests resulting in errors
+	AverageSize int64
+}
+
+// protocolModule counts requests, errors and bytes per protocol version
+type protocolModule struct {
+	counts      map[string]int
+	errorCounts map[string]int
+	sizes       map[string]int64
+}
+
+func newProtocolModule() *protocolModule {
+	return &protocolModule{
+		counts:      make(map[string]int),
+		errorCounts: make(map[string]int),
+		sizes:       make(map[string]int64),
+	}
+}
+
+func (m *protocolModule) Name() string { return ModuleProtocols }
+
+func (m *protocolModule) Process(entry *parser.LogEntry) {
+	protocol := normaliseProtocol(entry.Protocol)
+	m.counts[protocol]++
+	m.sizes[protocol] += entry.Size
+	if entry.Status >= 400 {
+		m.errorCounts[protocol]++
+	}
+}
+
+func (m *protocolModule) Finalize(results *Results) {
+	var protocolStats []ProtocolStat
+	for protocol, count := range m.counts {
+		protocolStats = append(protocolStats, ProtocolStat{
+			Protocol:    protocol,
+			Count:       count,
+			ErrorCount:  m.errorCounts[protocol],
+			ErrorRate:   float64(m.errorCounts[protocol]) / float64(count) * 100,
+			AverageSize: m.sizes[protocol] / int64(count),
+		})
+	}
+
+	sort.Slice(protocolStats, func(i, j int) bool {
+		if protocolStats[i].Count != protocolStats[j].Count {
+			return protocolStats[i].Count > protocolStats[j].Count
+		}
+		return protocolStats[i].Protocol < protocolStats[j].Protocol
+	})
+
+	results.Protocols = protocolStats
+}
+
+// httpVersion matches an HTTP version such as HTTP/1.1 or HTTP/2
+var httpVersion = regexp.MustCompile(`^HTTP/(\d)(\.\d)?$`)
+
+// normaliseProtocol maps protocol strings to a canonical form so that
+// "HTTP/2" and "HTTP/2.0" are counted together. The protocol comes from the
+// request line, so anything but an HTTP version is counted as "Other".
+func normaliseProtocol(protocol string) string {
+	protocol = strings.ToUpper(strings.TrimSpace(protocol))
+	if protocol == "" {
+		return "Unknown"
+	}
+
+	version := httpVersion.FindStringSubmatch(protocol)
+	if version == nil {
+		return "Other"
+	}
+	if version[2] == "" {
+		return protocol + ".0"
+	}
+	return protocol
+}
+
+// IsLegacyProtocol reports whether a protocol predates HTTP/1.1
+func IsLegacyProtocol(protocol string) bool {
+	return protocol == "HTTP/1.0" || protocol == "HTTP/0.9"
+}
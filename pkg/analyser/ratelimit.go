@@ -0,0 +1,303 @@
+package analyser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// RateLimitThresholds controls when an IP or endpoint is reported as a rate-limit candidate
+type RateLimitThresholds struct {
+	RequestsPerMinute int // Per-client requests in a single minute considered excessive
+	SustainedMinutes  int // Consecutive minutes above the limit before reporting
+}
+
+// DefaultRateLimitThresholds returns the default thresholds (60 req/min for 3 minutes)
+func DefaultRateLimitThresholds() RateLimitThresholds {
+	return RateLimitThresholds{
+		RequestsPerMinute: 60,
+		SustainedMinutes:  3,
+	}
+}
+
+// RateLimitCandidate is an IP or endpoint whose request rate exceeded the thresholds
+type RateLimitCandidate struct {
+	Type             string  // "ip" or "endpoint"
+	Key              string  // IP address or normalised endpoint
+	PeakPerMinute    int     // Highest per-client requests in one minute
+	AveragePerMinute float64 // Average per-client requests in minutes above the limit
+	SustainedMinutes int     // Longest run of consecutive minutes above the limit
+	TotalRequests    int
+	PeakTime         time.Time
+}
+
+// SetRateLimitThresholds sets the thresholds used for rate-limit candidate detection
+func (a *Analyser) SetRateLimitThresholds(thresholds RateLimitThresholds) {
+	a.rateLimits = thresholds
+}
+
+// RateLimitThresholds returns the thresholds used for rate-limit candidate detection
+func (a *Analyser) RateLimitThresholds() RateLimitThresholds {
+	return a.rateLimits
+}
+
+// rateLimitClient is a client on one endpoint
+type rateLimitClient struct {
+	ip       string
+	endpoint string
+}
+
+// rateMinute is the number of requests in one minute
+type rateMinute struct {
+	minute int64
+	count  int
+}
+
+// rateMinutes is a per-minute histogram sorted by minute. Most clients are
+// seen in only a few minutes, so a slice is far smaller than a map.
+type rateMinutes []rateMinute
+
+// add counts a request in minute and returns the updated histogram
+func (r rateMinutes) add(minute int64) rateMinutes {
+	// Entries mostly arrive in order, so check the latest minute first
+	if n := len(r); n > 0 && r[n-1].minute == minute {
+		r[n-1].count++
+		return r
+	}
+	i := sort.Search(len(r), func(i int) bool { return r[i].minute >= minute })
+	if i < len(r) && r[i].minute == minute {
+		r[i].count++
+		return r
+	}
+	r = append(r, rateMinute{})
+	copy(r[i+1:], r[i:])
+	r[i] = rateMinute{minute: minute, count: 1}
+	return r
+}
+
+// rateLimitModule counts requests per minute per IP, and per IP+endpoint
+// pair, and reports those over the thresholds
+type rateLimitModule struct {
+	thresholds      RateLimitThresholds
+	ipMinutes       map[string]rateMinutes
+	endpointMinutes map[rateLimitClient]rateMinutes
+}
+
+func newRateLimitModule(a *Analyser) *rateLimitModule {
+	return &rateLimitModule{
+		thresholds:      a.rateLimits,
+		ipMinutes:       make(map[string]rateMinutes),
+		endpointMinutes: make(map[rateLimitClient]rateMinutes),
+	}
+}
+
+func (m *rateLimitModule) Name() string { return ModuleRateLimits }
+
+func (m *rateLimitModule) Process(entry *parser.LogEntry) {
+	if m.thresholds.RequestsPerMinute <= 0 {
+		return
+	}
+	minute := entry.Timestamp.Unix() / 60
+	m.ipMinutes[entry.IP] = m.ipMinutes[entry.IP].add(minute)
+
+	key := rateLimitClient{ip: entry.IP, endpoint: NormaliseEndpoint(entry.URL)}
+	m.endpointMinutes[key] = m.endpointMinutes[key].add(minute)
+}
+
+func (m *rateLimitModule) Finalize(results *Results) {
+	results.RateLimitCandidates = rateLimitCandidates(m.ipMinutes, m.endpointMinutes, m.thresholds)
+}
+
+// rateLimitCandidates evaluates the per-minute counts against the thresholds
+func rateLimitCandidates(ipMinutes map[string]rateMinutes, endpointMinutes map[rateLimitClient]rateMinutes, thresholds RateLimitThresholds) []RateLimitCandidate {
+	if thresholds.RequestsPerMinute <= 0 {
+		return []RateLimitCandidate{}
+	}
+	if thresholds.SustainedMinutes <= 0 {
+		thresholds.SustainedMinutes = 1
+	}
+
+	var candidates []RateLimitCandidate
+
+	for ip, minutes := range ipMinutes {
+		if candidate, ok := evaluateRateWindow(minutes, thresholds); ok {
+			candidate.Type = "ip"
+			candidate.Key = ip
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	// Endpoints are candidates when any single client exceeds the limit on them
+	endpointCandidates := make(map[string]*RateLimitCandidate)
+	for key, minutes := range endpointMinutes {
+		candidate, ok := evaluateRateWindow(minutes, thresholds)
+		if !ok {
+			continue
+		}
+
+		existing, exists := endpointCandidates[key.endpoint]
+		if !exists {
+			candidate.Type = "endpoint"
+			candidate.Key = key.endpoint
+			endpointCandidates[key.endpoint] = &candidate
+			continue
+		}
+
+		existing.TotalRequests += candidate.TotalRequests
+		if candidate.PeakPerMinute > existing.PeakPerMinute {
+			existing.PeakPerMinute = candidate.PeakPerMinute
+			existing.PeakTime = candidate.PeakTime
+		}
+		if candidate.SustainedMinutes > existing.SustainedMinutes {
+			existing.SustainedMinutes = candidate.SustainedMinutes
+		}
+		if candidate.AveragePerMinute > existing.AveragePerMinute {
+			existing.AveragePerMinute = candidate.AveragePerMinute
+		}
+	}
+	for _, candidate := range endpointCandidates {
+		candidates = append(candidates, *candidate)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].PeakPerMinute != candidates[j].PeakPerMinute {
+			return candidates[i].PeakPerMinute > candidates[j].PeakPerMinute
+		}
+		if candidates[i].Type != candidates[j].Type {
+			return candidates[i].Type < candidates[j].Type
+		}
+		return candidates[i].Key < candidates[j].Key
+	})
+
+	return candidates
+}
+
+// evaluateRateWindow checks a per-minute histogram against the thresholds
+func evaluateRateWindow(minutes rateMinutes, thresholds RateLimitThresholds) (RateLimitCandidate, bool) {
+	total := 0
+	for _, counted := range minutes {
+		total += counted.count
+	}
+
+	candidate := RateLimitCandidate{TotalRequests: total}
+	run := 0
+	overMinutes := 0
+	overRequests := 0
+	var previous int64
+
+	for _, counted := range minutes {
+		minute, count := counted.minute, counted.count
+		if count > candidate.PeakPerMinute {
+			candidate.PeakPerMinute = count
+			candidate.PeakTime = time.Unix(minute*60, 0)
+		}
+
+		if count <= thresholds.RequestsPerMinute {
+			run = 0
+			continue
+		}
+
+		overMinutes++
+		overRequests += count
+		if run > 0 && minute == previous+1 {
+			run++
+		} else {
+			run = 1
+		}
+		previous = minute
+
+		if run > candidate.SustainedMinutes {
+			candidate.SustainedMinutes = run
+		}
+	}
+
+	if candidate.SustainedMinutes < thresholds.SustainedMinutes {
+		return RateLimitCandidate{}, false
+	}
+
+	candidate.AveragePerMinute = float64(overRequests) / float64(overMinutes)
+	return candidate, true
+}
+
+// GenerateNginxRateLimitConfig suggests nginx limit_req zones and location
+// limits for the detected candidates
+func GenerateNginxRateLimitConfig(candidates []RateLimitCandidate, thresholds RateLimitThresholds) string {
+	var sb strings.Builder
+
+	burst := thresholds.RequestsPerMinute / 2
+	if burst < 5 {
+		burst = 5
+	}
+
+	sb.WriteString(fmt.Sprintf("# Suggested rate limits: %d requests/minute sustained for %d+ minutes\n",
+		thresholds.RequestsPerMinute, thresholds.SustainedMinutes))
+	sb.WriteString("# Place limit_req_zone in the http {} block\n")
+	sb.WriteString(fmt.Sprintf("limit_req_zone $binary_remote_addr zone=per_ip:10m rate=%dr/m;\n", thresholds.RequestsPerMinute))
+	sb.WriteString("limit_req_status 429;\n\n")
+
+	endpointCount := 0
+	for _, candidate := range candidates {
+		if candidate.Type != "endpoint" {
+			continue
+		}
+		endpointCount++
+		if strings.IndexFunc(candidate.Key, unicode.IsControl) >= 0 {
+			sb.WriteString(fmt.Sprintf("# Skipped an endpoint with control characters: %q\n\n", candidate.Key))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("# %s: peak %d req/min per client, sustained %d minutes\n",
+			candidate.Key, candidate.PeakPerMinute, candidate.SustainedMinutes))
+		sb.WriteString(fmt.Sprintf("location %s {\n", nginxLocation(candidate.Key)))
+		sb.WriteString(fmt.Sprintf("    limit_req zone=per_ip burst=%d nodelay;\n", burst))
+		sb.WriteString("}\n\n")
+	}
+
+	if endpointCount == 0 {
+		sb.WriteString("# No endpoints exceeded the threshold; apply a site-wide limit in server {}:\n")
+		sb.WriteString(fmt.Sprintf("# limit_req zone=per_ip burst=%d nodelay;\n\n", burst))
+	}
+
+	ipCount := 0
+	for _, candidate := range candidates {
+		if candidate.Type != "ip" {
+			continue
+		}
+		if ipCount == 0 {
+			sb.WriteString("# Heaviest clients (consider stricter limits or deny rules)\n")
+		}
+		ipCount++
+		sb.WriteString(fmt.Sprintf("# %s: peak %d req/min, avg %.1f req/min over %d sustained minutes\n",
+			candidate.Key, candidate.PeakPerMinute, candidate.AveragePerMinute, candidate.SustainedMinutes))
+	}
+
+	return sb.String()
+}
+
+// nginxLocation converts a normalised endpoint into an nginx location matcher,
+// using a regex location when ID segments were collapsed. The matcher is
+// quoted, as endpoints come from untrusted request paths.
+func nginxLocation(endpoint string) string {
+	if !strings.Contains(endpoint, "{id}") {
+		return "= " + nginxQuote(endpoint)
+	}
+
+	segments := strings.Split(endpoint, "/")
+	for i, segment := range segments {
+		if segment == "{id}" {
+			segments[i] = "[^/]+"
+		} else {
+			segments[i] = regexp.QuoteMeta(segment)
+		}
+	}
+	return "~ " + nginxQuote("^"+strings.Join(segments, "/")+"$")
+}
+
+// nginxQuote quotes a string for an nginx configuration file
+func nginxQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
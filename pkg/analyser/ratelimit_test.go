@@ -0,0 +1,35 @@
+package analyser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateNginxRateLimitConfigQuotesHostilePaths(t *testing.T) {
+	thresholds := RateLimitThresholds{RequestsPerMinute: 60, SustainedMinutes: 1}
+	candidates := []RateLimitCandidate{
+		{Type: "endpoint", Key: `/a { return 200; } location /b`},
+		{Type: "endpoint", Key: `/x"; deny all; \`},
+		{Type: "endpoint", Key: `/users/{id}/"x"`},
+		{Type: "endpoint", Key: "/split\n}\nserver {"},
+	}
+
+	config := GenerateNginxRateLimitConfig(candidates, thresholds)
+
+	for _, want := range []string{
+		`location = "/a { return 200; } location /b" {`,
+		`location = "/x\"; deny all; \\" {`,
+		`location ~ "^/users/[^/]+/\"x\"$" {`,
+		`# Skipped an endpoint with control characters: "/split\n}\nserver {"`,
+	} {
+		if !strings.Contains(config, want) {
+			t.Errorf("config is missing %s:\n%s", want, config)
+		}
+	}
+	if strings.Contains(config, "\nserver {") {
+		t.Errorf("a newline in an endpoint reached the config:\n%s", config)
+	}
+	if locations := strings.Count(config, "\nlocation "); locations != 3 {
+		t.Errorf("config has %d locations, want 3:\n%s", locations, config)
+	}
+}
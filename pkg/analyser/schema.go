@@ -0,0 +1,200 @@
+package analyser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ResultsSchemaVersion is the version of the JSON structure of exported
+// Results. New fields may be added without changing it; renaming or removing
+// a field or changing its type increases it, and UpgradeResultsJSON converts
+// exports written with older versions.
+const ResultsSchemaVersion = 2
+
+// legacySchemaVersion is assumed for exports written before schema_version existed
+const legacySchemaVersion = 1
+
+// schemaMigrations upgrade a decoded Results export from the keyed version to the next
+var schemaMigrations = map[int]func(results map[string]interface{}){
+	1: migrateResultsV1,
+}
+
+// UpgradeResultsJSON converts a JSON export of Results, or of grouped results
+// written with --group, from any older schema version to ResultsSchemaVersion.
+// Sections missing from older exports are filled with empty values so that
+// every documented field is present.
+func UpgradeResultsJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var export map[string]interface{}
+	if err := decoder.Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid JSON export: %w", err)
+	}
+
+	version, err := exportSchemaVersion(export)
+	if err != nil {
+		return nil, err
+	}
+
+	if combined, ok := export["Combined"].(map[string]interface{}); ok {
+		if err := upgradeResults(combined, version); err != nil {
+			return nil, err
+		}
+		groups, _ := export["Groups"].([]interface{})
+		for _, group := range groups {
+			if group, ok := group.(map[string]interface{}); ok {
+				if results, ok := group["Results"].(map[string]interface{}); ok {
+					if err := upgradeResults(results, version); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return reencode(export, &GroupedResults{})
+	}
+
+	if err := upgradeResults(export, version); err != nil {
+		return nil, err
+	}
+	return reencode(export, newEmptyResults())
+}
+
+// LoadResultsJSON reads a JSON export of Results written with any schema
+// version. For grouped exports the combined roll-up is returned.
+func LoadResultsJSON(filename string) (*Results, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	upgraded, err := UpgradeResultsJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var grouped GroupedResults
+	if err := json.Unmarshal(upgraded, &grouped); err == nil && grouped.Combined != nil {
+		return grouped.Combined, nil
+	}
+	results := newEmptyResults()
+	if err := json.Unmarshal(upgraded, results); err != nil {
+		return nil, fmt.Errorf("export does not match the results schema: %w", err)
+	}
+	return results, nil
+}
+
+// reencode decodes an upgraded export into target and encodes it again, so
+// converted exports have the same field order and formatting as new ones
+func reencode(export map[string]interface{}, target interface{}) ([]byte, error) {
+	data, err := json.Marshal(export)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return nil, fmt.Errorf("export does not match the results schema: %w", err)
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(target); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// exportSchemaVersion returns the schema version an export was written with
+func exportSchemaVersion(export map[string]interface{}) (int, error) {
+	value, exists := export["schema_version"]
+	if !exists {
+		return legacySchemaVersion, nil
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("invalid schema_version %v", value)
+	}
+	version, err := number.Int64()
+	if err != nil || version < legacySchemaVersion {
+		return 0, fmt.Errorf("invalid schema_version %v", value)
+	}
+	if version > ResultsSchemaVersion {
+		return 0, fmt.Errorf("export uses schema version %d, newer than the supported version %d", version, ResultsSchemaVersion)
+	}
+	return int(version), nil
+}
+
+// upgradeResults applies every migration after version, then fills missing fields
+func upgradeResults(results map[string]interface{}, version int) error {
+	defaults, err := emptyResultsJSON()
+	if err != nil {
+		return err
+	}
+
+	for v := version; v < ResultsSchemaVersion; v++ {
+		if migrate, exists := schemaMigrations[v]; exists {
+			migrate(results)
+		}
+	}
+	fillMissingFields(results, defaults)
+	results["schema_version"] = ResultsSchemaVersion
+	return nil
+}
+
+// emptyResultsJSON returns the JSON form of empty results, used as field defaults
+func emptyResultsJSON() (map[string]interface{}, error) {
+	data, err := json.Marshal(newEmptyResults())
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var defaults map[string]interface{}
+	if err := decoder.Decode(&defaults); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// fillMissingFields copies fields that are absent or null in target from
+// defaults, descending into nested objects
+func fillMissingFields(target, defaults map[string]interface{}) {
+	for key, value := range defaults {
+		existing, exists := target[key]
+		if !exists || existing == nil {
+			target[key] = value
+			continue
+		}
+
+		nestedTarget, targetIsObject := existing.(map[string]interface{})
+		nestedDefaults, defaultIsObject := value.(map[string]interface{})
+		if targetIsObject && defaultIsObject {
+			fillMissingFields(nestedTarget, nestedDefaults)
+		}
+	}
+}
+
+// migrateResultsV1 upgrades exports written before schema_version existed.
+// Traffic peaks gained an Hour field, which is recovered from the peak's
+// "2006-01-02 15:00" timestamp.
+func migrateResultsV1(results map[string]interface{}) {
+	peaks, _ := results["TrafficPeaks"].([]interface{})
+	for _, peak := range peaks {
+		peak, ok := peak.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, exists := peak["Hour"]; exists {
+			continue
+		}
+
+		timestamp, _ := peak["Time"].(string)
+		if parsed, err := time.Parse("2006-01-02 15:00", timestamp); err == nil {
+			peak["Hour"] = parsed.Hour()
+		}
+	}
+}
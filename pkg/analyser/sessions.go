@@ -0,0 +1,133 @@
+package analyser
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// DefaultSessionTimeout is the inactivity gap that ends a visitor session
+const DefaultSessionTimeout = 30 * time.Minute
+
+// Session is a run of requests from one visitor, identified by IP and user
+// agent, with no gap between requests longer than the session timeout
+type Session struct {
+	ID        string
+	IP        string
+	UserAgent string
+	Start     time.Time
+	End       time.Time
+	Requests  int
+	Pages     int // Requests for dynamic pages rather than static assets
+	Bytes     int64
+	Errors    int // 4xx and 5xx responses
+	EntryURL  string
+	ExitURL   string
+}
+
+// Duration returns the time between the first and last request
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// ReconstructSessions groups log entries into visitor sessions, ordered by
+// start time. A timeout of zero uses DefaultSessionTimeout.
+func ReconstructSessions(logs []*parser.LogEntry, timeout time.Duration) []Session {
+	if timeout <= 0 {
+		timeout = DefaultSessionTimeout
+	}
+
+	ordered := append([]*parser.LogEntry(nil), logs...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	var sessions []Session
+	open := make(map[string]int) // Visitor → index of their latest session
+	for _, entry := range ordered {
+		visitor := entry.IP + "|" + entry.UserAgent
+
+		index, exists := open[visitor]
+		if !exists || entry.Timestamp.Sub(sessions[index].End) > timeout {
+			sessions = append(sessions, Session{
+				IP:        entry.IP,
+				UserAgent: entry.UserAgent,
+				Start:     entry.Timestamp,
+				EntryURL:  entry.URL,
+			})
+			index = len(sessions) - 1
+			open[visitor] = index
+		}
+
+		session := &sessions[index]
+		session.End = entry.Timestamp
+		session.ExitURL = entry.URL
+		session.Requests++
+		session.Bytes += entry.Size
+		if !isStaticFileType(getFileType(entry.URL)) {
+			session.Pages++
+		}
+		if entry.Status >= 400 {
+			session.Errors++
+		}
+	}
+
+	for i := range sessions {
+		sessions[i].ID = fmt.Sprintf("s%d", i+1)
+	}
+	return sessions
+}
+
+// visitorSession is the start and end of a visitor's latest session
+type visitorSession struct {
+	start time.Time
+	end   time.Time
+}
+
+// sessionLengthModule measures visitor sessions as entries arrive, the way
+// ReconstructSessions groups them, without keeping the entries. Entries that
+// arrive out of order only stretch the session they fall in.
+type sessionLengthModule struct {
+	open   map[string]visitorSession // Visitor → their latest session
+	closed []time.Duration
+}
+
+func newSessionLengthModule() *sessionLengthModule {
+	return &sessionLengthModule{open: make(map[string]visitorSession)}
+}
+
+func (m *sessionLengthModule) Name() string { return ModuleSessionLengths }
+
+func (m *sessionLengthModule) Process(entry *parser.LogEntry) {
+	visitor := entry.IP + "|" + entry.UserAgent
+
+	session, exists := m.open[visitor]
+	if !exists || entry.Timestamp.Sub(session.end) > DefaultSessionTimeout {
+		if exists {
+			m.closed = append(m.closed, session.end.Sub(session.start))
+		}
+		m.open[visitor] = visitorSession{start: entry.Timestamp, end: entry.Timestamp}
+		return
+	}
+
+	if entry.Timestamp.Before(session.start) {
+		session.start = entry.Timestamp
+	}
+	if entry.Timestamp.After(session.end) {
+		session.end = entry.Timestamp
+	}
+	m.open[visitor] = session
+}
+
+// Finalize counts the sessions still open as ended, leaving them open so a
+// stream can keep adding to them
+func (m *sessionLengthModule) Finalize(results *Results) {
+	lengths := make([]time.Duration, 0, len(m.closed)+len(m.open))
+	lengths = append(lengths, m.closed...)
+	for _, session := range m.open {
+		lengths = append(lengths, session.end.Sub(session.start))
+	}
+	results.SessionLengths = lengths
+}
@@ -0,0 +1,109 @@
+package analyser
+
+import (
+	"math"
+	"sort"
+)
+
+// sizeSketchExact is how many sizes a sketch keeps as they are before it
+// switches to buckets, so the percentiles of small samples are exact
+const sizeSketchExact = 128
+
+// sizeSketchGamma is the ratio between the bounds of consecutive buckets; the
+// value reported for a bucket is within 1% of every size in it
+const sizeSketchGamma = 1.02
+
+// sizeSketch estimates the percentiles of response sizes in bounded memory,
+// so modules can report them without keeping every size. Past
+// sizeSketchExact sizes it counts them in logarithmic buckets, of which no
+// more than a few thousand cover every int64.
+type sizeSketch struct {
+	count   int
+	min     int64
+	max     int64
+	exact   []int64     // Until buckets are used
+	buckets map[int]int // Bucket index → sizes in it
+}
+
+// Add records a size
+func (s *sizeSketch) Add(size int64) {
+	if s.count == 0 || size < s.min {
+		s.min = size
+	}
+	if s.count == 0 || size > s.max {
+		s.max = size
+	}
+	s.count++
+
+	if s.buckets != nil {
+		s.buckets[sizeSketchBucket(size)]++
+		return
+	}
+	s.exact = append(s.exact, size)
+	if len(s.exact) > sizeSketchExact {
+		s.buckets = make(map[int]int)
+		for _, exact := range s.exact {
+			s.buckets[sizeSketchBucket(exact)]++
+		}
+		s.exact = nil
+	}
+}
+
+// Count returns the number of sizes recorded
+func (s *sizeSketch) Count() int {
+	return s.count
+}
+
+// Percentile returns the p-th percentile, the size percentileSize would
+// pick from all of them sorted, or the value of its bucket
+func (s *sizeSketch) Percentile(p int) int64 {
+	if s.buckets == nil {
+		sorted := append([]int64(nil), s.exact...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		return percentileSize(sorted, p)
+	}
+
+	index := s.count * p / 100
+	if index >= s.count {
+		index = s.count - 1
+	}
+
+	indices := make([]int, 0, len(s.buckets))
+	for bucket := range s.buckets {
+		indices = append(indices, bucket)
+	}
+	sort.Ints(indices)
+	seen := 0
+	for _, bucket := range indices {
+		seen += s.buckets[bucket]
+		if seen > index {
+			return s.bucketValue(bucket)
+		}
+	}
+	return s.max
+}
+
+// sizeSketchBucket returns the bucket of a size: 0 for sizes up to 0, and i
+// for sizes in (gamma^(i-2), gamma^(i-1)]
+func sizeSketchBucket(size int64) int {
+	if size <= 0 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(float64(size))/math.Log(sizeSketchGamma))) + 1
+}
+
+// bucketValue returns the size reported for a bucket, the point with the
+// same relative distance to both of its bounds, within the sizes seen
+func (s *sizeSketch) bucketValue(bucket int) int64 {
+	value := int64(0)
+	if bucket > 0 {
+		value = int64(math.Round(2 * math.Pow(sizeSketchGamma, float64(bucket-1)) / (sizeSketchGamma + 1)))
+	}
+	if value < s.min {
+		return s.min
+	}
+	if value > s.max {
+		return s.max
+	}
+	return value
+}
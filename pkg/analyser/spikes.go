@@ -0,0 +1,204 @@
+package analyser
+
+import (
+	"sort"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// SpikeContributor is a single IP, URL, user agent or referer whose traffic
+// rose during a peak hour compared with the other hours
+type SpikeContributor struct {
+	Key           string
+	PeakCount     int     // Requests during the peak hour
+	BaselineCount float64 // Average requests per other active hour
+	Excess        float64 // PeakCount minus BaselineCount
+	Share         float64 // Percentage of the peak's excess traffic
+}
+
+// SpikeAttribution lists the biggest contributors to a traffic peak
+type SpikeAttribution struct {
+	IPs        []SpikeContributor
+	URLs       []SpikeContributor
+	UserAgents []SpikeContributor
+	Referers   []SpikeContributor
+}
+
+// maxSpikeContributors is the number of contributors kept per dimension
+const maxSpikeContributors = 5
+
+// spikeAttributionModule keeps per-hour counts for each dimension so that
+// peaks found by the hourly traffic module can be explained
+type spikeAttributionModule struct {
+	ips        map[int]map[string]int
+	urls       map[int]map[string]int
+	userAgents map[int]map[string]int
+	referers   map[int]map[string]int
+	hours      map[int]int
+}
+
+func newSpikeAttributionModule() *spikeAttributionModule {
+	return &spikeAttributionModule{
+		ips:        make(map[int]map[string]int),
+		urls:       make(map[int]map[string]int),
+		userAgents: make(map[int]map[string]int),
+		referers:   make(map[int]map[string]int),
+		hours:      make(map[int]int),
+	}
+}
+
+func (m *spikeAttributionModule) Name() string { return ModuleSpikes }
+
+func (m *spikeAttributionModule) Process(entry *parser.LogEntry) {
+	hour := entry.Timestamp.Hour()
+	m.hours[hour]++
+
+	referer := entry.Referer
+	if referer == "" || referer == "-" {
+		referer = "(direct)"
+	}
+
+	incrementHourly(m.ips, hour, entry.IP)
+	incrementHourly(m.urls, hour, entry.URL)
+	incrementHourly(m.userAgents, hour, entry.UserAgent)
+	incrementHourly(m.referers, hour, referer)
+}
+
+// Finalize attributes the peaks already written by the hourly traffic module
+func (m *spikeAttributionModule) Finalize(results *Results) {
+	for i := range results.TrafficPeaks {
+		peak := &results.TrafficPeaks[i]
+		if _, exists := m.hours[peak.Hour]; !exists {
+			continue
+		}
+
+		peak.Attribution = &SpikeAttribution{
+			IPs:        m.attribute(m.ips, peak.Hour),
+			URLs:       m.attribute(m.urls, peak.Hour),
+			UserAgents: m.attribute(m.userAgents, peak.Hour),
+			Referers:   m.attribute(m.referers, peak.Hour),
+		}
+	}
+}
+
+// attribute ranks keys by how far their peak-hour traffic exceeds their
+// average over the other active hours
+func (m *spikeAttributionModule) attribute(counts map[int]map[string]int, peakHour int) []SpikeContributor {
+	otherHours := len(m.hours) - 1
+	if otherHours < 1 {
+		return []SpikeContributor{}
+	}
+
+	baselineTotals := make(map[string]int)
+	for hour, keys := range counts {
+		if hour == peakHour {
+			continue
+		}
+		for key, count := range keys {
+			baselineTotals[key] += count
+		}
+	}
+
+	var contributors []SpikeContributor
+	for key, count := range counts[peakHour] {
+		baseline := float64(baselineTotals[key]) / float64(otherHours)
+		excess := float64(count) - baseline
+		if excess <= 0 {
+			continue
+		}
+		contributors = append(contributors, SpikeContributor{
+			Key:           key,
+			PeakCount:     count,
+			BaselineCount: baseline,
+			Excess:        excess,
+		})
+	}
+
+	baselineRequests := float64(0)
+	for hour, count := range m.hours {
+		if hour != peakHour {
+			baselineRequests += float64(count)
+		}
+	}
+	spikeExcess := float64(m.hours[peakHour]) - baselineRequests/float64(otherHours)
+
+	return rankSpikeContributors(contributors, spikeExcess)
+}
+
+// rankSpikeContributors sorts contributors by excess, keeps the top entries
+// and expresses each as a share of the spike's total excess
+func rankSpikeContributors(contributors []SpikeContributor, spikeExcess float64) []SpikeContributor {
+	sort.Slice(contributors, func(i, j int) bool {
+		if contributors[i].Excess != contributors[j].Excess {
+			return contributors[i].Excess > contributors[j].Excess
+		}
+		return contributors[i].Key < contributors[j].Key
+	})
+
+	if len(contributors) > maxSpikeContributors {
+		contributors = contributors[:maxSpikeContributors]
+	}
+
+	for i := range contributors {
+		if spikeExcess > 0 {
+			contributors[i].Share = contributors[i].Excess / spikeExcess * 100
+		}
+	}
+
+	if contributors == nil {
+		return []SpikeContributor{}
+	}
+	return contributors
+}
+
+func incrementHourly(counts map[int]map[string]int, hour int, key string) {
+	if counts[hour] == nil {
+		counts[hour] = make(map[string]int)
+	}
+	counts[hour][key]++
+}
+
+// mergeSpikeAttributions combines attributions for the same peak hour from
+// partial results. Only retained contributors can be combined, so the
+// merged ranking is approximate.
+func mergeSpikeAttributions(x, y *SpikeAttribution, spikeExcess float64) *SpikeAttribution {
+	if x == nil && y == nil {
+		return nil
+	}
+	if x == nil {
+		x = &SpikeAttribution{}
+	}
+	if y == nil {
+		y = &SpikeAttribution{}
+	}
+
+	merge := func(a, b []SpikeContributor) []SpikeContributor {
+		combined := make(map[string]*SpikeContributor)
+		for _, contributor := range append(append([]SpikeContributor{}, a...), b...) {
+			existing, exists := combined[contributor.Key]
+			if !exists {
+				copied := contributor
+				combined[contributor.Key] = &copied
+				continue
+			}
+			existing.PeakCount += contributor.PeakCount
+			existing.BaselineCount += contributor.BaselineCount
+			existing.Excess = float64(existing.PeakCount) - existing.BaselineCount
+		}
+
+		var contributors []SpikeContributor
+		for _, contributor := range combined {
+			if contributor.Excess > 0 {
+				contributors = append(contributors, *contributor)
+			}
+		}
+		return rankSpikeContributors(contributors, spikeExcess)
+	}
+
+	return &SpikeAttribution{
+		IPs:        merge(x.IPs, y.IPs),
+		URLs:       merge(x.URLs, y.URLs),
+		UserAgents: merge(x.UserAgents, y.UserAgents),
+		Referers:   merge(x.Referers, y.Referers),
+	}
+}
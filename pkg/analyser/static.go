@@ -0,0 +1,224 @@
+package analyser
+
+import (
+	"sort"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Content classes reported by the static/dynamic split
+const (
+	ContentStatic  = "Static"
+	ContentDynamic = "Dynamic"
+)
+
+// maxCacheCandidates limits the number of assets listed as missing cache headers
+const maxCacheCandidates = 20
+
+// ContentClassStat summarises requests for static assets or dynamic pages.
+// Response sizes stand in for latency, as elsewhere in the analysis.
+type ContentClassStat struct {
+	Class       string
+	Requests    int
+	Errors      int     // 4xx/5xx responses
+	ErrorRate   float64 // Percentage of requests resulting in errors
+	Bytes       int64
+	AverageSize int64
+	MedianSize  int64
+	P95Size     int64
+}
+
+// CacheCandidate is a static asset that the same clients download in full
+// more than once, suggesting missing or too short cache headers
+type CacheCandidate struct {
+	URL            string
+	FileType       string
+	Downloads      int   // Full (200) responses
+	RepeatClients  int   // IPs that downloaded the asset more than once
+	RepeatRequests int   // Downloads beyond each client's first
+	WastedBytes    int64 // Bytes transferred by the repeat downloads
+}
+
+// StaticDynamicReport splits traffic into static assets and dynamic requests
+type StaticDynamicReport struct {
+	Static          ContentClassStat
+	Dynamic         ContentClassStat
+	CacheCandidates []CacheCandidate // Most repeated downloads first
+}
+
+// isStaticFileType reports whether a getFileType category is a static asset
+func isStaticFileType(fileType string) bool {
+	return fileType != "Dynamic/HTML"
+}
+
+// staticSplitModule tracks static and dynamic traffic and repeat asset downloads
+type staticSplitModule struct {
+	stats     map[string]*ContentClassStat
+	sizes     map[string]*sizeSketch
+	downloads map[string]map[string]int // asset -> IP -> full downloads
+	assetSize map[string]int64
+	fileTypes map[string]string
+}
+
+func newStaticSplitModule() *staticSplitModule {
+	return &staticSplitModule{
+		stats: map[string]*ContentClassStat{
+			ContentStatic:  {Class: ContentStatic},
+			ContentDynamic: {Class: ContentDynamic},
+		},
+		sizes: map[string]*sizeSketch{
+			ContentStatic:  {},
+			ContentDynamic: {},
+		},
+		downloads: make(map[string]map[string]int),
+		assetSize: make(map[string]int64),
+		fileTypes: make(map[string]string),
+	}
+}
+
+func (m *staticSplitModule) Name() string { return ModuleStaticSplit }
+
+func (m *staticSplitModule) Process(entry *parser.LogEntry) {
+	fileType := getFileType(entry.URL)
+	class := ContentDynamic
+	if isStaticFileType(fileType) {
+		class = ContentStatic
+	}
+
+	stat := m.stats[class]
+	stat.Requests++
+	stat.Bytes += entry.Size
+	if entry.Status >= 400 {
+		stat.Errors++
+	}
+	m.sizes[class].Add(entry.Size)
+
+	// A 304 means the client revalidated its cached copy, so only full
+	// downloads count towards repeat requests
+	if class != ContentStatic || entry.Status != 200 {
+		return
+	}
+	if m.downloads[entry.URL] == nil {
+		m.downloads[entry.URL] = make(map[string]int)
+		m.fileTypes[entry.URL] = fileType
+	}
+	m.downloads[entry.URL][entry.IP]++
+	if entry.Size > m.assetSize[entry.URL] {
+		m.assetSize[entry.URL] = entry.Size
+	}
+}
+
+func (m *staticSplitModule) Finalize(results *Results) {
+	report := StaticDynamicReport{CacheCandidates: []CacheCandidate{}}
+	for _, class := range []string{ContentStatic, ContentDynamic} {
+		stat := *m.stats[class]
+		stat.MedianSize = m.sizes[class].Percentile(50)
+		stat.P95Size = m.sizes[class].Percentile(95)
+		finishContentClassStat(&stat)
+
+		if class == ContentStatic {
+			report.Static = stat
+		} else {
+			report.Dynamic = stat
+		}
+	}
+
+	for url, clients := range m.downloads {
+		candidate := CacheCandidate{URL: url, FileType: m.fileTypes[url]}
+		for _, downloads := range clients {
+			candidate.Downloads += downloads
+			if downloads > 1 {
+				candidate.RepeatClients++
+				candidate.RepeatRequests += downloads - 1
+			}
+		}
+		if candidate.RepeatRequests == 0 {
+			continue
+		}
+		candidate.WastedBytes = int64(candidate.RepeatRequests) * m.assetSize[url]
+		report.CacheCandidates = append(report.CacheCandidates, candidate)
+	}
+	report.CacheCandidates = sortCacheCandidates(report.CacheCandidates)
+
+	results.StaticDynamic = report
+}
+
+// finishContentClassStat derives the error rate and average size from the totals
+func finishContentClassStat(stat *ContentClassStat) {
+	stat.ErrorRate = 0
+	stat.AverageSize = 0
+	if stat.Requests > 0 {
+		stat.ErrorRate = float64(stat.Errors) / float64(stat.Requests) * 100
+		stat.AverageSize = stat.Bytes / int64(stat.Requests)
+	}
+}
+
+// sortCacheCandidates orders candidates by repeat downloads and keeps the top entries
+func sortCacheCandidates(candidates []CacheCandidate) []CacheCandidate {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].RepeatRequests != candidates[j].RepeatRequests {
+			return candidates[i].RepeatRequests > candidates[j].RepeatRequests
+		}
+		return candidates[i].URL < candidates[j].URL
+	})
+
+	if len(candidates) > maxCacheCandidates {
+		candidates = candidates[:maxCacheCandidates]
+	}
+	return candidates
+}
+
+// mergeContentClassStats combines two class summaries; percentiles are
+// approximated by weighting each part's value by its request count
+func mergeContentClassStats(x, y ContentClassStat) ContentClassStat {
+	merged := ContentClassStat{
+		Class:    x.Class,
+		Requests: x.Requests + y.Requests,
+		Errors:   x.Errors + y.Errors,
+		Bytes:    x.Bytes + y.Bytes,
+	}
+	if merged.Class == "" {
+		merged.Class = y.Class
+	}
+	if merged.Requests > 0 {
+		weighted := func(a, b int64) int64 {
+			return (a*int64(x.Requests) + b*int64(y.Requests)) / int64(merged.Requests)
+		}
+		merged.MedianSize = weighted(x.MedianSize, y.MedianSize)
+		merged.P95Size = weighted(x.P95Size, y.P95Size)
+	}
+	finishContentClassStat(&merged)
+	return merged
+}
+
+// mergeStaticDynamicReports combines partial reports. Repeat downloads are
+// only detected within each part, so clients whose repeats span parts are
+// undercounted.
+func mergeStaticDynamicReports(x, y StaticDynamicReport) StaticDynamicReport {
+	merged := StaticDynamicReport{
+		Static:  mergeContentClassStats(x.Static, y.Static),
+		Dynamic: mergeContentClassStats(x.Dynamic, y.Dynamic),
+	}
+
+	candidates := make(map[string]*CacheCandidate)
+	for _, candidate := range append(append([]CacheCandidate{}, x.CacheCandidates...), y.CacheCandidates...) {
+		existing, exists := candidates[candidate.URL]
+		if !exists {
+			copied := candidate
+			candidates[candidate.URL] = &copied
+			continue
+		}
+		existing.Downloads += candidate.Downloads
+		existing.RepeatClients += candidate.RepeatClients
+		existing.RepeatRequests += candidate.RepeatRequests
+		existing.WastedBytes += candidate.WastedBytes
+	}
+
+	merged.CacheCandidates = []CacheCandidate{}
+	for _, candidate := range candidates {
+		merged.CacheCandidates = append(merged.CacheCandidates, *candidate)
+	}
+	merged.CacheCandidates = sortCacheCandidates(merged.CacheCandidates)
+
+	return merged
+}
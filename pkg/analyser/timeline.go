@@ -0,0 +1,150 @@
+package analyser
+
+import (
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// RequestTimeline counts requests, errors and bytes per minute, so traffic can
+// be charted over time at any bucket size of a minute or more
+type RequestTimeline struct {
+	perMinute map[int64]timelineCount // Keyed by Unix minute
+	location  *time.Location          // Time zone of the logs, used to align buckets
+}
+
+// timelineCount is the number of requests, 4xx/5xx errors and bytes sent in
+// one minute
+type timelineCount struct {
+	requests int
+	errors   int
+	bytes    int64
+}
+
+// TimelineBucket is the traffic of one bucket of a request timeline
+type TimelineBucket struct {
+	Start    time.Time
+	Requests int
+	Errors   int // 4xx and 5xx responses
+	Bytes    int64
+}
+
+// ErrorRate returns the percentage of requests in the bucket that failed
+func (b TimelineBucket) ErrorRate() float64 {
+	if b.Requests == 0 {
+		return 0
+	}
+	return float64(b.Errors) * 100 / float64(b.Requests)
+}
+
+// Span returns the time from the first to the end of the last minute with
+// requests
+func (t *RequestTimeline) Span() time.Duration {
+	if t == nil || len(t.perMinute) == 0 {
+		return 0
+	}
+	first, last := t.bounds()
+	return time.Duration(last-first+1) * time.Minute
+}
+
+// Buckets returns the request, error and byte counts per bucket of the given size,
+// from the bucket of the first request to that of the last, including empty
+// buckets. Buckets are aligned to the time zone of the logs, so hourly buckets
+// start on the hour; sizes are rounded up to whole minutes.
+func (t *RequestTimeline) Buckets(size time.Duration) []TimelineBucket {
+	if t == nil || len(t.perMinute) == 0 {
+		return nil
+	}
+	if size < time.Minute {
+		size = time.Minute
+	}
+	minutes := int64((size + time.Minute - 1) / time.Minute)
+
+	location := t.location
+	if location == nil {
+		location = time.UTC
+	}
+	first, last := t.bounds()
+	_, offset := time.Unix(first*60, 0).In(location).Zone()
+	shift := int64(offset / 60)
+
+	start := floorDiv(first+shift, minutes)
+	buckets := make([]TimelineBucket, floorDiv(last+shift, minutes)-start+1)
+	for i := range buckets {
+		buckets[i].Start = time.Unix(((start+int64(i))*minutes-shift)*60, 0).In(location)
+	}
+	for minute, count := range t.perMinute {
+		bucket := &buckets[floorDiv(minute+shift, minutes)-start]
+		bucket.Requests += count.requests
+		bucket.Errors += count.errors
+		bucket.Bytes += count.bytes
+	}
+	return buckets
+}
+
+// bounds returns the first and last minute with requests
+func (t *RequestTimeline) bounds() (int64, int64) {
+	minutes := make([]int64, 0, len(t.perMinute))
+	for minute := range t.perMinute {
+		minutes = append(minutes, minute)
+	}
+	sort.Slice(minutes, func(i, j int) bool { return minutes[i] < minutes[j] })
+	return minutes[0], minutes[len(minutes)-1]
+}
+
+// timelineModule counts requests, errors and bytes per minute
+type timelineModule struct {
+	timeline *RequestTimeline
+}
+
+func newTimelineModule() *timelineModule {
+	return &timelineModule{timeline: &RequestTimeline{perMinute: make(map[int64]timelineCount)}}
+}
+
+func (m *timelineModule) Name() string { return ModuleTimeline }
+
+func (m *timelineModule) Process(entry *parser.LogEntry) {
+	if m.timeline.location == nil {
+		m.timeline.location = entry.Timestamp.Location()
+	}
+
+	minute := floorDiv(entry.Timestamp.Unix(), 60)
+	count := m.timeline.perMinute[minute]
+	count.requests++
+	count.bytes += entry.Size
+	if entry.Status >= 400 {
+		count.errors++
+	}
+	m.timeline.perMinute[minute] = count
+}
+
+func (m *timelineModule) Finalize(results *Results) {
+	results.Timeline = m.timeline
+}
+
+// mergeTimelines sums the per-minute counts of two timelines. Results loaded
+// from an export have no timeline, so the other one is kept as is.
+func mergeTimelines(x, y *RequestTimeline) *RequestTimeline {
+	if x == nil {
+		return y
+	}
+	if y == nil {
+		return x
+	}
+
+	merged := &RequestTimeline{perMinute: make(map[int64]timelineCount, len(x.perMinute)+len(y.perMinute)), location: x.location}
+	if merged.location == nil {
+		merged.location = y.location
+	}
+	for _, timeline := range []*RequestTimeline{x, y} {
+		for minute, count := range timeline.perMinute {
+			total := merged.perMinute[minute]
+			total.requests += count.requests
+			total.errors += count.errors
+			total.bytes += count.bytes
+			merged.perMinute[minute] = total
+		}
+	}
+	return merged
+}
@@ -0,0 +1,62 @@
+package analyser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TopAll is the list limit meaning "no limit"
+const TopAll = 0
+
+// DefaultExportTopN is the number of entries written per ranked list in CSV exports
+const DefaultExportTopN = 20
+
+// ParseTopN parses a list limit given as a positive number or "all"
+func ParseTopN(value string) (int, error) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	if value == "all" {
+		return TopAll, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid top value %q (expected a positive number or \"all\")", value)
+	}
+
+	return n, nil
+}
+
+// LimitTop returns a copy of the results with the ranked lists (top IPs,
+// URLs, bots, file types, error URLs, large requests and endpoints) cut to
+// n entries. A limit of TopAll returns the results unchanged.
+func (r *Results) LimitTop(n int) *Results {
+	if n <= TopAll {
+		return r
+	}
+
+	limited := *r
+	if len(limited.TopIPs) > n {
+		limited.TopIPs = limited.TopIPs[:n]
+	}
+	if len(limited.TopURLs) > n {
+		limited.TopURLs = limited.TopURLs[:n]
+	}
+	if len(limited.TopBots) > n {
+		limited.TopBots = limited.TopBots[:n]
+	}
+	if len(limited.FileTypes) > n {
+		limited.FileTypes = limited.FileTypes[:n]
+	}
+	if len(limited.ErrorURLs) > n {
+		limited.ErrorURLs = limited.ErrorURLs[:n]
+	}
+	if len(limited.LargeRequests) > n {
+		limited.LargeRequests = limited.LargeRequests[:n]
+	}
+	if len(limited.EndpointStats) > n {
+		limited.EndpointStats = limited.EndpointStats[:n]
+	}
+
+	return &limited
+}
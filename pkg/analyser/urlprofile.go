@@ -0,0 +1,73 @@
+package analyser
+
+import (
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// URLProfile is a full drill-down of the requests made for a single URL
+type URLProfile struct {
+	URL           string
+	TotalRequests int
+	TotalBytes    int64
+	FirstSeen     time.Time
+	LastSeen      time.Time
+	StatusCodes   []DetailedStatusCode
+	Methods       []MethodStat
+	Clients       []IPStat
+	UserAgents    []UserAgentStat
+	Timeline      []IPTimelineBucket
+	Threats       []SecurityThreat
+}
+
+// ProfileURLs builds drill-down profiles for several URLs in a single pass
+// over the logs, keyed by URL. URLs that were never requested are left out.
+func (a *Analyser) ProfileURLs(logs []*parser.LogEntry, urls []string) map[string]*URLProfile {
+	urlLogs := make(map[string][]*parser.LogEntry, len(urls))
+	for _, url := range urls {
+		urlLogs[url] = nil
+	}
+	for _, log := range logs {
+		if entries, ok := urlLogs[log.URL]; ok {
+			urlLogs[log.URL] = append(entries, log)
+		}
+	}
+
+	profiles := make(map[string]*URLProfile)
+	for url, entries := range urlLogs {
+		if len(entries) > 0 {
+			profiles[url] = a.profileURL(url, entries)
+		}
+	}
+	return profiles
+}
+
+// profileURL builds the profile of a URL from all of its requests
+func (a *Analyser) profileURL(url string, urlLogs []*parser.LogEntry) *URLProfile {
+	sort.SliceStable(urlLogs, func(i, j int) bool {
+		return urlLogs[i].Timestamp.Before(urlLogs[j].Timestamp)
+	})
+
+	timeRange := a.calculateTimeRange(urlLogs)
+
+	clients := make(map[string]int)
+	for _, log := range urlLogs {
+		clients[log.IP]++
+	}
+
+	return &URLProfile{
+		URL:           url,
+		TotalRequests: len(urlLogs),
+		TotalBytes:    a.calculateTotalBytes(urlLogs),
+		FirstSeen:     timeRange.Start,
+		LastSeen:      timeRange.End,
+		StatusCodes:   a.analyseDetailedStatusCodes(urlLogs),
+		Methods:       a.analyseHTTPMethods(urlLogs),
+		Clients:       sortIPCounts(clients),
+		UserAgents:    analyseUserAgents(urlLogs),
+		Timeline:      buildIPTimeline(urlLogs),
+		Threats:       a.analyseSecurityThreats(urlLogs).ThreatsDetected,
+	}
+}
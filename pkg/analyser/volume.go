@@ -0,0 +1,257 @@
+package analyser
+
+import (
+	"math"
+	"sort"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Directions reported by volume anomaly detection
+const (
+	VolumeDownload = "download"
+	VolumeUpload   = "upload"
+)
+
+// Minimum volumes before a z-score alone flags an IP, so that small sites
+// with mostly idle clients do not report every moderately active visitor
+const (
+	minZScoreDownloadBytes   = 10 * 1024 * 1024
+	minZScoreUploadRequests  = 10
+	maxReportedVolumeAnomaly = 50
+)
+
+// VolumeThresholds controls when an IP's transfer volume is reported as a
+// possible data exfiltration indicator. Access logs do not record request
+// body sizes, so uploads are measured in upload requests (POST, PUT, PATCH).
+type VolumeThresholds struct {
+	DownloadBytes  int64   // Bytes sent to one IP that are always reported (0 disables)
+	UploadRequests int     // Upload requests from one IP that are always reported (0 disables)
+	ZScore         float64 // Standard deviations above the mean of all IPs (0 disables)
+}
+
+// DefaultVolumeThresholds returns the default thresholds (1GB, 500 uploads, z-score 3)
+func DefaultVolumeThresholds() VolumeThresholds {
+	return VolumeThresholds{
+		DownloadBytes:  1024 * 1024 * 1024,
+		UploadRequests: 500,
+		ZScore:         3.0,
+	}
+}
+
+// VolumeAnomaly is an IP that transferred an abnormal amount of data
+type VolumeAnomaly struct {
+	IP             string
+	Direction      string  // "download" or "upload"
+	Requests       int     // All requests from the IP
+	Bytes          int64   // Bytes sent to the IP
+	UploadRequests int     // POST/PUT/PATCH requests from the IP
+	ZScore         float64 // Standard deviations above the mean of all IPs
+	Reason         string  // "absolute", "z-score" or "absolute+z-score"
+}
+
+// VolumeReport lists IPs with abnormal download or upload volumes
+type VolumeReport struct {
+	Thresholds    VolumeThresholds
+	MeanBytes     float64 // Mean bytes sent per IP
+	StdDevBytes   float64
+	MeanUploads   float64 // Mean upload requests per IP
+	StdDevUploads float64
+	Anomalies     []VolumeAnomaly // Highest z-score first
+
+	ips map[string]*ipVolume // Per-IP totals, kept for merging
+}
+
+// ipVolume holds the transfer totals of one IP
+type ipVolume struct {
+	requests int
+	bytes    int64
+	uploads  int
+}
+
+// SetVolumeThresholds sets the thresholds used for volume anomaly detection
+func (a *Analyser) SetVolumeThresholds(thresholds VolumeThresholds) {
+	a.volumeThresholds = thresholds
+}
+
+// isUploadMethod reports whether a request method carries a request body
+func isUploadMethod(method string) bool {
+	switch method {
+	case "POST", "PUT", "PATCH":
+		return true
+	default:
+		return false
+	}
+}
+
+// volumeModule accumulates transfer totals per IP
+type volumeModule struct {
+	analyser *Analyser
+	ips      map[string]*ipVolume
+}
+
+func newVolumeModule(a *Analyser) *volumeModule {
+	return &volumeModule{
+		analyser: a,
+		ips:      make(map[string]*ipVolume),
+	}
+}
+
+func (m *volumeModule) Name() string { return ModuleVolume }
+
+func (m *volumeModule) Process(entry *parser.LogEntry) {
+	volume, exists := m.ips[entry.IP]
+	if !exists {
+		volume = &ipVolume{}
+		m.ips[entry.IP] = volume
+	}
+
+	volume.requests++
+	volume.bytes += entry.Size
+	if isUploadMethod(entry.Method) {
+		volume.uploads++
+	}
+}
+
+func (m *volumeModule) Finalize(results *Results) {
+	results.DataVolume = buildVolumeReport(m.ips, m.analyser.volumeThresholds)
+}
+
+// buildVolumeReport flags IPs whose download bytes or upload requests exceed
+// the absolute thresholds or lie far above the mean of all IPs
+func buildVolumeReport(ips map[string]*ipVolume, thresholds VolumeThresholds) VolumeReport {
+	report := VolumeReport{
+		Thresholds: thresholds,
+		Anomalies:  []VolumeAnomaly{},
+		ips:        ips,
+	}
+	if len(ips) == 0 {
+		return report
+	}
+
+	var bytes, uploads []float64
+	for _, volume := range ips {
+		bytes = append(bytes, float64(volume.bytes))
+		uploads = append(uploads, float64(volume.uploads))
+	}
+	report.MeanBytes, report.StdDevBytes = meanStdDev(bytes)
+	report.MeanUploads, report.StdDevUploads = meanStdDev(uploads)
+
+	for ip, volume := range ips {
+		downloadZ := zScore(float64(volume.bytes), report.MeanBytes, report.StdDevBytes)
+		reason := volumeReason(
+			thresholds.DownloadBytes > 0 && volume.bytes >= thresholds.DownloadBytes,
+			thresholds.ZScore > 0 && downloadZ >= thresholds.ZScore && volume.bytes >= minZScoreDownloadBytes,
+		)
+		if reason != "" {
+			report.Anomalies = append(report.Anomalies, newVolumeAnomaly(ip, VolumeDownload, volume, downloadZ, reason))
+		}
+
+		uploadZ := zScore(float64(volume.uploads), report.MeanUploads, report.StdDevUploads)
+		reason = volumeReason(
+			thresholds.UploadRequests > 0 && volume.uploads >= thresholds.UploadRequests,
+			thresholds.ZScore > 0 && uploadZ >= thresholds.ZScore && volume.uploads >= minZScoreUploadRequests,
+		)
+		if reason != "" {
+			report.Anomalies = append(report.Anomalies, newVolumeAnomaly(ip, VolumeUpload, volume, uploadZ, reason))
+		}
+	}
+
+	sort.Slice(report.Anomalies, func(i, j int) bool {
+		if report.Anomalies[i].ZScore != report.Anomalies[j].ZScore {
+			return report.Anomalies[i].ZScore > report.Anomalies[j].ZScore
+		}
+		if report.Anomalies[i].IP != report.Anomalies[j].IP {
+			return report.Anomalies[i].IP < report.Anomalies[j].IP
+		}
+		return report.Anomalies[i].Direction < report.Anomalies[j].Direction
+	})
+	if len(report.Anomalies) > maxReportedVolumeAnomaly {
+		report.Anomalies = report.Anomalies[:maxReportedVolumeAnomaly]
+	}
+
+	return report
+}
+
+func newVolumeAnomaly(ip, direction string, volume *ipVolume, z float64, reason string) VolumeAnomaly {
+	return VolumeAnomaly{
+		IP:             ip,
+		Direction:      direction,
+		Requests:       volume.requests,
+		Bytes:          volume.bytes,
+		UploadRequests: volume.uploads,
+		ZScore:         z,
+		Reason:         reason,
+	}
+}
+
+// volumeReason describes which thresholds were exceeded
+func volumeReason(absolute, statistical bool) string {
+	switch {
+	case absolute && statistical:
+		return "absolute+z-score"
+	case absolute:
+		return "absolute"
+	case statistical:
+		return "z-score"
+	default:
+		return ""
+	}
+}
+
+// meanStdDev returns the mean and population standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// zScore returns how many standard deviations value lies above the mean
+func zScore(value, mean, stdDev float64) float64 {
+	if stdDev == 0 {
+		return 0
+	}
+	return (value - mean) / stdDev
+}
+
+// mergeVolumeReports combines volume reports by summing the per-IP totals.
+// Reports without per-IP totals (e.g. loaded from an export) keep the union
+// of their anomalies.
+func mergeVolumeReports(x, y VolumeReport) VolumeReport {
+	if x.ips != nil && y.ips != nil {
+		ips := make(map[string]*ipVolume, len(x.ips)+len(y.ips))
+		for _, part := range []map[string]*ipVolume{x.ips, y.ips} {
+			for ip, volume := range part {
+				merged, exists := ips[ip]
+				if !exists {
+					merged = &ipVolume{}
+					ips[ip] = merged
+				}
+				merged.requests += volume.requests
+				merged.bytes += volume.bytes
+				merged.uploads += volume.uploads
+			}
+		}
+		return buildVolumeReport(ips, x.Thresholds)
+	}
+
+	merged := x
+	merged.ips = nil
+	merged.Anomalies = append(append([]VolumeAnomaly{}, x.Anomalies...), y.Anomalies...)
+	sort.Slice(merged.Anomalies, func(i, j int) bool {
+		return merged.Anomalies[i].ZScore > merged.Anomalies[j].ZScore
+	})
+	return merged
+}
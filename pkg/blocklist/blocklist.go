@@ -0,0 +1,169 @@
+// Package blocklist turns the suspicious IPs of a security analysis into
+// blocking rules for nginx, iptables, nftables and Cloudflare
+package blocklist
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// DefaultMinScore is the threat score from which an IP is blocked, the score
+// the analyser treats as a high threat
+const DefaultMinScore = 50
+
+// Formats lists the blocklist formats
+var Formats = []string{"nginx", "iptables", "nftables", "cloudflare", "csv"}
+
+// Entry is an IP to block with the findings behind it
+type Entry struct {
+	IP         string
+	Score      int
+	Categories []string
+	Techniques []string // MITRE ATT&CK technique IDs of the categories
+	Requests   int
+	FirstSeen  time.Time
+	LastSeen   time.Time
+}
+
+// Options control the generated rules
+type Options struct {
+	ExpiryDays  int       // Days the rules are meant to stay in place (0 for no expiry)
+	GeneratedAt time.Time // Time the rules were generated, the start of the expiry
+	MinScore    int       // Threat score threshold, noted in the header
+}
+
+// expires returns the expiry time of the rules, zero without an expiry
+func (o Options) expires() time.Time {
+	if o.ExpiryDays <= 0 {
+		return time.Time{}
+	}
+	return o.GeneratedAt.AddDate(0, 0, o.ExpiryDays)
+}
+
+// FormatFromFilename picks the blocklist format from a file extension: .conf
+// for nginx, .sh for iptables, .nft for nftables and .csv for CSV. Cloudflare
+// lists are CSV too, so they need an explicit format.
+func FormatFromFilename(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".conf":
+		return "nginx", nil
+	case ".sh":
+		return "iptables", nil
+	case ".nft":
+		return "nftables", nil
+	case ".csv":
+		return "csv", nil
+	default:
+		return "", fmt.Errorf("cannot tell the blocklist format of %s: use a .conf, .sh, .nft or .csv extension, or choose one of %s",
+			filename, strings.Join(Formats, ", "))
+	}
+}
+
+// FromResults returns the suspicious IPs with a threat score of at least
+// minScore, highest score first. Private, loopback and link-local addresses
+// are left out, so generated rules never lock out internal clients.
+func FromResults(results *analyser.Results, minScore int) []Entry {
+	var entries []Entry
+	for _, suspicious := range results.SecurityAnalysis.SuspiciousIPs {
+		if suspicious.ThreatScore < minScore || !blockable(suspicious.IP) {
+			continue
+		}
+		entries = append(entries, Entry{
+			IP:         net.ParseIP(suspicious.IP).String(),
+			Score:      suspicious.ThreatScore,
+			Categories: suspicious.ThreatCategories,
+			Techniques: suspicious.MITRETechniques,
+			Requests:   suspicious.RequestCount,
+			FirstSeen:  suspicious.FirstSeen,
+			LastSeen:   suspicious.LastSeen,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Score > entries[j].Score
+	})
+	return entries
+}
+
+// blockable reports whether ip is a valid public address. Only addresses
+// that parse are written, so log content cannot end up in the rules.
+func blockable(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	return !parsed.IsPrivate() && !parsed.IsLoopback() && !parsed.IsLinkLocalUnicast() && !parsed.IsUnspecified()
+}
+
+// Write writes the entries as blocking rules in the given format
+func Write(w io.Writer, format string, entries []Entry, options Options) error {
+	switch format {
+	case "nginx":
+		return writeNginx(w, entries, options)
+	case "iptables":
+		return writeIptables(w, entries, options)
+	case "nftables":
+		return writeNftables(w, entries, options)
+	case "cloudflare":
+		return writeCloudflare(w, entries, options)
+	case "csv":
+		return writeCSV(w, entries, options)
+	default:
+		return fmt.Errorf("unknown blocklist format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteFile writes the entries as blocking rules to filename
+func WriteFile(filename, format string, entries []Entry, options Options) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	if err := Write(buffered, format, entries, options); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// header returns the comment lines at the top of generated rules
+func header(prefix string, entries []Entry, options Options) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Generated by smart-log-analyser on %s\n", prefix, options.GeneratedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "%s %d IP(s) with a threat score of at least %d\n", prefix, len(entries), options.MinScore)
+	if expires := options.expires(); !expires.IsZero() {
+		fmt.Fprintf(&b, "%s Rules expire on %s; regenerate or remove them then\n", prefix, expires.Format("2006-01-02"))
+	}
+	return b.String()
+}
+
+// comment describes why an entry is blocked
+func comment(entry Entry, options Options) string {
+	text := fmt.Sprintf("score %d", entry.Score)
+	if len(entry.Categories) > 0 {
+		text += ": " + strings.Join(entry.Categories, ", ")
+	}
+	text += fmt.Sprintf("; %d requests", entry.Requests)
+	if expires := options.expires(); !expires.IsZero() {
+		text += "; expires " + expires.Format("2006-01-02")
+	}
+	return text
+}
@@ -0,0 +1,161 @@
+package blocklist
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// nftTable is the nftables table that holds the blocklist
+const nftTable = "smart_log_analyser"
+
+// Longest comments iptables and nftables accept, in bytes
+const (
+	iptablesCommentLimit = 255
+	nftCommentLimit      = 127
+)
+
+// writeNginx writes an include file of deny rules
+func writeNginx(w io.Writer, entries []Entry, options Options) error {
+	var b strings.Builder
+	b.WriteString(header("#", entries, options))
+	b.WriteString("# Include from an http, server or location block: include /etc/nginx/blocklist.conf;\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "deny %s; # %s\n", entry.IP, comment(entry, options))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeIptables writes a shell script that adds a DROP rule per IP, using
+// ip6tables for IPv6 addresses. Rules that exist already are skipped, so the
+// script can be run again after regenerating it.
+func writeIptables(w io.Writer, entries []Entry, options Options) error {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString(header("#", entries, options))
+	for _, entry := range entries {
+		command := "iptables"
+		if isIPv6(entry.IP) {
+			command = "ip6tables"
+		}
+		rule := fmt.Sprintf("INPUT -s %s -m comment --comment %q -j DROP", entry.IP, truncate(shellSafe("smart-log-analyser "+comment(entry, options)), iptablesCommentLimit))
+		fmt.Fprintf(&b, "%s -C %s 2>/dev/null || %s -I %s\n", command, rule, command, rule)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeNftables writes an nft script that replaces the blocklist table, with
+// a set per address family. With an expiry the elements time out on their
+// own.
+func writeNftables(w io.Writer, entries []Entry, options Options) error {
+	var v4, v6 []Entry
+	for _, entry := range entries {
+		if isIPv6(entry.IP) {
+			v6 = append(v6, entry)
+		} else {
+			v4 = append(v4, entry)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/usr/sbin/nft -f\n")
+	b.WriteString(header("#", entries, options))
+	fmt.Fprintf(&b, "add table inet %s\ndelete table inet %s\n\n", nftTable, nftTable)
+	fmt.Fprintf(&b, "table inet %s {\n", nftTable)
+	writeNftSet(&b, "blocklist_v4", "ipv4_addr", v4, options)
+	writeNftSet(&b, "blocklist_v6", "ipv6_addr", v6, options)
+	b.WriteString("\tchain input {\n")
+	b.WriteString("\t\ttype filter hook input priority filter; policy accept;\n")
+	b.WriteString("\t\tip saddr @blocklist_v4 drop\n")
+	b.WriteString("\t\tip6 saddr @blocklist_v6 drop\n")
+	b.WriteString("\t}\n}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeNftSet(b *strings.Builder, name, addressType string, entries []Entry, options Options) {
+	fmt.Fprintf(b, "\tset %s {\n\t\ttype %s\n", name, addressType)
+	if options.ExpiryDays > 0 {
+		b.WriteString("\t\tflags timeout\n")
+	}
+	if len(entries) > 0 {
+		b.WriteString("\t\telements = {\n")
+		for i, entry := range entries {
+			timeout := ""
+			if options.ExpiryDays > 0 {
+				timeout = fmt.Sprintf(" timeout %dd", options.ExpiryDays)
+			}
+			separator := ","
+			if i == len(entries)-1 {
+				separator = ""
+			}
+			fmt.Fprintf(b, "\t\t\t%s%s comment %q%s\n", entry.IP, timeout, truncate(shellSafe(comment(entry, options)), nftCommentLimit), separator)
+		}
+		b.WriteString("\t\t}\n")
+	}
+	b.WriteString("\t}\n")
+}
+
+// writeCloudflare writes an IP list for upload to Cloudflare: one address
+// per line with its description, without a header
+func writeCloudflare(w io.Writer, entries []Entry, options Options) error {
+	writer := csv.NewWriter(w)
+	for _, entry := range entries {
+		writer.Write([]string{entry.IP, "smart-log-analyser " + comment(entry, options)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeCSV writes the entries with their findings and expiry
+func writeCSV(w io.Writer, entries []Entry, options Options) error {
+	expires := ""
+	if t := options.expires(); !t.IsZero() {
+		expires = t.Format("2006-01-02")
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"ip", "threat_score", "categories", "mitre_techniques", "requests", "first_seen", "last_seen", "expires"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.IP,
+			strconv.Itoa(entry.Score),
+			strings.Join(entry.Categories, ";"),
+			strings.Join(entry.Techniques, ";"),
+			strconv.Itoa(entry.Requests),
+			entry.FirstSeen.Format("2006-01-02 15:04:05"),
+			entry.LastSeen.Format("2006-01-02 15:04:05"),
+			expires,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func isIPv6(ip string) bool {
+	return net.ParseIP(ip).To4() == nil
+}
+
+// shellSafe drops the characters that would end a quoted comment early
+func shellSafe(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '"', '\\', '$', '`':
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// truncate shortens text to at most limit bytes; comments are ASCII
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	return text[:limit]
+}
@@ -0,0 +1,122 @@
+package charts
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ChartData is the series behind a chart, so the numbers can be plotted
+// again in other tools
+type ChartData struct {
+	Name    string          `json:"name"` // File name without extension, e.g. "status_codes"
+	Title   string          `json:"title"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// SetCollectData enables or disables keeping the data of each generated chart
+func (g *ChartGenerator) SetCollectData(enabled bool) {
+	g.collectData = enabled
+	if !enabled {
+		g.data = nil
+	}
+}
+
+// Data returns the data of the charts generated since collection was
+// enabled, in the order they were first generated. A chart generated again
+// replaces its earlier data.
+func (g *ChartGenerator) Data() []ChartData {
+	return g.data
+}
+
+// record keeps the data of a chart when collection is enabled
+func (g *ChartGenerator) record(name, title string, columns []string, rows [][]interface{}) {
+	if !g.collectData {
+		return
+	}
+
+	data := ChartData{Name: name, Title: title, Columns: columns, Rows: rows}
+	if data.Rows == nil {
+		data.Rows = [][]interface{}{}
+	}
+	for i := range g.data {
+		if g.data[i].Name == name {
+			g.data[i] = data
+			return
+		}
+	}
+	g.data = append(g.data, data)
+}
+
+// WriteChartData writes each chart's data to dir as <name>.json and
+// <name>.csv, creating dir if needed, and returns the paths written
+func WriteChartData(dir string, data []ChartData) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create chart data directory: %w", err)
+	}
+
+	var paths []string
+	for _, chart := range data {
+		jsonPath := filepath.Join(dir, chart.Name+".json")
+		encoded, err := json.MarshalIndent(chart, "", "  ")
+		if err != nil {
+			return paths, fmt.Errorf("failed to encode %s: %w", chart.Name, err)
+		}
+		if err := os.WriteFile(jsonPath, append(encoded, '\n'), 0644); err != nil {
+			return paths, fmt.Errorf("failed to write %s: %w", jsonPath, err)
+		}
+		paths = append(paths, jsonPath)
+
+		csvPath := filepath.Join(dir, chart.Name+".csv")
+		if err := writeChartCSV(csvPath, chart); err != nil {
+			return paths, fmt.Errorf("failed to write %s: %w", csvPath, err)
+		}
+		paths = append(paths, csvPath)
+	}
+	return paths, nil
+}
+
+// writeChartCSV writes a chart's columns as the header row followed by its rows
+func writeChartCSV(path string, chart ChartData) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(chart.Columns); err != nil {
+		return err
+	}
+	for _, row := range chart.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = formatChartValue(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatChartValue formats a value for a CSV cell, writing times as RFC 3339
+// and floats without exponents
+func formatChartValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -2,6 +2,7 @@ package charts
 
 import (
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -42,30 +43,6 @@ const (
 	ColorBgWhite   = "\033[47m"
 )
 
-// Chart color palette for different data types
-var (
-	StatusCodeColors = map[string]string{
-		"2xx": ColorGreen,      // Success - Green
-		"3xx": ColorYellow,     // Redirect - Yellow  
-		"4xx": ColorRed,        // Client Error - Red
-		"5xx": ColorMagenta,    // Server Error - Magenta
-		"1xx": ColorCyan,       // Info - Cyan
-	}
-	
-	TrafficColors = []string{
-		ColorBlue,
-		ColorCyan,
-		ColorGreen,
-		ColorYellow,
-		ColorMagenta,
-		ColorRed,
-		ColorBrightBlue,
-		ColorBrightCyan,
-	}
-	
-	DefaultBarColor = ColorBlue
-)
-
 // SupportsColor checks if the terminal supports color output
 func SupportsColor() bool {
 	// Check TERM environment variable
@@ -101,30 +78,14 @@ func SupportsColor() bool {
 	return false
 }
 
-// GetStatusCodeColor returns the appropriate color for an HTTP status code
+// GetStatusCodeColor returns the default theme's color for an HTTP status code
 func GetStatusCodeColor(statusCode int) string {
-	switch {
-	case statusCode >= 200 && statusCode < 300:
-		return StatusCodeColors["2xx"]
-	case statusCode >= 300 && statusCode < 400:
-		return StatusCodeColors["3xx"]
-	case statusCode >= 400 && statusCode < 500:
-		return StatusCodeColors["4xx"]
-	case statusCode >= 500 && statusCode < 600:
-		return StatusCodeColors["5xx"]
-	case statusCode >= 100 && statusCode < 200:
-		return StatusCodeColors["1xx"]
-	default:
-		return DefaultBarColor
-	}
+	return themes[DefaultThemeName].statusColor(statusCode)
 }
 
-// GetTrafficColor returns a color from the traffic color palette
+// GetTrafficColor returns a color from the default theme's series palette
 func GetTrafficColor(index int) string {
-	if index < 0 || index >= len(TrafficColors) {
-		return DefaultBarColor
-	}
-	return TrafficColors[index%len(TrafficColors)]
+	return themes[DefaultThemeName].seriesColor(index)
 }
 
 // Colorize applies a color to text with automatic reset
@@ -143,32 +104,43 @@ func ColorizeBackground(text, bgColor string) string {
 	return bgColor + text + ColorReset
 }
 
-// StripColors removes all ANSI color codes from a string
+// StripColors removes all ANSI color and style codes from a string
 func StripColors(text string) string {
-	// Simple regex would be better, but avoiding regex dependency
-	result := text
-	colorCodes := []string{
-		ColorReset, ColorBold, ColorDim,
-		ColorBlack, ColorRed, ColorGreen, ColorYellow, ColorBlue, ColorMagenta, ColorCyan, ColorWhite,
-		ColorBrightBlack, ColorBrightRed, ColorBrightGreen, ColorBrightYellow,
-		ColorBrightBlue, ColorBrightMagenta, ColorBrightCyan, ColorBrightWhite,
-		ColorBgBlack, ColorBgRed, ColorBgGreen, ColorBgYellow, ColorBgBlue, ColorBgMagenta, ColorBgCyan, ColorBgWhite,
-	}
-	
-	for _, code := range colorCodes {
-		result = strings.ReplaceAll(result, code, "")
+	var result strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\033' && i+1 < len(text) && text[i+1] == '[' {
+			end := strings.IndexByte(text[i:], 'm')
+			if end > 0 {
+				i += end
+				continue
+			}
+		}
+		result.WriteByte(text[i])
 	}
-	
-	return result
+	return result.String()
 }
 
-// GetTerminalWidth attempts to determine the terminal width
+// DefaultTerminalWidth is used when the terminal width cannot be detected,
+// e.g. when output is piped to a file
+const DefaultTerminalWidth = 80
+
+// minTerminalWidth keeps charts readable in very narrow terminals
+const minTerminalWidth = 40
+
+// GetTerminalWidth returns the width of the terminal on stdout, falling back
+// to the COLUMNS environment variable and then DefaultTerminalWidth
 func GetTerminalWidth() int {
-	// Try to get from environment first
-	if cols := os.Getenv("COLUMNS"); cols != "" {
-		// Parse COLUMNS if available
+	width, ok := terminalWidth(os.Stdout)
+	if !ok {
+		if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+			width, ok = cols, true
+		}
 	}
-	
-	// Default fallback width
-	return 80
-}
\ No newline at end of file
+	if !ok {
+		return DefaultTerminalWidth
+	}
+	if width < minTerminalWidth {
+		return minTerminalWidth
+	}
+	return width
+}
@@ -2,8 +2,11 @@ package charts
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"smart-log-analyser/pkg/analyser"
 )
@@ -12,6 +15,12 @@ import (
 type ChartGenerator struct {
 	width      int
 	showColors bool
+	braille    bool
+	theme      Theme
+	scale      HistogramScale // Bar lengths of histograms
+
+	collectData bool
+	data        []ChartData // Series of the charts generated, see SetCollectData
 }
 
 // NewChartGenerator creates a new chart generator
@@ -19,6 +28,7 @@ func NewChartGenerator() *ChartGenerator {
 	return &ChartGenerator{
 		width:      80, // Default width
 		showColors: SupportsColor(),
+		theme:      DefaultTheme(),
 	}
 }
 
@@ -32,6 +42,32 @@ func (g *ChartGenerator) SetColors(enabled bool) {
 	g.showColors = enabled
 }
 
+// SetTheme sets the color palette of the charts
+func (g *ChartGenerator) SetTheme(theme Theme) {
+	g.theme = theme
+}
+
+// SetHistogramScale sets whether histogram bars are drawn on a linear or log
+// scale
+func (g *ChartGenerator) SetHistogramScale(scale HistogramScale) {
+	g.scale = scale
+}
+
+// SetBraille enables or disables high-resolution Braille line charts
+func (g *ChartGenerator) SetBraille(enabled bool) {
+	g.braille = enabled
+}
+
+// labelLimit scales a label length chosen for 80 columns to the chart width,
+// so wide terminals show more of long URLs and narrow ones keep room for bars
+func (g *ChartGenerator) labelLimit(at80 int) int {
+	limit := at80 * g.width / 80
+	if limit < at80/2 {
+		limit = at80 / 2
+	}
+	return limit
+}
+
 // GenerateStatusCodeChart creates a bar chart showing HTTP status code distribution
 func (g *ChartGenerator) GenerateStatusCodeChart(results *analyser.Results) string {
 	if len(results.StatusCodes) == 0 {
@@ -57,14 +93,21 @@ func (g *ChartGenerator) GenerateStatusCodeChart(results *analyser.Results) stri
 		return statusList[i].count > statusList[j].count
 	})
 
+	var rows [][]interface{}
+	for _, status := range statusList {
+		rows = append(rows, []interface{}{status.code, status.count, percentOf(status.count, results.TotalRequests)})
+	}
+	g.record("status_codes", chart.Config.Title, []string{"status_class", "requests", "percent"}, rows)
+
 	// Add bars to chart
 	for _, status := range statusList {
 		label := status.code
 		color := ""
 		if g.showColors {
-			// Convert string to int for color selection
-			codeInt, _ := strconv.Atoi(status.code)
-			color = GetStatusCodeColor(codeInt)
+			// Keys are status classes such as "4xx Client Error"
+			class, _, _ := strings.Cut(status.code, " ")
+			codeInt, _ := strconv.Atoi(strings.Replace(class, "xx", "00", 1))
+			color = g.theme.statusColor(codeInt)
 		}
 		chart.AddBar(label, int64(status.count), color)
 	}
@@ -87,16 +130,22 @@ func (g *ChartGenerator) GenerateTopIPsChart(results *analyser.Results, limit in
 		count = len(results.TopIPs)
 	}
 
+	var rows [][]interface{}
+	for _, ipData := range results.TopIPs[:count] {
+		rows = append(rows, []interface{}{ipData.IP, ipData.Count, percentOf(ipData.Count, results.TotalRequests)})
+	}
+	g.record("top_ips", chart.Config.Title, []string{"ip", "requests", "percent"}, rows)
+
 	for i, ipData := range results.TopIPs[:count] {
 		label := ipData.IP
 		// Truncate long IPs for display
-		if len(label) > 15 {
-			label = TruncateString(label, 15)
+		if limit := g.labelLimit(15); len(label) > limit {
+			label = TruncateString(label, limit)
 		}
 		
 		color := ""
 		if g.showColors {
-			color = GetTrafficColor(i)
+			color = g.theme.seriesColor(i)
 		}
 		chart.AddBar(label, int64(ipData.Count), color)
 	}
@@ -119,16 +168,22 @@ func (g *ChartGenerator) GenerateTopURLsChart(results *analyser.Results, limit i
 		count = len(results.TopURLs)
 	}
 
+	var rows [][]interface{}
+	for _, urlData := range results.TopURLs[:count] {
+		rows = append(rows, []interface{}{urlData.URL, urlData.Count, percentOf(urlData.Count, results.TotalRequests)})
+	}
+	g.record("top_urls", chart.Config.Title, []string{"url", "requests", "percent"}, rows)
+
 	for i, urlData := range results.TopURLs[:count] {
 		label := urlData.URL
 		// Truncate long URLs for display
-		if len(label) > 30 {
-			label = TruncateString(label, 30)
+		if limit := g.labelLimit(30); len(label) > limit {
+			label = TruncateString(label, limit)
 		}
 		
 		color := ""
 		if g.showColors {
-			color = GetTrafficColor(i)
+			color = g.theme.seriesColor(i)
 		}
 		chart.AddBar(label, int64(urlData.Count), color)
 	}
@@ -143,9 +198,13 @@ func (g *ChartGenerator) GenerateBotTrafficChart(results *analyser.Results) stri
 
 	// Add human traffic
 	humanCount := int64(results.TotalRequests - results.BotRequests)
+	g.record("bot_traffic", chart.Config.Title, []string{"class", "requests", "percent"}, [][]interface{}{
+		{"human", humanCount, percentOf(int(humanCount), results.TotalRequests)},
+		{"bot", results.BotRequests, percentOf(results.BotRequests, results.TotalRequests)},
+	})
 	if g.showColors {
-		chart.AddBar("Human Traffic", humanCount, ColorGreen)
-		chart.AddBar("Bot Traffic", int64(results.BotRequests), ColorYellow)
+		chart.AddBar("Human Traffic", humanCount, g.theme.Good)
+		chart.AddBar("Bot Traffic", int64(results.BotRequests), g.theme.Warning)
 	} else {
 		chart.AddBar("Human Traffic", humanCount, "")
 		chart.AddBar("Bot Traffic", int64(results.BotRequests), "")
@@ -154,75 +213,429 @@ func (g *ChartGenerator) GenerateBotTrafficChart(results *analyser.Results) stri
 	return chart.Render()
 }
 
-// GenerateGeographicChart creates a text-based geographic distribution chart
-func (g *ChartGenerator) GenerateGeographicChart(results *analyser.Results) string {
-	geo := results.GeographicAnalysis
-	if len(geo.TopCountries) == 0 && geo.LocalTraffic == 0 {
-		return "No geographic data available\n"
+// GenerateResponseSizeChart creates a histogram of response sizes
+func (g *ChartGenerator) GenerateResponseSizeChart(results *analyser.Results) string {
+	if results.TotalRequests == 0 || len(results.SizeHistogram) == 0 {
+		return "No response size data available\n"
 	}
 
-	chart := NewBarChart("Geographic Distribution", g.width)
-	chart.Config.ShowColors = g.showColors
-	
-	if g.showColors {
-		chart.AddBar("Local Networks", int64(geo.LocalTraffic), ColorGreen)
-		chart.AddBar("Cloud/CDN", int64(geo.CloudTraffic), ColorBlue)
-		chart.AddBar("Unknown IPs", int64(geo.UnknownIPs), ColorYellow)
-	} else {
-		chart.AddBar("Local Networks", int64(geo.LocalTraffic), "")
-		chart.AddBar("Cloud/CDN", int64(geo.CloudTraffic), "")
-		chart.AddBar("Unknown IPs", int64(geo.UnknownIPs), "")
+	histogram := g.newHistogram("Response Size Distribution")
+	var rows [][]interface{}
+	for _, bucket := range results.SizeHistogram {
+		histogram.AddBin(bucket.Label, int64(bucket.Count), "")
+		rows = append(rows, []interface{}{bucket.Label, bucket.Min, bucket.Max, bucket.Count, bucket.Bytes})
 	}
+	g.record("response_sizes", histogram.Config.Title, []string{"bucket", "min_bytes", "max_bytes", "responses", "bytes"}, rows)
+	return g.renderHistogram(histogram)
+}
 
-	return chart.Render()
+// histogramBins is the number of bins of histograms of measured values
+const histogramBins = 10
+
+// GenerateLatencyHistogram creates a histogram of request times, in bins
+// growing by an equal ratio from the fastest to the slowest request
+func (g *ChartGenerator) GenerateLatencyHistogram(results *analyser.Results) string {
+	times := results.LatencyHeatmap.RequestTimes()
+	if len(times) == 0 {
+		return "No request timing data available (add $request_time to the nginx log format)\n"
+	}
+
+	values := make([]float64, len(times))
+	for i, d := range times {
+		values[i] = float64(d)
+	}
+	histogram := g.newHistogram("Request Time Distribution")
+	histogram.Bins = BinValues(values, histogramBins, LogScale, func(value float64) string {
+		return formatLatency(time.Duration(value))
+	})
+	g.recordHistogram("request_times", histogram, "ms", float64(time.Millisecond))
+	return g.renderHistogram(histogram)
 }
 
-// GenerateResponseSizeChart creates a histogram of response sizes
-func (g *ChartGenerator) GenerateResponseSizeChart(results *analyser.Results) string {
-	if results.TotalRequests == 0 {
-		return "No response size data available\n"
+// GenerateSessionLengthChart creates a histogram of visitor session lengths.
+// Single-request sessions have a length of zero and get a bin of their own.
+func (g *ChartGenerator) GenerateSessionLengthChart(results *analyser.Results) string {
+	if len(results.SessionLengths) == 0 {
+		return "No session data available\n"
 	}
 
-	chart := NewBarChart("Response Size Distribution", g.width)
+	values := make([]float64, len(results.SessionLengths))
+	for i, d := range results.SessionLengths {
+		values[i] = d.Seconds()
+	}
+	histogram := g.newHistogram(fmt.Sprintf("Session Length Distribution (%s sessions)", FormatNumber(int64(len(values)))))
+	histogram.Bins = BinValues(values, histogramBins, LogScale, func(value float64) string {
+		return formatSessionLength(time.Duration(value * float64(time.Second)))
+	})
+	g.recordHistogram("session_lengths", histogram, "seconds", 1)
+	return g.renderHistogram(histogram)
+}
+
+// newHistogram creates a histogram fitted to the generator's settings
+func (g *ChartGenerator) newHistogram(title string) *Histogram {
+	histogram := NewHistogram(title, g.width)
+	histogram.Config.ShowColors = g.showColors
+	histogram.Scale = g.scale
+	return histogram
+}
+
+// recordHistogram keeps the bins of a histogram of values, with the bounds
+// divided by scale to give them the unit
+func (g *ChartGenerator) recordHistogram(name string, histogram *Histogram, unit string, scale float64) {
+	var rows [][]interface{}
+	for _, bin := range histogram.Bins {
+		rows = append(rows, []interface{}{bin.Label, bin.Min / scale, bin.Max / scale, bin.Count})
+	}
+	g.record(name, histogram.Config.Title, []string{"bin", "min_" + unit, "max_" + unit, "count"}, rows)
+}
+
+// renderHistogram colors the bins of a histogram from the series palette
+func (g *ChartGenerator) renderHistogram(histogram *Histogram) string {
+	for i := range histogram.Bins {
+		histogram.Bins[i].Color = g.theme.seriesColor(i)
+	}
+	return histogram.Render()
+}
+
+// maxSeriesBars limits how many rows of a series chart are drawn
+const maxSeriesBars = 25
+
+// GenerateSeriesChart creates a bar chart from labelled values, such as the
+// rows of an aggregate query. Values are rounded to whole numbers; percentages
+// are shown only when the values add up to a meaningful total.
+func (g *ChartGenerator) GenerateSeriesChart(title string, labels []string, values []float64, showPercent bool) string {
+	if len(labels) == 0 {
+		return "No data available\n"
+	}
+
+	chart := NewBarChart(title, g.width)
 	chart.Config.ShowColors = g.showColors
+	chart.Config.ShowPercent = showPercent
 
-	// Create buckets for response sizes (using response size as proxy)
-	// Define size buckets (in bytes)
-	buckets := []struct {
-		label string
-		min   int64
-		max   int64
-		count int64
-	}{
-		{"< 1KB", 0, 1024, 0},
-		{"1-10KB", 1024, 10240, 0},
-		{"10-100KB", 10240, 102400, 0},
-		{"100KB-1MB", 102400, 1048576, 0},
-		{"> 1MB", 1048576, 999999999, 0},
-	}
-
-	// This is a simplified version - in a real implementation, 
-	// you'd collect actual response size data during parsing
-	// For now, we'll use percentiles as a proxy
-	totalRequests := int64(results.TotalRequests)
-	
-	// Distribute requests across buckets based on percentiles (approximation)
-	buckets[0].count = totalRequests * 20 / 100  // 20% small files
-	buckets[1].count = totalRequests * 50 / 100  // 50% medium files
-	buckets[2].count = totalRequests * 25 / 100  // 25% large files
-	buckets[3].count = totalRequests * 4 / 100   // 4% very large files
-	buckets[4].count = totalRequests * 1 / 100   // 1% huge files
+	var rows [][]interface{}
+	for i, label := range labels {
+		rows = append(rows, []interface{}{label, values[i]})
+	}
+	g.record("query", title, []string{"label", title}, rows)
 
-	// Add bars to chart
-	for i, bucket := range buckets {
+	count := len(labels)
+	if count > maxSeriesBars {
+		count = maxSeriesBars
+	}
+	for i := 0; i < count; i++ {
 		color := ""
 		if g.showColors {
-			color = GetTrafficColor(i)
+			color = g.theme.seriesColor(i)
 		}
-		chart.AddBar(bucket.label, bucket.count, color)
+		chart.AddBar(TruncateString(labels[i], g.labelLimit(30)), int64(math.Round(values[i])), color)
 	}
 
-	return chart.Render()
+	output := chart.Render()
+	if len(labels) > count {
+		output += fmt.Sprintf("... %d more rows not shown\n", len(labels)-count)
+	}
+	return output
+}
+
+// heatmapShades are the cell characters from fastest to slowest
+var heatmapShades = []string{"·", "░", "▒", "▓", "█"}
+
+// GenerateLatencyHeatmap creates an hour × endpoint grid of P95 request
+// times. Cells are shaded relative to the slowest cell; regressed cells are
+// marked with "!".
+func (g *ChartGenerator) GenerateLatencyHeatmap(results *analyser.Results) string {
+	heatmap := results.LatencyHeatmap
+	if heatmap == nil || len(heatmap.Endpoints) == 0 {
+		return "No request timing data available (add $request_time to the nginx log format)\n"
+	}
+
+	var slowest time.Duration
+	for _, row := range heatmap.Cells {
+		for _, cell := range row {
+			if cell.P95 > slowest {
+				slowest = cell.P95
+			}
+		}
+	}
+
+	var rows [][]interface{}
+	for i, endpoint := range heatmap.Endpoints {
+		for hour, cell := range heatmap.Cells[i] {
+			rows = append(rows, []interface{}{endpoint, hour, cell.Requests, float64(cell.P95.Microseconds()) / 1000, cell.Regressed})
+		}
+	}
+	g.record("latency_heatmap", "Latency Heatmap (P95 request time by hour)", []string{"endpoint", "hour", "requests", "p95_ms", "regressed"}, rows)
+
+	labelWidth := g.width - 24*2 - 10
+	if labelWidth < 12 {
+		labelWidth = 12
+	}
+
+	var output strings.Builder
+	output.WriteString("Latency Heatmap (P95 request time by hour)\n")
+	output.WriteString(strings.Repeat("═", labelWidth+24*2+10) + "\n")
+
+	output.WriteString(fmt.Sprintf("%-*s ", labelWidth, "Endpoint"))
+	for hour := 0; hour < 24; hour += 3 {
+		output.WriteString(fmt.Sprintf("%-6s", fmt.Sprintf("%02d", hour)))
+	}
+	output.WriteString(" P95\n")
+
+	for i, endpoint := range heatmap.Endpoints {
+		output.WriteString(fmt.Sprintf("%-*s ", labelWidth, TruncateString(endpoint, labelWidth)))
+		for _, cell := range heatmap.Cells[i] {
+			shade := " "
+			if cell.Requests > 0 {
+				level := 0
+				if slowest > 0 {
+					level = int(float64(cell.P95) / float64(slowest) * float64(len(heatmapShades)-1))
+				}
+				shade = heatmapShades[level]
+			}
+			marker := " "
+			if cell.Regressed {
+				marker = "!"
+				if g.showColors {
+					shade = Colorize(shade, g.theme.Alert)
+					marker = Colorize(marker, g.theme.Alert)
+				}
+			}
+			output.WriteString(shade + marker)
+		}
+		output.WriteString(fmt.Sprintf(" %s\n", formatLatency(heatmap.EndpointP95[i])))
+	}
+
+	output.WriteString(fmt.Sprintf("\nScale: %s fastest … %s %s slowest, ! = P95 over 1.5× the endpoint's usual P95\n",
+		heatmapShades[0], heatmapShades[len(heatmapShades)-1], formatLatency(slowest)))
+	if len(heatmap.RegressedHours) > 0 {
+		var hours []string
+		for _, hour := range heatmap.RegressedHours {
+			hours = append(hours, fmt.Sprintf("%02d:00 (%s)", hour, formatLatency(heatmap.HourP95[hour])))
+		}
+		output.WriteString(fmt.Sprintf("Regressed hours (overall P95 %s): %s\n", formatLatency(heatmap.OverallP95), strings.Join(hours, ", ")))
+	}
+
+	return output.String()
+}
+
+// GenerateTrafficHeatmap creates a day-of-week × hour grid of request counts,
+// so multi-day traffic patterns are visible at a glance. Cells are shaded
+// relative to the busiest cell.
+func (g *ChartGenerator) GenerateTrafficHeatmap(results *analyser.Results) string {
+	busiest, busiestDay, busiestHour := 0, time.Sunday, 0
+	for day, hours := range results.WeekdayHourTraffic {
+		for hour, count := range hours {
+			if count > busiest {
+				busiest, busiestDay, busiestHour = count, time.Weekday(day), hour
+			}
+		}
+	}
+	if busiest == 0 {
+		return "No traffic data available for the day × hour heatmap\n"
+	}
+
+	var rows [][]interface{}
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		for hour, count := range results.WeekdayHourTraffic[day] {
+			rows = append(rows, []interface{}{day.String(), hour, count})
+		}
+	}
+	g.record("traffic_heatmap", "Traffic Heatmap (requests by day of week and hour)", []string{"weekday", "hour", "requests"}, rows)
+
+	var output strings.Builder
+	output.WriteString("Traffic Heatmap (requests by day of week and hour)\n")
+	output.WriteString(strings.Repeat("═", 4+24*2+8) + "\n")
+
+	output.WriteString("    ")
+	for hour := 0; hour < 24; hour += 3 {
+		output.WriteString(fmt.Sprintf("%-6s", fmt.Sprintf("%02d", hour)))
+	}
+	output.WriteString(fmt.Sprintf(" %7s\n", "Total"))
+
+	// Weeks start on Monday
+	for i := 1; i <= 7; i++ {
+		day := time.Weekday(i % 7)
+		output.WriteString(day.String()[:3] + " ")
+		total := 0
+		for _, count := range results.WeekdayHourTraffic[day] {
+			total += count
+			if count == 0 {
+				output.WriteString("  ")
+				continue
+			}
+			level := int(float64(count) / float64(busiest) * float64(len(heatmapShades)-1))
+			shade := strings.Repeat(heatmapShades[level], 2)
+			if g.showColors {
+				shade = Colorize(shade, g.theme.heatmapColor(level))
+			}
+			output.WriteString(shade)
+		}
+		output.WriteString(fmt.Sprintf(" %7s\n", FormatNumber(int64(total))))
+	}
+
+	output.WriteString(fmt.Sprintf("\nScale: %s quiet … %s busiest (%s requests in one hour)\n",
+		heatmapShades[0], heatmapShades[len(heatmapShades)-1], FormatNumber(int64(busiest))))
+	output.WriteString(fmt.Sprintf("Busiest slot: %s %02d:00\n", busiestDay, busiestHour))
+
+	return output.String()
+}
+
+// timeSeriesBuckets are the bucket sizes tried when fitting a requests over
+// time chart to the chart width, and preferred when widening a bucket
+var timeSeriesBuckets = []time.Duration{
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// GenerateTimeSeriesChart creates a requests over time area chart with the
+// error rate overlaid on a second axis. A bucket of zero picks the smallest
+// of 1m, 5m, 15m, … 24h that fits the chart width; buckets too small to fit
+// are widened to a multiple of the requested size.
+func (g *ChartGenerator) GenerateTimeSeriesChart(results *analyser.Results, bucket time.Duration) string {
+	span := results.Timeline.Span()
+	if span == 0 {
+		return "No timeline data available for the requests over time chart\n"
+	}
+
+	plotWidth := g.width - 18
+	if plotWidth < 20 {
+		plotWidth = 20
+	}
+
+	points := plotWidth
+	if g.braille {
+		points *= 2 // Two dot columns per character
+	}
+
+	requested := bucket
+	bucket, buckets := fitTimelineBuckets(results.Timeline, points, bucket)
+
+	title := fmt.Sprintf("Requests over Time (%s buckets)", formatBucket(bucket))
+	if requested > 0 && bucket != requested {
+		title = fmt.Sprintf("Requests over Time (%s buckets, widened from %s to fit the chart width)", formatBucket(bucket), formatBucket(requested))
+	}
+
+	labelFormat := "15:04"
+	if bucket >= 24*time.Hour {
+		labelFormat = "01-02"
+	} else if buckets[0].Start.YearDay() != buckets[len(buckets)-1].Start.YearDay() || span > 24*time.Hour {
+		labelFormat = "01-02 15:04"
+	}
+
+	chart := NewLineChart(title, g.width)
+	chart.Config.ShowColors = g.showColors
+	chart.Braille = g.braille
+	chart.Series = LineSeries{Name: "Requests", Color: g.theme.Primary}
+	chart.Overlay = &LineSeries{Name: "Error rate", Color: g.theme.Alert, Unit: "%"}
+
+	var peak, worst analyser.TimelineBucket
+	total := 0
+	for _, b := range buckets {
+		chart.Labels = append(chart.Labels, b.Start.Format(labelFormat))
+		chart.Series.Values = append(chart.Series.Values, float64(b.Requests))
+		chart.Overlay.Values = append(chart.Overlay.Values, b.ErrorRate())
+		total += b.Requests
+		if b.Requests > peak.Requests {
+			peak = b
+		}
+		if b.ErrorRate() > worst.ErrorRate() {
+			worst = b
+		}
+	}
+
+	if worst.Errors == 0 {
+		chart.Overlay = nil
+	}
+
+	var rows [][]interface{}
+	for _, b := range buckets {
+		rows = append(rows, []interface{}{b.Start, b.Requests, b.Errors, math.Round(b.ErrorRate()*100) / 100, b.Bytes})
+	}
+	g.record("requests_over_time", title, []string{"bucket_start", "requests", "errors", "error_rate", "bytes"}, rows)
+
+	var output strings.Builder
+	output.WriteString(chart.Render())
+	output.WriteString(fmt.Sprintf("\nPeak: %s requests at %s, average %.1f per %s bucket\n",
+		FormatNumber(int64(peak.Requests)), peak.Start.Format("2006-01-02 15:04"), float64(total)/float64(len(buckets)), formatBucket(bucket)))
+	if worst.Errors > 0 {
+		output.WriteString(fmt.Sprintf("Highest error rate: %.1f%% (%d of %d requests) at %s\n",
+			worst.ErrorRate(), worst.Errors, worst.Requests, worst.Start.Format("2006-01-02 15:04")))
+	}
+
+	return output.String()
+}
+
+// fitTimelineBuckets buckets a timeline into at most width buckets. A bucket
+// of zero picks the smallest standard size that fits; sizes too small to fit
+// are widened, preferring a standard size that is a multiple of the request.
+func fitTimelineBuckets(timeline *analyser.RequestTimeline, width int, bucket time.Duration) (time.Duration, []analyser.TimelineBucket) {
+	if bucket <= 0 {
+		span := timeline.Span()
+		bucket = timeSeriesBuckets[len(timeSeriesBuckets)-1]
+		for _, size := range timeSeriesBuckets {
+			if int(span/size) < width {
+				bucket = size
+				break
+			}
+		}
+	}
+
+	buckets := timeline.Buckets(bucket)
+	if len(buckets) > width {
+		widened := bucket * time.Duration((len(buckets)+width-1)/width)
+		for _, size := range timeSeriesBuckets {
+			if size >= widened && size%bucket == 0 {
+				widened = size
+				break
+			}
+		}
+		bucket = widened
+		buckets = timeline.Buckets(bucket)
+	}
+	return bucket, buckets
+}
+
+// formatBucket formats a bucket size as days, hours or minutes, e.g. 5m
+func formatBucket(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// formatLatency formats a request time in milliseconds or seconds
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
+
+// formatSessionLength formats a session length in seconds, minutes or hours
+func formatSessionLength(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Round(time.Second)/time.Second))
+	case d < 10*time.Minute:
+		return fmt.Sprintf("%.1fm", d.Minutes())
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Round(time.Minute)/time.Minute))
+	default:
+		return fmt.Sprintf("%.1fh", d.Hours())
+	}
+}
+
+// percentOf returns count as a percentage of total, to two decimals
+func percentOf(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(count)*10000/float64(total)) / 100
 }
 
 // GenerateFullReport generates all available charts
@@ -234,8 +647,11 @@ func (g *ChartGenerator) GenerateFullReport(results *analyser.Results) string {
 	report += g.GenerateTopIPsChart(results, 5) + "\n"
 	report += g.GenerateTopURLsChart(results, 5) + "\n"
 	report += g.GenerateBotTrafficChart(results) + "\n"
-	report += g.GenerateGeographicChart(results) + "\n"
+	report += g.GenerateGeographicChart(results, 5) + "\n"
 	report += g.GenerateResponseSizeChart(results) + "\n"
+	report += g.GenerateSessionLengthChart(results) + "\n"
+	report += g.GenerateTrafficHeatmap(results) + "\n"
+	report += g.GenerateTimeSeriesChart(results, 0) + "\n"
 
 	return report
 }
@@ -245,6 +661,13 @@ func (g *ChartGenerator) GenerateSummary(results *analyser.Results) string {
 	report := fmt.Sprintf("📊 Quick Charts Summary\n")
 	report += fmt.Sprintf("══════════════════════\n\n")
 
+	if sparklines, ok := NewTimelineSparklines(results.Timeline, g.width-24); ok {
+		report += fmt.Sprintf("Traffic     %s\n", sparklines.Traffic)
+		report += fmt.Sprintf("Error rate  %s\n", sparklines.ErrorRate)
+		report += fmt.Sprintf("Bandwidth   %s\n", sparklines.Bandwidth)
+		report += fmt.Sprintf("            %s\n\n", sparklines.Describe())
+	}
+
 	report += g.GenerateStatusCodeChart(results) + "\n"
 	report += g.GenerateBotTrafficChart(results) + "\n"
 
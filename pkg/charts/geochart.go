@@ -0,0 +1,229 @@
+package charts
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// Markers shown instead of a flag for traffic without a country
+var locationMarkers = map[string]string{
+	"Local":   "🏠",
+	"Cloud":   "🌐",
+	"Unknown": "❓",
+}
+
+// geoRow is one region or country of the geographic chart
+type geoRow struct {
+	name   string
+	code   string // ISO country code, when known
+	marker string // Flag or location marker, 2 columns wide
+	count  int
+}
+
+// CountryFlag returns the emoji flag of an ISO 3166-1 alpha-2 country code,
+// or an empty string when the code is not two letters
+func CountryFlag(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "UK" {
+		code = "GB"
+	}
+	if len(code) != 2 {
+		return ""
+	}
+
+	var flag []rune
+	for _, letter := range code {
+		if letter < 'A' || letter > 'Z' {
+			return ""
+		}
+		flag = append(flag, 0x1F1E6+letter-'A') // Regional indicator symbols
+	}
+	return string(flag)
+}
+
+// GenerateGeographicChart creates a chart of requests by region next to the
+// top countries with their flags. The columns are stacked on narrow
+// terminals.
+func (g *ChartGenerator) GenerateGeographicChart(results *analyser.Results, limit int) string {
+	countries, regions := geoRows(results)
+	if len(countries) == 0 {
+		return "No geographic data available\n"
+	}
+
+	total := 0
+	for _, country := range countries {
+		total += country.count
+	}
+	var countryRows, regionRows [][]interface{}
+	for _, country := range countries {
+		countryRows = append(countryRows, []interface{}{country.name, country.code, country.count, percentOf(country.count, total)})
+	}
+	for _, region := range regions {
+		regionRows = append(regionRows, []interface{}{region.name, region.count, percentOf(region.count, total)})
+	}
+	g.record("countries", "Geographic Distribution by Country", []string{"country", "code", "requests", "percent"}, countryRows)
+	g.record("regions", "Geographic Distribution by Region", []string{"region", "requests", "percent"}, regionRows)
+	shown := countries
+	if limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+
+	countryTitle := fmt.Sprintf("Top %d Countries", len(shown))
+	if len(shown) == len(countries) {
+		countryTitle = "Countries"
+	}
+	twoColumns := g.width >= 100
+	columnWidth := g.width
+	if twoColumns {
+		columnWidth = (g.width - 3) / 2
+	}
+	regionLines := g.geoColumn("Regions", regions, total, columnWidth, false)
+	countryLines := g.geoColumn(countryTitle, shown, total, columnWidth, true)
+	if len(shown) < len(countries) {
+		others := 0
+		for _, country := range countries[len(shown):] {
+			others += country.count
+		}
+		countryLines = append(countryLines, fmt.Sprintf("+ %d more countries (%.1f%%)",
+			len(countries)-len(shown), float64(others)*100/float64(total)))
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("🌍 Geographic Distribution (%d countries)\n", len(countries)))
+	if !twoColumns {
+		output.WriteString(strings.Join(regionLines, "\n") + "\n\n")
+		output.WriteString(strings.Join(countryLines, "\n") + "\n")
+		return output.String()
+	}
+
+	for i := 0; i < len(regionLines) || i < len(countryLines); i++ {
+		left, right := "", ""
+		if i < len(regionLines) {
+			left = regionLines[i]
+		}
+		if i < len(countryLines) {
+			right = countryLines[i]
+		}
+		padding := columnWidth - utf8.RuneCountInString(StripColors(left))
+		if padding < 0 {
+			padding = 0
+		}
+		output.WriteString(strings.TrimRight(left+strings.Repeat(" ", padding)+" │ "+right, " ") + "\n")
+	}
+	return output.String()
+}
+
+// geoColumn renders the rows of one column of the geographic chart, each
+// with its share of the total requests and a bar scaled to the largest row
+func (g *ChartGenerator) geoColumn(title string, rows []geoRow, total, width int, markers bool) []string {
+	nameWidth := 0
+	for _, row := range rows {
+		if length := utf8.RuneCountInString(row.name); length > nameWidth {
+			nameWidth = length
+		}
+	}
+	if limit := g.labelLimit(16); nameWidth > limit {
+		nameWidth = limit
+	}
+
+	markerWidth := 0
+	if markers {
+		markerWidth = 3 // Emoji are 2 columns wide, plus a space
+	}
+	barWidth := width - markerWidth - nameWidth - 8 // " 100.0%" and a space
+	if barWidth < 5 {
+		barWidth = 5
+	}
+
+	largest := 0
+	for _, row := range rows {
+		if row.count > largest {
+			largest = row.count
+		}
+	}
+
+	lines := []string{title, strings.Repeat("─", utf8.RuneCountInString(title))}
+	for i, row := range rows {
+		share := float64(row.count) / float64(total)
+		length := int(math.Round(float64(row.count) / float64(largest) * float64(barWidth)))
+		if length == 0 && row.count > 0 {
+			length = 1
+		}
+		bar := strings.Repeat("█", length)
+		if g.showColors {
+			bar = Colorize(bar, g.geoColor(row.name, i))
+		}
+
+		line := ""
+		if markers {
+			marker := row.marker
+			if marker == "" {
+				marker = "  "
+			}
+			line = marker + " "
+		}
+		name := TruncateString(row.name, nameWidth)
+		line += name + strings.Repeat(" ", nameWidth-utf8.RuneCountInString(name)+1)
+		line += bar + strings.Repeat(" ", barWidth-length)
+		line += fmt.Sprintf(" %5.1f%%", share*100)
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// geoColor keeps private, cloud and unknown traffic in the theme's good,
+// neutral and warning colors and colors the others from the series palette
+func (g *ChartGenerator) geoColor(name string, index int) string {
+	switch name {
+	case "Local", "Private Network":
+		return g.theme.Good
+	case "Cloud", "CDN/Cloud":
+		return g.theme.Neutral
+	case "Unknown":
+		return g.theme.Warning
+	default:
+		return g.theme.seriesColor(index)
+	}
+}
+
+// geoRows returns the countries and regions by requests, from the
+// per-country statistics or, for results without them, the geographic
+// analysis
+func geoRows(results *analyser.Results) ([]geoRow, []geoRow) {
+	var countries []geoRow
+	regionCounts := make(map[string]int)
+	if len(results.CountryStats) > 0 {
+		for _, stat := range results.CountryStats {
+			marker := CountryFlag(stat.Code)
+			if marker == "" {
+				marker = locationMarkers[stat.Country]
+			}
+			countries = append(countries, geoRow{name: stat.Country, code: stat.Code, marker: marker, count: stat.Requests})
+			regionCounts[stat.Region] += stat.Requests
+		}
+	} else {
+		for _, stat := range results.GeographicAnalysis.TopCountries {
+			countries = append(countries, geoRow{name: stat.Country, marker: locationMarkers[stat.Country], count: stat.Count})
+			regionCounts[stat.Region] += stat.Count
+		}
+	}
+
+	var regions []geoRow
+	for region, count := range regionCounts {
+		regions = append(regions, geoRow{name: region, count: count})
+	}
+	for _, rows := range [][]geoRow{countries, regions} {
+		sort.SliceStable(rows, func(i, j int) bool {
+			if rows[i].count != rows[j].count {
+				return rows[i].count > rows[j].count
+			}
+			return rows[i].name < rows[j].name
+		})
+	}
+	return countries, regions
+}
@@ -0,0 +1,207 @@
+package charts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// barEighths are the partial cell characters of a horizontal bar, in eighths
+var barEighths = []string{"▏", "▎", "▍", "▌", "▋", "▊", "▉", "█"}
+
+// HistogramScale is how bin bounds are spaced, or how bar lengths grow with
+// the count of a bin
+type HistogramScale int
+
+const (
+	LinearScale HistogramScale = iota
+	LogScale                   // Suits counts or values spanning several orders of magnitude
+)
+
+// ParseHistogramScale parses "linear" or "log"
+func ParseHistogramScale(name string) (HistogramScale, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "linear":
+		return LinearScale, nil
+	case "log":
+		return LogScale, nil
+	default:
+		return LinearScale, fmt.Errorf("unknown histogram scale %q (use linear or log)", name)
+	}
+}
+
+// HistogramBin is the count of values in one bucket of a histogram
+type HistogramBin struct {
+	Label string
+	Min   float64 // Bounds of binned values, zero for bins added by label
+	Max   float64
+	Count int64
+	Color string // Terminal color code
+}
+
+// Histogram draws bucketed counts as horizontal bars with their share of the
+// total. On a log scale bar lengths follow the logarithm of the counts, so
+// sparse tail buckets stay visible next to a dominant one.
+type Histogram struct {
+	Config ChartConfig
+	Bins   []HistogramBin
+	Scale  HistogramScale
+}
+
+// NewHistogram creates a new histogram
+func NewHistogram(title string, width int) *Histogram {
+	return &Histogram{
+		Config: ChartConfig{
+			Width:       width,
+			Title:       title,
+			ShowColors:  true,
+			ShowValues:  true,
+			ShowPercent: true,
+		},
+	}
+}
+
+// AddBin adds a bucket to the histogram
+func (h *Histogram) AddBin(label string, count int64, color string) {
+	h.Bins = append(h.Bins, HistogramBin{Label: label, Count: count, Color: color})
+}
+
+// Render generates the histogram as a string
+func (h *Histogram) Render() string {
+	if len(h.Bins) == 0 {
+		return "No data to display"
+	}
+
+	var total, maxCount int64
+	labelWidth := 0
+	for _, bin := range h.Bins {
+		total += bin.Count
+		if bin.Count > maxCount {
+			maxCount = bin.Count
+		}
+		if width := utf8.RuneCountInString(bin.Label); width > labelWidth {
+			labelWidth = width
+		}
+	}
+
+	countWidth := len(fmt.Sprintf("%d", maxCount))
+	barWidth := h.Config.Width - labelWidth - countWidth - 13 // " │", " 100.0% ()"
+	if barWidth < 10 {
+		barWidth = 10
+	}
+
+	var output strings.Builder
+	if h.Config.Title != "" {
+		output.WriteString(fmt.Sprintf("📊 %s\n", h.Config.Title))
+	}
+
+	for _, bin := range h.Bins {
+		eighths := int(math.Round(h.length(bin.Count, maxCount) * float64(barWidth*8)))
+		if eighths == 0 && bin.Count > 0 {
+			eighths = 1 // Keep non-empty bins visible
+		}
+		bar := strings.Repeat("█", eighths/8)
+		if eighths%8 > 0 {
+			bar += barEighths[eighths%8-1]
+		}
+		padding := strings.Repeat(" ", barWidth-utf8.RuneCountInString(bar))
+		if h.Config.ShowColors && bin.Color != "" && bar != "" {
+			bar = Colorize(bar, bin.Color)
+		}
+
+		output.WriteString(fmt.Sprintf("%*s │%s%s", labelWidth, bin.Label, bar, padding))
+		if h.Config.ShowPercent && total > 0 {
+			output.WriteString(fmt.Sprintf(" %5.1f%%", float64(bin.Count)*100/float64(total)))
+		}
+		if h.Config.ShowValues {
+			output.WriteString(fmt.Sprintf(" (%*d)", countWidth, bin.Count))
+		}
+		output.WriteString("\n")
+	}
+
+	if h.Scale == LogScale {
+		output.WriteString(fmt.Sprintf("%*s  Bar lengths on a log scale\n", labelWidth, ""))
+	}
+	return output.String()
+}
+
+// length returns a bar's length as a fraction of the longest bar
+func (h *Histogram) length(count, maxCount int64) float64 {
+	if count <= 0 || maxCount <= 0 {
+		return 0
+	}
+	if h.Scale == LogScale {
+		return math.Log1p(float64(count)) / math.Log1p(float64(maxCount))
+	}
+	return float64(count) / float64(maxCount)
+}
+
+// BinValues buckets values into bins between the smallest and largest value,
+// labelling each bin with its formatted bounds. Linear bins are of equal
+// width; log bins grow by an equal ratio, with values of zero or less counted
+// in a bin of their own.
+func BinValues(values []float64, bins int, scale HistogramScale, format func(float64) string) []HistogramBin {
+	if len(values) == 0 || bins < 1 {
+		return nil
+	}
+
+	var zeros int64
+	var positive []float64
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		if scale == LogScale && value <= 0 {
+			zeros++
+			continue
+		}
+		positive = append(positive, value)
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+
+	var result []HistogramBin
+	if zeros > 0 {
+		result = append(result, HistogramBin{Label: format(0), Count: zeros})
+	}
+	if len(positive) == 0 {
+		return result
+	}
+	if low == high {
+		return append(result, HistogramBin{Label: format(low), Min: low, Max: high, Count: int64(len(positive))})
+	}
+
+	// Bin i covers [bound(i), bound(i+1)), the last one includes high
+	bound := func(i int) float64 {
+		if scale == LogScale {
+			return low * math.Pow(high/low, float64(i)/float64(bins))
+		}
+		return low + (high-low)*float64(i)/float64(bins)
+	}
+	index := func(value float64) int {
+		var position float64
+		if scale == LogScale {
+			position = math.Log(value/low) / math.Log(high/low)
+		} else {
+			position = (value - low) / (high - low)
+		}
+		i := int(position * float64(bins))
+		if i >= bins {
+			i = bins - 1
+		}
+		return i
+	}
+
+	counts := make([]int64, bins)
+	for _, value := range positive {
+		counts[index(value)]++
+	}
+	for i, count := range counts {
+		result = append(result, HistogramBin{
+			Label: format(bound(i)) + "–" + format(bound(i+1)),
+			Min:   bound(i),
+			Max:   bound(i + 1),
+			Count: count,
+		})
+	}
+	return result
+}
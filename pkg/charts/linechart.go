@@ -0,0 +1,324 @@
+package charts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// areaLevels are the partial cell characters of an area chart, in eighths
+var areaLevels = []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+// overlayMarker marks the points of a line chart's overlay series
+const overlayMarker = "•"
+
+// LineSeries is one series of values of a line chart
+type LineSeries struct {
+	Name   string
+	Values []float64
+	Color  string // Terminal color code
+	Unit   string // Suffix of the axis labels, e.g. "%"
+}
+
+// LineChart plots a series over time as a filled area against the left axis,
+// optionally overlaid with a second series drawn as markers against its own
+// right axis. Each value is one column, or half a column when drawn with
+// Braille dots, so callers bucket the data to fit the width.
+type LineChart struct {
+	Config  ChartConfig
+	Labels  []string // X axis label of each value
+	Series  LineSeries
+	Overlay *LineSeries // Nil for a single series
+	Braille bool        // Draw both series as lines of Braille dots, 2×4 per character
+}
+
+// NewLineChart creates a new line chart
+func NewLineChart(title string, width int) *LineChart {
+	return &LineChart{
+		Config: ChartConfig{
+			Width:      width,
+			Height:     10, // Default height
+			Title:      title,
+			ShowColors: true,
+		},
+	}
+}
+
+// Render generates the ASCII line chart as a string
+func (c *LineChart) Render() string {
+	if len(c.Series.Values) == 0 {
+		return "No data to display"
+	}
+
+	height := c.Config.Height
+	if height < 3 {
+		height = 3
+	}
+	maxValue := seriesMax(c.Series.Values)
+	var maxOverlay float64
+	if c.Overlay != nil {
+		maxOverlay = seriesMax(c.Overlay.Values)
+	}
+
+	var rows [][]string
+	var top func(row int) float64 // Fraction of the maximum at the top of a row
+	labels := c.Labels
+	if c.Braille {
+		rows = c.brailleRows(height, maxValue, maxOverlay)
+		top = func(row int) float64 { return float64(row*4+3) / float64(height*4-1) }
+		labels = nil
+		for i := 0; i < len(c.Labels); i += 2 {
+			labels = append(labels, c.Labels[i])
+		}
+	} else {
+		rows = c.areaRows(height, maxValue, maxOverlay)
+		top = func(row int) float64 { return float64(row+1) / float64(height) }
+	}
+	width := len(rows[0])
+
+	// Axis labels on the top and middle rows and the baseline
+	middle := height / 2
+	leftLabels := map[int]string{
+		height - 1: formatAxisValue(maxValue, c.Series.Unit),
+		middle:     formatAxisValue(maxValue*top(middle), c.Series.Unit),
+	}
+	rightLabels := map[int]string{
+		height - 1: formatAxisValue(maxOverlay, unitOf(c.Overlay)),
+		middle:     formatAxisValue(maxOverlay*top(middle), unitOf(c.Overlay)),
+	}
+	zero := formatAxisValue(0, c.Series.Unit)
+	axisWidth := len(zero)
+	for _, label := range leftLabels {
+		if len(label) > axisWidth {
+			axisWidth = len(label)
+		}
+	}
+
+	var output strings.Builder
+	if c.Config.Title != "" {
+		output.WriteString(c.Config.Title + "\n")
+		output.WriteString(strings.Repeat("═", len([]rune(c.Config.Title))) + "\n")
+	}
+
+	for row := height - 1; row >= 0; row-- {
+		tick := "│"
+		if label, exists := leftLabels[row]; exists {
+			tick = "┤"
+			output.WriteString(fmt.Sprintf("%*s ", axisWidth, label))
+		} else {
+			output.WriteString(strings.Repeat(" ", axisWidth+1))
+		}
+		output.WriteString(tick + strings.Join(rows[height-1-row], ""))
+
+		if c.Overlay != nil {
+			if label, exists := rightLabels[row]; exists {
+				output.WriteString(" " + label)
+			}
+		}
+		output.WriteString("\n")
+	}
+
+	output.WriteString(fmt.Sprintf("%*s └", axisWidth, zero) + strings.Repeat("─", width))
+	if c.Overlay != nil {
+		output.WriteString(" " + formatAxisValue(0, c.Overlay.Unit))
+	}
+	output.WriteString("\n")
+	output.WriteString(strings.Repeat(" ", axisWidth+2) + axisLabels(labels, width) + "\n")
+
+	seriesMark, overlayMark := "█", overlayMarker
+	if c.Braille {
+		seriesMark, overlayMark = "⣿", "⣿"
+	}
+	legend := c.colorize(seriesMark, c.Series.Color) + " " + c.Series.Name
+	if c.Overlay != nil {
+		legend += "   " + c.colorize(overlayMark, c.Overlay.Color) + " " + c.Overlay.Name + " (right axis)"
+	}
+	output.WriteString(strings.Repeat(" ", axisWidth+2) + legend + "\n")
+
+	return output.String()
+}
+
+// areaRows draws the series as a filled area with the overlay as markers,
+// returning the cells of each row from the top
+func (c *LineChart) areaRows(height int, maxValue, maxOverlay float64) [][]string {
+	rows := make([][]string, height)
+	for row := height - 1; row >= 0; row-- {
+		cells := make([]string, len(c.Series.Values))
+		for i, value := range c.Series.Values {
+			if c.Overlay != nil && overlayRow(c.Overlay.Values[i], maxOverlay, height) == row {
+				cells[i] = c.colorize(overlayMarker, c.Overlay.Color)
+				continue
+			}
+			cells[i] = c.colorize(areaCell(value, maxValue, height, row), c.Series.Color)
+		}
+		rows[height-1-row] = cells
+	}
+	return rows
+}
+
+// brailleRows draws each series as a line of Braille dots, returning the
+// cells of each row from the top. Cells holding only overlay dots take the
+// overlay color.
+func (c *LineChart) brailleRows(height int, maxValue, maxOverlay float64) [][]string {
+	width := (len(c.Series.Values) + 1) / 2
+	series := newBrailleCanvas(width, height)
+	series.plot(c.Series.Values, maxValue)
+	var overlay *brailleCanvas
+	if c.Overlay != nil {
+		overlay = newBrailleCanvas(width, height)
+		overlay.plot(c.Overlay.Values, maxOverlay)
+	}
+
+	rows := make([][]string, height)
+	for row := range rows {
+		cells := make([]string, width)
+		for column := range cells {
+			dots, color := series.cells[row][column], c.Series.Color
+			if overlay != nil && overlay.cells[row][column] != 0 {
+				if dots == 0 {
+					color = c.Overlay.Color
+				}
+				dots |= overlay.cells[row][column]
+			}
+			cells[column] = " "
+			if dots != 0 {
+				cells[column] = c.colorize(string(rune(0x2800)+rune(dots)), color)
+			}
+		}
+		rows[row] = cells
+	}
+	return rows
+}
+
+// brailleDots are the bits of the Braille dots of a character by column and
+// row from the top
+var brailleDots = [2][4]byte{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// brailleCanvas is a grid of Braille characters, each 2 dots wide and 4 high
+type brailleCanvas struct {
+	cells  [][]byte // Rows from the top
+	height int      // In dots
+}
+
+func newBrailleCanvas(width, height int) *brailleCanvas {
+	canvas := &brailleCanvas{cells: make([][]byte, height), height: height * 4}
+	for row := range canvas.cells {
+		canvas.cells[row] = make([]byte, width)
+	}
+	return canvas
+}
+
+// set turns on the dot at x, and y counted from the bottom
+func (b *brailleCanvas) set(x, y int) {
+	y = b.height - 1 - y
+	b.cells[y/4][x/2] |= brailleDots[x%2][y%4]
+}
+
+// plot draws values as a line, one dot column per value, joining each point
+// to the previous one with a vertical run of dots
+func (b *brailleCanvas) plot(values []float64, maxValue float64) {
+	previous := -1
+	for x, value := range values {
+		y := 0
+		if maxValue > 0 {
+			y = int(math.Round(value / maxValue * float64(b.height-1)))
+		}
+		from, to := y, y
+		if previous >= 0 && previous != y {
+			if previous < y {
+				from = previous + 1
+			} else {
+				to = previous - 1
+			}
+		}
+		for dot := from; dot <= to; dot++ {
+			b.set(x, dot)
+		}
+		previous = y
+	}
+}
+
+// colorize applies a color when colors are enabled
+func (c *LineChart) colorize(text, color string) string {
+	if !c.Config.ShowColors || color == "" || text == " " {
+		return text
+	}
+	return Colorize(text, color)
+}
+
+// areaCell returns the part of a value's filled area that falls in a row
+func areaCell(value, maxValue float64, height, row int) string {
+	if value <= 0 || maxValue <= 0 {
+		return " "
+	}
+
+	eighths := int(math.Round(value / maxValue * float64(height*8)))
+	if eighths == 0 {
+		eighths = 1 // Keep small non-zero values visible
+	}
+	level := eighths - row*8
+	if level <= 0 {
+		return " "
+	}
+	if level > len(areaLevels) {
+		level = len(areaLevels)
+	}
+	return areaLevels[level-1]
+}
+
+// overlayRow returns the row of an overlay value's marker, or -1 for zero
+// values, which are left off the chart
+func overlayRow(value, maxValue float64, height int) int {
+	if value <= 0 || maxValue <= 0 {
+		return -1
+	}
+	return int(math.Round(value / maxValue * float64(height-1)))
+}
+
+// unitOf returns the unit of a series, or none when there is no series
+func unitOf(series *LineSeries) string {
+	if series == nil {
+		return ""
+	}
+	return series.Unit
+}
+
+// seriesMax returns the largest value of a series
+func seriesMax(values []float64) float64 {
+	var max float64
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+	return max
+}
+
+// formatAxisValue formats an axis label, abbreviating large counts
+func formatAxisValue(value float64, unit string) string {
+	if unit != "" {
+		return fmt.Sprintf("%.1f%s", value, unit)
+	}
+	if value < 10 && value != math.Trunc(value) {
+		return fmt.Sprintf("%.1f", value)
+	}
+	return FormatNumber(int64(math.Round(value)))
+}
+
+// axisLabels spaces out x axis labels under a chart of the given width,
+// starting with the first value and skipping labels that would overlap
+func axisLabels(labels []string, width int) string {
+	line := []rune(strings.Repeat(" ", width))
+	next := 0
+	for i, label := range labels {
+		if i < next || i+len(label) > width {
+			continue
+		}
+		copy(line[i:], []rune(label))
+		next = i + len(label) + 3
+	}
+	return strings.TrimRight(string(line), " ")
+}
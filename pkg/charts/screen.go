@@ -0,0 +1,66 @@
+package charts
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Terminal control sequences used to redraw a screen in place
+const (
+	clearScreen = "\033[2J"
+	hideCursor  = "\033[?25l"
+	showCursor  = "\033[?25h"
+	clearLine   = "\033[K"
+)
+
+// Screen redraws a full-screen view in place, like top, rewriting only the
+// lines that changed since the previous frame instead of scrolling
+type Screen struct {
+	out      io.Writer
+	previous []string
+	started  bool
+}
+
+// NewScreen creates a screen that draws to out, normally os.Stdout
+func NewScreen(out io.Writer) *Screen {
+	return &Screen{out: out}
+}
+
+// Draw shows a frame, clearing the terminal on the first call
+func (s *Screen) Draw(frame string) {
+	var output strings.Builder
+	if !s.started {
+		output.WriteString(clearScreen + hideCursor)
+		s.started = true
+	}
+
+	lines := strings.Split(strings.TrimRight(frame, "\n"), "\n")
+	for i, line := range lines {
+		if i < len(s.previous) && s.previous[i] == line {
+			continue
+		}
+		output.WriteString(fmt.Sprintf("\033[%d;1H%s%s", i+1, line, clearLine))
+	}
+	for i := len(lines); i < len(s.previous); i++ {
+		output.WriteString(fmt.Sprintf("\033[%d;1H%s", i+1, clearLine))
+	}
+	output.WriteString(fmt.Sprintf("\033[%d;1H", len(lines)+1))
+
+	s.previous = lines
+	io.WriteString(s.out, output.String())
+}
+
+// Reset clears the terminal before the next frame, which is then drawn in
+// full, e.g. after the terminal was resized
+func (s *Screen) Reset() {
+	s.started = false
+	s.previous = nil
+}
+
+// Close restores the cursor below the last frame
+func (s *Screen) Close() {
+	if s.started {
+		io.WriteString(s.out, showCursor)
+	}
+}
@@ -0,0 +1,74 @@
+package charts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// Sparkline renders values as a one-line chart of block characters, scaled
+// from zero to the largest value
+func Sparkline(values []float64) string {
+	max := seriesMax(values)
+
+	var line strings.Builder
+	for _, value := range values {
+		level := 0
+		if max > 0 && value > 0 {
+			level = int(math.Round(value / max * float64(len(areaLevels)-1)))
+		}
+		line.WriteString(areaLevels[level])
+	}
+	return line.String()
+}
+
+// TimelineSparklines are one-line charts of traffic, error rate and bandwidth
+// over the analysed time range, for summaries and exports
+type TimelineSparklines struct {
+	Bucket    time.Duration
+	Start     time.Time
+	End       time.Time // End of the last bucket
+	Traffic   string    // Requests per bucket
+	ErrorRate string    // Percentage of 4xx/5xx responses per bucket
+	Bandwidth string    // Bytes sent per bucket
+}
+
+// NewTimelineSparklines buckets a timeline into sparklines at most width
+// characters long. It returns false when there is no timeline, e.g. for
+// results loaded from an export.
+func NewTimelineSparklines(timeline *analyser.RequestTimeline, width int) (TimelineSparklines, bool) {
+	if timeline.Span() == 0 {
+		return TimelineSparklines{}, false
+	}
+	if width < 8 {
+		width = 8
+	}
+
+	bucket, buckets := fitTimelineBuckets(timeline, width, 0)
+	traffic := make([]float64, len(buckets))
+	errorRate := make([]float64, len(buckets))
+	bandwidth := make([]float64, len(buckets))
+	for i, b := range buckets {
+		traffic[i] = float64(b.Requests)
+		errorRate[i] = b.ErrorRate()
+		bandwidth[i] = float64(b.Bytes)
+	}
+
+	return TimelineSparklines{
+		Bucket:    bucket,
+		Start:     buckets[0].Start,
+		End:       buckets[len(buckets)-1].Start.Add(bucket),
+		Traffic:   Sparkline(traffic),
+		ErrorRate: Sparkline(errorRate),
+		Bandwidth: Sparkline(bandwidth),
+	}, true
+}
+
+// Describe returns the bucket size and time range of the sparklines, e.g.
+// "1h buckets, 2024-08-22 10:00 to 2024-08-23 10:00"
+func (s TimelineSparklines) Describe() string {
+	return fmt.Sprintf("%s buckets, %s to %s", formatBucket(s.Bucket), s.Start.Format("2006-01-02 15:04"), s.End.Format("2006-01-02 15:04"))
+}
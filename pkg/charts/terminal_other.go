@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package charts
+
+import "os"
+
+// terminalWidth is not supported on this platform, so the COLUMNS variable
+// or the default width is used
+func terminalWidth(file *os.File) (int, bool) {
+	return 0, false
+}
+
+// WatchTerminalWidth is not supported on this platform: the returned channel
+// never receives
+func WatchTerminalWidth() (widths <-chan int, stop func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,51 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package charts
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// terminalWidth returns the number of columns of the terminal file is
+// attached to, or false when it is not a terminal
+func terminalWidth(file *os.File) (int, bool) {
+	var size struct {
+		rows, cols, xPixels, yPixels uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 || size.cols == 0 {
+		return 0, false
+	}
+	return int(size.cols), true
+}
+
+// WatchTerminalWidth sends the new terminal width whenever the terminal is
+// resized (SIGWINCH), until stop is called
+func WatchTerminalWidth() (widths <-chan int, stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+
+	resized := make(chan int, 1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				select {
+				case resized <- GetTerminalWidth():
+				default: // A resize is already pending
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return resized, func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
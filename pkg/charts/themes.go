@@ -0,0 +1,242 @@
+package charts
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultThemeName is the theme used unless another one is configured
+const DefaultThemeName = "default"
+
+// Theme is a named chart color palette. Empty colors draw plain text, so a
+// theme can leave out colors without disabling them elsewhere.
+type Theme struct {
+	Name        string
+	StatusCodes map[string]string // By status class, e.g. "5xx"
+	Series      []string          // Ranked bars such as top IPs and URLs, cycled
+	Primary     string            // Main line, area and sparkline series
+	Alert       string            // Error rate overlays and regressed cells
+	Good        string            // E.g. human traffic and local networks
+	Warning     string            // E.g. bot traffic and unknown IPs
+	Neutral     string            // E.g. cloud and CDN traffic
+	Heatmap     []string          // Heatmap shades from quiet to busy
+}
+
+// ansi256 returns the foreground code of a color of the 256-color palette
+func ansi256(color int) string {
+	return fmt.Sprintf("\033[38;5;%dm", color)
+}
+
+// themes are the built-in themes by name
+var themes = map[string]Theme{
+	DefaultThemeName: {
+		StatusCodes: map[string]string{
+			"1xx": ColorCyan,
+			"2xx": ColorGreen,
+			"3xx": ColorYellow,
+			"4xx": ColorRed,
+			"5xx": ColorMagenta,
+		},
+		Series:  []string{ColorBlue, ColorCyan, ColorGreen, ColorYellow, ColorMagenta, ColorRed, ColorBrightBlue, ColorBrightCyan},
+		Primary: ColorCyan,
+		Alert:   ColorRed,
+		Good:    ColorGreen,
+		Warning: ColorYellow,
+		Neutral: ColorBlue,
+		Heatmap: []string{ColorDim, ColorBlue, ColorGreen, ColorYellow, ColorRed},
+	},
+	"solarized": {
+		StatusCodes: map[string]string{
+			"1xx": ansi256(37),  // Cyan
+			"2xx": ansi256(64),  // Green
+			"3xx": ansi256(136), // Yellow
+			"4xx": ansi256(166), // Orange
+			"5xx": ansi256(160), // Red
+		},
+		Series:  []string{ansi256(33), ansi256(37), ansi256(64), ansi256(136), ansi256(166), ansi256(125), ansi256(61), ansi256(160)},
+		Primary: ansi256(33),
+		Alert:   ansi256(160),
+		Good:    ansi256(64),
+		Warning: ansi256(136),
+		Neutral: ansi256(61),
+		Heatmap: []string{ansi256(240), ansi256(33), ansi256(37), ansi256(136), ansi256(160)},
+	},
+	"monochrome": {
+		StatusCodes: map[string]string{},
+	},
+	"high-contrast": {
+		StatusCodes: map[string]string{
+			"1xx": ColorBold + ColorBrightCyan,
+			"2xx": ColorBold + ColorBrightGreen,
+			"3xx": ColorBold + ColorBrightYellow,
+			"4xx": ColorBold + ColorBrightRed,
+			"5xx": ColorBold + ColorBrightMagenta,
+		},
+		Series:  []string{ColorBold + ColorBrightWhite, ColorBold + ColorBrightYellow, ColorBold + ColorBrightCyan, ColorBold + ColorBrightGreen, ColorBold + ColorBrightMagenta, ColorBold + ColorBrightRed},
+		Primary: ColorBold + ColorBrightCyan,
+		Alert:   ColorBold + ColorBrightRed,
+		Good:    ColorBold + ColorBrightGreen,
+		Warning: ColorBold + ColorBrightYellow,
+		Neutral: ColorBold + ColorBrightWhite,
+		Heatmap: []string{ColorBrightBlack, ColorBold + ColorBrightCyan, ColorBold + ColorBrightGreen, ColorBold + ColorBrightYellow, ColorBold + ColorBrightRed},
+	},
+}
+
+// colorNames are the color names accepted in theme overrides
+var colorNames = map[string]string{
+	"black":          ColorBlack,
+	"red":            ColorRed,
+	"green":          ColorGreen,
+	"yellow":         ColorYellow,
+	"blue":           ColorBlue,
+	"magenta":        ColorMagenta,
+	"cyan":           ColorCyan,
+	"white":          ColorWhite,
+	"bright-black":   ColorBrightBlack,
+	"bright-red":     ColorBrightRed,
+	"bright-green":   ColorBrightGreen,
+	"bright-yellow":  ColorBrightYellow,
+	"bright-blue":    ColorBrightBlue,
+	"bright-magenta": ColorBrightMagenta,
+	"bright-cyan":    ColorBrightCyan,
+	"bright-white":   ColorBrightWhite,
+	"none":           "",
+}
+
+// ThemeNames returns the names of the built-in themes, sorted
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns the built-in theme with the given name
+func LookupTheme(name string) (Theme, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	theme, exists := themes[name]
+	if !exists {
+		return Theme{}, fmt.Errorf("unknown chart theme %q (themes: %s)", name, strings.Join(ThemeNames(), ", "))
+	}
+	theme.Name = name
+	return theme.copy(), nil
+}
+
+// DefaultTheme returns the default chart theme
+func DefaultTheme() Theme {
+	theme, _ := LookupTheme(DefaultThemeName)
+	return theme
+}
+
+// WithColors returns the theme with some of its colors replaced. Keys are a
+// status class (1xx-5xx), primary, alert, good, warning, neutral, seriesN or
+// heatmapN (counted from 1); values are color names such as red or
+// bright-blue, 256-color palette numbers, or none.
+func (t Theme) WithColors(overrides map[string]string) (Theme, error) {
+	theme := t.copy()
+	for key, value := range overrides {
+		key = strings.ToLower(strings.TrimSpace(key))
+		color, err := ParseColor(value)
+		if err != nil {
+			return Theme{}, fmt.Errorf("%s: %w", key, err)
+		}
+
+		switch key {
+		case "1xx", "2xx", "3xx", "4xx", "5xx":
+			theme.StatusCodes[key] = color
+		case "primary":
+			theme.Primary = color
+		case "alert":
+			theme.Alert = color
+		case "good":
+			theme.Good = color
+		case "warning":
+			theme.Warning = color
+		case "neutral":
+			theme.Neutral = color
+		default:
+			if index, ok := numberedKey(key, "series"); ok {
+				theme.Series = setColor(theme.Series, index, color)
+			} else if index, ok := numberedKey(key, "heatmap"); ok && index < len(heatmapShades) {
+				theme.Heatmap = setColor(theme.Heatmap, index, color)
+			} else {
+				return Theme{}, fmt.Errorf("unknown chart element %q (use 1xx-5xx, primary, alert, good, warning, neutral, series1.. or heatmap1-%d)", key, len(heatmapShades))
+			}
+		}
+	}
+	return theme, nil
+}
+
+// ParseColor returns the terminal code of a color name, e.g. bright-blue, or
+// of a 256-color palette number
+func ParseColor(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if color, exists := colorNames[name]; exists {
+		return color, nil
+	}
+	if number, err := strconv.Atoi(name); err == nil && number >= 0 && number <= 255 {
+		return ansi256(number), nil
+	}
+	return "", fmt.Errorf("unknown color %q (use a name such as red or bright-blue, 0-255, or none)", name)
+}
+
+// statusColor returns the color of a status code's class
+func (t Theme) statusColor(code int) string {
+	if code < 100 || code >= 600 {
+		return t.seriesColor(0)
+	}
+	return t.StatusCodes[fmt.Sprintf("%dxx", code/100)]
+}
+
+// seriesColor returns the color of the bar ranked index
+func (t Theme) seriesColor(index int) string {
+	if len(t.Series) == 0 || index < 0 {
+		return ""
+	}
+	return t.Series[index%len(t.Series)]
+}
+
+// heatmapColor returns the color of a heatmap shade level
+func (t Theme) heatmapColor(level int) string {
+	if level < 0 || level >= len(t.Heatmap) {
+		return ""
+	}
+	return t.Heatmap[level]
+}
+
+// copy returns a theme whose map and slices can be changed independently
+func (t Theme) copy() Theme {
+	copied := t
+	copied.StatusCodes = make(map[string]string, len(t.StatusCodes))
+	for class, color := range t.StatusCodes {
+		copied.StatusCodes[class] = color
+	}
+	copied.Series = append([]string(nil), t.Series...)
+	copied.Heatmap = append([]string(nil), t.Heatmap...)
+	return copied
+}
+
+// numberedKey parses keys such as series3 into a zero-based index
+func numberedKey(key, prefix string) (int, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+	if err != nil || number < 1 {
+		return 0, false
+	}
+	return number - 1, true
+}
+
+// setColor sets one color of a palette, growing it with uncolored entries
+func setColor(palette []string, index int, color string) []string {
+	for len(palette) <= index {
+		palette = append(palette, "")
+	}
+	palette[index] = color
+	return palette
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -266,10 +267,17 @@ func (cm *ConfigManager) createDefaultConfig() *AppConfig {
 			ExportFormats:    []string{"json", "csv"},
 			ShowDetails:      false,
 			TrendAnalysis:    false,
+			AnomalyBaselines: AnomalyBaselineConfig{
+				ErrorRate:             5.0,
+				ErrorRateThreshold:    2.0,
+				NotFoundRate:          2.0,
+				NotFoundRateThreshold: 3.0,
+			},
 		},
-		Servers:   []ServerProfile{},
-		Templates: []ReportTemplate{},
-		Presets:   []AnalysisPreset{},
+		Servers:      []ServerProfile{},
+		Templates:    []ReportTemplate{},
+		Presets:      []AnalysisPreset{},
+		SavedQueries: []SavedQuery{},
 		Preferences: UserPreferences{
 			DefaultExportDir: "output",
 			DefaultConfigDir: "config",
@@ -312,6 +320,46 @@ func (cm *ConfigManager) validateConfig(config *AppConfig) error {
 		}
 	}
 
+	baselines := config.Analysis.AnomalyBaselines
+	if baselines.ErrorRate < 0 || baselines.ErrorRate > 100 {
+		return ConfigValidationError{
+			Field:   "analysis.anomaly_baselines.error_rate",
+			Message: "must be between 0 and 100",
+		}
+	}
+
+	if baselines.NotFoundRate < 0 || baselines.NotFoundRate > 100 {
+		return ConfigValidationError{
+			Field:   "analysis.anomaly_baselines.not_found_rate",
+			Message: "must be between 0 and 100",
+		}
+	}
+
+	if baselines.ErrorRateThreshold < 0 || baselines.NotFoundRateThreshold < 0 {
+		return ConfigValidationError{
+			Field:   "analysis.anomaly_baselines",
+			Message: "thresholds must not be negative",
+		}
+	}
+
+	if err := config.Analysis.TrendAlerts.validate("analysis.trend_alerts"); err != nil {
+		return err
+	}
+	for name, profile := range config.Analysis.TrendAlerts.Profiles {
+		if err := profile.validate("analysis.trend_alerts.profiles." + name); err != nil {
+			return err
+		}
+	}
+	if config.Analysis.Capacity.MaxRPS < 0 {
+		return ConfigValidationError{
+			Field:   "analysis.capacity.max_rps",
+			Message: "must not be negative",
+		}
+	}
+	if err := config.Analysis.SecurityScoring.validate("analysis.security_scoring"); err != nil {
+		return err
+	}
+
 	// Validate server profiles
 	for i, server := range config.Servers {
 		if server.Name == "" {
@@ -350,9 +398,67 @@ func (cm *ConfigManager) validateConfig(config *AppConfig) error {
 		}
 	}
 
+	// Validate saved queries
+	for i, saved := range config.SavedQueries {
+		if saved.Name == "" {
+			return ConfigValidationError{
+				Field:   fmt.Sprintf("saved_queries[%d].name", i),
+				Message: "query name is required",
+			}
+		}
+		if saved.Query == "" {
+			return ConfigValidationError{
+				Field:   fmt.Sprintf("saved_queries[%d].query", i),
+				Message: "query is required",
+			}
+		}
+	}
+
+	// Validate report templates
+	for i, template := range config.Templates {
+		if template.Name == "" {
+			return ConfigValidationError{
+				Field:   fmt.Sprintf("templates[%d].name", i),
+				Message: "template name is required",
+			}
+		}
+		for _, format := range template.Formats {
+			if !containsString(TemplateFormats, format) {
+				return ConfigValidationError{
+					Field:   fmt.Sprintf("templates[%d].formats", i),
+					Message: fmt.Sprintf("unknown format %q (use %s)", format, strings.Join(TemplateFormats, ", ")),
+				}
+			}
+		}
+		for j, section := range template.Sections {
+			if !containsString(TemplateSectionTypes, section.Type) {
+				return ConfigValidationError{
+					Field:   fmt.Sprintf("templates[%d].sections[%d].type", i, j),
+					Message: fmt.Sprintf("unknown section type %q (use %s)", section.Type, strings.Join(TemplateSectionTypes, ", ")),
+				}
+			}
+			if section.Type != "text" && section.Query == "" {
+				return ConfigValidationError{
+					Field:   fmt.Sprintf("templates[%d].sections[%d].query", i, j),
+					Message: "query is required",
+				}
+			}
+		}
+	}
+
 	return nil
 }
 
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 // ConfigDir returns the configuration directory path
 func (cm *ConfigManager) ConfigDir() string {
 	return cm.configDir
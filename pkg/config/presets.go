@@ -14,7 +14,10 @@ func GetBuiltinPresets() []AnalysisPreset {
 			Name:        "security-failed-logins",
 			Description: "Detect failed login attempts and suspicious authentication patterns",
 			Category:    "security",
-			Query:       "SELECT ip, COUNT() as attempts, url FROM logs WHERE status IN (401, 403) GROUP BY ip, url HAVING attempts > 3 ORDER BY attempts DESC",
+			Query:       "SELECT ip, COUNT() as attempts, url FROM logs WHERE status IN (401, 403) GROUP BY ip, url HAVING attempts > :min_attempts ORDER BY attempts DESC",
+			Parameters: []QueryParameter{
+				{Name: "min_attempts", Default: "3", Description: "Failed attempts before an IP is reported"},
+			},
 			Filters: PresetFilters{
 				StatusCodes: []int{401, 403},
 			},
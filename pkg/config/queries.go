@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AddSavedQuery adds a new saved query
+func (cm *ConfigManager) AddSavedQuery(saved SavedQuery) error {
+	config := cm.GetConfig()
+
+	// Check for duplicate names
+	for _, existing := range config.SavedQueries {
+		if existing.Name == saved.Name {
+			return fmt.Errorf("saved query with name '%s' already exists", saved.Name)
+		}
+	}
+
+	saved.CreatedAt = time.Now()
+	saved.UpdatedAt = time.Now()
+	config.SavedQueries = append(config.SavedQueries, saved)
+
+	return cm.Save()
+}
+
+// UpdateSavedQuery replaces an existing saved query, keeping its creation time
+func (cm *ConfigManager) UpdateSavedQuery(name string, saved SavedQuery) error {
+	config := cm.GetConfig()
+
+	for i, existing := range config.SavedQueries {
+		if existing.Name == name {
+			saved.CreatedAt = existing.CreatedAt
+			saved.UpdatedAt = time.Now()
+			config.SavedQueries[i] = saved
+			return cm.Save()
+		}
+	}
+
+	return fmt.Errorf("saved query '%s' not found", name)
+}
+
+// DeleteSavedQuery removes a saved query
+func (cm *ConfigManager) DeleteSavedQuery(name string) error {
+	config := cm.GetConfig()
+
+	for i, saved := range config.SavedQueries {
+		if saved.Name == name {
+			config.SavedQueries = append(config.SavedQueries[:i], config.SavedQueries[i+1:]...)
+			return cm.Save()
+		}
+	}
+
+	return fmt.Errorf("saved query '%s' not found", name)
+}
+
+// GetSavedQuery retrieves a saved query by name
+func (cm *ConfigManager) GetSavedQuery(name string) (*SavedQuery, error) {
+	config := cm.GetConfig()
+
+	for _, saved := range config.SavedQueries {
+		if saved.Name == name {
+			return &saved, nil
+		}
+	}
+
+	return nil, fmt.Errorf("saved query '%s' not found", name)
+}
+
+// ListSavedQueries returns the saved queries sorted by name
+func (cm *ConfigManager) ListSavedQueries() []SavedQuery {
+	queries := append([]SavedQuery(nil), cm.GetConfig().SavedQueries...)
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].Name < queries[j].Name
+	})
+	return queries
+}
+
+// ParameterValues returns the value of each parameter, taking overrides
+// before defaults. Parameters without either are left out.
+func (q SavedQuery) ParameterValues(overrides map[string]string) map[string]string {
+	return parameterValues(q.Parameters, overrides)
+}
+
+// ParameterValues returns the values for the placeholders of the preset query,
+// taking overrides before the preset defaults
+func (p AnalysisPreset) ParameterValues(overrides map[string]string) map[string]string {
+	return parameterValues(p.Parameters, overrides)
+}
+
+// parameterValues merges parameter defaults with overrides
+func parameterValues(params []QueryParameter, overrides map[string]string) map[string]string {
+	values := make(map[string]string, len(params))
+	for _, param := range params {
+		if param.Default != "" {
+			values[param.Name] = param.Default
+		}
+	}
+	for name, value := range overrides {
+		values[name] = value
+	}
+	return values
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecuritySeverities are the severity names of security scoring weights,
+// lowest first
+var SecuritySeverities = []string{"info", "low", "medium", "high", "critical"}
+
+// DefaultRiskLevelBoundaries are the built-in lowest security scores of the
+// risk levels
+var DefaultRiskLevelBoundaries = RiskLevelBoundaries{Minimal: 90, Low: 70, Medium: 50, High: 30}
+
+// Boundaries returns the risk level boundaries with the built-in ones in
+// place of unset ones
+func (b RiskLevelBoundaries) Boundaries() RiskLevelBoundaries {
+	if b.Minimal == 0 {
+		b.Minimal = DefaultRiskLevelBoundaries.Minimal
+	}
+	if b.Low == 0 {
+		b.Low = DefaultRiskLevelBoundaries.Low
+	}
+	if b.Medium == 0 {
+		b.Medium = DefaultRiskLevelBoundaries.Medium
+	}
+	if b.High == 0 {
+		b.High = DefaultRiskLevelBoundaries.High
+	}
+	return b
+}
+
+// IsSet reports whether any dimension weight is set
+func (w SecurityDimensionWeights) IsSet() bool {
+	return w != SecurityDimensionWeights{}
+}
+
+// validate checks the security scoring settings, naming field in its errors
+func (s SecurityScoringConfig) validate(field string) error {
+	weights := s.DimensionWeights
+	if weights.ThreatDetection < 0 || weights.AnomalyDetection < 0 || weights.TrafficIntegrity < 0 || weights.AccessControl < 0 {
+		return ConfigValidationError{
+			Field:   field + ".dimension_weights",
+			Message: "weights must not be negative",
+		}
+	}
+
+	for name, severityWeights := range map[string]map[string]float64{
+		"severity_weights":         s.SeverityWeights,
+		"anomaly_severity_weights": s.AnomalySeverityWeights,
+	} {
+		for severity, weight := range severityWeights {
+			if !containsString(SecuritySeverities, severity) {
+				return ConfigValidationError{
+					Field:   field + "." + name,
+					Message: fmt.Sprintf("unknown severity %q (use %s)", severity, strings.Join(SecuritySeverities, ", ")),
+				}
+			}
+			if weight < 0 {
+				return ConfigValidationError{
+					Field:   field + "." + name + "." + severity,
+					Message: "must not be negative",
+				}
+			}
+		}
+	}
+
+	levels := s.RiskLevels
+	if levels.Minimal < 0 || levels.Low < 0 || levels.Medium < 0 || levels.High < 0 ||
+		levels.Minimal > 100 || levels.Low > 100 || levels.Medium > 100 || levels.High > 100 {
+		return ConfigValidationError{
+			Field:   field + ".risk_levels",
+			Message: "scores must be between 0 and 100",
+		}
+	}
+	boundaries := levels.Boundaries()
+	if boundaries.Minimal <= boundaries.Low || boundaries.Low <= boundaries.Medium || boundaries.Medium <= boundaries.High {
+		return ConfigValidationError{
+			Field:   field + ".risk_levels",
+			Message: fmt.Sprintf("scores must decrease from minimal to high (got %d, %d, %d, %d with the defaults of unset levels)", boundaries.Minimal, boundaries.Low, boundaries.Medium, boundaries.High),
+		}
+	}
+	return nil
+}
@@ -2,9 +2,16 @@ package config
 
 import (
 	"fmt"
+	"sort"
 	"time"
 )
 
+// TemplateFormats are the formats a report template can be generated in
+var TemplateFormats = []string{"html", "json", "csv"}
+
+// TemplateSectionTypes are the kinds of report template sections
+var TemplateSectionTypes = []string{"stats", "chart", "table", "text"}
+
 // GetBuiltinTemplates returns built-in report templates
 func GetBuiltinTemplates() []ReportTemplate {
 	now := time.Now()
@@ -32,7 +39,7 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Attack Patterns Chart",
 					Type:    "chart",
-					Query:   "SELECT status, COUNT() as count FROM logs WHERE status >= 400 GROUP BY status ORDER BY count DESC",
+					Query:   "SELECT status, COUNT() as hits FROM logs WHERE status >= 400 GROUP BY status ORDER BY hits DESC",
 					Config: map[string]interface{}{
 						"chart_type": "bar",
 						"title":      "Attack Patterns by Status Code",
@@ -44,7 +51,7 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Suspicious IPs",
 					Type:    "table",
-					Query:   "SELECT ip, COUNT() as requests, COUNT(DISTINCT url) as unique_urls FROM logs WHERE status >= 400 GROUP BY ip ORDER BY requests DESC LIMIT 15",
+					Query:   "SELECT ip, COUNT() as requests, SUM(size) as total_bytes FROM logs WHERE status >= 400 GROUP BY ip ORDER BY requests DESC LIMIT 15",
 					Order:   4,
 					Enabled: true,
 				},
@@ -75,14 +82,14 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Performance Overview",
 					Type:    "stats",
-					Query:   "SELECT COUNT() as total_requests, AVG(size) as avg_response_size, COUNT(CASE WHEN status >= 400 THEN 1 END) as error_rate FROM logs",
+					Query:   "SELECT COUNT() as total_requests, AVG(size) as avg_response_size, MAX(size) as largest_response FROM logs",
 					Order:   1,
 					Enabled: true,
 				},
 				{
 					Name:    "Response Time Analysis",
 					Type:    "chart",
-					Query:   "SELECT HOUR(timestamp) as hour, AVG(size) as avg_size FROM logs GROUP BY hour ORDER BY hour",
+					Query:   "SELECT HOUR(timestamp), AVG(size) as avg_size FROM logs GROUP BY HOUR(timestamp) ORDER BY HOUR(timestamp)",
 					Config: map[string]interface{}{
 						"chart_type": "line",
 						"title":      "Average Response Size by Hour",
@@ -101,7 +108,7 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Error Analysis",
 					Type:    "chart",
-					Query:   "SELECT status, COUNT() as count FROM logs WHERE status >= 400 GROUP BY status ORDER BY count DESC",
+					Query:   "SELECT status, COUNT() as hits FROM logs WHERE status >= 400 GROUP BY status ORDER BY hits DESC",
 					Config: map[string]interface{}{
 						"chart_type": "pie",
 						"title":      "Error Distribution",
@@ -137,14 +144,14 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Traffic Summary",
 					Type:    "stats",
-					Query:   "SELECT COUNT() as total_requests, COUNT(DISTINCT ip) as unique_visitors, SUM(size) as total_bytes FROM logs",
+					Query:   "SELECT COUNT() as total_requests, SUM(size) as total_bytes, AVG(size) as avg_size FROM logs",
 					Order:   1,
 					Enabled: true,
 				},
 				{
 					Name:    "Hourly Traffic Pattern",
 					Type:    "chart",
-					Query:   "SELECT HOUR(timestamp) as hour, COUNT() as requests FROM logs GROUP BY hour ORDER BY hour",
+					Query:   "SELECT HOUR(timestamp), COUNT() as requests FROM logs GROUP BY HOUR(timestamp) ORDER BY HOUR(timestamp)",
 					Config: map[string]interface{}{
 						"chart_type": "line",
 						"title":      "Requests by Hour",
@@ -161,12 +168,12 @@ func GetBuiltinTemplates() []ReportTemplate {
 					Enabled: true,
 				},
 				{
-					Name:    "Content Type Distribution",
+					Name:    "Requests by Method",
 					Type:    "chart",
-					Query:   "SELECT CASE WHEN url LIKE '%.css' THEN 'CSS' WHEN url LIKE '%.js' THEN 'JavaScript' WHEN url LIKE '%.png' OR url LIKE '%.jpg' THEN 'Images' ELSE 'Dynamic' END as type, COUNT() as requests FROM logs GROUP BY type ORDER BY requests DESC",
+					Query:   "SELECT method, COUNT() as requests FROM logs GROUP BY method ORDER BY requests DESC",
 					Config: map[string]interface{}{
 						"chart_type": "pie",
-						"title":      "Content Type Distribution",
+						"title":      "Requests by HTTP Method",
 						"color":      "#28a745",
 					},
 					Order:   4,
@@ -197,14 +204,14 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Key Metrics",
 					Type:    "stats",
-					Query:   "SELECT COUNT() as total_requests, COUNT(DISTINCT ip) as unique_visitors, COUNT(CASE WHEN status >= 400 THEN 1 END) as errors, SUM(size) as total_bytes FROM logs",
+					Query:   "SELECT COUNT() as total_requests, SUM(size) as total_bytes, MIN(timestamp) as first_request, MAX(timestamp) as last_request FROM logs",
 					Order:   1,
 					Enabled: true,
 				},
 				{
 					Name:    "Traffic Trend",
 					Type:    "chart",
-					Query:   "SELECT DATE(timestamp) as date, COUNT() as requests FROM logs GROUP BY date ORDER BY date",
+					Query:   "SELECT DATE(timestamp), COUNT() as requests FROM logs GROUP BY DATE(timestamp) ORDER BY DATE(timestamp)",
 					Config: map[string]interface{}{
 						"chart_type": "line",
 						"title":      "Daily Traffic Trend",
@@ -216,7 +223,7 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Status Code Distribution",
 					Type:    "chart",
-					Query:   "SELECT CASE WHEN status < 300 THEN 'Success' WHEN status < 400 THEN 'Redirect' WHEN status < 500 THEN 'Client Error' ELSE 'Server Error' END as category, COUNT() as count FROM logs GROUP BY category ORDER BY count DESC",
+					Query:   "SELECT status, COUNT() as hits FROM logs GROUP BY status ORDER BY hits DESC",
 					Config: map[string]interface{}{
 						"chart_type": "pie",
 						"title":      "Response Status Distribution",
@@ -252,14 +259,14 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Analysis Overview",
 					Type:    "stats",
-					Query:   "SELECT COUNT() as total_requests, COUNT(DISTINCT ip) as unique_visitors, MIN(timestamp) as first_request, MAX(timestamp) as last_request FROM logs",
+					Query:   "SELECT COUNT() as total_requests, SUM(size) as total_bytes, MIN(timestamp) as first_request, MAX(timestamp) as last_request FROM logs",
 					Order:   1,
 					Enabled: true,
 				},
 				{
 					Name:    "Top IP Addresses",
 					Type:    "table",
-					Query:   "SELECT ip, COUNT() as requests, COUNT(DISTINCT url) as unique_pages, SUM(size) as total_bytes FROM logs GROUP BY ip ORDER BY requests DESC LIMIT 20",
+					Query:   "SELECT ip, COUNT() as requests, SUM(size) as total_bytes, AVG(size) as avg_size FROM logs GROUP BY ip ORDER BY requests DESC LIMIT 20",
 					Order:   2,
 					Enabled: true,
 				},
@@ -273,7 +280,7 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "HTTP Methods Distribution",
 					Type:    "chart",
-					Query:   "SELECT method, COUNT() as count FROM logs GROUP BY method ORDER BY count DESC",
+					Query:   "SELECT method, COUNT() as hits FROM logs GROUP BY method ORDER BY hits DESC",
 					Config: map[string]interface{}{
 						"chart_type": "bar",
 						"title":      "HTTP Methods Usage",
@@ -292,7 +299,7 @@ func GetBuiltinTemplates() []ReportTemplate {
 				{
 					Name:    "Bandwidth Usage Over Time",
 					Type:    "chart",
-					Query:   "SELECT HOUR(timestamp) as hour, SUM(size) as total_bytes FROM logs GROUP BY hour ORDER BY hour",
+					Query:   "SELECT HOUR(timestamp), SUM(size) as total_bytes FROM logs GROUP BY HOUR(timestamp) ORDER BY HOUR(timestamp)",
 					Config: map[string]interface{}{
 						"chart_type": "line",
 						"title":      "Hourly Bandwidth Usage",
@@ -401,6 +408,42 @@ func (cm *ConfigManager) GetTemplate(name string) (*ReportTemplate, error) {
 	return nil, fmt.Errorf("template '%s' not found", name)
 }
 
+// EnabledSections returns the template's enabled sections in report order
+func (t ReportTemplate) EnabledSections() []TemplateSection {
+	var sections []TemplateSection
+	for _, section := range t.Sections {
+		if section.Enabled {
+			sections = append(sections, section)
+		}
+	}
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Order < sections[j].Order
+	})
+	return sections
+}
+
+// OutputFormats returns the formats the template is generated in by default
+func (t ReportTemplate) OutputFormats() []string {
+	if len(t.Formats) == 0 {
+		return []string{"html"}
+	}
+	return t.Formats
+}
+
+// ResolveTemplate finds a template by name in the configuration, falling back
+// to the built-in templates when they have not been installed
+func (cm *ConfigManager) ResolveTemplate(name string) (*ReportTemplate, error) {
+	if template, err := cm.GetTemplate(name); err == nil {
+		return template, nil
+	}
+	for _, template := range GetBuiltinTemplates() {
+		if template.Name == name {
+			return &template, nil
+		}
+	}
+	return nil, fmt.Errorf("template '%s' not found", name)
+}
+
 // GetTemplatesByCategory retrieves templates by category
 func (cm *ConfigManager) GetTemplatesByCategory(category string) []ReportTemplate {
 	config := cm.GetConfig()
@@ -434,7 +477,7 @@ func GetTemplateTemplate(category string) ReportTemplate {
 			{
 				Name:    "Analysis Chart",
 				Type:    "chart",
-				Query:   "SELECT status, COUNT() as count FROM logs GROUP BY status ORDER BY count DESC",
+				Query:   "SELECT status, COUNT() as hits FROM logs GROUP BY status ORDER BY hits DESC",
 				Config: map[string]interface{}{
 					"chart_type": "bar",
 					"title":      "Analysis Results",
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TrendAlertMetrics lists the trend metrics that can raise degradation alerts
+var TrendAlertMetrics = []string{"request_volume", "error_rate", "average_response_size", "traffic_volume", "unique_visitors", "bot_traffic", "security_score", "threat_rate"}
+
+// Thresholds returns the trend alert thresholds of a profile: the base
+// thresholds with the profile's non-zero settings on top. An empty profile
+// name returns the base thresholds.
+func (c TrendAlertConfig) Thresholds(profile string) (TrendThresholds, error) {
+	thresholds := c.TrendThresholds
+	if profile == "" {
+		return thresholds, nil
+	}
+
+	override, exists := c.Profiles[profile]
+	if !exists {
+		return thresholds, fmt.Errorf("trend profile '%s' not found (available: %s)", profile, strings.Join(c.ProfileNames(), ", "))
+	}
+	if override.MinChange != 0 {
+		thresholds.MinChange = override.MinChange
+	}
+	if override.ErrorRateThreshold != 0 {
+		thresholds.ErrorRateThreshold = override.ErrorRateThreshold
+	}
+	if override.ResponseTimeThreshold != 0 {
+		thresholds.ResponseTimeThreshold = override.ResponseTimeThreshold
+	}
+	if override.TrafficDropThreshold != 0 {
+		thresholds.TrafficDropThreshold = override.TrafficDropThreshold
+	}
+	if override.MinRequests != 0 {
+		thresholds.MinRequests = override.MinRequests
+	}
+	if len(override.Metrics) > 0 {
+		thresholds.Metrics = override.Metrics
+	}
+	return thresholds, nil
+}
+
+// ProfileNames returns the names of the trend profiles in order
+func (c TrendAlertConfig) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate checks a set of trend thresholds, naming field in its errors
+func (t TrendThresholds) validate(field string) error {
+	if t.MinChange < 0 || t.ErrorRateThreshold < 0 || t.ResponseTimeThreshold < 0 || t.TrafficDropThreshold < 0 || t.MinRequests < 0 {
+		return ConfigValidationError{
+			Field:   field,
+			Message: "thresholds must not be negative",
+		}
+	}
+	for _, metric := range t.Metrics {
+		if !containsString(TrendAlertMetrics, metric) {
+			return ConfigValidationError{
+				Field:   field + ".metrics",
+				Message: fmt.Sprintf("unknown metric %q (use %s)", metric, strings.Join(TrendAlertMetrics, ", ")),
+			}
+		}
+	}
+	return nil
+}
@@ -6,12 +6,13 @@ import (
 
 // AppConfig represents the main application configuration
 type AppConfig struct {
-	Analysis    AnalysisConfig    `yaml:"analysis"`
-	Servers     []ServerProfile   `yaml:"servers"`
-	Templates   []ReportTemplate  `yaml:"templates"`
-	Presets     []AnalysisPreset  `yaml:"presets"`
-	Preferences UserPreferences   `yaml:"preferences"`
-	Version     string            `yaml:"version"`
+	Analysis     AnalysisConfig   `yaml:"analysis"`
+	Servers      []ServerProfile  `yaml:"servers"`
+	Templates    []ReportTemplate `yaml:"templates"`
+	Presets      []AnalysisPreset `yaml:"presets"`
+	SavedQueries []SavedQuery     `yaml:"saved_queries,omitempty"`
+	Preferences  UserPreferences  `yaml:"preferences"`
+	Version      string           `yaml:"version"`
 }
 
 // AnalysisConfig holds default analysis settings
@@ -21,10 +22,79 @@ type AnalysisConfig struct {
 	DefaultTimeRange string   `yaml:"default_time_range"`
 	AutoCharts       bool     `yaml:"auto_charts"`
 	ChartWidth       int      `yaml:"chart_width"`
+	ChartTheme       string            `yaml:"chart_theme,omitempty"`  // Named chart color theme
+	ChartColors      map[string]string `yaml:"chart_colors,omitempty"` // Per-element color overrides, e.g. 5xx: magenta
 	NoColors         bool     `yaml:"no_colors"`
 	ExportFormats    []string `yaml:"export_formats"`
 	ShowDetails      bool     `yaml:"show_details"`
 	TrendAnalysis    bool     `yaml:"trend_analysis"`
+	AnomalyBaselines AnomalyBaselineConfig `yaml:"anomaly_baselines"`
+	TrendAlerts      TrendAlertConfig      `yaml:"trend_alerts,omitempty"`
+	Capacity         CapacityConfig        `yaml:"capacity,omitempty"`
+	SecurityScoring  SecurityScoringConfig `yaml:"security_scoring,omitempty"`
+}
+
+// SecurityScoringConfig tunes the security score and its risk levels to an
+// organization's risk appetite. Zero or empty settings fall back to the
+// built-in scoring.
+type SecurityScoringConfig struct {
+	DimensionWeights       SecurityDimensionWeights `yaml:"dimension_weights,omitempty"`        // Share of each dimension in the security score
+	SeverityWeights        map[string]float64       `yaml:"severity_weights,omitempty"`         // Impact of a threat per severity, e.g. critical: 20
+	AnomalySeverityWeights map[string]float64       `yaml:"anomaly_severity_weights,omitempty"` // Impact of an anomaly per severity
+	RiskLevels             RiskLevelBoundaries      `yaml:"risk_levels,omitempty"`              // Lowest security score of each risk level
+}
+
+// SecurityDimensionWeights are relative, so 40/25/20/15 and 0.4/0.25/0.2/0.15
+// score alike. Once any is set, unset ones weigh nothing.
+type SecurityDimensionWeights struct {
+	ThreatDetection  float64 `yaml:"threat_detection,omitempty"`
+	AnomalyDetection float64 `yaml:"anomaly_detection,omitempty"`
+	TrafficIntegrity float64 `yaml:"traffic_integrity,omitempty"`
+	AccessControl    float64 `yaml:"access_control,omitempty"`
+}
+
+// RiskLevelBoundaries are the lowest security scores (0-100) of the risk
+// levels; scores below High are Critical
+type RiskLevelBoundaries struct {
+	Minimal int `yaml:"minimal,omitempty"`
+	Low     int `yaml:"low,omitempty"`
+	Medium  int `yaml:"medium,omitempty"`
+	High    int `yaml:"high,omitempty"`
+}
+
+// CapacityConfig holds the capacity limits that trend analysis projects the
+// traffic growth against. Zero or empty limits are not projected.
+type CapacityConfig struct {
+	MaxRPS       float64 `yaml:"max_rps,omitempty"`       // Sustained requests per second the servers can handle
+	MaxBandwidth string  `yaml:"max_bandwidth,omitempty"` // Bandwidth the servers can send per second, e.g. 100MB
+}
+
+// TrendAlertConfig holds the thresholds of trend degradation alerts, with
+// named profiles that override them per environment (e.g. production, staging)
+type TrendAlertConfig struct {
+	TrendThresholds `yaml:",inline"`
+	Profiles        map[string]TrendThresholds `yaml:"profiles,omitempty"`
+}
+
+// TrendThresholds are the settings of trend degradation alerts. Zero values
+// fall back to the built-in thresholds.
+type TrendThresholds struct {
+	MinChange             float64  `yaml:"min_change,omitempty"`              // Percent change below which a metric is stable
+	ErrorRateThreshold    float64  `yaml:"error_rate_threshold,omitempty"`    // Percent error rate increase that is critical
+	ResponseTimeThreshold float64  `yaml:"response_time_threshold,omitempty"` // Percent response size/time increase that is critical
+	TrafficDropThreshold  float64  `yaml:"traffic_drop_threshold,omitempty"`  // Percent traffic drop that is critical
+	MinRequests           int      `yaml:"min_requests,omitempty"`            // Requests both periods need before alerts are raised
+	Metrics               []string `yaml:"metrics,omitempty"`                 // Metrics that may raise alerts; all when empty
+}
+
+// AnomalyBaselineConfig holds the expected error and 404 rates (in percent)
+// used by anomaly detection. Zero values fall back to the built-in baselines.
+type AnomalyBaselineConfig struct {
+	ErrorRate             float64 `yaml:"error_rate"`
+	ErrorRateThreshold    float64 `yaml:"error_rate_threshold"`
+	NotFoundRate          float64 `yaml:"not_found_rate"`
+	NotFoundRateThreshold float64 `yaml:"not_found_rate_threshold"`
+	LearnedFrom           string  `yaml:"learned_from,omitempty"` // Reference log the rates were learned from
 }
 
 // ServerProfile represents a server connection configuration
@@ -41,15 +111,34 @@ type ServerProfile struct {
 
 // AnalysisPreset represents a saved analysis configuration
 type AnalysisPreset struct {
-	Name        string        `yaml:"name"`
-	Description string        `yaml:"description"`
-	Category    string        `yaml:"category"`
-	Query       string        `yaml:"query,omitempty"`
-	Filters     PresetFilters `yaml:"filters"`
-	Exports     []ExportConfig `yaml:"exports"`
-	Charts      []ChartConfig  `yaml:"charts"`
-	CreatedAt   time.Time     `yaml:"created_at"`
-	UpdatedAt   time.Time     `yaml:"updated_at"`
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	Category    string           `yaml:"category"`
+	Query       string           `yaml:"query,omitempty"`
+	Parameters  []QueryParameter `yaml:"parameters,omitempty"` // Defaults for :name placeholders in Query
+	Filters     PresetFilters    `yaml:"filters"`
+	Exports     []ExportConfig   `yaml:"exports"`
+	Charts      []ChartConfig    `yaml:"charts"`
+	CreatedAt   time.Time        `yaml:"created_at"`
+	UpdatedAt   time.Time        `yaml:"updated_at"`
+}
+
+// SavedQuery is a named SLAQ statement that can be rerun with different
+// values for its :name placeholders
+type SavedQuery struct {
+	Name        string           `yaml:"name"`
+	Description string           `yaml:"description,omitempty"`
+	Query       string           `yaml:"query"`
+	Parameters  []QueryParameter `yaml:"parameters,omitempty"`
+	CreatedAt   time.Time        `yaml:"created_at"`
+	UpdatedAt   time.Time        `yaml:"updated_at"`
+}
+
+// QueryParameter describes a :name placeholder of a saved query or preset query
+type QueryParameter struct {
+	Name        string `yaml:"name"`
+	Default     string `yaml:"default,omitempty"`
+	Description string `yaml:"description,omitempty"`
 }
 
 // PresetFilters holds filtering configuration for presets
@@ -68,7 +157,7 @@ type PresetFilters struct {
 
 // ExportConfig defines export settings for presets
 type ExportConfig struct {
-	Format   string `yaml:"format"` // json, csv, html
+	Format   string `yaml:"format"` // json, csv, xlsx, html
 	Filename string `yaml:"filename,omitempty"`
 	Template string `yaml:"template,omitempty"`
 	AutoOpen bool   `yaml:"auto_open"`
@@ -91,6 +180,7 @@ type ReportTemplate struct {
 	Category    string            `yaml:"category"`
 	Sections    []TemplateSection `yaml:"sections"`
 	Style       TemplateStyle     `yaml:"style"`
+	Formats     []string          `yaml:"formats,omitempty"` // html, json, csv; html when empty
 	CreatedAt   time.Time         `yaml:"created_at"`
 	UpdatedAt   time.Time         `yaml:"updated_at"`
 }
@@ -0,0 +1,210 @@
+// Package decode undoes the encodings attackers use to slip payloads past
+// pattern matching: percent-encoding (repeated), %u and backslash escapes,
+// overlong UTF-8, HTML entities, fullwidth characters and base64
+package decode
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPercentPasses bounds the percent-decoding loop; three passes undo
+// triple encoding such as %25252e
+const maxPercentPasses = 3
+
+// Base64 segments shorter than minBase64Length are too likely to be plain
+// words, IDs or tokens
+const minBase64Length = 16
+
+var base64Segment = regexp.MustCompile(`[A-Za-z0-9+/_-]{16,}={0,2}`)
+
+// ForMatching returns the parts joined by spaces, followed on new lines by
+// the decoded form of each part that decodes to something else and by the
+// readable base64 segments in them. Matching against it finds payloads in
+// either form, while patterns for the raw encodings keep working; patterns
+// without newlines never match across forms.
+func ForMatching(parts ...string) string {
+	target := strings.Join(parts, " ")
+	var extra []string
+	for _, part := range parts {
+		normalized := Normalize(part)
+		if normalized != part {
+			extra = append(extra, normalized)
+		}
+		extra = append(extra, Base64Segments(normalized)...)
+	}
+	if len(extra) == 0 {
+		return target
+	}
+	return target + "\n" + strings.Join(extra, "\n")
+}
+
+// Normalize decodes the text until it stops changing or the pass limit is
+// reached. A '+' in a query string is a space.
+func Normalize(text string) string {
+	if !mayBeEncoded(text) {
+		return text
+	}
+	if i := strings.IndexByte(text, '?'); i >= 0 {
+		text = text[:i+1] + strings.ReplaceAll(text[i+1:], "+", " ")
+	}
+	for pass := 0; pass < maxPercentPasses; pass++ {
+		decoded := percentDecode(text)
+		if decoded == text {
+			break
+		}
+		text = decoded
+	}
+	text = fixOverlongUTF8(text)
+	text = unescapeBackslashes(text)
+	text = html.UnescapeString(text)
+	return foldFullwidth(text)
+}
+
+// mayBeEncoded is a quick check for any of the encodings Normalize undoes
+func mayBeEncoded(text string) bool {
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '%', c == '+', c == '\\', c == '&', c >= utf8.RuneSelf:
+			return true
+		}
+	}
+	return false
+}
+
+// percentDecode decodes %XX and %uXXXX escapes, leaving malformed ones as
+// they are
+func percentDecode(text string) string {
+	if !strings.Contains(text, "%") {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '%' {
+			if i+5 < len(text) && (text[i+1] == 'u' || text[i+1] == 'U') {
+				if r, err := strconv.ParseUint(text[i+2:i+6], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 5
+					continue
+				}
+			}
+			if i+2 < len(text) {
+				if c, err := strconv.ParseUint(text[i+1:i+3], 16, 8); err == nil {
+					b.WriteByte(byte(c))
+					i += 2
+					continue
+				}
+			}
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// fixOverlongUTF8 decodes overlong two byte sequences such as C0 AF for '/',
+// which lenient decoders accept and strict pattern matching misses
+func fixOverlongUTF8(text string) string {
+	if utf8.ValidString(text) {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if (c == 0xC0 || c == 0xC1) && i+1 < len(text) && text[i+1]&0xC0 == 0x80 {
+			b.WriteByte((c&0x1F)<<6 | text[i+1]&0x3F)
+			i++
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// unescapeBackslashes decodes \xXX and \uXXXX escapes
+func unescapeBackslashes(text string) string {
+	if !strings.Contains(text, `\`) {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			switch text[i+1] {
+			case 'x', 'X':
+				if i+3 < len(text) {
+					if c, err := strconv.ParseUint(text[i+2:i+4], 16, 8); err == nil {
+						b.WriteByte(byte(c))
+						i += 3
+						continue
+					}
+				}
+			case 'u', 'U':
+				if i+5 < len(text) {
+					if r, err := strconv.ParseUint(text[i+2:i+6], 16, 32); err == nil {
+						b.WriteRune(rune(r))
+						i += 5
+						continue
+					}
+				}
+			}
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// foldFullwidth maps fullwidth forms such as U+FF1C '＜' to ASCII
+func foldFullwidth(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			return r - 0xFF01 + '!'
+		}
+		return r
+	}, text)
+}
+
+// Base64Segments returns the base64 segments of the text that decode to
+// readable text other than JSON, which tokens such as JWTs carry
+func Base64Segments(text string) []string {
+	var segments []string
+	for _, segment := range base64Segment.FindAllString(text, -1) {
+		decoded, ok := decodeBase64(segment)
+		if !ok || json.Valid([]byte(decoded)) {
+			continue
+		}
+		segments = append(segments, decoded)
+	}
+	return segments
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not; false
+// unless the result is almost all printable ASCII
+func decodeBase64(segment string) (string, bool) {
+	raw := strings.TrimRight(segment, "=")
+	if len(raw) < minBase64Length || len(raw)%4 == 1 {
+		return "", false
+	}
+	encoding := base64.RawStdEncoding
+	if strings.ContainsAny(raw, "-_") {
+		encoding = base64.RawURLEncoding
+	}
+	data, err := encoding.DecodeString(raw)
+	if err != nil {
+		return "", false
+	}
+
+	printable := 0
+	for _, c := range data {
+		if c >= 0x20 && c < 0x7F || c == '\t' || c == '\n' || c == '\r' {
+			printable++
+		}
+	}
+	if printable*10 < len(data)*9 {
+		return "", false
+	}
+	return string(data), true
+}
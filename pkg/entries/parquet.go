@@ -0,0 +1,300 @@
+package entries
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// ParquetRowGroupSize is the number of entries buffered per row group
+const ParquetRowGroupSize = 100000
+
+var parquetMagic = []byte("PAR1")
+
+// Parquet physical types, repetitions, converted types, encodings and page
+// types from the format specification
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// parquetColumn is one column of the entries schema. encode appends the
+// column's value for an entry, returning false for a null
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32 // -1 for none
+	optional  bool
+	encode    func(buf *bytes.Buffer, entry *parser.LogEntry) bool
+}
+
+// parquetColumns is the schema of exported entries, matching the NDJSON fields
+var parquetColumns = []parquetColumn{
+	{"ip", parquetByteArray, parquetUTF8, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainString(buf, e.IP) }},
+	{"timestamp", parquetInt64, parquetTimestampMillis, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool {
+		return plainInt64(buf, e.Timestamp.UnixMilli())
+	}},
+	{"method", parquetByteArray, parquetUTF8, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainString(buf, e.Method) }},
+	{"url", parquetByteArray, parquetUTF8, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainString(buf, e.URL) }},
+	{"protocol", parquetByteArray, parquetUTF8, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainString(buf, e.Protocol) }},
+	{"status", parquetInt32, -1, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainInt32(buf, int32(e.Status)) }},
+	{"size", parquetInt64, -1, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainInt64(buf, e.Size) }},
+	{"referer", parquetByteArray, parquetUTF8, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainString(buf, e.Referer) }},
+	{"user_agent", parquetByteArray, parquetUTF8, false, func(buf *bytes.Buffer, e *parser.LogEntry) bool { return plainString(buf, e.UserAgent) }},
+	{"request_time", parquetDouble, -1, true, func(buf *bytes.Buffer, e *parser.LogEntry) bool {
+		if !e.HasRequestTime {
+			return false
+		}
+		return plainInt64(buf, int64(math.Float64bits(e.RequestTime.Seconds())))
+	}},
+}
+
+// Plain encodings: little-endian numbers, and strings prefixed by their length
+
+func plainString(buf *bytes.Buffer, value string) bool {
+	plainInt32(buf, int32(len(value)))
+	buf.WriteString(value)
+	return true
+}
+
+func plainInt32(buf *bytes.Buffer, value int32) bool {
+	var encoded [4]byte
+	binary.LittleEndian.PutUint32(encoded[:], uint32(value))
+	buf.Write(encoded[:])
+	return true
+}
+
+func plainInt64(buf *bytes.Buffer, value int64) bool {
+	var encoded [8]byte
+	binary.LittleEndian.PutUint64(encoded[:], uint64(value))
+	buf.Write(encoded[:])
+	return true
+}
+
+// parquetChunk records where a column chunk was written, for the footer
+type parquetChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+// parquetRowGroup records the column chunks of a written row group
+type parquetRowGroup struct {
+	rows   int64
+	chunks []parquetChunk
+}
+
+// ParquetWriter writes entries as an uncompressed, plain-encoded Parquet
+// file, buffering ParquetRowGroupSize entries per row group
+type ParquetWriter struct {
+	out       io.Writer
+	offset    int64
+	pending   []parser.LogEntry
+	rowGroups []parquetRowGroup
+	rows      int64
+}
+
+// NewParquetWriter starts a Parquet file on out
+func NewParquetWriter(out io.Writer) (*ParquetWriter, error) {
+	w := &ParquetWriter{out: out}
+	if err := w.write(parquetMagic); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write adds an entry, writing a row group when enough are buffered
+func (w *ParquetWriter) Write(entry *parser.LogEntry) error {
+	w.pending = append(w.pending, *entry)
+	if len(w.pending) >= ParquetRowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes the buffered entries and the file footer. It does not close
+// the underlying writer.
+func (w *ParquetWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	footer := w.footer()
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(footer)))
+	if err := w.write(footer); err != nil {
+		return err
+	}
+	if err := w.write(length[:]); err != nil {
+		return err
+	}
+	return w.write(parquetMagic)
+}
+
+// flush writes the buffered entries as a row group of one page per column
+func (w *ParquetWriter) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rows: int64(len(w.pending))}
+	for _, column := range parquetColumns {
+		page := w.page(column)
+
+		header := thriftWriter{}
+		header.beginStruct(0)
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(len(w.pending)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.endStruct()
+
+		chunk := parquetChunk{
+			offset: w.offset,
+			size:   int64(header.buf.Len() + len(page)),
+			values: int64(len(w.pending)),
+		}
+		if err := w.write(header.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := w.write(page); err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.rows += group.rows
+	w.pending = w.pending[:0]
+	return nil
+}
+
+// page encodes the buffered values of a column, preceded by their
+// definition levels when the column is optional
+func (w *ParquetWriter) page(column parquetColumn) []byte {
+	var values bytes.Buffer
+	defined := make([]bool, len(w.pending))
+	for i := range w.pending {
+		defined[i] = column.encode(&values, &w.pending[i])
+	}
+
+	if !column.optional {
+		return values.Bytes()
+	}
+
+	levels := definitionLevels(defined)
+	page := make([]byte, 4, 4+len(levels)+values.Len())
+	binary.LittleEndian.PutUint32(page, uint32(len(levels)))
+	page = append(page, levels...)
+	return append(page, values.Bytes()...)
+}
+
+// definitionLevels encodes null flags as RLE runs of 1-bit levels
+func definitionLevels(defined []bool) []byte {
+	var buf []byte
+	var varint [binary.MaxVarintLen64]byte
+	for start := 0; start < len(defined); {
+		end := start
+		for end < len(defined) && defined[end] == defined[start] {
+			end++
+		}
+		buf = append(buf, varint[:binary.PutUvarint(varint[:], uint64(end-start)<<1)]...)
+		if defined[start] {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		start = end
+	}
+	return buf
+}
+
+// footer encodes the file metadata: the schema and the row groups
+func (w *ParquetWriter) footer() []byte {
+	meta := thriftWriter{}
+	meta.beginStruct(0)
+	meta.i32(1, 1)
+
+	meta.list(2, thriftStruct, len(parquetColumns)+1)
+	meta.beginStruct(0)
+	meta.string(4, "schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.endStruct()
+	for _, column := range parquetColumns {
+		repetition := int32(parquetRequired)
+		if column.optional {
+			repetition = parquetOptional
+		}
+		meta.beginStruct(0)
+		meta.i32(1, column.physical)
+		meta.i32(3, repetition)
+		meta.string(4, column.name)
+		if column.converted >= 0 {
+			meta.i32(6, column.converted)
+		}
+		meta.endStruct()
+	}
+
+	meta.i64(3, w.rows)
+
+	meta.list(4, thriftStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		meta.beginStruct(0)
+		meta.list(1, thriftStruct, len(group.chunks))
+		var total int64
+		for i, chunk := range group.chunks {
+			column := parquetColumns[i]
+			meta.beginStruct(0)
+			meta.i64(2, chunk.offset)
+			meta.beginStruct(3)
+			meta.i32(1, column.physical)
+			meta.list(2, thriftI32, 2)
+			meta.i32Element(parquetPlain)
+			meta.i32Element(parquetRLE)
+			meta.list(3, thriftBinary, 1)
+			meta.stringElement(column.name)
+			meta.i32(4, 0) // Uncompressed
+			meta.i64(5, chunk.values)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.endStruct()
+			meta.endStruct()
+			total += chunk.size
+		}
+		meta.i64(2, total)
+		meta.i64(3, group.rows)
+		meta.endStruct()
+	}
+
+	meta.string(6, "smart-log-analyser")
+	meta.endStruct()
+	return meta.buf.Bytes()
+}
+
+func (w *ParquetWriter) write(data []byte) error {
+	n, err := w.out.Write(data)
+	w.offset += int64(n)
+	return err
+}
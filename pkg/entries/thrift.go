@@ -0,0 +1,96 @@
+package entries
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol field types used by the Parquet metadata
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which
+// Parquet uses for its page headers and file footer
+type thriftWriter struct {
+	buf     bytes.Buffer
+	lastID  int16
+	parents []int16
+}
+
+// field writes a field header, as a delta from the previous field when it fits
+func (w *thriftWriter) field(id int16, fieldType byte) {
+	if delta := id - w.lastID; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.buf.WriteByte(fieldType)
+		w.varint(zigzag(int64(id)))
+	}
+	w.lastID = id
+}
+
+func (w *thriftWriter) i32(id int16, value int32) {
+	w.field(id, thriftI32)
+	w.varint(zigzag(int64(value)))
+}
+
+func (w *thriftWriter) i64(id int16, value int64) {
+	w.field(id, thriftI64)
+	w.varint(zigzag(value))
+}
+
+func (w *thriftWriter) string(id int16, value string) {
+	w.field(id, thriftBinary)
+	w.varint(uint64(len(value)))
+	w.buf.WriteString(value)
+}
+
+// list writes the header of a list field; its elements follow
+func (w *thriftWriter) list(id int16, elementType byte, size int) {
+	w.field(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		w.buf.WriteByte(0xf0 | elementType)
+		w.varint(uint64(size))
+	}
+}
+
+// i32Element and stringElement write list elements
+func (w *thriftWriter) i32Element(value int32) {
+	w.varint(zigzag(int64(value)))
+}
+
+func (w *thriftWriter) stringElement(value string) {
+	w.varint(uint64(len(value)))
+	w.buf.WriteString(value)
+}
+
+// beginStruct starts a struct field, or a list element when id is 0
+func (w *thriftWriter) beginStruct(id int16) {
+	if id != 0 {
+		w.field(id, thriftStruct)
+	}
+	w.parents = append(w.parents, w.lastID)
+	w.lastID = 0
+}
+
+// endStruct ends the current struct with a stop field
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	w.lastID = w.parents[len(w.parents)-1]
+	w.parents = w.parents[:len(w.parents)-1]
+}
+
+func (w *thriftWriter) varint(value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	w.buf.Write(encoded[:binary.PutUvarint(encoded[:], value)])
+}
+
+func zigzag(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
@@ -0,0 +1,152 @@
+package entries
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Writer writes parsed log entries in an export format
+type Writer interface {
+	Write(entry *parser.LogEntry) error
+	Close() error
+}
+
+// Formats lists the supported export formats
+var Formats = []string{"ndjson", "parquet"}
+
+// FormatFromFilename picks the export format from a file extension: .parquet
+// for Parquet, and .ndjson, .jsonl or .json for NDJSON
+func FormatFromFilename(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".parquet":
+		return "parquet", nil
+	case ".ndjson", ".jsonl", ".json":
+		return "ndjson", nil
+	default:
+		return "", fmt.Errorf("cannot tell the export format of %s: use a .parquet or .ndjson extension", filename)
+	}
+}
+
+// Create creates filename and returns a writer of entries to it in the given
+// format. Closing the writer closes the file.
+func Create(filename, format string) (Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewWriterSize(file, 1<<20)
+
+	var writer Writer
+	switch format {
+	case "ndjson":
+		writer = NewNDJSONWriter(buffered)
+	case "parquet":
+		writer, err = NewParquetWriter(buffered)
+	default:
+		err = fmt.Errorf("unknown entry export format %q (use %s)", format, strings.Join(Formats, " or "))
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(filename)
+		return nil, err
+	}
+
+	return &fileWriter{Writer: writer, buffered: buffered, file: file}, nil
+}
+
+// WriteFile writes entries to filename in the format of its extension
+func WriteFile(filename string, logs []*parser.LogEntry) error {
+	format, err := FormatFromFilename(filename)
+	if err != nil {
+		return err
+	}
+
+	writer, err := Create(filename, format)
+	if err != nil {
+		return err
+	}
+	for _, entry := range logs {
+		if err := writer.Write(entry); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// fileWriter flushes and closes the file of a writer when it is closed
+type fileWriter struct {
+	Writer
+	buffered *bufio.Writer
+	file     *os.File
+}
+
+func (w *fileWriter) Close() error {
+	err := w.Writer.Close()
+	if flushErr := w.buffered.Flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ndjsonEntry is the JSON record of an entry, one per line
+type ndjsonEntry struct {
+	IP          string   `json:"ip"`
+	Timestamp   string   `json:"timestamp"`
+	Method      string   `json:"method"`
+	URL         string   `json:"url"`
+	Protocol    string   `json:"protocol"`
+	Status      int      `json:"status"`
+	Size        int64    `json:"size"`
+	Referer     string   `json:"referer"`
+	UserAgent   string   `json:"user_agent"`
+	RequestTime *float64 `json:"request_time"` // Seconds, null when not logged
+}
+
+// NDJSONWriter writes entries as newline-delimited JSON
+type NDJSONWriter struct {
+	encoder *json.Encoder
+}
+
+// NewNDJSONWriter creates a writer of NDJSON records to out
+func NewNDJSONWriter(out io.Writer) *NDJSONWriter {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	return &NDJSONWriter{encoder: encoder}
+}
+
+// Write writes an entry as one JSON line
+func (w *NDJSONWriter) Write(entry *parser.LogEntry) error {
+	record := ndjsonEntry{
+		IP:        entry.IP,
+		Timestamp: entry.Timestamp.Format(time.RFC3339),
+		Method:    entry.Method,
+		URL:       entry.URL,
+		Protocol:  entry.Protocol,
+		Status:    entry.Status,
+		Size:      entry.Size,
+		Referer:   entry.Referer,
+		UserAgent: entry.UserAgent,
+	}
+	if entry.HasRequestTime {
+		seconds := entry.RequestTime.Seconds()
+		record.RequestTime = &seconds
+	}
+	return w.encoder.Encode(record)
+}
+
+// Close does nothing; NDJSON needs no trailer
+func (w *NDJSONWriter) Close() error {
+	return nil
+}
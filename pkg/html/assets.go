@@ -0,0 +1,92 @@
+package html
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed assets/*
+var assetFS embed.FS
+
+// Local copies of the CDN assets that replace the built-in ones in offline
+// reports when found in the assets directory
+const (
+	BootstrapCSSFile = "bootstrap.min.css"
+	BootstrapJSFile  = "bootstrap.bundle.min.js"
+	ChartJSFile      = "chart.umd.min.js"
+)
+
+// ReportAssets are the stylesheets and scripts inlined into offline reports
+type ReportAssets struct {
+	CSS    template.CSS // Bootstrap, or the built-in subset, and the icon styles
+	HeadJS template.JS  // Chart.js, or the built-in chart renderer
+	BodyJS template.JS  // Bootstrap's script, or the built-in tab switching
+}
+
+// SetOffline sets whether reports inline their stylesheets and scripts
+// instead of loading them from a CDN, so they open without network access.
+// Copies of Bootstrap and Chart.js in assetsDir, if given, are inlined in
+// place of the smaller built-in versions.
+func (g *Generator) SetOffline(enabled bool, assetsDir string) {
+	g.offline = enabled
+	g.assetsDir = assetsDir
+}
+
+// reportAssets returns the assets to inline, or nil for online reports
+func (g *Generator) reportAssets() (*ReportAssets, error) {
+	if !g.offline {
+		return nil, nil
+	}
+
+	css, err := g.asset(BootstrapCSSFile, "report.css")
+	if err != nil {
+		return nil, err
+	}
+	icons, err := assetFS.ReadFile("assets/icons.css")
+	if err != nil {
+		return nil, err
+	}
+	chartJS, err := g.asset(ChartJSFile, "charts.js")
+	if err != nil {
+		return nil, err
+	}
+	bootstrapJS, err := g.asset(BootstrapJSFile, "tabs.js")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReportAssets{
+		CSS:    template.CSS(inlineSafe(css+"\n"+string(icons), "</style")),
+		HeadJS: template.JS(inlineSafe(chartJS, "</script")),
+		BodyJS: template.JS(inlineSafe(bootstrapJS, "</script")),
+	}, nil
+}
+
+// asset reads name from the assets directory, falling back to the built-in
+// asset when the directory has no such file
+func (g *Generator) asset(name, builtin string) (string, error) {
+	if g.assetsDir != "" {
+		data, err := os.ReadFile(filepath.Join(g.assetsDir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read asset %s: %w", name, err)
+		}
+	}
+
+	data, err := assetFS.ReadFile("assets/" + builtin)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// inlineSafe keeps an inlined asset from closing its element early
+func inlineSafe(content, closingTag string) string {
+	return strings.ReplaceAll(content, closingTag, `<\`+closingTag[1:])
+}
@@ -0,0 +1,294 @@
+/*
+ * Built-in chart renderer of offline reports. It implements the part of the
+ * Chart.js API the report templates use - new Chart(canvas, {type, data,
+ * options}) with line, bar, horizontal bar, pie and doughnut charts - and
+ * draws them on the canvas without animations or tooltips.
+ */
+(function() {
+    const palette = ['#667eea', '#28a745', '#ffc107', '#dc3545', '#17a2b8', '#6f42c1', '#fd7e14', '#20c997'];
+    const font = '12px system-ui, -apple-system, "Segoe UI", Roboto, Arial, sans-serif';
+
+    function colorAt(color, index) {
+        if (Array.isArray(color)) {
+            return color[index % color.length];
+        }
+        return color || palette[index % palette.length];
+    }
+
+    // niceStep rounds a tick interval to 1, 2 or 5 times a power of ten
+    function niceStep(range, ticks) {
+        const raw = range / ticks;
+        const power = Math.pow(10, Math.floor(Math.log10(raw)));
+        const scaled = raw / power;
+        return (scaled <= 1 ? 1 : scaled <= 2 ? 2 : scaled <= 5 ? 5 : 10) * power;
+    }
+
+    function formatTick(value) {
+        if (Math.abs(value) >= 1000000) {
+            return (value / 1000000).toFixed(1).replace(/\.0$/, '') + 'M';
+        }
+        if (Math.abs(value) >= 1000) {
+            return (value / 1000).toFixed(1).replace(/\.0$/, '') + 'k';
+        }
+        return String(Math.round(value * 100) / 100);
+    }
+
+    function Chart(item, config) {
+        this.canvas = item && item.canvas ? item.canvas : item;
+        this.config = config || {};
+        this.data = this.config.data || {labels: [], datasets: []};
+        this.options = this.config.options || {};
+        this.draw();
+
+        const chart = this;
+        window.addEventListener('resize', function() { chart.draw(); });
+    }
+
+    Chart.defaults = {responsive: true, maintainAspectRatio: false};
+
+    Chart.prototype.draw = function() {
+        const canvas = this.canvas;
+        const parent = canvas.parentElement;
+        let width = 400;
+        if (parent) {
+            const style = getComputedStyle(parent);
+            width = parent.clientWidth - parseFloat(style.paddingLeft) - parseFloat(style.paddingRight);
+        }
+        const height = 300;
+        const ratio = window.devicePixelRatio || 1;
+        canvas.width = Math.max(width, 100) * ratio;
+        canvas.height = height * ratio;
+        canvas.style.width = Math.max(width, 100) + 'px';
+        canvas.style.height = height + 'px';
+
+        const ctx = canvas.getContext('2d');
+        ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+        ctx.clearRect(0, 0, width, height);
+        ctx.font = font;
+        ctx.textBaseline = 'middle';
+
+        const type = this.config.type;
+        const area = this.drawLegend(ctx, width, height);
+        if (type === 'pie' || type === 'doughnut') {
+            this.drawPie(ctx, area, type === 'doughnut');
+        } else {
+            const horizontal = type === 'horizontalBar' || this.options.indexAxis === 'y';
+            this.drawAxes(ctx, area, type === 'line' ? 'line' : 'bar', horizontal);
+        }
+    };
+
+    // drawLegend draws the slice labels of pie charts, or the dataset labels
+    // of other charts, and returns the area left for the chart
+    Chart.prototype.drawLegend = function(ctx, width, height) {
+        const circular = this.config.type === 'pie' || this.config.type === 'doughnut';
+        let entries = [];
+        if (circular) {
+            const dataset = this.data.datasets[0] || {};
+            entries = (this.data.labels || []).map((label, i) => ({label: label, color: colorAt(dataset.backgroundColor, i)}));
+        } else {
+            entries = this.data.datasets
+                .filter(dataset => dataset.label)
+                .map((dataset, i) => ({label: dataset.label, color: dataset.borderColor && this.config.type === 'line' ? dataset.borderColor : colorAt(dataset.backgroundColor, i)}));
+        }
+
+        const legend = ((this.options.plugins || {}).legend) || {};
+        if (entries.length === 0 || legend.display === false) {
+            return {x: 0, y: 0, width: width, height: height};
+        }
+
+        // Lay the entries out in centred rows
+        const rows = [[]];
+        let rowWidth = 0;
+        entries.forEach(entry => {
+            const entryWidth = 18 + ctx.measureText(entry.label).width + 14;
+            if (rowWidth + entryWidth > width && rows[rows.length - 1].length > 0) {
+                rows.push([]);
+                rowWidth = 0;
+            }
+            rows[rows.length - 1].push({entry: entry, width: entryWidth});
+            rowWidth += entryWidth;
+        });
+
+        const legendHeight = rows.length * 20 + 6;
+        const bottom = legend.position === 'bottom';
+        let y = bottom ? height - legendHeight + 13 : 10;
+        rows.forEach(row => {
+            let x = (width - row.reduce((sum, item) => sum + item.width, 0)) / 2;
+            row.forEach(item => {
+                ctx.fillStyle = item.entry.color;
+                ctx.fillRect(x, y - 6, 12, 12);
+                ctx.fillStyle = '#495057';
+                ctx.textAlign = 'left';
+                ctx.fillText(item.entry.label, x + 18, y);
+                x += item.width;
+            });
+            y += 20;
+        });
+
+        return bottom
+            ? {x: 0, y: 0, width: width, height: height - legendHeight}
+            : {x: 0, y: legendHeight, width: width, height: height - legendHeight};
+    };
+
+    Chart.prototype.drawPie = function(ctx, area, doughnut) {
+        const dataset = this.data.datasets[0] || {data: []};
+        const values = dataset.data.map(value => Math.max(Number(value) || 0, 0));
+        const total = values.reduce((sum, value) => sum + value, 0);
+        const radius = Math.max(Math.min(area.width, area.height) / 2 - 10, 10);
+        const cx = area.x + area.width / 2;
+        const cy = area.y + area.height / 2;
+        if (total === 0) {
+            ctx.fillStyle = '#6c757d';
+            ctx.textAlign = 'center';
+            ctx.fillText('No data', cx, cy);
+            return;
+        }
+
+        let angle = -Math.PI / 2;
+        values.forEach((value, i) => {
+            const sweep = value / total * Math.PI * 2;
+            ctx.beginPath();
+            ctx.moveTo(cx, cy);
+            ctx.arc(cx, cy, radius, angle, angle + sweep);
+            ctx.closePath();
+            ctx.fillStyle = colorAt(dataset.backgroundColor, i);
+            ctx.fill();
+            ctx.lineWidth = dataset.borderWidth || 2;
+            ctx.strokeStyle = colorAt(dataset.borderColor || '#fff', i);
+            ctx.stroke();
+            angle += sweep;
+        });
+
+        if (doughnut) {
+            ctx.beginPath();
+            ctx.arc(cx, cy, radius / 2, 0, Math.PI * 2);
+            ctx.fillStyle = '#fff';
+            ctx.fill();
+        }
+    };
+
+    Chart.prototype.drawAxes = function(ctx, area, type, horizontal) {
+        const labels = this.data.labels || [];
+        const datasets = this.data.datasets || [];
+        let max = 0;
+        let min = 0;
+        datasets.forEach(dataset => dataset.data.forEach(value => {
+            max = Math.max(max, Number(value) || 0);
+            min = Math.min(min, Number(value) || 0);
+        }));
+        if (max === min) {
+            max = min + 1;
+        }
+        const step = niceStep(max - min, 5);
+        max = Math.ceil(max / step) * step;
+        min = Math.floor(min / step) * step;
+
+        // Space for tick labels along the value axis and category labels
+        const ticks = [];
+        for (let value = min; value <= max + step / 2; value += step) {
+            ticks.push(value);
+        }
+        const tickWidth = Math.max(...ticks.map(value => ctx.measureText(formatTick(value)).width));
+        const labelWidth = labels.length ? Math.min(Math.max(...labels.map(label => ctx.measureText(String(label)).width)), area.width / 3) : 0;
+        const left = area.x + (horizontal ? labelWidth : tickWidth) + 10;
+        const right = area.x + area.width - 10;
+        const top = area.y + 10;
+        const bottom = area.y + area.height - 24;
+        const plotWidth = Math.max(right - left, 10);
+        const plotHeight = Math.max(bottom - top, 10);
+
+        const valuePosition = value => horizontal
+            ? left + (value - min) / (max - min) * plotWidth
+            : bottom - (value - min) / (max - min) * plotHeight;
+
+        // Grid lines and value ticks
+        ctx.strokeStyle = '#e9ecef';
+        ctx.lineWidth = 1;
+        ctx.fillStyle = '#6c757d';
+        ticks.forEach(value => {
+            const position = valuePosition(value);
+            ctx.beginPath();
+            if (horizontal) {
+                ctx.moveTo(position, top);
+                ctx.lineTo(position, bottom);
+                ctx.textAlign = 'center';
+                ctx.fillText(formatTick(value), position, bottom + 12);
+            } else {
+                ctx.moveTo(left, position);
+                ctx.lineTo(right, position);
+                ctx.textAlign = 'right';
+                ctx.fillText(formatTick(value), left - 6, position);
+            }
+            ctx.stroke();
+        });
+
+        // Category labels, skipping some when they would overlap
+        const slots = labels.length || 1;
+        const slotSize = (horizontal ? plotHeight : plotWidth) / slots;
+        const every = horizontal ? Math.ceil(16 / slotSize) : Math.ceil((labelWidth + 8) / slotSize);
+        ctx.fillStyle = '#495057';
+        labels.forEach((label, i) => {
+            if (i % Math.max(every, 1) !== 0) {
+                return;
+            }
+            const center = (horizontal ? top : left) + slotSize * (i + 0.5);
+            let text = String(label);
+            while (horizontal && text.length > 3 && ctx.measureText(text).width > labelWidth) {
+                text = text.slice(0, -2) + '…';
+            }
+            if (horizontal) {
+                ctx.textAlign = 'right';
+                ctx.fillText(text, left - 6, center);
+            } else {
+                ctx.textAlign = 'center';
+                ctx.fillText(text, center, bottom + 12);
+            }
+        });
+
+        const zero = valuePosition(Math.max(min, 0));
+        datasets.forEach((dataset, d) => {
+            if (type === 'line') {
+                const points = dataset.data.map((value, i) => ({
+                    x: left + slotSize * (i + 0.5),
+                    y: valuePosition(Number(value) || 0)
+                }));
+                if (points.length === 0) {
+                    return;
+                }
+                if (dataset.fill) {
+                    ctx.beginPath();
+                    ctx.moveTo(points[0].x, zero);
+                    points.forEach(point => ctx.lineTo(point.x, point.y));
+                    ctx.lineTo(points[points.length - 1].x, zero);
+                    ctx.closePath();
+                    ctx.fillStyle = colorAt(dataset.backgroundColor, d);
+                    ctx.fill();
+                }
+                ctx.beginPath();
+                points.forEach((point, i) => i === 0 ? ctx.moveTo(point.x, point.y) : ctx.lineTo(point.x, point.y));
+                ctx.strokeStyle = dataset.borderColor || colorAt(undefined, d);
+                ctx.lineWidth = 2;
+                ctx.stroke();
+                return;
+            }
+
+            // Bars of the datasets side by side within each category
+            const barSize = slotSize * 0.8 / datasets.length;
+            dataset.data.forEach((value, i) => {
+                const offset = slotSize * 0.1 + barSize * d + slotSize * i;
+                const position = valuePosition(Number(value) || 0);
+                ctx.fillStyle = colorAt(dataset.backgroundColor, Array.isArray(dataset.backgroundColor) ? i : d);
+                if (horizontal) {
+                    ctx.fillRect(Math.min(zero, position), top + offset, Math.abs(position - zero), barSize);
+                } else {
+                    ctx.fillRect(left + offset, Math.min(zero, position), barSize, Math.abs(position - zero));
+                }
+            });
+        });
+    };
+
+    Chart.prototype.destroy = function() {};
+    Chart.prototype.update = function() { this.draw(); };
+
+    window.Chart = window.Chart || Chart;
+})();
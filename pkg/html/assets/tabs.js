@@ -0,0 +1,34 @@
+/*
+ * Built-in tab switching of offline reports, standing in for Bootstrap's
+ * script: buttons with data-bs-toggle="tab" show the pane of their
+ * data-bs-target, and bootstrap.Tab(button).show() does the same.
+ */
+(function() {
+    function show(button) {
+        const nav = button.closest('.nav');
+        const target = document.querySelector(button.getAttribute('data-bs-target'));
+        if (!nav || !target) {
+            return;
+        }
+        nav.querySelectorAll('.nav-link').forEach(link => link.classList.remove('active'));
+        button.classList.add('active');
+        target.parentElement.querySelectorAll(':scope > .tab-pane').forEach(pane => {
+            pane.classList.remove('active', 'show');
+        });
+        target.classList.add('active', 'show');
+    }
+
+    window.bootstrap = window.bootstrap || {
+        Tab: function(button) {
+            this.show = function() { show(button); };
+        }
+    };
+
+    document.addEventListener('click', function(event) {
+        const button = event.target.closest('[data-bs-toggle="tab"]');
+        if (button) {
+            event.preventDefault();
+            show(button);
+        }
+    });
+})();
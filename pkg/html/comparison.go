@@ -0,0 +1,380 @@
+package html
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// RegressionThreshold is the relative change, in percent, at which a metric
+// moving in the wrong direction is highlighted as a regression
+const RegressionThreshold = 10.0
+
+// Direction in which a metric gets worse; metrics not listed are informational
+var metricWorseWhenHigher = map[string]bool{
+	"Error Rate %":     true,
+	"4xx Rate %":       true,
+	"5xx Rate %":       true,
+	"Security Threats": true,
+	"P95 Request Time": true,
+	"Security Score":   false,
+}
+
+// Metrics measured in bytes, formatted with formatBytes
+var byteMetrics = map[string]bool{
+	"Total Bytes":  true,
+	"Average Size": true,
+	"P50 Size":     true,
+	"P95 Size":     true,
+	"P99 Size":     true,
+}
+
+// ComparisonData contains all data needed for a comparison report
+type ComparisonData struct {
+	Title       string
+	GeneratedAt string
+	BeforeLabel string
+	AfterLabel  string
+	BeforeRange string
+	AfterRange  string
+	Threshold   string // RegressionThreshold, formatted
+
+	Metrics       []ComparisonMetric
+	Regressions   []ComparisonMetric // Metrics that got worse by RegressionThreshold or more
+	Improvements  []ComparisonMetric // Metrics that got better by RegressionThreshold or more
+	StatusClasses []ComparisonMetric
+
+	// Endpoints whose error rate rose, worst first
+	EndpointRegressions []EndpointChangeRow
+
+	URLGainers []MoverRow
+	URLLosers  []MoverRow
+	IPGainers  []MoverRow
+	IPLosers   []MoverRow
+
+	// Requests per hour of day for the traffic chart
+	HourlyLabels []string
+	HourlyBefore []int
+	HourlyAfter  []int
+
+	// Inlined stylesheets and scripts (nil to load them from a CDN)
+	Assets *ReportAssets
+}
+
+// ComparisonMetric is one metric of both datasets with its change
+type ComparisonMetric struct {
+	Name      string
+	Before    string
+	After     string
+	Change    string
+	Percent   string
+	Class     string // "regression", "improvement" or empty
+	Direction string // Arrow showing whether the metric rose or fell
+}
+
+// MoverRow is a URL or IP whose request count rose or fell
+type MoverRow struct {
+	Key    string
+	Before int
+	After  int
+	Change string
+	Badge  string // "new" or "gone" when the key is only in one dataset
+}
+
+// EndpointChangeRow is an endpoint whose error rate changed between datasets
+type EndpointChangeRow struct {
+	Endpoint        string
+	BeforeRequests  int
+	AfterRequests   int
+	BeforeErrorRate string
+	AfterErrorRate  string
+	Change          string
+}
+
+// Endpoints need this many requests in both datasets before an error rate
+// rise is reported, so single failed requests are not flagged
+const minEndpointRequests = 10
+
+// GenerateComparisonReport creates a side-by-side HTML report of two analysis
+// results, e.g. this week against last week. before is the baseline and
+// after the dataset compared against it.
+func (g *Generator) GenerateComparisonReport(before, after *analyser.Results, beforeLabel, afterLabel, outputPath, title string) error {
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	reportData := g.transformComparison(before, after, beforeLabel, afterLabel, title)
+	assets, err := g.reportAssets()
+	if err != nil {
+		return err
+	}
+	reportData.Assets = assets
+
+	// Create output file
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	return g.execute(file, "comparison_report.html", reportData)
+}
+
+// transformComparison computes the deltas between two results
+func (g *Generator) transformComparison(before, after *analyser.Results, beforeLabel, afterLabel, title string) *ComparisonData {
+	data := &ComparisonData{
+		Title:       title,
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+		BeforeLabel: beforeLabel,
+		AfterLabel:  afterLabel,
+		BeforeRange: formatTimeRange(before.TimeRange),
+		AfterRange:  formatTimeRange(after.TimeRange),
+		Threshold:   fmt.Sprintf("%.0f%%", RegressionThreshold),
+	}
+
+	comparison := analyser.Compare(before, after)
+	metrics := comparison.Metrics
+	if before.LatencyHeatmap != nil && after.LatencyHeatmap != nil {
+		metrics = append(metrics, metricDelta("P95 Request Time",
+			before.LatencyHeatmap.OverallP95.Seconds()*1000, after.LatencyHeatmap.OverallP95.Seconds()*1000))
+	}
+	for _, delta := range metrics {
+		metric := comparisonMetric(delta)
+		data.Metrics = append(data.Metrics, metric)
+		switch metric.Class {
+		case "regression":
+			data.Regressions = append(data.Regressions, metric)
+		case "improvement":
+			data.Improvements = append(data.Improvements, metric)
+		}
+	}
+
+	for _, class := range []string{"2xx Success", "3xx Redirect", "4xx Client Error", "5xx Server Error"} {
+		delta := metricDelta(class, float64(before.StatusCodes[class]), float64(after.StatusCodes[class]))
+		metric := comparisonMetric(delta)
+		if strings.HasPrefix(class, "4") || strings.HasPrefix(class, "5") {
+			// Error counts follow traffic, so flag a class only when its share rose
+			beforeShare := shareOf(before.StatusCodes[class], before.TotalRequests)
+			afterShare := shareOf(after.StatusCodes[class], after.TotalRequests)
+			metric.Class = changeClass(beforeShare, afterShare, true)
+		}
+		data.StatusClasses = append(data.StatusClasses, metric)
+	}
+
+	data.EndpointRegressions = endpointRegressions(before.EndpointStats, after.EndpointStats, g.topN)
+
+	urlsBefore, urlsAfter := make(map[string]int), make(map[string]int)
+	for _, url := range before.TopURLs {
+		urlsBefore[url.URL] = url.Count
+	}
+	for _, url := range after.TopURLs {
+		urlsAfter[url.URL] = url.Count
+	}
+	data.URLGainers, data.URLLosers = moverRows(urlsBefore, urlsAfter, g.topN)
+
+	ipsBefore, ipsAfter := make(map[string]int), make(map[string]int)
+	for _, ip := range before.TopIPs {
+		ipsBefore[ip.IP] = ip.Count
+	}
+	for _, ip := range after.TopIPs {
+		ipsAfter[ip.IP] = ip.Count
+	}
+	data.IPGainers, data.IPLosers = moverRows(ipsBefore, ipsAfter, g.topN)
+
+	data.HourlyBefore = make([]int, 24)
+	data.HourlyAfter = make([]int, 24)
+	for hour := 0; hour < 24; hour++ {
+		data.HourlyLabels = append(data.HourlyLabels, fmt.Sprintf("%02d:00", hour))
+	}
+	for _, hourly := range before.HourlyTraffic {
+		if hourly.Hour >= 0 && hourly.Hour < 24 {
+			data.HourlyBefore[hourly.Hour] += hourly.RequestCount
+		}
+	}
+	for _, hourly := range after.HourlyTraffic {
+		if hourly.Hour >= 0 && hourly.Hour < 24 {
+			data.HourlyAfter[hourly.Hour] += hourly.RequestCount
+		}
+	}
+
+	return data
+}
+
+// metricDelta builds a delta the way analyser.Compare does
+func metricDelta(name string, before, after float64) analyser.MetricDelta {
+	delta := analyser.MetricDelta{Name: name, Before: before, After: after, Change: after - before}
+	if before != 0 {
+		delta.PercentChange = (after - before) / before * 100
+	}
+	return delta
+}
+
+// comparisonMetric formats a metric delta and classifies its change
+func comparisonMetric(delta analyser.MetricDelta) ComparisonMetric {
+	format := func(value float64) string {
+		switch {
+		case byteMetrics[delta.Name]:
+			return formatBytes(int64(math.Round(value)))
+		case delta.Name == "P95 Request Time":
+			return formatLatency(time.Duration(value * float64(time.Millisecond)))
+		case strings.HasSuffix(delta.Name, "%"):
+			return fmt.Sprintf("%.2f%%", value)
+		case value != math.Trunc(value):
+			return fmt.Sprintf("%.1f", value)
+		default:
+			return formatNumber(int(value))
+		}
+	}
+
+	metric := ComparisonMetric{
+		Name:    delta.Name,
+		Before:  format(delta.Before),
+		After:   format(delta.After),
+		Percent: "n/a",
+	}
+
+	change := format(math.Abs(delta.Change))
+	switch {
+	case delta.Change > 0:
+		metric.Change, metric.Direction = "+"+change, "▲"
+	case delta.Change < 0:
+		metric.Change, metric.Direction = "-"+change, "▼"
+	default:
+		metric.Change, metric.Direction = change, "="
+	}
+	if delta.Before != 0 {
+		metric.Percent = fmt.Sprintf("%+.1f%%", delta.PercentChange)
+	}
+
+	if worseWhenHigher, exists := metricWorseWhenHigher[delta.Name]; exists {
+		metric.Class = changeClass(delta.Before, delta.After, worseWhenHigher)
+	}
+	return metric
+}
+
+// changeClass returns "regression" or "improvement" when a value moved by at
+// least RegressionThreshold percent, or appeared where it was zero
+func changeClass(before, after float64, worseWhenHigher bool) string {
+	if before == after {
+		return ""
+	}
+	if before != 0 && math.Abs(after-before)/math.Abs(before)*100 < RegressionThreshold {
+		return ""
+	}
+	if (after > before) == worseWhenHigher {
+		return "regression"
+	}
+	return "improvement"
+}
+
+// endpointRegressions returns the endpoints whose error rate rose by at least
+// RegressionThreshold percent, largest rise first
+func endpointRegressions(before, after []analyser.EndpointStat, limit int) []EndpointChangeRow {
+	baseline := make(map[string]analyser.EndpointStat)
+	for _, stat := range before {
+		baseline[stat.Method+" "+stat.Endpoint] = stat
+	}
+
+	type change struct {
+		before, after analyser.EndpointStat
+		rise          float64
+	}
+	var changes []change
+	for _, stat := range after {
+		previous, exists := baseline[stat.Method+" "+stat.Endpoint]
+		if !exists || previous.Count < minEndpointRequests || stat.Count < minEndpointRequests {
+			continue
+		}
+		if changeClass(previous.ErrorRate, stat.ErrorRate, true) != "regression" {
+			continue
+		}
+		changes = append(changes, change{before: previous, after: stat, rise: stat.ErrorRate - previous.ErrorRate})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].rise != changes[j].rise {
+			return changes[i].rise > changes[j].rise
+		}
+		return changes[i].after.Endpoint < changes[j].after.Endpoint
+	})
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+
+	var rows []EndpointChangeRow
+	for _, c := range changes {
+		rows = append(rows, EndpointChangeRow{
+			Endpoint:        c.after.Method + " " + c.after.Endpoint,
+			BeforeRequests:  c.before.Count,
+			AfterRequests:   c.after.Count,
+			BeforeErrorRate: fmt.Sprintf("%.1f%%", c.before.ErrorRate),
+			AfterErrorRate:  fmt.Sprintf("%.1f%%", c.after.ErrorRate),
+			Change:          fmt.Sprintf("%+.1f pts", c.rise),
+		})
+	}
+	return rows
+}
+
+// moverRows splits the keys whose count changed into gainers, largest rise
+// first, and losers, largest fall first
+func moverRows(before, after map[string]int, limit int) ([]MoverRow, []MoverRow) {
+	keys := make(map[string]bool)
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var gainers, losers []analyser.Mover
+	for key := range keys {
+		mover := analyser.Mover{Key: key, Before: before[key], After: after[key], Change: after[key] - before[key]}
+		switch {
+		case mover.Change > 0:
+			gainers = append(gainers, mover)
+		case mover.Change < 0:
+			losers = append(losers, mover)
+		}
+	}
+
+	rows := func(movers []analyser.Mover, less func(a, b int) bool) []MoverRow {
+		sort.Slice(movers, func(i, j int) bool {
+			if movers[i].Change != movers[j].Change {
+				return less(movers[i].Change, movers[j].Change)
+			}
+			return movers[i].Key < movers[j].Key
+		})
+		if limit > 0 && len(movers) > limit {
+			movers = movers[:limit]
+		}
+
+		var result []MoverRow
+		for _, mover := range movers {
+			row := MoverRow{Key: mover.Key, Before: mover.Before, After: mover.After, Change: fmt.Sprintf("%+d", mover.Change)}
+			if mover.Before == 0 {
+				row.Badge = "new"
+			} else if mover.After == 0 {
+				row.Badge = "gone"
+			}
+			result = append(result, row)
+		}
+		return result
+	}
+
+	return rows(gainers, func(a, b int) bool { return a > b }), rows(losers, func(a, b int) bool { return a < b })
+}
+
+// shareOf returns count as a percentage of total
+func shareOf(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total) * 100
+}
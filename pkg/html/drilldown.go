@@ -0,0 +1,263 @@
+package html
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+	"smart-log-analyser/pkg/parser"
+)
+
+// Limits on the lists of each drill-down, which keep the embedded data small
+const (
+	maxDrillDownRows    = 10
+	maxDrillDownThreats = 20
+)
+
+// threatTypeNames names the threat types of the basic security analysis
+var threatTypeNames = map[string]string{
+	"sql_injection":       "SQL Injection",
+	"xss":                 "XSS",
+	"directory_traversal": "Directory Traversal",
+	"unusual_method":      "Unusual Method",
+}
+
+// DrillDownData holds the IP and URL detail views of an interactive report,
+// keyed by IP address and by full URL
+type DrillDownData struct {
+	IPs  map[string]*DrillDown `json:"ips"`
+	URLs map[string]*DrillDown `json:"urls"`
+}
+
+// DrillDown is the detail view of a single IP or URL
+type DrillDown struct {
+	Name        string `json:"name"`
+	Requests    int    `json:"requests"`
+	Bytes       string `json:"bytes"`
+	AverageSize string `json:"averageSize"`
+	ErrorRate   string `json:"errorRate"`
+	FirstSeen   string `json:"firstSeen"`
+	LastSeen    string `json:"lastSeen"`
+	Location    string `json:"location,omitempty"`
+	ThreatScore int    `json:"threatScore,omitempty"`
+
+	// Requests and errors per hour, or per day for longer logs
+	TimelineLabels   []string `json:"timelineLabels"`
+	TimelineRequests []int    `json:"timelineRequests"`
+	TimelineErrors   []int    `json:"timelineErrors"`
+
+	Statuses    []DrillDownCount  `json:"statuses"`
+	Methods     []DrillDownCount  `json:"methods"`
+	UserAgents  []DrillDownCount  `json:"userAgents"`
+	Related     []DrillDownCount  `json:"related"` // URLs requested by an IP, or clients of a URL
+	Threats     []DrillDownThreat `json:"threats"`
+	MoreThreats int               `json:"moreThreats,omitempty"`
+}
+
+// DrillDownCount is one row of a drill-down breakdown
+type DrillDownCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// DrillDownThreat is a threat detected in the requests of a drill-down
+type DrillDownThreat struct {
+	Time     string `json:"time"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	Target   string `json:"target"` // The URL attacked by an IP, or the IP attacking a URL
+	Pattern  string `json:"pattern"`
+}
+
+// SetDrillDownLogs sets the log entries the IP and URL detail views of
+// interactive reports are built from, profiled with a (no entries for no
+// detail views)
+func (g *Generator) SetDrillDownLogs(a *analyser.Analyser, logs []*parser.LogEntry) {
+	g.drillDownAnalyser = a
+	g.drillDownLogs = logs
+}
+
+// drillDowns profiles the IPs and URLs listed in the report
+func (g *Generator) drillDowns(report *ReportData) *DrillDownData {
+	var ips, urls []string
+	for _, row := range report.TopIPs {
+		ips = append(ips, row.IP)
+	}
+	if report.Security != nil {
+		for _, row := range report.Security.RiskIPs {
+			ips = append(ips, row.IP)
+		}
+	}
+	for _, row := range report.TopURLs {
+		urls = append(urls, row.Path)
+	}
+	for _, row := range report.ErrorURLs {
+		urls = append(urls, row.Path)
+	}
+
+	data := &DrillDownData{
+		IPs:  make(map[string]*DrillDown),
+		URLs: make(map[string]*DrillDown),
+	}
+	for ip, profile := range g.drillDownAnalyser.ProfileIPs(g.drillDownLogs, ips) {
+		data.IPs[ip] = ipDrillDown(profile)
+	}
+	for url, profile := range g.drillDownAnalyser.ProfileURLs(g.drillDownLogs, urls) {
+		data.URLs[url] = urlDrillDown(profile)
+	}
+	return data
+}
+
+// drillDownJSON encodes the drill-downs for embedding in a script element;
+// json.Marshal escapes <, > and &, so the data cannot end the element
+func drillDownJSON(data *DrillDownData) (string, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode drill-down data: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// ipDrillDown converts the profile of an IP to its detail view
+func ipDrillDown(profile *analyser.IPProfile) *DrillDown {
+	view := newDrillDown(profile.IP, profile.TotalRequests, profile.TotalBytes,
+		profile.FirstSeen, profile.LastSeen, profile.StatusCodes, profile.Timeline)
+
+	view.Location = profile.Country
+	if profile.Region != "" && profile.Region != profile.Country {
+		view.Location += " (" + profile.Region + ")"
+	}
+	if profile.ThreatSummary != nil {
+		view.ThreatScore = profile.ThreatSummary.ThreatScore
+	}
+
+	for _, method := range profile.Methods {
+		view.Methods = append(view.Methods, DrillDownCount{Label: method.Method, Count: method.Count})
+	}
+	for _, agent := range profile.UserAgents {
+		view.UserAgents = append(view.UserAgents, DrillDownCount{Label: agent.UserAgent, Count: agent.Count})
+	}
+	for _, url := range profile.URLs {
+		view.Related = append(view.Related, DrillDownCount{Label: url.URL, Count: url.Count})
+	}
+	for _, threat := range profile.Threats {
+		view.Threats = append(view.Threats, drillDownThreat(threat, threat.URL))
+	}
+
+	return view.trim()
+}
+
+// urlDrillDown converts the profile of a URL to its detail view
+func urlDrillDown(profile *analyser.URLProfile) *DrillDown {
+	view := newDrillDown(profile.URL, profile.TotalRequests, profile.TotalBytes,
+		profile.FirstSeen, profile.LastSeen, profile.StatusCodes, profile.Timeline)
+
+	for _, method := range profile.Methods {
+		view.Methods = append(view.Methods, DrillDownCount{Label: method.Method, Count: method.Count})
+	}
+	for _, agent := range profile.UserAgents {
+		view.UserAgents = append(view.UserAgents, DrillDownCount{Label: agent.UserAgent, Count: agent.Count})
+	}
+	for _, client := range profile.Clients {
+		view.Related = append(view.Related, DrillDownCount{Label: client.IP, Count: client.Count})
+	}
+	for _, threat := range profile.Threats {
+		view.Threats = append(view.Threats, drillDownThreat(threat, threat.IP))
+	}
+
+	return view.trim()
+}
+
+// newDrillDown fills in the parts of a detail view IPs and URLs have in common
+func newDrillDown(name string, requests int, bytes int64, firstSeen, lastSeen time.Time,
+	statuses []analyser.DetailedStatusCode, timeline []analyser.IPTimelineBucket) *DrillDown {
+	view := &DrillDown{
+		Name:        name,
+		Requests:    requests,
+		Bytes:       formatBytes(bytes),
+		AverageSize: formatBytes(bytes / int64(requests)),
+		FirstSeen:   firstSeen.Format("2006-01-02 15:04:05"),
+		LastSeen:    lastSeen.Format("2006-01-02 15:04:05"),
+	}
+
+	errorCount := 0
+	for _, status := range statuses {
+		view.Statuses = append(view.Statuses, DrillDownCount{Label: strconv.Itoa(status.Code), Count: status.Count})
+		if status.Code >= 400 {
+			errorCount += status.Count
+		}
+	}
+	view.ErrorRate = fmt.Sprintf("%.1f%%", float64(errorCount*100)/float64(requests))
+
+	view.TimelineLabels, view.TimelineRequests, view.TimelineErrors = drillDownTimeline(timeline)
+	return view
+}
+
+// drillDownTimeline fills the hours without requests into a time-ordered
+// hourly timeline, counting per day instead when it spans more than two days
+func drillDownTimeline(timeline []analyser.IPTimelineBucket) ([]string, []int, []int) {
+	if len(timeline) == 0 {
+		return nil, nil, nil
+	}
+
+	start, end := timeline[0].Hour, timeline[len(timeline)-1].Hour
+	bucket, layout := time.Hour, "Jan 02 15:04"
+	if end.Sub(start) > 48*time.Hour {
+		bucket, layout = 24*time.Hour, "Jan 02"
+		year, month, day := start.Date()
+		start = time.Date(year, month, day, 0, 0, 0, 0, start.Location())
+	}
+
+	requests := make([]int, int(end.Sub(start)/bucket)+1)
+	errorCounts := make([]int, len(requests))
+	for _, hour := range timeline {
+		i := int(hour.Hour.Sub(start) / bucket)
+		requests[i] += hour.Requests
+		errorCounts[i] += hour.Errors
+	}
+
+	labels := make([]string, len(requests))
+	for i := range labels {
+		labels[i] = start.Add(time.Duration(i) * bucket).Format(layout)
+	}
+	return labels, requests, errorCounts
+}
+
+// drillDownThreat formats a threat detected in a drill-down
+func drillDownThreat(threat analyser.SecurityThreat, target string) DrillDownThreat {
+	name, ok := threatTypeNames[threat.Type]
+	if !ok {
+		name = strings.Title(strings.ReplaceAll(threat.Type, "_", " "))
+	}
+	return DrillDownThreat{
+		Time:     threat.Timestamp.Format("2006-01-02 15:04:05"),
+		Type:     name,
+		Severity: strings.Title(threat.Severity),
+		Target:   target,
+		Pattern:  threat.Pattern,
+	}
+}
+
+// trim caps the lists of a detail view, keeping the most frequent entries
+// and the first threats
+func (d *DrillDown) trim() *DrillDown {
+	d.Methods = topCounts(d.Methods)
+	d.UserAgents = topCounts(d.UserAgents)
+	d.Related = topCounts(d.Related)
+	if len(d.Threats) > maxDrillDownThreats {
+		d.MoreThreats = len(d.Threats) - maxDrillDownThreats
+		d.Threats = d.Threats[:maxDrillDownThreats]
+	}
+	return d
+}
+
+// topCounts keeps the first rows of a breakdown sorted by count
+func topCounts(counts []DrillDownCount) []DrillDownCount {
+	if len(counts) > maxDrillDownRows {
+		return counts[:maxDrillDownRows]
+	}
+	return counts
+}
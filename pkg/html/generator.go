@@ -2,8 +2,10 @@ package html
 
 import (
 	"embed"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -11,6 +13,9 @@ import (
 	"time"
 
 	"smart-log-analyser/pkg/analyser"
+	"smart-log-analyser/pkg/parser"
+	"smart-log-analyser/pkg/security"
+	"smart-log-analyser/pkg/trends"
 )
 
 //go:embed templates/*
@@ -50,6 +55,9 @@ type ReportData struct {
 	P99Size float64
 	AvgSize float64
 
+	// Latency heatmap (nil unless the logs include request times)
+	LatencyHeatmap *HeatmapTable
+
 	// Geographic Data
 	GeoLabels []string
 	GeoData   []int
@@ -63,11 +71,27 @@ type ReportData struct {
 	SecurityClass  string
 	TotalThreats   int
 	SuspiciousIPs  int
+	VolumeAnomalies []VolumeRow
+
+	// Enhanced security dashboard (nil unless a security analysis was set)
+	Security *SecuritySection
+
+	// Trend analysis (nil unless one was set)
+	Trends *TrendSection
 
 	// Tables Data
 	TopIPs   []IPRow
 	TopURLs  []URLRow
 	ErrorURLs []ErrorRow
+
+	// Full results as JSON for download and drill-downs (empty unless embedded)
+	AnalysisJSON template.JS
+
+	// IP and URL detail views as JSON (empty unless drill-down logs were set)
+	DrillDownJSON template.JS
+
+	// Inlined stylesheets and scripts (nil to load them from a CDN)
+	Assets *ReportAssets
 }
 
 // IPRow represents a row in the top IPs table
@@ -83,6 +107,7 @@ type IPRow struct {
 // URLRow represents a row in the top URLs table
 type URLRow struct {
 	URL           string
+	Path          string // Full URL, the key of its drill-down
 	Count         int
 	Percentage    string
 	AverageSize   string
@@ -92,15 +117,55 @@ type URLRow struct {
 // ErrorRow represents a row in the error analysis table
 type ErrorRow struct {
 	URL         string
+	Path        string // Full URL, the key of its drill-down
 	ErrorCount  int
 	StatusCodes string
 	ErrorRate   string
 }
 
+// HeatmapTable is the hour × endpoint latency heatmap for the performance tab
+type HeatmapTable struct {
+	Hours          []string
+	Rows           []HeatmapRow
+	RegressedHours string
+}
+
+// HeatmapRow is one endpoint of the latency heatmap
+type HeatmapRow struct {
+	Endpoint string
+	P95      string
+	Cells    []HeatmapCell
+}
+
+// HeatmapCell is the P95 request time of one endpoint during one hour
+type HeatmapCell struct {
+	Label     string
+	Title     string
+	Style     template.CSS
+	Regressed bool
+}
+
+// VolumeRow represents an IP with an abnormal download or upload volume
+type VolumeRow struct {
+	IP        string
+	Direction string
+	Volume    string
+	ZScore    string
+	Reason    string
+}
+
 // Generator handles HTML report generation
 type Generator struct {
-	template            *template.Template
-	interactiveTemplate *template.Template
+	templates *template.Template // Every report template, by file name
+	topN      int
+	embedData bool
+	offline   bool
+	assetsDir string
+
+	securityAnalysis  *security.EnhancedSecurityAnalysis
+	trendAnalysis     *trends.TrendAnalysis
+	drillDownAnalyser *analyser.Analyser
+	drillDownLogs     []*parser.LogEntry
 }
 
 // NewGenerator creates a new HTML report generator
@@ -124,26 +189,40 @@ func NewGenerator() (*Generator, error) {
 		"printf": func(format string, args ...interface{}) string {
 			return fmt.Sprintf(format, args...)
 		},
+		"dict": func(pairs ...interface{}) map[string]interface{} {
+			values := make(map[string]interface{})
+			for i := 0; i+1 < len(pairs); i += 2 {
+				key, _ := pairs[i].(string)
+				values[key] = pairs[i+1]
+			}
+			return values
+		},
 	}
 
-	// Parse standard template
-	tmpl, err := template.New("report.html").Funcs(funcMap).ParseFS(templateFS, "templates/report.html")
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse standard template: %w", err)
-	}
-
-	// Parse interactive template
-	interactiveTmpl, err := template.New("interactive_report.html").Funcs(funcMap).ParseFS(templateFS, "templates/interactive_report.html")
+	// Parse every report template into one set, executed by file name
+	templates, err := template.New("").Funcs(funcMap).ParseFS(templateFS, "templates/*.html")
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse interactive template: %w", err)
+		return nil, fmt.Errorf("failed to parse templates: %w", err)
 	}
 
 	return &Generator{
-		template:            tmpl,
-		interactiveTemplate: interactiveTmpl,
+		templates: templates,
+		topN:      10,
+		embedData: true,
 	}, nil
 }
 
+// SetTopN sets how many top IPs and URLs are included in reports (0 for all)
+func (g *Generator) SetTopN(n int) {
+	g.topN = n
+}
+
+// SetEmbedData sets whether interactive reports include the full results as
+// JSON, for download and for the drill-downs
+func (g *Generator) SetEmbedData(enabled bool) {
+	g.embedData = enabled
+}
+
 // GenerateReport creates an HTML report from analysis results
 func (g *Generator) GenerateReport(results *analyser.Results, outputPath string, title string) error {
 	// Create output directory if it doesn't exist
@@ -153,6 +232,11 @@ func (g *Generator) GenerateReport(results *analyser.Results, outputPath string,
 
 	// Transform analysis results to report data
 	reportData := g.transformResults(results, title)
+	assets, err := g.reportAssets()
+	if err != nil {
+		return err
+	}
+	reportData.Assets = assets
 
 	// Create output file
 	file, err := os.Create(outputPath)
@@ -162,8 +246,8 @@ func (g *Generator) GenerateReport(results *analyser.Results, outputPath string,
 	defer file.Close()
 
 	// Execute template
-	if err := g.template.Execute(file, reportData); err != nil {
-		return fmt.Errorf("failed to execute template: %w", err)
+	if err := g.execute(file, "report.html", reportData); err != nil {
+		return err
 	}
 
 	return nil
@@ -178,6 +262,32 @@ func (g *Generator) GenerateInteractiveReport(results *analyser.Results, outputP
 
 	// Transform analysis results to report data
 	reportData := g.transformResults(results, title)
+	assets, err := g.reportAssets()
+	if err != nil {
+		return err
+	}
+	reportData.Assets = assets
+	if g.embedData {
+		// json.Marshal escapes <, > and &, so the data cannot end its script element
+		data, err := json.Marshal(results)
+		if err != nil {
+			return fmt.Errorf("failed to encode analysis data: %w", err)
+		}
+		reportData.AnalysisJSON = template.JS(data)
+	}
+	if g.securityAnalysis != nil {
+		reportData.Security = g.transformSecurity(g.securityAnalysis)
+	}
+	if g.trendAnalysis != nil {
+		reportData.Trends = transformTrends(g.trendAnalysis)
+	}
+	if len(g.drillDownLogs) > 0 {
+		data, err := drillDownJSON(g.drillDowns(reportData))
+		if err != nil {
+			return err
+		}
+		reportData.DrillDownJSON = template.JS(data)
+	}
 
 	// Create output file
 	file, err := os.Create(outputPath)
@@ -187,13 +297,24 @@ func (g *Generator) GenerateInteractiveReport(results *analyser.Results, outputP
 	defer file.Close()
 
 	// Execute interactive template
-	if err := g.interactiveTemplate.Execute(file, reportData); err != nil {
-		return fmt.Errorf("failed to execute interactive template: %w", err)
+	if err := g.execute(file, "interactive_report.html", reportData); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// execute renders the named template, failing clearly when it is not embedded
+func (g *Generator) execute(w io.Writer, name string, data interface{}) error {
+	if g.templates.Lookup(name) == nil {
+		return fmt.Errorf("template %s is not available in this build", name)
+	}
+	if err := g.templates.ExecuteTemplate(w, name, data); err != nil {
+		return fmt.Errorf("failed to execute template %s: %w", name, err)
+	}
+	return nil
+}
+
 // transformResults converts analyser.Results to ReportData
 func (g *Generator) transformResults(results *analyser.Results, title string) *ReportData {
 	now := time.Now()
@@ -206,12 +327,7 @@ func (g *Generator) transformResults(results *analyser.Results, title string) *R
 	botTraffic := results.BotRequests
 	
 	// Format date range
-	dateRange := "N/A"
-	if !results.TimeRange.Start.IsZero() && !results.TimeRange.End.IsZero() {
-		dateRange = fmt.Sprintf("%s to %s", 
-			results.TimeRange.Start.Format("2006-01-02 15:04"),
-			results.TimeRange.End.Format("2006-01-02 15:04"))
-	}
+	dateRange := formatTimeRange(results.TimeRange)
 
 	// Prepare hourly data
 	hourlyLabels := make([]string, 0)
@@ -270,7 +386,7 @@ func (g *Generator) transformResults(results *analyser.Results, title string) *R
 	// Prepare top IPs
 	topIPs := make([]IPRow, 0)
 	for i, ip := range results.TopIPs {
-		if i >= 10 { // Limit to top 10
+		if g.topN > 0 && i >= g.topN {
 			break
 		}
 
@@ -290,12 +406,13 @@ func (g *Generator) transformResults(results *analyser.Results, title string) *R
 	// Prepare top URLs
 	topURLs := make([]URLRow, 0)
 	for i, url := range results.TopURLs {
-		if i >= 10 { // Limit to top 10
+		if g.topN > 0 && i >= g.topN {
 			break
 		}
 
 		topURLs = append(topURLs, URLRow{
 			URL:           truncateURL(url.URL, 80),
+			Path:          url.URL,
 			Count:         url.Count,
 			Percentage:    fmt.Sprintf("%.1f", float64(url.Count*100)/float64(results.TotalRequests)),
 			AverageSize:   "N/A", // TODO: Calculate from results if available
@@ -312,6 +429,7 @@ func (g *Generator) transformResults(results *analyser.Results, title string) *R
 		
 		errorURLs = append(errorURLs, ErrorRow{
 			URL:         truncateURL(errorURL.URL, 60),
+			Path:        errorURL.URL,
 			ErrorCount:  errorURL.Count,
 			StatusCodes: errorURL.FormatStatusCodes(),
 			ErrorRate:   fmt.Sprintf("%.1f", float64(errorURL.Count*100)/float64(results.TotalRequests)),
@@ -363,6 +481,9 @@ func (g *Generator) transformResults(results *analyser.Results, title string) *R
 		SecurityClass:  securityClass,
 		TotalThreats:   getTotalThreats(results),
 		SuspiciousIPs:  getSuspiciousIPCount(results),
+		VolumeAnomalies: getVolumeRows(results),
+
+		LatencyHeatmap: getHeatmapTable(results),
 
 		TopIPs:    topIPs,
 		TopURLs:   topURLs,
@@ -372,6 +493,94 @@ func (g *Generator) transformResults(results *analyser.Results, title string) *R
 
 // Helper functions
 
+// getHeatmapTable shades each heatmap cell by its P95 relative to the slowest cell
+func getHeatmapTable(results *analyser.Results) *HeatmapTable {
+	heatmap := results.LatencyHeatmap
+	if heatmap == nil || len(heatmap.Endpoints) == 0 {
+		return nil
+	}
+
+	var slowest time.Duration
+	for _, row := range heatmap.Cells {
+		for _, cell := range row {
+			if cell.P95 > slowest {
+				slowest = cell.P95
+			}
+		}
+	}
+
+	table := &HeatmapTable{}
+	for hour := 0; hour < 24; hour++ {
+		table.Hours = append(table.Hours, fmt.Sprintf("%02d", hour))
+	}
+
+	for i, endpoint := range heatmap.Endpoints {
+		row := HeatmapRow{Endpoint: endpoint, P95: formatLatency(heatmap.EndpointP95[i])}
+		for hour, cell := range heatmap.Cells[i] {
+			if cell.Requests == 0 {
+				row.Cells = append(row.Cells, HeatmapCell{})
+				continue
+			}
+			intensity := 0.0
+			if slowest > 0 {
+				intensity = float64(cell.P95) / float64(slowest)
+			}
+			row.Cells = append(row.Cells, HeatmapCell{
+				Label:     formatLatency(cell.P95),
+				Title:     fmt.Sprintf("%02d:00 - %d requests, P95 %s", hour, cell.Requests, formatLatency(cell.P95)),
+				Style:     template.CSS(fmt.Sprintf("background-color: rgba(220, 53, 69, %.2f)", 0.1+intensity*0.8)),
+				Regressed: cell.Regressed,
+			})
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	var hours []string
+	for _, hour := range heatmap.RegressedHours {
+		hours = append(hours, fmt.Sprintf("%02d:00 (%s)", hour, formatLatency(heatmap.HourP95[hour])))
+	}
+	table.RegressedHours = strings.Join(hours, ", ")
+
+	return table
+}
+
+// formatTimeRange formats the period covered by results
+func formatTimeRange(timeRange analyser.TimeRange) string {
+	if timeRange.Start.IsZero() || timeRange.End.IsZero() {
+		return "N/A"
+	}
+	return fmt.Sprintf("%s to %s",
+		timeRange.Start.Format("2006-01-02 15:04"),
+		timeRange.End.Format("2006-01-02 15:04"))
+}
+
+// formatLatency formats a request time in milliseconds or seconds
+func formatLatency(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.2fs", d.Seconds())
+}
+
+// getVolumeRows formats the abnormal data volume findings for the security tab
+func getVolumeRows(results *analyser.Results) []VolumeRow {
+	rows := make([]VolumeRow, 0)
+	for _, anomaly := range results.DataVolume.Anomalies {
+		volume := formatBytes(anomaly.Bytes)
+		if anomaly.Direction == analyser.VolumeUpload {
+			volume = fmt.Sprintf("%d upload requests", anomaly.UploadRequests)
+		}
+		rows = append(rows, VolumeRow{
+			IP:        anomaly.IP,
+			Direction: anomaly.Direction,
+			Volume:    volume,
+			ZScore:    fmt.Sprintf("%.1f", anomaly.ZScore),
+			Reason:    anomaly.Reason,
+		})
+	}
+	return rows
+}
+
 func formatBytes(bytes int64) string {
 	if bytes < 1024 {
 		return fmt.Sprintf("%d B", bytes)
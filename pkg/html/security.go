@@ -0,0 +1,498 @@
+package html
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/security"
+)
+
+// maxSecurityIncidents caps the incidents shown on the security timeline
+const maxSecurityIncidents = 20
+
+// SecuritySection is the security dashboard of the interactive report, built
+// from the enhanced security analysis
+type SecuritySection struct {
+	Score         int
+	ScoreClass    string
+	RiskLevel     string
+	RiskClass     string
+	ActiveThreats int
+	CriticalVulns int
+	Anomalies     int
+	Incidents     int
+	HighRiskIPs   int
+	Dimensions    []DimensionRow
+
+	// Threat distribution by attack type and by severity
+	ThreatLabels   []string
+	ThreatData     []int
+	SeverityLabels []string
+	SeverityData   []int
+
+	// Threats detected per hour, or per day for longer logs
+	TimelineLabels []string
+	TimelineData   []int
+
+	RiskIPs         []RiskIPRow
+	MoreRiskIPs     int
+	Techniques      []TechniqueRow
+	OWASP           []OWASPRow
+	Timeline        []IncidentRow
+	MoreIncidents   int
+	Recommendations []RecommendationRow
+}
+
+// DimensionRow is one weighted component of the security score
+type DimensionRow struct {
+	Name  string
+	Score string
+	Style template.CSS
+	Class string
+}
+
+// RiskIPRow represents a high-risk IP in the security tab
+type RiskIPRow struct {
+	IP            string
+	RiskLevel     string
+	RiskClass     string
+	BehaviorScore string
+	Requests      int64
+	ErrorRate     string
+	Threats       int
+	Tags          string
+	LastSeen      string
+}
+
+// TechniqueRow is a MITRE ATT&CK technique with the threats mapped to it
+type TechniqueRow struct {
+	ID      string
+	Name    string
+	Tactic  string
+	URL     string
+	Threats int
+	IPs     int
+}
+
+// OWASPRow is an OWASP Top 10 category with the threats under it
+type OWASPRow struct {
+	ID            string
+	Name          string
+	Threats       int
+	IPs           int
+	Severity      string
+	SeverityClass string
+	AttackTypes   string
+	Endpoints     []string // Most targeted paths with their threats
+	MoreEndpoints int
+}
+
+// IncidentRow is one incident on the security timeline
+type IncidentRow struct {
+	ID            string
+	Title         string
+	Severity      string
+	SeverityClass string
+	Period        string
+	AttackVector  string
+	ThreatActor   string
+	Events        int
+	IOCs          []string
+	Impact        string
+	Phases        []PhaseRow // Gantt bars of the attack phases
+}
+
+// PhaseRow is an attack phase of an incident, drawn as a bar positioned over
+// the course of the incident
+type PhaseRow struct {
+	AttackType string
+	Severity   string
+	Class      string
+	Period     string
+	Events     int
+	Style      template.CSS
+}
+
+// RecommendationRow is a security recommendation with its actions
+type RecommendationRow struct {
+	Title       string
+	Category    string
+	Description string
+	Impact      string
+	ImpactClass string
+	Effort      string
+	Actions     []string
+}
+
+// SetSecurityAnalysis sets the enhanced security analysis shown in the
+// security tab of interactive reports (nil for the basic security summary)
+func (g *Generator) SetSecurityAnalysis(analysis *security.EnhancedSecurityAnalysis) {
+	g.securityAnalysis = analysis
+}
+
+// transformSecurity converts an enhanced security analysis to the security tab
+func (g *Generator) transformSecurity(analysis *security.EnhancedSecurityAnalysis) *SecuritySection {
+	summary := analysis.Summary
+	section := &SecuritySection{
+		Score:         summary.SecurityScore,
+		ScoreClass:    securityScoreClass(summary.SecurityScore),
+		RiskLevel:     summary.OverallRisk.String(),
+		RiskClass:     riskBadgeClass(summary.OverallRisk),
+		ActiveThreats: summary.ActiveThreats,
+		CriticalVulns: summary.CriticalVulns,
+		Anomalies:     len(analysis.Anomalies),
+		Incidents:     len(analysis.Incidents),
+		HighRiskIPs:   len(summary.HighRiskIPs),
+		Dimensions: []DimensionRow{
+			dimensionRow("Threat Detection", summary.SecurityDimensions.ThreatDetection),
+			dimensionRow("Anomaly Detection", summary.SecurityDimensions.AnomalyDetection),
+			dimensionRow("Traffic Integrity", summary.SecurityDimensions.TrafficIntegrity),
+			dimensionRow("Access Control", summary.SecurityDimensions.AccessControl),
+		},
+	}
+
+	section.ThreatLabels, section.ThreatData = threatDistribution(analysis.Threats)
+	section.SeverityLabels, section.SeverityData = severityDistribution(analysis.Threats)
+	section.TimelineLabels, section.TimelineData = threatTimeline(analysis.Threats)
+	section.RiskIPs, section.MoreRiskIPs = g.riskIPRows(analysis)
+	for _, count := range security.MapTechniques(analysis.Threats) {
+		section.Techniques = append(section.Techniques, TechniqueRow{
+			ID:      count.Technique.ID,
+			Name:    count.Technique.Name,
+			Tactic:  count.Technique.Tactic,
+			URL:     count.Technique.URL(),
+			Threats: count.Threats,
+			IPs:     count.IPs,
+		})
+	}
+	section.OWASP = owaspRows(analysis.Threats)
+	section.Timeline, section.MoreIncidents = incidentRows(analysis.Incidents)
+
+	for _, rec := range summary.RecommendedActions {
+		section.Recommendations = append(section.Recommendations, RecommendationRow{
+			Title:       rec.Title,
+			Category:    rec.Category,
+			Description: rec.Description,
+			Impact:      rec.Impact.String(),
+			ImpactClass: severityBadgeClass(rec.Impact),
+			Effort:      rec.Effort,
+			Actions:     rec.Actions,
+		})
+	}
+
+	return section
+}
+
+// owaspRows returns every OWASP Top 10 category with its threats, attack
+// types and most targeted endpoints
+func owaspRows(threats []security.EnhancedThreat) []OWASPRow {
+	var rows []OWASPRow
+	for _, summary := range security.SummarizeOWASP(threats) {
+		row := OWASPRow{
+			ID:      summary.Category.ID,
+			Name:    summary.Category.Name,
+			Threats: summary.Threats,
+			IPs:     summary.IPs,
+		}
+		if summary.Threats > 0 {
+			severity, _ := security.ParseSeverity(summary.Severity)
+			row.Severity = summary.Severity
+			row.SeverityClass = severityBadgeClass(severity)
+
+			attackTypes := make([]string, 0, len(summary.AttackTypes))
+			for name := range summary.AttackTypes {
+				attackTypes = append(attackTypes, name)
+			}
+			sort.Strings(attackTypes)
+			row.AttackTypes = strings.Join(attackTypes, ", ")
+
+			for _, endpoint := range summary.Top {
+				row.Endpoints = append(row.Endpoints, fmt.Sprintf("%s (%d)", endpoint.Path, endpoint.Threats))
+			}
+			row.MoreEndpoints = summary.Endpoints - len(summary.Top)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// threatDistribution counts the threats of each attack type, most common first
+func threatDistribution(threats []security.EnhancedThreat) ([]string, []int) {
+	counts := make(map[string]int)
+	for _, threat := range threats {
+		counts[threatTypeName(threat)]++
+	}
+
+	labels := make([]string, 0, len(counts))
+	for name := range counts {
+		labels = append(labels, name)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if counts[labels[i]] != counts[labels[j]] {
+			return counts[labels[i]] > counts[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+
+	data := make([]int, len(labels))
+	for i, name := range labels {
+		data[i] = counts[name]
+	}
+	return labels, data
+}
+
+// threatTypeName returns the name of the attack type of a threat
+func threatTypeName(threat security.EnhancedThreat) string {
+	if t, ok := threat.Type.(fmt.Stringer); ok {
+		return t.String()
+	}
+	return "Unknown"
+}
+
+// severityDistribution counts the threats of each severity, most severe first
+func severityDistribution(threats []security.EnhancedThreat) ([]string, []int) {
+	severities := []security.ThreatSeverity{
+		security.SeverityCritical,
+		security.SeverityHigh,
+		security.SeverityMedium,
+		security.SeverityLow,
+		security.SeverityInfo,
+	}
+
+	counts := make(map[security.ThreatSeverity]int)
+	for _, threat := range threats {
+		counts[threat.Severity]++
+	}
+
+	labels := make([]string, len(severities))
+	data := make([]int, len(severities))
+	for i, severity := range severities {
+		labels[i] = severity.String()
+		data[i] = counts[severity]
+	}
+	return labels, data
+}
+
+// threatTimeline counts threats per hour, or per day when the threats span
+// more than two days
+func threatTimeline(threats []security.EnhancedThreat) ([]string, []int) {
+	if len(threats) == 0 {
+		return nil, nil
+	}
+
+	start, end := threats[0].Timestamp, threats[0].Timestamp
+	for _, threat := range threats[1:] {
+		if threat.Timestamp.Before(start) {
+			start = threat.Timestamp
+		}
+		if threat.Timestamp.After(end) {
+			end = threat.Timestamp
+		}
+	}
+
+	bucket, layout := time.Hour, "Jan 02 15:04"
+	start = start.Truncate(time.Hour)
+	if end.Sub(start) > 48*time.Hour {
+		bucket, layout = 24*time.Hour, "Jan 02"
+		year, month, day := start.Date()
+		start = time.Date(year, month, day, 0, 0, 0, 0, start.Location())
+	}
+
+	data := make([]int, int(end.Sub(start)/bucket)+1)
+	for _, threat := range threats {
+		data[int(threat.Timestamp.Sub(start)/bucket)]++
+	}
+
+	labels := make([]string, len(data))
+	for i := range labels {
+		labels[i] = start.Add(time.Duration(i) * bucket).Format(layout)
+	}
+	return labels, data
+}
+
+// riskIPRows lists the high-risk IPs, most suspicious first
+func (g *Generator) riskIPRows(analysis *security.EnhancedSecurityAnalysis) ([]RiskIPRow, int) {
+	var profiles []*security.IPBehaviorProfile
+	for _, ip := range analysis.Summary.HighRiskIPs {
+		if profile, ok := analysis.IPProfiles[ip]; ok {
+			profiles = append(profiles, profile)
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].BehaviorScore != profiles[j].BehaviorScore {
+			return profiles[i].BehaviorScore > profiles[j].BehaviorScore
+		}
+		return profiles[i].IP < profiles[j].IP
+	})
+
+	// Profiles do not link their threats, so count them from the detections
+	threats := make(map[string]int)
+	for _, threat := range analysis.Threats {
+		threats[threat.IP]++
+	}
+
+	more := 0
+	if g.topN > 0 && len(profiles) > g.topN {
+		more = len(profiles) - g.topN
+		profiles = profiles[:g.topN]
+	}
+
+	rows := make([]RiskIPRow, len(profiles))
+	for i, profile := range profiles {
+		rows[i] = RiskIPRow{
+			IP:            profile.IP,
+			RiskLevel:     profile.RiskLevel.String(),
+			RiskClass:     riskBadgeClass(profile.RiskLevel),
+			BehaviorScore: fmt.Sprintf("%.2f", profile.BehaviorScore),
+			Requests:      profile.TotalRequests,
+			ErrorRate:     fmt.Sprintf("%.1f%%", profile.ErrorRate*100),
+			Threats:       threats[profile.IP],
+			Tags:          strings.Join(profile.Tags, ", "),
+			LastSeen:      profile.LastSeen.Format("Jan 02 15:04:05"),
+		}
+	}
+	return rows, more
+}
+
+// incidentRows lists the incidents in the order they started, keeping the
+// most severe when there are too many to show
+func incidentRows(incidents []security.IncidentData) ([]IncidentRow, int) {
+	sorted := make([]security.IncidentData, len(incidents))
+	copy(sorted, incidents)
+
+	more := 0
+	if len(sorted) > maxSecurityIncidents {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Severity > sorted[j].Severity
+		})
+		more = len(sorted) - maxSecurityIncidents
+		sorted = sorted[:maxSecurityIncidents]
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	rows := make([]IncidentRow, len(sorted))
+	for i, incident := range sorted {
+		period := incident.StartTime.Format("Jan 02 15:04:05")
+		if incident.EndTime.After(incident.StartTime) {
+			period += " – " + incident.EndTime.Format("15:04:05")
+		}
+		rows[i] = IncidentRow{
+			ID:            incident.ID,
+			Title:         incident.Title,
+			Severity:      incident.Severity.String(),
+			SeverityClass: severityBadgeClass(incident.Severity),
+			Period:        period,
+			AttackVector:  incident.AttackVector,
+			ThreatActor:   incident.ThreatActor,
+			Events:        len(incident.Timeline),
+			IOCs:          incident.IOCs,
+			Impact:        incident.Impact,
+			Phases:        phaseRows(security.NewIncidentTimeline(incident)),
+		}
+	}
+	return rows, more
+}
+
+// phaseRows positions the attack phases of an incident as Gantt bars, with
+// at least a sliver for phases of a single moment
+func phaseRows(timeline security.IncidentTimeline) []PhaseRow {
+	rows := make([]PhaseRow, len(timeline.Phases))
+	for i, phase := range timeline.Phases {
+		left, width := 0.0, 100.0
+		if timeline.Duration > 0 {
+			left = phase.Offset / timeline.Duration * 100
+			width = phase.Duration / timeline.Duration * 100
+		}
+		if width < 1 {
+			width = 1
+		}
+		if left+width > 100 {
+			left = 100 - width
+		}
+		period := phase.Start.Format("15:04:05")
+		if phase.End.After(phase.Start) {
+			period += " – " + phase.End.Format("15:04:05")
+		}
+		severity, _ := security.ParseSeverity(phase.Severity)
+		rows[i] = PhaseRow{
+			AttackType: phase.AttackType,
+			Severity:   phase.Severity,
+			Class:      severityBadgeClass(severity),
+			Period:     period,
+			Events:     phase.Events,
+			Style:      template.CSS(fmt.Sprintf("margin-left: %.1f%%; width: %.1f%%", left, width)),
+		}
+	}
+	return rows
+}
+
+// dimensionRow renders one security dimension score (0-100) as a bar
+func dimensionRow(name string, score float64) DimensionRow {
+	return DimensionRow{
+		Name:  name,
+		Score: fmt.Sprintf("%.0f", score),
+		Style: template.CSS(fmt.Sprintf("width: %.0f%%", score)),
+		Class: scoreBarClass(int(score)),
+	}
+}
+
+// securityScoreClass returns the text class of a security score
+func securityScoreClass(score int) string {
+	switch {
+	case score >= 90:
+		return "security-excellent"
+	case score >= 70:
+		return "security-good"
+	case score >= 50:
+		return "security-fair"
+	case score >= 30:
+		return "security-poor"
+	default:
+		return "security-critical"
+	}
+}
+
+// scoreBarClass returns the background class of a score bar
+func scoreBarClass(score int) string {
+	switch {
+	case score >= 80:
+		return "bg-success"
+	case score >= 60:
+		return "bg-warning"
+	default:
+		return "bg-danger"
+	}
+}
+
+// riskBadgeClass returns the badge class of a risk level
+func riskBadgeClass(risk security.RiskLevel) string {
+	switch risk {
+	case security.RiskCritical, security.RiskHigh:
+		return "bg-danger"
+	case security.RiskMedium:
+		return "bg-warning"
+	case security.RiskLow:
+		return "bg-info"
+	default:
+		return "bg-success"
+	}
+}
+
+// severityBadgeClass returns the badge class of a threat severity
+func severityBadgeClass(severity security.ThreatSeverity) string {
+	switch severity {
+	case security.SeverityCritical, security.SeverityHigh:
+		return "bg-danger"
+	case security.SeverityMedium:
+		return "bg-warning"
+	case security.SeverityLow:
+		return "bg-info"
+	default:
+		return "bg-secondary"
+	}
+}
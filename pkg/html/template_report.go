@@ -0,0 +1,175 @@
+package html
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"smart-log-analyser/pkg/reports"
+)
+
+// templateChartPalette colours pie slices after the chart's own colour
+var templateChartPalette = []string{"#007bff", "#28a745", "#ffc107", "#dc3545", "#17a2b8", "#6f42c1", "#fd7e14", "#20c997", "#6c757d", "#e83e8c"}
+
+// TemplateReportData contains all data needed for a report generated from a
+// report template
+type TemplateReportData struct {
+	Title       string
+	Description string
+	Template    string
+	Category    string
+	GeneratedAt string
+	Entries     string
+
+	// Style of the template
+	Primary     string
+	Secondary   string
+	Theme       string // light, dark or minimal
+	ColumnClass string // Bootstrap column of charts and tables
+	ShowLogo    bool
+	CustomCSS   template.CSS
+
+	Sections []TemplateSectionView
+
+	// Inlined stylesheets and scripts (nil to load them from a CDN)
+	Assets *ReportAssets
+}
+
+// TemplateSectionView is one section of a template report
+type TemplateSectionView struct {
+	reports.Section
+	ID        string
+	Wide      bool        // Text and stats sections span the whole row
+	ChartJSON template.JS // Chart.js configuration of chart sections
+}
+
+// GenerateTemplateReport creates an HTML report from a report built from a
+// report template
+func (g *Generator) GenerateTemplateReport(report *reports.Report, outputPath string) error {
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	reportData, err := transformTemplateReport(report)
+	if err != nil {
+		return err
+	}
+	assets, err := g.reportAssets()
+	if err != nil {
+		return err
+	}
+	reportData.Assets = assets
+
+	// Create output file
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer file.Close()
+
+	return g.execute(file, "template_report.html", reportData)
+}
+
+// transformTemplateReport lays out a built report with its template's style
+func transformTemplateReport(report *reports.Report) (*TemplateReportData, error) {
+	style := report.Style
+	data := &TemplateReportData{
+		Title:       report.Title,
+		Description: report.Description,
+		Template:    report.Template,
+		Category:    report.Category,
+		GeneratedAt: report.GeneratedAt.Format("2006-01-02 15:04:05"),
+		Entries:     formatNumber(report.Entries),
+		Primary:     validColor(style.Colors["primary"], "#007bff"),
+		Secondary:   validColor(style.Colors["secondary"], "#6c757d"),
+		Theme:       style.Theme,
+		ColumnClass: "col-12",
+		ShowLogo:    style.ShowLogo,
+		CustomCSS:   template.CSS(strings.ReplaceAll(style.CustomCSS, "</", `<\/`)), // Cannot close its style element
+	}
+	if style.Layout == "multi-column" {
+		data.ColumnClass = "col-lg-6"
+	}
+
+	for i, section := range report.Sections {
+		view := TemplateSectionView{
+			Section: section,
+			ID:      fmt.Sprintf("section-%d", i+1),
+			Wide:    section.Type == "text" || section.Type == "stats" || section.Error != "",
+		}
+		if section.Chart != nil && section.Error == "" {
+			config, err := templateChartConfig(section.Chart, data.Primary)
+			if err != nil {
+				return nil, err
+			}
+			view.ChartJSON = config
+		}
+		data.Sections = append(data.Sections, view)
+	}
+	return data, nil
+}
+
+// templateChartConfig builds the Chart.js configuration of a chart section.
+// json.Marshal escapes <, > and &, so labels cannot end the script element.
+func templateChartConfig(chart *reports.Chart, primary string) (template.JS, error) {
+	color := validColor(chart.Color, primary)
+
+	dataset := map[string]interface{}{
+		"label": chart.ValueLabel,
+		"data":  chart.Values,
+	}
+	options := map[string]interface{}{
+		"responsive":          true,
+		"maintainAspectRatio": false,
+	}
+
+	chartType := chart.Type
+	switch chartType {
+	case "pie", "doughnut":
+		colors := make([]string, len(chart.Values))
+		palette := append([]string{color}, templateChartPalette...)
+		for i := range colors {
+			colors[i] = palette[i%len(palette)]
+		}
+		dataset["backgroundColor"] = colors
+	case "line":
+		dataset["borderColor"] = color
+		dataset["backgroundColor"] = color
+		dataset["fill"] = false
+		dataset["tension"] = 0.3
+		options["scales"] = map[string]interface{}{"y": map[string]interface{}{"beginAtZero": true}}
+	default:
+		chartType = "bar"
+		dataset["backgroundColor"] = color
+		options["scales"] = map[string]interface{}{"y": map[string]interface{}{"beginAtZero": true}}
+	}
+
+	config, err := json.Marshal(map[string]interface{}{
+		"type": chartType,
+		"data": map[string]interface{}{
+			"labels":   chart.Labels,
+			"datasets": []interface{}{dataset},
+		},
+		"options": options,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode chart %s: %w", chart.Title, err)
+	}
+	return template.JS(config), nil
+}
+
+// cssColor matches the hex and named colours templates may use
+var cssColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// validColor returns color, or fallback when it is not set or not a colour
+func validColor(color, fallback string) string {
+	if cssColor.MatchString(color) {
+		return color
+	}
+	return fallback
+}
@@ -0,0 +1,328 @@
+package html
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"smart-log-analyser/pkg/trends"
+)
+
+// TrendSection is the trends tab of the interactive report, built from the
+// trend analysis
+type TrendSection struct {
+	Health          string
+	HealthClass     string
+	AnalysisType    string
+	Summary         string
+	Comparisons     []TrendComparison
+	Alerts          []TrendAlertRow
+	Endpoints       []TrendEndpointRow
+	Capacity        []TrendCapacityRow
+	Recommendations []string
+}
+
+// TrendComparison is one period-to-period comparison of the trend analysis
+type TrendComparison struct {
+	Baseline   string
+	Current    string
+	Trend      string
+	TrendClass string
+	RiskScore  int
+	RiskClass  string
+	Summary    string
+	Changes    []TrendChangeRow
+
+	// Security posture and attack pressure; empty when a period has no
+	// security metrics
+	SecurityTrend      string
+	SecurityTrendClass string
+	SecurityChanges    []TrendChangeRow
+
+	// Percent change of each metric for the change chart
+	ChartLabels []string
+	ChartData   []float64
+	ChartColors []string
+}
+
+// TrendChangeRow is the change of one metric between two periods
+type TrendChangeRow struct {
+	Metric         string
+	Baseline       string
+	Current        string
+	Change         string
+	Direction      string
+	DirectionClass string
+	Significance   string
+	Description    string
+}
+
+// TrendAlertRow is a degradation alert of the trend analysis
+type TrendAlertRow struct {
+	ID             string
+	Severity       string
+	SeverityClass  string
+	Metric         string
+	Baseline       string
+	Current        string
+	Threshold      string
+	Impact         string
+	Recommendation string
+}
+
+// TrendEndpointRow is the change of one metric of one endpoint
+type TrendEndpointRow struct {
+	Endpoint       string
+	Metric         string
+	Baseline       string
+	Current        string
+	Change         string
+	Direction      string
+	DirectionClass string
+}
+
+// TrendCapacityRow is the projection of one resource to its capacity limit
+type TrendCapacityRow struct {
+	Resource    string
+	Current     string
+	Limit       string
+	Utilization string
+	Days        string
+	Status      string
+	StatusClass string
+	Description string
+}
+
+// SetTrendAnalysis sets the trend analysis shown in a trends tab of
+// interactive reports (nil for no trends tab)
+func (g *Generator) SetTrendAnalysis(analysis *trends.TrendAnalysis) {
+	g.trendAnalysis = analysis
+}
+
+// transformTrends converts a trend analysis to the trends tab
+func transformTrends(analysis *trends.TrendAnalysis) *TrendSection {
+	section := &TrendSection{
+		Health:          strings.Title(analysis.OverallHealth),
+		HealthClass:     healthClass(analysis.OverallHealth),
+		AnalysisType:    strings.Title(analysis.AnalysisType),
+		Summary:         analysis.TrendSummary,
+		Recommendations: analysis.Recommendations,
+	}
+
+	for _, comparison := range analysis.PeriodComparisons {
+		section.Comparisons = append(section.Comparisons, trendComparison(comparison))
+	}
+
+	for _, alert := range analysis.DegradationAlerts {
+		section.Alerts = append(section.Alerts, TrendAlertRow{
+			ID:             alert.AlertID,
+			Severity:       strings.Title(alert.Severity),
+			SeverityClass:  alertSeverityClass(alert.Severity),
+			Metric:         alert.MetricName,
+			Baseline:       formatTrendValue(alert.MetricName, alert.BaselineValue),
+			Current:        formatTrendValue(alert.MetricName, alert.CurrentValue),
+			Threshold:      fmt.Sprintf("%.0f%%", alert.Threshold),
+			Impact:         alert.Impact,
+			Recommendation: alert.Recommendation,
+		})
+	}
+
+	for _, trend := range analysis.EndpointTrends {
+		change := fmt.Sprintf("%+.1f pts", trend.NewValue-trend.OldValue)
+		if trend.MetricName != "Error Rate" {
+			change = fmt.Sprintf("%+.1f%%", trend.PercentChange)
+		}
+		section.Endpoints = append(section.Endpoints, TrendEndpointRow{
+			Endpoint:       trend.Endpoint,
+			Metric:         trend.MetricName,
+			Baseline:       formatEndpointTrendValue(trend.MetricName, trend.OldValue),
+			Current:        formatEndpointTrendValue(trend.MetricName, trend.NewValue),
+			Change:         change,
+			Direction:      strings.Title(trend.Direction.String()),
+			DirectionClass: directionClass(trend.Direction),
+		})
+	}
+
+	for _, projection := range analysis.CapacityProjections {
+		days := "–"
+		if projection.DaysUntilLimit >= 0 {
+			days = fmt.Sprintf("%.0f (%s)", math.Ceil(projection.DaysUntilLimit), projection.LimitDate.Format("2006-01-02"))
+		}
+		section.Capacity = append(section.Capacity, TrendCapacityRow{
+			Resource:    projection.Resource,
+			Current:     trends.FormatCapacityValue(projection.Resource, projection.Current),
+			Limit:       trends.FormatCapacityValue(projection.Resource, projection.Limit),
+			Utilization: fmt.Sprintf("%.0f%%", projection.Utilization),
+			Days:        days,
+			Status:      strings.Title(projection.Status),
+			StatusClass: capacityStatusClass(projection.Status),
+			Description: projection.Description,
+		})
+	}
+
+	return section
+}
+
+// trendComparison formats a period comparison and its metric changes
+func trendComparison(comparison trends.PeriodComparison) TrendComparison {
+	result := TrendComparison{
+		Baseline:   trendPeriod(comparison.BaselinePeriod),
+		Current:    trendPeriod(comparison.CurrentPeriod),
+		Trend:      strings.Title(comparison.OverallTrend.String()),
+		TrendClass: directionClass(comparison.OverallTrend),
+		RiskScore:  comparison.RiskScore,
+		RiskClass:  riskScoreClass(comparison.RiskScore),
+		Summary:    comparison.Summary,
+	}
+
+	for _, change := range comparison.TrendChanges {
+		result.Changes = append(result.Changes, trendChangeRow(change))
+		result.ChartLabels = append(result.ChartLabels, change.MetricName)
+		result.ChartData = append(result.ChartData, math.Round(change.PercentChange*10)/10)
+		result.ChartColors = append(result.ChartColors, directionColor(change.Direction))
+	}
+
+	if len(comparison.SecurityChanges) > 0 {
+		result.SecurityTrend = strings.Title(comparison.SecurityTrend.String())
+		result.SecurityTrendClass = directionClass(comparison.SecurityTrend)
+		for _, change := range comparison.SecurityChanges {
+			result.SecurityChanges = append(result.SecurityChanges, trendChangeRow(change))
+		}
+	}
+
+	return result
+}
+
+// trendChangeRow formats the change of one metric
+func trendChangeRow(change trends.TrendChange) TrendChangeRow {
+	percent := "n/a"
+	if change.OldValue != 0 {
+		percent = fmt.Sprintf("%+.1f%%", change.PercentChange)
+	} else if change.NewValue == 0 {
+		percent = "0.0%"
+	}
+
+	return TrendChangeRow{
+		Metric:         change.MetricName,
+		Baseline:       formatTrendValue(change.MetricName, change.OldValue),
+		Current:        formatTrendValue(change.MetricName, change.NewValue),
+		Change:         percent,
+		Direction:      strings.Title(change.Direction.String()),
+		DirectionClass: directionClass(change.Direction),
+		Significance:   strings.Title(change.Significance),
+		Description:    change.Description,
+	}
+}
+
+// trendPeriod describes a period by its span and request count
+func trendPeriod(period trends.PeriodMetrics) string {
+	if period.StartTime.IsZero() {
+		return fmt.Sprintf("%s (%s requests)", period.Period, formatNumber(period.TotalRequests))
+	}
+	return fmt.Sprintf("%s: %s – %s (%s requests)", period.Period,
+		period.StartTime.Format("2006-01-02 15:04"), period.EndTime.Format("2006-01-02 15:04"),
+		formatNumber(period.TotalRequests))
+}
+
+// formatTrendValue formats a trend metric value in the unit of the metric
+func formatTrendValue(metric string, value float64) string {
+	switch metric {
+	case "Average Response Size", "Traffic Volume":
+		return formatBytes(int64(math.Round(value)))
+	case "Error Rate", "Bot Traffic":
+		return fmt.Sprintf("%.2f%%", value)
+	case "Security Score", "Suspicious IPs":
+		return trends.FormatSecurityValue(metric, value)
+	default:
+		if strings.HasSuffix(metric, " Rate") {
+			return trends.FormatSecurityValue(metric, value)
+		}
+		return formatNumber(int(math.Round(value)))
+	}
+}
+
+// formatEndpointTrendValue formats an endpoint trend value in the unit of the metric
+func formatEndpointTrendValue(metric string, value float64) string {
+	switch metric {
+	case "P95 Request Time":
+		return fmt.Sprintf("%.1f ms", value)
+	case "P95 Response Size":
+		return formatBytes(int64(math.Round(value)))
+	default:
+		return fmt.Sprintf("%.2f%%", value)
+	}
+}
+
+// healthClass returns the alert class of an overall health status
+func healthClass(health string) string {
+	switch health {
+	case "healthy":
+		return "success"
+	case "warning":
+		return "warning"
+	default:
+		return "danger"
+	}
+}
+
+// directionClass returns the badge class of a trend direction
+func directionClass(direction trends.TrendDirection) string {
+	switch direction {
+	case trends.TrendImproving:
+		return "bg-success"
+	case trends.TrendDegrading:
+		return "bg-warning"
+	case trends.TrendCritical:
+		return "bg-danger"
+	default:
+		return "bg-secondary"
+	}
+}
+
+// directionColor returns the chart color of a trend direction
+func directionColor(direction trends.TrendDirection) string {
+	switch direction {
+	case trends.TrendImproving:
+		return "#28a745"
+	case trends.TrendDegrading:
+		return "#ffc107"
+	case trends.TrendCritical:
+		return "#dc3545"
+	default:
+		return "#6c757d"
+	}
+}
+
+// riskScoreClass returns the text class of a trend risk score (higher is worse)
+func riskScoreClass(score int) string {
+	switch {
+	case score > 70:
+		return "text-danger"
+	case score > 30:
+		return "text-warning"
+	default:
+		return "text-success"
+	}
+}
+
+// capacityStatusClass returns the badge class of a capacity projection status
+func capacityStatusClass(status string) string {
+	switch status {
+	case "exceeded", "critical":
+		return "bg-danger"
+	case "warning":
+		return "bg-warning"
+	case "ok", "not growing":
+		return "bg-success"
+	default:
+		return "bg-secondary"
+	}
+}
+
+// alertSeverityClass returns the badge class of a degradation alert severity
+func alertSeverityClass(severity string) string {
+	if severity == "warning" {
+		return "bg-warning"
+	}
+	return "bg-danger"
+}
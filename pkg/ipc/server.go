@@ -33,6 +33,9 @@ type Server struct {
 // NewServer creates a new IPC server
 func NewServer() (*Server, error) {
 	analyzer := analyser.New()
+	if signatures, err := analyser.LoadBotSignatures(filepath.Join("config", analyser.DefaultBotConfigFile)); err == nil {
+		analyzer.SetBotSignatures(signatures)
+	}
 	configMgr := config.NewConfigManager("config")
 	htmlGen, err := html.NewGenerator()
 	if err != nil {
@@ -16,12 +16,15 @@ import (
 	"smart-log-analyser/pkg/charts"
 	"smart-log-analyser/pkg/config"
 	"smart-log-analyser/pkg/html"
+	"smart-log-analyser/pkg/mitre"
 	"smart-log-analyser/pkg/parser"
 	"smart-log-analyser/pkg/performance"
 	"smart-log-analyser/pkg/query"
 	"smart-log-analyser/pkg/remote"
+	"smart-log-analyser/pkg/reports"
 	"smart-log-analyser/pkg/security"
 	"smart-log-analyser/pkg/trends"
+	"smart-log-analyser/pkg/xlsx"
 )
 
 // selectLogFiles allows user to select log files
@@ -219,6 +222,11 @@ func (m *Menu) performAnalysis(files []string, since, until *time.Time, showDeta
 	
 	// Perform analysis
 	logAnalyser := analyser.New()
+	if signatures, err := analyser.LoadBotSignatures(filepath.Join("config", analyser.DefaultBotConfigFile)); err != nil {
+		fmt.Printf("⚠️  Using built-in bot signatures: %v\n", err)
+	} else {
+		logAnalyser.SetBotSignatures(signatures)
+	}
 	results := logAnalyser.Analyse(allEntries, since, until)
 	
 	// Display results
@@ -226,6 +234,12 @@ func (m *Menu) performAnalysis(files []string, since, until *time.Time, showDeta
 	fmt.Printf("\n├─ Total Requests: %s", formatNumber(results.TotalRequests))
 	fmt.Printf("\n├─ Unique IPs: %s", formatNumber(results.UniqueIPs))
 	fmt.Printf("\n├─ Data Transferred: %s", formatBytes(results.TotalBytes))
+	if sparklines, ok := charts.NewTimelineSparklines(results.Timeline, 40); ok {
+		fmt.Printf("\n├─ Requests:   %s", sparklines.Traffic)
+		fmt.Printf("\n├─ Error Rate: %s", sparklines.ErrorRate)
+		fmt.Printf("\n├─ Bandwidth:  %s", sparklines.Bandwidth)
+		fmt.Printf("\n├─ Activity:   %s", sparklines.Describe())
+	}
 	fmt.Printf("\n└─ Time Range: %s to %s\n", 
 		results.TimeRange.Start.Format("2006-01-02 15:04"),
 		results.TimeRange.End.Format("2006-01-02 15:04"))
@@ -274,9 +288,10 @@ func (m *Menu) handleExport(results *analyser.Results) error {
 	fmt.Println("1. HTML Report")
 	fmt.Println("2. JSON Export")
 	fmt.Println("3. CSV Export")
-	fmt.Println("4. All formats")
+	fmt.Println("4. Excel (XLSX) Export")
+	fmt.Println("5. All formats")
 	
-	choice, err := m.getIntInput("Select format (1-4): ", 1, 4)
+	choice, err := m.getIntInput("Select format (1-5): ", 1, 5)
 	if err != nil {
 		return err
 	}
@@ -291,9 +306,12 @@ func (m *Menu) handleExport(results *analyser.Results) error {
 	case 3:
 		return m.exportCSV(results, timestamp)
 	case 4:
+		return m.exportXLSX(results, timestamp)
+	case 5:
 		m.exportHTML(results, timestamp)
 		m.exportJSON(results, timestamp)
-		return m.exportCSV(results, timestamp)
+		m.exportCSV(results, timestamp)
+		return m.exportXLSX(results, timestamp)
 	}
 	
 	return nil
@@ -376,6 +394,7 @@ func (m *Menu) exportJSON(results *analyser.Results, timestamp string) error {
 
 // exportCSV exports CSV data
 func (m *Menu) exportCSV(results *analyser.Results, timestamp string) error {
+	results = results.LimitTop(analyser.DefaultExportTopN)
 	filename := fmt.Sprintf("output/summary_%s.csv", timestamp)
 	
 	// Ensure output directory exists
@@ -402,6 +421,13 @@ func (m *Menu) exportCSV(results *analyser.Results, timestamp string) error {
 	writer.Write([]string{"Overview", "Human Requests", strconv.Itoa(results.HumanRequests), fmt.Sprintf("%.1f", float64(results.HumanRequests)/float64(results.TotalRequests)*100)})
 	writer.Write([]string{"Overview", "Bot Requests", strconv.Itoa(results.BotRequests), fmt.Sprintf("%.1f", float64(results.BotRequests)/float64(results.TotalRequests)*100)})
 	
+	// Write activity sparklines
+	if sparklines, ok := charts.NewTimelineSparklines(results.Timeline, 60); ok {
+		writer.Write([]string{"Activity", "Requests (" + sparklines.Describe() + ")", sparklines.Traffic, ""})
+		writer.Write([]string{"Activity", "Error Rate (" + sparklines.Describe() + ")", sparklines.ErrorRate, ""})
+		writer.Write([]string{"Activity", "Bandwidth (" + sparklines.Describe() + ")", sparklines.Bandwidth, ""})
+	}
+	
 	// Write status codes
 	for status, count := range results.StatusCodes {
 		percentage := float64(count) / float64(results.TotalRequests) * 100
@@ -415,28 +441,24 @@ func (m *Menu) exportCSV(results *analyser.Results, timestamp string) error {
 	}
 	
 	// Write top IPs
-	for i, ip := range results.TopIPs {
-		if i >= 20 { break } // Limit to top 20 for CSV
+	for _, ip := range results.TopIPs {
 		percentage := float64(ip.Count) / float64(results.TotalRequests) * 100
 		writer.Write([]string{"Top IPs", ip.IP, strconv.Itoa(ip.Count), fmt.Sprintf("%.1f", percentage)})
 	}
 	
 	// Write top URLs
-	for i, url := range results.TopURLs {
-		if i >= 20 { break } // Limit to top 20 for CSV
+	for _, url := range results.TopURLs {
 		percentage := float64(url.Count) / float64(results.TotalRequests) * 100
 		writer.Write([]string{"Top URLs", url.URL, strconv.Itoa(url.Count), fmt.Sprintf("%.1f", percentage)})
 	}
 	
 	// Write error URLs with detailed status codes
-	for i, url := range results.ErrorURLs {
-		if i >= 20 { break } // Limit to top 20 for CSV
+	for _, url := range results.ErrorURLs {
 		writer.Write([]string{"Error URLs", url.URL, strconv.Itoa(url.Count), ""})
 	}
 	
 	// Write large requests
-	for i, url := range results.LargeRequests {
-		if i >= 20 { break } // Limit to top 20 for CSV
+	for _, url := range results.LargeRequests {
 		writer.Write([]string{"Large Requests", url.URL, strconv.Itoa(url.Count), ""}) // Count field contains size
 	}
 	
@@ -444,6 +466,23 @@ func (m *Menu) exportCSV(results *analyser.Results, timestamp string) error {
 	return nil
 }
 
+// exportXLSX exports an Excel workbook with one worksheet per section
+func (m *Menu) exportXLSX(results *analyser.Results, timestamp string) error {
+	filename := fmt.Sprintf("output/summary_%s.xlsx", timestamp)
+	
+	// Ensure output directory exists
+	if err := os.MkdirAll("output", 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	
+	if err := xlsx.ExportResults(results.LimitTop(analyser.DefaultExportTopN), nil, filename); err != nil {
+		return fmt.Errorf("failed to write Excel workbook: %w", err)
+	}
+	
+	fmt.Printf("✅ Excel workbook exported to: %s\n", filename)
+	return nil
+}
+
 // Remote analysis handlers (simplified implementations)
 
 func (m *Menu) downloadLogs(analyse bool) error {
@@ -869,9 +908,9 @@ func (m *Menu) addServer(config *remote.Config, configFile string) error {
 		return nil
 	}
 	
-	server.Password = m.getStringInput("Password: ")
-	if server.Password == "" {
-		fmt.Println("❌ Password is required")
+	server.Password = m.getStringInput("Password (leave empty to use ssh-agent): ")
+	if server.Password == "" && !remote.AgentAvailable() {
+		fmt.Println("❌ A password is required when no ssh-agent is running (SSH_AUTH_SOCK is not set)")
 		return nil
 	}
 	
@@ -898,6 +937,7 @@ func (m *Menu) addServer(config *remote.Config, configFile string) error {
 	fmt.Printf("\n📋 New server configuration:\n")
 	fmt.Printf("   Host: %s:%d\n", server.Host, server.Port)
 	fmt.Printf("   User: %s\n", server.Username)
+	fmt.Printf("   Auth: %s\n", server.AuthDescription())
 	fmt.Printf("   Log Path: %s\n", server.LogPath)
 	
 	if !m.confirmYesNo("\nAdd this server") {
@@ -997,16 +1037,7 @@ func (m *Menu) testServerConnections(config *remote.Config) error {
 }
 
 func (m *Menu) saveConfig(config *remote.Config, configFile string) error {
-	data, err := json.MarshalIndent(config, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
-	}
-	
-	if err := os.WriteFile(configFile, data, 0600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
-	}
-	
-	return nil
+	return remote.SaveConfig(configFile, config)
 }
 
 func parseIntOrDefault(s string, defaultValue int) (int, error) {
@@ -1034,11 +1065,9 @@ func (m *Menu) showASCIICharts(results *analyser.Results) error {
 		return err
 	}
 	
-	// Get terminal width preference
-	width := 80
-	if m.confirmYesNo("\nUse wide charts (100 columns)") {
-		width = 100
-	}
+	// Fit the charts to the terminal
+	width := charts.GetTerminalWidth()
+	fmt.Printf("\nChart width: %d columns (terminal width)\n", width)
 	
 	// Check color preference
 	useColors := true
@@ -1052,6 +1081,23 @@ func (m *Menu) showASCIICharts(results *analyser.Results) error {
 	generator := charts.NewChartGenerator()
 	generator.SetWidth(width)
 	generator.SetColors(useColors)
+	if useColors {
+		themeNames := charts.ThemeNames()
+		fmt.Println("\nChart Themes:")
+		for i, name := range themeNames {
+			fmt.Printf("%d. %s\n", i+1, name)
+		}
+		themeChoice, err := m.getIntInput(fmt.Sprintf("Select theme (1-%d): ", len(themeNames)), 1, len(themeNames))
+		if err != nil {
+			return err
+		}
+		theme, _ := charts.LookupTheme(themeNames[themeChoice-1])
+		generator.SetTheme(theme)
+	}
+	generator.SetBraille(m.confirmYesNo("Use high-resolution Braille line charts"))
+	if m.confirmYesNo("Draw histograms on a log scale") {
+		generator.SetHistogramScale(charts.LogScale)
+	}
 	
 	fmt.Println("\n" + strings.Repeat("═", width))
 	fmt.Println()
@@ -1090,14 +1136,18 @@ func (m *Menu) showCustomCharts(generator *charts.ChartGenerator, results *analy
 	fmt.Println("4. Bot vs Human Traffic")
 	fmt.Println("5. Geographic Distribution")
 	fmt.Println("6. Response Size Distribution")
-	fmt.Println("7. Show all charts")
+	fmt.Println("7. Traffic Heatmap (day × hour)")
+	fmt.Println("8. Requests over Time")
+	fmt.Println("9. Session Length Distribution")
+	fmt.Println("10. Request Time Distribution")
+	fmt.Println("11. Show all charts")
 	fmt.Println()
 	
 	// Allow multiple selections
 	selectedCharts := make(map[int]bool)
 	
 	for {
-		choice, err := m.getIntInput("Select chart (1-7, 0 to finish): ", 0, 7)
+		choice, err := m.getIntInput("Select chart (1-11, 0 to finish): ", 0, 11)
 		if err != nil {
 			return err
 		}
@@ -1137,10 +1187,18 @@ func (m *Menu) showCustomCharts(generator *charts.ChartGenerator, results *analy
 		case 4:
 			fmt.Print(generator.GenerateBotTrafficChart(results))
 		case 5:
-			fmt.Print(generator.GenerateGeographicChart(results))
+			fmt.Print(generator.GenerateGeographicChart(results, 10))
 		case 6:
 			fmt.Print(generator.GenerateResponseSizeChart(results))
 		case 7:
+			fmt.Print(generator.GenerateTrafficHeatmap(results))
+		case 8:
+			fmt.Print(generator.GenerateTimeSeriesChart(results, 0))
+		case 9:
+			fmt.Print(generator.GenerateSessionLengthChart(results))
+		case 10:
+			fmt.Print(generator.GenerateLatencyHistogram(results))
+		case 11:
 			fmt.Print(generator.GenerateFullReport(results))
 			// Don't show other individual charts if showing all
 			return nil
@@ -1254,8 +1312,14 @@ func (m *Menu) handleTrendAnalysis(allEntries []*parser.LogEntry) error {
 	
 	fmt.Printf("\n🔍 Analyzing %d log entries for trends...\n", len(allEntries))
 	
-	// Perform trend analysis
+	// Perform trend analysis with the configured alert thresholds
 	trendAnalyser := trends.New()
+	configManager := config.NewConfigManager("config")
+	if err := configManager.Load(); err == nil {
+		if thresholds, err := configManager.GetConfig().Analysis.TrendAlerts.Thresholds(""); err == nil {
+			trendAnalyser = trends.NewWithConfig(trends.ConfigurationFrom(thresholds))
+		}
+	}
 	trendResults, err := trendAnalyser.DetectDegradation(allEntries)
 	if err != nil {
 		fmt.Printf("❌ Trend analysis failed: %v\n", err)
@@ -1280,12 +1344,12 @@ func (m *Menu) handleTrendAnalysis(allEntries []*parser.LogEntry) error {
 	
 	switch choice {
 	case 1:
-		fmt.Print(trends.RenderTrendCharts(trendResults, 80, true))
+		fmt.Print(trends.RenderTrendCharts(trendResults, charts.GetTerminalWidth(), true))
 	case 2:
-		fmt.Print(trends.RenderQuickTrendSummary(trendResults, 80, true))
+		fmt.Print(trends.RenderQuickTrendSummary(trendResults, charts.GetTerminalWidth(), true))
 	case 3:
-		fmt.Print(trends.RenderQuickTrendSummary(trendResults, 80, true))
-		fmt.Print(trends.RenderTrendCharts(trendResults, 80, true))
+		fmt.Print(trends.RenderQuickTrendSummary(trendResults, charts.GetTerminalWidth(), true))
+		fmt.Print(trends.RenderTrendCharts(trendResults, charts.GetTerminalWidth(), true))
 	case 4:
 		// Continue
 	}
@@ -1337,6 +1401,36 @@ func (m *Menu) displayTrendResults(analysis *trends.TrendAnalysis) {
 		fmt.Printf("\n\n✅ No degradation alerts detected")
 	}
 
+	// Endpoint trends
+	if len(analysis.EndpointTrends) > 0 {
+		fmt.Printf("\n\n🎯 Endpoint Trends (%d):", len(analysis.EndpointTrends))
+		for i, trend := range analysis.EndpointTrends {
+			if i >= 3 { // Show max 3 endpoint trends in menu
+				fmt.Printf("\n   ... and %d more endpoint trends", len(analysis.EndpointTrends)-3)
+				break
+			}
+			fmt.Printf("\n   %s %s", m.getTrendEmoji(trend.Direction), trend.Description)
+		}
+	}
+
+	// Security trends
+	if len(analysis.PeriodComparisons) > 0 && len(analysis.PeriodComparisons[0].SecurityChanges) > 0 {
+		comparison := analysis.PeriodComparisons[0]
+		fmt.Printf("\n\n🛡️  Security Trends: posture %s", comparison.SecurityTrend.String())
+		shown := 0
+		for _, change := range comparison.SecurityChanges {
+			if change.Direction == trends.TrendStable {
+				continue
+			}
+			if shown >= 3 { // Show max 3 security changes in menu
+				fmt.Printf("\n   ... and more security changes")
+				break
+			}
+			fmt.Printf("\n   %s %s", m.getTrendEmoji(change.Direction), change.Description)
+			shown++
+		}
+	}
+
 	// Recommendations
 	if len(analysis.Recommendations) > 0 {
 		fmt.Printf("\n\n💡 Top Recommendations:")
@@ -1673,11 +1767,18 @@ func (m *Menu) runPresetAnalysis(preset config.AnalysisPreset, logFiles []string
 	
 	// Apply preset query if available
 	if preset.Query != "" {
+		statement, err := m.bindQueryParameters(preset.Query, preset.ParameterValues(nil))
+		if err != nil {
+			fmt.Printf("❌ Query parameter error: %v\n", err)
+			m.pauseForEffect()
+			return nil
+		}
+		
 		fmt.Printf("🔍 Executing preset query...\n")
-		fmt.Printf("Query: %s\n\n", preset.Query)
+		fmt.Printf("Query: %s\n\n", statement)
 		
 		// Use query system to execute the preset query
-		result, err := query.ExecuteQuery(preset.Query, allLogs)
+		result, err := query.ExecuteQuery(statement, allLogs)
 		if err != nil {
 			fmt.Printf("❌ Query error: %v\n", err)
 			m.pauseForEffect()
@@ -1838,35 +1939,325 @@ func (m *Menu) importPresets() error {
 	return nil
 }
 
-// handleReportTemplates manages report templates
+// handleSavedQueries lists, runs, saves and deletes saved queries
+func (m *Menu) handleSavedQueries() error {
+	for {
+		configManager := config.NewConfigManager("config")
+		if err := configManager.Load(); err != nil {
+			return err
+		}
+		queries := configManager.ListSavedQueries()
+		
+		m.clearScreen()
+		fmt.Println("🔖 Saved Queries")
+		fmt.Println("════════════════")
+		fmt.Println()
+		
+		if len(queries) == 0 {
+			fmt.Println("No saved queries yet.")
+		}
+		for i, saved := range queries {
+			fmt.Printf("%d. %s\n", i+1, saved.Name)
+			if saved.Description != "" {
+				fmt.Printf("   📝 %s\n", saved.Description)
+			}
+			fmt.Printf("   🔍 %s\n", saved.Query)
+		}
+		fmt.Println()
+		fmt.Println("Available options:")
+		fmt.Println("1. 🚀 Run Saved Query")
+		fmt.Println("2. ➕ Save New Query")
+		fmt.Println("3. 🗑️  Delete Saved Query")
+		fmt.Println("4. 🚪 Back to Configuration Menu")
+		fmt.Println()
+		
+		choice, err := m.getIntInput("Enter choice (1-4): ", 1, 4)
+		if err != nil {
+			return err
+		}
+		
+		switch choice {
+		case 1:
+			if saved := m.selectSavedQuery(queries); saved != nil {
+				if err := m.runSavedQuery(*saved); err != nil {
+					m.showError("Saved query error", err)
+				}
+			}
+		case 2:
+			if err := m.saveNewQuery(configManager); err != nil {
+				m.showError("Save query error", err)
+			}
+		case 3:
+			if saved := m.selectSavedQuery(queries); saved != nil {
+				if m.confirmYesNo(fmt.Sprintf("Delete saved query '%s'?", saved.Name)) {
+					if err := configManager.DeleteSavedQuery(saved.Name); err != nil {
+						m.showError("Delete query error", err)
+					}
+				}
+			}
+		case 4:
+			return nil
+		}
+	}
+}
+
+// selectSavedQuery asks the user to pick a saved query, returning nil if there are none
+func (m *Menu) selectSavedQuery(queries []config.SavedQuery) *config.SavedQuery {
+	if len(queries) == 0 {
+		fmt.Println("❌ No saved queries available.")
+		m.pauseForEffect()
+		return nil
+	}
+	
+	choice, err := m.getIntInput(fmt.Sprintf("Select query (1-%d): ", len(queries)), 1, len(queries))
+	if err != nil {
+		return nil
+	}
+	return &queries[choice-1]
+}
+
+// saveNewQuery prompts for a query and the defaults of its placeholders
+func (m *Menu) saveNewQuery(configManager *config.ConfigManager) error {
+	fmt.Println("\n➕ Save New Query")
+	fmt.Println("─────────────────")
+	fmt.Println("Use :name placeholders for values that change between runs, e.g. ip = :ip")
+	
+	name := m.getStringInput("Name: ")
+	if name == "" {
+		return fmt.Errorf("a name is required")
+	}
+	statement := m.getStringInput("Query: ")
+	if statement == "" {
+		return fmt.Errorf("a query is required")
+	}
+	description := m.getStringInput("Description (optional): ")
+	
+	saved := config.SavedQuery{
+		Name:        name,
+		Description: description,
+		Query:       statement,
+	}
+	defaults := make(map[string]string)
+	for _, param := range query.QueryParameters(statement) {
+		value := m.getStringInput(fmt.Sprintf("Default for :%s (blank for none): ", param))
+		if value != "" {
+			defaults[param] = value
+		}
+		saved.Parameters = append(saved.Parameters, config.QueryParameter{Name: param, Default: value})
+	}
+	
+	if err := query.ValidateParameterizedQuery(statement, defaults); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	if err := configManager.AddSavedQuery(saved); err != nil {
+		return err
+	}
+	
+	fmt.Printf("✅ Saved query '%s'\n", name)
+	m.pauseForEffect()
+	return nil
+}
+
+// bindQueryParameters prompts for the value of each :name placeholder in a
+// query, offering defaults, and binds the answers into the query
+func (m *Menu) bindQueryParameters(queryStr string, defaults map[string]string) (string, error) {
+	values := make(map[string]string, len(defaults))
+	for name, value := range defaults {
+		values[name] = value
+	}
+	
+	for _, name := range query.QueryParameters(queryStr) {
+		prompt := fmt.Sprintf("Value for :%s: ", name)
+		if defaults[name] != "" {
+			prompt = fmt.Sprintf("Value for :%s [%s]: ", name, defaults[name])
+		}
+		if value := m.getStringInput(prompt); value != "" {
+			values[name] = value
+		}
+	}
+	
+	return query.BindParameters(queryStr, values)
+}
+
+// runSavedQuery prompts for parameter values and log files, then runs a saved query
+func (m *Menu) runSavedQuery(saved config.SavedQuery) error {
+	statement, err := m.bindQueryParameters(saved.Query, saved.ParameterValues(nil))
+	if err != nil {
+		return err
+	}
+	
+	logFiles, err := m.selectLogFiles()
+	if err != nil {
+		return err
+	}
+	if len(logFiles) == 0 {
+		fmt.Println("❌ No log files selected.")
+		m.pauseForEffect()
+		return nil
+	}
+	
+	p := parser.New()
+	var allLogs []*parser.LogEntry
+	for _, logFile := range logFiles {
+		logs, err := p.ParseFile(logFile)
+		if err != nil {
+			fmt.Printf("    ❌ Failed to parse %s: %v\n", logFile, err)
+			continue
+		}
+		allLogs = append(allLogs, logs...)
+	}
+	if len(allLogs) == 0 {
+		return fmt.Errorf("no log entries found in selected files")
+	}
+	
+	fmt.Printf("\n🔍 Executing: %s\n\n", statement)
+	result, err := query.ExecuteQuery(statement, allLogs)
+	if err != nil {
+		return err
+	}
+	
+	formattedResult, err := query.FormatResult(result, "table")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📊 Query Results:\n%s", formattedResult)
+	
+	m.pause()
+	return nil
+}
+
+// handleReportTemplates lists the report templates and generates reports from them
 func (m *Menu) handleReportTemplates() error {
 	configManager := config.NewConfigManager("config")
 	if err := configManager.Load(); err != nil {
 		return err
 	}
 	
+	// Offer the built-in templates until they are installed
 	templates := configManager.GetConfig().Templates
+	if len(templates) == 0 {
+		templates = config.GetBuiltinTemplates()
+	}
 	
 	m.clearScreen()
 	fmt.Printf("📄 Report Templates Management (%d templates)\n", len(templates))
 	fmt.Println("═══════════════════════════════════════════════")
 	fmt.Println()
 	
-	if len(templates) == 0 {
-		fmt.Println("No templates available. Please initialize configuration first.")
-		m.pauseForEffect()
-		return nil
-	}
-	
 	fmt.Println("Available templates:")
 	for i, template := range templates {
 		fmt.Printf("%d. %s [%s]\n", i+1, template.Name, template.Category)
 		fmt.Printf("   📝 %s\n", template.Description)
-		fmt.Printf("   📊 %d sections\n", len(template.Sections))
+		fmt.Printf("   📊 %d sections, formats: %s\n", len(template.EnabledSections()), strings.Join(template.OutputFormats(), ", "))
 	}
+	fmt.Println()
+	fmt.Println("1. 📑 Generate Report from a Template")
+	fmt.Println("2. 🚪 Back to Configuration Menu")
+	fmt.Println()
 	
-	fmt.Println("\nPress Enter to continue...")
-	m.scanner.Scan()
+	choice, err := m.getIntInput("Enter choice (1-2): ", 1, 2)
+	if err != nil {
+		return err
+	}
+	if choice == 2 {
+		return nil
+	}
+	
+	index, err := m.getIntInput(fmt.Sprintf("Select template (1-%d): ", len(templates)), 1, len(templates))
+	if err != nil {
+		return err
+	}
+	return m.generateTemplateReport(templates[index-1])
+}
+
+// generateTemplateReport runs a report template over selected log files and
+// writes the report in the chosen formats
+func (m *Menu) generateTemplateReport(template config.ReportTemplate) error {
+	logFiles, err := m.selectLogFiles()
+	if err != nil {
+		return err
+	}
+	if len(logFiles) == 0 {
+		fmt.Println("❌ No log files selected.")
+		m.pauseForEffect()
+		return nil
+	}
+	
+	fmt.Println("\n📤 Report Formats")
+	fmt.Printf("1. Template default (%s)\n", strings.Join(template.OutputFormats(), ", "))
+	fmt.Println("2. HTML Report")
+	fmt.Println("3. JSON Export")
+	fmt.Println("4. CSV Export")
+	fmt.Println("5. All formats")
+	
+	choice, err := m.getIntInput("Select format (1-5): ", 1, 5)
+	if err != nil {
+		return err
+	}
+	formats := template.OutputFormats()
+	switch choice {
+	case 2:
+		formats = []string{"html"}
+	case 3:
+		formats = []string{"json"}
+	case 4:
+		formats = []string{"csv"}
+	case 5:
+		formats = config.TemplateFormats
+	}
+	
+	p := parser.New()
+	var allLogs []*parser.LogEntry
+	for _, logFile := range logFiles {
+		logs, err := p.ParseFile(logFile)
+		if err != nil {
+			fmt.Printf("    ❌ Failed to parse %s: %v\n", logFile, err)
+			continue
+		}
+		allLogs = append(allLogs, logs...)
+	}
+	if len(allLogs) == 0 {
+		return fmt.Errorf("no log entries found in selected files")
+	}
+	
+	fmt.Printf("\n📑 Generating '%s' report from %d entries...\n", template.Name, len(allLogs))
+	report := reports.Build(template, query.NewQueryEngine(allLogs), len(allLogs))
+	for _, section := range report.Sections {
+		if section.Error != "" {
+			fmt.Printf("    ⚠️  Section '%s' failed: %s\n", section.Name, section.Error)
+		}
+	}
+	
+	timestamp := time.Now().Format("20060102_150405")
+	var htmlFile string
+	for _, format := range formats {
+		filename := fmt.Sprintf("output/%s_%s.%s", template.Name, timestamp, format)
+		var err error
+		switch format {
+		case "html":
+			var generator *html.Generator
+			if generator, err = html.NewGenerator(); err == nil {
+				err = generator.GenerateTemplateReport(report, filename)
+			}
+		case "json":
+			err = reports.WriteJSON(report, filename)
+		case "csv":
+			err = reports.WriteCSV(report, filename)
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to export %s report: %v\n", strings.ToUpper(format), err)
+			continue
+		}
+		fmt.Printf("✅ %s report saved to: %s\n", strings.ToUpper(format), filename)
+		if format == "html" {
+			htmlFile = filename
+		}
+	}
+	
+	if htmlFile != "" && m.confirmYesNo("Open report in browser") {
+		m.openInBrowser(htmlFile)
+	}
+	m.pauseForEffect()
 	return nil
 }
 
@@ -2849,6 +3240,17 @@ func (m *Menu) generateSecurityReport() error {
 
 // Helper functions for security analysis
 
+// securityConfig returns the default security configuration with the
+// scoring weights and risk levels of the configuration
+func securityConfig() security.SecurityConfig {
+	securityCfg := security.DefaultSecurityConfig()
+	configManager := config.NewConfigManager("config")
+	if err := configManager.Load(); err == nil {
+		securityCfg.Scoring = security.ScoringConfigFrom(configManager.GetConfig().Analysis.SecurityScoring)
+	}
+	return securityCfg
+}
+
 // performSecurityAnalysisAndShow performs full security analysis and shows results
 func (m *Menu) performSecurityAnalysisAndShow(files []string, title string) error {
 	analysis, err := m.performFullSecurityAnalysis(files)
@@ -2856,7 +3258,7 @@ func (m *Menu) performSecurityAnalysisAndShow(files []string, title string) erro
 		return err
 	}
 	
-	visualizer := security.NewSecurityVisualizer(security.DefaultSecurityConfig())
+	visualizer := security.NewSecurityVisualizer(securityConfig())
 	fmt.Println("\n" + visualizer.GenerateSecurityDashboard(analysis))
 	
 	return m.showSecurityResults(analysis, title)
@@ -2885,43 +3287,7 @@ func (m *Menu) performFullSecurityAnalysis(files []string) (*security.EnhancedSe
 	
 	// Perform comprehensive security analysis
 	fmt.Println("\n🔍 Performing comprehensive security analysis...")
-	config := security.DefaultSecurityConfig()
-	
-	threatDetector := security.NewThreatDetector(config)
-	anomalyDetector := security.NewAnomalyDetector(config)
-	scorer := security.NewSecurityScorer(config)
-	
-	// Detect all threats
-	webThreats, _ := threatDetector.DetectWebAttacks(allEntries)
-	infraThreats, _ := threatDetector.DetectInfrastructureAttacks(allEntries)
-	allThreats := append(webThreats, infraThreats...)
-	
-	// Detect anomalies
-	anomalies, _ := anomalyDetector.DetectAnomalies(allEntries)
-	
-	// Create IP profiles
-	ipProfiles, _ := anomalyDetector.ProfileIPs(allEntries)
-	
-	// Generate incidents
-	incidents, _ := scorer.GenerateIncidents(allThreats, anomalies)
-	
-	// Create analysis
-	analysis := &security.EnhancedSecurityAnalysis{
-		Threats:              allThreats,
-		Anomalies:            anomalies,
-		IPProfiles:           ipProfiles,
-		Incidents:            incidents,
-		AnalysisTimestamp:    time.Now(),
-		TotalEntriesAnalyzed: int64(len(allEntries)),
-		LogTimeRange: security.TimeRange{
-			Start: allEntries[0].Timestamp,
-			End:   allEntries[len(allEntries)-1].Timestamp,
-		},
-	}
-	
-	analysis.Summary = scorer.GenerateSecuritySummary(analysis)
-	
-	return analysis, nil
+	return security.Analyse(allEntries, securityConfig())
 }
 
 // showSecurityResults shows security analysis results with options
@@ -2943,11 +3309,12 @@ func (m *Menu) showSecurityResults(analysis *security.EnhancedSecurityAnalysis,
 			return err
 		}
 		
-		visualizer := security.NewSecurityVisualizer(security.DefaultSecurityConfig())
+		visualizer := security.NewSecurityVisualizer(securityConfig())
 		
 		switch choice {
 		case 1:
 			fmt.Println(visualizer.GenerateDetailedThreatReport(analysis.Threats))
+			fmt.Println(visualizer.GenerateOWASPReport(analysis.Threats))
 			m.pause()
 		case 2:
 			fmt.Println(visualizer.GenerateAnomalyReport(analysis.Anomalies))
@@ -2993,7 +3360,7 @@ func (m *Menu) performThreatFocusedAnalysis(files []string) error {
 	
 	// Perform detailed threat detection
 	fmt.Println("\n🔍 Performing advanced threat detection...")
-	config := security.DefaultSecurityConfig()
+	config := securityConfig()
 	config.ThreatDetectionSensitivity = 9.0 // High sensitivity for detailed analysis
 	
 	threatDetector := security.NewThreatDetector(config)
@@ -3039,7 +3406,7 @@ func (m *Menu) performBehavioralAnalysis(files []string) error {
 	
 	// Perform behavioral analysis
 	fmt.Println("\n🧠 Analyzing behavioral patterns...")
-	config := security.DefaultSecurityConfig()
+	config := securityConfig()
 	config.BehavioralAnalysisEnabled = true
 	config.AnomalyThreshold = 2.0 // Lower threshold for more sensitive detection
 	
@@ -3065,7 +3432,7 @@ func (m *Menu) performRiskAssessment(files []string) error {
 		return err
 	}
 	
-	visualizer := security.NewSecurityVisualizer(security.DefaultSecurityConfig())
+	visualizer := security.NewSecurityVisualizer(securityConfig())
 	
 	// Display risk-focused results
 	fmt.Println("\n📊 Security Risk Assessment Results")
@@ -3150,7 +3517,7 @@ func (m *Menu) generateSecurityReportInFormat(files []string, format int) error
 func (m *Menu) generateTextSecurityReport(analysis *security.EnhancedSecurityAnalysis) error {
 	filename := fmt.Sprintf("security-report-%s.txt", time.Now().Format("20060102-150405"))
 	
-	visualizer := security.NewSecurityVisualizer(security.DefaultSecurityConfig())
+	visualizer := security.NewSecurityVisualizer(securityConfig())
 	
 	var content strings.Builder
 	content.WriteString("SECURITY ANALYSIS REPORT\n")
@@ -3163,6 +3530,7 @@ func (m *Menu) generateTextSecurityReport(analysis *security.EnhancedSecurityAna
 	
 	content.WriteString(visualizer.GenerateSecurityDashboard(analysis))
 	content.WriteString("\n" + visualizer.GenerateDetailedThreatReport(analysis.Threats))
+	content.WriteString("\n" + visualizer.GenerateOWASPReport(analysis.Threats))
 	content.WriteString("\n" + visualizer.GenerateAnomalyReport(analysis.Anomalies))
 	content.WriteString("\n" + visualizer.GenerateSecurityRecommendationReport(analysis.Summary.RecommendedActions))
 	
@@ -3242,6 +3610,10 @@ func (m *Menu) generateHTMLSecurityReport(analysis *security.EnhancedSecurityAna
 				threatType = t.String()
 			case security.InfrastructureAttackType:
 				threatType = t.String()
+			case security.CustomAttackType:
+				threatType = t.String()
+			case security.ExfiltrationType:
+				threatType = t.String()
 			default:
 				threatType = "Unknown"
 			}
@@ -3252,6 +3624,7 @@ func (m *Menu) generateHTMLSecurityReport(analysis *security.EnhancedSecurityAna
                 <p><strong>Severity:</strong> ` + threat.Severity.String() + `</p>
                 <p><strong>Confidence:</strong> ` + fmt.Sprintf("%.0f%%", threat.Confidence*100) + `</p>
                 <p><strong>Target:</strong> ` + threat.URL + `</p>
+                <p><strong>MITRE ATT&amp;CK:</strong> ` + strings.Join(mitre.IDs(threat.MITRETechniques()), ", ") + `</p>
             </div>
 `)
 		}
@@ -3344,7 +3717,7 @@ func (m *Menu) exportSecurityCSV(analysis *security.EnhancedSecurityAnalysis) er
 	// Write header
 	header := []string{
 		"Timestamp", "IP", "Threat Type", "Severity", "Confidence", 
-		"URL", "Attack Vector", "Payload",
+		"URL", "Attack Vector", "Payload", "MITRE Techniques",
 	}
 	writer.Write(header)
 	
@@ -3356,6 +3729,10 @@ func (m *Menu) exportSecurityCSV(analysis *security.EnhancedSecurityAnalysis) er
 			threatType = t.String()
 		case security.InfrastructureAttackType:
 			threatType = t.String()
+		case security.CustomAttackType:
+			threatType = t.String()
+		case security.ExfiltrationType:
+			threatType = t.String()
 		default:
 			threatType = "Unknown"
 		}
@@ -3369,6 +3746,7 @@ func (m *Menu) exportSecurityCSV(analysis *security.EnhancedSecurityAnalysis) er
 			threat.URL,
 			threat.AttackVector,
 			threat.Payload,
+			strings.Join(mitre.IDs(threat.MITRETechniques()), ";"),
 		}
 		writer.Write(record)
 	}
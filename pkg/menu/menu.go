@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"smart-log-analyser/pkg/remote"
 )
 
 // Menu represents the interactive menu system
@@ -17,9 +19,11 @@ type Menu struct {
 
 // New creates a new menu system
 func New() *Menu {
-	return &Menu{
+	m := &Menu{
 		scanner: bufio.NewScanner(os.Stdin),
 	}
+	remote.DefaultHostKeyPolicy.Prompt = m.trustHostKey
+	return m
 }
 
 // Run starts the interactive menu system
@@ -63,7 +67,7 @@ func (m *Menu) Run() error {
 			m.showGoodbye()
 			return nil
 		default:
-			fmt.Println("❌ Invalid choice. Please try again.\n")
+			fmt.Print("❌ Invalid choice. Please try again.\n\n")
 		}
 	}
 }
@@ -261,16 +265,17 @@ func (m *Menu) handleConfiguration() error {
 	fmt.Println()
 	fmt.Println("Available options:")
 	fmt.Println("1. 🎯 Browse & Use Analysis Presets")
-	fmt.Println("2. 📄 Manage Report Templates") 
-	fmt.Println("3. 🌐 Setup Remote Server Connections")
-	fmt.Println("4. ⚙️  Configure Analysis Preferences")
-	fmt.Println("5. 📊 View Configuration Status")
-	fmt.Println("6. 💾 Backup & Restore Configuration")
-	fmt.Println("7. 🔄 Reset to Defaults")
-	fmt.Println("8. 🚪 Back to Main Menu")
+	fmt.Println("2. 🔖 Saved Queries")
+	fmt.Println("3. 📄 Manage Report Templates") 
+	fmt.Println("4. 🌐 Setup Remote Server Connections")
+	fmt.Println("5. ⚙️  Configure Analysis Preferences")
+	fmt.Println("6. 📊 View Configuration Status")
+	fmt.Println("7. 💾 Backup & Restore Configuration")
+	fmt.Println("8. 🔄 Reset to Defaults")
+	fmt.Println("9. 🚪 Back to Main Menu")
 	fmt.Println()
 	
-	choice, err := m.getIntInput("Enter choice (1-8): ", 1, 8)
+	choice, err := m.getIntInput("Enter choice (1-9): ", 1, 9)
 	if err != nil {
 		return err
 	}
@@ -279,18 +284,20 @@ func (m *Menu) handleConfiguration() error {
 	case 1:
 		return m.handleAnalysisPresets()
 	case 2:
-		return m.handleReportTemplates()
+		return m.handleSavedQueries()
 	case 3:
-		return m.setupRemoteServers()
+		return m.handleReportTemplates()
 	case 4:
-		return m.configureAnalysisPreferences()
+		return m.setupRemoteServers()
 	case 5:
-		return m.viewConfiguration()
+		return m.configureAnalysisPreferences()
 	case 6:
-		return m.handleBackupRestore()
+		return m.viewConfiguration()
 	case 7:
-		return m.resetConfiguration()
+		return m.handleBackupRestore()
 	case 8:
+		return m.resetConfiguration()
+	case 9:
 		return nil // Back to main menu
 	}
 	
@@ -350,6 +357,14 @@ func (m *Menu) getStringInput(prompt string) string {
 	return strings.TrimSpace(m.scanner.Text())
 }
 
+// trustHostKey asks whether to trust the host key of a server missing from
+// known_hosts
+func (m *Menu) trustHostKey(host, keyType, fingerprint string) bool {
+	fmt.Printf("\n⚠️  The authenticity of host %s can't be established.\n", host)
+	fmt.Printf("   %s key fingerprint is %s.\n", keyType, fingerprint)
+	return m.confirmYesNo("   Trust this host and add it to known hosts")
+}
+
 // confirmYesNo gets yes/no confirmation from user
 func (m *Menu) confirmYesNo(prompt string) bool {
 	for {
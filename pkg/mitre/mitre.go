@@ -0,0 +1,119 @@
+// Package mitre maps detected threats to MITRE ATT&CK techniques, so
+// security reports and exports can be consumed by SOC tooling
+package mitre
+
+import (
+	"sort"
+	"strings"
+)
+
+// Technique is a MITRE ATT&CK technique or sub-technique
+type Technique struct {
+	ID     string `json:"id"`     // e.g. "T1190" or "T1110.003"
+	Name   string `json:"name"`   // Technique name
+	Tactic string `json:"tactic"` // Tactic the technique serves in these logs
+}
+
+// URL returns the ATT&CK page of the technique
+func (t Technique) URL() string {
+	return "https://attack.mitre.org/techniques/" + strings.Replace(t.ID, ".", "/", 1) + "/"
+}
+
+// String returns the ID and name of the technique
+func (t Technique) String() string {
+	return t.ID + " " + t.Name
+}
+
+// techniques are the techniques threats are mapped to, by ID
+var techniques = map[string]Technique{
+	"T1046":     {"T1046", "Network Service Discovery", "Discovery"},
+	"T1059":     {"T1059", "Command and Scripting Interpreter", "Execution"},
+	"T1029":     {"T1029", "Scheduled Transfer", "Exfiltration"},
+	"T1068":     {"T1068", "Exploitation for Privilege Escalation", "Privilege Escalation"},
+	"T1083":     {"T1083", "File and Directory Discovery", "Discovery"},
+	"T1105":     {"T1105", "Ingress Tool Transfer", "Command and Control"},
+	"T1110":     {"T1110", "Brute Force", "Credential Access"},
+	"T1110.003": {"T1110.003", "Brute Force: Password Spraying", "Credential Access"},
+	"T1110.004": {"T1110.004", "Brute Force: Credential Stuffing", "Credential Access"},
+	"T1189":     {"T1189", "Drive-by Compromise", "Initial Access"},
+	"T1190":     {"T1190", "Exploit Public-Facing Application", "Initial Access"},
+	"T1213":     {"T1213", "Data from Information Repositories", "Collection"},
+	"T1496":     {"T1496", "Resource Hijacking", "Impact"},
+	"T1498":     {"T1498", "Network Denial of Service", "Impact"},
+	"T1499":     {"T1499", "Endpoint Denial of Service", "Impact"},
+	"T1505.003": {"T1505.003", "Server Software Component: Web Shell", "Persistence"},
+	"T1539":     {"T1539", "Steal Web Session Cookie", "Credential Access"},
+	"T1567":     {"T1567", "Exfiltration Over Web Service", "Exfiltration"},
+	"T1583.005": {"T1583.005", "Acquire Infrastructure: Botnet", "Resource Development"},
+	"T1595":     {"T1595", "Active Scanning", "Reconnaissance"},
+	"T1595.001": {"T1595.001", "Active Scanning: Scanning IP Blocks", "Reconnaissance"},
+	"T1595.002": {"T1595.002", "Active Scanning: Vulnerability Scanning", "Reconnaissance"},
+	"T1595.003": {"T1595.003", "Active Scanning: Wordlist Scanning", "Reconnaissance"},
+}
+
+// categoryTechniques maps the threat categories of the analyser to technique
+// IDs
+var categoryTechniques = map[string][]string{
+	"sql_injection":       {"T1190"},
+	"xss":                 {"T1190", "T1189"},
+	"directory_traversal": {"T1190", "T1083"},
+	"brute_force":         {"T1110"},
+	"scanner":             {"T1595.002"},
+	"reconnaissance":      {"T1595"},
+}
+
+// Lookup returns the technique with the given ID
+func Lookup(id string) (Technique, bool) {
+	technique, ok := techniques[id]
+	return technique, ok
+}
+
+// ByIDs returns the known techniques among the IDs, skipping unknown IDs
+func ByIDs(ids ...string) []Technique {
+	var result []Technique
+	for _, id := range ids {
+		if technique, ok := techniques[id]; ok {
+			result = append(result, technique)
+		}
+	}
+	return result
+}
+
+// ForCategory returns the techniques of an analyser threat category such as
+// "sql_injection" or "brute_force"; nil for unmapped categories
+func ForCategory(category string) []Technique {
+	return ByIDs(categoryTechniques[category]...)
+}
+
+// ForCategories returns the distinct techniques of the categories, ordered
+// by ID
+func ForCategories(categories []string) []Technique {
+	var result []Technique
+	seen := make(map[string]bool)
+	for _, category := range categories {
+		for _, technique := range ForCategory(category) {
+			if !seen[technique.ID] {
+				seen[technique.ID] = true
+				result = append(result, technique)
+			}
+		}
+	}
+	Sort(result)
+	return result
+}
+
+// IDs returns the IDs of the techniques
+func IDs(techniques []Technique) []string {
+	ids := make([]string, len(techniques))
+	for i, technique := range techniques {
+		ids[i] = technique.ID
+	}
+	return ids
+}
+
+// Sort orders techniques by ID
+func Sort(techniques []Technique) {
+	sort.Slice(techniques, func(i, j int) bool {
+		return techniques[i].ID < techniques[j].ID
+	})
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorLogEntry is one line of an nginx error.log, e.g.
+//
+//	2024/08/20 10:15:32 [error] 1234#0: *5678 open() "/var/www/favicon.ico" failed (2: No such file or directory), client: 192.168.1.10, server: example.com, request: "GET /favicon.ico HTTP/1.1", host: "example.com"
+//
+// The request context after the message is only present for errors raised
+// while handling a request.
+type ErrorLogEntry struct {
+	Timestamp  time.Time
+	Level      string
+	PID        int
+	TID        int
+	Connection int64 // The *N connection number, 0 when absent
+	Message    string
+	Client     string
+	Server     string
+	Request    string
+	Method     string
+	URL        string
+	Protocol   string
+	Upstream   string
+	Host       string
+	Referrer   string
+}
+
+var (
+	errorLineRegex    = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[(\w+)\] (\d+)#(\d+): (?:\*(\d+) )?(.*)$`)
+	errorContextRegex = regexp.MustCompile(`, (client|server|request|upstream|host|referrer): ("(?:[^"\\]|\\.)*"|[^,]*)`)
+)
+
+// ParseErrorFile parses an nginx error log
+func (p *Parser) ParseErrorFile(filename string) ([]*ErrorLogEntry, error) {
+	var entries []*ErrorLogEntry
+	err := p.StreamErrorFile(filename, func(entry *ErrorLogEntry) {
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// StreamErrorFile parses an nginx error log and passes each entry to handler
+// as it is read. Lines that do not start a new entry, such as the rest of a
+// multi-line message, are skipped.
+func (p *Parser) StreamErrorFile(filename string, handler func(*ErrorLogEntry)) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := p.createReader(file, filename)
+	if err != nil {
+		return fmt.Errorf("failed to create reader for %s: %w", filename, err)
+	}
+	defer func() {
+		if closer, ok := reader.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry, err := p.ParseErrorLine(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to parse line %d in %s: %v\n", lineNum, filepath.Base(filename), err)
+			continue
+		}
+
+		handler(entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+
+	return nil
+}
+
+// ParseErrorLine parses one nginx error log line. nginx writes these
+// timestamps in the server's local time without a zone.
+func (p *Parser) ParseErrorLine(line string) (*ErrorLogEntry, error) {
+	matches := errorLineRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, fmt.Errorf("line does not match the nginx error log format")
+	}
+
+	timestamp, err := time.ParseInLocation("2006/01/02 15:04:05", matches[1], time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	entry := &ErrorLogEntry{
+		Timestamp: timestamp,
+		Level:     matches[2],
+		Message:   matches[6],
+	}
+	entry.PID, _ = strconv.Atoi(matches[3])
+	entry.TID, _ = strconv.Atoi(matches[4])
+	if matches[5] != "" {
+		entry.Connection, _ = strconv.ParseInt(matches[5], 10, 64)
+	}
+
+	// The request context follows the message as ", key: value" pairs
+	if start := strings.Index(entry.Message, ", client: "); start >= 0 {
+		context := entry.Message[start:]
+		entry.Message = entry.Message[:start]
+
+		for _, pair := range errorContextRegex.FindAllStringSubmatch(context, -1) {
+			value := pair[2]
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			} else {
+				value = strings.Trim(value, `"`)
+			}
+
+			switch pair[1] {
+			case "client":
+				entry.Client = value
+			case "server":
+				entry.Server = value
+			case "request":
+				entry.Request = value
+				entry.Method, entry.URL, entry.Protocol = parseRequestField(value)
+			case "upstream":
+				entry.Upstream = value
+			case "host":
+				entry.Host = value
+			case "referrer":
+				entry.Referrer = value
+			}
+		}
+	}
+
+	return entry, nil
+}
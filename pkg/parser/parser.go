@@ -24,6 +24,15 @@ type LogEntry struct {
 	Size      int64
 	Referer   string
 	UserAgent string
+
+	// RequestTime is nginx's $request_time when the log format appends it
+	// after the user agent; HasRequestTime reports whether it was present
+	RequestTime    time.Duration
+	HasRequestTime bool
+
+	// Fields holds extra named values when the entry stands in for a row of
+	// other data, such as an error log line or a session, queried with SLAQ
+	Fields map[string]string
 }
 
 type Parser struct {
@@ -32,8 +41,9 @@ type Parser struct {
 }
 
 func New() *Parser {
-	// More flexible patterns that can handle edge cases
-	combinedPattern := `^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d+) (\d+) "([^"]*)" "([^"]*)"$`
+	// More flexible patterns that can handle edge cases. Extra fields after
+	// the user agent (such as $request_time) are captured for timing data.
+	combinedPattern := `^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d+) (\d+) "([^"]*)" "([^"]*)"(.*)$`
 	commonPattern := `^(\S+) \S+ \S+ \[([^\]]+)\] "([^"]*)" (\d+) (\d+)$`
 
 	return &Parser{
@@ -43,16 +53,30 @@ func New() *Parser {
 }
 
 func (p *Parser) ParseFile(filename string) ([]*LogEntry, error) {
+	var entries []*LogEntry
+	err := p.StreamFile(filename, func(entry *LogEntry) {
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// StreamFile parses a log file and passes each entry to handler as it is read,
+// without holding the whole file in memory
+func (p *Parser) StreamFile(filename string, handler func(*LogEntry)) error {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
 	// Create a reader that handles compressed files
 	reader, err := p.createReader(file, filename)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create reader for %s: %w", filename, err)
+		return fmt.Errorf("failed to create reader for %s: %w", filename, err)
 	}
 	defer func() {
 		if closer, ok := reader.(io.Closer); ok {
@@ -60,7 +84,6 @@ func (p *Parser) ParseFile(filename string) ([]*LogEntry, error) {
 		}
 	}()
 
-	var entries []*LogEntry
 	scanner := bufio.NewScanner(reader)
 	
 	// Increase buffer size for potentially large compressed files
@@ -83,14 +106,14 @@ func (p *Parser) ParseFile(filename string) ([]*LogEntry, error) {
 			continue
 		}
 
-		entries = append(entries, entry)
+		handler(entry)
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file %s: %w", filename, err)
+		return fmt.Errorf("error reading file %s: %w", filename, err)
 	}
 
-	return entries, nil
+	return nil
 }
 
 // createReader creates appropriate reader based on file extension
@@ -161,7 +184,7 @@ func (p *Parser) parseCombinedFormat(matches []string) (*LogEntry, error) {
 		size = 0
 	}
 
-	return &LogEntry{
+	entry := &LogEntry{
 		IP:        ip,
 		Timestamp: timestamp,
 		Method:    method,
@@ -171,7 +194,10 @@ func (p *Parser) parseCombinedFormat(matches []string) (*LogEntry, error) {
 		Size:      size,
 		Referer:   matches[6],
 		UserAgent: matches[7],
-	}, nil
+	}
+	entry.RequestTime, entry.HasRequestTime = parseRequestTime(matches[8])
+
+	return entry, nil
 }
 
 func (p *Parser) parseCommonFormat(matches []string) (*LogEntry, error) {
@@ -227,6 +253,29 @@ func parseRequestField(request string) (method, url, protocol string) {
 	return "", "", ""
 }
 
+// parseRequestTime extracts the request duration from the fields logged
+// after the user agent. It accepts nginx's $request_time as a bare number of
+// seconds with millisecond precision ("0.123"), or as rt=/request_time=.
+func parseRequestTime(trailing string) (time.Duration, bool) {
+	for _, field := range strings.Fields(trailing) {
+		field = strings.Trim(field, `"`)
+		if strings.HasPrefix(field, "rt=") {
+			field = strings.TrimPrefix(field, "rt=")
+		} else if strings.HasPrefix(field, "request_time=") {
+			field = strings.TrimPrefix(field, "request_time=")
+		} else if !strings.Contains(field, ".") {
+			continue
+		}
+
+		seconds, err := strconv.ParseFloat(field, 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
+
 func parseTimestamp(timestampStr string) (time.Time, error) {
 	return time.Parse("02/Jan/2006:15:04:05 -0700", timestampStr)
 }
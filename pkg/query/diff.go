@@ -0,0 +1,191 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DiffQuery runs a GROUP BY query against two sets of logs, such as the
+// traffic before and after a deployment, and returns one row per group with
+// each numeric column before, after and its change. Groups missing from one
+// side count as zero. Rows are ordered by the largest change of the first
+// numeric column; the query's LIMIT and OFFSET apply to the diff rather than
+// to each side.
+func DiffQuery(queryStr string, before, after *QueryEngine) (*QueryResult, error) {
+	stmt, err := ParseQuery(queryStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	if stmt.Explain {
+		return nil, fmt.Errorf("EXPLAIN queries cannot be diffed")
+	}
+	if len(stmt.GroupBy) == 0 {
+		return nil, fmt.Errorf("query diff compares groups: add a GROUP BY, e.g. GROUP BY url")
+	}
+
+	unlimited := *stmt
+	unlimited.Limit = nil
+	unlimited.Offset = nil
+
+	beforeResult, err := before.executeStatement(&unlimited)
+	if err != nil {
+		return nil, fmt.Errorf("before: %w", err)
+	}
+	afterResult, err := after.executeStatement(&unlimited)
+	if err != nil {
+		return nil, fmt.Errorf("after: %w", err)
+	}
+
+	result := diffResults(beforeResult, afterResult, len(stmt.GroupBy))
+
+	if stmt.Offset != nil {
+		offset := int(*stmt.Offset)
+		if offset > len(result.Rows) {
+			offset = len(result.Rows)
+		}
+		result.Rows = result.Rows[offset:]
+	}
+	if stmt.Limit != nil && int(*stmt.Limit) < len(result.Rows) {
+		result.Rows = result.Rows[:*stmt.Limit]
+	}
+	result.Count = len(result.Rows)
+	return result, nil
+}
+
+// diffRow is one group of a diff with its row on each side, nil when the
+// group is missing from that side
+type diffRow struct {
+	key    string
+	keys   []Value
+	before []Value
+	after  []Value
+	diff   []Value // The row of the diff result
+}
+
+// diffResults pairs the rows of two results of the same query by their first
+// keyColumns columns and compares the remaining columns
+func diffResults(before, after *QueryResult, keyColumns int) *QueryResult {
+	rows := make(map[string]*diffRow)
+	var order []*diffRow
+	pair := func(result *QueryResult, isBefore bool) {
+		for _, row := range result.Rows {
+			var parts []string
+			for _, value := range row[:keyColumns] {
+				parts = append(parts, value.String())
+			}
+			key := strings.Join(parts, "|")
+
+			group, exists := rows[key]
+			if !exists {
+				group = &diffRow{key: key, keys: row[:keyColumns]}
+				rows[key] = group
+				order = append(order, group)
+			}
+			if isBefore {
+				group.before = row
+			} else {
+				group.after = row
+			}
+		}
+	}
+	pair(before, true)
+	pair(after, false)
+
+	// Numeric columns are compared, others are shown side by side
+	numeric := make([]bool, len(before.Columns))
+	for column := keyColumns; column < len(numeric); column++ {
+		numeric[column] = isNumericColumn(before, column) && isNumericColumn(after, column)
+	}
+
+	result := &QueryResult{Columns: append([]string(nil), before.Columns[:keyColumns]...)}
+	changeIndex := -1 // Change of the first numeric column, which orders the rows
+	for column := keyColumns; column < len(numeric); column++ {
+		name := before.Columns[column]
+		result.Columns = append(result.Columns, name+" before", name+" after")
+		if numeric[column] {
+			if changeIndex < 0 {
+				changeIndex = len(result.Columns)
+			}
+			result.Columns = append(result.Columns, name+" change", name+" change %")
+		}
+	}
+
+	for _, group := range order {
+		row := append([]Value(nil), group.keys...)
+		for column := keyColumns; column < len(numeric); column++ {
+			if !numeric[column] {
+				row = append(row, diffValue(group.before, column), diffValue(group.after, column))
+				continue
+			}
+			beforeValue, afterValue := diffNumber(group.before, column), diffNumber(group.after, column)
+			row = append(row, beforeValue, afterValue, numberChange(beforeValue, afterValue), percentChange(beforeValue, afterValue))
+		}
+		group.diff = row
+	}
+
+	// Largest change first, then by group
+	sort.SliceStable(order, func(i, j int) bool {
+		if changeIndex >= 0 {
+			a, b := math.Abs(numberValue(order[i].diff[changeIndex])), math.Abs(numberValue(order[j].diff[changeIndex]))
+			if a != b {
+				return a > b
+			}
+		}
+		return order[i].key < order[j].key
+	})
+	for _, group := range order {
+		result.Rows = append(result.Rows, group.diff)
+	}
+
+	result.Count = len(result.Rows)
+	return result
+}
+
+// diffValue returns a column of one side of a diff, empty when the group is
+// missing from that side
+func diffValue(row []Value, column int) Value {
+	if row == nil {
+		return Value{Type: ValueString, StringVal: ""}
+	}
+	return row[column]
+}
+
+// diffNumber returns a numeric column of one side of a diff, zero when the
+// group is missing from that side
+func diffNumber(row []Value, column int) Value {
+	if row == nil {
+		return Value{Type: ValueInt, IntVal: 0}
+	}
+	return row[column]
+}
+
+// numberChange returns after minus before, as an integer when both are
+func numberChange(before, after Value) Value {
+	if before.Type == ValueInt && after.Type == ValueInt {
+		return Value{Type: ValueInt, IntVal: after.IntVal - before.IntVal}
+	}
+	return Value{Type: ValueFloat, FloatVal: numberValue(after) - numberValue(before)}
+}
+
+// percentChange returns the change relative to before, or "new" for groups
+// that had nothing before
+func percentChange(before, after Value) Value {
+	from, to := numberValue(before), numberValue(after)
+	if from == 0 {
+		if to == 0 {
+			return Value{Type: ValueFloat, FloatVal: 0}
+		}
+		return Value{Type: ValueString, StringVal: "new"}
+	}
+	return Value{Type: ValueFloat, FloatVal: (to - from) * 100 / math.Abs(from)}
+}
+
+// numberValue returns an integer or float value as a float
+func numberValue(value Value) float64 {
+	if value.Type == ValueInt {
+		return float64(value.IntVal)
+	}
+	return value.FloatVal
+}
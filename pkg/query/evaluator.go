@@ -6,10 +6,29 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"smart-log-analyser/pkg/parser"
 )
 
+// regexCache holds compiled MATCHES patterns so each is compiled only once
+var regexCache sync.Map
+
+// compileRegex compiles an RE2 pattern, reusing earlier compilations
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := regexCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
 // evaluateBinaryOperation performs binary operations
 func evaluateBinaryOperation(left Value, op Operator, right Value) (Value, error) {
 	switch op {
@@ -73,6 +92,10 @@ func compareValues(left, right Value, cmp func(int) bool) (Value, error) {
 		if err != nil {
 			return Value{}, err
 		}
+		// e.g. an empty lookup column from a LEFT JOIN compared with a number
+		if left.Type != right.Type {
+			return Value{}, fmt.Errorf("cannot compare %s with %s", left.String(), right.String())
+		}
 	}
 
 	var result int
@@ -136,6 +159,18 @@ func coerceValues(left, right Value) (Value, Value, error) {
 		}
 	}
 
+	// Time literal strings compared with times, e.g. lookup columns
+	if left.Type == ValueString && right.Type == ValueTime {
+		if t, ok := parseTimeLiteral(left.StringVal); ok {
+			left = Value{Type: ValueTime, TimeVal: t}
+		}
+	}
+	if right.Type == ValueString && left.Type == ValueTime {
+		if t, ok := parseTimeLiteral(right.StringVal); ok {
+			right = Value{Type: ValueTime, TimeVal: t}
+		}
+	}
+
 	// Int to float coercion
 	if left.Type == ValueInt && right.Type == ValueFloat {
 		left = Value{Type: ValueFloat, FloatVal: float64(left.IntVal)}
@@ -167,18 +202,19 @@ func evaluateLike(left, right Value) (Value, error) {
 	return Value{Type: ValueBool, BoolVal: matched}, nil
 }
 
-// evaluateMatches implements regular expression matching
+// evaluateMatches implements RE2 regular expression matching. The pattern is
+// unanchored, so use ^ and $ to match the whole value.
 func evaluateMatches(left, right Value) (Value, error) {
 	if left.Type != ValueString || right.Type != ValueString {
 		return Value{}, fmt.Errorf("MATCHES operator requires string operands")
 	}
 
-	matched, err := regexp.MatchString(right.StringVal, left.StringVal)
+	re, err := compileRegex(right.StringVal)
 	if err != nil {
 		return Value{}, fmt.Errorf("invalid regex pattern: %v", err)
 	}
 
-	return Value{Type: ValueBool, BoolVal: matched}, nil
+	return Value{Type: ValueBool, BoolVal: re.MatchString(left.StringVal)}, nil
 }
 
 // evaluateContains implements string contains checking
@@ -405,6 +441,19 @@ func evaluateFunction(name string, args []Value, entry *parser.LogEntry) (Value,
 		dateStr := args[0].TimeVal.Format("2006-01-02")
 		return Value{Type: ValueString, StringVal: dateStr}, nil
 
+	case "DATE_TRUNC":
+		if len(args) != 2 {
+			return Value{}, fmt.Errorf("DATE_TRUNC function requires exactly 2 arguments")
+		}
+		if args[0].Type != ValueString || args[1].Type != ValueTime {
+			return Value{}, fmt.Errorf("DATE_TRUNC function requires a unit string and a time argument")
+		}
+		truncated, err := truncateTime(args[1].TimeVal, args[0].StringVal)
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{Type: ValueTime, TimeVal: truncated}, nil
+
 	case "UPPER":
 		if len(args) != 1 {
 			return Value{}, fmt.Errorf("UPPER function requires exactly 1 argument")
@@ -470,6 +519,12 @@ func evaluateFunction(name string, args []Value, entry *parser.LogEntry) (Value,
 		isPrivate := isPrivateIP(ip)
 		return Value{Type: ValueBool, BoolVal: isPrivate}, nil
 
+	case "NOW":
+		if len(args) != 0 {
+			return Value{}, fmt.Errorf("NOW function takes no arguments")
+		}
+		return Value{Type: ValueTime, TimeVal: time.Now()}, nil
+
 	case "COUNTRY":
 		if len(args) != 1 {
 			return Value{}, fmt.Errorf("COUNTRY function requires exactly 1 argument")
@@ -482,10 +537,150 @@ func evaluateFunction(name string, args []Value, entry *parser.LogEntry) (Value,
 		return Value{Type: ValueString, StringVal: country}, nil
 
 	default:
+		if fn, exists := registeredFunction(name); exists {
+			value, err := fn(args)
+			if err != nil {
+				return Value{}, fmt.Errorf("%s function: %w", strings.ToUpper(name), err)
+			}
+			return value, nil
+		}
 		return Value{}, fmt.Errorf("unknown function: %s", name)
 	}
 }
 
+// truncateTime rounds t down to the start of its bucket. Units are calendar
+// names (second, minute, hour, day, week, month, year) or a bucket size such
+// as '30s', '5m', '6h' or '7d'. Buckets of up to a day start at local midnight,
+// longer ones at the Unix epoch date; weeks start on Monday.
+func truncateTime(t time.Time, unit string) (time.Time, error) {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "second":
+		return t.Truncate(time.Second), nil
+	case "minute":
+		unit = "1m"
+	case "hour":
+		unit = "1h"
+	case "day":
+		return midnight, nil
+	case "week":
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return midnight.AddDate(0, 0, -daysSinceMonday), nil
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()), nil
+	case "year":
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+
+	size, err := parseBucketSize(unit)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if size%(24*time.Hour) == 0 {
+		days := int64(size / (24 * time.Hour))
+		epochDays := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+		return midnight.AddDate(0, 0, -int(epochDays%days)), nil
+	}
+	if size > 24*time.Hour {
+		return time.Time{}, fmt.Errorf("DATE_TRUNC bucket %q longer than a day must be whole days", unit)
+	}
+
+	elapsed := t.Sub(midnight)
+	return midnight.Add(elapsed - elapsed%size), nil
+}
+
+// timeLiteralFormats are the layouts accepted for time literals such as
+// '2024-01-01' or '2024-01-01T10:00:00Z'. Times without a zone are UTC.
+var timeLiteralFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// parseTimeLiteral parses a quoted time literal
+func parseTimeLiteral(literal string) (time.Time, bool) {
+	for _, format := range timeLiteralFormats {
+		if t, err := time.Parse(format, literal); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseInterval parses an INTERVAL such as '30m', '24h', '7d', '2w' or
+// '2 hours'
+func parseInterval(literal string) (time.Duration, error) {
+	text := strings.ToLower(strings.TrimSpace(literal))
+
+	if fields := strings.Fields(text); len(fields) == 2 {
+		count, err := strconv.Atoi(fields[0])
+		units := map[string]time.Duration{
+			"second": time.Second,
+			"minute": time.Minute,
+			"hour":   time.Hour,
+			"day":    24 * time.Hour,
+			"week":   7 * 24 * time.Hour,
+		}
+		if unit, ok := units[strings.TrimSuffix(fields[1], "s")]; ok && err == nil && count > 0 {
+			return time.Duration(count) * unit, nil
+		}
+	} else if strings.HasSuffix(text, "d") || strings.HasSuffix(text, "w") {
+		count, err := strconv.Atoi(text[:len(text)-1])
+		if err == nil && count > 0 {
+			days := count
+			if strings.HasSuffix(text, "w") {
+				days *= 7
+			}
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if interval, err := time.ParseDuration(text); err == nil && interval > 0 {
+		return interval, nil
+	}
+
+	return 0, fmt.Errorf("invalid INTERVAL %q (use e.g. '30m', '24h', '7d' or '2 hours')", literal)
+}
+
+// evaluateTimeShift adds or subtracts an interval from a time value. Strings
+// holding a time literal are accepted, e.g. from a lookup column.
+func evaluateTimeShift(value Value, interval time.Duration, subtract bool) (Value, error) {
+	if value.Type == ValueString {
+		if t, ok := parseTimeLiteral(value.StringVal); ok {
+			value = Value{Type: ValueTime, TimeVal: t}
+		}
+	}
+	if value.Type != ValueTime {
+		return Value{}, fmt.Errorf("INTERVAL can only be added to or subtracted from a time, got %s", value.String())
+	}
+
+	if subtract {
+		interval = -interval
+	}
+	return Value{Type: ValueTime, TimeVal: value.TimeVal.Add(interval)}, nil
+}
+
+// parseBucketSize parses a DATE_TRUNC bucket size such as '5m' or '7d'
+func parseBucketSize(unit string) (time.Duration, error) {
+	if strings.HasSuffix(unit, "d") || strings.HasSuffix(unit, "w") {
+		count, err := strconv.Atoi(unit[:len(unit)-1])
+		if err == nil && count > 0 {
+			days := count
+			if strings.HasSuffix(unit, "w") {
+				days *= 7
+			}
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	} else if size, err := time.ParseDuration(unit); err == nil && size >= time.Second {
+		return size, nil
+	}
+	return 0, fmt.Errorf("invalid DATE_TRUNC unit %q (use e.g. '5m', '1h', '1d', 'week' or 'month')", unit)
+}
+
 // toBool converts a value to boolean
 func toBool(value Value) (bool, error) {
 	switch value.Type {
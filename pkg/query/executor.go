@@ -11,7 +11,11 @@ import (
 
 // Executor executes queries against log entries
 type Executor struct {
-	logs []*parser.LogEntry
+	logs      []*parser.LogEntry
+	lookups   map[string]*LookupTable
+	tables    map[string]*Table
+	streaming bool // Entries arrive through a Stream, so subqueries cannot read logs
+	maxGroups int
 }
 
 // NewExecutor creates a new query executor
@@ -19,241 +23,195 @@ func NewExecutor(logs []*parser.LogEntry) *Executor {
 	return &Executor{logs: logs}
 }
 
-// Execute executes a parsed query and returns results
-func (e *Executor) Execute(stmt *SelectStatement) (*QueryResult, error) {
-	// Filter logs based on WHERE clause
-	filteredLogs := e.logs
-	if stmt.Where != nil {
-		var err error
-		filteredLogs, err = e.filterLogs(filteredLogs, stmt.Where)
-		if err != nil {
-			return nil, fmt.Errorf("error filtering logs: %w", err)
-		}
-	}
-
-	// Handle GROUP BY
-	if len(stmt.GroupBy) > 0 {
-		return e.executeGroupBy(stmt, filteredLogs)
-	}
+// SetLookupTables sets the lookup tables queries can JOIN against, keyed by name
+func (e *Executor) SetLookupTables(lookups map[string]*LookupTable) {
+	e.lookups = lookups
+}
 
-	// Handle simple SELECT
-	return e.executeSelect(stmt, filteredLogs)
+// SetTables sets the tables queries can select FROM besides logs, keyed by name
+func (e *Executor) SetTables(tables map[string]*Table) {
+	e.tables = tables
 }
 
-// filterLogs filters logs based on WHERE clause
-func (e *Executor) filterLogs(logs []*parser.LogEntry, where Expression) ([]*parser.LogEntry, error) {
-	var filtered []*parser.LogEntry
+// SetMaxGroups sets how many GROUP BY groups are kept in memory before their
+// state spills to temporary files
+func (e *Executor) SetMaxGroups(maxGroups int) {
+	e.maxGroups = maxGroups
+}
 
-	for _, log := range logs {
-		result, err := where.Evaluate(log)
-		if err != nil {
-			continue // Skip logs that cause evaluation errors
-		}
+// Execute executes a parsed query and returns results
+func (e *Executor) Execute(stmt *SelectStatement) (*QueryResult, error) {
+	rows, err := e.rows(stmt.From)
+	if err != nil {
+		return nil, err
+	}
 
-		match, err := toBool(result)
-		if err != nil {
-			continue // Skip non-boolean results
-		}
+	stream, err := e.NewStream(stmt)
+	if err != nil {
+		return nil, err
+	}
 
-		if match {
-			filtered = append(filtered, log)
+	for _, entry := range rows {
+		if stream.Done() {
+			break
 		}
+		stream.Add(entry)
 	}
-
-	return filtered, nil
+	return stream.Result()
 }
 
-// executeSelect executes a simple SELECT without GROUP BY
-func (e *Executor) executeSelect(stmt *SelectStatement, logs []*parser.LogEntry) (*QueryResult, error) {
-	result := &QueryResult{}
-
-	// Determine columns
-	if len(stmt.Fields) == 1 && stmt.Fields[0].Expression.String() == "*" {
-		// SELECT * - return all fields
-		result.Columns = []string{"IP", "Timestamp", "Method", "URL", "Protocol", "Status", "Size", "Referer", "UserAgent"}
-	} else {
-		// SELECT specific fields
-		for _, field := range stmt.Fields {
-			if field.Alias != "" {
-				result.Columns = append(result.Columns, field.Alias)
-			} else {
-				result.Columns = append(result.Columns, field.Expression.String())
+// resolveStatement returns a copy of stmt whose table and lookup columns read
+// from the rows and joined tables, and whose IN subqueries have been executed
+func (e *Executor) resolveStatement(stmt *SelectStatement, joins map[string]*resolvedJoin) (*SelectStatement, error) {
+	resolved := *stmt
+
+	table, err := e.table(stmt.From)
+	if err != nil {
+		return nil, err
+	}
+	resolveFields := func(expr Expression) (Expression, error) {
+		if table != nil {
+			var err error
+			if expr, err = resolveTableFields(expr, table); err != nil {
+				return nil, err
 			}
 		}
+		return resolveLookupFields(expr, joins)
 	}
 
-	// Process each log entry
-	for _, log := range logs {
-		var row []Value
-
-		if len(stmt.Fields) == 1 && stmt.Fields[0].Expression.String() == "*" {
-			// SELECT * - return all field values
-			row = []Value{
-				{Type: ValueString, StringVal: log.IP},
-				{Type: ValueTime, TimeVal: log.Timestamp},
-				{Type: ValueString, StringVal: log.Method},
-				{Type: ValueString, StringVal: log.URL},
-				{Type: ValueString, StringVal: log.Protocol},
-				{Type: ValueInt, IntVal: int64(log.Status)},
-				{Type: ValueInt, IntVal: log.Size},
-				{Type: ValueString, StringVal: log.Referer},
-				{Type: ValueString, StringVal: log.UserAgent},
+	if len(joins) > 0 || table != nil {
+		resolved.Fields = make([]SelectField, len(stmt.Fields))
+		for i, field := range stmt.Fields {
+			expr, err := resolveFields(field.Expression)
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			// SELECT specific fields
-			for _, field := range stmt.Fields {
-				value, err := field.Expression.Evaluate(log)
-				if err != nil {
-					// Use NULL-like value for errors
-					value = Value{Type: ValueString, StringVal: ""}
-				}
-				row = append(row, value)
+			resolved.Fields[i] = SelectField{Expression: expr, Alias: field.Alias}
+		}
+
+		resolved.GroupBy = make([]Expression, len(stmt.GroupBy))
+		for i, groupBy := range stmt.GroupBy {
+			expr, err := resolveFields(groupBy)
+			if err != nil {
+				return nil, err
 			}
+			resolved.GroupBy[i] = expr
 		}
 
-		result.Rows = append(result.Rows, row)
+		resolved.OrderBy = make([]OrderByClause, len(stmt.OrderBy))
+		for i, clause := range stmt.OrderBy {
+			expr, err := resolveFields(clause.Expression)
+			if err != nil {
+				return nil, err
+			}
+			resolved.OrderBy[i] = OrderByClause{Expression: expr, Descending: clause.Descending}
+		}
 	}
 
-	// Apply ORDER BY
-	if len(stmt.OrderBy) > 0 {
-		err := e.sortRows(result, stmt.OrderBy, logs)
+	if stmt.Where != nil {
+		where, err := resolveFields(stmt.Where)
+		if err == nil {
+			where, err = e.resolveSubqueries(where)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("error sorting results: %w", err)
+			return nil, err
 		}
+		resolved.Where = where
 	}
 
-	// Apply LIMIT
-	if stmt.Limit != nil {
-		limit := int(*stmt.Limit)
-		if limit < len(result.Rows) {
-			result.Rows = result.Rows[:limit]
+	if stmt.Having != nil {
+		having, err := resolveFields(stmt.Having)
+		if err == nil {
+			having, err = e.resolveSubqueries(having)
+		}
+		if err != nil {
+			return nil, err
 		}
+		resolved.Having = having
 	}
 
-	result.Count = len(result.Rows)
-	return result, nil
+	return &resolved, nil
 }
 
-// executeGroupBy executes a SELECT with GROUP BY
-func (e *Executor) executeGroupBy(stmt *SelectStatement, logs []*parser.LogEntry) (*QueryResult, error) {
-	// Group logs by GROUP BY expressions
-	groups, err := e.groupLogs(logs, stmt.GroupBy)
-	if err != nil {
-		return nil, fmt.Errorf("error grouping logs: %w", err)
-	}
-
-	result := &QueryResult{}
-
-	// Build column names
-	for _, expr := range stmt.GroupBy {
-		result.Columns = append(result.Columns, expr.String())
-	}
+// resolveSubqueries replaces every "x IN (SELECT ...)" in expr with a lookup
+// against the values the subquery selects
+func (e *Executor) resolveSubqueries(expr Expression) (Expression, error) {
+	switch expr := expr.(type) {
+	case *BinaryExpression:
+		left, err := e.resolveSubqueries(expr.Left)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, field := range stmt.Fields {
-		// Skip aggregate functions that are already in GROUP BY
-		if !e.isGroupByExpression(field.Expression, stmt.GroupBy) {
-			if field.Alias != "" {
-				result.Columns = append(result.Columns, field.Alias)
-			} else {
-				result.Columns = append(result.Columns, field.Expression.String())
+		if subquery, ok := expr.Right.(*SubqueryExpression); ok && expr.Operator == OpIn {
+			values, err := e.executeSubquery(subquery)
+			if err != nil {
+				return nil, err
 			}
+			return &inSubqueryExpression{Left: left, Subquery: subquery, Values: values}, nil
 		}
-	}
 
-	// Process each group
-	for _, group := range groups {
-		// Build row starting with group key values
-		var row []Value
-		for _, keyValue := range group.KeyValues {
-			row = append(row, keyValue)
+		right, err := e.resolveSubqueries(expr.Right)
+		if err != nil {
+			return nil, err
 		}
+		return &BinaryExpression{Left: left, Operator: expr.Operator, Right: right}, nil
 
-		// Evaluate aggregate functions for this group
-		for _, field := range stmt.Fields {
-			if !e.isGroupByExpression(field.Expression, stmt.GroupBy) {
-				value, err := e.evaluateAggregate(field.Expression, group.Logs)
-				if err != nil {
-					value = Value{Type: ValueString, StringVal: ""}
-				}
-				row = append(row, value)
-			}
+	case *UnaryExpression:
+		operand, err := e.resolveSubqueries(expr.Operand)
+		if err != nil {
+			return nil, err
 		}
+		return &UnaryExpression{Operator: expr.Operator, Operand: operand}, nil
 
-		// Apply HAVING filter if present
-		if stmt.Having != nil {
-			// Create a dummy log entry with aggregated values for HAVING evaluation
-			dummyLog := &parser.LogEntry{}
-			havingResult, err := stmt.Having.Evaluate(dummyLog)
-			if err != nil {
-				continue
-			}
-			match, err := toBool(havingResult)
-			if err != nil || !match {
-				continue
-			}
-		}
+	case *SubqueryExpression:
+		return nil, fmt.Errorf("subqueries are only supported as the list of IN: %s", expr.String())
+	}
+
+	return expr, nil
+}
 
-		result.Rows = append(result.Rows, row)
+// executeSubquery runs a subquery against all logs and returns the set of
+// values in its single column
+func (e *Executor) executeSubquery(subquery *SubqueryExpression) (map[string]bool, error) {
+	if e.streaming && strings.EqualFold(subquery.Statement.From, LogsTable) {
+		return nil, fmt.Errorf("IN subqueries over logs need every entry in memory and cannot run on a stream: %s", subquery.String())
 	}
 
-	// Apply ORDER BY
-	if len(stmt.OrderBy) > 0 {
-		err := e.sortGroupedRows(result, stmt.OrderBy)
-		if err != nil {
-			return nil, fmt.Errorf("error sorting results: %w", err)
-		}
+	result, err := e.Execute(subquery.Statement)
+	if err != nil {
+		return nil, fmt.Errorf("error in subquery: %w", err)
+	}
+	if len(result.Columns) != 1 {
+		return nil, fmt.Errorf("subquery must select exactly one column, got %d", len(result.Columns))
 	}
 
-	// Apply LIMIT
-	if stmt.Limit != nil {
-		limit := int(*stmt.Limit)
-		if limit < len(result.Rows) {
-			result.Rows = result.Rows[:limit]
+	values := make(map[string]bool, len(result.Rows))
+	for _, row := range result.Rows {
+		if len(row) > 0 {
+			values[FormatValue(row[0])] = true
 		}
 	}
-
-	result.Count = len(result.Rows)
-	return result, nil
+	return values, nil
 }
 
-// GroupData represents grouped log data
-type GroupData struct {
-	KeyValues []Value
-	Logs []*parser.LogEntry
+// inSubqueryExpression is an IN expression whose subquery has been executed
+type inSubqueryExpression struct {
+	Left     Expression
+	Subquery *SubqueryExpression
+	Values   map[string]bool // Subquery values, keyed by formatValue
 }
 
-// groupLogs groups logs by the specified expressions
-func (e *Executor) groupLogs(logs []*parser.LogEntry, groupBy []Expression) (map[string]GroupData, error) {
-	groups := make(map[string]GroupData)
-
-	for _, log := range logs {
-		// Evaluate group key
-		var keyValues []Value
-		for _, expr := range groupBy {
-			value, err := expr.Evaluate(log)
-			if err != nil {
-				// Use empty string for errors
-				value = Value{Type: ValueString, StringVal: ""}
-			}
-			keyValues = append(keyValues, value)
-		}
-
-		// Create string key for grouping
-		keyStr := e.groupKeyToString(keyValues)
+func (ie inSubqueryExpression) String() string {
+	return fmt.Sprintf("(%s %s %s)", ie.Left.String(), OpIn, ie.Subquery.String())
+}
 
-		// Add to group
-		if group, exists := groups[keyStr]; exists {
-			group.Logs = append(group.Logs, log)
-			groups[keyStr] = group
-		} else {
-			groups[keyStr] = GroupData{
-				KeyValues: keyValues,
-				Logs:      []*parser.LogEntry{log},
-			}
-		}
+func (ie inSubqueryExpression) Evaluate(entry *parser.LogEntry) (Value, error) {
+	left, err := ie.Left.Evaluate(entry)
+	if err != nil {
+		return Value{}, err
 	}
-
-	return groups, nil
+	return Value{Type: ValueBool, BoolVal: ie.Values[FormatValue(left)]}, nil
 }
 
 // groupKeyToString converts group key values to a string
@@ -276,140 +234,42 @@ func (e *Executor) isGroupByExpression(expr Expression, groupBy []Expression) bo
 	return false
 }
 
-// evaluateAggregate evaluates aggregate functions
-func (e *Executor) evaluateAggregate(expr Expression, logs []*parser.LogEntry) (Value, error) {
-	// Check if it's a function expression
-	if funcExpr, ok := expr.(*FunctionExpression); ok {
-		switch strings.ToUpper(funcExpr.Name) {
-		case "COUNT":
-			return Value{Type: ValueInt, IntVal: int64(len(logs))}, nil
-
-		case "SUM":
-			if len(funcExpr.Arguments) != 1 {
-				return Value{}, fmt.Errorf("SUM requires exactly 1 argument")
-			}
-			var sum float64
-			for _, log := range logs {
-				val, err := funcExpr.Arguments[0].Evaluate(log)
-				if err != nil {
-					continue
-				}
-				switch val.Type {
-				case ValueInt:
-					sum += float64(val.IntVal)
-				case ValueFloat:
-					sum += val.FloatVal
-				}
-			}
-			return Value{Type: ValueFloat, FloatVal: sum}, nil
-
-		case "AVG":
-			if len(funcExpr.Arguments) != 1 {
-				return Value{}, fmt.Errorf("AVG requires exactly 1 argument")
-			}
-			var sum float64
-			count := 0
-			for _, log := range logs {
-				val, err := funcExpr.Arguments[0].Evaluate(log)
-				if err != nil {
-					continue
-				}
-				switch val.Type {
-				case ValueInt:
-					sum += float64(val.IntVal)
-					count++
-				case ValueFloat:
-					sum += val.FloatVal
-					count++
-				}
-			}
-			if count == 0 {
-				return Value{Type: ValueFloat, FloatVal: 0}, nil
-			}
-			return Value{Type: ValueFloat, FloatVal: sum / float64(count)}, nil
-
-		case "MIN", "MAX":
-			if len(funcExpr.Arguments) != 1 {
-				return Value{}, fmt.Errorf("%s requires exactly 1 argument", funcExpr.Name)
-			}
-			if len(logs) == 0 {
-				return Value{Type: ValueInt, IntVal: 0}, nil
-			}
-			
-			firstVal, err := funcExpr.Arguments[0].Evaluate(logs[0])
-			if err != nil {
-				return Value{Type: ValueInt, IntVal: 0}, nil
-			}
-			
-			result := firstVal
-			for _, log := range logs[1:] {
-				val, err := funcExpr.Arguments[0].Evaluate(log)
-				if err != nil {
-					continue
-				}
-				
-				cmp := e.compareValues(val, result)
-				if (funcExpr.Name == "MIN" && cmp < 0) || (funcExpr.Name == "MAX" && cmp > 0) {
-					result = val
-				}
-			}
-			return result, nil
-		}
-	}
-
-	// For non-aggregate expressions, return the first value
-	if len(logs) > 0 {
-		return expr.Evaluate(logs[0])
+// isAggregateFunction reports whether a function aggregates over a group
+func isAggregateFunction(name string) bool {
+	switch strings.ToUpper(name) {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+		return true
 	}
-
-	return Value{Type: ValueString, StringVal: ""}, nil
+	return false
 }
 
-// sortRows sorts result rows based on ORDER BY clause
-func (e *Executor) sortRows(result *QueryResult, orderBy []OrderByClause, logs []*parser.LogEntry) error {
-	sort.Slice(result.Rows, func(i, j int) bool {
-		for _, clause := range orderBy {
-			// Evaluate the expression for both rows
-			val1, err1 := clause.Expression.Evaluate(logs[i])
-			val2, err2 := clause.Expression.Evaluate(logs[j])
-
-			if err1 != nil || err2 != nil {
-				continue
-			}
-
-			// Compare values
-			cmp := e.compareValues(val1, val2)
-			if cmp == 0 {
-				continue
-			}
-
-			if clause.Descending {
-				return cmp > 0
-			}
-			return cmp < 0
-		}
-		return false
-	})
-
-	return nil
+// isKnownField reports whether a field is one of the log entry fields
+func isKnownField(field QueryField) bool {
+	switch field {
+	case FieldIP, FieldTimestamp, FieldMethod, FieldURL, FieldProtocol,
+		FieldStatus, FieldSize, FieldReferer, FieldUserAgent:
+		return true
+	}
+	return false
 }
 
-// sortGroupedRows sorts grouped results
-func (e *Executor) sortGroupedRows(result *QueryResult, orderBy []OrderByClause) error {
-	sort.Slice(result.Rows, func(i, j int) bool {
+// sortGroupedRows sorts grouped rows by the ORDER BY keys, earlier keys
+// first and later keys breaking ties
+func (e *Executor) sortGroupedRows(rows [][]Value, columns []string, orderBy []OrderByClause) error {
+	sort.Slice(rows, func(i, j int) bool {
 		for _, clause := range orderBy {
 			// For grouped results, we need to match column names
-			colIndex := e.findColumnIndex(result.Columns, clause.Expression.String())
+			colIndex := e.findColumnIndex(columns, clause.Expression.String())
 			if colIndex == -1 {
 				continue
 			}
 
-			if colIndex >= len(result.Rows[i]) || colIndex >= len(result.Rows[j]) {
+			if colIndex >= len(rows[i]) || colIndex >= len(rows[j]) {
 				continue
 			}
 
-			val1 := result.Rows[i][colIndex]
-			val2 := result.Rows[j][colIndex]
+			val1 := rows[i][colIndex]
+			val2 := rows[j][colIndex]
 
 			cmp := e.compareValues(val1, val2)
 			if cmp == 0 {
@@ -497,6 +357,8 @@ func FormatResult(result *QueryResult, format string) (string, error) {
 		return formatAsCSV(result), nil
 	case "json":
 		return formatAsJSON(result), nil
+	case "html":
+		return formatAsHTML(result, "")
 	default:
 		return "", fmt.Errorf("unsupported format: %s", format)
 	}
@@ -520,7 +382,7 @@ func formatAsTable(result *QueryResult) string {
 	for _, row := range result.Rows {
 		var rowStrs []string
 		for _, value := range row {
-			rowStrs = append(rowStrs, formatValue(value))
+			rowStrs = append(rowStrs, FormatValue(value))
 		}
 		output.WriteString(strings.Join(rowStrs, " | "))
 		output.WriteString("\n")
@@ -542,7 +404,7 @@ func formatAsCSV(result *QueryResult) string {
 	for _, row := range result.Rows {
 		var rowStrs []string
 		for _, value := range row {
-			val := formatValue(value)
+			val := FormatValue(value)
 			// Escape CSV values
 			if strings.Contains(val, ",") || strings.Contains(val, "\"") {
 				val = "\"" + strings.ReplaceAll(val, "\"", "\"\"") + "\""
@@ -589,8 +451,8 @@ func formatAsJSON(result *QueryResult) string {
 	return output.String()
 }
 
-// formatValue formats a value for display
-func formatValue(value Value) string {
+// FormatValue formats a value for display
+func FormatValue(value Value) string {
 	switch value.Type {
 	case ValueString:
 		return value.StringVal
@@ -0,0 +1,561 @@
+package query
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// ExplainSampleSize is the number of entries EXPLAIN evaluates the filters
+// of a query against. Estimates for larger inputs are scaled from the sample.
+const ExplainSampleSize = 10000
+
+// explainColumns are the columns of an EXPLAIN result
+var explainColumns = []string{"Step", "Detail", "Estimate"}
+
+// explainSample keeps a uniform random sample of the entries an EXPLAIN
+// stream sees, along with what is needed to describe the plan
+type explainSample struct {
+	source  *SelectStatement         // Statement as parsed, for the AST
+	joins   map[string]*resolvedJoin // All joins, including LEFT JOINs
+	entries []*parser.LogEntry
+	total   int
+	random  *rand.Rand
+}
+
+// newExplainSample creates an empty sample. The seed is fixed so the same
+// input always gives the same estimates.
+func newExplainSample(source *SelectStatement, joins map[string]*resolvedJoin) *explainSample {
+	return &explainSample{
+		source: source,
+		joins:  joins,
+		random: rand.New(rand.NewSource(1)),
+	}
+}
+
+// add offers an entry to the sample (reservoir sampling)
+func (es *explainSample) add(entry *parser.LogEntry) {
+	es.total++
+	if len(es.entries) < ExplainSampleSize {
+		es.entries = append(es.entries, entry)
+		return
+	}
+	if i := es.random.Intn(es.total); i < ExplainSampleSize {
+		es.entries[i] = entry
+	}
+}
+
+// explainer builds the rows of an EXPLAIN result
+type explainer struct {
+	stream *Stream
+	sample *explainSample
+	result *QueryResult
+}
+
+// explain describes the plan of the stream's query: the parsed AST, the
+// filters applied and how much work each step is estimated to do
+func (s *Stream) explain() (*QueryResult, error) {
+	x := &explainer{
+		stream: s,
+		sample: s.sample,
+		result: &QueryResult{Columns: append([]string(nil), explainColumns...)},
+	}
+
+	for _, line := range statementAST(x.sample.source, 0, s.executor.tables) {
+		x.add("AST", line, "")
+	}
+
+	stmt := s.stmt
+	x.add("Scan", fmt.Sprintf("%s: %d entries, %d sampled", stmt.From, x.sample.total, len(x.sample.entries)), x.rows(len(x.sample.entries)))
+	if x.sample.total == 0 {
+		x.add("Warning", "no log entries were read", "")
+	}
+
+	passes := 1
+	for _, subquery := range findSubqueries(stmt.Where, stmt.Having) {
+		passes++
+		x.add("Subquery", subquery.Subquery.Statement.String(), fmt.Sprintf("runs first over every entry, %d values", len(subquery.Values)))
+	}
+
+	current := x.sample.entries
+	for _, join := range x.sample.source.Joins {
+		resolved := x.sample.joins[strings.ToLower(join.Table)]
+		var matched []*parser.LogEntry
+		for _, entry := range current {
+			if resolved.row(entry) != nil {
+				matched = append(matched, entry)
+			}
+		}
+
+		estimate := fmt.Sprintf("%s have a matching row", x.rows(len(matched)))
+		if join.Left {
+			estimate += "; all entries kept"
+		} else {
+			current = matched
+		}
+		if len(matched) == 0 && len(x.sample.entries) > 0 {
+			estimate += " ⚠ no sampled entry matches the lookup"
+		}
+		x.add("Join", fmt.Sprintf("%s (%d lookup rows)", join.String(), len(resolved.table.Rows)), estimate)
+	}
+
+	evaluations := 0
+	for _, condition := range splitConjuncts(stmt.Where) {
+		evaluations += len(current)
+		current = x.filter(condition, current)
+	}
+
+	if len(stmt.GroupBy) > 0 {
+		x.explainGroups(current)
+	} else {
+		x.explainRows(len(current))
+	}
+
+	work := fmt.Sprintf("%d pass(es) over the input", passes)
+	if len(stmt.Joins) > 0 {
+		work += fmt.Sprintf(", %d lookup(s) per entry", len(stmt.Joins))
+	}
+	x.add("Work", work, fmt.Sprintf("%s filter evaluations", x.count(evaluations)))
+
+	x.result.Count = len(x.result.Rows)
+	return x.result, nil
+}
+
+// filter applies one WHERE condition to the sampled entries that reached it
+func (x *explainer) filter(condition Expression, entries []*parser.LogEntry) []*parser.LogEntry {
+	var matched []*parser.LogEntry
+	var errors int
+	var firstErr error
+	for _, entry := range entries {
+		value, err := condition.Evaluate(entry)
+		if err == nil {
+			var match bool
+			if match, err = toBool(value); err == nil && match {
+				matched = append(matched, entry)
+			}
+		}
+		if err != nil {
+			errors++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	estimate := x.rows(len(matched)) + " pass"
+	if len(entries) > 0 {
+		estimate += fmt.Sprintf(" (%.1f%% of the rows reaching it)", float64(len(matched))*100/float64(len(entries)))
+	}
+	if errors > 0 {
+		estimate += fmt.Sprintf(" ⚠ %d sampled entries fail to evaluate: %v", errors, firstErr)
+	} else if len(entries) == 0 {
+		estimate += "; no sampled entry reaches this filter"
+	} else if len(matched) == 0 {
+		estimate += " ⚠ matches no sampled entries"
+	}
+	x.add("Filter", condition.String(), estimate)
+
+	return matched
+}
+
+// explainGroups describes GROUP BY, HAVING, ORDER BY and LIMIT for grouped
+// queries, running the query over the sample to count groups
+func (x *explainer) explainGroups(entries []*parser.LogEntry) {
+	s := x.stream
+	stmt := s.stmt
+
+	keys := make(map[string]bool)
+	for _, entry := range entries {
+		var values []Value
+		for _, expr := range stmt.GroupBy {
+			value, _ := expr.Evaluate(entry)
+			values = append(values, value)
+		}
+		keys[s.executor.groupKeyToString(values)] = true
+	}
+
+	var groupBy, aggregates []string
+	for _, expr := range stmt.GroupBy {
+		groupBy = append(groupBy, expr.String())
+	}
+	for _, function := range s.aggregates {
+		aggregates = append(aggregates, function.String())
+	}
+	detail := "GROUP BY " + strings.Join(groupBy, ", ")
+	if len(aggregates) > 0 {
+		detail += "; aggregates " + strings.Join(aggregates, ", ")
+	}
+
+	groups := len(keys)
+	estimate := fmt.Sprintf("%d groups", groups)
+	if !x.exact() {
+		estimate = fmt.Sprintf("at least %d groups", groups)
+	}
+	if groups > s.maxGroups {
+		estimate += fmt.Sprintf("; spills to disk above %d groups", s.maxGroups)
+	} else if !x.exact() && len(entries) > 0 && groups*x.sample.total/len(x.sample.entries) > s.maxGroups {
+		estimate += fmt.Sprintf("; may spill to disk above %d groups", s.maxGroups)
+	}
+	x.add("Group", detail, estimate)
+
+	output := x.sampleOutput()
+	if stmt.Having != nil {
+		estimate := fmt.Sprintf("%d of %d sampled groups pass", output, groups)
+		if output == 0 && groups > 0 {
+			estimate += " ⚠ no sampled group passes"
+		}
+		x.add("Having", stmt.Having.String(), estimate)
+	}
+
+	if len(stmt.OrderBy) > 0 {
+		x.add("Sort", "ORDER BY "+orderByString(stmt.OrderBy), "sorts the groups that pass")
+	}
+	output = x.explainPage(output, "groups")
+
+	estimate = fmt.Sprintf("%d rows", output)
+	if !x.exact() {
+		estimate = fmt.Sprintf("at least %d rows", output)
+	}
+	x.add("Output", strings.Join(s.Columns(), ", "), estimate)
+}
+
+// explainRows describes ORDER BY and LIMIT for queries without GROUP BY
+func (x *explainer) explainRows(matched int) {
+	s := x.stream
+	stmt := s.stmt
+	rows := x.scale(matched)
+
+	if len(stmt.OrderBy) > 0 {
+		estimate := fmt.Sprintf("sorts %s in memory", x.rows(matched))
+		if needed, limited := s.rowsNeeded(); limited {
+			estimate = fmt.Sprintf("keeps the top %d of %s", needed, x.rows(matched))
+		}
+		x.add("Sort", "ORDER BY "+orderByString(stmt.OrderBy), estimate)
+	}
+
+	if needed, limited := s.rowsNeeded(); limited && len(stmt.OrderBy) == 0 {
+		estimate := fmt.Sprintf("stops reading after %d matching rows", needed)
+		if matched > 0 && rows > needed {
+			estimate += fmt.Sprintf(", about %.2g%% of the input", float64(needed)*100/float64(rows))
+		}
+		x.add("Limit", pageString(stmt), estimate)
+		rows = x.pageRows(rows)
+	} else {
+		rows = x.explainPage(rows, "rows")
+	}
+
+	estimate := fmt.Sprintf("%d rows", rows)
+	if !x.exact() {
+		estimate = "~" + estimate
+	}
+	x.add("Output", strings.Join(s.Columns(), ", "), estimate)
+}
+
+// explainPage describes OFFSET and LIMIT and returns how many of the given
+// rows or groups remain
+func (x *explainer) explainPage(rows int, unit string) int {
+	stmt := x.stream.stmt
+	if stmt.Limit == nil && stmt.Offset == nil {
+		return rows
+	}
+
+	var estimate string
+	switch {
+	case stmt.Offset == nil:
+		estimate = fmt.Sprintf("returns the first %d %s", *stmt.Limit, unit)
+	case stmt.Limit == nil:
+		estimate = fmt.Sprintf("skips the first %d %s", *stmt.Offset, unit)
+	default:
+		estimate = fmt.Sprintf("returns %s %d-%d", unit, *stmt.Offset+1, *stmt.Offset+*stmt.Limit)
+	}
+	x.add("Limit", pageString(stmt), estimate)
+	return x.pageRows(rows)
+}
+
+// pageRows returns how many of the given rows are left after OFFSET and LIMIT
+func (x *explainer) pageRows(rows int) int {
+	stmt := x.stream.stmt
+	if stmt.Offset != nil {
+		rows -= int(*stmt.Offset)
+		if rows < 0 {
+			rows = 0
+		}
+	}
+	if stmt.Limit != nil && rows > int(*stmt.Limit) {
+		rows = int(*stmt.Limit)
+	}
+	return rows
+}
+
+// pageString renders the LIMIT and OFFSET of a statement
+func pageString(stmt *SelectStatement) string {
+	var parts []string
+	if stmt.Limit != nil {
+		parts = append(parts, fmt.Sprintf("LIMIT %d", *stmt.Limit))
+	}
+	if stmt.Offset != nil {
+		parts = append(parts, fmt.Sprintf("OFFSET %d", *stmt.Offset))
+	}
+	return strings.Join(parts, " ")
+}
+
+// sampleOutput runs the query without its LIMIT over the sample and returns
+// the number of rows it produces
+func (x *explainer) sampleOutput() int {
+	unlimited := *x.stream.stmt
+	unlimited.Limit = nil
+	unlimited.Offset = nil
+
+	run := *x.stream
+	run.stmt = &unlimited
+	run.sample = nil
+	run.rows = nil
+	run.spill = nil
+	run.groups = make(map[string]*groupState)
+	for _, entry := range x.sample.entries {
+		run.Add(entry)
+	}
+
+	result, err := run.Result()
+	if err != nil {
+		return 0
+	}
+	return result.Count
+}
+
+// add appends a row to the plan
+func (x *explainer) add(step, detail, estimate string) {
+	x.result.Rows = append(x.result.Rows, []Value{
+		{Type: ValueString, StringVal: step},
+		{Type: ValueString, StringVal: detail},
+		{Type: ValueString, StringVal: estimate},
+	})
+}
+
+// exact reports whether every entry was sampled, so counts need no scaling
+func (x *explainer) exact() bool {
+	return len(x.sample.entries) == x.sample.total
+}
+
+// scale converts a count over the sample to an estimate over all entries
+func (x *explainer) scale(n int) int {
+	if x.exact() || len(x.sample.entries) == 0 {
+		return n
+	}
+	return int(float64(n)*float64(x.sample.total)/float64(len(x.sample.entries)) + 0.5)
+}
+
+// count formats a sample count scaled to all entries
+func (x *explainer) count(n int) string {
+	if x.exact() {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("~%d", x.scale(n))
+}
+
+// rows formats a sample count as an estimated number of rows
+func (x *explainer) rows(n int) string {
+	return x.count(n) + " rows"
+}
+
+// splitConjuncts splits a WHERE condition into the parts joined by AND, in
+// the order they are written
+func splitConjuncts(expr Expression) []Expression {
+	if expr == nil {
+		return nil
+	}
+	if binary, ok := expr.(*BinaryExpression); ok && binary.Operator == OpAnd {
+		return append(splitConjuncts(binary.Left), splitConjuncts(binary.Right)...)
+	}
+	return []Expression{expr}
+}
+
+// findSubqueries returns the executed IN subqueries in the given expressions
+func findSubqueries(exprs ...Expression) []*inSubqueryExpression {
+	var subqueries []*inSubqueryExpression
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case *inSubqueryExpression:
+			subqueries = append(subqueries, expr)
+			subqueries = append(subqueries, findSubqueries(expr.Left)...)
+		case *BinaryExpression:
+			subqueries = append(subqueries, findSubqueries(expr.Left, expr.Right)...)
+		case *UnaryExpression:
+			subqueries = append(subqueries, findSubqueries(expr.Operand)...)
+		}
+	}
+	return subqueries
+}
+
+// orderByString lists ORDER BY clauses as written in a query
+func orderByString(clauses []OrderByClause) string {
+	var parts []string
+	for _, clause := range clauses {
+		parts = append(parts, clause.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// astScope is what names in an expression can refer to
+type astScope struct {
+	tables map[string]*Table
+	names  map[string]string // Lower-case name → AST label
+}
+
+// statementAST renders a statement as an indented tree, one node per line
+func statementAST(stmt *SelectStatement, depth int, tables map[string]*Table) []string {
+	indent := strings.Repeat("  ", depth)
+	lines := []string{indent + "SELECT"}
+
+	// Names other than log fields: columns of the table selected FROM, and
+	// SELECT aliases, which HAVING and ORDER BY may refer to
+	names := make(map[string]string)
+	scope := astScope{tables: tables, names: names}
+	if table, exists := tables[strings.ToLower(stmt.From)]; exists {
+		for _, column := range table.Columns {
+			label := "Column " + column.Name + " (" + valueTypeName(column.Type) + ")"
+			names[column.Name] = label
+			names[table.Name+"."+column.Name] = label
+		}
+		for _, field := range sharedFields {
+			names[table.Name+"."+field] = "Field " + field
+		}
+	}
+	for _, field := range stmt.Fields {
+		if field.Alias != "" {
+			names[strings.ToLower(field.Alias)] = "Alias " + field.Alias
+		}
+	}
+
+	for _, field := range stmt.Fields {
+		fieldLines := expressionAST(field.Expression, depth+1, scope)
+		if field.Alias != "" {
+			fieldLines[0] += " AS " + field.Alias
+		}
+		lines = append(lines, fieldLines...)
+	}
+
+	lines = append(lines, indent+"FROM "+stmt.From)
+	for _, join := range stmt.Joins {
+		if join.Left {
+			lines = append(lines, indent+"LEFT JOIN "+join.Table+" ON")
+		} else {
+			lines = append(lines, indent+"JOIN "+join.Table+" ON")
+		}
+		lines = append(lines, expressionAST(join.On, depth+1, scope)...)
+	}
+
+	if stmt.Where != nil {
+		lines = append(lines, indent+"WHERE")
+		lines = append(lines, expressionAST(stmt.Where, depth+1, scope)...)
+	}
+	if len(stmt.GroupBy) > 0 {
+		lines = append(lines, indent+"GROUP BY")
+		for _, expr := range stmt.GroupBy {
+			lines = append(lines, expressionAST(expr, depth+1, scope)...)
+		}
+	}
+	if stmt.Having != nil {
+		lines = append(lines, indent+"HAVING")
+		lines = append(lines, expressionAST(stmt.Having, depth+1, scope)...)
+	}
+	if len(stmt.OrderBy) > 0 {
+		lines = append(lines, indent+"ORDER BY")
+		for _, clause := range stmt.OrderBy {
+			clauseLines := expressionAST(clause.Expression, depth+1, scope)
+			if clause.Descending {
+				clauseLines[0] += " DESC"
+			}
+			lines = append(lines, clauseLines...)
+		}
+	}
+	if stmt.Limit != nil {
+		lines = append(lines, fmt.Sprintf("%sLIMIT %d", indent, *stmt.Limit))
+	}
+	if stmt.Offset != nil {
+		lines = append(lines, fmt.Sprintf("%sOFFSET %d", indent, *stmt.Offset))
+	}
+
+	return lines
+}
+
+// expressionAST renders an expression as an indented tree, one node per line
+func expressionAST(expr Expression, depth int, scope astScope) []string {
+	indent := strings.Repeat("  ", depth)
+
+	switch expr := expr.(type) {
+	case *BinaryExpression:
+		lines := []string{indent + "Binary " + string(expr.Operator)}
+		lines = append(lines, expressionAST(expr.Left, depth+1, scope)...)
+		return append(lines, expressionAST(expr.Right, depth+1, scope)...)
+
+	case *UnaryExpression:
+		return append([]string{indent + "Unary " + string(expr.Operator)}, expressionAST(expr.Operand, depth+1, scope)...)
+
+	case *TimeShiftExpression:
+		op := "+"
+		if expr.Subtract {
+			op = "-"
+		}
+		line := fmt.Sprintf("%sInterval %s %s", indent, op, expr.Literal)
+		return append([]string{line}, expressionAST(expr.Time, depth+1, scope)...)
+
+	case *FunctionExpression:
+		line := indent + "Function " + strings.ToUpper(expr.Name)
+		if isAggregateFunction(expr.Name) {
+			line += " (aggregate)"
+		} else if _, registered := registeredFunction(expr.Name); registered {
+			line += " (user-defined)"
+		}
+		lines := []string{line}
+		for _, arg := range expr.Arguments {
+			lines = append(lines, expressionAST(arg, depth+1, scope)...)
+		}
+		return lines
+
+	case *FieldExpression:
+		name := string(expr.Field)
+		label, named := scope.names[strings.ToLower(name)]
+		switch {
+		case name == "*":
+			return []string{indent + "All fields (*)"}
+		case named:
+			return []string{indent + label}
+		case strings.Contains(name, "."):
+			return []string{indent + "Lookup column " + name}
+		case !isKnownField(expr.Field):
+			return []string{indent + "Field " + name + " ⚠ unknown field"}
+		}
+		return []string{indent + "Field " + name}
+
+	case *LiteralExpression:
+		return []string{indent + "Literal " + expr.Value.String() + " (" + valueTypeName(expr.Value.Type) + ")"}
+
+	case *SubqueryExpression:
+		return append([]string{indent + "Subquery"}, statementAST(expr.Statement, depth+1, scope.tables)...)
+	}
+
+	return []string{indent + expr.String()}
+}
+
+// valueTypeName names a value type for EXPLAIN output
+func valueTypeName(valueType ValueType) string {
+	switch valueType {
+	case ValueString:
+		return "string"
+	case ValueInt:
+		return "int"
+	case ValueFloat:
+		return "float"
+	case ValueBool:
+		return "bool"
+	case ValueTime:
+		return "time"
+	case ValueList:
+		return "list"
+	default:
+		return "unknown"
+	}
+}
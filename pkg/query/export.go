@@ -0,0 +1,160 @@
+package query
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OutputFormatForFile returns the result format matching a file extension
+func OutputFormatForFile(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "csv", nil
+	case ".json":
+		return "json", nil
+	case ".html", ".htm":
+		return "html", nil
+	default:
+		return "", fmt.Errorf("unsupported query output %q (use .csv, .json or .html)", filename)
+	}
+}
+
+// WriteResultFile writes a query result to a file, choosing CSV, JSON or HTML
+// from the file extension
+func WriteResultFile(result *QueryResult, queryStr, filename string) error {
+	format, err := OutputFormatForFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var content string
+	if format == "html" {
+		content, err = formatAsHTML(result, queryStr)
+	} else {
+		content, err = FormatResult(result, format)
+	}
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filename, []byte(content), 0644)
+}
+
+// ChartSeries extracts a label and a value per row for charting aggregate
+// results. The value is the last numeric column; the label joins the others.
+func ChartSeries(result *QueryResult) (labels []string, values []float64, valueColumn string, err error) {
+	if len(result.Rows) == 0 {
+		return nil, nil, "", fmt.Errorf("query returned no rows to chart")
+	}
+
+	valueIndex := -1
+	for i := len(result.Columns) - 1; i >= 0; i-- {
+		if isNumericColumn(result, i) {
+			valueIndex = i
+			break
+		}
+	}
+	if valueIndex < 0 || len(result.Columns) < 2 {
+		return nil, nil, "", fmt.Errorf("charts need a label column and a numeric column, e.g. SELECT url, COUNT() FROM logs GROUP BY url")
+	}
+
+	for _, row := range result.Rows {
+		var parts []string
+		for i, value := range row {
+			if i != valueIndex {
+				parts = append(parts, FormatValue(value))
+			}
+		}
+		labels = append(labels, strings.Join(parts, " / "))
+
+		value := row[valueIndex]
+		if value.Type == ValueInt {
+			values = append(values, float64(value.IntVal))
+		} else {
+			values = append(values, value.FloatVal)
+		}
+	}
+
+	return labels, values, result.Columns[valueIndex], nil
+}
+
+// isNumericColumn reports whether every value in a column is a number
+func isNumericColumn(result *QueryResult, column int) bool {
+	for _, row := range result.Rows {
+		if column >= len(row) || (row[column].Type != ValueInt && row[column].Type != ValueFloat) {
+			return false
+		}
+	}
+	return true
+}
+
+var htmlResultTemplate = template.Must(template.New("query").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Query Results</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; margin: 2rem; color: #2c3e50; }
+pre { background: #f4f6f8; padding: 0.75rem; border-radius: 4px; white-space: pre-wrap; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #dfe4ea; padding: 0.4rem 0.6rem; text-align: left; }
+th { background: #34495e; color: #fff; }
+tr:nth-child(even) { background: #f8f9fa; }
+td.number { text-align: right; font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<h1>Query Results</h1>
+{{if .Query}}<pre>{{.Query}}</pre>{{end}}
+<p>{{.Count}} rows &middot; generated {{.Generated}}</p>
+<table>
+<thead><tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if .Number}} class="number"{{end}}>{{.Text}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
+
+type htmlCell struct {
+	Text   string
+	Number bool
+}
+
+// formatAsHTML formats result as a standalone HTML page
+func formatAsHTML(result *QueryResult, queryStr string) (string, error) {
+	rows := make([][]htmlCell, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = make([]htmlCell, len(row))
+		for j, value := range row {
+			rows[i][j] = htmlCell{
+				Text:   FormatValue(value),
+				Number: value.Type == ValueInt || value.Type == ValueFloat,
+			}
+		}
+	}
+
+	var buffer bytes.Buffer
+	err := htmlResultTemplate.Execute(&buffer, map[string]interface{}{
+		"Query":     queryStr,
+		"Count":     result.Count,
+		"Generated": time.Now().Format("2006-01-02 15:04:05"),
+		"Columns":   result.Columns,
+		"Rows":      rows,
+	})
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,81 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ScalarFunction computes the value of a user-defined function call from its
+// evaluated arguments, e.g. TEAM(url) receives the URL of each entry
+type ScalarFunction func(args []Value) (Value, error)
+
+var (
+	functionRegistry   = make(map[string]ScalarFunction)
+	functionRegistryMu sync.RWMutex
+
+	functionNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// RegisterFunction makes a scalar function callable from query strings under
+// name, which is matched case-insensitively. Functions must be registered
+// before the queries that call them are parsed, and must be safe to call from
+// several queries at once. Names of built-in functions, keywords and log
+// fields cannot be registered.
+func RegisterFunction(name string, fn ScalarFunction) error {
+	if !functionNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid function name %q: use letters, digits and underscores", name)
+	}
+	if fn == nil {
+		return fmt.Errorf("function %q has no implementation", name)
+	}
+
+	upper := strings.ToUpper(name)
+	if isKnownField(QueryField(strings.ToLower(name))) {
+		return fmt.Errorf("function name %q is a log field", name)
+	}
+	if _, exists := registeredFunction(upper); !exists && (&Lexer{}).determineKeywordTokenType(upper) != TokenField {
+		return fmt.Errorf("function name %q is a built-in function or keyword", name)
+	}
+
+	functionRegistryMu.Lock()
+	defer functionRegistryMu.Unlock()
+
+	if _, exists := functionRegistry[upper]; exists {
+		return fmt.Errorf("query function %q is already registered", upper)
+	}
+	functionRegistry[upper] = fn
+	return nil
+}
+
+// MustRegisterFunction is like RegisterFunction but panics on invalid or
+// duplicate names. It is intended for use in init functions.
+func MustRegisterFunction(name string, fn ScalarFunction) {
+	if err := RegisterFunction(name, fn); err != nil {
+		panic(err)
+	}
+}
+
+// RegisteredFunctions returns the names of all user-defined functions, sorted
+func RegisteredFunctions() []string {
+	functionRegistryMu.RLock()
+	defer functionRegistryMu.RUnlock()
+
+	names := make([]string, 0, len(functionRegistry))
+	for name := range functionRegistry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registeredFunction returns the user-defined function with the given name
+func registeredFunction(name string) (ScalarFunction, bool) {
+	functionRegistryMu.RLock()
+	defer functionRegistryMu.RUnlock()
+
+	fn, exists := functionRegistry[strings.ToUpper(name)]
+	return fn, exists
+}
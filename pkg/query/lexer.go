@@ -3,7 +3,6 @@ package query
 import (
 	"strconv"
 	"strings"
-	"time"
 	"unicode"
 )
 
@@ -47,6 +46,16 @@ func (l *Lexer) NextToken() Token {
 	case ';':
 		token.Type = TokenSemicolon
 		token.Value = ";"
+	case '*':
+		// SELECT * and COUNT(*)
+		token.Type = TokenField
+		token.Value = "*"
+	case '+':
+		token.Type = TokenPlus
+		token.Value = "+"
+	case '-':
+		token.Type = TokenMinus
+		token.Value = "-"
 	case '=':
 		token.Type = TokenEquals
 		token.Value = "="
@@ -130,7 +139,8 @@ func (l *Lexer) skipWhitespace() {
 // readIdentifier reads an identifier (field name, function name, keyword)
 func (l *Lexer) readIdentifier() string {
 	position := l.position - 1
-	for unicode.IsLetter(l.current) || unicode.IsDigit(l.current) || l.current == '_' {
+	for unicode.IsLetter(l.current) || unicode.IsDigit(l.current) || l.current == '_' ||
+		(l.current == '.' && (unicode.IsLetter(l.peekChar()) || l.peekChar() == '_')) { // table.column
 		l.readChar()
 	}
 	l.position-- // Step back one character
@@ -170,7 +180,13 @@ func (l *Lexer) determineKeywordTokenType(literal string) TokenType {
 		"ORDER":       TokenOrder,
 		"HAVING":      TokenHaving,
 		"LIMIT":       TokenLimit,
+		"OFFSET":      TokenOffset,
+		"INTERVAL":    TokenInterval,
 		"AS":          TokenAs,
+		"JOIN":        TokenJoin,
+		"LEFT":        TokenLeft,
+		"ON":          TokenOn,
+		"EXPLAIN":     TokenExplain,
 		"AND":         TokenAnd,
 		"OR":          TokenOr,
 		"NOT":         TokenNot,
@@ -221,6 +237,7 @@ func (l *Lexer) determineKeywordTokenType(literal string) TokenType {
 		"DAY":           true,
 		"WEEKDAY":       true,
 		"DATE":          true,
+		"DATE_TRUNC":    true,
 		"TIME_DIFF":     true,
 		"UPPER":         true,
 		"LOWER":         true,
@@ -229,11 +246,15 @@ func (l *Lexer) determineKeywordTokenType(literal string) TokenType {
 		"IP_TO_INT":     true,
 		"IS_PRIVATE_IP": true,
 		"COUNTRY":       true,
+		"NOW":           true,
 	}
 
 	if _, ok := functions[upper]; ok {
 		return TokenFunction
 	}
+	if _, ok := registeredFunction(upper); ok {
+		return TokenFunction
+	}
 
 	return TokenField // Default to field for unknown identifiers
 }
@@ -247,18 +268,8 @@ func (l *Lexer) determineStringTokenType(literal string) TokenType {
 	}
 
 	// Check if it's a date/time
-	dateFormats := []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02",
-		"15:04:05",
-		"2006/01/02 15:04:05",
-		"2006/01/02",
-	}
-
-	for _, format := range dateFormats {
-		if _, err := time.Parse(format, literal); err == nil {
-			return TokenDate
-		}
+	if _, ok := parseTimeLiteral(literal); ok {
+		return TokenDate
 	}
 
 	// Check if it's a number
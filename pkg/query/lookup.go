@@ -0,0 +1,394 @@
+package query
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// LookupTable is a user-supplied table, such as IP → customer or path →
+// service owner, that queries can JOIN against the logs
+type LookupTable struct {
+	Name    string
+	Columns []string
+	Rows    []map[string]Value
+}
+
+// ParseLookupSpec parses a lookup definition of the form "name=file"
+func ParseLookupSpec(spec string) (name, filename string, err error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+		return "", "", fmt.Errorf("invalid lookup %q (expected name=file.csv or name=file.json)", spec)
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), nil
+}
+
+// LoadLookupTable reads a lookup table from a CSV file with a header row, or
+// from a JSON file holding either an array of objects or an object keyed by
+// the lookup key (stored in the "key" column)
+func LoadLookupTable(name, filename string) (*LookupTable, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lookup %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	table := &LookupTable{Name: name}
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		err = table.readJSON(file)
+	} else {
+		err = table.readCSV(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lookup %s: %w", filename, err)
+	}
+
+	return table, nil
+}
+
+// readCSV loads rows from CSV, taking column names from the header row
+func (t *LookupTable) readCSV(reader io.Reader) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return fmt.Errorf("missing header row: %w", err)
+	}
+	for _, column := range header {
+		t.Columns = append(t.Columns, strings.ToLower(strings.TrimSpace(column)))
+	}
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		row := make(map[string]Value, len(t.Columns))
+		for i, column := range t.Columns {
+			field := ""
+			if i < len(record) {
+				field = strings.TrimSpace(record[i])
+			}
+			row[column] = lookupValue(field)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+
+	return nil
+}
+
+// readJSON loads rows from a JSON array of objects or an object of objects
+func (t *LookupTable) readJSON(reader io.Reader) error {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return err
+	}
+
+	var objects []map[string]interface{}
+	switch document := document.(type) {
+	case []interface{}:
+		for _, item := range document {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("expected an array of objects")
+			}
+			objects = append(objects, object)
+		}
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(document))
+		for key := range document {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			object := map[string]interface{}{"key": key}
+			switch value := document[key].(type) {
+			case map[string]interface{}:
+				for column, field := range value {
+					object[column] = field
+				}
+			default:
+				object["value"] = value
+			}
+			objects = append(objects, object)
+		}
+
+	default:
+		return fmt.Errorf("expected an array or object")
+	}
+
+	seen := make(map[string]bool)
+	for _, object := range objects {
+		row := make(map[string]Value, len(object))
+		for column, field := range object {
+			column = strings.ToLower(column)
+			if !seen[column] {
+				seen[column] = true
+				t.Columns = append(t.Columns, column)
+			}
+			if field == nil {
+				field = ""
+			}
+			row[column] = lookupValue(fmt.Sprint(field))
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	sort.Strings(t.Columns)
+
+	return nil
+}
+
+// lookupValue converts a lookup field to a number when it looks like one, so
+// lookup columns can be compared with numeric operators
+func lookupValue(field string) Value {
+	// Keep values such as "007" as strings so they still match log fields
+	if i, err := strconv.ParseInt(field, 10, 64); err == nil && strconv.FormatInt(i, 10) == field {
+		return Value{Type: ValueInt, IntVal: i}
+	}
+	if f, err := strconv.ParseFloat(field, 64); err == nil && strings.Contains(field, ".") {
+		return Value{Type: ValueFloat, FloatVal: f}
+	}
+	if field == "true" || field == "false" {
+		return Value{Type: ValueBool, BoolVal: field == "true"}
+	}
+	return Value{Type: ValueString, StringVal: field}
+}
+
+// hasColumn reports whether the table has a column
+func (t *LookupTable) hasColumn(column string) bool {
+	for _, c := range t.Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// index maps each value of column to the first row containing it
+func (t *LookupTable) index(column string) map[string]map[string]Value {
+	index := make(map[string]map[string]Value, len(t.Rows))
+	for _, row := range t.Rows {
+		key := FormatValue(row[column])
+		if _, exists := index[key]; !exists {
+			index[key] = row
+		}
+	}
+	return index
+}
+
+// resolvedJoin is a JOIN whose lookup table has been indexed by the join column
+type resolvedJoin struct {
+	table *LookupTable
+	key   Expression // Log expression matched against the lookup column
+	index map[string]map[string]Value
+	left  bool
+}
+
+// row returns the lookup row matching a log entry, or nil
+func (j *resolvedJoin) row(entry *parser.LogEntry) map[string]Value {
+	key, err := j.key.Evaluate(entry)
+	if err != nil {
+		return nil
+	}
+	return j.index[FormatValue(key)]
+}
+
+// filter keeps the log entries that have a matching lookup row
+func (j *resolvedJoin) filter(logs []*parser.LogEntry) []*parser.LogEntry {
+	var matched []*parser.LogEntry
+	for _, entry := range logs {
+		if j.row(entry) != nil {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// resolveJoins indexes the lookup table of every JOIN in stmt. The ON
+// condition must compare a log expression with a lookup column for equality.
+func (e *Executor) resolveJoins(stmt *SelectStatement) (map[string]*resolvedJoin, error) {
+	joins := make(map[string]*resolvedJoin)
+	for _, join := range stmt.Joins {
+		name := strings.ToLower(join.Table)
+		table, exists := e.lookups[name]
+		if !exists {
+			// Other query tables can be joined like lookups, by their first matching row
+			source, found := e.tables[name]
+			if !found {
+				return nil, fmt.Errorf("unknown lookup table %q", join.Table)
+			}
+			var err error
+			if table, err = source.lookupTable(); err != nil {
+				return nil, fmt.Errorf("table %s: %w", name, err)
+			}
+		}
+
+		condition, ok := join.On.(*BinaryExpression)
+		if !ok || condition.Operator != OpEquals {
+			return nil, fmt.Errorf("JOIN %s ON must compare a log field with a lookup column using =", join.Table)
+		}
+
+		key, column := condition.Left, lookupColumn(condition.Right, name)
+		if column == "" {
+			key, column = condition.Right, lookupColumn(condition.Left, name)
+		}
+		if column == "" {
+			return nil, fmt.Errorf("JOIN %s ON must reference a column of %s, e.g. %s.%s", join.Table, join.Table, join.Table, firstColumn(table))
+		}
+		if !table.hasColumn(column) {
+			return nil, fmt.Errorf("lookup table %q has no column %q (columns: %s)", join.Table, column, strings.Join(table.Columns, ", "))
+		}
+		if lookupColumn(key, name) != "" {
+			return nil, fmt.Errorf("JOIN %s ON must compare the lookup column with a log field", join.Table)
+		}
+
+		joins[name] = &resolvedJoin{
+			table: table,
+			key:   key,
+			index: table.index(column),
+			left:  join.Left,
+		}
+	}
+
+	from, err := e.table(stmt.From)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve keys after all tables are known so a key may use an earlier join
+	for _, join := range joins {
+		key := join.key
+		if from != nil {
+			if key, err = resolveTableFields(key, from); err != nil {
+				return nil, err
+			}
+		}
+		if key, err = resolveLookupFields(key, joins); err != nil {
+			return nil, err
+		}
+		join.key = key
+	}
+
+	return joins, nil
+}
+
+// lookupColumn returns the column of a table.column reference to table, or ""
+func lookupColumn(expr Expression, table string) string {
+	field, ok := expr.(*FieldExpression)
+	if !ok {
+		return ""
+	}
+	name := strings.ToLower(string(field.Field))
+	if !strings.HasPrefix(name, table+".") {
+		return ""
+	}
+	return strings.TrimPrefix(name, table+".")
+}
+
+// firstColumn returns the first column of a table for error messages
+func firstColumn(table *LookupTable) string {
+	if len(table.Columns) == 0 {
+		return "column"
+	}
+	return table.Columns[0]
+}
+
+// resolveLookupFields replaces table.column references in expr with reads
+// from the joined lookup tables
+func resolveLookupFields(expr Expression, joins map[string]*resolvedJoin) (Expression, error) {
+	switch expr := expr.(type) {
+	case *FieldExpression:
+		name := string(expr.Field)
+		dot := strings.Index(name, ".")
+		if dot < 0 {
+			return expr, nil
+		}
+
+		table, column := strings.ToLower(name[:dot]), strings.ToLower(name[dot+1:])
+		join, exists := joins[table]
+		if !exists {
+			return nil, fmt.Errorf("unknown table %q in %s (JOIN it first)", name[:dot], name)
+		}
+		if !join.table.hasColumn(column) {
+			return nil, fmt.Errorf("lookup table %q has no column %q (columns: %s)", name[:dot], column, strings.Join(join.table.Columns, ", "))
+		}
+		return &lookupFieldExpression{Name: name, Column: column, join: join}, nil
+
+	case *BinaryExpression:
+		left, err := resolveLookupFields(expr.Left, joins)
+		if err != nil {
+			return nil, err
+		}
+		right, err := resolveLookupFields(expr.Right, joins)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpression{Left: left, Operator: expr.Operator, Right: right}, nil
+
+	case *UnaryExpression:
+		operand, err := resolveLookupFields(expr.Operand, joins)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpression{Operator: expr.Operator, Operand: operand}, nil
+
+	case *TimeShiftExpression:
+		shifted, err := resolveLookupFields(expr.Time, joins)
+		if err != nil {
+			return nil, err
+		}
+		return &TimeShiftExpression{Time: shifted, Subtract: expr.Subtract, Interval: expr.Interval, Literal: expr.Literal}, nil
+
+	case *FunctionExpression:
+		args := make([]Expression, len(expr.Arguments))
+		for i, arg := range expr.Arguments {
+			resolved, err := resolveLookupFields(arg, joins)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = resolved
+		}
+		return &FunctionExpression{Name: expr.Name, Arguments: args}, nil
+	}
+
+	return expr, nil
+}
+
+// lookupFieldExpression reads a lookup column for the row joined to a log
+// entry. Entries without a row (LEFT JOIN) read an empty string.
+type lookupFieldExpression struct {
+	Name   string // As written in the query, e.g. customers.name
+	Column string
+	join   *resolvedJoin
+}
+
+func (lf lookupFieldExpression) String() string {
+	return lf.Name
+}
+
+func (lf lookupFieldExpression) Evaluate(entry *parser.LogEntry) (Value, error) {
+	row := lf.join.row(entry)
+	if row == nil {
+		return Value{Type: ValueString, StringVal: ""}, nil
+	}
+	return row[lf.Column], nil
+}
@@ -0,0 +1,159 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// QueryParameters returns the names of the :name placeholders in a query, in
+// order of first use. Placeholders inside quoted strings are ignored.
+func QueryParameters(queryStr string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	scanParameters(queryStr, func(name string) string {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+		return ":" + name
+	})
+	return names
+}
+
+// BindParameters replaces the :name placeholders in a query with quoted
+// literals taken from params. Values are always bound as literals, so they
+// cannot change the structure of the query; numeric values still compare as
+// numbers. Every placeholder must have a value.
+func BindParameters(queryStr string, params map[string]string) (string, error) {
+	var missing []string
+	var bindErr error
+
+	bound := scanParameters(queryStr, func(name string) string {
+		value, exists := params[name]
+		if !exists {
+			missing = append(missing, name)
+			return ":" + name
+		}
+
+		literal, err := quoteParameter(value)
+		if err != nil && bindErr == nil {
+			bindErr = fmt.Errorf("parameter :%s: %w", name, err)
+		}
+		return literal
+	})
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "", fmt.Errorf("missing value for parameter(s) %s", ":"+strings.Join(unique(missing), ", :"))
+	}
+	if bindErr != nil {
+		return "", bindErr
+	}
+	return bound, nil
+}
+
+// ValidateParameterizedQuery checks that a query with placeholders parses.
+// Placeholders without a value in params are checked with a sample literal.
+func ValidateParameterizedQuery(queryStr string, params map[string]string) error {
+	sample := make(map[string]string)
+	for _, name := range QueryParameters(queryStr) {
+		if value, exists := params[name]; exists {
+			sample[name] = value
+		} else {
+			sample[name] = "0"
+		}
+	}
+
+	bound, err := BindParameters(queryStr, sample)
+	if err != nil {
+		return err
+	}
+	_, err = ParseQuery(bound)
+	return err
+}
+
+// ParseParameterSpec parses a parameter assignment of the form "name=value"
+func ParseParameterSpec(spec string) (name, value string, err error) {
+	parts := strings.SplitN(spec, "=", 2)
+	name = strings.TrimPrefix(strings.TrimSpace(parts[0]), ":")
+	if len(parts) != 2 || !isParameterName(name) {
+		return "", "", fmt.Errorf("invalid parameter %q (expected name=value)", spec)
+	}
+	return name, parts[1], nil
+}
+
+// scanParameters rewrites each placeholder outside quoted strings with replace
+func scanParameters(queryStr string, replace func(name string) string) string {
+	var builder strings.Builder
+	var quote rune
+
+	runes := []rune(queryStr)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ':' && i+1 < len(runes) && isParameterStart(runes[i+1]):
+			end := i + 1
+			for end < len(runes) && isParameterPart(runes[end]) {
+				end++
+			}
+			builder.WriteString(replace(string(runes[i+1 : end])))
+			i = end - 1
+			continue
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+// quoteParameter quotes a value as a string literal. The lexer has no escape
+// sequences, so values containing both quote characters cannot be bound.
+func quoteParameter(value string) (string, error) {
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'", nil
+	}
+	if !strings.Contains(value, `"`) {
+		return `"` + value + `"`, nil
+	}
+	return "", fmt.Errorf("value cannot contain both ' and \" quotes")
+}
+
+func isParameterName(name string) bool {
+	if name == "" || !isParameterStart([]rune(name)[0]) {
+		return false
+	}
+	for _, r := range name {
+		if !isParameterPart(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isParameterStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+func isParameterPart(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// unique removes adjacent duplicates from a sorted slice
+func unique(values []string) []string {
+	var result []string
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
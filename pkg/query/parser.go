@@ -11,6 +11,7 @@ type Parser struct {
 	tokens   []Token
 	current  int
 	position int
+	depth    int // Nesting level of the statement being parsed, 0 outside subqueries
 }
 
 // NewParser creates a new parser instance
@@ -29,6 +30,17 @@ func (p *Parser) ParseQuery() (*SelectStatement, error) {
 		return nil, err
 	}
 
+	// EXPLAIN SELECT ... describes the plan of the statement that follows
+	if p.expectToken(TokenExplain) {
+		p.advance()
+		stmt, err := p.parseSelectStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Explain = true
+		return stmt, nil
+	}
+
 	return p.parseSelectStatement()
 }
 
@@ -60,8 +72,21 @@ func (p *Parser) parseSelectStatement() (*SelectStatement, error) {
 	stmt.From = p.currentToken().Value
 	p.advance()
 
-	// Parse optional clauses
+	// Parse JOINs against lookup tables
+	for p.expectToken(TokenJoin) || p.expectToken(TokenLeft) {
+		join, err := p.parseJoinClause()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Joins = append(stmt.Joins, join)
+	}
+
+	// Parse optional clauses; a subquery ends at its closing parenthesis
 	for !p.isAtEnd() && p.currentToken().Type != TokenEOF {
+		if p.depth > 0 && p.currentToken().Type == TokenRightParen {
+			break
+		}
+
 		switch p.currentToken().Type {
 		case TokenWhere:
 			p.advance()
@@ -115,6 +140,18 @@ func (p *Parser) parseSelectStatement() (*SelectStatement, error) {
 			stmt.Limit = &limit
 			p.advance()
 
+		case TokenOffset:
+			p.advance()
+			if !p.expectToken(TokenNumber) {
+				return nil, p.error("Expected number after OFFSET")
+			}
+			offset, err := strconv.ParseInt(p.currentToken().Value, 10, 64)
+			if err != nil {
+				return nil, p.error("Invalid OFFSET value")
+			}
+			stmt.Offset = &offset
+			p.advance()
+
 		default:
 			return nil, p.error("Unexpected token: " + p.currentToken().Value)
 		}
@@ -123,6 +160,38 @@ func (p *Parser) parseSelectStatement() (*SelectStatement, error) {
 	return stmt, nil
 }
 
+// parseJoinClause parses [LEFT] JOIN table ON condition
+func (p *Parser) parseJoinClause() (JoinClause, error) {
+	join := JoinClause{}
+	if p.expectToken(TokenLeft) {
+		join.Left = true
+		p.advance()
+		if !p.expectToken(TokenJoin) {
+			return JoinClause{}, p.error("Expected JOIN after LEFT")
+		}
+	}
+	p.advance()
+
+	if !p.expectToken(TokenField) {
+		return JoinClause{}, p.error("Expected lookup table name after JOIN")
+	}
+	join.Table = p.currentToken().Value
+	p.advance()
+
+	if !p.expectToken(TokenOn) {
+		return JoinClause{}, p.error("Expected ON after JOIN " + join.Table)
+	}
+	p.advance()
+
+	on, err := p.parseExpression()
+	if err != nil {
+		return JoinClause{}, err
+	}
+	join.On = on
+
+	return join, nil
+}
+
 // parseSelectFields parses the field list in SELECT clause
 func (p *Parser) parseSelectFields() ([]SelectField, error) {
 	var fields []SelectField
@@ -282,7 +351,7 @@ func (p *Parser) parseComparison() (Expression, error) {
 		return &UnaryExpression{Operator: OpNot, Operand: operand}, nil
 	}
 
-	left, err := p.parsePrimary()
+	left, err := p.parseAdditive()
 	if err != nil {
 		return nil, err
 	}
@@ -301,10 +370,16 @@ func (p *Parser) parseComparison() (Expression, error) {
 			// These are unary operators applied to fields
 			return &UnaryExpression{Operator: op, Operand: left}, nil
 		default:
-			right, err := p.parsePrimary()
+			position := p.currentToken().Position
+			right, err := p.parseAdditive()
 			if err != nil {
 				return nil, err
 			}
+			if op == OpMatches {
+				if err := validateRegexLiteral(right, position); err != nil {
+					return nil, err
+				}
+			}
 			return &BinaryExpression{Left: left, Operator: op, Right: right}, nil
 		}
 	}
@@ -312,9 +387,41 @@ func (p *Parser) parseComparison() (Expression, error) {
 	return left, nil
 }
 
+// parseAdditive parses a value followed by any number of "+ INTERVAL '...'"
+// or "- INTERVAL '...'" time shifts
+func (p *Parser) parseAdditive() (Expression, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.expectToken(TokenPlus) || p.expectToken(TokenMinus) {
+		subtract := p.expectToken(TokenMinus)
+		p.advance()
+		if !p.expectToken(TokenInterval) {
+			return nil, p.error("Expected INTERVAL after '+' or '-'")
+		}
+		p.advance()
+
+		token := p.currentToken()
+		if token.Type != TokenString && token.Type != TokenNumber {
+			return nil, p.error("Expected quoted interval after INTERVAL, e.g. INTERVAL '24h'")
+		}
+		interval, err := parseInterval(token.Value)
+		if err != nil {
+			return nil, p.error(err.Error())
+		}
+		p.advance()
+
+		expr = &TimeShiftExpression{Time: expr, Subtract: subtract, Interval: interval, Literal: token.Value}
+	}
+
+	return expr, nil
+}
+
 // parseBetweenExpression parses BETWEEN expressions
 func (p *Parser) parseBetweenExpression(left Expression) (Expression, error) {
-	min, err := p.parsePrimary()
+	min, err := p.parseAdditive()
 	if err != nil {
 		return nil, err
 	}
@@ -324,7 +431,7 @@ func (p *Parser) parseBetweenExpression(left Expression) (Expression, error) {
 	}
 	p.advance()
 
-	max, err := p.parsePrimary()
+	max, err := p.parseAdditive()
 	if err != nil {
 		return nil, err
 	}
@@ -342,6 +449,10 @@ func (p *Parser) parseInExpression(left Expression) (Expression, error) {
 	}
 	p.advance()
 
+	if p.expectToken(TokenSelect) {
+		return p.parseInSubquery(left)
+	}
+
 	var values []Value
 	for {
 		value, err := p.parseLiteral()
@@ -349,6 +460,7 @@ func (p *Parser) parseInExpression(left Expression) (Expression, error) {
 			return nil, err
 		}
 		values = append(values, value)
+		p.advance()
 
 		if p.currentToken().Type == TokenRightParen {
 			break
@@ -372,6 +484,27 @@ func (p *Parser) parseInExpression(left Expression) (Expression, error) {
 	}, nil
 }
 
+// parseInSubquery parses the SELECT of an IN (SELECT ...) expression
+func (p *Parser) parseInSubquery(left Expression) (Expression, error) {
+	p.depth++
+	stmt, err := p.parseSelectStatement()
+	p.depth--
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.expectToken(TokenRightParen) {
+		return nil, p.error("Expected ')' after subquery")
+	}
+	p.advance()
+
+	return &BinaryExpression{
+		Left:     left,
+		Operator: OpIn,
+		Right:    &SubqueryExpression{Statement: stmt},
+	}, nil
+}
+
 // parsePrimary parses primary expressions (fields, literals, functions, parentheses)
 func (p *Parser) parsePrimary() (Expression, error) {
 	token := p.currentToken()
@@ -444,6 +577,15 @@ func (p *Parser) parseFunctionCall() (Expression, error) {
 	}
 	p.advance()
 
+	// Reject unknown DATE_TRUNC units up front rather than returning empty buckets
+	if strings.ToUpper(funcName) == "DATE_TRUNC" && len(args) > 0 {
+		if unit, ok := args[0].(*LiteralExpression); ok && unit.Value.Type == ValueString {
+			if _, err := truncateTime(time.Now(), unit.Value.StringVal); err != nil {
+				return nil, p.error(err.Error())
+			}
+		}
+	}
+
 	return &FunctionExpression{Name: funcName, Arguments: args}, nil
 }
 
@@ -475,16 +617,8 @@ func (p *Parser) parseLiteral() (Value, error) {
 		return Value{Type: ValueBool, BoolVal: val}, nil
 
 	case TokenDate:
-		// Try multiple date formats
-		formats := []string{
-			"2006-01-02 15:04:05",
-			"2006-01-02",
-			"15:04:05",
-		}
-		for _, format := range formats {
-			if t, err := time.Parse(format, token.Value); err == nil {
-				return Value{Type: ValueTime, TimeVal: t}, nil
-			}
+		if t, ok := parseTimeLiteral(token.Value); ok {
+			return Value{Type: ValueTime, TimeVal: t}, nil
 		}
 		return Value{}, p.error("Invalid date format: " + token.Value)
 
@@ -493,6 +627,19 @@ func (p *Parser) parseLiteral() (Value, error) {
 	}
 }
 
+// validateRegexLiteral reports invalid MATCHES patterns when the query is
+// parsed instead of silently matching nothing
+func validateRegexLiteral(expr Expression, position int) error {
+	literal, ok := expr.(*LiteralExpression)
+	if !ok || literal.Value.Type != ValueString {
+		return nil
+	}
+	if _, err := compileRegex(literal.Value.StringVal); err != nil {
+		return NewQueryError("Invalid regular expression: "+err.Error(), position, "parser")
+	}
+	return nil
+}
+
 // Helper methods
 func (p *Parser) currentToken() Token {
 	if p.current >= len(p.tokens) {
@@ -563,6 +710,11 @@ func (p *Parser) tokenToField(value string) QueryField {
 		"*":          "*", // Special case for SELECT *
 	}
 	
+	// logs.ip is the same as ip when lookup tables are joined
+	if strings.HasPrefix(strings.ToLower(value), "logs.") {
+		value = value[len("logs."):]
+	}
+
 	if field, ok := mapping[strings.ToUpper(value)]; ok {
 		return field
 	}
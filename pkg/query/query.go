@@ -9,7 +9,10 @@ import (
 
 // QueryEngine provides a high-level interface for executing queries
 type QueryEngine struct {
-	logs []*parser.LogEntry
+	logs      []*parser.LogEntry
+	lookups   map[string]*LookupTable
+	tables    map[string]*Table
+	maxGroups int
 }
 
 // NewQueryEngine creates a new query engine
@@ -27,9 +30,62 @@ func (qe *QueryEngine) Query(queryStr string, format string) (string, error) {
 	return FormatResult(result, format)
 }
 
+// AddLookupTable makes a lookup table available to JOINs under its name
+func (qe *QueryEngine) AddLookupTable(table *LookupTable) {
+	if qe.lookups == nil {
+		qe.lookups = make(map[string]*LookupTable)
+	}
+	qe.lookups[strings.ToLower(table.Name)] = table
+}
+
+// AddTable makes a table available to FROM and JOIN under its name
+func (qe *QueryEngine) AddTable(table *Table) {
+	if qe.tables == nil {
+		qe.tables = make(map[string]*Table)
+	}
+	qe.tables[table.Name] = table
+}
+
+// SetMaxGroups sets how many GROUP BY groups are kept in memory before their
+// state spills to temporary files (DefaultMaxGroups when not set)
+func (qe *QueryEngine) SetMaxGroups(maxGroups int) {
+	qe.maxGroups = maxGroups
+}
+
 // ExecuteQuery executes a query string and returns raw results
 func (qe *QueryEngine) ExecuteQuery(queryStr string) (*QueryResult, error) {
-	return ExecuteQuery(queryStr, qe.logs)
+	stmt, err := ParseQuery(queryStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	return qe.executeStatement(stmt)
+}
+
+// executeStatement runs a parsed statement against the engine's logs
+func (qe *QueryEngine) executeStatement(stmt *SelectStatement) (*QueryResult, error) {
+	executor := NewExecutor(qe.logs)
+	executor.SetLookupTables(qe.lookups)
+	executor.SetTables(qe.tables)
+	executor.SetMaxGroups(qe.maxGroups)
+	return executor.Execute(stmt)
+}
+
+// NewStream parses a query and prepares it to run over entries passed one at
+// a time with Stream.Add, for inputs too large to load into memory. The
+// query must select FROM logs, and IN subqueries may not read logs.
+func (qe *QueryEngine) NewStream(queryStr string) (*Stream, error) {
+	stmt, err := ParseQuery(queryStr)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+
+	executor := &Executor{lookups: qe.lookups, tables: qe.tables, streaming: true, maxGroups: qe.maxGroups}
+	if table, err := executor.table(stmt.From); err != nil {
+		return nil, err
+	} else if table != nil {
+		return nil, fmt.Errorf("FROM %s reads a query table rather than the log stream; run it without --stream", table.Name)
+	}
+	return executor.NewStream(stmt)
 }
 
 // ValidateQuery validates a query without executing it
@@ -52,7 +108,7 @@ func (qe *QueryEngine) GetAvailableFunctions() []string {
 		// Aggregate functions
 		"COUNT", "SUM", "AVG", "MIN", "MAX",
 		// Time functions
-		"HOUR", "DAY", "WEEKDAY", "DATE", "TIME_DIFF",
+		"HOUR", "DAY", "WEEKDAY", "DATE", "DATE_TRUNC", "NOW", "TIME_DIFF",
 		// String functions
 		"UPPER", "LOWER", "LENGTH", "SUBSTR",
 		// Network functions
@@ -144,6 +200,7 @@ type QueryBuilder struct {
 	orderByFields []string
 	havingClause string
 	limitValue   *int64
+	offsetValue  *int64
 }
 
 // NewQueryBuilder creates a new query builder
@@ -209,6 +266,12 @@ func (qb *QueryBuilder) Limit(limit int64) *QueryBuilder {
 	return qb
 }
 
+// Offset sets the OFFSET value
+func (qb *QueryBuilder) Offset(offset int64) *QueryBuilder {
+	qb.offsetValue = &offset
+	return qb
+}
+
 // Build constructs the final query string
 func (qb *QueryBuilder) Build() string {
 	var query strings.Builder
@@ -249,6 +312,11 @@ func (qb *QueryBuilder) Build() string {
 		query.WriteString(fmt.Sprintf(" LIMIT %d", *qb.limitValue))
 	}
 
+	// OFFSET clause
+	if qb.offsetValue != nil {
+		query.WriteString(fmt.Sprintf(" OFFSET %d", *qb.offsetValue))
+	}
+
 	return query.String()
 }
 
@@ -381,7 +449,10 @@ func (qh *QueryHelper) ValidateFunctionName(function string) bool {
 		"count": true, "sum": true, "avg": true, "min": true, "max": true,
 		"hour": true, "day": true, "weekday": true, "date": true,
 		"upper": true, "lower": true, "length": true, "substr": true,
-		"is_private_ip": true, "country": true,
+		"is_private_ip": true, "country": true, "now": true,
+	}
+	if _, registered := registeredFunction(function); registered {
+		return true
 	}
 	return validFunctions[strings.ToLower(function)]
 }
@@ -392,7 +463,9 @@ func (qh *QueryHelper) SuggestCorrection(err error) string {
 
 	suggestions := map[string]string{
 		"unknown field":    "Available fields: ip, timestamp, method, url, protocol, status, size, referer, user_agent",
-		"unknown function": "Available functions: COUNT, SUM, AVG, MIN, MAX, HOUR, DAY, UPPER, LOWER, etc.",
+		"unknown table":    "Available tables: logs, errors (with --error-log), sessions, threats",
+		"unknown function": "Available functions: COUNT, SUM, AVG, MIN, MAX, HOUR, DAY, NOW, UPPER, LOWER, etc.",
+		"interval":         "Intervals are quoted durations: INTERVAL '30m', '24h', '7d' or '2 hours', added to or subtracted from a time",
 		"syntax error":     "Check for missing quotes, parentheses, or keywords like SELECT, FROM, WHERE",
 		"invalid operator": "Available operators: =, !=, <, >, LIKE, CONTAINS, IN, BETWEEN, IS_BOT, etc.",
 	}
@@ -0,0 +1,786 @@
+package query
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// DefaultMaxGroups is the number of GROUP BY groups a stream keeps in memory
+// before spilling their aggregate state to temporary files
+const DefaultMaxGroups = 100000
+
+// spillPartitions is the number of files spilled groups are hashed into, so
+// each file can be merged on its own
+const spillPartitions = 16
+
+// selectAllColumns are the columns returned by SELECT *
+var selectAllColumns = []string{"IP", "Timestamp", "Method", "URL", "Protocol", "Status", "Size", "Referer", "UserAgent"}
+
+// Stream executes a query over log entries passed one at a time. Only the
+// selected rows and one aggregate state per group are kept, so the entries
+// themselves never need to be held in memory. When a GROUP BY produces more
+// groups than the configured maximum, group state spills to temporary files
+// and is merged one partition at a time.
+type Stream struct {
+	executor *Executor
+	stmt     *SelectStatement
+	joins    []*resolvedJoin // Inner joins, which drop entries without a row
+
+	table *Table // Table selected FROM, nil for logs
+
+	// Plain SELECT
+	selectAll bool
+	rows      []streamRow
+
+	// GROUP BY
+	aggregates     []*FunctionExpression
+	aggregateIndex map[string]int // Aggregate expression string → index in aggregates
+	groups         map[string]*groupState
+	maxGroups      int
+	spill          *groupSpill
+	sortColumns    []string     // Columns ORDER BY sorts grouped rows on, including hidden keys
+	hiddenKeys     []Expression // ORDER BY keys that are not selected, appended to each grouped row
+
+	// EXPLAIN only samples entries and describes the plan
+	sample *explainSample
+
+	err error
+}
+
+// streamRow is a selected row with the ORDER BY keys of its entry
+type streamRow struct {
+	values []Value
+	keys   []Value
+	keyErr []bool
+}
+
+// groupState is the running aggregate state of one group
+type groupState struct {
+	Key        string
+	KeyValues  []Value
+	First      parser.LogEntry // Non-aggregate expressions read the group's first entry
+	Aggregates []aggregateState
+}
+
+// aggregateState accumulates one aggregate function over a group
+type aggregateState struct {
+	Count    int64 // Entries for COUNT, numeric values for AVG
+	Sum      float64
+	First    Value // MIN and MAX start from the first entry's value
+	FirstErr bool
+	Best     Value
+	HasBest  bool
+}
+
+// NewStream prepares stmt to run over entries passed to Add. IN subqueries
+// are run against the executor's logs, so they need an executor created with
+// NewExecutor.
+func (e *Executor) NewStream(stmt *SelectStatement) (*Stream, error) {
+	source := stmt
+
+	// Resolve lookup columns and run subqueries first so WHERE and HAVING
+	// only see their values
+	joins, err := e.resolveJoins(stmt)
+	if err != nil {
+		return nil, err
+	}
+	stmt, err = e.resolveStatement(stmt, joins)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.Having != nil && len(stmt.GroupBy) == 0 {
+		return nil, fmt.Errorf("HAVING requires GROUP BY")
+	}
+
+	table, err := e.table(stmt.From)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Stream{
+		executor:  e,
+		stmt:      stmt,
+		table:     table,
+		selectAll: len(stmt.Fields) == 1 && stmt.Fields[0].Expression.String() == "*",
+		maxGroups: DefaultMaxGroups,
+	}
+	s.SetMaxGroups(e.maxGroups)
+	if stmt.Explain {
+		s.sample = newExplainSample(source, joins)
+	}
+	for _, join := range joins {
+		if !join.left {
+			s.joins = append(s.joins, join)
+		}
+	}
+
+	// Aggregates without GROUP BY fold every entry into a single group
+	if len(stmt.GroupBy) > 0 || hasAggregateField(stmt.Fields) {
+		s.groups = make(map[string]*groupState)
+		s.aggregateIndex = make(map[string]int)
+		for _, field := range stmt.Fields {
+			if !e.isGroupByExpression(field.Expression, stmt.GroupBy) {
+				s.collectAggregates(field.Expression, false)
+			}
+		}
+		if stmt.Having != nil {
+			s.collectAggregates(stmt.Having, true)
+		}
+
+		// ORDER BY keys that are not selected, such as SUM(size), are
+		// computed for each group and dropped once the rows are sorted
+		s.sortColumns = s.Columns()
+		for _, clause := range stmt.OrderBy {
+			name := clause.Expression.String()
+			if e.findColumnIndex(s.sortColumns, name) == -1 {
+				s.collectAggregates(clause.Expression, true)
+				s.sortColumns = append(s.sortColumns, name)
+				s.hiddenKeys = append(s.hiddenKeys, clause.Expression)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// hasAggregateField reports whether any SELECT field is an aggregate function
+func hasAggregateField(fields []SelectField) bool {
+	for _, field := range fields {
+		if function, ok := field.Expression.(*FunctionExpression); ok && isAggregateFunction(function.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaxGroups sets how many groups are kept in memory before spilling
+func (s *Stream) SetMaxGroups(maxGroups int) {
+	if maxGroups > 0 {
+		s.maxGroups = maxGroups
+	}
+}
+
+// collectAggregates registers the aggregate functions an expression needs.
+// SELECT fields only aggregate at the top level; HAVING may nest them.
+func (s *Stream) collectAggregates(expr Expression, nested bool) {
+	switch expr := expr.(type) {
+	case *FunctionExpression:
+		if isAggregateFunction(expr.Name) {
+			if _, exists := s.aggregateIndex[expr.String()]; !exists {
+				s.aggregateIndex[expr.String()] = len(s.aggregates)
+				s.aggregates = append(s.aggregates, expr)
+			}
+		}
+	case *BinaryExpression:
+		if nested {
+			s.collectAggregates(expr.Left, true)
+			s.collectAggregates(expr.Right, true)
+		}
+	case *UnaryExpression:
+		if nested {
+			s.collectAggregates(expr.Operand, true)
+		}
+	case *TimeShiftExpression:
+		if nested {
+			s.collectAggregates(expr.Time, true)
+		}
+	}
+}
+
+// Done reports whether further entries can no longer change the result, as
+// with a LIMIT without ORDER BY that has been reached
+func (s *Stream) Done() bool {
+	if s.err != nil {
+		return true
+	}
+	needed, limited := s.rowsNeeded()
+	return s.groups == nil && limited && len(s.stmt.OrderBy) == 0 && len(s.rows) >= needed
+}
+
+// rowsNeeded returns how many leading rows of the ordered result OFFSET and
+// LIMIT read, and false when there is no LIMIT
+func (s *Stream) rowsNeeded() (int, bool) {
+	if s.stmt.Limit == nil {
+		return 0, false
+	}
+	needed := int(*s.stmt.Limit)
+	if s.stmt.Offset != nil {
+		needed += int(*s.stmt.Offset)
+	}
+	return needed, true
+}
+
+// Add passes one log entry through the query
+func (s *Stream) Add(entry *parser.LogEntry) {
+	if s.Done() {
+		return
+	}
+	if s.sample != nil {
+		s.sample.add(entry)
+		return
+	}
+
+	// Drop log entries without a matching row in an inner-joined lookup table
+	for _, join := range s.joins {
+		if join.row(entry) == nil {
+			return
+		}
+	}
+
+	// Filter logs based on WHERE clause
+	if s.stmt.Where != nil {
+		result, err := s.stmt.Where.Evaluate(entry)
+		if err != nil {
+			return // Skip logs that cause evaluation errors
+		}
+		match, err := toBool(result)
+		if err != nil || !match {
+			return // Skip non-boolean results
+		}
+	}
+
+	if s.groups != nil {
+		s.addToGroup(entry)
+	} else {
+		s.addRow(entry)
+	}
+}
+
+// addRow selects the fields of an entry for a query without GROUP BY
+func (s *Stream) addRow(entry *parser.LogEntry) {
+	var row streamRow
+
+	if s.selectAll {
+		row.values = []Value{
+			{Type: ValueString, StringVal: entry.IP},
+			{Type: ValueTime, TimeVal: entry.Timestamp},
+			{Type: ValueString, StringVal: entry.Method},
+			{Type: ValueString, StringVal: entry.URL},
+			{Type: ValueString, StringVal: entry.Protocol},
+			{Type: ValueInt, IntVal: int64(entry.Status)},
+			{Type: ValueInt, IntVal: entry.Size},
+			{Type: ValueString, StringVal: entry.Referer},
+			{Type: ValueString, StringVal: entry.UserAgent},
+		}
+		if s.table != nil {
+			for _, column := range s.table.Columns {
+				row.values = append(row.values, tableValue(entry, column))
+			}
+		}
+	} else {
+		for _, field := range s.stmt.Fields {
+			value, err := field.Expression.Evaluate(entry)
+			if err != nil {
+				// Use NULL-like value for errors
+				value = Value{Type: ValueString, StringVal: ""}
+			}
+			row.values = append(row.values, value)
+		}
+	}
+
+	for _, clause := range s.stmt.OrderBy {
+		value, err := clause.Expression.Evaluate(entry)
+		row.keys = append(row.keys, value)
+		row.keyErr = append(row.keyErr, err != nil)
+	}
+
+	s.rows = append(s.rows, row)
+
+	// With ORDER BY and LIMIT only the best rows so far need to be kept
+	if needed, limited := s.rowsNeeded(); limited && len(s.stmt.OrderBy) > 0 && len(s.rows) >= 2*needed+1024 {
+		s.sortRows()
+		s.rows = s.rows[:needed]
+	}
+}
+
+// sortRows orders plain rows by their ORDER BY keys, keeping the input
+// order of equal rows
+func (s *Stream) sortRows() {
+	sort.SliceStable(s.rows, func(i, j int) bool {
+		for k, clause := range s.stmt.OrderBy {
+			if s.rows[i].keyErr[k] || s.rows[j].keyErr[k] {
+				continue
+			}
+
+			cmp := s.executor.compareValues(s.rows[i].keys[k], s.rows[j].keys[k])
+			if cmp == 0 {
+				continue
+			}
+			if clause.Descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+// addToGroup folds an entry into the aggregate state of its group
+func (s *Stream) addToGroup(entry *parser.LogEntry) {
+	var keyValues []Value
+	for _, expr := range s.stmt.GroupBy {
+		value, err := expr.Evaluate(entry)
+		if err != nil {
+			// Use empty string for errors
+			value = Value{Type: ValueString, StringVal: ""}
+		}
+		keyValues = append(keyValues, value)
+	}
+	key := s.executor.groupKeyToString(keyValues)
+
+	group, exists := s.groups[key]
+	if !exists {
+		group = &groupState{
+			Key:        key,
+			KeyValues:  keyValues,
+			First:      *entry,
+			Aggregates: make([]aggregateState, len(s.aggregates)),
+		}
+		s.groups[key] = group
+	}
+
+	for i, function := range s.aggregates {
+		s.accumulate(&group.Aggregates[i], function, entry, !exists)
+	}
+
+	if len(s.groups) > s.maxGroups {
+		s.spillGroups()
+	}
+}
+
+// accumulate adds one entry to an aggregate
+func (s *Stream) accumulate(state *aggregateState, function *FunctionExpression, entry *parser.LogEntry, first bool) {
+	name := strings.ToUpper(function.Name)
+	if name == "COUNT" {
+		state.Count++
+		return
+	}
+	if len(function.Arguments) != 1 {
+		return // Reported when the group's value is read
+	}
+
+	value, err := function.Arguments[0].Evaluate(entry)
+	switch name {
+	case "SUM", "AVG":
+		if err != nil {
+			return
+		}
+		switch value.Type {
+		case ValueInt:
+			state.Sum += float64(value.IntVal)
+			state.Count++
+		case ValueFloat:
+			state.Sum += value.FloatVal
+			state.Count++
+		}
+
+	case "MIN", "MAX":
+		if first {
+			state.First, state.FirstErr = value, err != nil
+		}
+		if err == nil && (!state.HasBest || s.better(name, value, state.Best)) {
+			state.Best, state.HasBest = value, true
+		}
+	}
+}
+
+// better reports whether value replaces best for MIN or MAX
+func (s *Stream) better(name string, value, best Value) bool {
+	cmp := s.executor.compareValues(value, best)
+	return (name == "MIN" && cmp < 0) || (name == "MAX" && cmp > 0)
+}
+
+// mergeGroup folds the state of later, spilled, entries of a group into group
+func (s *Stream) mergeGroup(group, later *groupState) {
+	for i, function := range s.aggregates {
+		state, next := &group.Aggregates[i], later.Aggregates[i]
+		state.Count += next.Count
+		state.Sum += next.Sum
+		if next.HasBest {
+			name := strings.ToUpper(function.Name)
+			if !state.HasBest || s.better(name, next.Best, state.Best) {
+				state.Best, state.HasBest = next.Best, true
+			}
+		}
+	}
+}
+
+// aggregateValue returns the value of an aggregate function for a group
+func (s *Stream) aggregateValue(group *groupState, function *FunctionExpression) (Value, error) {
+	index, exists := s.aggregateIndex[function.String()]
+	if !exists {
+		return Value{}, fmt.Errorf("aggregate %s was not computed", function.String())
+	}
+	state := group.Aggregates[index]
+
+	name := strings.ToUpper(function.Name)
+	if name != "COUNT" && len(function.Arguments) != 1 {
+		return Value{}, fmt.Errorf("%s requires exactly 1 argument", name)
+	}
+
+	switch name {
+	case "COUNT":
+		return Value{Type: ValueInt, IntVal: state.Count}, nil
+	case "SUM":
+		return Value{Type: ValueFloat, FloatVal: state.Sum}, nil
+	case "AVG":
+		if state.Count == 0 {
+			return Value{Type: ValueFloat, FloatVal: 0}, nil
+		}
+		return Value{Type: ValueFloat, FloatVal: state.Sum / float64(state.Count)}, nil
+	default: // MIN, MAX
+		if state.FirstErr || !state.HasBest {
+			return Value{Type: ValueInt, IntVal: 0}, nil
+		}
+		return state.Best, nil
+	}
+}
+
+// groupValue evaluates a SELECT field for a group: aggregates read the
+// group's state and other expressions its first entry
+func (s *Stream) groupValue(group *groupState, expr Expression) (Value, error) {
+	if function, ok := expr.(*FunctionExpression); ok && isAggregateFunction(function.Name) {
+		return s.aggregateValue(group, function)
+	}
+	return expr.Evaluate(&group.First)
+}
+
+// Columns returns the result column names
+func (s *Stream) Columns() []string {
+	if s.selectAll {
+		columns := append([]string(nil), selectAllColumns...)
+		if s.table != nil {
+			for _, column := range s.table.Columns {
+				columns = append(columns, column.Name)
+			}
+		}
+		return columns
+	}
+
+	var columns []string
+	for _, expr := range s.stmt.GroupBy {
+		columns = append(columns, expr.String())
+	}
+	for _, field := range s.stmt.Fields {
+		// Skip fields that are already in GROUP BY
+		if s.groups != nil && s.executor.isGroupByExpression(field.Expression, s.stmt.GroupBy) {
+			continue
+		}
+		if field.Alias != "" {
+			columns = append(columns, field.Alias)
+		} else {
+			columns = append(columns, field.Expression.String())
+		}
+	}
+	return columns
+}
+
+// Result finishes the query and returns its rows. Spill files are removed.
+func (s *Stream) Result() (*QueryResult, error) {
+	defer s.Close()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.sample != nil {
+		return s.explain()
+	}
+
+	result := &QueryResult{Columns: s.Columns()}
+
+	if s.groups == nil {
+		// Apply ORDER BY
+		if len(s.stmt.OrderBy) > 0 {
+			s.sortRows()
+		}
+		for _, row := range s.rows {
+			result.Rows = append(result.Rows, row.values)
+		}
+	} else if err := s.groupRows(result); err != nil {
+		return nil, err
+	}
+
+	// Apply OFFSET and LIMIT
+	if s.stmt.Offset != nil {
+		offset := int(*s.stmt.Offset)
+		if offset > len(result.Rows) {
+			offset = len(result.Rows)
+		}
+		result.Rows = result.Rows[offset:]
+	}
+	if s.stmt.Limit != nil {
+		limit := int(*s.stmt.Limit)
+		if limit < len(result.Rows) {
+			result.Rows = result.Rows[:limit]
+		}
+	}
+
+	// Drop the hidden ORDER BY keys of grouped rows
+	if len(s.hiddenKeys) > 0 {
+		for i, row := range result.Rows {
+			result.Rows[i] = row[:len(result.Columns)]
+		}
+	}
+
+	result.Count = len(result.Rows)
+	return result, nil
+}
+
+// groupRows adds a row for every group that passes HAVING, merging spilled
+// groups one partition at a time
+func (s *Stream) groupRows(result *QueryResult) error {
+	// Aggregating no entries without GROUP BY still gives one row of zeros
+	if len(s.stmt.GroupBy) == 0 && len(s.groups) == 0 {
+		s.groups[""] = &groupState{Aggregates: make([]aggregateState, len(s.aggregates))}
+	}
+
+	if s.spill == nil {
+		if err := s.appendGroupRows(result, s.groups); err != nil {
+			return err
+		}
+		return s.orderGroupRows(result)
+	}
+
+	s.spillGroups()
+	if s.err != nil {
+		return s.err
+	}
+	for partition := 0; partition < spillPartitions; partition++ {
+		groups, err := s.spill.read(partition, s)
+		if err == nil {
+			err = s.appendGroupRows(result, groups)
+		}
+		if err == nil {
+			err = s.orderGroupRows(result)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Rows beyond OFFSET plus LIMIT can never come back once ordered
+		if needed, limited := s.rowsNeeded(); limited && len(result.Rows) > needed {
+			result.Rows = result.Rows[:needed]
+		}
+	}
+	return nil
+}
+
+// appendGroupRows evaluates the SELECT fields and HAVING of each group
+func (s *Stream) appendGroupRows(result *QueryResult, groups map[string]*groupState) error {
+	for _, group := range groups {
+		// Build row starting with group key values
+		row := append([]Value(nil), group.KeyValues...)
+
+		aliases := make(map[string]Value)
+		for _, field := range s.stmt.Fields {
+			if s.executor.isGroupByExpression(field.Expression, s.stmt.GroupBy) {
+				continue
+			}
+			value, err := s.groupValue(group, field.Expression)
+			if err != nil {
+				value = Value{Type: ValueString, StringVal: ""}
+			}
+			row = append(row, value)
+			if field.Alias != "" {
+				aliases[strings.ToLower(field.Alias)] = value
+			}
+		}
+
+		// Apply HAVING filter if present
+		if s.stmt.Having != nil {
+			havingResult, err := s.evaluateGroupExpression(s.stmt.Having, group, aliases)
+			if err != nil {
+				return fmt.Errorf("error evaluating HAVING: %w", err)
+			}
+			match, err := toBool(havingResult)
+			if err != nil || !match {
+				continue
+			}
+		}
+
+		for _, expr := range s.hiddenKeys {
+			value, err := s.evaluateGroupExpression(expr, group, aliases)
+			if err != nil {
+				value = Value{Type: ValueString, StringVal: ""}
+			}
+			row = append(row, value)
+		}
+
+		result.Rows = append(result.Rows, row)
+	}
+	return nil
+}
+
+// orderGroupRows applies ORDER BY to grouped rows
+func (s *Stream) orderGroupRows(result *QueryResult) error {
+	if len(s.stmt.OrderBy) == 0 {
+		return nil
+	}
+	if err := s.executor.sortGroupedRows(result.Rows, s.sortColumns, s.stmt.OrderBy); err != nil {
+		return fmt.Errorf("error sorting results: %w", err)
+	}
+	return nil
+}
+
+// evaluateGroupExpression evaluates a HAVING expression for one group.
+// Aggregate functions read the group's state, SELECT aliases resolve to their
+// aggregated values and other fields use the group's first entry.
+func (s *Stream) evaluateGroupExpression(expr Expression, group *groupState, aliases map[string]Value) (Value, error) {
+	switch expr := expr.(type) {
+	case *FunctionExpression:
+		if isAggregateFunction(expr.Name) {
+			return s.aggregateValue(group, expr)
+		}
+
+	case *FieldExpression:
+		if value, ok := aliases[strings.ToLower(string(expr.Field))]; ok {
+			return value, nil
+		}
+		if !isKnownField(expr.Field) {
+			return Value{}, fmt.Errorf("unknown column in HAVING: %s", expr.Field)
+		}
+
+	case *LiteralExpression:
+		return expr.Value, nil
+
+	case *BinaryExpression:
+		left, err := s.evaluateGroupExpression(expr.Left, group, aliases)
+		if err != nil {
+			return Value{}, err
+		}
+		right, err := s.evaluateGroupExpression(expr.Right, group, aliases)
+		if err != nil {
+			return Value{}, err
+		}
+		return evaluateBinaryOperation(left, expr.Operator, right)
+
+	case *UnaryExpression:
+		operand, err := s.evaluateGroupExpression(expr.Operand, group, aliases)
+		if err != nil {
+			return Value{}, err
+		}
+		return evaluateUnaryOperation(expr.Operator, operand)
+
+	case *TimeShiftExpression:
+		value, err := s.evaluateGroupExpression(expr.Time, group, aliases)
+		if err != nil {
+			return Value{}, err
+		}
+		return evaluateTimeShift(value, expr.Interval, expr.Subtract)
+	}
+
+	return expr.Evaluate(&group.First)
+}
+
+// spillGroups writes the groups in memory to the spill files and clears them
+func (s *Stream) spillGroups() {
+	if s.err != nil {
+		return
+	}
+	if s.spill == nil {
+		spill, err := newGroupSpill()
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.spill = spill
+	}
+
+	for _, group := range s.groups {
+		if err := s.spill.write(group); err != nil {
+			s.err = err
+			return
+		}
+	}
+	s.groups = make(map[string]*groupState)
+}
+
+// Close removes any spill files. Result calls it.
+func (s *Stream) Close() {
+	if s.spill != nil {
+		s.spill.remove()
+		s.spill = nil
+	}
+}
+
+// groupSpill holds group states written to disk, hashed into partitions by key
+type groupSpill struct {
+	dir      string
+	files    [spillPartitions]*os.File
+	encoders [spillPartitions]*gob.Encoder
+}
+
+func newGroupSpill() (*groupSpill, error) {
+	dir, err := os.MkdirTemp("", "slaq-spill-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill directory: %w", err)
+	}
+
+	spill := &groupSpill{dir: dir}
+	for i := range spill.files {
+		file, err := os.Create(filepath.Join(dir, fmt.Sprintf("partition-%02d.gob", i)))
+		if err != nil {
+			spill.remove()
+			return nil, fmt.Errorf("failed to create spill file: %w", err)
+		}
+		spill.files[i] = file
+		spill.encoders[i] = gob.NewEncoder(file)
+	}
+	return spill, nil
+}
+
+// write appends a group state to its partition
+func (gs *groupSpill) write(group *groupState) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(group.Key))
+	if err := gs.encoders[hash.Sum32()%spillPartitions].Encode(group); err != nil {
+		return fmt.Errorf("failed to spill group state: %w", err)
+	}
+	return nil
+}
+
+// read merges the states of one partition by group, in the order they were written
+func (gs *groupSpill) read(partition int, s *Stream) (map[string]*groupState, error) {
+	file := gs.files[partition]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*groupState)
+	decoder := gob.NewDecoder(file)
+	for {
+		group := &groupState{}
+		err := decoder.Decode(group)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spilled group state: %w", err)
+		}
+
+		if existing, exists := groups[group.Key]; exists {
+			s.mergeGroup(existing, group)
+		} else {
+			groups[group.Key] = group
+		}
+	}
+	return groups, nil
+}
+
+// remove closes and deletes the spill files
+func (gs *groupSpill) remove() {
+	for _, file := range gs.files {
+		if file != nil {
+			file.Close()
+		}
+	}
+	os.RemoveAll(gs.dir)
+}
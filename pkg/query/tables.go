@@ -0,0 +1,261 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// LogsTable is the name of the table holding the access log entries
+const LogsTable = "logs"
+
+// sharedFields are the log fields every table can be queried by. A table
+// leaves the fields that do not apply to it empty.
+var sharedFields = []string{"ip", "timestamp", "method", "url", "protocol", "status", "size", "referer", "user_agent"}
+
+// TableColumn is a column a table adds to the shared log fields
+type TableColumn struct {
+	Name        string
+	Type        ValueType
+	Description string
+}
+
+// Table is a logical table queries can select FROM besides logs, such as
+// nginx errors, visitor sessions or security threats. Each row is a log
+// entry: the shared fields (ip, timestamp, url, ...) mean the same in every
+// table, so they can be compared across tables, and the table's own columns
+// are kept in the entry's Fields.
+type Table struct {
+	Name        string
+	Description string
+	Columns     []TableColumn
+
+	load   func() ([]*parser.LogEntry, error)
+	loaded bool
+	rows   []*parser.LogEntry
+	err    error
+	lookup *LookupTable // Rows as a lookup table, built on the first JOIN
+}
+
+// NewTable creates a table whose rows are produced by load the first time a
+// query reads it
+func NewTable(name, description string, columns []TableColumn, load func() ([]*parser.LogEntry, error)) *Table {
+	return &Table{
+		Name:        strings.ToLower(name),
+		Description: description,
+		Columns:     columns,
+		load:        load,
+	}
+}
+
+// NewTableRow returns a row with the shared fields of entry and the given
+// table columns. Values may be strings, integers, floats, bools or times.
+func NewTableRow(entry parser.LogEntry, values map[string]interface{}) *parser.LogEntry {
+	entry.Fields = make(map[string]string, len(values))
+	for name, value := range values {
+		switch value := value.(type) {
+		case time.Time:
+			entry.Fields[name] = value.Format(time.RFC3339Nano)
+		case float64:
+			entry.Fields[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			entry.Fields[name] = fmt.Sprint(value)
+		}
+	}
+	return &entry
+}
+
+// Rows returns the rows of the table, loading them on first use
+func (t *Table) Rows() ([]*parser.LogEntry, error) {
+	if !t.loaded {
+		t.rows, t.err = t.load()
+		t.loaded = true
+	}
+	return t.rows, t.err
+}
+
+// column returns the table column with the given name
+func (t *Table) column(name string) (TableColumn, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return TableColumn{}, false
+}
+
+// columnNames lists the shared fields followed by the table's own columns
+func (t *Table) columnNames() []string {
+	names := append([]string(nil), sharedFields...)
+	for _, column := range t.Columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+// lookupTable returns the rows of the table as a lookup table, so logs can
+// JOIN against it like a --lookup file
+func (t *Table) lookupTable() (*LookupTable, error) {
+	if t.lookup != nil {
+		return t.lookup, nil
+	}
+
+	rows, err := t.Rows()
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := &LookupTable{Name: t.Name, Columns: t.columnNames()}
+	for _, entry := range rows {
+		row := make(map[string]Value, len(lookup.Columns))
+		for _, name := range sharedFields {
+			row[name], _ = (&FieldExpression{Field: QueryField(name)}).Evaluate(entry)
+		}
+		for _, column := range t.Columns {
+			row[column.Name] = tableValue(entry, column)
+		}
+		lookup.Rows = append(lookup.Rows, row)
+	}
+
+	t.lookup = lookup
+	return lookup, nil
+}
+
+// tableValue reads a table column from a row, converting it to the column type
+func tableValue(entry *parser.LogEntry, column TableColumn) Value {
+	field := entry.Fields[column.Name]
+
+	switch column.Type {
+	case ValueInt:
+		if i, err := strconv.ParseInt(field, 10, 64); err == nil {
+			return Value{Type: ValueInt, IntVal: i}
+		}
+		return Value{Type: ValueInt}
+	case ValueFloat:
+		if f, err := strconv.ParseFloat(field, 64); err == nil {
+			return Value{Type: ValueFloat, FloatVal: f}
+		}
+		return Value{Type: ValueFloat}
+	case ValueBool:
+		return Value{Type: ValueBool, BoolVal: field == "true"}
+	case ValueTime:
+		if t, err := time.Parse(time.RFC3339Nano, field); err == nil {
+			return Value{Type: ValueTime, TimeVal: t}
+		}
+		return Value{Type: ValueTime}
+	default:
+		return Value{Type: ValueString, StringVal: field}
+	}
+}
+
+// table returns the table a statement selects FROM, or nil for logs
+func (e *Executor) table(name string) (*Table, error) {
+	name = strings.ToLower(name)
+	if name == LogsTable {
+		return nil, nil
+	}
+	if table, exists := e.tables[name]; exists {
+		return table, nil
+	}
+
+	names := []string{LogsTable}
+	for tableName := range e.tables {
+		names = append(names, tableName)
+	}
+	sort.Strings(names[1:])
+	return nil, fmt.Errorf("unknown table %q (tables: %s)", name, strings.Join(names, ", "))
+}
+
+// rows returns the entries a statement selects FROM
+func (e *Executor) rows(from string) ([]*parser.LogEntry, error) {
+	table, err := e.table(from)
+	if err != nil || table == nil {
+		return e.logs, err
+	}
+
+	rows, err := table.Rows()
+	if err != nil {
+		return nil, fmt.Errorf("table %s: %w", table.Name, err)
+	}
+	return rows, nil
+}
+
+// resolveTableFields replaces references to the columns of the table a
+// statement selects FROM, written as column or table.column, with reads from
+// the row's Fields
+func resolveTableFields(expr Expression, table *Table) (Expression, error) {
+	switch expr := expr.(type) {
+	case *FieldExpression:
+		name := strings.ToLower(string(expr.Field))
+		qualified := strings.HasPrefix(name, table.Name+".")
+		name = strings.TrimPrefix(name, table.Name+".")
+
+		if column, exists := table.column(name); exists {
+			return &tableFieldExpression{Name: string(expr.Field), column: column}, nil
+		}
+		if qualified {
+			field := &FieldExpression{Field: QueryField(name)}
+			if !isKnownField(field.Field) {
+				return nil, fmt.Errorf("table %q has no column %q (columns: %s)", table.Name, name, strings.Join(table.columnNames(), ", "))
+			}
+			return field, nil
+		}
+		return expr, nil
+
+	case *BinaryExpression:
+		left, err := resolveTableFields(expr.Left, table)
+		if err != nil {
+			return nil, err
+		}
+		right, err := resolveTableFields(expr.Right, table)
+		if err != nil {
+			return nil, err
+		}
+		return &BinaryExpression{Left: left, Operator: expr.Operator, Right: right}, nil
+
+	case *UnaryExpression:
+		operand, err := resolveTableFields(expr.Operand, table)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpression{Operator: expr.Operator, Operand: operand}, nil
+
+	case *TimeShiftExpression:
+		shifted, err := resolveTableFields(expr.Time, table)
+		if err != nil {
+			return nil, err
+		}
+		return &TimeShiftExpression{Time: shifted, Subtract: expr.Subtract, Interval: expr.Interval, Literal: expr.Literal}, nil
+
+	case *FunctionExpression:
+		args := make([]Expression, len(expr.Arguments))
+		for i, arg := range expr.Arguments {
+			resolved, err := resolveTableFields(arg, table)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = resolved
+		}
+		return &FunctionExpression{Name: expr.Name, Arguments: args}, nil
+	}
+
+	return expr, nil
+}
+
+// tableFieldExpression reads a table column from a row
+type tableFieldExpression struct {
+	Name   string // As written in the query, e.g. level or errors.level
+	column TableColumn
+}
+
+func (tf tableFieldExpression) String() string {
+	return tf.Name
+}
+
+func (tf tableFieldExpression) Evaluate(entry *parser.LogEntry) (Value, error) {
+	return tableValue(entry, tf.column), nil
+}
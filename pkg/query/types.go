@@ -39,6 +39,8 @@ const (
 	TokenIsBot
 	TokenIsError
 	TokenIsSuccess
+	TokenPlus
+	TokenMinus
 
 	// Logical operators
 	TokenAnd
@@ -56,7 +58,13 @@ const (
 	TokenOrderBy
 	TokenHaving
 	TokenLimit
+	TokenOffset
+	TokenInterval
 	TokenAs
+	TokenJoin
+	TokenLeft
+	TokenOn
+	TokenExplain
 
 	// Punctuation
 	TokenLeftParen
@@ -186,15 +194,21 @@ type Statement interface {
 type SelectStatement struct {
 	Fields   []SelectField
 	From     string
+	Joins    []JoinClause
 	Where    Expression
 	GroupBy  []Expression
 	OrderBy  []OrderByClause
 	Having   Expression
 	Limit    *int64
+	Offset   *int64 // Rows to skip before LIMIT applies
+	Explain  bool // EXPLAIN SELECT ... describes the query plan instead of running it
 }
 
 func (s SelectStatement) String() string {
 	result := "SELECT "
+	if s.Explain {
+		result = "EXPLAIN " + result
+	}
 	for i, field := range s.Fields {
 		if i > 0 {
 			result += ", "
@@ -202,6 +216,9 @@ func (s SelectStatement) String() string {
 		result += field.String()
 	}
 	result += " FROM " + s.From
+	for _, join := range s.Joins {
+		result += " " + join.String()
+	}
 	if s.Where != nil {
 		result += " WHERE " + s.Where.String()
 	}
@@ -229,6 +246,9 @@ func (s SelectStatement) String() string {
 	if s.Limit != nil {
 		result += fmt.Sprintf(" LIMIT %d", *s.Limit)
 	}
+	if s.Offset != nil {
+		result += fmt.Sprintf(" OFFSET %d", *s.Offset)
+	}
 	return result
 }
 
@@ -246,6 +266,23 @@ func (sf SelectField) String() string {
 	return result
 }
 
+// JoinClause joins the logs against a lookup table, e.g.
+// JOIN customers ON ip = customers.ip. Lookup columns are referenced as
+// table.column; a LEFT JOIN keeps log entries without a matching row.
+type JoinClause struct {
+	Table string
+	On    Expression
+	Left  bool
+}
+
+func (jc JoinClause) String() string {
+	result := "JOIN " + jc.Table + " ON " + jc.On.String()
+	if jc.Left {
+		result = "LEFT " + result
+	}
+	return result
+}
+
 // OrderByClause represents ORDER BY clause
 type OrderByClause struct {
 	Expression Expression
@@ -351,6 +388,31 @@ func (ue UnaryExpression) Evaluate(entry *parser.LogEntry) (Value, error) {
 	return evaluateUnaryOperation(ue.Operator, operand)
 }
 
+// TimeShiftExpression adds or subtracts a fixed interval from a time, as in
+// NOW() - INTERVAL '24h'
+type TimeShiftExpression struct {
+	Time     Expression
+	Subtract bool
+	Interval time.Duration
+	Literal  string // The interval as written, e.g. 24h or 2 hours
+}
+
+func (ts TimeShiftExpression) String() string {
+	op := "+"
+	if ts.Subtract {
+		op = "-"
+	}
+	return fmt.Sprintf("%s %s INTERVAL '%s'", ts.Time.String(), op, ts.Literal)
+}
+
+func (ts TimeShiftExpression) Evaluate(entry *parser.LogEntry) (Value, error) {
+	value, err := ts.Time.Evaluate(entry)
+	if err != nil {
+		return Value{}, err
+	}
+	return evaluateTimeShift(value, ts.Interval, ts.Subtract)
+}
+
 // FunctionExpression represents function calls
 type FunctionExpression struct {
 	Name      string
@@ -383,6 +445,21 @@ func (fe FunctionExpression) Evaluate(entry *parser.LogEntry) (Value, error) {
 	return evaluateFunction(fe.Name, args, entry)
 }
 
+// SubqueryExpression represents a nested SELECT used as the list of an IN
+// expression. The executor runs it before filtering and replaces it with the
+// selected values.
+type SubqueryExpression struct {
+	Statement *SelectStatement
+}
+
+func (se SubqueryExpression) String() string {
+	return "(" + se.Statement.String() + ")"
+}
+
+func (se SubqueryExpression) Evaluate(entry *parser.LogEntry) (Value, error) {
+	return Value{}, fmt.Errorf("subquery %s was not resolved before evaluation", se.String())
+}
+
 // QueryResult represents the result of a query execution
 type QueryResult struct {
 	Columns []string
@@ -0,0 +1,40 @@
+package remote
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AgentAvailable reports whether an ssh-agent socket is set in SSH_AUTH_SOCK
+func AgentAvailable() bool {
+	return os.Getenv("SSH_AUTH_SOCK") != ""
+}
+
+// connectAgent connects to the running ssh-agent. The connection must stay
+// open while the agent signs for the SSH handshake.
+func connectAgent() (agent.ExtendedAgent, net.Conn, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, fmt.Errorf("no ssh-agent running (SSH_AUTH_SOCK is not set)")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent at %s: %w", socket, err)
+	}
+
+	client := agent.NewClient(conn)
+	keys, err := client.List()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to list ssh-agent keys: %w", err)
+	}
+	if len(keys) == 0 {
+		conn.Close()
+		return nil, nil, fmt.Errorf("ssh-agent has no keys (add one with ssh-add)")
+	}
+	return client, conn, nil
+}
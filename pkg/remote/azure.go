@@ -0,0 +1,304 @@
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Azure Blob Storage defaults
+const (
+	azureAPIVersion = "2020-10-02"
+	azureScope      = "https://storage.azure.com/"
+)
+
+// AzureContainer is an Azure Blob Storage container, read through the Blob
+// service REST API
+type AzureContainer struct {
+	Account   string
+	Container string
+
+	endpoint *url.URL // Of the account's blob service
+	http     *http.Client
+
+	sharedKey []byte       // Account key, for Shared Key signed requests
+	sas       url.Values   // Shared access signature
+	token     *bearerToken // Microsoft Entra ID token
+}
+
+// azureBlobList is a page of a blob listing
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			ContentLength int64  `xml:"Content-Length"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// azureError is the error document the service answers failed requests with
+type azureError struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// NewAzureContainer connects to a container of a storage account with the
+// credentials of the environment: AZURE_STORAGE_CONNECTION_STRING,
+// AZURE_STORAGE_KEY, AZURE_STORAGE_SAS_TOKEN, the service principal of
+// AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET, or the managed
+// identity of the Azure VM or service. Without any, requests are anonymous,
+// for public containers.
+func NewAzureContainer(account, container string, options BucketOptions) (*AzureContainer, error) {
+	c := &AzureContainer{Account: account, Container: container, http: objectHTTPClient()}
+	blobEndpoint := fmt.Sprintf("https://%s.blob.core.windows.net", account)
+
+	if connection := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); connection != "" {
+		values := make(map[string]string)
+		for _, part := range strings.Split(connection, ";") {
+			if name, value, ok := strings.Cut(part, "="); ok {
+				values[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+			}
+		}
+		if name := values["accountname"]; name != "" && name != account {
+			return nil, fmt.Errorf("AZURE_STORAGE_CONNECTION_STRING is for account %s, not %s", name, account)
+		}
+		if values["blobendpoint"] != "" {
+			blobEndpoint = values["blobendpoint"]
+		}
+		if err := c.useKeyOrSAS(values["accountkey"], values["sharedaccesssignature"]); err != nil {
+			return nil, fmt.Errorf("invalid AZURE_STORAGE_CONNECTION_STRING: %w", err)
+		}
+	} else if err := c.useKeyOrSAS(os.Getenv("AZURE_STORAGE_KEY"), os.Getenv("AZURE_STORAGE_SAS_TOKEN")); err != nil {
+		return nil, err
+	}
+
+	if options.Endpoint != "" {
+		blobEndpoint = options.Endpoint
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(blobEndpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q, expected a URL such as http://127.0.0.1:10000/devstoreaccount1", blobEndpoint)
+	}
+	c.endpoint = endpoint
+
+	if c.sharedKey != nil || c.sas != nil {
+		return c, nil
+	}
+	tenant, client, secret := os.Getenv("AZURE_TENANT_ID"), os.Getenv("AZURE_CLIENT_ID"), os.Getenv("AZURE_CLIENT_SECRET")
+	if tenant != "" && client != "" && secret != "" {
+		c.token = &bearerToken{fetch: func() (string, time.Duration, error) {
+			return postTokenForm(fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", url.PathEscape(tenant)), url.Values{
+				"grant_type":    {"client_credentials"},
+				"client_id":     {client},
+				"client_secret": {secret},
+				"scope":         {azureScope + ".default"},
+			})
+		}}
+		return c, nil
+	}
+
+	// The managed identity, when on Azure
+	token := &bearerToken{fetch: managedIdentityToken}
+	if _, err := token.get(); err == nil {
+		c.token = token
+	}
+	return c, nil
+}
+
+// useKeyOrSAS sets the account key or shared access signature, if either
+func (c *AzureContainer) useKeyOrSAS(key, sas string) error {
+	if key != "" {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return fmt.Errorf("invalid account key: %w", err)
+		}
+		c.sharedKey = decoded
+		return nil
+	}
+	if sas != "" {
+		values, err := url.ParseQuery(strings.TrimPrefix(sas, "?"))
+		if err != nil {
+			return fmt.Errorf("invalid SAS token: %w", err)
+		}
+		c.sas = values
+	}
+	return nil
+}
+
+// Name returns the URL of the container
+func (c *AzureContainer) Name() string {
+	return fmt.Sprintf("az://%s/%s", c.Account, c.Container)
+}
+
+// ListObjects returns the blobs whose name starts with prefix
+func (c *AzureContainer) ListObjects(prefix string) ([]RemoteFile, error) {
+	var objects []RemoteFile
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		response, err := c.get("", query, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s/%s: %w", c.Name(), prefix, err)
+		}
+		var list azureBlobList
+		err = xml.NewDecoder(response.Body).Decode(&list)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse listing of %s: %w", c.Name(), err)
+		}
+		for _, blob := range list.Blobs {
+			modTime, _ := http.ParseTime(blob.Properties.LastModified)
+			objects = append(objects, RemoteFile{Path: blob.Name, Size: blob.Properties.ContentLength, ModTime: modTime})
+		}
+		if list.NextMarker == "" {
+			break
+		}
+		marker = list.NextMarker
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return objects, nil
+}
+
+// CopyObject streams a blob from offset to dst, with the MD5 set when it was
+// uploaded, which a ranged read returns separately from that of the range
+func (c *AzureContainer) CopyObject(key string, offset int64, dst io.Writer) (int64, string, error) {
+	response, err := c.get(key, nil, offset)
+	if err != nil {
+		return 0, "", err
+	}
+	checksum := base64Checksum("md5", response.Header.Get("X-Ms-Blob-Content-Md5"))
+	if checksum == "" && offset == 0 {
+		checksum = base64Checksum("md5", response.Header.Get("Content-Md5"))
+	}
+	copied, err := copyResponse(response, offset, dst)
+	return copied, checksum, err
+}
+
+// get sends a GET request for a blob, or for the container when blob is "",
+// from offset if not 0, returning the response of a successful one
+func (c *AzureContainer) get(blob string, query url.Values, offset int64) (*http.Response, error) {
+	u := *c.endpoint
+	u.Path = c.endpoint.Path + "/" + c.Container
+	if blob != "" {
+		u.Path += "/" + blob
+	}
+	u.RawPath = awsURIEncode(u.Path, false)
+	signed := url.Values{}
+	for name, values := range query {
+		signed[name] = values
+	}
+	for name, values := range c.sas {
+		signed[name] = values
+	}
+	u.RawQuery = signed.Encode()
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Ms-Version", azureAPIVersion)
+	request.Header.Set("X-Ms-Date", time.Now().UTC().Format(http.TimeFormat))
+	if offset > 0 {
+		request.Header.Set("X-Ms-Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	switch {
+	case c.sharedKey != nil:
+		request.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", c.Account, c.sign(request, query)))
+	case c.token != nil:
+		token, err := c.token.get()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get an Azure access token: %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+		var azureErr azureError
+		if xml.Unmarshal(body, &azureErr) == nil && azureErr.Code != "" {
+			message, _, _ := strings.Cut(azureErr.Message, "\n")
+			return nil, httpStatusError(response.StatusCode, fmt.Errorf("%s: %s (HTTP %d)", azureErr.Code, strings.TrimSpace(message), response.StatusCode))
+		}
+		if code := response.Header.Get("X-Ms-Error-Code"); code != "" {
+			return nil, httpStatusError(response.StatusCode, fmt.Errorf("%s (HTTP %d)", code, response.StatusCode))
+		}
+		return nil, httpStatusError(response.StatusCode, fmt.Errorf("HTTP %d", response.StatusCode))
+	}
+	return response, nil
+}
+
+// sign returns the Shared Key signature of a GET request without a body:
+// the standard headers are empty, then come the x-ms- headers and the
+// resource, as the account, path and query
+func (c *AzureContainer) sign(request *http.Request, query url.Values) string {
+	var names []string
+	for name := range request.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var stringToSign strings.Builder
+	stringToSign.WriteString(request.Method + strings.Repeat("\n", 12))
+	for _, name := range names {
+		stringToSign.WriteString(name + ":" + strings.TrimSpace(request.Header.Get(name)) + "\n")
+	}
+
+	stringToSign.WriteString("/" + c.Account + request.URL.EscapedPath())
+	params := make([]string, 0, len(query))
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		stringToSign.WriteString("\n" + strings.ToLower(name) + ":" + strings.Join(values, ","))
+	}
+
+	mac := hmac.New(sha256.New, c.sharedKey)
+	mac.Write([]byte(stringToSign.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// managedIdentityToken gets an access token of the managed identity from the
+// instance metadata service, or the endpoint App Service and Functions set
+func managedIdentityToken() (string, time.Duration, error) {
+	if endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER"); endpoint != "" && header != "" {
+		request, err := http.NewRequest(http.MethodGet, endpoint+"?"+url.Values{"api-version": {"2019-08-01"}, "resource": {azureScope}}.Encode(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		request.Header.Set("X-Identity-Header", header)
+		return fetchToken(&http.Client{Timeout: metadataTimeout}, request)
+	}
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureScope}}
+	if client := os.Getenv("AZURE_CLIENT_ID"); client != "" {
+		query.Set("client_id", client)
+	}
+	request, err := http.NewRequest(http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Metadata", "true")
+	return fetchToken(&http.Client{Timeout: metadataTimeout}, request)
+}
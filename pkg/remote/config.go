@@ -5,14 +5,44 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
+// SSHConfig is a server to download logs from. It authenticates with its
+// key file and password, whose secrets can be kept in a credential store
+// instead of the file; without either, or with UseAgent, it uses the keys of
+// the running ssh-agent.
 type SSHConfig struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	LogPath  string `json:"log_path"`
+	Host            string `json:"host"`
+	Port            int    `json:"port"`
+	Username        string `json:"username"`
+	Password        string `json:"password,omitempty"`
+	KeyFile         string `json:"key_file,omitempty"`         // Private key, its passphrase in the credential store
+	CredentialStore string `json:"credential_store,omitempty"` // CredentialStoreKeyring or CredentialStoreFile
+	UseAgent        bool   `json:"use_agent,omitempty"`
+	LogPath         string `json:"log_path"`
+}
+
+// usesAgent reports whether the server is authenticated with ssh-agent
+func (c *SSHConfig) usesAgent() bool {
+	return c.UseAgent || (c.Password == "" && c.KeyFile == "" && c.CredentialStore == "")
+}
+
+// AuthDescription describes how the server is authenticated to
+func (c *SSHConfig) AuthDescription() string {
+	var methods []string
+	if c.usesAgent() {
+		methods = append(methods, "ssh-agent")
+	}
+	if c.KeyFile != "" {
+		methods = append(methods, "key "+c.KeyFile)
+	}
+	if c.Password != "" {
+		methods = append(methods, "password")
+	} else if c.CredentialStore != "" {
+		methods = append(methods, "password from "+c.CredentialStore)
+	}
+	return strings.Join(methods, ", then ")
 }
 
 type Config struct {
@@ -44,11 +74,30 @@ func LoadConfig(filename string) (*Config, error) {
 		if config.Servers[i].LogPath == "" {
 			config.Servers[i].LogPath = "/var/log/nginx/access.log"
 		}
+		if store := config.Servers[i].CredentialStore; store != "" {
+			if _, err := OpenCredentialStore(store); err != nil {
+				return nil, fmt.Errorf("server %s: %w", config.Servers[i].Host, err)
+			}
+		}
 	}
 
 	return &config, nil
 }
 
+// SaveConfig writes the configuration, readable by the owner only
+func SaveConfig(filename string, config *Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	return nil
+}
+
 func CreateSampleConfig(filename string) error {
 	// Check if config file already exists
 	if _, err := os.Stat(filename); err == nil {
@@ -0,0 +1,333 @@
+package remote
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// Credential stores, named by a server's credential_store
+const (
+	CredentialStoreKeyring = "keyring" // macOS Keychain, Secret Service or Windows Credential Manager
+	CredentialStoreFile    = "file"    // Passphrase-encrypted file, where no keyring is available
+)
+
+// Kinds of secrets kept for a server
+const (
+	SecretPassword   = "password"
+	SecretPassphrase = "passphrase" // Of the server's key_file
+)
+
+// credentialService names the tool's entries in the OS keyring
+const credentialService = "smart-log-analyser"
+
+// ErrCredentialNotFound is returned for a secret that was never stored
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// CredentialStore keeps secrets by account
+type CredentialStore interface {
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+// OpenCredentialStore returns the credential store of a name
+func OpenCredentialStore(name string) (CredentialStore, error) {
+	switch name {
+	case CredentialStoreKeyring:
+		return keyringStore{}, nil
+	case CredentialStoreFile:
+		return DefaultCredentialFile, nil
+	default:
+		return nil, fmt.Errorf("unknown credential store %q (use %s or %s)", name, CredentialStoreKeyring, CredentialStoreFile)
+	}
+}
+
+// SecretAccount returns the account a secret of the server is stored under,
+// such as "password:root@web1:22"
+func (c *SSHConfig) SecretAccount(kind string) string {
+	return fmt.Sprintf("%s:%s@%s:%d", kind, c.Username, c.Host, c.Port)
+}
+
+// LoadSecret returns a secret of the server from its credential store; ""
+// when the server has no store or the secret was not stored
+func (c *SSHConfig) LoadSecret(kind string) (string, error) {
+	if c.CredentialStore == "" {
+		return "", nil
+	}
+	store, err := OpenCredentialStore(c.CredentialStore)
+	if err != nil {
+		return "", err
+	}
+	secret, err := store.Get(c.SecretAccount(kind))
+	if errors.Is(err, ErrCredentialNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s of %s from %s store: %w", kind, c.Host, c.CredentialStore, err)
+	}
+	return secret, nil
+}
+
+// StoreSecret saves a secret of the server in its credential store
+func (c *SSHConfig) StoreSecret(kind, secret string) error {
+	store, err := OpenCredentialStore(c.CredentialStore)
+	if err != nil {
+		return err
+	}
+	if err := store.Set(c.SecretAccount(kind), secret); err != nil {
+		return fmt.Errorf("failed to save %s of %s to %s store: %w", kind, c.Host, c.CredentialStore, err)
+	}
+	return nil
+}
+
+// keyringStore keeps secrets in the OS keyring
+type keyringStore struct{}
+
+func (keyringStore) Get(account string) (string, error) {
+	secret, err := keyring.Get(credentialService, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrCredentialNotFound
+	}
+	return secret, err
+}
+
+func (keyringStore) Set(account, secret string) error {
+	return keyring.Set(credentialService, account, secret)
+}
+
+func (keyringStore) Delete(account string) error {
+	err := keyring.Delete(credentialService, account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrCredentialNotFound
+	}
+	return err
+}
+
+// scrypt parameters of the encrypted credentials file
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// CredentialsPassphraseEnv names the environment variable holding the
+// passphrase of the credentials file, for runs without a terminal
+const CredentialsPassphraseEnv = "SLA_CREDENTIALS_PASSPHRASE"
+
+// DefaultCredentialFile is the encrypted file store used by servers with
+// credential_store "file"
+var DefaultCredentialFile = &EncryptedFileStore{Path: "credentials.enc", Passphrase: credentialsPassphrase}
+
+// credentialsPassphrase returns the passphrase of a credentials file from the
+// environment, or else asks for it on the terminal
+func credentialsPassphrase(path string) (string, error) {
+	if passphrase := os.Getenv(CredentialsPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("set %s to decrypt credentials file %s without a terminal", CredentialsPassphraseEnv, path)
+	}
+	return ReadSecret(fmt.Sprintf("Passphrase for credentials file %s: ", path))
+}
+
+// ReadSecret asks for a secret on the terminal without echoing it
+func ReadSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("cannot ask for a secret without a terminal")
+	}
+	fmt.Print(prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+// EncryptedFileStore keeps secrets in a file encrypted with
+// XChaCha20-Poly1305, under a key derived from a passphrase with scrypt
+type EncryptedFileStore struct {
+	Path string
+
+	// Passphrase returns the passphrase of the file at a path, asked once per
+	// run
+	Passphrase func(path string) (string, error)
+
+	mu         sync.Mutex // Servers connect at once
+	passphrase string
+}
+
+// encryptedCredentials is the JSON layout of the credentials file
+type encryptedCredentials struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func (s *EncryptedFileStore) Get(account string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[account]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return secret, nil
+}
+
+func (s *EncryptedFileStore) Set(account, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[account] = secret
+	return s.save(secrets)
+}
+
+func (s *EncryptedFileStore) Delete(account string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[account]; !ok {
+		return ErrCredentialNotFound
+	}
+	delete(secrets, account)
+	return s.save(secrets)
+}
+
+// Unlock asks for the passphrase of an existing file and checks it, so it is
+// not asked in the middle of a download
+func (s *EncryptedFileStore) Unlock() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.load()
+	return err
+}
+
+// getPassphrase returns the passphrase of the file, asking for it once
+func (s *EncryptedFileStore) getPassphrase() (string, error) {
+	if s.passphrase != "" {
+		return s.passphrase, nil
+	}
+	if s.Passphrase == nil {
+		return "", fmt.Errorf("no passphrase for credentials file %s", s.Path)
+	}
+	passphrase, err := s.Passphrase(s.Path)
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("empty passphrase for credentials file %s", s.Path)
+	}
+	s.passphrase = passphrase
+	return passphrase, nil
+}
+
+// load decrypts the secrets of the file; none when it does not exist yet
+func (s *EncryptedFileStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return make(map[string]string), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var file encryptedCredentials
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", s.Path, err)
+	}
+	if file.Version != 1 || file.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported credentials file %s (version %d, kdf %q)", s.Path, file.Version, file.KDF)
+	}
+
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := credentialCipher(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		s.passphrase = ""
+		return nil, fmt.Errorf("wrong passphrase for credentials file %s, or the file is corrupted", s.Path)
+	}
+
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", s.Path, err)
+	}
+	return secrets, nil
+}
+
+// save encrypts the secrets to the file with a fresh salt and nonce
+func (s *EncryptedFileStore) save(secrets map[string]string) error {
+	passphrase, err := s.getPassphrase()
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	file := encryptedCredentials{Version: 1, KDF: "scrypt", Salt: make([]byte, 16), Nonce: make([]byte, chacha20poly1305.NonceSizeX)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	aead, err := credentialCipher(passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plaintext, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(s.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create credentials directory: %w", err)
+		}
+	}
+	if err := os.WriteFile(s.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	return nil
+}
+
+// credentialCipher derives the cipher of the credentials file
+func credentialCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
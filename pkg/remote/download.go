@@ -0,0 +1,589 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Download defaults
+const (
+	DefaultParallelServers = 4
+	DefaultFilesPerServer  = 2
+)
+
+// Server download states
+const (
+	StateWaiting     = "waiting"
+	StateConnecting  = "connecting"
+	StateListing     = "listing"
+	StateDownloading = "downloading"
+	StateDone        = "done"
+	StateFailed      = "failed"
+)
+
+// DownloadOptions configure a download from several servers
+type DownloadOptions struct {
+	OutputDir      string
+	Single         bool  // Only each server's log_path, not all its access logs
+	MaxFiles       int   // Per server, 0 for all
+	Parallel       int   // Servers, or objects of a bucket, downloaded from at once
+	PerServer      int   // Files downloaded at once from each server
+	BandwidthLimit int64 // Bytes per second across all downloads, 0 for none
+
+	// Sync, if set, downloads only new or changed files, to names without the
+	// time of the run, and records them in the state
+	Sync *SyncState
+
+	// TimeRange, if set, downloads only the lines within it, filtered on the
+	// server and saved gzip compressed
+	TimeRange TimeRange
+
+	// Retries is how often a failed file is tried again, resuming where it
+	// stopped when it can
+	Retries int
+	// NoVerify skips the checksum and gzip checks of downloaded files; their
+	// size is still checked
+	NoVerify bool
+}
+
+// DownloadEvent is something that happened during a download, for output
+// without progress bars
+type DownloadEvent struct {
+	Host       string
+	State      string // The server's new state, or "" for a file event
+	RemotePath string // Set for file events
+	LocalPath  string
+	Bytes      int64
+	Err        error
+	Retry      time.Duration // Set when a failed file is tried again after it
+}
+
+// ServerProgress is the progress of the download from a server
+type ServerProgress struct {
+	Host        string
+	State       string
+	Err         error
+	Files       int
+	FilesDone   int
+	FilesFailed int
+	FilesSynced int // Unchanged since the last sync, or renamed by log rotation
+	Retries     int
+	Bytes       int64
+	TotalBytes  int64 // Of the files whose size is known, 0 when filtered
+	Downloaded  []string
+}
+
+// Downloader downloads the logs of several servers at once: Parallel
+// servers, each with PerServer files in flight over its one connection,
+// sharing the bandwidth limit
+type Downloader struct {
+	options   DownloadOptions
+	limiter   *rateLimiter
+	timestamp string
+	events    func(DownloadEvent)
+
+	mu       sync.Mutex
+	progress []*ServerProgress
+}
+
+// NewDownloader creates a downloader; events, if not nil, is called for
+// every state change and finished file, from the download goroutines
+func NewDownloader(options DownloadOptions, events func(DownloadEvent)) *Downloader {
+	if options.Parallel < 1 {
+		options.Parallel = 1
+	}
+	if options.PerServer < 1 {
+		options.PerServer = 1
+	}
+	d := &Downloader{
+		options:   options,
+		timestamp: time.Now().Format("20060102_150405"),
+		events:    func(DownloadEvent) {},
+	}
+	if options.BandwidthLimit > 0 {
+		d.limiter = newRateLimiter(options.BandwidthLimit)
+	}
+	if events != nil {
+		// One event at a time, so output lines do not interleave
+		var eventsMu sync.Mutex
+		d.events = func(event DownloadEvent) {
+			eventsMu.Lock()
+			defer eventsMu.Unlock()
+			events(event)
+		}
+	}
+	return d
+}
+
+// Run downloads from the servers and returns their final progress, in the
+// order of the servers
+func (d *Downloader) Run(servers []SSHConfig) []ServerProgress {
+	d.mu.Lock()
+	d.progress = make([]*ServerProgress, len(servers))
+	for i, server := range servers {
+		d.progress[i] = &ServerProgress{Host: server.Host, State: StateWaiting}
+	}
+	d.mu.Unlock()
+
+	slots := make(chan struct{}, d.options.Parallel)
+	var wg sync.WaitGroup
+	for i := range servers {
+		wg.Add(1)
+		go func(server *SSHConfig, progress *ServerProgress) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			d.downloadServer(server, progress)
+		}(&servers[i], d.progress[i])
+	}
+	wg.Wait()
+	return d.Progress()
+}
+
+// Progress returns a snapshot of the progress of every server
+func (d *Downloader) Progress() []ServerProgress {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	snapshot := make([]ServerProgress, len(d.progress))
+	for i, progress := range d.progress {
+		snapshot[i] = *progress
+		snapshot[i].Downloaded = append([]string(nil), progress.Downloaded...)
+	}
+	return snapshot
+}
+
+// setState moves a server to a state, reporting it
+func (d *Downloader) setState(progress *ServerProgress, state string, err error) {
+	d.mu.Lock()
+	progress.State = state
+	progress.Err = err
+	host := progress.Host
+	d.mu.Unlock()
+	d.events(DownloadEvent{Host: host, State: state, Err: err})
+}
+
+// downloadServer downloads the files of one server
+func (d *Downloader) downloadServer(server *SSHConfig, progress *ServerProgress) {
+	d.setState(progress, StateConnecting, nil)
+	client := NewSSHClient(server)
+	if err := client.Connect(); err != nil {
+		d.setState(progress, StateFailed, err)
+		return
+	}
+	conn := newServerConn(server, client)
+	defer conn.close()
+
+	d.setState(progress, StateListing, nil)
+	files := []string{server.LogPath}
+	if !d.options.Single {
+		logDir := filepath.Dir(server.LogPath)
+		if logDir == "." {
+			logDir = "/var/log/nginx"
+		}
+		accessFiles, err := client.ListAccessLogFiles(logDir)
+		if err != nil {
+			d.setState(progress, StateFailed, err)
+			return
+		}
+		files = accessFiles
+		if d.options.MaxFiles > 0 && len(files) > d.options.MaxFiles {
+			files = files[:d.options.MaxFiles]
+		}
+	}
+
+	stats, _ := client.FileStats(files)
+	remoteFiles := make([]RemoteFile, len(files))
+	for i, file := range files {
+		remoteFiles[i] = stats[file]
+		remoteFiles[i].Path = file
+	}
+	var synced []SyncedFile
+	if d.options.Sync != nil {
+		remoteFiles, synced = d.syncFiles(server, remoteFiles)
+	}
+
+	d.mu.Lock()
+	progress.Files = len(remoteFiles)
+	progress.FilesSynced = len(synced)
+	// What a filter leaves of a file is not known until it is downloaded
+	if d.options.TimeRange.IsZero() {
+		for _, file := range remoteFiles {
+			progress.TotalBytes += file.Size
+		}
+	}
+	d.mu.Unlock()
+	d.setState(progress, StateDownloading, nil)
+
+	jobs := make(chan RemoteFile)
+	var wg sync.WaitGroup
+	for i := 0; i < d.options.PerServer; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for remoteFile := range jobs {
+				if file, ok := d.downloadFile(conn, progress, remoteFile); ok {
+					d.mu.Lock()
+					synced = append(synced, file)
+					d.mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, file := range remoteFiles {
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	d.mu.Lock()
+	failed := progress.FilesFailed > 0 && progress.FilesDone == 0
+	d.mu.Unlock()
+	if d.options.Sync != nil {
+		d.options.Sync.update(syncServer(server), synced)
+	}
+	if failed {
+		d.setState(progress, StateFailed, fmt.Errorf("no file could be downloaded"))
+		return
+	}
+	d.setState(progress, StateDone, nil)
+}
+
+// syncServer names a server in the sync state
+func syncServer(server *SSHConfig) string {
+	return fmt.Sprintf("%s:%d", server.Host, server.Port)
+}
+
+// syncFiles sorts the remote files of a server into those to download and
+// those already synced, renaming the local copies of rotated files
+func (d *Downloader) syncFiles(server *SSHConfig, files []RemoteFile) ([]RemoteFile, []SyncedFile) {
+	state := d.options.Sync
+	plans := state.plan(syncServer(server), server.Host, files)
+	state.renameRotated(server.Host, plans)
+
+	var download []RemoteFile
+	var synced []SyncedFile
+	for _, plan := range plans {
+		if plan.action == syncDownload {
+			download = append(download, plan.file)
+			continue
+		}
+		file := plan.from
+		file.RemotePath = plan.file.Path
+		file.LocalFile = syncLocalFile(server.Host, plan.file.Path)
+		synced = append(synced, file)
+	}
+	return download, synced
+}
+
+// serverConn is the connection of a download to a server, shared by its
+// files and replaced when a transfer over it fails
+type serverConn struct {
+	server *SSHConfig
+	stop   chan struct{} // Ends the keepalives
+
+	mu      sync.Mutex
+	client  *SSHClient
+	broken  bool
+	clients []*SSHClient // Every connection made, closed at the end
+}
+
+func newServerConn(server *SSHConfig, client *SSHClient) *serverConn {
+	c := &serverConn{server: server, stop: make(chan struct{}), client: client, clients: []*SSHClient{client}}
+	go client.keepAlive(c.stop)
+	return c
+}
+
+// get returns the connection, reconnecting if it failed
+func (c *serverConn) get() (*SSHClient, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.broken {
+		client := NewSSHClient(c.server)
+		if err := client.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to reconnect: %w", err)
+		}
+		go client.keepAlive(c.stop)
+		c.client, c.broken = client, false
+		c.clients = append(c.clients, client)
+	}
+	return c.client, nil
+}
+
+// check passes on the error of a transfer over a connection, marking the
+// connection for replacement when it may be the cause
+func (c *serverConn) check(client *SSHClient, err error) error {
+	if err != nil && retryable(err) {
+		c.mu.Lock()
+		if c.client == client {
+			c.broken = true
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+func (c *serverConn) close() {
+	close(c.stop)
+	for _, client := range c.clients {
+		client.Close()
+	}
+}
+
+// downloadFile downloads one file of a server, named after the server, the
+// time of the run and the remote file, or without the time when syncing, and
+// ending in .gz when filtered. It returns the file to record in the sync
+// state, if any.
+func (d *Downloader) downloadFile(conn *serverConn, progress *ServerProgress, remoteFile RemoteFile) (SyncedFile, bool) {
+	server := conn.server
+	localFile := fmt.Sprintf("%s_%s_%s", server.Host, d.timestamp, filepath.Base(remoteFile.Path))
+	if d.options.Sync != nil {
+		localFile = syncLocalFile(server.Host, remoteFile.Path)
+	}
+	if !d.options.TimeRange.IsZero() && !strings.HasSuffix(localFile, ".gz") {
+		localFile += ".gz"
+	}
+	localPath := filepath.Join(d.options.OutputDir, localFile)
+
+	// Only the names of synced files are the same next run
+	t := transfer{size: -1, modTime: remoteFile.ModTime, keepPartial: d.options.Sync != nil}
+	switch {
+	case !d.options.TimeRange.IsZero():
+		// Filtered output differs each time, but its gzip trailer checks it
+		t.fetch = func(offset int64, dst io.Writer) (int64, string, error) {
+			client, err := conn.get()
+			if err != nil {
+				return 0, "", err
+			}
+			copied, err := client.CopyFilteredFile(remoteFile.Path, d.options.TimeRange, dst)
+			return copied, "", conn.check(client, err)
+		}
+		t.verify = verifyGzip
+	case remoteFile.ModTime.IsZero():
+		// The file could not be stat'ed, so its size is unknown
+		t.fetch = func(offset int64, dst io.Writer) (int64, string, error) {
+			client, err := conn.get()
+			if err != nil {
+				return 0, "", err
+			}
+			copied, err := client.CopyFile(remoteFile.Path, dst)
+			return copied, "", conn.check(client, err)
+		}
+	default:
+		t.size = remoteFile.Size
+		t.resumable = true
+		t.fetch = func(offset int64, dst io.Writer) (int64, string, error) {
+			client, err := conn.get()
+			if err != nil {
+				return 0, "", err
+			}
+			copied, err := client.CopyFileRange(remoteFile.Path, offset, remoteFile.Size-offset, dst)
+			if err != nil || d.options.NoVerify {
+				return copied, "", conn.check(client, err)
+			}
+			checksum, err := client.FileChecksum(remoteFile.Path, remoteFile.Size)
+			return copied, checksum, conn.check(client, err)
+		}
+	}
+
+	copied, err := d.copyFile(progress, server.Host, remoteFile.Path, localPath, t)
+	d.mu.Lock()
+	if err != nil {
+		progress.FilesFailed++
+	} else {
+		progress.FilesDone++
+		progress.Downloaded = append(progress.Downloaded, localPath)
+	}
+	d.mu.Unlock()
+	d.events(DownloadEvent{Host: server.Host, RemotePath: remoteFile.Path, LocalPath: localPath, Bytes: copied, Err: err})
+
+	// A file whose mtime is unknown cannot be compared next time
+	if err != nil || remoteFile.ModTime.IsZero() {
+		return SyncedFile{}, false
+	}
+	return SyncedFile{
+		Server:     syncServer(server),
+		RemotePath: remoteFile.Path,
+		Size:       remoteFile.Size,
+		ModTime:    remoteFile.ModTime,
+		LocalFile:  localFile,
+		SyncedAt:   time.Now(),
+	}, true
+}
+
+// transfer is how to download one file: fetch streams it to dst from an
+// offset, returning the checksum of the whole file as "algorithm:hex", or ""
+// when none is known
+type transfer struct {
+	size        int64     // -1 when unknown
+	modTime     time.Time // Of the remote file, to tell whether a partial copy is of it
+	resumable   bool      // fetch can start past 0
+	keepPartial bool      // The local path is the same next run, which can resume
+	fetch       func(offset int64, dst io.Writer) (int64, string, error)
+	verify      func(filename string) error // A further check of the file, if set
+}
+
+// partPath is where a file is written until complete, hidden so that
+// analysing a download directory skips it
+func partPath(localPath string) string {
+	return filepath.Join(filepath.Dir(localPath), "."+filepath.Base(localPath)+".part")
+}
+
+// copyFile downloads a file to a local path, counting the bytes into the
+// server's progress as they arrive, and retrying failures after a growing
+// delay. The file is written beside the path, resuming what an earlier
+// attempt left there, and renamed into place once its size and checksum are
+// checked, so a failure leaves an earlier copy.
+func (d *Downloader) copyFile(progress *ServerProgress, host, remotePath, localPath string, t transfer) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create local directory: %w", err)
+	}
+	part := partPath(localPath)
+	var counted int64 // Of this file, in the server's progress
+	for attempt := 0; ; attempt++ {
+		size, err := d.attemptCopy(progress, part, t, &counted)
+		if err == nil {
+			if err = os.Rename(part, localPath); err == nil {
+				return size, nil
+			}
+		}
+		if attempt >= d.options.Retries || !retryable(err) {
+			// A partial copy the next run can resume is kept
+			if !t.resumable || !t.keepPartial || size == 0 || !retryable(err) {
+				os.Remove(part)
+			}
+			return size, err
+		}
+		delay := backoff(attempt)
+		d.mu.Lock()
+		progress.Retries++
+		d.mu.Unlock()
+		d.events(DownloadEvent{Host: host, RemotePath: remotePath, LocalPath: localPath, Err: err, Retry: delay})
+		time.Sleep(delay)
+	}
+}
+
+// attemptCopy makes one try at a transfer into a part file, resuming a
+// partial copy of the same remote file, and checks the result
+func (d *Downloader) attemptCopy(progress *ServerProgress, part string, t transfer, counted *int64) (int64, error) {
+	var offset int64
+	if info, err := os.Stat(part); err == nil && t.resumable && info.Size() < t.size && info.ModTime().After(t.modTime) {
+		offset = info.Size()
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	localFile, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return 0, permanentError{fmt.Errorf("failed to create local file: %w", err)}
+	}
+	defer localFile.Close()
+
+	// The progress counts what the part file holds
+	d.mu.Lock()
+	progress.Bytes += offset - *counted
+	d.mu.Unlock()
+	*counted = offset
+	writer := &progressWriter{dst: localFile, limiter: d.limiter, count: func(n int) {
+		d.mu.Lock()
+		progress.Bytes += int64(n)
+		d.mu.Unlock()
+		*counted += int64(n)
+	}}
+	copied, checksum, err := t.fetch(offset, writer)
+	size := offset + copied
+	if err == nil {
+		err = localFile.Close()
+	}
+	if err != nil {
+		return size, err
+	}
+
+	if t.size >= 0 && size != t.size {
+		if size > t.size {
+			os.Remove(part)
+		}
+		return size, fmt.Errorf("size mismatch: got %d of %d bytes", size, t.size)
+	}
+	if d.options.NoVerify {
+		return size, nil
+	}
+	if err := verifyChecksum(part, checksum); err != nil {
+		os.Remove(part)
+		return size, err
+	}
+	if t.verify != nil {
+		if err := t.verify(part); err != nil {
+			os.Remove(part)
+			return size, err
+		}
+	}
+	return size, nil
+}
+
+// progressWriter counts and rate limits the bytes written through it
+type progressWriter struct {
+	dst     io.Writer
+	limiter *rateLimiter
+	count   func(n int)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > rateLimitChunk {
+			chunk = chunk[:rateLimitChunk]
+		}
+		if w.limiter != nil {
+			w.limiter.wait(len(chunk))
+		}
+		n, err := w.dst.Write(chunk)
+		written += n
+		w.count(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// rateLimitChunk bounds the bytes written per wait, so the limit is smooth
+const rateLimitChunk = 16 * 1024
+
+// rateLimiter is a token bucket of bytes shared by all downloads, holding
+// up to a second's worth
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond), last: time.Now()}
+}
+
+// wait blocks until n bytes may pass
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
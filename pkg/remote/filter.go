@@ -0,0 +1,68 @@
+package remote
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// timeZoneSlack widens a time range by the largest UTC offset, as lines are
+// compared by their local time without their zone
+const timeZoneSlack = 14 * time.Hour
+
+// TimeRange selects the log lines a download keeps, filtered on the server;
+// a nil bound is open
+type TimeRange struct {
+	Since *time.Time
+	Until *time.Time
+}
+
+// IsZero reports whether the range keeps every line
+func (r TimeRange) IsZero() bool {
+	return r.Since == nil && r.Until == nil
+}
+
+// filterProgram is the awk program keeping the lines whose [02/Jan/2006:15:04:05
+// timestamp is within lo and hi, as yyyymmddHHMMSS keys. Lines without one
+// are kept, so nothing the analyser could use is lost.
+const filterProgram = `{
+	i = index($0, "[")
+	if (i == 0) { print; next }
+	t = substr($0, i + 1, 20)
+	m = index("JanFebMarAprMayJunJulAugSepOctNovDec", substr(t, 4, 3))
+	if (m == 0 || substr(t, 3, 1) != "/" || substr(t, 12, 1) != ":") { print; next }
+	k = substr(t, 8, 4) sprintf("%02d", (m + 2) / 3) substr(t, 1, 2) substr(t, 13, 2) substr(t, 16, 2) substr(t, 19, 2)
+	if (k >= lo && k <= hi) print
+}`
+
+// filterCommand returns the shell command that writes the lines of a remote
+// log within the range, decompressing .gz files, gzip compressed for the
+// transfer. The range is widened by timeZoneSlack, so it keeps every line in
+// range whatever the zone of the log; the analyser's --since and --until
+// narrow it exactly.
+func (r TimeRange) filterCommand(path string) string {
+	lo, hi := "0", "99999999999999"
+	if r.Since != nil {
+		lo = r.Since.UTC().Add(-timeZoneSlack).Format("20060102150405")
+	}
+	if r.Until != nil {
+		hi = r.Until.UTC().Add(timeZoneSlack).Format("20060102150405")
+	}
+
+	quoted := shellQuote(path)
+	awk := fmt.Sprintf("awk -v lo=%s -v hi=%s %s", lo, hi, shellQuote(filterProgram))
+	source := awk + " " + quoted
+	if strings.HasSuffix(path, ".gz") {
+		source = "gzip -dc " + quoted + " | " + awk
+	}
+	// The exit status of a pipeline is gzip's, so an unreadable file is
+	// caught first
+	return fmt.Sprintf("[ -r %s ] || { echo %s >&2; exit 1; }; %s | gzip -c", quoted, shellQuote("cannot read "+path), source)
+}
+
+// CopyFilteredFile streams the lines of a remote log within a time range to
+// dst, gzip compressed, returning the compressed bytes copied
+func (c *SSHClient) CopyFilteredFile(remotePath string, timeRange TimeRange, dst io.Writer) (int64, error) {
+	return c.copyCommand(timeRange.filterCommand(remotePath), dst)
+}
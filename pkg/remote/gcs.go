@@ -0,0 +1,334 @@
+package remote
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GCS defaults
+const (
+	gcsDefaultEndpoint = "https://storage.googleapis.com"
+	gcsReadOnlyScope   = "https://www.googleapis.com/auth/devstorage.read_only"
+	gcsTokenURL        = "https://oauth2.googleapis.com/token"
+)
+
+// GCSBucket is a Google Cloud Storage bucket, read through the JSON API
+type GCSBucket struct {
+	Bucket string
+
+	endpoint string
+	http     *http.Client
+	token    *bearerToken // nil for anonymous requests
+}
+
+// gcsCredentials is the part of an application default credentials file the
+// client uses: a service account key or a gcloud user login
+type gcsCredentials struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// gcsObjectList is a page of an objects listing
+type gcsObjectList struct {
+	Items []struct {
+		Name    string    `json:"name"`
+		Size    string    `json:"size"` // A decimal string
+		Updated time.Time `json:"updated"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// gcsError is the error document the API answers failed requests with
+type gcsError struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewGCSBucket connects to a bucket with the application default
+// credentials, as gcloud finds them: the key file of
+// GOOGLE_APPLICATION_CREDENTIALS, the login of gcloud auth
+// application-default login, or the service account of the Compute Engine
+// instance, GKE pod or Cloud Run service. Without any, requests are
+// anonymous, for public buckets.
+func NewGCSBucket(bucket string, options BucketOptions) (*GCSBucket, error) {
+	b := &GCSBucket{Bucket: bucket, endpoint: gcsDefaultEndpoint, http: objectHTTPClient()}
+	if options.Endpoint != "" {
+		endpoint, err := url.Parse(options.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, expected a URL such as http://localhost:4443", options.Endpoint)
+		}
+		b.endpoint = strings.TrimSuffix(options.Endpoint, "/")
+	}
+
+	token, err := findGCSToken()
+	if err != nil {
+		return nil, err
+	}
+	b.token = token
+	return b, nil
+}
+
+// Name returns the URL of the bucket
+func (b *GCSBucket) Name() string {
+	return "gs://" + b.Bucket
+}
+
+// ListObjects returns the objects whose name starts with prefix
+func (b *GCSBucket) ListObjects(prefix string) ([]RemoteFile, error) {
+	var objects []RemoteFile
+	token := ""
+	for {
+		query := url.Values{"prefix": {prefix}, "fields": {"items(name,size,updated),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		response, err := b.get("/storage/v1/b/"+url.PathEscape(b.Bucket)+"/o", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s/%s: %w", b.Name(), prefix, err)
+		}
+		var list gcsObjectList
+		err = json.NewDecoder(response.Body).Decode(&list)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse listing of %s: %w", b.Name(), err)
+		}
+		for _, item := range list.Items {
+			size, _ := strconv.ParseInt(item.Size, 10, 64)
+			objects = append(objects, RemoteFile{Path: item.Name, Size: size, ModTime: item.Updated})
+		}
+		if list.NextPageToken == "" {
+			break
+		}
+		token = list.NextPageToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return objects, nil
+}
+
+// CopyObject streams an object from offset to dst, with its MD5, or its
+// CRC32C when it was uploaded in parts. Accepting gzip keeps an object stored
+// with Content-Encoding gzip as stored, which its checksums are of.
+func (b *GCSBucket) CopyObject(key string, offset int64, dst io.Writer) (int64, string, error) {
+	header := http.Header{"Accept-Encoding": {"gzip"}}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	response, err := b.get("/storage/v1/b/"+url.PathEscape(b.Bucket)+"/o/"+url.PathEscape(key), url.Values{"alt": {"media"}}, header)
+	if err != nil {
+		return 0, "", err
+	}
+	hashes := make(map[string]string)
+	for _, value := range response.Header.Values("X-Goog-Hash") {
+		for _, hash := range strings.Split(value, ",") {
+			if algorithm, digest, ok := strings.Cut(strings.TrimSpace(hash), "="); ok {
+				hashes[algorithm] = digest
+			}
+		}
+	}
+	checksum := base64Checksum("md5", hashes["md5"])
+	if checksum == "" {
+		checksum = base64Checksum("crc32c", hashes["crc32c"])
+	}
+	copied, err := copyResponse(response, offset, dst)
+	return copied, checksum, err
+}
+
+// get calls the API with extra headers, if any, returning the response of a
+// successful call
+func (b *GCSBucket) get(path string, query url.Values, header http.Header) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, b.endpoint+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	if b.token != nil {
+		token, err := b.token.get()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get a Google access token: %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := b.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+		var gcsErr gcsError
+		if json.Unmarshal(body, &gcsErr) == nil && gcsErr.Error.Message != "" {
+			return nil, httpStatusError(response.StatusCode, fmt.Errorf("%s (HTTP %d)", gcsErr.Error.Message, response.StatusCode))
+		}
+		return nil, httpStatusError(response.StatusCode, fmt.Errorf("HTTP %d", response.StatusCode))
+	}
+	return response, nil
+}
+
+// findGCSToken finds the application default credentials, returning nil
+// when there are none
+func findGCSToken() (*bearerToken, error) {
+	filename := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if filename == "" {
+		filename = gcloudCredentialsFile()
+	}
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		var credentials gcsCredentials
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			return nil, fmt.Errorf("failed to parse Google credentials %s: %w", filename, err)
+		}
+		switch credentials.Type {
+		case "service_account":
+			key, err := parseRSAPrivateKey(credentials.PrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("invalid private key in %s: %w", filename, err)
+			}
+			return &bearerToken{fetch: func() (string, time.Duration, error) {
+				return serviceAccountToken(credentials, key)
+			}}, nil
+		case "authorized_user":
+			return &bearerToken{fetch: func() (string, time.Duration, error) {
+				return refreshUserToken(credentials)
+			}}, nil
+		default:
+			return nil, fmt.Errorf("unsupported Google credentials type %q in %s", credentials.Type, filename)
+		}
+	}
+	if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" || !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read Google credentials: %w", err)
+	}
+
+	// The service account of the instance, when on Google Cloud
+	token := &bearerToken{fetch: metadataServerToken}
+	if _, err := token.get(); err != nil {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// gcloudCredentialsFile is where gcloud auth application-default login saves
+// its credentials
+func gcloudCredentialsFile() string {
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gcloud")
+		}
+	}
+	return filepath.Join(dir, "application_default_credentials.json")
+}
+
+// parseRSAPrivateKey parses the PEM private key of a service account
+func parseRSAPrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("no PEM key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+	return key, nil
+}
+
+// serviceAccountToken exchanges a JWT signed with the service account's key
+// for an access token
+func serviceAccountToken(credentials gcsCredentials, key *rsa.PrivateKey) (string, time.Duration, error) {
+	tokenURL := credentials.TokenURI
+	if tokenURL == "" {
+		tokenURL = gcsTokenURL
+	}
+	now := time.Now()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   credentials.ClientEmail,
+		"scope": gcsReadOnlyScope,
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", 0, err
+	}
+	assertion := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	return postTokenForm(tokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// refreshUserToken gets an access token for a gcloud user login
+func refreshUserToken(credentials gcsCredentials) (string, time.Duration, error) {
+	return postTokenForm(gcsTokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {credentials.ClientID},
+		"client_secret": {credentials.ClientSecret},
+		"refresh_token": {credentials.RefreshToken},
+	})
+}
+
+// metadataServerToken gets an access token of the instance's service account
+// from the metadata server
+func metadataServerToken() (string, time.Duration, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "169.254.169.254"
+	}
+	request, err := http.NewRequest(http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Metadata-Flavor", "Google")
+	return fetchToken(&http.Client{Timeout: metadataTimeout}, request)
+}
+
+// postTokenForm sends a form to an OAuth token endpoint
+func postTokenForm(tokenURL string, form url.Values) (string, time.Duration, error) {
+	request, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return fetchToken(&http.Client{Timeout: 30 * time.Second}, request)
+}
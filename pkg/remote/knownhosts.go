@@ -0,0 +1,133 @@
+package remote
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy verifies server host keys against a known_hosts file. Hosts
+// missing from it are trusted on first use when AcceptNew is set or Prompt
+// agrees, and recorded; a changed key is always rejected.
+type HostKeyPolicy struct {
+	KnownHostsFile string // Default ~/.ssh/known_hosts
+	AcceptNew      bool   // Trust and record unknown hosts without asking
+
+	// Prompt asks whether to trust an unknown host, given its key type and
+	// SHA256 fingerprint; nil rejects unknown hosts
+	Prompt func(host, keyType, fingerprint string) bool
+}
+
+// DefaultHostKeyPolicy is the policy every SSH connection uses
+var DefaultHostKeyPolicy = HostKeyPolicy{}
+
+// DefaultKnownHostsFile returns ~/.ssh/known_hosts
+func DefaultKnownHostsFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".ssh", "known_hosts")
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// Path returns the known_hosts file of the policy
+func (p HostKeyPolicy) Path() string {
+	if p.KnownHostsFile != "" {
+		return p.KnownHostsFile
+	}
+	return DefaultKnownHostsFile()
+}
+
+// hostKeyCallback returns the host key callback of the policy for a server
+// address, and the host key algorithms to ask the server for: those of the
+// keys known for it, so a server with several keys offers a known one
+func (p HostKeyPolicy) hostKeyCallback(addr string) (ssh.HostKeyCallback, []string, error) {
+	path := p.Path()
+	known, err := knownhosts.New(path)
+	if os.IsNotExist(err) {
+		// No file yet: every host is unknown until trusted
+		known = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("failed to read known hosts %s: %w", path, err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		fingerprint := ssh.FingerprintSHA256(key)
+		if len(keyErr.Want) > 0 {
+			want := keyErr.Want[0]
+			return fmt.Errorf("host key of %s has changed to %s %s but %s line %d has %s - possible man-in-the-middle attack; remove the line if the server was reinstalled",
+				hostname, key.Type(), fingerprint, want.Filename, want.Line, ssh.FingerprintSHA256(want.Key))
+		}
+
+		if !p.AcceptNew && (p.Prompt == nil || !p.Prompt(hostname, key.Type(), fingerprint)) {
+			return fmt.Errorf("host key of %s (%s %s) is not in %s", hostname, key.Type(), fingerprint, path)
+		}
+		if err := addKnownHost(path, hostname, key); err != nil {
+			return fmt.Errorf("failed to record host key of %s: %w", hostname, err)
+		}
+		return nil
+	}, knownAlgorithms(known, addr), nil
+}
+
+// knownAlgorithms returns the host key algorithms of the keys known for an
+// address, found by checking a key no host has; nil when none are known
+func knownAlgorithms(known ssh.HostKeyCallback, addr string) []string {
+	remote, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if err := known(addr, remote, probe); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	for _, want := range keyErr.Want {
+		switch keyType := want.Key.Type(); keyType {
+		case ssh.KeyAlgoRSA:
+			// RSA keys sign with SHA-2 on current servers
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, keyType)
+		}
+	}
+	return algorithms
+}
+
+// addKnownHost appends the key of a host to a known_hosts file, creating it
+// and its directory owner-only if missing
+func addKnownHost(path, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := file.WriteString(strings.TrimSpace(line) + "\n"); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,471 @@
+package remote
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serviceAccountDir holds the service account files of a pod, used in a
+// cluster without a kubeconfig
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeConfig is the part of a kubeconfig file the client uses
+type kubeConfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string      `yaml:"name"`
+		Cluster kubeCluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string      `yaml:"name"`
+		Context kubeContext `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string   `yaml:"name"`
+		User kubeUser `yaml:"user"`
+	} `yaml:"users"`
+}
+
+type kubeCluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+	TLSServerName            string `yaml:"tls-server-name"`
+	dir                      string // Of the kubeconfig, for relative paths
+}
+
+type kubeContext struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace"`
+}
+
+type kubeUser struct {
+	Token                 string     `yaml:"token"`
+	TokenFile             string     `yaml:"tokenFile"`
+	ClientCertificate     string     `yaml:"client-certificate"`
+	ClientCertificateData string     `yaml:"client-certificate-data"`
+	ClientKey             string     `yaml:"client-key"`
+	ClientKeyData         string     `yaml:"client-key-data"`
+	Username              string     `yaml:"username"`
+	Password              string     `yaml:"password"`
+	Exec                  *kubeExec  `yaml:"exec"`
+	AuthProvider          *yaml.Node `yaml:"auth-provider"`
+	dir                   string
+}
+
+// kubeExec is a credential plugin, such as aws eks get-token or
+// gke-gcloud-auth-plugin
+type kubeExec struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+	Env        []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+// KubeClient calls the Kubernetes API of a cluster
+type KubeClient struct {
+	Context   string // Name of the kubeconfig context, or "in-cluster"
+	Namespace string // Of the context, "default" if it has none
+
+	server string
+	http   *http.Client
+	auth   func(*http.Request) error
+}
+
+// DefaultKubeconfig returns the kubeconfig files of $KUBECONFIG, or else
+// ~/.kube/config
+func DefaultKubeconfig() []string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return []string{filepath.Join(".kube", "config")}
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// NewKubeClient connects to the cluster of a kubeconfig context, the current
+// one when context is "". Without a kubeconfig path the files of
+// DefaultKubeconfig are merged, and in a pod without any the service account
+// is used.
+func NewKubeClient(kubeconfig, context string) (*KubeClient, error) {
+	paths := DefaultKubeconfig()
+	if kubeconfig != "" {
+		paths = []string{kubeconfig}
+	}
+
+	config, found, err := loadKubeconfig(paths)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if kubeconfig == "" && context == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+			return inClusterClient()
+		}
+		return nil, fmt.Errorf("no kubeconfig found at %s", strings.Join(paths, ", "))
+	}
+
+	if context == "" {
+		context = config.CurrentContext
+	}
+	if context == "" {
+		return nil, fmt.Errorf("kubeconfig has no current context; pass one with --context")
+	}
+	var kubeCtx *kubeContext
+	for i := range config.Contexts {
+		if config.Contexts[i].Name == context {
+			kubeCtx = &config.Contexts[i].Context
+			break
+		}
+	}
+	if kubeCtx == nil {
+		return nil, fmt.Errorf("no context %q in kubeconfig", context)
+	}
+
+	var cluster *kubeCluster
+	for i := range config.Clusters {
+		if config.Clusters[i].Name == kubeCtx.Cluster {
+			cluster = &config.Clusters[i].Cluster
+			break
+		}
+	}
+	if cluster == nil || cluster.Server == "" {
+		return nil, fmt.Errorf("context %q has no cluster %q with a server", context, kubeCtx.Cluster)
+	}
+	var user kubeUser
+	for i := range config.Users {
+		if config.Users[i].Name == kubeCtx.User {
+			user = config.Users[i].User
+			break
+		}
+	}
+
+	tlsConfig, err := cluster.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("context %q: %w", context, err)
+	}
+	auth, err := user.authenticate(tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("context %q: %w", context, err)
+	}
+
+	namespace := kubeCtx.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return &KubeClient{
+		Context:   context,
+		Namespace: namespace,
+		server:    strings.TrimSuffix(cluster.Server, "/"),
+		http:      kubeHTTPClient(tlsConfig),
+		auth:      auth,
+	}, nil
+}
+
+// loadKubeconfig merges kubeconfig files, the first definition of a name
+// winning as with kubectl; found is false when none of the files exist
+func loadKubeconfig(paths []string) (config kubeConfig, found bool, err error) {
+	clusters, contexts, users := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, path := range paths {
+		data, err := os.ReadFile(ExpandHome(path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return config, false, fmt.Errorf("failed to read kubeconfig: %w", err)
+		}
+		var file kubeConfig
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return config, false, fmt.Errorf("failed to parse kubeconfig %s: %w", path, err)
+		}
+		found = true
+		dir := filepath.Dir(ExpandHome(path))
+
+		if config.CurrentContext == "" {
+			config.CurrentContext = file.CurrentContext
+		}
+		for _, cluster := range file.Clusters {
+			if !clusters[cluster.Name] {
+				clusters[cluster.Name] = true
+				cluster.Cluster.dir = dir
+				config.Clusters = append(config.Clusters, cluster)
+			}
+		}
+		for _, context := range file.Contexts {
+			if !contexts[context.Name] {
+				contexts[context.Name] = true
+				config.Contexts = append(config.Contexts, context)
+			}
+		}
+		for _, user := range file.Users {
+			if !users[user.Name] {
+				users[user.Name] = true
+				user.User.dir = dir
+				config.Users = append(config.Users, user)
+			}
+		}
+	}
+	return config, found, nil
+}
+
+// inClusterClient connects with the service account of the pod it runs in
+func inClusterClient() (*KubeClient, error) {
+	ca, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates in service account CA")
+	}
+	namespace := "default"
+	if data, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+		namespace = strings.TrimSpace(string(data))
+	}
+
+	return &KubeClient{
+		Context:   "in-cluster",
+		Namespace: namespace,
+		server:    "https://" + net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")),
+		http:      kubeHTTPClient(&tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}),
+		auth:      tokenFileAuth(filepath.Join(serviceAccountDir, "token")),
+	}, nil
+}
+
+// kubeHTTPClient returns a client without an overall timeout, as followed
+// logs stream for as long as they run
+func kubeHTTPClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}}
+}
+
+// tlsConfig returns the TLS settings of a cluster
+func (c *kubeCluster) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.InsecureSkipTLSVerify,
+	}
+	ca, err := fileOrData(c.CertificateAuthority, c.CertificateAuthorityData, c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate authority: %w", err)
+	}
+	if ca != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in certificate authority")
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// authenticate sets up the credentials of a user, adding a client
+// certificate to the TLS settings and returning what signs each request
+func (u *kubeUser) authenticate(tlsConfig *tls.Config) (func(*http.Request) error, error) {
+	if u.AuthProvider != nil {
+		return nil, fmt.Errorf("auth-provider users are not supported; switch to the exec credential plugin of your cloud (such as gke-gcloud-auth-plugin or kubelogin)")
+	}
+
+	cert, err := fileOrData(u.ClientCertificate, u.ClientCertificateData, u.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	key, err := fileOrData(u.ClientKey, u.ClientKeyData, u.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client key: %w", err)
+	}
+	if cert != nil && key != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	switch {
+	case u.Token != "":
+		return func(r *http.Request) error {
+			r.Header.Set("Authorization", "Bearer "+u.Token)
+			return nil
+		}, nil
+	case u.TokenFile != "":
+		return tokenFileAuth(resolvePath(u.TokenFile, u.dir)), nil
+	case u.Exec != nil:
+		plugin := &execCredential{exec: u.Exec}
+		if err := plugin.refresh(); err != nil {
+			return nil, err
+		}
+		if plugin.cert != nil {
+			tlsConfig.GetClientCertificate = plugin.clientCertificate
+		}
+		return plugin.authorize, nil
+	case u.Username != "":
+		return func(r *http.Request) error {
+			r.SetBasicAuth(u.Username, u.Password)
+			return nil
+		}, nil
+	}
+	return func(*http.Request) error { return nil }, nil
+}
+
+// tokenFileAuth reads a bearer token file for every request, as service
+// account tokens are rotated
+func tokenFileAuth(path string) func(*http.Request) error {
+	return func(r *http.Request) error {
+		token, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		r.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+		return nil
+	}
+}
+
+// execCredential runs a credential plugin, again when its token or
+// certificate expires
+type execCredential struct {
+	exec *kubeExec
+
+	mu      sync.Mutex
+	token   string
+	cert    *tls.Certificate
+	expires time.Time
+}
+
+// execCredentialOutput is the ExecCredential a plugin prints
+type execCredentialOutput struct {
+	Status struct {
+		Token                 string    `json:"token"`
+		ClientCertificateData string    `json:"clientCertificateData"`
+		ClientKeyData         string    `json:"clientKeyData"`
+		ExpirationTimestamp   time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+func (e *execCredential) authorize(r *http.Request) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.expires.IsZero() && time.Now().Add(time.Minute).After(e.expires) {
+		if err := e.run(); err != nil {
+			return err
+		}
+	}
+	if e.token != "" {
+		r.Header.Set("Authorization", "Bearer "+e.token)
+	}
+	return nil
+}
+
+func (e *execCredential) clientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.expires.IsZero() && time.Now().Add(time.Minute).After(e.expires) {
+		if err := e.run(); err != nil {
+			return nil, err
+		}
+	}
+	return e.cert, nil
+}
+
+func (e *execCredential) refresh() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.run()
+}
+
+// run runs the plugin as kubectl does, telling it the run is not interactive
+func (e *execCredential) run() error {
+	apiVersion := e.exec.APIVersion
+	if apiVersion == "" {
+		apiVersion = "client.authentication.k8s.io/v1"
+	}
+	info, err := json.Marshal(map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]interface{}{"interactive": false},
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(e.exec.Command, e.exec.Args...)
+	cmd.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, env := range e.exec.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := lastLine(stderr.String()); message != "" {
+			return fmt.Errorf("credential plugin %s failed: %s", e.exec.Command, message)
+		}
+		return fmt.Errorf("credential plugin %s failed: %w", e.exec.Command, err)
+	}
+
+	var credential execCredentialOutput
+	if err := json.Unmarshal(output, &credential); err != nil {
+		return fmt.Errorf("credential plugin %s printed no ExecCredential: %w", e.exec.Command, err)
+	}
+	status := credential.Status
+	if status.ClientCertificateData != "" && status.ClientKeyData != "" {
+		pair, err := tls.X509KeyPair([]byte(status.ClientCertificateData), []byte(status.ClientKeyData))
+		if err != nil {
+			return fmt.Errorf("credential plugin %s printed an invalid certificate: %w", e.exec.Command, err)
+		}
+		e.cert = &pair
+	} else if status.Token == "" {
+		return fmt.Errorf("credential plugin %s printed no token or certificate", e.exec.Command)
+	}
+	e.token = status.Token
+	e.expires = status.ExpirationTimestamp
+	return nil
+}
+
+// fileOrData returns the content of a kubeconfig field given as a file path
+// or as base64 data; nil when neither is set
+func fileOrData(path, data, dir string) ([]byte, error) {
+	if data != "" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	if path != "" {
+		return os.ReadFile(resolvePath(path, dir))
+	}
+	return nil, nil
+}
+
+// resolvePath resolves a kubeconfig path relative to the kubeconfig's dir
+func resolvePath(path, dir string) string {
+	path = ExpandHome(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,402 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// podRelistInterval is how often followed pods are listed again, to pick up
+// new pods and restart ended streams
+const podRelistInterval = 15 * time.Second
+
+// KubeLogSource selects the pods whose logs are read
+type KubeLogSource struct {
+	Namespace string // "" for the namespace of the context
+	Selector  string // Label selector, such as app.kubernetes.io/name=ingress-nginx
+	Container string // "" for every container of the pods
+}
+
+// KubePod is a pod and the containers whose logs are read
+type KubePod struct {
+	Namespace  string
+	Name       string
+	Containers []string
+	Phase      string
+}
+
+// podList is the part of a PodList the client uses
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Containers []struct {
+				Name string `json:"name"`
+			} `json:"containers"`
+		} `json:"spec"`
+		Status struct {
+			Phase string `json:"phase"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// kubeStatus is the Status the API answers errors with
+type kubeStatus struct {
+	Message string `json:"message"`
+}
+
+// namespace returns the namespace of a source
+func (k *KubeClient) namespace(source KubeLogSource) string {
+	if source.Namespace != "" {
+		return source.Namespace
+	}
+	return k.Namespace
+}
+
+// get calls the API, returning the response of a successful call
+func (k *KubeClient) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, k.server+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.auth(request); err != nil {
+		return nil, err
+	}
+	response, err := k.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+		var status kubeStatus
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			return nil, fmt.Errorf("kubernetes API: %s (HTTP %d)", status.Message, response.StatusCode)
+		}
+		return nil, fmt.Errorf("kubernetes API: HTTP %d", response.StatusCode)
+	}
+	return response, nil
+}
+
+// ListPods returns the pods of a source that have started, with the
+// containers whose logs are read
+func (k *KubeClient) ListPods(source KubeLogSource) ([]KubePod, error) {
+	query := url.Values{}
+	if source.Selector != "" {
+		query.Set("labelSelector", source.Selector)
+	}
+	namespace := k.namespace(source)
+	response, err := k.get(context.Background(), "/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods", query)
+	if err != nil {
+		return nil, fmt.Errorf("namespace %s: %w", namespace, err)
+	}
+	defer response.Body.Close()
+
+	var list podList
+	if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse pods: %w", err)
+	}
+	var pods []KubePod
+	for _, item := range list.Items {
+		if item.Status.Phase == "Pending" || item.Status.Phase == "Unknown" {
+			continue
+		}
+		pod := KubePod{Namespace: item.Metadata.Namespace, Name: item.Metadata.Name, Phase: item.Status.Phase}
+		for _, container := range item.Spec.Containers {
+			if source.Container == "" || container.Name == source.Container {
+				pod.Containers = append(pod.Containers, container.Name)
+			}
+		}
+		if len(pod.Containers) > 0 {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	return pods, nil
+}
+
+// podLogs opens the log of a container, each line prefixed with its
+// RFC3339Nano timestamp; since, if not zero, skips earlier lines
+func (k *KubeClient) podLogs(ctx context.Context, namespace, pod, container string, since time.Time, follow bool) (io.ReadCloser, error) {
+	query := url.Values{"container": {container}, "timestamps": {"true"}}
+	if !since.IsZero() {
+		query.Set("sinceTime", since.UTC().Format(time.RFC3339))
+	}
+	if follow {
+		query.Set("follow", "true")
+	}
+	response, err := k.get(ctx, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/pods/"+url.PathEscape(pod)+"/log", query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log of %s/%s: %w", pod, container, err)
+	}
+	return response.Body, nil
+}
+
+// readPodLog reads timestamped log lines, passing on those after since
+// (sinceTime is only precise to the second) without their timestamp
+func readPodLog(log io.Reader, since time.Time, line func(timestamp time.Time, text string) bool) error {
+	reader := bufio.NewReader(log)
+	for {
+		text, err := reader.ReadString('\n')
+		if text = strings.TrimRight(text, "\r\n"); text != "" {
+			timestamp, rest := splitLogTimestamp(text)
+			if timestamp.IsZero() || timestamp.After(since) {
+				if !line(timestamp, rest) {
+					return nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// splitLogTimestamp splits the timestamp the API prefixes a line with from
+// the line; a zero time when there is none
+func splitLogTimestamp(line string) (time.Time, string) {
+	i := strings.IndexByte(line, ' ')
+	if i < 0 {
+		return time.Time{}, line
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, line[:i])
+	if err != nil {
+		return time.Time{}, line
+	}
+	return timestamp, line[i+1:]
+}
+
+// DownloadPodLog saves the log of a container to a local path, only the lines
+// within a time range when it is set, returning the bytes written
+func (k *KubeClient) DownloadPodLog(pod KubePod, container, localPath string, timeRange TimeRange) (int64, error) {
+	var since time.Time
+	if timeRange.Since != nil {
+		since = timeRange.Since.Add(-time.Nanosecond)
+	}
+	log, err := k.podLogs(context.Background(), pod.Namespace, pod.Name, container, since, false)
+	if err != nil {
+		return 0, err
+	}
+	defer log.Close()
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create local directory: %w", err)
+	}
+	partPath := localPath + ".part"
+	file, err := os.Create(partPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	var written int64
+	var writeErr error
+	err = readPodLog(log, since, func(timestamp time.Time, text string) bool {
+		if timeRange.Until != nil && timestamp.After(*timeRange.Until) {
+			return false
+		}
+		n, err := writer.WriteString(text + "\n")
+		written += int64(n)
+		writeErr = err
+		return err == nil
+	})
+	if err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Close()
+	}
+	if err == nil {
+		err = os.Rename(partPath, localPath)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(partPath)
+		return written, fmt.Errorf("failed to download log of %s/%s: %w", pod.Name, container, err)
+	}
+	return written, nil
+}
+
+// PodFollower follows the logs of the running pods of a source as they are
+// written, picking up pods that start later and restarting streams that end
+type PodFollower struct {
+	client *KubeClient
+	source KubeLogSource
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	lines   []string
+	listErr error
+	streams map[string]*podStream
+}
+
+// podStream is the followed log of a container
+type podStream struct {
+	running bool
+	last    time.Time // Of the last line read, to resume after it
+	err     error
+}
+
+// FollowPods starts following the logs of the running pods of a source, from
+// their beginning or from now when only new lines are wanted
+func FollowPods(client *KubeClient, source KubeLogSource, fromEnd bool) (*PodFollower, error) {
+	if _, err := client.ListPods(source); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &PodFollower{client: client, source: source, ctx: ctx, cancel: cancel, streams: make(map[string]*podStream)}
+	var start time.Time
+	if fromEnd {
+		start = time.Now()
+	}
+	f.relist(start)
+	go f.run()
+	return f, nil
+}
+
+// Name describes the followed pods, as context/namespace and selector
+func (f *PodFollower) Name() string {
+	name := fmt.Sprintf("%s/%s", f.client.Context, f.client.namespace(f.source))
+	if f.source.Selector != "" {
+		name += " " + f.source.Selector
+	}
+	return name
+}
+
+// Poll returns the lines received since the last poll, or what went wrong
+// when there are none
+func (f *PodFollower) Poll() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lines := f.lines
+	f.lines = nil
+	if len(lines) > 0 {
+		return lines, nil
+	}
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	keys := make([]string, 0, len(f.streams))
+	for key := range f.streams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := f.streams[key].err; err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// Close stops following the pods
+func (f *PodFollower) Close() error {
+	f.cancel()
+	return nil
+}
+
+// run lists the pods again until closed
+func (f *PodFollower) run() {
+	ticker := time.NewTicker(podRelistInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.ctx.Done():
+			return
+		case <-ticker.C:
+			f.relist(time.Time{})
+		}
+	}
+}
+
+// relist starts following the containers of running pods that are not
+// followed; pods seen for the first time are read from start
+func (f *PodFollower) relist(start time.Time) {
+	pods, err := f.client.ListPods(f.source)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listErr = err
+	if err != nil {
+		return
+	}
+
+	current := make(map[string]bool)
+	for _, pod := range pods {
+		if pod.Phase != "Running" {
+			continue
+		}
+		for _, container := range pod.Containers {
+			key := pod.Name + "/" + container
+			current[key] = true
+			stream := f.streams[key]
+			if stream == nil {
+				stream = &podStream{last: start}
+				f.streams[key] = stream
+			}
+			if !stream.running {
+				stream.running = true
+				go f.follow(pod, container, stream)
+			}
+		}
+	}
+	// Deleted pods are not coming back
+	for key, stream := range f.streams {
+		if !current[key] && !stream.running {
+			delete(f.streams, key)
+		}
+	}
+}
+
+// follow streams the log of a container until it ends
+func (f *PodFollower) follow(pod KubePod, container string, stream *podStream) {
+	f.mu.Lock()
+	since := stream.last
+	f.mu.Unlock()
+
+	log, err := f.client.podLogs(f.ctx, pod.Namespace, pod.Name, container, since, true)
+	if err != nil {
+		f.mu.Lock()
+		stream.running, stream.err = false, err
+		f.mu.Unlock()
+		return
+	}
+	defer log.Close()
+
+	f.mu.Lock()
+	stream.err = nil
+	f.mu.Unlock()
+	readPodLog(log, since, func(timestamp time.Time, text string) bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.lines = append(f.lines, text)
+		if !timestamp.IsZero() {
+			stream.last = timestamp
+		}
+		return true
+	})
+
+	f.mu.Lock()
+	stream.running = false
+	f.mu.Unlock()
+}
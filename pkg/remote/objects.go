@@ -0,0 +1,368 @@
+package remote
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataTimeout bounds each request to the metadata service of a cloud
+// instance, which only answers on that cloud
+const metadataTimeout = 2 * time.Second
+
+// ObjectStore is a bucket of archived logs in object storage
+type ObjectStore interface {
+	// Name identifies the bucket, as its URL without a prefix
+	Name() string
+	// ListObjects returns the objects whose key starts with prefix, their key
+	// as Path
+	ListObjects(prefix string) ([]RemoteFile, error)
+	// CopyObject streams an object from offset to dst, returning the bytes
+	// copied and the checksum of the whole object as "algorithm:hex", or ""
+	// when the store has none
+	CopyObject(key string, offset int64, dst io.Writer) (int64, string, error)
+}
+
+// BucketOptions configure the connection to a bucket
+type BucketOptions struct {
+	Endpoint string // For S3-compatible services such as MinIO, "" for AWS
+	Region   string // "" for the region of the environment
+}
+
+// OpenBucket connects to the bucket of a URL such as s3://bucket/prefix,
+// returning it and the prefix
+func OpenBucket(rawURL string, options BucketOptions) (ObjectStore, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid bucket URL %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("invalid bucket URL %q: no bucket", rawURL)
+	}
+	prefix := strings.TrimPrefix(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		bucket, err := NewS3Bucket(u.Host, options)
+		return bucket, prefix, err
+	case "gs":
+		bucket, err := NewGCSBucket(u.Host, options)
+		return bucket, prefix, err
+	case "az":
+		// The account is the host, the container the first path element
+		container, prefix, _ := strings.Cut(prefix, "/")
+		if container == "" {
+			return nil, "", fmt.Errorf("invalid bucket URL %q: no container, expected az://account/container/prefix", rawURL)
+		}
+		bucket, err := NewAzureContainer(u.Host, container, options)
+		return bucket, prefix, err
+	default:
+		return nil, "", fmt.Errorf("unsupported bucket URL %q, expected s3://bucket/prefix, gs://bucket/prefix or az://account/container/prefix", rawURL)
+	}
+}
+
+// objectHTTPClient returns the HTTP client of object downloads, which saves
+// objects as stored, even those uploaded with a Content-Encoding
+func objectHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = true
+	return &http.Client{Transport: transport}
+}
+
+// copyResponse streams the body of a download from offset to dst, failing
+// when it ends before its Content-Length. A server that ignored the range
+// sends the whole object, whose start is skipped.
+func copyResponse(response *http.Response, offset int64, dst io.Writer) (int64, error) {
+	defer response.Body.Close()
+	expected := response.ContentLength
+	if offset > 0 && response.StatusCode != http.StatusPartialContent {
+		if _, err := io.CopyN(io.Discard, response.Body, offset); err != nil {
+			return 0, err
+		}
+		if expected >= 0 {
+			expected -= offset
+		}
+	}
+	copied, err := io.Copy(dst, response.Body)
+	if err != nil {
+		return copied, err
+	}
+	if expected >= 0 && copied != expected {
+		return copied, fmt.Errorf("truncated: %d of %d bytes", copied, expected)
+	}
+	return copied, nil
+}
+
+// base64Checksum converts a base64 digest from a header to "algorithm:hex",
+// or "" if it is not valid
+func base64Checksum(algorithm, digest string) string {
+	decoded, err := base64.StdEncoding.DecodeString(digest)
+	if err != nil || len(decoded) == 0 {
+		return ""
+	}
+	return algorithm + ":" + hex.EncodeToString(decoded)
+}
+
+// bearerToken caches an OAuth access token, fetching a new one shortly before
+// it expires
+type bearerToken struct {
+	fetch func() (string, time.Duration, error) // The token and its lifetime
+
+	mu     sync.Mutex
+	value  string
+	expiry time.Time
+}
+
+// get returns a valid token
+func (t *bearerToken) get() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.value != "" && time.Until(t.expiry) > 5*time.Minute {
+		return t.value, nil
+	}
+	value, lifetime, err := t.fetch()
+	if err != nil {
+		return "", err
+	}
+	t.value, t.expiry = value, time.Now().Add(lifetime)
+	return value, nil
+}
+
+// tokenResponse is the answer of an OAuth token endpoint; expires_in is a
+// number, or a string from Azure's managed identity endpoint
+type tokenResponse struct {
+	AccessToken string          `json:"access_token"`
+	ExpiresIn   json.RawMessage `json:"expires_in"`
+	Error       string          `json:"error"`
+	Description string          `json:"error_description"`
+}
+
+// fetchToken sends a token request, returning the token and its lifetime
+func fetchToken(client *http.Client, request *http.Request) (string, time.Duration, error) {
+	response, err := client.Do(request)
+	if err != nil {
+		return "", 0, err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1024*1024))
+	if err != nil {
+		return "", 0, err
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", 0, fmt.Errorf("%s: HTTP %d", request.URL.Host, response.StatusCode)
+	}
+	if token.AccessToken == "" {
+		if token.Error != "" {
+			return "", 0, fmt.Errorf("%s: %s", token.Error, token.Description)
+		}
+		return "", 0, fmt.Errorf("%s: HTTP %d, no access token", request.URL.Host, response.StatusCode)
+	}
+	seconds, err := strconv.Atoi(strings.Trim(string(token.ExpiresIn), `"`))
+	if err != nil {
+		seconds = 3600
+	}
+	return token.AccessToken, time.Duration(seconds) * time.Second, nil
+}
+
+// ObjectFilter selects the objects of a bucket to download
+type ObjectFilter struct {
+	Prefix string
+	// TimeRange keeps the objects whose logs may have lines within it, by the
+	// time in their key or else their modification time
+	TimeRange TimeRange
+}
+
+// keyTimes are the times log archives put in their keys, most precise first,
+// with the period a key's time covers: ALB's 20240115T0905Z (the end of a 5
+// minute interval), CloudFront's 2024-01-15-09, and dates as in
+// 2024/01/15/, 2024-01-15 or logrotate's access.log-20240115.gz
+var keyTimes = []struct {
+	pattern *regexp.Regexp
+	layout  string
+	period  time.Duration
+	utc     bool // Times in the key are UTC, not the time zone of the logs
+}{
+	{regexp.MustCompile(`(\d{8}T\d{4})Z`), "20060102T1504", 5 * time.Minute, true},
+	{regexp.MustCompile(`(?:^|\D)(\d{4}-\d{2}-\d{2}-\d{2})(?:\D|$)`), "2006-01-02-15", time.Hour, true},
+	{regexp.MustCompile(`(?:^|\D)(\d{4}/\d{2}/\d{2})(?:/|$)`), "2006/01/02", 24 * time.Hour, false},
+	{regexp.MustCompile(`(?:^|\D)(\d{4}-\d{2}-\d{2})(?:\D|$)`), "2006-01-02", 24 * time.Hour, false},
+	{regexp.MustCompile(`(?:^|\D)(20\d{6})(?:\D|$)`), "20060102", 24 * time.Hour, false},
+}
+
+// keyTime returns the time in an object key and the period it covers
+func keyTime(key string) (time.Time, time.Duration, bool) {
+	for _, keyTime := range keyTimes {
+		match := keyTime.pattern.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		t, err := time.Parse(keyTime.layout, match[1])
+		if err != nil {
+			continue
+		}
+		slack := keyTime.period
+		if !keyTime.utc {
+			slack += timeZoneSlack
+		}
+		return t, slack, true
+	}
+	return time.Time{}, 0, false
+}
+
+// Select returns the objects that pass the filter
+func (f ObjectFilter) Select(objects []RemoteFile) []RemoteFile {
+	var selected []RemoteFile
+	for _, object := range objects {
+		if f.matches(object) {
+			selected = append(selected, object)
+		}
+	}
+	return selected
+}
+
+// matches reports whether an object may hold lines within the time range. A
+// time in the key is compared with the range widened by the period it
+// covers, on both sides, as archives are named after either end of it.
+// Otherwise the modification time, when the last line was written, must not
+// be before the range.
+func (f ObjectFilter) matches(object RemoteFile) bool {
+	if !strings.HasPrefix(object.Path, f.Prefix) || strings.HasSuffix(object.Path, "/") {
+		return false
+	}
+	if f.TimeRange.IsZero() {
+		return true
+	}
+	t, slack, ok := keyTime(strings.TrimPrefix(object.Path, f.Prefix))
+	if !ok {
+		t, slack, ok = keyTime(object.Path)
+	}
+	if !ok {
+		return f.TimeRange.Since == nil || object.ModTime.IsZero() || !object.ModTime.Before(*f.TimeRange.Since)
+	}
+	if f.TimeRange.Since != nil && t.Before(f.TimeRange.Since.Add(-slack)) {
+		return false
+	}
+	if f.TimeRange.Until != nil && t.After(f.TimeRange.Until.Add(slack)) {
+		return false
+	}
+	return true
+}
+
+// objectLocalFile is the name an object is saved as: its key below the
+// directory of the prefix, with slashes as underscores
+func objectLocalFile(prefix, key string) string {
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i+1]
+	}
+	return strings.ReplaceAll(path.Clean(strings.TrimPrefix(key, dir)), "/", "_")
+}
+
+// RunStore downloads the objects of a bucket that pass a filter, Parallel at
+// once, and returns the progress of the bucket. Objects are saved under
+// their key, without the time of the run, as archives do not change.
+func (d *Downloader) RunStore(store ObjectStore, filter ObjectFilter) []ServerProgress {
+	progress := &ServerProgress{Host: store.Name(), State: StateWaiting}
+	d.mu.Lock()
+	d.progress = []*ServerProgress{progress}
+	d.mu.Unlock()
+	d.downloadStore(store, filter, progress)
+	return d.Progress()
+}
+
+// downloadStore downloads the objects of a bucket
+func (d *Downloader) downloadStore(store ObjectStore, filter ObjectFilter, progress *ServerProgress) {
+	d.setState(progress, StateListing, nil)
+	listed, err := store.ListObjects(filter.Prefix)
+	if err != nil {
+		d.setState(progress, StateFailed, err)
+		return
+	}
+	objects := filter.Select(listed)
+	localFile := func(object RemoteFile) string {
+		return objectLocalFile(filter.Prefix, object.Path)
+	}
+	var synced []SyncedFile
+	if d.options.Sync != nil {
+		var unchanged []SyncedFile
+		objects, unchanged = d.options.Sync.skipSynced(store.Name(), objects, localFile)
+		d.mu.Lock()
+		progress.FilesSynced = len(unchanged)
+		d.mu.Unlock()
+	}
+
+	d.mu.Lock()
+	progress.Files = len(objects)
+	for _, object := range objects {
+		progress.TotalBytes += object.Size
+	}
+	d.mu.Unlock()
+	d.setState(progress, StateDownloading, nil)
+
+	jobs := make(chan RemoteFile)
+	var wg sync.WaitGroup
+	for i := 0; i < d.options.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for object := range jobs {
+				localPath := filepath.Join(d.options.OutputDir, localFile(object))
+				key := object.Path
+				copied, err := d.copyFile(progress, store.Name(), key, localPath, transfer{
+					size:        object.Size,
+					modTime:     object.ModTime,
+					resumable:   true,
+					keepPartial: true,
+					fetch: func(offset int64, dst io.Writer) (int64, string, error) {
+						return store.CopyObject(key, offset, dst)
+					},
+				})
+				d.mu.Lock()
+				if err != nil {
+					progress.FilesFailed++
+				} else {
+					progress.FilesDone++
+					progress.Downloaded = append(progress.Downloaded, localPath)
+					synced = append(synced, SyncedFile{
+						Server:     store.Name(),
+						RemotePath: object.Path,
+						Size:       object.Size,
+						ModTime:    object.ModTime,
+						LocalFile:  localFile(object),
+						SyncedAt:   time.Now(),
+					})
+				}
+				d.mu.Unlock()
+				d.events(DownloadEvent{Host: store.Name(), RemotePath: object.Path, LocalPath: localPath, Bytes: copied, Err: err})
+			}
+		}()
+	}
+	for _, object := range objects {
+		jobs <- object
+	}
+	close(jobs)
+	wg.Wait()
+
+	if d.options.Sync != nil {
+		d.options.Sync.record(synced)
+	}
+	d.mu.Lock()
+	failed := progress.FilesFailed > 0 && progress.FilesDone == 0
+	d.mu.Unlock()
+	if failed {
+		d.setState(progress, StateFailed, fmt.Errorf("no object could be downloaded"))
+		return
+	}
+	d.setState(progress, StateDone, nil)
+}
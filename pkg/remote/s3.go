@@ -0,0 +1,499 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3 defaults
+const (
+	s3DefaultRegion = "us-east-1"
+	// emptySHA256 is the payload hash of requests without a body
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Bucket is a bucket of Amazon S3 or an S3-compatible service such as
+// MinIO, read with Signature Version 4 signed requests
+type S3Bucket struct {
+	Bucket string
+
+	endpoint *url.URL // nil for AWS
+	http     *http.Client
+
+	mu          sync.Mutex
+	region      string
+	credentials *awsCredentials // nil for anonymous requests
+}
+
+// awsCredentials are AWS access keys; those of roles expire
+type awsCredentials struct {
+	AccessKeyID     string                          `json:"AccessKeyId"`
+	SecretAccessKey string                          `json:"SecretAccessKey"`
+	SessionToken    string                          `json:"Token"`
+	Expiration      time.Time                       `json:"Expiration"`
+	refresh         func() (*awsCredentials, error) // nil for static keys
+}
+
+// s3Error is the error document S3 answers failed requests with
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+	Region  string `xml:"Region"`
+}
+
+// listBucketResult is the part of a ListObjectsV2 answer the client uses
+type listBucketResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// NewS3Bucket connects to an S3 bucket with the credentials of the
+// environment, as the AWS CLI finds them: AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY, the AWS_PROFILE profile of ~/.aws/credentials, or the
+// role of the ECS task or EC2 instance. Without any, requests are anonymous,
+// for public buckets.
+func NewS3Bucket(bucket string, options BucketOptions) (*S3Bucket, error) {
+	b := &S3Bucket{Bucket: bucket, http: objectHTTPClient()}
+	if options.Endpoint != "" {
+		endpoint, err := url.Parse(options.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid endpoint %q, expected a URL such as http://localhost:9000", options.Endpoint)
+		}
+		b.endpoint = endpoint
+	}
+
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	b.region = options.Region
+	for _, region := range []string{os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), awsConfigRegion(profile), s3DefaultRegion} {
+		if b.region == "" {
+			b.region = region
+		}
+	}
+
+	credentials, err := findAWSCredentials(profile)
+	if err != nil {
+		return nil, err
+	}
+	b.credentials = credentials
+	return b, nil
+}
+
+// Name returns the URL of the bucket
+func (b *S3Bucket) Name() string {
+	return "s3://" + b.Bucket
+}
+
+// ListObjects returns the objects whose key starts with prefix
+func (b *S3Bucket) ListObjects(prefix string) ([]RemoteFile, error) {
+	var objects []RemoteFile
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		response, err := b.do("", query, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s/%s: %w", b.Name(), prefix, err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse listing of %s: %w", b.Name(), err)
+		}
+		for _, object := range result.Contents {
+			objects = append(objects, RemoteFile{Path: object.Key, Size: object.Size, ModTime: object.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return objects, nil
+}
+
+// CopyObject streams an object from offset to dst. The ETag of an object
+// uploaded in one part and not encrypted with KMS is its MD5.
+func (b *S3Bucket) CopyObject(key string, offset int64, dst io.Writer) (int64, string, error) {
+	response, err := b.do(key, nil, offset)
+	if err != nil {
+		return 0, "", err
+	}
+	checksum := ""
+	etag := strings.Trim(response.Header.Get("ETag"), `"`)
+	encryption := response.Header.Get("X-Amz-Server-Side-Encryption")
+	if _, err := hex.DecodeString(etag); err == nil && len(etag) == 32 && (encryption == "" || encryption == "AES256") &&
+		response.Header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") == "" {
+		checksum = "md5:" + etag
+	}
+	copied, err := copyResponse(response, offset, dst)
+	return copied, checksum, err
+}
+
+// do sends a GET request for a key, or for the bucket when key is "", from
+// offset if not 0, returning the response of a successful one. A bucket of
+// another region is asked again in its region.
+func (b *S3Bucket) do(key string, query url.Values, offset int64) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		b.mu.Lock()
+		region := b.region
+		b.mu.Unlock()
+
+		request, err := b.request(region, key, query, offset)
+		if err != nil {
+			return nil, err
+		}
+		response, err := b.http.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode/100 == 2 {
+			return response, nil
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+		response.Body.Close()
+		var s3Err s3Error
+		xml.Unmarshal(body, &s3Err)
+		bucketRegion := response.Header.Get("X-Amz-Bucket-Region")
+		if bucketRegion == "" {
+			bucketRegion = s3Err.Region
+		}
+		if attempt == 0 && bucketRegion != "" && bucketRegion != region {
+			b.mu.Lock()
+			b.region = bucketRegion
+			b.mu.Unlock()
+			continue
+		}
+		if s3Err.Code != "" {
+			return nil, httpStatusError(response.StatusCode, fmt.Errorf("%s: %s (HTTP %d)", s3Err.Code, s3Err.Message, response.StatusCode))
+		}
+		return nil, httpStatusError(response.StatusCode, fmt.Errorf("HTTP %d", response.StatusCode))
+	}
+}
+
+// request builds a signed GET request. AWS buckets are addressed by host
+// name, unless their name has dots, which the certificate does not cover;
+// other services by path.
+func (b *S3Bucket) request(region, key string, query url.Values, offset int64) (*http.Request, error) {
+	u := &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", b.Bucket, region), Path: "/" + key}
+	switch {
+	case b.endpoint != nil:
+		u = &url.URL{Scheme: b.endpoint.Scheme, Host: b.endpoint.Host, Path: strings.TrimSuffix(b.endpoint.Path, "/") + "/" + b.Bucket + "/" + key}
+	case strings.Contains(b.Bucket, "."):
+		u = &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%s.amazonaws.com", region), Path: "/" + b.Bucket + "/" + key}
+	}
+	u.RawPath = awsURIEncode(u.Path, false)
+	u.RawQuery = awsCanonicalQuery(query)
+
+	request, err := http.NewRequestWithContext(context.Background(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	credentials, err := b.currentCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if credentials != nil {
+		signAWSRequest(request, credentials, region, "s3", time.Now())
+	}
+	return request, nil
+}
+
+// currentCredentials returns the credentials, refreshing expiring ones
+func (b *S3Bucket) currentCredentials() (*awsCredentials, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	credentials := b.credentials
+	if credentials == nil || credentials.refresh == nil || credentials.Expiration.IsZero() ||
+		time.Until(credentials.Expiration) > 5*time.Minute {
+		return credentials, nil
+	}
+	refreshed, err := credentials.refresh()
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh AWS credentials: %w", err)
+	}
+	refreshed.refresh = credentials.refresh
+	b.credentials = refreshed
+	return refreshed, nil
+}
+
+// signAWSRequest signs a request without a body with Signature Version 4
+func signAWSRequest(request *http.Request, credentials *awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if credentials.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode percent-encodes all but the unreserved characters, as
+// Signature Version 4 requires, keeping slashes in paths
+func awsURIEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+// awsCanonicalQuery encodes a query sorted by name, as it is signed
+func awsCanonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// findAWSCredentials looks for credentials in the environment, the shared
+// credentials file and the ECS task or EC2 instance role, returning nil when
+// there are none
+func findAWSCredentials(profile string) (*awsCredentials, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return &awsCredentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	filename := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if filename == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			filename = filepath.Join(home, ".aws", "credentials")
+		}
+	}
+	if section, err := readINISection(filename, profile); err == nil {
+		if section["aws_access_key_id"] != "" && section["aws_secret_access_key"] != "" {
+			return &awsCredentials{
+				AccessKeyID:     section["aws_access_key_id"],
+				SecretAccessKey: section["aws_secret_access_key"],
+				SessionToken:    section["aws_session_token"],
+			}, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read AWS credentials: %w", err)
+	}
+
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		refresh := func() (*awsCredentials, error) {
+			return fetchRoleCredentials("http://169.254.170.2"+relative, nil)
+		}
+		credentials, err := refresh()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the ECS task role credentials: %w", err)
+		}
+		credentials.refresh = refresh
+		return credentials, nil
+	}
+
+	// The instance role, when on EC2
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return nil, nil
+	}
+	credentials, err := instanceRoleCredentials()
+	if err != nil {
+		return nil, nil
+	}
+	credentials.refresh = instanceRoleCredentials
+	return credentials, nil
+}
+
+// instanceRoleCredentials fetches the credentials of the EC2 instance role
+// from the instance metadata service, with an IMDSv2 session token
+func instanceRoleCredentials() (*awsCredentials, error) {
+	const metadata = "http://169.254.169.254/latest"
+	client := &http.Client{Timeout: metadataTimeout}
+	request, err := http.NewRequest(http.MethodPut, metadata+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	token, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil || response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("no instance metadata token")
+	}
+	header := http.Header{"X-Aws-Ec2-Metadata-Token": {string(token)}}
+
+	role, err := metadataGet(client, metadata+"/meta-data/iam/security-credentials/", header)
+	if err != nil {
+		return nil, err
+	}
+	role = strings.TrimSpace(strings.SplitN(strings.TrimSpace(role), "\n", 2)[0])
+	if role == "" {
+		return nil, fmt.Errorf("no instance role")
+	}
+	return fetchRoleCredentials(metadata+"/meta-data/iam/security-credentials/"+role, header)
+}
+
+// fetchRoleCredentials reads the JSON credentials of a role from a metadata
+// endpoint
+func fetchRoleCredentials(endpoint string, header http.Header) (*awsCredentials, error) {
+	body, err := metadataGet(&http.Client{Timeout: metadataTimeout}, endpoint, header)
+	if err != nil {
+		return nil, err
+	}
+	var credentials awsCredentials
+	if err := json.Unmarshal([]byte(body), &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse role credentials: %w", err)
+	}
+	if credentials.AccessKeyID == "" {
+		return nil, fmt.Errorf("no role credentials")
+	}
+	return &credentials, nil
+}
+
+// metadataGet reads a metadata endpoint
+func metadataGet(client *http.Client, endpoint string, header http.Header) (string, error) {
+	request, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header = header
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: HTTP %d", endpoint, response.StatusCode)
+	}
+	return string(body), nil
+}
+
+// awsConfigRegion returns the region of a profile in ~/.aws/config, or ""
+func awsConfigRegion(profile string) string {
+	filename := os.Getenv("AWS_CONFIG_FILE")
+	if filename == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		filename = filepath.Join(home, ".aws", "config")
+	}
+	section := "profile " + profile
+	if profile == "default" {
+		section = "default"
+	}
+	values, err := readINISection(filename, section)
+	if err != nil {
+		return ""
+	}
+	return values["region"]
+}
+
+// readINISection reads the keys of a [section] of an INI file, as the AWS
+// shared files are written
+func readINISection(filename, section string) (map[string]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values := make(map[string]string)
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+			continue
+		}
+		if name, value, ok := strings.Cut(line, "="); ok && inSection {
+			values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		}
+	}
+	return values, scanner.Err()
+}
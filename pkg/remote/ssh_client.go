@@ -1,10 +1,14 @@
 package remote
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,8 +16,9 @@ import (
 )
 
 type SSHClient struct {
-	config *SSHConfig
-	client *ssh.Client
+	config    *SSHConfig
+	client    *ssh.Client
+	agentConn net.Conn
 }
 
 func NewSSHClient(config *SSHConfig) *SSHClient {
@@ -23,18 +28,28 @@ func NewSSHClient(config *SSHConfig) *SSHClient {
 }
 
 func (c *SSHClient) Connect() error {
+	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
+	hostKeyCallback, hostKeyAlgorithms, err := DefaultHostKeyPolicy.hostKeyCallback(addr)
+	if err != nil {
+		return err
+	}
+
+	auth, err := c.authMethods()
+	if err != nil {
+		return err
+	}
+
 	sshConfig := &ssh.ClientConfig{
-		User: c.config.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(c.config.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // For simplicity - should use proper verification in production
-		Timeout:         30 * time.Second,
+		User:              c.config.Username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms,
+		Timeout:           30 * time.Second,
 	}
 
-	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
 	client, err := ssh.Dial("tcp", addr, sshConfig)
 	if err != nil {
+		c.closeAgent()
 		return fmt.Errorf("failed to connect to %s: %w", addr, err)
 	}
 
@@ -42,7 +57,138 @@ func (c *SSHClient) Connect() error {
 	return nil
 }
 
+// authMethods returns the ways to authenticate, in order: the ssh-agent's
+// keys when use_agent is set or nothing else is configured, the key file,
+// then the password from the configuration or the credential store
+func (c *SSHClient) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if c.config.usesAgent() {
+		agentClient, conn, err := connectAgent()
+		if err != nil {
+			if !c.config.UseAgent {
+				return nil, fmt.Errorf("no password or key file configured for %s and %w", c.config.Host, err)
+			}
+			return nil, err
+		}
+		c.agentConn = conn
+		methods = append(methods, ssh.PublicKeysCallback(agentClient.Signers))
+	}
+
+	if c.config.KeyFile != "" {
+		signer, err := c.keyFileSigner()
+		if err != nil {
+			c.closeAgent()
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	password := c.config.Password
+	if password == "" {
+		secret, err := c.config.LoadSecret(SecretPassword)
+		if err != nil {
+			c.closeAgent()
+			return nil, err
+		}
+		password = secret
+	}
+	if password != "" {
+		methods = append(methods, ssh.Password(password))
+	}
+
+	if len(methods) == 0 {
+		store := c.config.CredentialStore + " credential store"
+		if c.config.CredentialStore == CredentialStoreFile {
+			store += " " + DefaultCredentialFile.Path
+		}
+		return nil, fmt.Errorf("no credentials: no key_file configured, ssh-agent not tried (use_agent is off) and no %s in the %s",
+			c.config.SecretAccount(SecretPassword), store)
+	}
+	return methods, nil
+}
+
+// keyFileSigner reads the server's private key, decrypting it with the
+// passphrase from the credential store when it has one
+func (c *SSHClient) keyFileSigner() (ssh.Signer, error) {
+	key, err := os.ReadFile(ExpandHome(c.config.KeyFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key file %s: %w", c.config.KeyFile, err)
+		}
+		return signer, nil
+	}
+
+	passphrase, err := c.config.LoadSecret(SecretPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, fmt.Errorf("key file %s is encrypted and no passphrase is stored for %s (see download --store-credentials)", c.config.KeyFile, c.config.Host)
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key file %s: %w", c.config.KeyFile, err)
+	}
+	return signer, nil
+}
+
+// KeyFileEncrypted reports whether a private key file needs a passphrase
+func KeyFileEncrypted(path string) (bool, error) {
+	key, err := os.ReadFile(ExpandHome(path))
+	if err != nil {
+		return false, fmt.Errorf("failed to read key file: %w", err)
+	}
+	_, err = ssh.ParsePrivateKey(key)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to parse key file %s: %w", path, err)
+	}
+	return false, nil
+}
+
+// CheckKeyPassphrase reports whether a passphrase decrypts a private key file
+func CheckKeyPassphrase(path, passphrase string) error {
+	key, err := os.ReadFile(ExpandHome(path))
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+	if _, err := ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase)); err != nil {
+		return fmt.Errorf("failed to decrypt key file %s: %w", path, err)
+	}
+	return nil
+}
+
+// ExpandHome replaces a leading ~ of a path with the home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// closeAgent closes the connection to the ssh-agent, if any
+func (c *SSHClient) closeAgent() {
+	if c.agentConn != nil {
+		c.agentConn.Close()
+		c.agentConn = nil
+	}
+}
+
 func (c *SSHClient) Close() error {
+	c.closeAgent()
 	if c.client != nil {
 		return c.client.Close()
 	}
@@ -54,13 +200,6 @@ func (c *SSHClient) DownloadFile(remotePath, localPath string) error {
 		return fmt.Errorf("not connected to server")
 	}
 
-	// Create SFTP session
-	session, err := c.client.NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create session: %w", err)
-	}
-	defer session.Close()
-
 	// Create local directory if it doesn't exist
 	localDir := filepath.Dir(localPath)
 	if err := os.MkdirAll(localDir, 0755); err != nil {
@@ -74,28 +213,176 @@ func (c *SSHClient) DownloadFile(remotePath, localPath string) error {
 	}
 	defer localFile.Close()
 
-	// Use cat command to read remote file content
-	cmd := fmt.Sprintf("cat %s", remotePath)
+	if _, err := c.CopyFile(remotePath, localFile); err != nil {
+		return err
+	}
+	return localFile.Close()
+}
+
+// CopyFile streams the content of a remote file to dst, returning the bytes
+// copied. Each call has its own session, so several can run at once.
+func (c *SSHClient) CopyFile(remotePath string, dst io.Writer) (int64, error) {
+	return c.copyCommand("cat "+shellQuote(remotePath), dst)
+}
+
+// copyCommand streams the output of a remote command to dst
+func (c *SSHClient) copyCommand(command string, dst io.Writer) (int64, error) {
+	if c.client == nil {
+		return 0, fmt.Errorf("not connected to server")
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return 0, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
 	stdout, err := session.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		return 0, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
 
-	if err := session.Start(cmd); err != nil {
-		return fmt.Errorf("failed to start command: %w", err)
+	if err := session.Start(command); err != nil {
+		return 0, fmt.Errorf("failed to start command: %w", err)
 	}
 
 	// Copy content from remote to local
-	_, err = io.Copy(localFile, stdout)
+	copied, err := io.Copy(dst, stdout)
 	if err != nil {
-		return fmt.Errorf("failed to copy file content: %w", err)
+		return copied, fmt.Errorf("failed to copy file content: %w", err)
 	}
 
 	if err := session.Wait(); err != nil {
-		return fmt.Errorf("command failed: %w", err)
+		failure := fmt.Errorf("command failed: %w", err)
+		if message := lastLine(stderr.String()); message != "" {
+			failure = fmt.Errorf("command failed: %s", message)
+		}
+		// A command that ran and failed, unlike a lost connection, would
+		// fail again
+		var exit *ssh.ExitError
+		if errors.As(err, &exit) {
+			return copied, permanentError{failure}
+		}
+		return copied, failure
 	}
 
-	return nil
+	return copied, nil
+}
+
+// CopyFileRange streams count bytes of a remote file from offset to dst, so
+// a download can resume, and so a file still being written is copied up to
+// the size it was listed with
+func (c *SSHClient) CopyFileRange(remotePath string, offset, count int64, dst io.Writer) (int64, error) {
+	quoted := shellQuote(remotePath)
+	command := fmt.Sprintf("head -c %d %s", count, quoted)
+	if offset > 0 {
+		command = fmt.Sprintf("tail -c +%d %s | head -c %d", offset+1, quoted, count)
+	}
+	// A pipeline fails with its last command, so an unreadable file is
+	// checked first
+	return c.copyCommand(fmt.Sprintf("[ -r %s ] || { echo %s >&2; exit 1; }; %s", quoted, shellQuote("cannot read "+remotePath), command), dst)
+}
+
+// FileChecksum returns the SHA-256 of the first size bytes of a remote file
+// as "sha256:hex", or "" when the server has neither sha256sum nor shasum
+func (c *SSHClient) FileChecksum(remotePath string, size int64) (string, error) {
+	var output bytes.Buffer
+	command := fmt.Sprintf("head -c %d %s | { sha256sum 2>/dev/null || shasum -a 256 2>/dev/null; } || true", size, shellQuote(remotePath))
+	if _, err := c.copyCommand(command, &output); err != nil {
+		return "", err
+	}
+	sum := strings.Fields(output.String())
+	if len(sum) == 0 || len(sum[0]) != 64 {
+		return "", nil
+	}
+	return "sha256:" + sum[0], nil
+}
+
+// keepAliveInterval is how often keepAlive probes a connection
+const keepAliveInterval = 15 * time.Second
+
+// keepAlive closes the connection when a keepalive goes unanswered, as a
+// connection lost without a reset would block reads on it forever. It
+// returns when stop is closed or the connection is.
+func (c *SSHClient) keepAlive(stop <-chan struct{}) {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+		select {
+		case <-stop:
+			return
+		case err := <-replied:
+			if err == nil {
+				continue
+			}
+		case <-time.After(keepAliveInterval):
+		}
+		c.client.Close()
+		return
+	}
+}
+
+// RemoteFile is the size and modification time of a remote file
+type RemoteFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+// FileStats returns the size and modification time of remote files; files
+// that cannot be read are left out
+func (c *SSHClient) FileStats(paths []string) (map[string]RemoteFile, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("not connected to server")
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	// One "size mtime path" line per file, with GNU stat or else BSD stat
+	var quoted []string
+	for _, path := range paths {
+		quoted = append(quoted, shellQuote(path))
+	}
+	output, _ := session.Output("for f in " + strings.Join(quoted, " ") + `; do s=$(stat -c '%s %Y' "$f" 2>/dev/null || stat -f '%z %m' "$f" 2>/dev/null) && printf '%s %s\n' "$s" "$f"; done`)
+
+	stats := make(map[string]RemoteFile)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		mtime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		stats[fields[2]] = RemoteFile{Path: fields[2], Size: size, ModTime: time.Unix(mtime, 0)}
+	}
+	return stats, nil
+}
+
+// shellQuote quotes a value for the remote shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
 }
 
 func (c *SSHClient) ListLogFiles(logDir string) ([]string, error) {
@@ -0,0 +1,221 @@
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncStateFile is the file inside the output directory recording what a
+// sync has downloaded
+const SyncStateFile = ".sync_state.json"
+
+// SyncedFile is a remote file downloaded by a sync, as it was when fetched
+type SyncedFile struct {
+	Server     string    `json:"server"`      // host:port, or the URL of a bucket
+	RemotePath string    `json:"remote_path"` // Or the key of an object
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	LocalFile  string    `json:"local_file"` // Inside the output directory
+	SyncedAt   time.Time `json:"synced_at"`
+}
+
+// SyncState records the remote files downloaded into an output directory, so
+// later syncs fetch only new or changed ones
+type SyncState struct {
+	Files []SyncedFile `json:"files"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// syncAction is what a sync does with a remote file
+type syncAction int
+
+const (
+	syncDownload syncAction = iota
+	syncUpToDate
+	syncRename // The local copy of another remote path, after log rotation
+)
+
+// syncPlan is the sync of one remote file
+type syncPlan struct {
+	file   RemoteFile
+	action syncAction
+	from   SyncedFile // The recorded copy, for syncUpToDate and syncRename
+}
+
+// LoadSyncState reads the sync state of an output directory. A missing file
+// is an empty state.
+func LoadSyncState(dir string) (*SyncState, error) {
+	state := &SyncState{dir: dir}
+
+	filename := filepath.Join(dir, SyncStateFile)
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state %s: %w", filename, err)
+	}
+	return state, nil
+}
+
+// Save writes the state back to its output directory
+func (s *SyncState) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, SyncStateFile), data, 0644)
+}
+
+// syncLocalFile is the name a remote file of a server is synced to; unlike
+// downloads it has no time, so each sync updates the same files
+func syncLocalFile(host, remotePath string) string {
+	return fmt.Sprintf("%s_%s", host, filepath.Base(remotePath))
+}
+
+// plan compares the remote files of a server with the recorded ones. A file
+// of the same path, size and mtime is up to date; one whose size and mtime
+// match a recorded file of another path was renamed by log rotation, and its
+// local copy is renamed rather than downloaded again. Recorded files whose
+// local copy is gone or altered are not trusted.
+func (s *SyncState) plan(server, host string, files []RemoteFile) []syncPlan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recorded []SyncedFile
+	for _, synced := range s.Files {
+		if synced.Server != server {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(s.dir, synced.LocalFile))
+		if err == nil && info.Size() == synced.Size {
+			recorded = append(recorded, synced)
+		}
+	}
+
+	plans := make([]syncPlan, len(files))
+	claimed := make(map[int]bool)
+	sameContent := func(synced SyncedFile, file RemoteFile) bool {
+		return synced.Size == file.Size && synced.ModTime.Equal(file.ModTime)
+	}
+	// Unchanged paths first, so rotation never takes a file's own copy
+	for i, file := range files {
+		plans[i] = syncPlan{file: file, action: syncDownload}
+		for j, synced := range recorded {
+			if !claimed[j] && synced.RemotePath == file.Path && sameContent(synced, file) &&
+				synced.LocalFile == syncLocalFile(host, file.Path) {
+				plans[i] = syncPlan{file: file, action: syncUpToDate, from: synced}
+				claimed[j] = true
+				break
+			}
+		}
+	}
+	for i, file := range files {
+		if plans[i].action != syncDownload {
+			continue
+		}
+		for j, synced := range recorded {
+			if !claimed[j] && sameContent(synced, file) {
+				plans[i] = syncPlan{file: file, action: syncRename, from: synced}
+				claimed[j] = true
+				break
+			}
+		}
+	}
+	return plans
+}
+
+// renameRotated renames the local copies of rotated files to the names of
+// their new remote paths. The renames of one rotation form a chain
+// (access.log.1 to access.log.2, access.log to access.log.1), so every copy is
+// first moved aside. Plans whose rename fails are downloaded instead.
+func (s *SyncState) renameRotated(host string, plans []syncPlan) {
+	aside := make(map[int]string)
+	for i, plan := range plans {
+		if plan.action != syncRename {
+			continue
+		}
+		temporary := filepath.Join(s.dir, fmt.Sprintf(".%s.rotate%d", plan.from.LocalFile, i))
+		if err := os.Rename(filepath.Join(s.dir, plan.from.LocalFile), temporary); err != nil {
+			plans[i].action = syncDownload
+			continue
+		}
+		aside[i] = temporary
+	}
+	for i, temporary := range aside {
+		if err := os.Rename(temporary, filepath.Join(s.dir, syncLocalFile(host, plans[i].file.Path))); err != nil {
+			os.Remove(temporary)
+			plans[i].action = syncDownload
+		}
+	}
+}
+
+// update replaces the recorded files of a server with its synced files
+func (s *SyncState) update(server string, files []SyncedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.Files[:0]
+	for _, synced := range s.Files {
+		if synced.Server != server {
+			kept = append(kept, synced)
+		}
+	}
+	s.Files = append(kept, files...)
+}
+
+// skipSynced splits the objects of a bucket into those to download and the
+// records of those synced before, unchanged and with their local copy intact
+func (s *SyncState) skipSynced(server string, objects []RemoteFile, localFile func(RemoteFile) string) ([]RemoteFile, []SyncedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recorded := make(map[string]SyncedFile)
+	for _, synced := range s.Files {
+		if synced.Server == server {
+			recorded[synced.RemotePath] = synced
+		}
+	}
+
+	var download []RemoteFile
+	var unchanged []SyncedFile
+	for _, object := range objects {
+		synced, ok := recorded[object.Path]
+		if ok && synced.Size == object.Size && synced.ModTime.Equal(object.ModTime) && synced.LocalFile == localFile(object) {
+			if info, err := os.Stat(filepath.Join(s.dir, synced.LocalFile)); err == nil && info.Size() == synced.Size {
+				unchanged = append(unchanged, synced)
+				continue
+			}
+		}
+		download = append(download, object)
+	}
+	return download, unchanged
+}
+
+// record adds synced files, replacing the records of the same remote files;
+// unlike update, the other records of the server are kept, as a bucket is
+// synced a prefix and time range at a time
+func (s *SyncState) record(files []SyncedFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replaced := make(map[string]bool)
+	for _, file := range files {
+		replaced[file.Server+"\x00"+file.RemotePath] = true
+	}
+	kept := s.Files[:0]
+	for _, synced := range s.Files {
+		if !replaced[synced.Server+"\x00"+synced.RemotePath] {
+			kept = append(kept, synced)
+		}
+	}
+	s.Files = append(kept, files...)
+}
@@ -0,0 +1,187 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Remote tail timing
+const (
+	tailRetryDelay    = 2 * time.Second
+	tailMaxRetryDelay = time.Minute
+)
+
+// Tail follows a remote log over SSH with tail -F, which keeps following the
+// path across log rotation. A dropped connection is reconnected in the
+// background, and lines written while it was down are missed.
+type Tail struct {
+	server *SSHConfig
+	path   string
+
+	mu     sync.Mutex
+	lines  []string
+	err    error // Why the tail is not running, until it runs again
+	client *SSHClient
+	closed bool
+	done   chan struct{}
+}
+
+// StartTail connects to a server and follows a log on it from its beginning,
+// or from its end when only new lines are wanted
+func StartTail(server *SSHConfig, path string, fromEnd bool) (*Tail, error) {
+	client := NewSSHClient(server)
+	if err := client.Connect(); err != nil {
+		return nil, err
+	}
+
+	t := &Tail{server: server, path: path, client: client, done: make(chan struct{})}
+	from := "+1"
+	if fromEnd {
+		from = "0"
+	}
+	go t.run(client, from)
+	return t, nil
+}
+
+// Name describes the followed log, as host:path
+func (t *Tail) Name() string {
+	return fmt.Sprintf("%s:%s", t.server.Host, t.path)
+}
+
+// Poll returns the lines received since the last poll, or why the tail is
+// not running when there are none
+func (t *Tail) Poll() ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.lines
+	t.lines = nil
+	if len(lines) == 0 {
+		return nil, t.err
+	}
+	return lines, nil
+}
+
+// Close stops following the log
+func (t *Tail) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	// Ends the read in run, which then closes the rest of the client
+	return t.client.client.Close()
+}
+
+// run follows the log until closed, reconnecting with a growing delay
+func (t *Tail) run(client *SSHClient, from string) {
+	delay := tailRetryDelay
+	for {
+		err := t.follow(client, from)
+		client.Close()
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			return
+		}
+		t.err = fmt.Errorf("lost %s: %v; reconnecting", t.Name(), err)
+		t.mu.Unlock()
+
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > tailMaxRetryDelay {
+				delay = tailMaxRetryDelay
+			}
+
+			client = NewSSHClient(t.server)
+			err := client.Connect()
+			t.mu.Lock()
+			if t.closed {
+				t.mu.Unlock()
+				client.Close()
+				return
+			}
+			if err != nil {
+				t.err = fmt.Errorf("lost %s: %v; retrying in %s", t.Name(), err, delay)
+				t.mu.Unlock()
+				continue
+			}
+			t.client = client
+			t.err = nil
+			t.mu.Unlock()
+			break
+		}
+		// Only lines written from now on, as earlier ones were already read
+		from = "0"
+		delay = tailRetryDelay
+	}
+}
+
+// follow runs tail -F on a connection, collecting its lines until the
+// command or the connection ends
+func (t *Tail) follow(client *SSHClient, from string) error {
+	session, err := client.client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	if err := session.Start(fmt.Sprintf("tail -n %s -F %s", from, shellQuote(t.path))); err != nil {
+		return fmt.Errorf("failed to start tail: %w", err)
+	}
+
+	stopKeepAlive := make(chan struct{})
+	defer close(stopKeepAlive)
+	go client.keepAlive(stopKeepAlive)
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if line = strings.TrimRight(line, "\r\n"); line == "" {
+			continue
+		}
+		t.mu.Lock()
+		t.lines = append(t.lines, line)
+		t.err = nil
+		t.mu.Unlock()
+	}
+
+	if err := session.Wait(); err != nil {
+		var missing *ssh.ExitMissingError
+		if errors.As(err, &missing) {
+			return fmt.Errorf("connection closed")
+		}
+		if message := lastLine(stderr.String()); message != "" {
+			return fmt.Errorf("%s", message)
+		}
+		return err
+	}
+	return fmt.Errorf("tail exited")
+}
+
+// lastLine returns the last non-empty line of text
+func lastLine(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
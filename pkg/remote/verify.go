@@ -0,0 +1,121 @@
+package remote
+
+import (
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Download retry defaults
+const (
+	DefaultRetries = 3
+	retryDelay     = time.Second
+	maxRetryDelay  = 30 * time.Second
+)
+
+// permanentError is a failure that retrying would not fix, such as a missing
+// file or denied access
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// retryable reports whether a failed download may succeed when tried again
+func retryable(err error) bool {
+	var permanent permanentError
+	return !errors.As(err, &permanent)
+}
+
+// httpStatusError is the error of a failed request; client errors other than
+// timeouts and throttling are permanent
+func httpStatusError(status int, err error) error {
+	if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
+}
+
+// backoff is the wait before a retry, doubling from retryDelay up to
+// maxRetryDelay, with up to a quarter added at random so downloads that
+// failed together do not retry together
+func backoff(attempt int) time.Duration {
+	delay := retryDelay
+	for i := 0; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/4)+1))
+}
+
+// newChecksumHash returns the hash of a checksum algorithm
+func newChecksumHash(algorithm string) (hash.Hash, bool) {
+	switch algorithm {
+	case "md5":
+		return md5.New(), true
+	case "sha256":
+		return sha256.New(), true
+	case "crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), true
+	}
+	return nil, false
+}
+
+// verifyChecksum compares a local file with a checksum as "algorithm:hex";
+// an empty checksum or an unknown algorithm is not checked
+func verifyChecksum(filename, checksum string) error {
+	algorithm, expected, _ := strings.Cut(checksum, ":")
+	h, ok := newChecksumHash(algorithm)
+	if !ok {
+		return nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%s checksum mismatch: got %s, expected %s", algorithm, actual, expected)
+	}
+	return nil
+}
+
+// verifyGzip reads a gzip file to its end, whose trailer checks that the
+// data is complete and intact
+func verifyGzip(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("corrupt gzip data: %w", err)
+	}
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return fmt.Errorf("corrupt gzip data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package reports
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"smart-log-analyser/pkg/query"
+)
+
+// MarshalJSON writes numbers, booleans and strings as their JSON types, and
+// times and anything else as their display text
+func (c Cell) MarshalJSON() ([]byte, error) {
+	switch c.value.Type {
+	case query.ValueInt:
+		return json.Marshal(c.value.IntVal)
+	case query.ValueFloat:
+		return json.Marshal(c.value.FloatVal)
+	case query.ValueBool:
+		return json.Marshal(c.value.BoolVal)
+	default:
+		return json.Marshal(c.Text)
+	}
+}
+
+// WriteJSON writes the report as indented JSON
+func WriteJSON(report *Report, filename string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := createDir(filename); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// WriteCSV writes the report as CSV: each section starts with a row holding
+// its name, followed by its stats, table rows or text, and a blank row
+func WriteCSV(report *Report, filename string) error {
+	if err := createDir(filename); err != nil {
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{report.Title})
+	writer.Write([]string{"Generated", report.GeneratedAt.Format("2006-01-02 15:04:05")})
+	writer.Write([]string{"Entries", strconv.Itoa(report.Entries)})
+	writer.Write(nil)
+
+	for _, section := range report.Sections {
+		writer.Write([]string{section.Name})
+		switch {
+		case section.Error != "":
+			writer.Write([]string{"Error", section.Error})
+		case section.Type == "text":
+			writer.Write([]string{section.Text})
+		case section.Type == "stats":
+			for _, stat := range section.Stats {
+				writer.Write([]string{stat.Label, stat.Value.Text})
+			}
+		default:
+			writer.Write(section.Columns)
+			for _, row := range section.Rows {
+				record := make([]string, len(row))
+				for i, cell := range row {
+					record[i] = cell.Text
+				}
+				writer.Write(record)
+			}
+		}
+		writer.Write(nil)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func createDir(filename string) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		return os.MkdirAll(dir, 0755)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package reports
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/config"
+	"smart-log-analyser/pkg/query"
+)
+
+// Report is a report template filled in with query results, ready to be
+// written as HTML, JSON or CSV
+type Report struct {
+	Template    string               `json:"template"`
+	Title       string               `json:"title"`
+	Description string               `json:"description,omitempty"`
+	Category    string               `json:"category,omitempty"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Entries     int                  `json:"entries"`
+	Style       config.TemplateStyle `json:"-"`
+	Sections    []Section            `json:"sections"`
+}
+
+// Section is one section of a report. Which fields are set depends on the
+// section type: Text for text, Stats for stats, Columns and Rows for tables
+// and charts, and Chart for charts. Error is set instead when the section's
+// query failed.
+type Section struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Query   string   `json:"query,omitempty"`
+	Text    string   `json:"text,omitempty"`
+	Stats   []Stat   `json:"stats,omitempty"`
+	Columns []string `json:"columns,omitempty"`
+	Rows    [][]Cell `json:"rows,omitempty"`
+	Chart   *Chart   `json:"chart,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// Stat is one named value of a stats section
+type Stat struct {
+	Label string `json:"label"`
+	Value Cell   `json:"value"`
+}
+
+// Cell is a query result value with its display text
+type Cell struct {
+	Text   string
+	Number bool
+	value  query.Value
+}
+
+// Chart is the series a chart section plots
+type Chart struct {
+	Type       string    `json:"type"` // bar, line or pie
+	Title      string    `json:"title"`
+	Color      string    `json:"color,omitempty"`
+	ValueLabel string    `json:"value_label"`
+	Labels     []string  `json:"labels"`
+	Values     []float64 `json:"values"`
+}
+
+// Build runs the enabled sections of a template through the query engine.
+// A section whose query fails records the error and the others still run,
+// so one bad query does not lose the whole report.
+func Build(template config.ReportTemplate, engine *query.QueryEngine, entries int) *Report {
+	report := &Report{
+		Template:    template.Name,
+		Title:       titleOf(template.Name),
+		Description: template.Description,
+		Category:    template.Category,
+		GeneratedAt: time.Now(),
+		Entries:     entries,
+		Style:       template.Style,
+	}
+
+	for _, section := range template.EnabledSections() {
+		report.Sections = append(report.Sections, buildSection(section, engine))
+	}
+	return report
+}
+
+// buildSection runs one template section
+func buildSection(template config.TemplateSection, engine *query.QueryEngine) Section {
+	section := Section{Name: template.Name, Type: template.Type, Query: template.Query}
+
+	if template.Type == "text" {
+		section.Text = configString(template, "content")
+		return section
+	}
+
+	result, err := engine.ExecuteQuery(template.Query)
+	if err != nil {
+		section.Error = err.Error()
+		return section
+	}
+
+	switch template.Type {
+	case "stats":
+		if len(result.Rows) > 0 {
+			for i, column := range result.Columns {
+				section.Stats = append(section.Stats, Stat{Label: titleOf(column), Value: newCell(result.Rows[0][i])})
+			}
+		}
+
+	case "chart":
+		section.Columns, section.Rows = table(result)
+		if len(result.Rows) == 0 {
+			break
+		}
+		labels, values, valueColumn, err := query.ChartSeries(result)
+		if err != nil {
+			section.Error = err.Error()
+			break
+		}
+		chartType := configString(template, "chart_type")
+		if chartType == "" {
+			chartType = "bar"
+		}
+		title := configString(template, "title")
+		if title == "" {
+			title = template.Name
+		}
+		section.Chart = &Chart{
+			Type:       chartType,
+			Title:      title,
+			Color:      configString(template, "color"),
+			ValueLabel: valueColumn,
+			Labels:     labels,
+			Values:     values,
+		}
+
+	default: // table
+		section.Columns, section.Rows = table(result)
+	}
+	return section
+}
+
+// table converts a query result to display cells
+func table(result *query.QueryResult) ([]string, [][]Cell) {
+	rows := make([][]Cell, len(result.Rows))
+	for i, row := range result.Rows {
+		rows[i] = make([]Cell, len(row))
+		for j, value := range row {
+			rows[i][j] = newCell(value)
+		}
+	}
+	return result.Columns, rows
+}
+
+func newCell(value query.Value) Cell {
+	return Cell{
+		Text:   query.FormatValue(value),
+		Number: value.Type == query.ValueInt || value.Type == query.ValueFloat,
+		value:  value,
+	}
+}
+
+// configString reads a string setting of a section, "" when it is not set
+func configString(section config.TemplateSection, key string) string {
+	if value, ok := section.Config[key]; ok && value != nil {
+		return fmt.Sprint(value)
+	}
+	return ""
+}
+
+// titleOf turns a name like "security-report" or "total_requests" into a title
+func titleOf(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '-' || r == '_' || r == ' '
+	})
+	for i, word := range words {
+		words[i] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,96 @@
+package security
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ThreatGroup is the near-identical threats of one IP, attack type and
+// normalized pattern, such as every hit of one SQL injection pattern by a
+// scanner
+type ThreatGroup struct {
+	Threat    EnhancedThreat // Most severe threat of the group, the earliest of equals
+	Count     int
+	URLs      int // Distinct URLs targeted
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// digitRuns and spaceRuns are normalized away so patterns that only differ
+// in counts, IDs or spacing group together
+var (
+	digitRuns = regexp.MustCompile(`\d+`)
+	spaceRuns = regexp.MustCompile(`\s+`)
+)
+
+// normalizedPattern returns the pattern of a threat with case, numbers and
+// spacing normalized
+func normalizedPattern(threat EnhancedThreat) string {
+	pattern := strings.ToLower(strings.TrimSpace(threat.Pattern))
+	pattern = digitRuns.ReplaceAllString(pattern, "#")
+	return spaceRuns.ReplaceAllString(pattern, " ")
+}
+
+// AggregateThreats groups the threats by IP, attack type and normalized
+// pattern, most severe groups first and larger groups first among equals
+func AggregateThreats(threats []EnhancedThreat) []ThreatGroup {
+	type aggregate struct {
+		group *ThreatGroup
+		urls  map[string]bool
+		order int
+	}
+
+	aggregates := make(map[string]*aggregate)
+	for _, threat := range threats {
+		key := threat.IP + "\x00" + threatTypeName(threat) + "\x00" + normalizedPattern(threat)
+		a, exists := aggregates[key]
+		if !exists {
+			a = &aggregate{
+				group: &ThreatGroup{Threat: threat, FirstSeen: threat.Timestamp, LastSeen: threat.Timestamp},
+				urls:  make(map[string]bool),
+				order: len(aggregates),
+			}
+			aggregates[key] = a
+		}
+
+		group := a.group
+		group.Count++
+		if threat.URL != "" {
+			a.urls[threat.URL] = true
+		}
+		if threat.Severity > group.Threat.Severity ||
+			threat.Severity == group.Threat.Severity && threat.Timestamp.Before(group.Threat.Timestamp) {
+			group.Threat = threat
+		}
+		if threat.Timestamp.Before(group.FirstSeen) {
+			group.FirstSeen = threat.Timestamp
+		}
+		if threat.Timestamp.After(group.LastSeen) {
+			group.LastSeen = threat.Timestamp
+		}
+	}
+
+	ordered := make([]*aggregate, 0, len(aggregates))
+	for _, a := range aggregates {
+		a.group.URLs = len(a.urls)
+		ordered = append(ordered, a)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		gi, gj := ordered[i].group, ordered[j].group
+		if gi.Threat.Severity != gj.Threat.Severity {
+			return gi.Threat.Severity > gj.Threat.Severity
+		}
+		if gi.Count != gj.Count {
+			return gi.Count > gj.Count
+		}
+		return ordered[i].order < ordered[j].order
+	})
+
+	groups := make([]ThreatGroup, len(ordered))
+	for i, a := range ordered {
+		groups[i] = *a.group
+	}
+	return groups
+}
@@ -0,0 +1,63 @@
+package security
+
+import (
+	"fmt"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// Analyse runs threat detection, anomaly detection, IP profiling and incident
+// correlation over the entries and scores the result
+func Analyse(entries []*parser.LogEntry, config SecurityConfig) (*EnhancedSecurityAnalysis, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no log entries found to analyze")
+	}
+
+	threatDetector := NewThreatDetector(config)
+	anomalyDetector := NewAnomalyDetector(config)
+	scorer := NewSecurityScorer(config)
+
+	// Detect all threats
+	webThreats, _ := threatDetector.DetectWebAttacks(entries)
+	infraThreats, _ := threatDetector.DetectInfrastructureAttacks(entries)
+	allThreats := append(webThreats, infraThreats...)
+
+	// Detect anomalies and profile IP behaviour
+	anomalies, _ := anomalyDetector.DetectAnomalies(entries)
+	ipProfiles, _ := anomalyDetector.ProfileIPs(entries)
+	if config.Reputation != nil {
+		applyReputation(ipProfiles, config.Reputation, entries)
+	}
+
+	// Correlate threats into incidents
+	incidents, _ := scorer.GenerateIncidents(allThreats, anomalies)
+
+	analysis := &EnhancedSecurityAnalysis{
+		Threats:              allThreats,
+		Anomalies:            anomalies,
+		IPProfiles:           ipProfiles,
+		Incidents:            incidents,
+		AnalysisTimestamp:    time.Now(),
+		TotalEntriesAnalyzed: int64(len(entries)),
+		LogTimeRange:         entriesTimeRange(entries),
+	}
+
+	analysis.Summary = scorer.GenerateSecuritySummary(analysis)
+
+	return analysis, nil
+}
+
+// entriesTimeRange returns the span of the entries, which need not be sorted
+func entriesTimeRange(entries []*parser.LogEntry) TimeRange {
+	timeRange := TimeRange{Start: entries[0].Timestamp, End: entries[0].Timestamp}
+	for _, entry := range entries[1:] {
+		if entry.Timestamp.Before(timeRange.Start) {
+			timeRange.Start = entry.Timestamp
+		}
+		if entry.Timestamp.After(timeRange.End) {
+			timeRange.End = entry.Timestamp
+		}
+	}
+	return timeRange
+}
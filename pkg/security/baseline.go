@@ -0,0 +1,212 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// maxBaselineDiffItems caps each list of the baseline diff report
+const maxBaselineDiffItems = 10
+
+// BaselineDiff is what changed between a stored security report, the
+// baseline, and the current one
+type BaselineDiff struct {
+	BaselineGeneratedAt time.Time `json:"baseline_generated_at"`
+	BaselineStart       time.Time `json:"baseline_start"`
+	BaselineEnd         time.Time `json:"baseline_end"`
+
+	ScoreBefore   int    `json:"score_before"`
+	ScoreAfter    int    `json:"score_after"`
+	ScoreDelta    int    `json:"score_delta"` // Negative when security got worse
+	RiskBefore    string `json:"risk_before"`
+	RiskAfter     string `json:"risk_after"`
+	ThreatsBefore int    `json:"threats_before"`
+	ThreatsAfter  int    `json:"threats_after"`
+
+	NewIPs            []DiffIP         `json:"new_ips"`          // Attacker IPs the baseline did not have
+	GoneIPs           []string         `json:"gone_ips"`         // Baseline attacker IPs no longer seen
+	NewAttackTypes    []DiffAttackType `json:"new_attack_types"` // Attack types the baseline did not have
+	GoneAttackTypes   []string         `json:"gone_attack_types"`
+	NewIncidents      []ReportIncident `json:"new_incidents"`
+	ResolvedIncidents []ReportIncident `json:"resolved_incidents"` // Baseline incidents no longer seen
+}
+
+// DiffIP is an attacker IP of a BaselineDiff with its threats
+type DiffIP struct {
+	IP          string   `json:"ip"`
+	Threats     int      `json:"threats"`
+	Severity    string   `json:"severity"` // Highest among the threats
+	AttackTypes []string `json:"attack_types"`
+}
+
+// DiffAttackType is an attack type of a BaselineDiff with its threats
+type DiffAttackType struct {
+	Type    string `json:"type"`
+	Threats int    `json:"threats"`
+	IPs     int    `json:"ips"`
+}
+
+// LoadReport reads a JSON security report, such as one written by
+// SaveReport or security scan --format json
+func LoadReport(filename string) (*Report, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security report: %w", err)
+	}
+	report := &Report{}
+	if err := json.Unmarshal(data, report); err != nil {
+		return nil, fmt.Errorf("failed to parse security report %s: %w", filename, err)
+	}
+	return report, nil
+}
+
+// SaveReport writes a JSON security report
+func SaveReport(filename string, report Report) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// DiffReports compares the current report with the baseline. Incidents are
+// matched by title and threat actor, since their IDs differ between runs.
+func DiffReports(baseline, current Report) BaselineDiff {
+	diff := BaselineDiff{
+		BaselineGeneratedAt: baseline.GeneratedAt,
+		BaselineStart:       baseline.Start,
+		BaselineEnd:         baseline.End,
+		ScoreBefore:         baseline.SecurityScore,
+		ScoreAfter:          current.SecurityScore,
+		ScoreDelta:          current.SecurityScore - baseline.SecurityScore,
+		RiskBefore:          baseline.RiskLevel,
+		RiskAfter:           current.RiskLevel,
+		NewIPs:              []DiffIP{},
+		GoneIPs:             []string{},
+		NewAttackTypes:      []DiffAttackType{},
+		GoneAttackTypes:     []string{},
+		NewIncidents:        []ReportIncident{},
+		ResolvedIncidents:   []ReportIncident{},
+	}
+
+	before, after := summarizeReportThreats(baseline), summarizeReportThreats(current)
+	diff.ThreatsBefore, diff.ThreatsAfter = before.threats, after.threats
+
+	for ip, threats := range after.ips {
+		if _, seen := before.ips[ip]; !seen {
+			diff.NewIPs = append(diff.NewIPs, *threats)
+		}
+	}
+	sort.Slice(diff.NewIPs, func(i, j int) bool {
+		if diff.NewIPs[i].Threats != diff.NewIPs[j].Threats {
+			return diff.NewIPs[i].Threats > diff.NewIPs[j].Threats
+		}
+		return diff.NewIPs[i].IP < diff.NewIPs[j].IP
+	})
+	for ip := range before.ips {
+		if _, seen := after.ips[ip]; !seen {
+			diff.GoneIPs = append(diff.GoneIPs, ip)
+		}
+	}
+	sort.Strings(diff.GoneIPs)
+
+	for attackType, threats := range after.attackTypes {
+		if _, seen := before.attackTypes[attackType]; !seen {
+			diff.NewAttackTypes = append(diff.NewAttackTypes, *threats)
+		}
+	}
+	sort.Slice(diff.NewAttackTypes, func(i, j int) bool {
+		if diff.NewAttackTypes[i].Threats != diff.NewAttackTypes[j].Threats {
+			return diff.NewAttackTypes[i].Threats > diff.NewAttackTypes[j].Threats
+		}
+		return diff.NewAttackTypes[i].Type < diff.NewAttackTypes[j].Type
+	})
+	for attackType := range before.attackTypes {
+		if _, seen := after.attackTypes[attackType]; !seen {
+			diff.GoneAttackTypes = append(diff.GoneAttackTypes, attackType)
+		}
+	}
+	sort.Strings(diff.GoneAttackTypes)
+
+	diff.NewIncidents = unmatchedIncidents(current.Incidents, baseline.Incidents)
+	diff.ResolvedIncidents = unmatchedIncidents(baseline.Incidents, current.Incidents)
+	return diff
+}
+
+// reportThreats sums up the threats of a report per IP and attack type
+type reportThreats struct {
+	threats     int
+	ips         map[string]*DiffIP
+	attackTypes map[string]*DiffAttackType
+}
+
+// summarizeReportThreats sums up the threats of a report; a threat of an
+// aggregated report counts for its whole group
+func summarizeReportThreats(report Report) reportThreats {
+	summary := reportThreats{
+		ips:         make(map[string]*DiffIP),
+		attackTypes: make(map[string]*DiffAttackType),
+	}
+	severities := make(map[string]ThreatSeverity)
+	typeIPs := make(map[string]map[string]bool)
+	for _, threat := range report.Threats {
+		count := threat.Count
+		if count == 0 {
+			count = 1
+		}
+		summary.threats += count
+
+		ip, exists := summary.ips[threat.IP]
+		if !exists {
+			ip = &DiffIP{IP: threat.IP}
+			summary.ips[threat.IP] = ip
+		}
+		ip.Threats += count
+		if !containsValue(ip.AttackTypes, threat.Type) {
+			ip.AttackTypes = append(ip.AttackTypes, threat.Type)
+			sort.Strings(ip.AttackTypes)
+		}
+		if severity, err := ParseSeverity(threat.Severity); err == nil && (ip.Severity == "" || severity > severities[threat.IP]) {
+			severities[threat.IP] = severity
+			ip.Severity = severity.String()
+		}
+
+		attackType, exists := summary.attackTypes[threat.Type]
+		if !exists {
+			attackType = &DiffAttackType{Type: threat.Type}
+			summary.attackTypes[threat.Type] = attackType
+			typeIPs[threat.Type] = make(map[string]bool)
+		}
+		attackType.Threats += count
+		if !typeIPs[threat.Type][threat.IP] {
+			typeIPs[threat.Type][threat.IP] = true
+			attackType.IPs++
+		}
+	}
+	return summary
+}
+
+// unmatchedIncidents returns the incidents without a match of the same title
+// and threat actor among others
+func unmatchedIncidents(incidents, others []ReportIncident) []ReportIncident {
+	seen := make(map[string]bool, len(others))
+	for _, incident := range others {
+		seen[incident.Title+"\x00"+incident.ThreatActor] = true
+	}
+	unmatched := []ReportIncident{}
+	for _, incident := range incidents {
+		if !seen[incident.Title+"\x00"+incident.ThreatActor] {
+			unmatched = append(unmatched, incident)
+		}
+	}
+	return unmatched
+}
@@ -0,0 +1,252 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// DefaultBehaviorStoreFile is the behavior store file name inside the config
+// directory
+const DefaultBehaviorStoreFile = "security_behavior.json"
+
+// Low-and-slow brute force spreads failed logins over hours or days so no
+// run or window sees enough of them from one IP
+const (
+	LowAndSlowWindow      = 7 * 24 * time.Hour
+	lowAndSlowMinAttempts = 20
+	lowAndSlowMinHours    = 6
+)
+
+// behaviorHourLayout keys the hourly buckets of the store, in UTC
+const behaviorHourLayout = "2006-01-02T15"
+
+// BehaviorStore keeps the failed logins of each IP per hour across analysis
+// runs as a JSON file, so attacks spread over many runs can be detected
+type BehaviorStore struct {
+	UpdatedAt time.Time              `json:"updated_at"`
+	IPs       map[string]*IPBehavior `json:"ips"`
+
+	filename string
+}
+
+// IPBehavior is the stored behavior of an IP
+type IPBehavior struct {
+	FailedLogins map[string]int `json:"failed_logins"` // UTC hour -> failed logins
+}
+
+// LoadBehaviorStore reads the behavior store. A missing file is an empty
+// store.
+func LoadBehaviorStore(filename string) (*BehaviorStore, error) {
+	store := &BehaviorStore{IPs: make(map[string]*IPBehavior), filename: filename}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read behavior store: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse behavior store %s: %w", filename, err)
+	}
+	if store.IPs == nil {
+		store.IPs = make(map[string]*IPBehavior)
+	}
+	return store, nil
+}
+
+// Save writes the store back to its file
+func (s *BehaviorStore) Save() error {
+	if dir := filepath.Dir(s.filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create behavior store directory: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.filename, data, 0644)
+}
+
+// Record stores the failed logins of the entries. The counts of a run replace
+// the stored counts of the hours it covers, so analysing the same logs twice
+// does not count them twice.
+func (s *BehaviorStore) Record(logs []*parser.LogEntry) {
+	covered, failures := hourlyFailedLogins(logs)
+	for ip, behavior := range s.IPs {
+		for hour := range behavior.FailedLogins {
+			if covered[hour] {
+				delete(behavior.FailedLogins, hour)
+			}
+		}
+		if len(behavior.FailedLogins) == 0 {
+			delete(s.IPs, ip)
+		}
+	}
+	for ip, hours := range failures {
+		behavior, exists := s.IPs[ip]
+		if !exists {
+			behavior = &IPBehavior{FailedLogins: make(map[string]int)}
+			s.IPs[ip] = behavior
+		}
+		for hour, count := range hours {
+			behavior.FailedLogins[hour] = count
+		}
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// Prune drops the hours more than retention before the newest one stored and
+// returns how many IPs no longer have any
+func (s *BehaviorStore) Prune(retention time.Duration) int {
+	newest := ""
+	for _, behavior := range s.IPs {
+		for hour := range behavior.FailedLogins {
+			if hour > newest {
+				newest = hour
+			}
+		}
+	}
+	if newest == "" || retention <= 0 {
+		return 0
+	}
+	latest, _ := time.Parse(behaviorHourLayout, newest)
+	cutoff := latest.Add(-retention).Format(behaviorHourLayout)
+
+	dropped := 0
+	for ip, behavior := range s.IPs {
+		for hour := range behavior.FailedLogins {
+			if hour < cutoff {
+				delete(behavior.FailedLogins, hour)
+			}
+		}
+		if len(behavior.FailedLogins) == 0 {
+			delete(s.IPs, ip)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// hourlyFailedLogins returns the UTC hours the entries cover and the failed
+// logins of each IP per hour
+func hourlyFailedLogins(logs []*parser.LogEntry) (map[string]bool, map[string]map[string]int) {
+	covered := make(map[string]bool)
+	failures := make(map[string]map[string]int)
+	for _, entry := range logs {
+		hour := entry.Timestamp.UTC().Format(behaviorHourLayout)
+		covered[hour] = true
+		if !isFailedLogin(entry) {
+			continue
+		}
+		if failures[entry.IP] == nil {
+			failures[entry.IP] = make(map[string]int)
+		}
+		failures[entry.IP][hour]++
+	}
+	return covered, failures
+}
+
+// detectLowAndSlow detects IPs whose failed logins stay under the brute force
+// threshold in this run but add up over LowAndSlowWindow, counting the earlier
+// runs in the behavior store when there is one
+func (td *ThreatDetector) detectLowAndSlow(ipEntries map[string][]*parser.LogEntry) []EnhancedThreat {
+	var logs []*parser.LogEntry
+	for _, entries := range ipEntries {
+		logs = append(logs, entries...)
+	}
+	covered, failures := hourlyFailedLogins(logs)
+
+	var ips []string
+	for ip := range failures {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	var threats []EnhancedThreat
+	for _, ip := range ips {
+		var last *parser.LogEntry
+		runAttempts := 0
+		for _, entry := range ipEntries[ip] {
+			if isFailedLogin(entry) {
+				runAttempts++
+				if last == nil || entry.Timestamp.After(last.Timestamp) {
+					last = entry
+				}
+			}
+		}
+		if runAttempts > bruteForceThreshold {
+			continue // Reported as brute force
+		}
+
+		// This run's hours, and the stored hours it does not cover
+		hours := make(map[string]int)
+		for hour, count := range failures[ip] {
+			hours[hour] = count
+		}
+		if td.config.Behavior != nil {
+			if behavior, ok := td.config.Behavior.IPs[ip]; ok {
+				for hour, count := range behavior.FailedLogins {
+					if !covered[hour] {
+						hours[hour] = count
+					}
+				}
+			}
+		}
+
+		cutoff := last.Timestamp.UTC().Add(-LowAndSlowWindow).Format(behaviorHourLayout)
+		end := last.Timestamp.UTC().Format(behaviorHourLayout)
+		attempts, activeHours, first := 0, 0, end
+		for hour, count := range hours {
+			if hour < cutoff || hour > end {
+				continue
+			}
+			attempts += count
+			activeHours++
+			if hour < first {
+				first = hour
+			}
+		}
+		if attempts < lowAndSlowMinAttempts || activeHours < lowAndSlowMinHours {
+			continue
+		}
+
+		severity := SeverityMedium
+		if attempts >= 100 || activeHours >= 48 {
+			severity = SeverityHigh
+		}
+		firstSeen, _ := time.Parse(behaviorHourLayout, first)
+		threats = append(threats, EnhancedThreat{
+			ID:           fmt.Sprintf("lowslow_%d_%s", time.Now().UnixNano(), ip),
+			Type:         BruteForceLogin,
+			Severity:     severity,
+			Confidence:   0.7,
+			Pattern:      "Failed logins spread over many hours",
+			URL:          last.URL,
+			IP:           ip,
+			UserAgent:    last.UserAgent,
+			Timestamp:    last.Timestamp,
+			Method:       last.Method,
+			StatusCode:   last.Status,
+			AttackVector: "Authentication (low and slow)",
+			Context: map[string]interface{}{
+				"description":     fmt.Sprintf("%d failed logins over %d hours since %s", attempts, activeHours, firstSeen.Format("2006-01-02 15:00")),
+				"failed_attempts": attempts,
+				"active_hours":    activeHours,
+				"run_attempts":    runAttempts,
+				"first_seen":      firstSeen,
+				"window":          LowAndSlowWindow.String(),
+			},
+			MitigationAdvice: []string{"Block the IP", "Enable MFA", "Alert on failed logins per account over days, not minutes"},
+		})
+	}
+
+	return threats
+}
@@ -0,0 +1,182 @@
+package security
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// A botnet is at least botnetMinIPs IPs with the same fingerprint: user
+// agent, first botnetSequenceLength request paths and request interval
+const (
+	botnetMinIPs            = 3
+	botnetMinRequests       = 5
+	botnetSequenceLength    = 10
+	botnetMaxIntervalSpread = 0.5 // Coefficient of variation of the intervals; people are less regular
+)
+
+// searchEngineCrawlers share fingerprints across their many IPs by design
+var searchEngineCrawlers = regexp.MustCompile(`(?i)(googlebot|bingbot|applebot|duckduckbot|yandexbot|baiduspider)`)
+
+// botnetFingerprint returns the fingerprint of an IP's requests; false when
+// there are too few of them or their timing is not machine-like
+func botnetFingerprint(entries []*parser.LogEntry) (string, []string, time.Duration, bool) {
+	if len(entries) < botnetMinRequests {
+		return "", nil, 0, false
+	}
+	sorted := make([]*parser.LogEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	userAgent := sorted[0].UserAgent
+	if searchEngineCrawlers.MatchString(userAgent) {
+		return "", nil, 0, false
+	}
+	var sequence []string
+	var intervals []float64
+	for i, entry := range sorted {
+		if entry.UserAgent != userAgent {
+			return "", nil, 0, false
+		}
+		if i < botnetSequenceLength {
+			path := entry.URL
+			if j := strings.IndexAny(path, "?#"); j >= 0 {
+				path = path[:j]
+			}
+			sequence = append(sequence, entry.Method+" "+path)
+		}
+		if i > 0 {
+			intervals = append(intervals, entry.Timestamp.Sub(sorted[i-1].Timestamp).Seconds())
+		}
+	}
+
+	// Regular timing: the intervals vary little around their mean
+	mean := 0.0
+	for _, interval := range intervals {
+		mean += interval
+	}
+	mean /= float64(len(intervals))
+	variance := 0.0
+	for _, interval := range intervals {
+		variance += (interval - mean) * (interval - mean)
+	}
+	if mean > 0 && math.Sqrt(variance/float64(len(intervals)))/mean > botnetMaxIntervalSpread {
+		return "", nil, 0, false
+	}
+
+	sort.Float64s(intervals)
+	median := time.Duration(intervals[len(intervals)/2] * float64(time.Second))
+	// Intervals in the same power of two bucket count as the same timing
+	timing := int(math.Round(math.Log2(median.Seconds() + 1)))
+	return fmt.Sprintf("%s\x00%s\x00%d", userAgent, strings.Join(sequence, "\n"), timing), sequence, median, true
+}
+
+// detectCoordinatedBotnets correlates IPs that send the same request sequence
+// with the same user agent and timing. Each member IP is reported with the
+// botnet's ID and members in its context, so incidents group them together.
+func (td *ThreatDetector) detectCoordinatedBotnets(ipEntries map[string][]*parser.LogEntry) []EnhancedThreat {
+	type member struct {
+		ip      string
+		entries []*parser.LogEntry
+		median  time.Duration
+	}
+	clusters := make(map[string][]member)
+	sequences := make(map[string][]string)
+	for ip, entries := range ipEntries {
+		fingerprint, sequence, median, ok := botnetFingerprint(entries)
+		if !ok {
+			continue
+		}
+		clusters[fingerprint] = append(clusters[fingerprint], member{ip, entries, median})
+		sequences[fingerprint] = sequence
+	}
+
+	var fingerprints []string
+	for fingerprint, members := range clusters {
+		if len(members) >= botnetMinIPs {
+			fingerprints = append(fingerprints, fingerprint)
+		}
+	}
+	sort.Strings(fingerprints)
+
+	var threats []EnhancedThreat
+	for _, fingerprint := range fingerprints {
+		members := clusters[fingerprint]
+		sort.Slice(members, func(i, j int) bool { return members[i].ip < members[j].ip })
+		var ips []string
+		for _, m := range members {
+			ips = append(ips, m.ip)
+		}
+		sum := sha1.Sum([]byte(fingerprint))
+		botnetID := fmt.Sprintf("botnet-%x", sum[:4])
+
+		severity := SeverityMedium
+		if len(members) >= 10 {
+			severity = SeverityHigh
+		}
+		if len(members) >= 50 {
+			severity = SeverityCritical
+		}
+
+		for _, m := range members {
+			last := m.entries[0]
+			for _, entry := range m.entries {
+				if entry.Timestamp.After(last.Timestamp) {
+					last = entry
+				}
+			}
+			threats = append(threats, EnhancedThreat{
+				ID:           fmt.Sprintf("botnet_%d_%s", time.Now().UnixNano(), m.ip),
+				Type:         BotnetActivity,
+				Severity:     severity,
+				Confidence:   0.75,
+				Pattern:      "Identical request sequence, user agent and timing",
+				URL:          last.URL,
+				IP:           m.ip,
+				UserAgent:    last.UserAgent,
+				Timestamp:    last.Timestamp,
+				Method:       last.Method,
+				StatusCode:   last.Status,
+				AttackVector: "Coordinated automation",
+				Context: map[string]interface{}{
+					"description":      fmt.Sprintf("One of %d IPs sending the same requests every %s", len(members), m.median.Round(time.Second)),
+					"botnet_id":        botnetID,
+					"botnet_members":   ips,
+					"request_sequence": strings.Join(sequences[fingerprint], " → "),
+					"median_interval":  m.median.String(),
+				},
+				MitigationAdvice: []string{"Block all member IPs together", "Challenge the shared user agent", "Rate limit the targeted endpoints across IPs"},
+			})
+		}
+	}
+
+	return threats
+}
+
+// threatBotnet returns the botnet ID and member IPs a threat was attributed
+// to; false for threats outside a botnet
+func threatBotnet(threat EnhancedThreat) (string, []string, bool) {
+	id, ok := threat.Context["botnet_id"].(string)
+	if !ok {
+		return "", nil, false
+	}
+	members, _ := threat.Context["botnet_members"].([]string)
+	return id, members, true
+}
+
+// incidentBotnet returns the botnet of an incident's threats; no members for
+// incidents of a single IP
+func incidentBotnet(threats []EnhancedThreat) (string, []string) {
+	for _, threat := range threats {
+		if id, members, ok := threatBotnet(threat); ok {
+			return id, members
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,364 @@
+package security
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/decode"
+	"smart-log-analyser/pkg/parser"
+)
+
+// cmsProduct says where a CMS keeps its login form and extensions
+type cmsProduct struct {
+	name  string
+	login *regexp.Regexp
+	// Failed logins redirect back to the form like successful ones, so every
+	// login POST counts as an attempt
+	redirectsOnFailure bool
+	extension          *regexp.Regexp // First submatch is the plugin, theme or extension
+	extensionsPath     string
+}
+
+// cmsProducts are the CMSs whose attacks are told apart
+var cmsProducts = []cmsProduct{
+	{
+		name:           "WordPress",
+		login:          regexp.MustCompile(`(?i)^/wp-login\.php$`),
+		extension:      regexp.MustCompile(`(?i)^/wp-content/(?:plugins|themes)/([a-z0-9._-]+)`),
+		extensionsPath: "/wp-content/plugins/",
+	},
+	{
+		name:               "Joomla",
+		login:              regexp.MustCompile(`(?i)^/administrator/(?:index\.php)?$`),
+		redirectsOnFailure: true,
+		extension:          regexp.MustCompile(`(?i)^(?:/administrator)?/(?:components|modules)/((?:com|mod)_[a-z0-9_]+)`),
+		extensionsPath:     "/components/",
+	},
+	{
+		name:           "Drupal",
+		login:          regexp.MustCompile(`(?i)^/user(?:/login)?$`),
+		extension:      regexp.MustCompile(`(?i)^/(?:sites/[^/]+/)?modules/(?:contrib/|custom/)?([a-z0-9_]+)`),
+		extensionsPath: "/modules/",
+	},
+}
+
+// xmlrpcPath is the XML-RPC endpoint of WordPress, which takes hundreds of
+// password guesses in one system.multicall and reflects pingbacks at others
+var xmlrpcPath = regexp.MustCompile(`(?i)^/xmlrpc\.php$`)
+
+// CMS attack thresholds per IP
+const (
+	xmlrpcAbuseThreshold       = 5  // POSTs to xmlrpc.php
+	pluginEnumerationThreshold = 10 // Distinct missing plugins, themes or extensions
+)
+
+// cmsProbe is a request for a vulnerable plugin or a leaked file of a CMS
+type cmsProbe struct {
+	cms      string
+	name     string
+	target   string // Plugin, extension or file probed
+	cves     []string
+	severity ThreatSeverity
+	pattern  *regexp.Regexp
+}
+
+// cmsProbes are the vulnerable plugin and sensitive file requests seen in CMS
+// scanning, matched against the decoded URL
+var cmsProbes = []cmsProbe{
+	{"WordPress", "WP File Manager connector upload", "wp-file-manager", []string{"CVE-2020-25213"}, SeverityCritical,
+		regexp.MustCompile(`(?i)/wp-content/plugins/wp-file-manager/lib/php/connector\.minimal\.php`)},
+	{"WordPress", "Slider Revolution file download", "revslider", []string{"CVE-2014-9734"}, SeverityHigh,
+		regexp.MustCompile(`(?i)action=revslider_show_image&[^\s]*img=`)},
+	{"WordPress", "Duplicator file download", "duplicator", []string{"CVE-2020-11738"}, SeverityHigh,
+		regexp.MustCompile(`(?i)action=duplicator_download&[^\s]*file=`)},
+	{"WordPress", "Social Warfare remote code execution", "social-warfare", []string{"CVE-2019-9978"}, SeverityCritical,
+		regexp.MustCompile(`(?i)swp_debug=load_options&[^\s]*swp_url=`)},
+	{"WordPress", "TimThumb remote file inclusion", "timthumb", []string{"CVE-2011-4106"}, SeverityHigh,
+		regexp.MustCompile(`(?i)/(?:tim)?thumb\.php\?[^\s]*src=(?:https?:)?//`)},
+	{"WordPress", "wp-config.php backup", "wp-config.php", nil, SeverityHigh,
+		regexp.MustCompile(`(?i)/wp-config\.php(?:\.bak|\.old|\.orig|\.save|\.swp|\.txt|~|\.\d+)`)},
+	{"WordPress", "Debug log", "debug.log", nil, SeverityMedium,
+		regexp.MustCompile(`(?i)/wp-content/debug\.log`)},
+	{"Joomla", "com_fields SQL injection", "com_fields", []string{"CVE-2017-8917"}, SeverityCritical,
+		regexp.MustCompile(`(?i)option=com_fields&[^\s]*list\[fullordering\]=`)},
+	{"Joomla", "API information disclosure", "api", []string{"CVE-2023-23752"}, SeverityHigh,
+		regexp.MustCompile(`(?i)/api/index\.php/v1/(?:config/application|users)\?[^\s]*public=true`)},
+	{"Joomla", "configuration.php backup", "configuration.php", nil, SeverityHigh,
+		regexp.MustCompile(`(?i)/configuration\.php(?:\.bak|\.old|\.orig|\.save|\.swp|\.txt|~)`)},
+	{"Drupal", "settings.php backup", "settings.php", nil, SeverityHigh,
+		regexp.MustCompile(`(?i)/sites/[^/]+/settings\.php(?:\.bak|\.old|\.orig|\.save|\.swp|\.txt|~)`)},
+}
+
+// detectCMSProbes matches the request against the vulnerable plugin and
+// sensitive file probes of the CMSs
+func (td *ThreatDetector) detectCMSProbes(entry *parser.LogEntry) []EnhancedThreat {
+	var threats []EnhancedThreat
+
+	target := decode.ForMatching(entry.URL)
+
+	for _, probe := range cmsProbes {
+		match := probe.pattern.FindStringIndex(target)
+		if match == nil {
+			continue
+		}
+
+		description := fmt.Sprintf("%s %s", probe.cms, probe.name)
+		if len(probe.cves) > 0 {
+			description += fmt.Sprintf(" (%s)", strings.Join(probe.cves, ", "))
+		}
+		if entry.Status >= 200 && entry.Status < 300 {
+			description += fmt.Sprintf(", answered with %d", entry.Status)
+		}
+		context := map[string]interface{}{
+			"description": description,
+			"cms":         probe.cms,
+			"target":      probe.target,
+		}
+		advice := []string{
+			fmt.Sprintf("Remove or update %s", probe.target),
+			"Block the source IP",
+		}
+		if len(probe.cves) > 0 {
+			context["cves"] = probe.cves
+			advice[0] = fmt.Sprintf("Update or remove %s, vulnerable to %s", probe.target, strings.Join(probe.cves, ", "))
+		}
+		threats = append(threats, EnhancedThreat{
+			ID:               fmt.Sprintf("cms_%d_%s", time.Now().UnixNano(), entry.IP),
+			Type:             CMSVulnerabilityProbe,
+			Severity:         probe.severity,
+			Confidence:       0.9,
+			Pattern:          probe.pattern.String(),
+			URL:              entry.URL,
+			IP:               entry.IP,
+			UserAgent:        entry.UserAgent,
+			Timestamp:        entry.Timestamp,
+			Method:           entry.Method,
+			StatusCode:       entry.Status,
+			ResponseSize:     entry.Size,
+			AttackVector:     "HTTP Request",
+			Payload:          target[match[0]:match[1]],
+			Context:          context,
+			MitigationAdvice: advice,
+		})
+	}
+
+	return threats
+}
+
+// detectCMSAttacks detects XML-RPC abuse, CMS login brute force and plugin
+// enumeration by an IP
+func (td *ThreatDetector) detectCMSAttacks(ip string, entries []*parser.LogEntry) []EnhancedThreat {
+	var threats []EnhancedThreat
+
+	xmlrpcCalls := 0
+	loginAttempts := make(map[string]int)
+	missingExtensions := make(map[string]map[string]bool)
+	var last *parser.LogEntry
+	for _, entry := range entries {
+		if last == nil || entry.Timestamp.After(last.Timestamp) {
+			last = entry
+		}
+		path := requestPath(entry.URL)
+		post := strings.EqualFold(entry.Method, "POST")
+
+		if post && xmlrpcPath.MatchString(path) {
+			xmlrpcCalls++
+			continue
+		}
+		for _, product := range cmsProducts {
+			if post && product.login.MatchString(path) {
+				// The generic brute force detection counts the 401s and 403s
+				if !isFailedLogin(entry) && (product.redirectsOnFailure || entry.Status < 300) {
+					loginAttempts[product.name]++
+				}
+				break
+			}
+			if entry.Status == 404 {
+				if match := product.extension.FindStringSubmatch(path); match != nil {
+					if missingExtensions[product.name] == nil {
+						missingExtensions[product.name] = make(map[string]bool)
+					}
+					missingExtensions[product.name][strings.ToLower(match[1])] = true
+					break
+				}
+			}
+		}
+	}
+
+	newThreat := func(id string, threatType interface{}, severity ThreatSeverity, confidence float64, url, pattern string, context map[string]interface{}, advice []string) EnhancedThreat {
+		return EnhancedThreat{
+			ID:               fmt.Sprintf("%s_%d_%s", id, time.Now().UnixNano(), ip),
+			Type:             threatType,
+			Severity:         severity,
+			Confidence:       confidence,
+			Pattern:          pattern,
+			URL:              url,
+			IP:               ip,
+			UserAgent:        last.UserAgent,
+			Timestamp:        last.Timestamp,
+			Method:           "POST",
+			AttackVector:     "CMS",
+			Context:          context,
+			MitigationAdvice: advice,
+		}
+	}
+
+	if xmlrpcCalls >= xmlrpcAbuseThreshold {
+		threats = append(threats, newThreat("xmlrpc", XMLRPCAbuse, cmsAttemptSeverity(xmlrpcCalls), 0.8, "/xmlrpc.php",
+			"Repeated XML-RPC calls",
+			map[string]interface{}{
+				"description":     fmt.Sprintf("%d POSTs to xmlrpc.php, used for multicall password guessing and pingback floods", xmlrpcCalls),
+				"cms":             "WordPress",
+				"xmlrpc_requests": xmlrpcCalls,
+			},
+			[]string{"Disable XML-RPC or block xmlrpc.php at the web server", "Block the source IP", "Enable rate limiting"}))
+	}
+
+	for _, product := range cmsProducts {
+		if attempts := loginAttempts[product.name]; attempts > bruteForceThreshold {
+			threats = append(threats, newThreat("brute", BruteForceLogin, cmsAttemptSeverity(attempts), math.Min(0.95, float64(attempts)/50), "/auth-endpoints",
+				fmt.Sprintf("%s login brute force", product.name),
+				map[string]interface{}{
+					"description":     fmt.Sprintf("%d %s login attempts", attempts, product.name),
+					"cms":             product.name,
+					"failed_attempts": attempts,
+				},
+				[]string{"Limit login attempts", "Enable MFA for CMS accounts", "Restrict the admin login to known networks"}))
+		}
+
+		missing := missingExtensions[product.name]
+		if len(missing) < pluginEnumerationThreshold {
+			continue
+		}
+		var names []string
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		severity := SeverityLow
+		if len(names) >= 5*pluginEnumerationThreshold {
+			severity = SeverityMedium
+		}
+		threat := newThreat("plugins", PluginEnumeration, severity, 0.85, product.extensionsPath, fmt.Sprintf("%s plugin enumeration", product.name),
+			map[string]interface{}{
+				"description":        fmt.Sprintf("Requests for %d %s plugins, themes or extensions that are not installed", len(names), product.name),
+				"cms":                product.name,
+				"missing_extensions": len(names),
+				"extensions":         names,
+			},
+			[]string{"Keep plugins, themes and extensions updated", "Remove unused ones", "Block the source IP"})
+		threat.Method = "GET"
+		threats = append(threats, threat)
+	}
+
+	return threats
+}
+
+// cmsAttemptSeverity grades repeated login and XML-RPC attempts like brute
+// force
+func cmsAttemptSeverity(attempts int) ThreatSeverity {
+	switch {
+	case attempts > 100:
+		return SeverityCritical
+	case attempts > 50:
+		return SeverityHigh
+	default:
+		return SeverityMedium
+	}
+}
+
+// threatCMS returns the CMS a threat was aimed at, if any
+func threatCMS(threat EnhancedThreat) (string, bool) {
+	cms, ok := threat.Context["cms"].(string)
+	return cms, ok && cms != ""
+}
+
+// CMSSummary sums up the attacks on one CMS
+type CMSSummary struct {
+	CMS            string         `json:"cms"`
+	Threats        int            `json:"threats"`
+	IPs            int            `json:"ips"`
+	AttackTypes    map[string]int `json:"attack_types"` // Threats per attack type
+	LoginAttempts  int            `json:"login_attempts"`
+	XMLRPCRequests int            `json:"xmlrpc_requests"`
+	Targets        []CMSTarget    `json:"targets,omitempty"` // Vulnerable plugins and files probed, most probed first
+}
+
+// CMSTarget is a vulnerable plugin or sensitive file of a CMS and how often
+// it was probed
+type CMSTarget struct {
+	Name   string   `json:"name"`
+	Probes int      `json:"probes"`
+	CVEs   []string `json:"cves,omitempty"`
+}
+
+// SummarizeCMSAttacks sums up the threats aimed at each CMS, most attacked
+// first
+func SummarizeCMSAttacks(threats []EnhancedThreat) []CMSSummary {
+	type cmsTotals struct {
+		summary *CMSSummary
+		ips     map[string]bool
+		targets map[string]*CMSTarget
+	}
+
+	totals := make(map[string]*cmsTotals)
+	for _, threat := range threats {
+		cms, ok := threatCMS(threat)
+		if !ok {
+			continue
+		}
+		t, exists := totals[cms]
+		if !exists {
+			t = &cmsTotals{
+				summary: &CMSSummary{CMS: cms, AttackTypes: make(map[string]int)},
+				ips:     make(map[string]bool),
+				targets: make(map[string]*CMSTarget),
+			}
+			totals[cms] = t
+		}
+
+		t.summary.Threats++
+		t.summary.AttackTypes[threatTypeName(threat)]++
+		t.ips[threat.IP] = true
+		if attempts, ok := threat.Context["failed_attempts"].(int); ok {
+			t.summary.LoginAttempts += attempts
+		}
+		if calls, ok := threat.Context["xmlrpc_requests"].(int); ok {
+			t.summary.XMLRPCRequests += calls
+		}
+		if name, ok := threat.Context["target"].(string); ok {
+			target, exists := t.targets[name]
+			if !exists {
+				target = &CMSTarget{Name: name, CVEs: ThreatCVEs(threat)}
+				t.targets[name] = target
+			}
+			target.Probes++
+		}
+	}
+
+	summaries := make([]CMSSummary, 0, len(totals))
+	for _, t := range totals {
+		t.summary.IPs = len(t.ips)
+		for _, target := range t.targets {
+			t.summary.Targets = append(t.summary.Targets, *target)
+		}
+		sort.Slice(t.summary.Targets, func(i, j int) bool {
+			if t.summary.Targets[i].Probes != t.summary.Targets[j].Probes {
+				return t.summary.Targets[i].Probes > t.summary.Targets[j].Probes
+			}
+			return t.summary.Targets[i].Name < t.summary.Targets[j].Name
+		})
+		summaries = append(summaries, *t.summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Threats != summaries[j].Threats {
+			return summaries[i].Threats > summaries[j].Threats
+		}
+		return summaries[i].CMS < summaries[j].CMS
+	})
+	return summaries
+}
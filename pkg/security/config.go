@@ -0,0 +1,103 @@
+package security
+
+import (
+	"smart-log-analyser/pkg/config"
+)
+
+// ScoringConfig holds the weights of the security score and the boundaries
+// of its risk levels
+type ScoringConfig struct {
+	DimensionWeights       SecurityDimensionWeights   // Fractions summing to 1
+	SeverityWeights        map[ThreatSeverity]float64 // Impact of a threat per severity
+	AnomalySeverityWeights map[ThreatSeverity]float64 // Impact of an anomaly per severity
+	RiskBoundaries         RiskBoundaries
+}
+
+// RiskBoundaries are the lowest security scores of the risk levels; scores
+// below High are Critical
+type RiskBoundaries struct {
+	Minimal int
+	Low     int
+	Medium  int
+	High    int
+}
+
+// DefaultScoringConfig returns the built-in scoring: 40/25/20/15 dimension
+// weights and risk levels from 90, 70, 50 and 30
+func DefaultScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		DimensionWeights: SecurityDimensionWeights{
+			ThreatDetection:  0.40, // 40% - Direct threats are most important
+			AnomalyDetection: 0.25, // 25% - Behavioral anomalies
+			TrafficIntegrity: 0.20, // 20% - Overall traffic health
+			AccessControl:    0.15, // 15% - Authentication/authorization issues
+		},
+		SeverityWeights: map[ThreatSeverity]float64{
+			SeverityInfo:     1.0,
+			SeverityLow:      2.0,
+			SeverityMedium:   5.0,
+			SeverityHigh:     10.0,
+			SeverityCritical: 20.0,
+		},
+		AnomalySeverityWeights: map[ThreatSeverity]float64{
+			SeverityInfo:     0.5,
+			SeverityLow:      1.0,
+			SeverityMedium:   2.5,
+			SeverityHigh:     5.0,
+			SeverityCritical: 10.0,
+		},
+		RiskBoundaries: RiskBoundaries(config.DefaultRiskLevelBoundaries),
+	}
+}
+
+// ScoringConfigFrom returns the built-in scoring with the configured
+// settings on top. The configured dimension weights are scaled to sum to 1.
+func ScoringConfigFrom(scoring config.SecurityScoringConfig) ScoringConfig {
+	configuration := DefaultScoringConfig()
+
+	if weights := scoring.DimensionWeights; weights.IsSet() {
+		total := weights.ThreatDetection + weights.AnomalyDetection + weights.TrafficIntegrity + weights.AccessControl
+		configuration.DimensionWeights = SecurityDimensionWeights{
+			ThreatDetection:  weights.ThreatDetection / total,
+			AnomalyDetection: weights.AnomalyDetection / total,
+			TrafficIntegrity: weights.TrafficIntegrity / total,
+			AccessControl:    weights.AccessControl / total,
+		}
+	}
+	for name, weight := range scoring.SeverityWeights {
+		if severity, err := ParseSeverity(name); err == nil {
+			configuration.SeverityWeights[severity] = weight
+		}
+	}
+	for name, weight := range scoring.AnomalySeverityWeights {
+		if severity, err := ParseSeverity(name); err == nil {
+			configuration.AnomalySeverityWeights[severity] = weight
+		}
+	}
+	configuration.RiskBoundaries = RiskBoundaries(scoring.RiskLevels.Boundaries())
+	return configuration
+}
+
+// withScoring returns the config with the built-in scoring when it has none
+func withScoring(config SecurityConfig) SecurityConfig {
+	if config.Scoring.SeverityWeights == nil {
+		config.Scoring = DefaultScoringConfig()
+	}
+	return config
+}
+
+// RiskLevel returns the risk level of a security score
+func (b RiskBoundaries) RiskLevel(securityScore int) RiskLevel {
+	switch {
+	case securityScore >= b.Minimal:
+		return RiskMinimal
+	case securityScore >= b.Low:
+		return RiskLow
+	case securityScore >= b.Medium:
+		return RiskMedium
+	case securityScore >= b.High:
+		return RiskHigh
+	default:
+		return RiskCritical
+	}
+}
@@ -0,0 +1,257 @@
+package security
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"smart-log-analyser/pkg/decode"
+	"smart-log-analyser/pkg/parser"
+)
+
+// CVESignaturesFilename is the CVE signature file name inside the config
+// directory
+const CVESignaturesFilename = "cve-signatures.yaml"
+
+// cveSignaturesFile is the YAML layout of a CVE signature file
+type cveSignaturesFile struct {
+	Signatures []CVESignatureSpec `yaml:"signatures"`
+}
+
+// CVESignatureSpec is an exploit signature as written in a CVE signature file
+type CVESignatureSpec struct {
+	Name     string   `yaml:"name"`
+	CVEs     []string `yaml:"cves"`
+	Product  string   `yaml:"product,omitempty"`
+	Severity string   `yaml:"severity,omitempty"` // info, low, medium, high or critical (default)
+	Pattern  string   `yaml:"pattern"`            // Regular expression on the decoded URL, user agent and referer
+	Methods  []string `yaml:"methods,omitempty"`
+}
+
+// CVESignature is a compiled signature of exploitation attempts of known
+// vulnerabilities that show in access logs
+type CVESignature struct {
+	Name     string
+	CVEs     []string
+	Product  string
+	Severity ThreatSeverity
+	Pattern  *regexp.Regexp
+	Methods  []string
+}
+
+// cveIDPattern is the form of a CVE ID
+var cveIDPattern = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+
+// defaultCVESignatureSpecs are the built-in signatures, kept in step with
+// config/cve-signatures.yaml
+var defaultCVESignatureSpecs = []CVESignatureSpec{
+	{Name: "Log4Shell JNDI lookup", CVEs: []string{"CVE-2021-44228", "CVE-2021-45046"}, Product: "Apache Log4j",
+		Pattern: `(?i)\$\{\s*(?:jndi\s*:|\$\{(?:lower|upper|::-|env|sys|date)[^}]*\})`},
+	{Name: "Text4Shell interpolation", CVEs: []string{"CVE-2022-42889"}, Product: "Apache Commons Text",
+		Pattern: `(?i)\$\{(?:script|dns|url):`},
+	{Name: "Spring4Shell class loader manipulation", CVEs: []string{"CVE-2022-22965"}, Product: "Spring Framework",
+		Pattern: `(?i)class\.module\.classLoader`},
+	{Name: "OGNL expression injection", CVEs: []string{"CVE-2022-26134", "CVE-2021-26084", "CVE-2018-11776"}, Product: "Atlassian Confluence, Apache Struts",
+		Pattern: `(?i)\$\{[^}]*(?:@java\.lang\.|@org\.apache\.|#_memberAccess|getRuntime\(\))`},
+	{Name: "PHPUnit eval-stdin remote code execution", CVEs: []string{"CVE-2017-9841"}, Product: "PHPUnit",
+		Pattern: `(?i)/phpunit/.*/eval-stdin\.php`},
+	{Name: "Citrix ADC path traversal", CVEs: []string{"CVE-2019-19781"}, Product: "Citrix ADC and Gateway",
+		Pattern: `(?i)/vpns?/(?:\.\./)+vpns/|/vpns/portal/scripts/newbm\.pl`},
+	{Name: "Exchange ProxyShell and ProxyNotShell", CVEs: []string{"CVE-2021-34473", "CVE-2021-34523", "CVE-2021-31207", "CVE-2022-41040"}, Product: "Microsoft Exchange",
+		Pattern: `(?i)/autodiscover/autodiscover\.json\?[^\s]*(?:@[^\s]*/(?:mapi|ews|powershell)|powershell)`},
+	{Name: "BIG-IP iControl REST authentication bypass", CVEs: []string{"CVE-2022-1388"}, Product: "F5 BIG-IP",
+		Pattern: `(?i)/mgmt/tm/util/bash`, Methods: []string{"POST"}},
+	{Name: "BIG-IP TMUI remote code execution", CVEs: []string{"CVE-2020-5902"}, Product: "F5 BIG-IP",
+		Pattern: `(?i)/tmui/login\.jsp/\.\.;/`},
+	{Name: "FortiOS SSL VPN path traversal", CVEs: []string{"CVE-2018-13379"}, Product: "Fortinet FortiOS",
+		Pattern: `(?i)/remote/fgt_lang\?lang=/\.\./`},
+	{Name: "Pulse Connect Secure file read", CVEs: []string{"CVE-2019-11510"}, Product: "Pulse Connect Secure",
+		Pattern: `(?i)/dana-na/.*\.\./.*dana/html5acc/guacamole`},
+	{Name: "Ivanti Connect Secure authentication bypass", CVEs: []string{"CVE-2023-46805", "CVE-2024-21887"}, Product: "Ivanti Connect Secure",
+		Pattern: `(?i)/api/v1/totp/user-backup-code/\.\./`},
+	{Name: "Apache HTTP Server path traversal", CVEs: []string{"CVE-2021-41773", "CVE-2021-42013"}, Product: "Apache HTTP Server",
+		Pattern: `(?i)/(?:cgi-bin|icons)/(?:\.|%2e|%%32%65){2}/`},
+	{Name: "Drupalgeddon2 form API injection", CVEs: []string{"CVE-2018-7600"}, Product: "Drupal",
+		Pattern: `(?i)element_parents=account/mail/#value|\[#(?:post_render|markup|access_callback)\]`},
+	{Name: "ThinkPHP invokefunction remote code execution", CVEs: []string{"CVE-2018-20062", "CVE-2019-9082"}, Product: "ThinkPHP",
+		Pattern: `(?i)think\\app/invokefunction`},
+	{Name: "WebLogic console authentication bypass", CVEs: []string{"CVE-2020-14882", "CVE-2020-14883"}, Product: "Oracle WebLogic Server",
+		Pattern: `(?i)/console/(?:css|images)/\.\./console\.portal`},
+	{Name: "vCenter vROps plugin file upload", CVEs: []string{"CVE-2021-21972"}, Product: "VMware vCenter Server",
+		Pattern: `(?i)/ui/vropspluginui/rest/services/uploadova`},
+	{Name: "MOVEit Transfer SQL injection and web shell", CVEs: []string{"CVE-2023-34362"}, Product: "Progress MOVEit Transfer",
+		Pattern: `(?i)/moveitisapi/moveitisapi\.dll\?action=m2|/human2\.aspx`},
+	{Name: "PHP-CGI argument injection", CVEs: []string{"CVE-2012-1823", "CVE-2024-4577"}, Product: "PHP",
+		Pattern: `(?i)(?:allow_url_include|auto_prepend_file)\s*=`},
+	{Name: "Shellshock", CVEs: []string{"CVE-2014-6271"}, Product: "GNU Bash",
+		Pattern: `\(\)\s*\{\s*[^}]*;\s*\}\s*;`},
+}
+
+// defaultCVESignatures are the compiled built-in signatures
+var defaultCVESignatures = mustCompileCVESignatures(defaultCVESignatureSpecs)
+
+// DefaultCVESignatures returns the built-in CVE signatures
+func DefaultCVESignatures() []CVESignature {
+	return append([]CVESignature(nil), defaultCVESignatures...)
+}
+
+// DefaultCVESignatureSpecs returns the built-in CVE signatures in file form
+func DefaultCVESignatureSpecs() []CVESignatureSpec {
+	return append([]CVESignatureSpec(nil), defaultCVESignatureSpecs...)
+}
+
+// LoadCVESignatures reads and compiles the signatures of a CVE signature
+// file. If the file does not exist the built-in signatures are returned.
+func LoadCVESignatures(filename string) ([]CVESignature, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return DefaultCVESignatures(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CVE signature file: %w", err)
+	}
+	var file cveSignaturesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	signatures := []CVESignature{}
+	for i, spec := range file.Signatures {
+		signature, err := compileCVESignature(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: signature %d: %w", filename, i+1, err)
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, nil
+}
+
+// SaveCVESignatures writes signatures to a CVE signature file
+func SaveCVESignatures(filename string, specs []CVESignatureSpec) error {
+	data, err := yaml.Marshal(cveSignaturesFile{Signatures: specs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal CVE signatures: %w", err)
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// compileCVESignature validates a signature and compiles its pattern
+func compileCVESignature(spec CVESignatureSpec) (CVESignature, error) {
+	signature := CVESignature{Name: strings.TrimSpace(spec.Name), Product: spec.Product, Severity: SeverityCritical}
+	if signature.Name == "" {
+		return signature, fmt.Errorf("missing name")
+	}
+	if len(spec.CVEs) == 0 {
+		return signature, fmt.Errorf("%s: no CVE IDs", signature.Name)
+	}
+	for _, id := range spec.CVEs {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if !cveIDPattern.MatchString(id) {
+			return signature, fmt.Errorf("%s: invalid CVE ID %q", signature.Name, id)
+		}
+		signature.CVEs = append(signature.CVEs, id)
+	}
+	if spec.Severity != "" {
+		severity, err := ParseSeverity(spec.Severity)
+		if err != nil {
+			return signature, fmt.Errorf("%s: %w", signature.Name, err)
+		}
+		signature.Severity = severity
+	}
+	if spec.Pattern == "" {
+		return signature, fmt.Errorf("%s: missing pattern", signature.Name)
+	}
+	pattern, err := regexp.Compile(spec.Pattern)
+	if err != nil {
+		return signature, fmt.Errorf("%s: invalid pattern: %w", signature.Name, err)
+	}
+	signature.Pattern = pattern
+	for _, method := range spec.Methods {
+		signature.Methods = append(signature.Methods, strings.ToUpper(method))
+	}
+	return signature, nil
+}
+
+// mustCompileCVESignatures compiles built-in signatures, which are known to
+// be valid
+func mustCompileCVESignatures(specs []CVESignatureSpec) []CVESignature {
+	signatures := make([]CVESignature, len(specs))
+	for i, spec := range specs {
+		signature, err := compileCVESignature(spec)
+		if err != nil {
+			panic(err)
+		}
+		signatures[i] = signature
+	}
+	return signatures
+}
+
+// cveSignatures returns the configured signatures, or the built-in ones
+func (td *ThreatDetector) cveSignatures() []CVESignature {
+	if td.config.CVESignatures != nil {
+		return td.config.CVESignatures
+	}
+	return defaultCVESignatures
+}
+
+// detectKnownExploits matches the request against the CVE signatures, with
+// the CVE IDs of each match in the threat
+func (td *ThreatDetector) detectKnownExploits(entry *parser.LogEntry) []EnhancedThreat {
+	var threats []EnhancedThreat
+
+	target := decode.ForMatching(entry.URL, entry.UserAgent, entry.Referer)
+
+	for _, signature := range td.cveSignatures() {
+		if len(signature.Methods) > 0 && !containsValue(signature.Methods, strings.ToUpper(entry.Method)) {
+			continue
+		}
+		match := signature.Pattern.FindStringIndex(target)
+		if match == nil {
+			continue
+		}
+		payload := target[match[0]:match[1]]
+
+		description := fmt.Sprintf("%s (%s)", signature.Name, strings.Join(signature.CVEs, ", "))
+		if entry.Status >= 200 && entry.Status < 400 {
+			description += fmt.Sprintf(", answered with %d", entry.Status)
+		}
+		threats = append(threats, EnhancedThreat{
+			ID:           fmt.Sprintf("cve_%d_%s", time.Now().UnixNano(), entry.IP),
+			Type:         KnownExploit,
+			Severity:     signature.Severity,
+			Confidence:   0.95,
+			Pattern:      signature.Pattern.String(),
+			URL:          entry.URL,
+			IP:           entry.IP,
+			UserAgent:    entry.UserAgent,
+			Timestamp:    entry.Timestamp,
+			Method:       entry.Method,
+			StatusCode:   entry.Status,
+			ResponseSize: entry.Size,
+			AttackVector: "HTTP Request",
+			Payload:      payload,
+			Context: map[string]interface{}{
+				"description": description,
+				"signature":   signature.Name,
+				"cves":        signature.CVEs,
+				"product":     signature.Product,
+			},
+			MitigationAdvice: []string{
+				fmt.Sprintf("Patch %s against %s", signature.Product, strings.Join(signature.CVEs, ", ")),
+				"Check the targeted host for compromise if the request succeeded",
+				"Block the source IP",
+			},
+		})
+	}
+
+	return threats
+}
+
+// ThreatCVEs returns the CVE IDs a threat was attributed to, if any
+func ThreatCVEs(threat EnhancedThreat) []string {
+	cves, _ := threat.Context["cves"].([]string)
+	return cves
+}
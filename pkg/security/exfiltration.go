@@ -0,0 +1,400 @@
+package security
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// ExfiltrationType is an indicator of data leaving the site, a threat
+// category of its own next to web and infrastructure attacks
+type ExfiltrationType int
+
+const (
+	LargeResponseExfiltration ExfiltrationType = iota
+	SequentialEnumeration
+	OffHoursBulkDownload
+)
+
+// String returns the string representation of ExfiltrationType
+func (et ExfiltrationType) String() string {
+	switch et {
+	case LargeResponseExfiltration:
+		return "Large Response Exfiltration"
+	case SequentialEnumeration:
+		return "Sequential ID Enumeration"
+	case OffHoursBulkDownload:
+		return "Off-Hours Bulk Download"
+	default:
+		return "Unknown Exfiltration"
+	}
+}
+
+// Large responses: successful responses to an IP at least largeResponseFactor
+// times the median size of their path, and of largeResponseMinBytes or more,
+// adding up to largeResponseMinTotal
+const (
+	largeResponseFactor     = 10
+	largeResponseMinBytes   = 1 << 20
+	largeResponseMinTotal   = 10 << 20
+	largeResponseMinSamples = 5 // Responses a path needs for its own median
+)
+
+// Sequential enumeration: an IP walks enumerationMinRun or more IDs of one
+// URL template, each at most enumerationMaxStep above the previous
+const (
+	enumerationMinRun  = 20
+	enumerationMaxStep = 2
+)
+
+// Off-hours bulk downloads: successful responses to an IP between
+// offHoursStart and offHoursEnd, in the logs' own time zone, adding up to
+// offHoursMinBytes over offHoursMinURLs or more URLs
+const (
+	offHoursStart    = 22
+	offHoursEnd      = 6
+	offHoursMinBytes = 50 << 20
+	offHoursMinURLs  = 10
+)
+
+// detectExfiltration detects the exfiltration indicators of the IPs:
+// unusually large responses, sequential enumeration of ID-based URLs and bulk
+// downloads outside office hours
+func (td *ThreatDetector) detectExfiltration(logs []*parser.LogEntry, ipEntries map[string][]*parser.LogEntry) []EnhancedThreat {
+	var ips []string
+	for ip := range ipEntries {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	pathMedians, siteMedian := responseSizeMedians(logs)
+	var threats []EnhancedThreat
+	for _, ip := range ips {
+		entries := ipEntries[ip]
+		if threat, ok := detectLargeResponses(ip, entries, pathMedians, siteMedian); ok {
+			threats = append(threats, threat)
+		}
+		threats = append(threats, detectSequentialEnumeration(ip, entries)...)
+		if threat, ok := detectOffHoursBulk(ip, entries); ok {
+			threats = append(threats, threat)
+		}
+	}
+	return threats
+}
+
+// responseSizeMedians returns the median size of the successful responses of
+// each path with enough of them, and of the whole site
+func responseSizeMedians(logs []*parser.LogEntry) (map[string]int64, int64) {
+	sizes := make(map[string][]int64)
+	var all []int64
+	for _, entry := range logs {
+		if !successfulResponse(entry) {
+			continue
+		}
+		path := requestPath(entry.URL)
+		sizes[path] = append(sizes[path], entry.Size)
+		all = append(all, entry.Size)
+	}
+	medians := make(map[string]int64)
+	for path, pathSizes := range sizes {
+		if len(pathSizes) >= largeResponseMinSamples {
+			medians[path] = medianSize(pathSizes)
+		}
+	}
+	return medians, medianSize(all)
+}
+
+// detectLargeResponses reports an IP that received responses far larger than
+// their paths usually return
+func detectLargeResponses(ip string, entries []*parser.LogEntry, pathMedians map[string]int64, siteMedian int64) (EnhancedThreat, bool) {
+	var total int64
+	var largest, last *parser.LogEntry
+	responses := 0
+	for _, entry := range entries {
+		if !successfulResponse(entry) || entry.Size < largeResponseMinBytes {
+			continue
+		}
+		median, ok := pathMedians[requestPath(entry.URL)]
+		if !ok {
+			median = siteMedian
+		}
+		if entry.Size < largeResponseFactor*median {
+			continue
+		}
+		responses++
+		total += entry.Size
+		if largest == nil || entry.Size > largest.Size {
+			largest = entry
+		}
+		if last == nil || entry.Timestamp.After(last.Timestamp) {
+			last = entry
+		}
+	}
+	if total < largeResponseMinTotal {
+		return EnhancedThreat{}, false
+	}
+
+	return exfiltrationThreat(ip, LargeResponseExfiltration, exfiltrationSeverity(total, 100<<20, 1<<30), 0.6, last,
+		fmt.Sprintf("Responses over %dx the usual size of their path", largeResponseFactor),
+		map[string]interface{}{
+			"description":       fmt.Sprintf("%d oversized responses totalling %s, the largest %s from %s", responses, formatExfiltrationBytes(total), formatExfiltrationBytes(largest.Size), requestPath(largest.URL)),
+			"responses":         responses,
+			"bytes":             total,
+			"largest_url":       largest.URL,
+			"largest_bytes":     largest.Size,
+			"indicator_context": "large_response",
+		},
+		[]string{"Check what the responses contained and whether the IP may access it", "Paginate or cap the size of API and export responses", "Block the source IP if the access was not legitimate"}), true
+}
+
+// detectSequentialEnumeration reports the URL templates an IP walked through
+// ID by ID, such as /api/users/1001, /api/users/1002 and so on
+func detectSequentialEnumeration(ip string, entries []*parser.LogEntry) []EnhancedThreat {
+	type walk struct {
+		ids     map[int64]*parser.LogEntry
+		success map[int64]bool
+	}
+	walks := make(map[string]*walk)
+	for _, entry := range entries {
+		template, id, ok := idTemplate(entry.URL)
+		if !ok {
+			continue
+		}
+		w, exists := walks[template]
+		if !exists {
+			w = &walk{ids: make(map[int64]*parser.LogEntry), success: make(map[int64]bool)}
+			walks[template] = w
+		}
+		if previous, seen := w.ids[id]; !seen || entry.Timestamp.After(previous.Timestamp) {
+			w.ids[id] = entry
+		}
+		if successfulResponse(entry) {
+			w.success[id] = true
+		}
+	}
+
+	var templates []string
+	for template := range walks {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	var threats []EnhancedThreat
+	for _, template := range templates {
+		w := walks[template]
+		if len(w.ids) < enumerationMinRun {
+			continue
+		}
+		ids := make([]int64, 0, len(w.ids))
+		for id := range w.ids {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		// The longest run of IDs close to each other
+		bestStart, bestEnd, start := 0, 0, 0
+		for i := 1; i <= len(ids); i++ {
+			if i == len(ids) || ids[i]-ids[i-1] > enumerationMaxStep {
+				if i-start > bestEnd-bestStart {
+					bestStart, bestEnd = start, i
+				}
+				start = i
+			}
+		}
+		run := ids[bestStart:bestEnd]
+		if len(run) < enumerationMinRun {
+			continue
+		}
+
+		found := 0
+		var last *parser.LogEntry
+		for _, id := range run {
+			if w.success[id] {
+				found++
+			}
+			if entry := w.ids[id]; last == nil || entry.Timestamp.After(last.Timestamp) {
+				last = entry
+			}
+		}
+		severity := SeverityMedium
+		if found >= 100 {
+			severity = SeverityHigh
+		}
+		if found >= 500 {
+			severity = SeverityCritical
+		}
+
+		threats = append(threats, exfiltrationThreat(ip, SequentialEnumeration, severity, math.Min(0.95, 0.5+float64(len(run))/200), last,
+			"Sequential IDs of "+template,
+			map[string]interface{}{
+				"description":       fmt.Sprintf("Walked %d IDs of %s (%d to %d), %d of them found", len(run), template, run[0], run[len(run)-1], found),
+				"url_template":      template,
+				"ids":               len(run),
+				"first_id":          run[0],
+				"last_id":           run[len(run)-1],
+				"found":             found,
+				"indicator_context": "enumeration",
+			},
+			[]string{"Check that each object is authorized for the requesting user (IDOR)", "Use unguessable identifiers such as UUIDs", "Rate limit object lookups per client"}))
+	}
+	return threats
+}
+
+// detectOffHoursBulk reports an IP that downloaded a lot outside office hours
+func detectOffHoursBulk(ip string, entries []*parser.LogEntry) (EnhancedThreat, bool) {
+	var total int64
+	urls := make(map[string]bool)
+	var first, last *parser.LogEntry
+	for _, entry := range entries {
+		if !successfulResponse(entry) || !offHours(entry.Timestamp) {
+			continue
+		}
+		total += entry.Size
+		urls[entry.URL] = true
+		if first == nil || entry.Timestamp.Before(first.Timestamp) {
+			first = entry
+		}
+		if last == nil || entry.Timestamp.After(last.Timestamp) {
+			last = entry
+		}
+	}
+	if total < offHoursMinBytes || len(urls) < offHoursMinURLs {
+		return EnhancedThreat{}, false
+	}
+
+	return exfiltrationThreat(ip, OffHoursBulkDownload, exfiltrationSeverity(total, 500<<20, 5<<30), 0.55, last,
+		fmt.Sprintf("Bulk downloads between %02d:00 and %02d:00", offHoursStart, offHoursEnd),
+		map[string]interface{}{
+			"description":       fmt.Sprintf("Downloaded %s over %d URLs off hours, %s to %s", formatExfiltrationBytes(total), len(urls), first.Timestamp.Format("Jan 02 15:04"), last.Timestamp.Format("Jan 02 15:04")),
+			"bytes":             total,
+			"urls":              len(urls),
+			"indicator_context": "off_hours",
+		},
+		[]string{"Confirm the downloads with the account or system owner", "Restrict bulk exports to scheduled, authorized jobs", "Alert on large transfers outside office hours"}), true
+}
+
+// exfiltrationThreat builds the threat of an exfiltration indicator at the
+// last request that showed it
+func exfiltrationThreat(ip string, threatType ExfiltrationType, severity ThreatSeverity, confidence float64, last *parser.LogEntry, pattern string, context map[string]interface{}, advice []string) EnhancedThreat {
+	return EnhancedThreat{
+		ID:               fmt.Sprintf("exfil_%d_%s", time.Now().UnixNano(), ip),
+		Type:             threatType,
+		Severity:         severity,
+		Confidence:       confidence,
+		Pattern:          pattern,
+		URL:              last.URL,
+		IP:               ip,
+		UserAgent:        last.UserAgent,
+		Timestamp:        last.Timestamp,
+		Method:           last.Method,
+		StatusCode:       last.Status,
+		ResponseSize:     last.Size,
+		AttackVector:     "Data exfiltration",
+		Context:          context,
+		MitigationAdvice: advice,
+	}
+}
+
+// idTemplate returns the URL with its numeric ID replaced by {id}, and the
+// ID: the last all-digit path segment, or else an id query parameter
+func idTemplate(rawURL string) (string, int64, bool) {
+	path := requestPath(rawURL)
+	segments := strings.Split(path, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if id, ok := numericID(segments[i]); ok {
+			segments[i] = "{id}"
+			return strings.Join(segments, "/"), id, true
+		}
+	}
+
+	query := ""
+	if i := strings.Index(rawURL, "?"); i >= 0 {
+		query = rawURL[i+1:]
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", 0, false
+	}
+	var names []string
+	for name := range values {
+		if strings.HasSuffix(strings.ToLower(name), "id") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if id, ok := numericID(values.Get(name)); ok {
+			return path + "?" + name + "={id}", id, true
+		}
+	}
+	return "", 0, false
+}
+
+// numericID parses an ID of up to 12 digits
+func numericID(value string) (int64, bool) {
+	if value == "" || len(value) > 12 {
+		return 0, false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	return id, err == nil
+}
+
+// offHours reports whether a time is between offHoursStart and offHoursEnd
+// in its own time zone, that of the log
+func offHours(t time.Time) bool {
+	hour := t.Hour()
+	return hour >= offHoursStart || hour < offHoursEnd
+}
+
+// successfulResponse reports whether the request was answered with content
+func successfulResponse(entry *parser.LogEntry) bool {
+	return entry.Status >= 200 && entry.Status < 300
+}
+
+// exfiltrationSeverity grades bytes transferred: Medium, High from high and
+// Critical from critical
+func exfiltrationSeverity(bytes, high, critical int64) ThreatSeverity {
+	switch {
+	case bytes >= critical:
+		return SeverityCritical
+	case bytes >= high:
+		return SeverityHigh
+	default:
+		return SeverityMedium
+	}
+}
+
+// medianSize returns the median of the sizes, 0 without any
+func medianSize(sizes []int64) int64 {
+	if len(sizes) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), sizes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// formatExfiltrationBytes formats a byte count in binary units
+func formatExfiltrationBytes(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
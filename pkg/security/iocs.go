@@ -0,0 +1,168 @@
+package security
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IOC types
+const (
+	IOCTypeIP        = "ip"
+	IOCTypeUserAgent = "user-agent"
+	IOCTypePayload   = "payload"
+)
+
+// IOCFormats are the formats WriteIOCsFile can write
+var IOCFormats = []string{"json", "csv", "txt"}
+
+// IOC is an indicator of compromise seen in the threats, with the threats
+// that carried it
+type IOC struct {
+	Type        string    `json:"type"`
+	Value       string    `json:"value"`
+	Severity    string    `json:"severity"` // Highest severity of its threats
+	Threats     int       `json:"threats"`
+	AttackTypes []string  `json:"attack_types"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+
+	severity ThreatSeverity
+}
+
+// ExtractIOCs returns the attacking IPs, scanner and bot user agents and
+// attack payloads of the threats, the same indicators incidents list, most
+// severe and most frequent first
+func ExtractIOCs(threats []EnhancedThreat) []IOC {
+	indicators := make(map[string]*IOC)
+	add := func(iocType, value string, threat EnhancedThreat) {
+		key := iocType + "\x00" + value
+		ioc, exists := indicators[key]
+		if !exists {
+			ioc = &IOC{Type: iocType, Value: value, FirstSeen: threat.Timestamp, LastSeen: threat.Timestamp}
+			indicators[key] = ioc
+		}
+		ioc.Threats++
+		if threat.Severity > ioc.severity {
+			ioc.severity = threat.Severity
+		}
+		if threat.Timestamp.Before(ioc.FirstSeen) {
+			ioc.FirstSeen = threat.Timestamp
+		}
+		if threat.Timestamp.After(ioc.LastSeen) {
+			ioc.LastSeen = threat.Timestamp
+		}
+		if name := threatTypeName(threat); !containsValue(ioc.AttackTypes, name) {
+			ioc.AttackTypes = append(ioc.AttackTypes, name)
+		}
+	}
+
+	for _, threat := range threats {
+		if threat.IP != "" {
+			add(IOCTypeIP, threat.IP, threat)
+		}
+		userAgent := strings.ToLower(threat.UserAgent)
+		if strings.Contains(userAgent, "bot") || strings.Contains(userAgent, "scanner") {
+			add(IOCTypeUserAgent, threat.UserAgent, threat)
+		}
+		if threat.Payload != "" {
+			add(IOCTypePayload, threat.Payload, threat)
+		}
+	}
+
+	iocs := make([]IOC, 0, len(indicators))
+	for _, ioc := range indicators {
+		ioc.Severity = ioc.severity.String()
+		sort.Strings(ioc.AttackTypes)
+		iocs = append(iocs, *ioc)
+	}
+	sort.Slice(iocs, func(i, j int) bool {
+		if iocs[i].severity != iocs[j].severity {
+			return iocs[i].severity > iocs[j].severity
+		}
+		if iocs[i].Threats != iocs[j].Threats {
+			return iocs[i].Threats > iocs[j].Threats
+		}
+		if iocs[i].Type != iocs[j].Type {
+			return iocs[i].Type < iocs[j].Type
+		}
+		return iocs[i].Value < iocs[j].Value
+	})
+	return iocs
+}
+
+// IOCFormatFromFilename picks the IOC format from a file extension
+func IOCFormatFromFilename(filename string) (string, error) {
+	format := strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	if !containsValue(IOCFormats, format) {
+		return "", fmt.Errorf("cannot tell the IOC format of %s: use a .json, .csv or .txt extension", filename)
+	}
+	return format, nil
+}
+
+// WriteIOCs writes the IOCs as a JSON array, as CSV, or as plain text with
+// one value per line for feeding blocklists and watchlists
+func WriteIOCs(w io.Writer, format string, iocs []IOC) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if iocs == nil {
+			iocs = []IOC{}
+		}
+		return encoder.Encode(iocs)
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"type", "value", "severity", "threats", "attack_types", "first_seen", "last_seen"}); err != nil {
+			return err
+		}
+		for _, ioc := range iocs {
+			if err := writer.Write([]string{ioc.Type, ioc.Value, ioc.Severity, strconv.Itoa(ioc.Threats), strings.Join(ioc.AttackTypes, "; "),
+				ioc.FirstSeen.Format(time.RFC3339), ioc.LastSeen.Format(time.RFC3339)}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "txt":
+		for _, ioc := range iocs {
+			if _, err := fmt.Fprintln(w, ioc.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown IOC format %q (use %s)", format, strings.Join(IOCFormats, ", "))
+	}
+}
+
+// WriteIOCsFile writes the IOCs to filename in the format of its extension
+func WriteIOCsFile(filename string, iocs []IOC) error {
+	format, err := IOCFormatFromFilename(filename)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := WriteIOCs(file, format, iocs); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,118 @@
+package security
+
+import (
+	"sort"
+
+	"smart-log-analyser/pkg/mitre"
+)
+
+// webAttackTechniques maps web attacks to ATT&CK technique IDs. All of them
+// exploit the public-facing application; some also say what the attacker was
+// after.
+var webAttackTechniques = map[WebAttackType][]string{
+	SQLInjection:          {"T1190"},
+	CrossSiteScripting:    {"T1190", "T1189"},
+	CommandInjection:      {"T1190", "T1059"},
+	DirectoryTraversal:    {"T1190", "T1083"},
+	RemoteFileInclusion:   {"T1190", "T1105"},
+	LocalFileInclusion:    {"T1190", "T1083"},
+	XXEInjection:          {"T1190"},
+	DeserializationAttack: {"T1190"},
+	HTTPHeaderInjection:   {"T1190"},
+	CSRFAttack:            {"T1190"},
+	AuthenticationBypass:  {"T1190"},
+	SessionHijacking:      {"T1539"},
+	Clickjacking:          {"T1189"},
+	CSPBypass:             {"T1189"},
+	HTTPSplitting:         {"T1190"},
+	KnownExploit:          {"T1190"},
+	CMSVulnerabilityProbe: {"T1190"},
+}
+
+// infrastructureAttackTechniques maps infrastructure attacks to ATT&CK
+// technique IDs
+var infrastructureAttackTechniques = map[InfrastructureAttackType][]string{
+	BruteForceLogin:       {"T1110"},
+	PasswordSpray:         {"T1110.003"},
+	DDoSAttack:            {"T1498"},
+	PortScan:              {"T1595.001"},
+	VulnerabilityScanning: {"T1595.002"},
+	WebShellAccess:        {"T1505.003"},
+	PrivilegeEscalation:   {"T1068"},
+	DataExfiltration:      {"T1567"},
+	BotnetActivity:        {"T1583.005"},
+	CryptoMining:          {"T1496"},
+	ResourceExhaustion:    {"T1499"},
+	ServiceEnumeration:    {"T1046"},
+	ForceBrowsing:         {"T1595.003"},
+	CachePoison:           {"T1190"},
+	CredentialStuffing:    {"T1110.004"},
+	XMLRPCAbuse:           {"T1110", "T1498"},
+	PluginEnumeration:     {"T1595.003"},
+}
+
+// exfiltrationTechniques maps exfiltration indicators to ATT&CK technique IDs
+var exfiltrationTechniques = map[ExfiltrationType][]string{
+	LargeResponseExfiltration: {"T1567"},
+	SequentialEnumeration:     {"T1213", "T1567"},
+	OffHoursBulkDownload:      {"T1029", "T1567"},
+}
+
+// TechniqueCount is an ATT&CK technique with the threats mapped to it
+type TechniqueCount struct {
+	Technique mitre.Technique `json:"technique"`
+	Threats   int             `json:"threats"`
+	IPs       int             `json:"ips"`
+}
+
+// MITRETechniques returns the ATT&CK techniques of the threat's type, or
+// those of the custom rule that detected it; nil for types without a mapping
+func (t EnhancedThreat) MITRETechniques() []mitre.Technique {
+	if ids, ok := t.Context["mitre_techniques"].([]string); ok {
+		return mitre.ByIDs(ids...)
+	}
+	switch threatType := t.Type.(type) {
+	case WebAttackType:
+		return mitre.ByIDs(webAttackTechniques[threatType]...)
+	case InfrastructureAttackType:
+		return mitre.ByIDs(infrastructureAttackTechniques[threatType]...)
+	case ExfiltrationType:
+		return mitre.ByIDs(exfiltrationTechniques[threatType]...)
+	default:
+		return nil
+	}
+}
+
+// MapTechniques counts the threats and source IPs per ATT&CK technique, most
+// threats first
+func MapTechniques(threats []EnhancedThreat) []TechniqueCount {
+	counts := make(map[string]*TechniqueCount)
+	ips := make(map[string]map[string]bool)
+	for _, threat := range threats {
+		for _, technique := range threat.MITRETechniques() {
+			count, exists := counts[technique.ID]
+			if !exists {
+				count = &TechniqueCount{Technique: technique}
+				counts[technique.ID] = count
+				ips[technique.ID] = make(map[string]bool)
+			}
+			count.Threats++
+			if !ips[technique.ID][threat.IP] {
+				ips[technique.ID][threat.IP] = true
+				count.IPs++
+			}
+		}
+	}
+
+	result := make([]TechniqueCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, *count)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Threats != result[j].Threats {
+			return result[i].Threats > result[j].Threats
+		}
+		return result[i].Technique.ID < result[j].Technique.ID
+	})
+	return result
+}
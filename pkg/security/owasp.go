@@ -0,0 +1,175 @@
+package security
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxOWASPEndpoints caps the endpoints listed per OWASP Top 10 category
+const maxOWASPEndpoints = 5
+
+// OWASPCategory is a category of the OWASP Top 10 (2021)
+type OWASPCategory struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// OWASPTop10 are the OWASP Top 10 (2021) categories in order
+var OWASPTop10 = []OWASPCategory{
+	{"A01:2021", "Broken Access Control"},
+	{"A02:2021", "Cryptographic Failures"},
+	{"A03:2021", "Injection"},
+	{"A04:2021", "Insecure Design"},
+	{"A05:2021", "Security Misconfiguration"},
+	{"A06:2021", "Vulnerable and Outdated Components"},
+	{"A07:2021", "Identification and Authentication Failures"},
+	{"A08:2021", "Software and Data Integrity Failures"},
+	{"A09:2021", "Security Logging and Monitoring Failures"},
+	{"A10:2021", "Server-Side Request Forgery (SSRF)"},
+}
+
+// webAttackOWASP maps web attacks to the index of their OWASP Top 10
+// category
+var webAttackOWASP = map[WebAttackType]int{
+	SQLInjection:          2,
+	CrossSiteScripting:    2,
+	CommandInjection:      2,
+	DirectoryTraversal:    0,
+	RemoteFileInclusion:   2,
+	LocalFileInclusion:    2,
+	XXEInjection:          4,
+	DeserializationAttack: 7,
+	HTTPHeaderInjection:   2,
+	CSRFAttack:            0,
+	AuthenticationBypass:  6,
+	SessionHijacking:      6,
+	Clickjacking:          4,
+	CSPBypass:             4,
+	HTTPSplitting:         2,
+	KnownExploit:          5,
+	CMSVulnerabilityProbe: 5,
+}
+
+// infrastructureAttackOWASP maps the infrastructure attacks that exploit
+// the application to the index of their OWASP Top 10 category. Scanning,
+// floods and bot traffic have none.
+var infrastructureAttackOWASP = map[InfrastructureAttackType]int{
+	BruteForceLogin:     6,
+	PasswordSpray:       6,
+	CredentialStuffing:  6,
+	XMLRPCAbuse:         6,
+	PrivilegeEscalation: 0,
+	ForceBrowsing:       0,
+	CachePoison:         4,
+	PluginEnumeration:   5,
+}
+
+// OWASPSummary is the threats of an OWASP Top 10 category
+type OWASPSummary struct {
+	Category    OWASPCategory   `json:"category"`
+	Threats     int             `json:"threats"`
+	IPs         int             `json:"ips"`
+	Severity    string          `json:"severity,omitempty"` // Highest among the threats
+	AttackTypes map[string]int  `json:"attack_types,omitempty"`
+	Endpoints   int             `json:"endpoints"`               // Distinct paths targeted
+	Top         []OWASPEndpoint `json:"top_endpoints,omitempty"` // Most targeted paths
+}
+
+// OWASPEndpoint is a path targeted by the threats of an OWASP category
+type OWASPEndpoint struct {
+	Path    string `json:"path"`
+	Threats int    `json:"threats"`
+}
+
+// OWASPCategory returns the OWASP Top 10 category of the threat's type;
+// false for types without one
+func (t EnhancedThreat) OWASPCategory() (OWASPCategory, bool) {
+	index, ok := owaspIndex(t)
+	if !ok {
+		return OWASPCategory{}, false
+	}
+	return OWASPTop10[index], true
+}
+
+// owaspIndex returns the index in OWASPTop10 of the threat's category
+func owaspIndex(t EnhancedThreat) (int, bool) {
+	switch threatType := t.Type.(type) {
+	case WebAttackType:
+		index, ok := webAttackOWASP[threatType]
+		return index, ok
+	case InfrastructureAttackType:
+		index, ok := infrastructureAttackOWASP[threatType]
+		return index, ok
+	case ExfiltrationType:
+		// Walking object IDs is broken access control; the other indicators
+		// are about volume, not a weakness of the application
+		return 0, threatType == SequentialEnumeration
+	default:
+		return 0, false
+	}
+}
+
+// SummarizeOWASP groups the threats under the OWASP Top 10 categories, with
+// every category in order so categories without threats show as clear
+func SummarizeOWASP(threats []EnhancedThreat) []OWASPSummary {
+	summaries := make([]OWASPSummary, len(OWASPTop10))
+	ips := make([]map[string]bool, len(OWASPTop10))
+	endpoints := make([]map[string]int, len(OWASPTop10))
+	severities := make([]ThreatSeverity, len(OWASPTop10))
+	for i, category := range OWASPTop10 {
+		summaries[i].Category = category
+		ips[i] = make(map[string]bool)
+		endpoints[i] = make(map[string]int)
+	}
+
+	for _, threat := range threats {
+		i, ok := owaspIndex(threat)
+		if !ok {
+			continue
+		}
+		summary := &summaries[i]
+		summary.Threats++
+		ips[i][threat.IP] = true
+		if threat.Severity > severities[i] {
+			severities[i] = threat.Severity
+		}
+		if summary.AttackTypes == nil {
+			summary.AttackTypes = make(map[string]int)
+		}
+		summary.AttackTypes[threatTypeName(threat)]++
+		if path := endpointPath(threat.URL); path != "" {
+			endpoints[i][path]++
+		}
+	}
+
+	for i := range summaries {
+		summary := &summaries[i]
+		if summary.Threats == 0 {
+			continue
+		}
+		summary.IPs = len(ips[i])
+		summary.Severity = severities[i].String()
+		summary.Endpoints = len(endpoints[i])
+		for path, count := range endpoints[i] {
+			summary.Top = append(summary.Top, OWASPEndpoint{Path: path, Threats: count})
+		}
+		sort.Slice(summary.Top, func(a, b int) bool {
+			if summary.Top[a].Threats != summary.Top[b].Threats {
+				return summary.Top[a].Threats > summary.Top[b].Threats
+			}
+			return summary.Top[a].Path < summary.Top[b].Path
+		})
+		if len(summary.Top) > maxOWASPEndpoints {
+			summary.Top = summary.Top[:maxOWASPEndpoints]
+		}
+	}
+	return summaries
+}
+
+// endpointPath returns the path of a URL without its query string
+func endpointPath(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	return url
+}
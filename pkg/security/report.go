@@ -0,0 +1,245 @@
+package security
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Severities are the names accepted by ParseSeverity, lowest first
+var Severities = []string{"info", "low", "medium", "high", "critical"}
+
+// ParseSeverity parses a severity name such as "high", in any case
+func ParseSeverity(name string) (ThreatSeverity, error) {
+	for i, severity := range Severities {
+		if strings.EqualFold(strings.TrimSpace(name), severity) {
+			return ThreatSeverity(i), nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity %q (use %s)", name, strings.Join(Severities, ", "))
+}
+
+// FilterBySeverity returns a copy of the analysis with only the threats,
+// anomalies and incidents at or above min. The summary, profiles and
+// recommendations are kept as they are.
+func FilterBySeverity(analysis *EnhancedSecurityAnalysis, min ThreatSeverity) *EnhancedSecurityAnalysis {
+	filtered := *analysis
+	filtered.Threats = nil
+	for _, threat := range analysis.Threats {
+		if threat.Severity >= min {
+			filtered.Threats = append(filtered.Threats, threat)
+		}
+	}
+	filtered.Anomalies = nil
+	for _, anomaly := range analysis.Anomalies {
+		if anomaly.Severity >= min {
+			filtered.Anomalies = append(filtered.Anomalies, anomaly)
+		}
+	}
+	filtered.Incidents = nil
+	for _, incident := range analysis.Incidents {
+		if incident.Severity >= min {
+			filtered.Incidents = append(filtered.Incidents, incident)
+		}
+	}
+	return &filtered
+}
+
+// ThreatLevel returns the highest severity of the threats; false when there
+// are none
+func ThreatLevel(threats []EnhancedThreat) (ThreatSeverity, bool) {
+	if len(threats) == 0 {
+		return SeverityInfo, false
+	}
+	level := SeverityInfo
+	for _, threat := range threats {
+		if threat.Severity > level {
+			level = threat.Severity
+		}
+	}
+	return level, true
+}
+
+// Report is the JSON form of a security analysis
+type Report struct {
+	GeneratedAt     time.Time              `json:"generated_at"`
+	Start           time.Time              `json:"start"`
+	End             time.Time              `json:"end"`
+	Entries         int64                  `json:"entries"`
+	SecurityScore   int                    `json:"security_score"`
+	RiskLevel       string                 `json:"risk_level"`
+	ThreatLevel     string                 `json:"threat_level"` // Highest threat severity, "None" without threats
+	SeverityCounts  map[string]int         `json:"severity_counts"`
+	Threats         []ReportThreat         `json:"threats"`
+	Anomalies       []ReportAnomaly        `json:"anomalies"`
+	Incidents       []ReportIncident       `json:"incidents"`
+	CMS             []CMSSummary           `json:"cms,omitempty"` // Attacks per CMS
+	OWASP           []OWASPSummary         `json:"owasp"`         // Threats per OWASP Top 10 category
+	Recommendations []ReportRecommendation `json:"recommendations"`
+	Baseline        *BaselineDiff          `json:"baseline,omitempty"` // Changes since a baseline report
+}
+
+// ReportThreat is a threat of a Report
+type ReportThreat struct {
+	Type         string    `json:"type"`
+	Severity     string    `json:"severity"`
+	Confidence   float64   `json:"confidence"`
+	IP           string    `json:"ip"`
+	Timestamp    time.Time `json:"timestamp"`
+	Method       string    `json:"method,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	Status       int       `json:"status,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	AttackVector string    `json:"attack_vector,omitempty"`
+	Payload      string    `json:"payload,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Techniques   []string  `json:"mitre_techniques,omitempty"`
+	CVEs         []string  `json:"cves,omitempty"`
+
+	// Set in aggregated reports, where a threat stands for its group
+	Count     int        `json:"count,omitempty"`
+	URLs      int        `json:"urls,omitempty"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+}
+
+// ReportAnomaly is an anomaly of a Report
+type ReportAnomaly struct {
+	Type        string    `json:"type"`
+	Severity    string    `json:"severity"`
+	IP          string    `json:"ip,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+	Description string    `json:"description"`
+}
+
+// ReportIncident is an incident of a Report
+type ReportIncident struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Severity     string    `json:"severity"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	AttackVector string    `json:"attack_vector"`
+	ThreatActor  string    `json:"threat_actor"`
+	Impact       string    `json:"impact"`
+	IOCs         []string  `json:"iocs"`
+}
+
+// ReportRecommendation is a recommendation of a Report
+type ReportRecommendation struct {
+	Priority    int      `json:"priority"`
+	Category    string   `json:"category"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Actions     []string `json:"actions,omitempty"`
+}
+
+// NewReport builds the JSON form of the analysis
+func NewReport(analysis *EnhancedSecurityAnalysis, generatedAt time.Time) Report {
+	report := newReport(analysis, generatedAt)
+	for _, threat := range analysis.Threats {
+		report.Threats = append(report.Threats, newReportThreat(threat))
+	}
+	return report
+}
+
+// NewAggregatedReport builds the JSON form of the analysis with a threat per
+// group of near-identical threats, see AggregateThreats. The severity counts
+// still count every threat.
+func NewAggregatedReport(analysis *EnhancedSecurityAnalysis, generatedAt time.Time) Report {
+	report := newReport(analysis, generatedAt)
+	for _, group := range AggregateThreats(analysis.Threats) {
+		threat := newReportThreat(group.Threat)
+		firstSeen, lastSeen := group.FirstSeen, group.LastSeen
+		threat.Count = group.Count
+		threat.URLs = group.URLs
+		threat.FirstSeen = &firstSeen
+		threat.LastSeen = &lastSeen
+		report.Threats = append(report.Threats, threat)
+	}
+	return report
+}
+
+// newReportThreat builds the JSON form of a threat
+func newReportThreat(threat EnhancedThreat) ReportThreat {
+	var techniques []string
+	for _, technique := range threat.MITRETechniques() {
+		techniques = append(techniques, technique.ID)
+	}
+	description, _ := threat.Context["description"].(string)
+	return ReportThreat{
+		Type:         threatTypeName(threat),
+		Severity:     threat.Severity.String(),
+		Confidence:   threat.Confidence,
+		IP:           threat.IP,
+		Timestamp:    threat.Timestamp,
+		Method:       threat.Method,
+		URL:          threat.URL,
+		Status:       threat.StatusCode,
+		UserAgent:    threat.UserAgent,
+		AttackVector: threat.AttackVector,
+		Payload:      threat.Payload,
+		Description:  description,
+		Techniques:   techniques,
+		CVEs:         ThreatCVEs(threat),
+	}
+}
+
+// newReport builds the JSON form of the analysis but for its threats
+func newReport(analysis *EnhancedSecurityAnalysis, generatedAt time.Time) Report {
+	report := Report{
+		GeneratedAt:     generatedAt,
+		Start:           analysis.LogTimeRange.Start,
+		End:             analysis.LogTimeRange.End,
+		Entries:         analysis.TotalEntriesAnalyzed,
+		SecurityScore:   analysis.Summary.SecurityScore,
+		RiskLevel:       analysis.Summary.OverallRisk.String(),
+		ThreatLevel:     "None",
+		SeverityCounts:  make(map[string]int),
+		Threats:         []ReportThreat{},
+		Anomalies:       []ReportAnomaly{},
+		Incidents:       []ReportIncident{},
+		Recommendations: []ReportRecommendation{},
+	}
+	if level, ok := ThreatLevel(analysis.Threats); ok {
+		report.ThreatLevel = level.String()
+	}
+
+	for _, threat := range analysis.Threats {
+		report.SeverityCounts[strings.ToLower(threat.Severity.String())]++
+	}
+	for _, anomaly := range analysis.Anomalies {
+		report.Anomalies = append(report.Anomalies, ReportAnomaly{
+			Type:        anomaly.Type.String(),
+			Severity:    anomaly.Severity.String(),
+			IP:          anomaly.IP,
+			Timestamp:   anomaly.Timestamp,
+			Description: anomaly.Description,
+		})
+	}
+	for _, incident := range analysis.Incidents {
+		report.Incidents = append(report.Incidents, ReportIncident{
+			ID:           incident.ID,
+			Title:        incident.Title,
+			Severity:     incident.Severity.String(),
+			Start:        incident.StartTime,
+			End:          incident.EndTime,
+			AttackVector: incident.AttackVector,
+			ThreatActor:  incident.ThreatActor,
+			Impact:       incident.Impact,
+			IOCs:         incident.IOCs,
+		})
+	}
+	report.CMS = SummarizeCMSAttacks(analysis.Threats)
+	report.OWASP = SummarizeOWASP(analysis.Threats)
+	for _, recommendation := range analysis.Recommendations {
+		report.Recommendations = append(report.Recommendations, ReportRecommendation{
+			Priority:    recommendation.Priority,
+			Category:    recommendation.Category,
+			Title:       recommendation.Title,
+			Description: recommendation.Description,
+			Actions:     recommendation.Actions,
+		})
+	}
+	return report
+}
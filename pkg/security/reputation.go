@@ -0,0 +1,353 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// DefaultReputationStoreFile is the IP reputation store file name inside the
+// config directory
+const DefaultReputationStoreFile = "ip_reputation.json"
+
+// Reputations fade: a day's threats count half after ReputationHalfLife and
+// are dropped after ReputationRetention
+const (
+	ReputationHalfLife  = 14 * 24 * time.Hour
+	ReputationRetention = 90 * 24 * time.Hour
+)
+
+// reputationDayLayout keys the daily buckets of the store, in UTC
+const reputationDayLayout = "2006-01-02"
+
+// knownAttackerTag marks the profiles of IPs with threats in earlier runs
+const knownAttackerTag = "known-attacker"
+
+// reputationPoints are the reputation points of a threat per severity
+var reputationPoints = map[ThreatSeverity]float64{
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     5,
+	SeverityCritical: 10,
+}
+
+// reputationBehaviorPoints are the points of the highest behavior score
+// (0.0-1.0) of an IP
+const reputationBehaviorPoints = 40
+
+// ReputationStore keeps the threats and behavior of IPs per day across
+// analysis runs as a JSON file, so an IP that attacked before starts later
+// runs with elevated risk
+type ReputationStore struct {
+	UpdatedAt time.Time                `json:"updated_at"`
+	IPs       map[string]*IPReputation `json:"ips"`
+
+	filename string
+}
+
+// IPReputation is the stored record of an IP
+type IPReputation struct {
+	Days map[string]*ReputationDay `json:"days"` // UTC day -> activity
+	Tags []string                  `json:"tags,omitempty"`
+}
+
+// ReputationDay is an IP's activity on one day
+type ReputationDay struct {
+	Requests      int64          `json:"requests"`
+	Threats       int            `json:"threats"`
+	Points        float64        `json:"points"`         // Reputation points of the threats
+	BehaviorScore float64        `json:"behavior_score"` // Of the run that recorded the day
+	AttackTypes   map[string]int `json:"attack_types,omitempty"`
+}
+
+// Reputation sums up an IP's stored record as of a time
+type Reputation struct {
+	IP            string         `json:"ip"`
+	Score         float64        `json:"score"` // 0-100, faded to the time
+	Level         RiskLevel      `json:"-"`
+	RiskLevel     string         `json:"risk_level"`
+	FirstSeen     time.Time      `json:"first_seen"`
+	LastSeen      time.Time      `json:"last_seen"`
+	LastThreat    *time.Time     `json:"last_threat,omitempty"`
+	ActiveDays    int            `json:"active_days"`
+	Requests      int64          `json:"requests"`
+	Threats       int            `json:"threats"`
+	BehaviorScore float64        `json:"behavior_score"` // Highest recorded
+	AttackTypes   map[string]int `json:"attack_types,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+}
+
+// LoadReputationStore reads the reputation store. A missing file is an empty
+// store.
+func LoadReputationStore(filename string) (*ReputationStore, error) {
+	store := &ReputationStore{IPs: make(map[string]*IPReputation), filename: filename}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reputation store: %w", err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse reputation store %s: %w", filename, err)
+	}
+	if store.IPs == nil {
+		store.IPs = make(map[string]*IPReputation)
+	}
+	return store, nil
+}
+
+// Save writes the store back to its file
+func (s *ReputationStore) Save() error {
+	if dir := filepath.Dir(s.filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create reputation store directory: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.filename, data, 0644)
+}
+
+// Record stores the threats and IP profiles of an analysis of the logs, for
+// the IPs with a threat or at least low risk. A run's days replace the stored
+// ones, so analysing the same logs twice does not count them twice.
+func (s *ReputationStore) Record(logs []*parser.LogEntry, analysis *EnhancedSecurityAnalysis) {
+	covered, requests := dailyRequests(logs)
+	for ip, reputation := range s.IPs {
+		for day := range reputation.Days {
+			if covered[day] {
+				delete(reputation.Days, day)
+			}
+		}
+		if len(reputation.Days) == 0 {
+			delete(s.IPs, ip)
+		}
+	}
+
+	run := make(map[string]map[string]*ReputationDay)
+	dayOf := func(ip, day string) *ReputationDay {
+		if run[ip] == nil {
+			run[ip] = make(map[string]*ReputationDay)
+		}
+		if run[ip][day] == nil {
+			run[ip][day] = &ReputationDay{Requests: requests[ip][day]}
+		}
+		return run[ip][day]
+	}
+	for _, threat := range analysis.Threats {
+		day := dayOf(threat.IP, threat.Timestamp.UTC().Format(reputationDayLayout))
+		day.Threats++
+		day.Points += reputationPoints[threat.Severity]
+		if day.AttackTypes == nil {
+			day.AttackTypes = make(map[string]int)
+		}
+		day.AttackTypes[threatTypeName(threat)]++
+	}
+	for ip, profile := range analysis.IPProfiles {
+		if run[ip] == nil && profile.RiskLevel < RiskLow {
+			continue
+		}
+		for day := range requests[ip] {
+			dayOf(ip, day).BehaviorScore = profile.BehaviorScore
+		}
+	}
+
+	for ip, days := range run {
+		reputation, exists := s.IPs[ip]
+		if !exists {
+			reputation = &IPReputation{Days: make(map[string]*ReputationDay)}
+			s.IPs[ip] = reputation
+		}
+		for day, activity := range days {
+			reputation.Days[day] = activity
+		}
+		if profile, ok := analysis.IPProfiles[ip]; ok {
+			for _, tag := range profile.Tags {
+				if tag != knownAttackerTag && !containsValue(reputation.Tags, tag) {
+					reputation.Tags = append(reputation.Tags, tag)
+				}
+			}
+			sort.Strings(reputation.Tags)
+		}
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// Prune drops the days more than retention before the newest one stored and
+// returns how many IPs no longer have any
+func (s *ReputationStore) Prune(retention time.Duration) int {
+	newest := ""
+	for _, reputation := range s.IPs {
+		for day := range reputation.Days {
+			if day > newest {
+				newest = day
+			}
+		}
+	}
+	if newest == "" || retention <= 0 {
+		return 0
+	}
+	latest, _ := time.Parse(reputationDayLayout, newest)
+	cutoff := latest.Add(-retention).Format(reputationDayLayout)
+
+	dropped := 0
+	for ip, reputation := range s.IPs {
+		for day := range reputation.Days {
+			if day < cutoff {
+				delete(reputation.Days, day)
+			}
+		}
+		if len(reputation.Days) == 0 {
+			delete(s.IPs, ip)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Reputation returns the reputation of an IP as of a time; false when the
+// store has no record of it
+func (s *ReputationStore) Reputation(ip string, at time.Time) (Reputation, bool) {
+	return s.reputation(ip, at, nil)
+}
+
+// Reputations returns the reputation of every stored IP as of a time, worst
+// first
+func (s *ReputationStore) Reputations(at time.Time) []Reputation {
+	reputations := make([]Reputation, 0, len(s.IPs))
+	for ip := range s.IPs {
+		if reputation, ok := s.reputation(ip, at, nil); ok {
+			reputations = append(reputations, reputation)
+		}
+	}
+	sort.Slice(reputations, func(i, j int) bool {
+		if reputations[i].Score != reputations[j].Score {
+			return reputations[i].Score > reputations[j].Score
+		}
+		return reputations[i].IP < reputations[j].IP
+	})
+	return reputations
+}
+
+// reputation sums up the stored days of an IP but the skipped ones; false
+// when none are left
+func (s *ReputationStore) reputation(ip string, at time.Time, skip map[string]bool) (Reputation, bool) {
+	stored, ok := s.IPs[ip]
+	if !ok {
+		return Reputation{}, false
+	}
+
+	reputation := Reputation{IP: ip, AttackTypes: make(map[string]int), Tags: stored.Tags}
+	threatPoints, behavior := 0.0, 0.0
+	for key, day := range stored.Days {
+		if skip[key] {
+			continue
+		}
+		date, err := time.Parse(reputationDayLayout, key)
+		if err != nil {
+			continue
+		}
+		reputation.ActiveDays++
+		if reputation.FirstSeen.IsZero() || date.Before(reputation.FirstSeen) {
+			reputation.FirstSeen = date
+		}
+		if date.After(reputation.LastSeen) {
+			reputation.LastSeen = date
+		}
+		if day.Threats > 0 && (reputation.LastThreat == nil || date.After(*reputation.LastThreat)) {
+			lastThreat := date
+			reputation.LastThreat = &lastThreat
+		}
+		reputation.Requests += day.Requests
+		reputation.Threats += day.Threats
+		reputation.BehaviorScore = math.Max(reputation.BehaviorScore, day.BehaviorScore)
+		for attackType, count := range day.AttackTypes {
+			reputation.AttackTypes[attackType] += count
+		}
+
+		fade := reputationFade(at.Sub(date))
+		threatPoints += day.Points * fade
+		behavior = math.Max(behavior, day.BehaviorScore*fade)
+	}
+	if reputation.ActiveDays == 0 {
+		return Reputation{}, false
+	}
+
+	reputation.Score = math.Min(100, math.Round(threatPoints+behavior*reputationBehaviorPoints))
+	reputation.Level = reputationRiskLevel(reputation.Score)
+	reputation.RiskLevel = reputation.Level.String()
+	return reputation, true
+}
+
+// reputationFade halves the weight of a day every ReputationHalfLife
+func reputationFade(age time.Duration) float64 {
+	if age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, float64(age)/float64(ReputationHalfLife))
+}
+
+// reputationRiskLevel maps a reputation score to a risk level on the scale
+// of behavior scores
+func reputationRiskLevel(score float64) RiskLevel {
+	switch {
+	case score >= 80:
+		return RiskCritical
+	case score >= 60:
+		return RiskHigh
+	case score >= 40:
+		return RiskMedium
+	case score >= 20:
+		return RiskLow
+	default:
+		return RiskMinimal
+	}
+}
+
+// applyReputation raises the risk level of the profiled IPs to their
+// reputation from earlier runs, as of the start of the logs. The days the
+// logs cover are left out, so a run is not held against itself, and behavior
+// scores stay this run's own so recording them does not feed the reputation
+// back into itself.
+func applyReputation(profiles map[string]*IPBehaviorProfile, store *ReputationStore, logs []*parser.LogEntry) {
+	covered, _ := dailyRequests(logs)
+	start := entriesTimeRange(logs).Start
+	for ip, profile := range profiles {
+		reputation, ok := store.reputation(ip, start, covered)
+		if !ok || reputation.Score <= 0 {
+			continue
+		}
+		if reputation.Level > profile.RiskLevel {
+			profile.RiskLevel = reputation.Level
+		}
+		if reputation.Threats > 0 && !containsValue(profile.Tags, knownAttackerTag) {
+			profile.Tags = append(profile.Tags, knownAttackerTag)
+		}
+	}
+}
+
+// dailyRequests returns the UTC days the entries cover and the requests of
+// each IP per day
+func dailyRequests(logs []*parser.LogEntry) (map[string]bool, map[string]map[string]int64) {
+	covered := make(map[string]bool)
+	requests := make(map[string]map[string]int64)
+	for _, entry := range logs {
+		day := entry.Timestamp.UTC().Format(reputationDayLayout)
+		covered[day] = true
+		if requests[entry.IP] == nil {
+			requests[entry.IP] = make(map[string]int64)
+		}
+		requests[entry.IP][day]++
+	}
+	return covered, requests
+}
@@ -0,0 +1,399 @@
+package security
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"smart-log-analyser/pkg/mitre"
+	"smart-log-analyser/pkg/parser"
+)
+
+// CustomRulesFilename is the custom rules file looked for in the
+// configuration directory
+const CustomRulesFilename = "security-rules.yaml"
+
+// CustomAttackType is the attack type of a custom rule without a category:
+// the rule's name
+type CustomAttackType string
+
+// String returns the name of the custom rule
+func (cat CustomAttackType) String() string {
+	return string(cat)
+}
+
+// ruleCategories are the built-in attack types a custom rule can report as
+var ruleCategories = map[string]interface{}{
+	"sql_injection":          SQLInjection,
+	"xss":                    CrossSiteScripting,
+	"command_injection":      CommandInjection,
+	"directory_traversal":    DirectoryTraversal,
+	"remote_file_inclusion":  RemoteFileInclusion,
+	"local_file_inclusion":   LocalFileInclusion,
+	"xxe":                    XXEInjection,
+	"deserialization":        DeserializationAttack,
+	"header_injection":       HTTPHeaderInjection,
+	"authentication_bypass":  AuthenticationBypass,
+	"session_hijacking":      SessionHijacking,
+	"known_exploit":          KnownExploit,
+	"cms_probe":              CMSVulnerabilityProbe,
+	"brute_force":            BruteForceLogin,
+	"password_spray":         PasswordSpray,
+	"credential_stuffing":    CredentialStuffing,
+	"ddos":                   DDoSAttack,
+	"vulnerability_scanning": VulnerabilityScanning,
+	"web_shell":              WebShellAccess,
+	"data_exfiltration":      DataExfiltration,
+	"botnet":                 BotnetActivity,
+	"resource_exhaustion":    ResourceExhaustion,
+	"service_enumeration":    ServiceEnumeration,
+	"forced_browsing":        ForceBrowsing,
+	"xmlrpc_abuse":           XMLRPCAbuse,
+	"plugin_enumeration":     PluginEnumeration,
+}
+
+// ruleSeverities are the severities a custom rule can have
+var ruleSeverities = map[string]ThreatSeverity{
+	"info":     SeverityInfo,
+	"low":      SeverityLow,
+	"medium":   SeverityMedium,
+	"high":     SeverityHigh,
+	"critical": SeverityCritical,
+}
+
+// customRulesFile is the YAML layout of a custom rules file
+type customRulesFile struct {
+	Rules []CustomRuleSpec `yaml:"rules"`
+}
+
+// CustomRuleSpec is a detection rule as written in a custom rules file
+type CustomRuleSpec struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Category    string `yaml:"category,omitempty"` // Built-in attack type to report as; the rule name otherwise
+	Severity    string `yaml:"severity,omitempty"` // info, low, medium (default), high or critical
+	Match       struct {
+		URL       string   `yaml:"url,omitempty"`        // Regular expression on the request URL
+		UserAgent string   `yaml:"user_agent,omitempty"` // Regular expression on the user agent
+		Referer   string   `yaml:"referer,omitempty"`    // Regular expression on the referer
+		Methods   []string `yaml:"methods,omitempty"`
+		Status    []string `yaml:"status,omitempty"` // Codes such as 401, or classes such as 4xx
+	} `yaml:"match"`
+	Threshold *struct {
+		Count  int    `yaml:"count"`  // Matching requests from one IP
+		Window string `yaml:"window"` // Within this duration, e.g. 5m (default: the whole log)
+	} `yaml:"threshold,omitempty"`
+	MITRE      []string `yaml:"mitre,omitempty"`      // ATT&CK technique IDs
+	Mitigation []string `yaml:"mitigation,omitempty"` // Mitigation advice
+}
+
+// CustomRule is a compiled custom detection rule. Rules without a threshold
+// report every matching request; rules with one report an IP once it makes
+// Count matching requests within Window.
+type CustomRule struct {
+	Name        string
+	Description string
+	Type        interface{} // WebAttackType, InfrastructureAttackType or CustomAttackType
+	Severity    ThreatSeverity
+	URL         *regexp.Regexp
+	UserAgent   *regexp.Regexp
+	Referer     *regexp.Regexp
+	Methods     []string
+	Status      []string
+	Count       int           // 0 without a threshold
+	Window      time.Duration // 0 for the whole log
+	Techniques  []string
+	Mitigation  []string
+}
+
+// LoadCustomRules reads and compiles the rules of a custom rules file
+func LoadCustomRules(filename string) ([]CustomRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file customRulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+	}
+
+	var rules []CustomRule
+	names := make(map[string]bool)
+	for i, spec := range file.Rules {
+		rule, err := compileRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", filename, i+1, err)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("%s: rule %d: duplicate rule name %q", filename, i+1, rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compileRule validates a rule and compiles its patterns
+func compileRule(spec CustomRuleSpec) (CustomRule, error) {
+	rule := CustomRule{
+		Name:        strings.TrimSpace(spec.Name),
+		Description: spec.Description,
+		Severity:    SeverityMedium,
+		Techniques:  spec.MITRE,
+		Mitigation:  spec.Mitigation,
+	}
+	if rule.Name == "" {
+		return rule, fmt.Errorf("name is required")
+	}
+
+	rule.Type = CustomAttackType(rule.Name)
+	if spec.Category != "" {
+		attackType, ok := ruleCategories[spec.Category]
+		if !ok {
+			return rule, fmt.Errorf("unknown category %q (use %s)", spec.Category, strings.Join(sortedKeys(ruleCategories), ", "))
+		}
+		rule.Type = attackType
+	}
+	if spec.Severity != "" {
+		severity, ok := ruleSeverities[strings.ToLower(spec.Severity)]
+		if !ok {
+			return rule, fmt.Errorf("unknown severity %q (use info, low, medium, high or critical)", spec.Severity)
+		}
+		rule.Severity = severity
+	}
+
+	var err error
+	if rule.URL, err = compileRulePattern("url", spec.Match.URL); err != nil {
+		return rule, err
+	}
+	if rule.UserAgent, err = compileRulePattern("user_agent", spec.Match.UserAgent); err != nil {
+		return rule, err
+	}
+	if rule.Referer, err = compileRulePattern("referer", spec.Match.Referer); err != nil {
+		return rule, err
+	}
+	for _, method := range spec.Match.Methods {
+		rule.Methods = append(rule.Methods, strings.ToUpper(method))
+	}
+	for _, status := range spec.Match.Status {
+		status = strings.ToLower(strings.TrimSpace(status))
+		if !validStatusCondition(status) {
+			return rule, fmt.Errorf("invalid status %q (use a code such as 401 or a class such as 4xx)", status)
+		}
+		rule.Status = append(rule.Status, status)
+	}
+	if rule.URL == nil && rule.UserAgent == nil && rule.Referer == nil && len(rule.Methods) == 0 && len(rule.Status) == 0 {
+		return rule, fmt.Errorf("match needs at least one of url, user_agent, referer, methods or status")
+	}
+
+	if spec.Threshold != nil {
+		if spec.Threshold.Count < 1 {
+			return rule, fmt.Errorf("threshold count must be at least 1")
+		}
+		rule.Count = spec.Threshold.Count
+		if spec.Threshold.Window != "" {
+			if rule.Window, err = time.ParseDuration(spec.Threshold.Window); err != nil || rule.Window <= 0 {
+				return rule, fmt.Errorf("invalid threshold window %q (use e.g. 30s, 5m or 1h)", spec.Threshold.Window)
+			}
+		}
+	}
+	for _, id := range rule.Techniques {
+		if _, ok := mitre.Lookup(id); !ok {
+			return rule, fmt.Errorf("unknown MITRE ATT&CK technique %q", id)
+		}
+	}
+	return rule, nil
+}
+
+func compileRulePattern(field, pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s pattern: %w", field, err)
+	}
+	return compiled, nil
+}
+
+// validStatusCondition reports whether status is a code or a class like 4xx
+func validStatusCondition(status string) bool {
+	if len(status) == 3 && status[0] >= '1' && status[0] <= '5' && status[1:] == "xx" {
+		return true
+	}
+	code, err := strconv.Atoi(status)
+	return err == nil && code >= 100 && code <= 599
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matches reports whether an entry meets all conditions of the rule, and
+// returns the text its first pattern matched as the payload
+func (rule CustomRule) matches(entry *parser.LogEntry) (bool, string) {
+	payload := ""
+	for _, condition := range []struct {
+		pattern *regexp.Regexp
+		value   string
+	}{{rule.URL, entry.URL}, {rule.UserAgent, entry.UserAgent}, {rule.Referer, entry.Referer}} {
+		if condition.pattern == nil {
+			continue
+		}
+		loc := condition.pattern.FindStringIndex(condition.value)
+		if loc == nil {
+			return false, ""
+		}
+		if payload == "" {
+			payload = condition.value[loc[0]:loc[1]]
+		}
+	}
+	if len(rule.Methods) > 0 && !containsValue(rule.Methods, strings.ToUpper(entry.Method)) {
+		return false, ""
+	}
+	if len(rule.Status) > 0 && !rule.matchesStatus(entry.Status) {
+		return false, ""
+	}
+	return true, payload
+}
+
+func (rule CustomRule) matchesStatus(status int) bool {
+	code := strconv.Itoa(status)
+	for _, condition := range rule.Status {
+		if condition == code || (strings.HasSuffix(condition, "xx") && strings.HasPrefix(code, condition[:1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// pattern describes the conditions of the rule
+func (rule CustomRule) pattern() string {
+	var conditions []string
+	if rule.URL != nil {
+		conditions = append(conditions, "url~"+rule.URL.String())
+	}
+	if rule.UserAgent != nil {
+		conditions = append(conditions, "user_agent~"+rule.UserAgent.String())
+	}
+	if rule.Referer != nil {
+		conditions = append(conditions, "referer~"+rule.Referer.String())
+	}
+	if len(rule.Methods) > 0 {
+		conditions = append(conditions, "method in "+strings.Join(rule.Methods, ","))
+	}
+	if len(rule.Status) > 0 {
+		conditions = append(conditions, "status in "+strings.Join(rule.Status, ","))
+	}
+	return strings.Join(conditions, " and ")
+}
+
+// threat builds the threat a rule reports for an entry
+func (rule CustomRule) threat(entry *parser.LogEntry, payload string, context map[string]interface{}) EnhancedThreat {
+	context["rule"] = rule.Name
+	if rule.Description != "" {
+		context["description"] = rule.Description
+	}
+	if len(rule.Techniques) > 0 {
+		context["mitre_techniques"] = rule.Techniques
+	}
+	return EnhancedThreat{
+		ID:               fmt.Sprintf("custom_%d_%s", time.Now().UnixNano(), entry.IP),
+		Type:             rule.Type,
+		Severity:         rule.Severity,
+		Confidence:       0.8,
+		Pattern:          rule.pattern(),
+		URL:              entry.URL,
+		IP:               entry.IP,
+		UserAgent:        entry.UserAgent,
+		Timestamp:        entry.Timestamp,
+		Method:           entry.Method,
+		StatusCode:       entry.Status,
+		ResponseSize:     entry.Size,
+		AttackVector:     "Custom rule: " + rule.Name,
+		Payload:          payload,
+		Context:          context,
+		MitigationAdvice: rule.Mitigation,
+	}
+}
+
+// detectCustomRules reports the entry for each rule without a threshold that
+// it matches
+func (td *ThreatDetector) detectCustomRules(entry *parser.LogEntry) []EnhancedThreat {
+	var threats []EnhancedThreat
+	for _, rule := range td.config.CustomRules {
+		if rule.Count > 0 {
+			continue
+		}
+		if ok, payload := rule.matches(entry); ok {
+			threats = append(threats, rule.threat(entry, payload, map[string]interface{}{}))
+		}
+	}
+	return threats
+}
+
+// detectCustomThresholds reports an IP once for each rule with a threshold
+// when it makes the threshold's count of matching requests within its window
+func (td *ThreatDetector) detectCustomThresholds(ip string, entries []*parser.LogEntry) []EnhancedThreat {
+	type match struct {
+		entry   *parser.LogEntry
+		payload string
+	}
+
+	var threats []EnhancedThreat
+	for _, rule := range td.config.CustomRules {
+		if rule.Count == 0 {
+			continue
+		}
+
+		var matches []match
+		for _, entry := range entries {
+			if ok, payload := rule.matches(entry); ok {
+				matches = append(matches, match{entry, payload})
+			}
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].entry.Timestamp.Before(matches[j].entry.Timestamp)
+		})
+
+		// Slide a window over the matches until one holds enough of them
+		start := 0
+		for end := range matches {
+			for rule.Window > 0 && matches[end].entry.Timestamp.Sub(matches[start].entry.Timestamp) > rule.Window {
+				start++
+			}
+			if end-start+1 >= rule.Count {
+				context := map[string]interface{}{
+					"matching_requests": len(matches),
+					"threshold":         rule.Count,
+				}
+				if rule.Window > 0 {
+					context["window"] = rule.Window.String()
+				}
+				threats = append(threats, rule.threat(matches[end].entry, matches[end].payload, context))
+				break
+			}
+		}
+	}
+	return threats
+}
@@ -16,7 +16,7 @@ type SecurityScorer struct {
 // NewSecurityScorer creates a new security scorer
 func NewSecurityScorer(config SecurityConfig) *SecurityScorer {
 	return &SecurityScorer{
-		config: config,
+		config: withScoring(config),
 	}
 }
 
@@ -24,13 +24,8 @@ func NewSecurityScorer(config SecurityConfig) *SecurityScorer {
 func (ss *SecurityScorer) CalculateSecurityScore(analysis *EnhancedSecurityAnalysis) int {
 	dimensions := ss.CalculateSecurityDimensions(analysis)
 	
-	// Weighted scoring system
-	weights := SecurityDimensionWeights{
-		ThreatDetection:  0.40, // 40% - Direct threats are most important
-		AnomalyDetection: 0.25, // 25% - Behavioral anomalies
-		TrafficIntegrity: 0.20, // 20% - Overall traffic health
-		AccessControl:    0.15, // 15% - Authentication/authorization issues
-	}
+	// Weighted scoring system, see ScoringConfig
+	weights := ss.config.Scoring.DimensionWeights
 	
 	weightedScore := (dimensions.ThreatDetection * weights.ThreatDetection) +
 		(dimensions.AnomalyDetection * weights.AnomalyDetection) +
@@ -73,13 +68,7 @@ func (ss *SecurityScorer) calculateThreatDetectionScore(analysis *EnhancedSecuri
 
 	// Calculate threat impact based on severity and frequency
 	threatImpact := 0.0
-	severityWeights := map[ThreatSeverity]float64{
-		SeverityInfo:     1.0,
-		SeverityLow:      2.0,
-		SeverityMedium:   5.0,
-		SeverityHigh:     10.0,
-		SeverityCritical: 20.0,
-	}
+	severityWeights := ss.config.Scoring.SeverityWeights
 
 	for _, threat := range analysis.Threats {
 		if weight, exists := severityWeights[threat.Severity]; exists {
@@ -112,13 +101,7 @@ func (ss *SecurityScorer) calculateAnomalyDetectionScore(analysis *EnhancedSecur
 
 	// Calculate anomaly impact
 	anomalyImpact := 0.0
-	severityWeights := map[ThreatSeverity]float64{
-		SeverityInfo:     0.5,
-		SeverityLow:      1.0,
-		SeverityMedium:   2.5,
-		SeverityHigh:     5.0,
-		SeverityCritical: 10.0,
-	}
+	severityWeights := ss.config.Scoring.AnomalySeverityWeights
 
 	for _, anomaly := range analysis.Anomalies {
 		if weight, exists := severityWeights[anomaly.Severity]; exists {
@@ -229,6 +212,12 @@ func (ss *SecurityScorer) calculateAccessControlScore(analysis *EnhancedSecurity
 		case PasswordSpray:
 			penalties += 12.0
 			authThreats++
+		case CredentialStuffing:
+			penalties += 12.0
+			authThreats++
+		case XMLRPCAbuse:
+			penalties += 12.0
+			authThreats++
 		case AuthenticationBypass:
 			penalties += 20.0
 			authThreats++
@@ -263,16 +252,7 @@ func (ss *SecurityScorer) calculateAccessControlScore(analysis *EnhancedSecurity
 
 // CalculateRiskLevel determines overall risk level based on security score
 func (ss *SecurityScorer) CalculateRiskLevel(securityScore int) RiskLevel {
-	if securityScore >= 90 {
-		return RiskMinimal
-	} else if securityScore >= 70 {
-		return RiskLow
-	} else if securityScore >= 50 {
-		return RiskMedium
-	} else if securityScore >= 30 {
-		return RiskHigh
-	}
-	return RiskCritical
+	return ss.config.Scoring.RiskBoundaries.RiskLevel(securityScore)
 }
 
 // GenerateSecuritySummary creates a comprehensive security summary
@@ -336,6 +316,10 @@ func (ss *SecurityScorer) identifyTopAttackTypes(threats []EnhancedThreat) []str
 			attackType = t.String()
 		case InfrastructureAttackType:
 			attackType = t.String()
+		case CustomAttackType:
+			attackType = t.String()
+		case ExfiltrationType:
+			attackType = t.String()
 		default:
 			attackType = "Unknown"
 		}
@@ -383,6 +367,10 @@ func (ss *SecurityScorer) calculateThreatTrends(analysis *EnhancedSecurityAnalys
 			attackType = t.String()
 		case InfrastructureAttackType:
 			attackType = t.String()
+		case CustomAttackType:
+			attackType = t.String()
+		case ExfiltrationType:
+			attackType = t.String()
 		default:
 			attackType = "Unknown"
 		}
@@ -453,7 +441,7 @@ func (ss *SecurityScorer) generateSecurityRecommendations(analysis *EnhancedSecu
 	var recommendations []SecurityRecommendation
 
 	// Critical score recommendations
-	if securityScore < 30 {
+	if ss.CalculateRiskLevel(securityScore) == RiskCritical {
 		recommendations = append(recommendations, SecurityRecommendation{
 			Priority:    1,
 			Category:    "Critical Security Alert",
@@ -566,7 +554,7 @@ func (ss *SecurityScorer) generateSecurityRecommendations(analysis *EnhancedSecu
 		authThreats := 0
 		for _, threat := range analysis.Threats {
 			switch threat.Type {
-			case BruteForceLogin, PasswordSpray, AuthenticationBypass:
+			case BruteForceLogin, PasswordSpray, CredentialStuffing, XMLRPCAbuse, AuthenticationBypass:
 				authThreats++
 			}
 		}
@@ -701,13 +689,35 @@ func (ss *SecurityScorer) groupThreatsIntoIncidents(threats []EnhancedThreat) []
 		return sortedThreats[i].Timestamp.Before(sortedThreats[j].Timestamp)
 	})
 
+	// The threats of the IPs in a botnet form one incident
+	ipBotnets := make(map[string]string)
+	for _, threat := range sortedThreats {
+		if id, members, ok := threatBotnet(threat); ok {
+			for _, ip := range members {
+				ipBotnets[ip] = id
+			}
+		}
+	}
+
 	// Group threats by IP and time proximity
 	ipGroups := make(map[string][]EnhancedThreat)
+	var botnetIDs []string
 	for _, threat := range sortedThreats {
-		ipGroups[threat.IP] = append(ipGroups[threat.IP], threat)
+		key := threat.IP
+		if id, ok := ipBotnets[threat.IP]; ok {
+			key = id
+			if ipGroups[key] == nil {
+				botnetIDs = append(botnetIDs, id)
+			}
+		}
+		ipGroups[key] = append(ipGroups[key], threat)
 	}
 
 	// Create incident groups
+	for _, id := range botnetIDs {
+		groups = append(groups, ipGroups[id])
+		delete(ipGroups, id)
+	}
 	for _, ipThreats := range ipGroups {
 		if len(ipThreats) >= 3 { // Minimum 3 threats for an incident
 			groups = append(groups, ipThreats)
@@ -732,6 +742,10 @@ func (ss *SecurityScorer) generateIncidentTitle(threats []EnhancedThreat) string
 			attackType = t.String()
 		case InfrastructureAttackType:
 			attackType = t.String()
+		case CustomAttackType:
+			attackType = t.String()
+		case ExfiltrationType:
+			attackType = t.String()
 		default:
 			attackType = "Unknown Attack"
 		}
@@ -748,6 +762,13 @@ func (ss *SecurityScorer) generateIncidentTitle(threats []EnhancedThreat) string
 		}
 	}
 
+	if _, members := incidentBotnet(threats); len(members) > 0 {
+		if len(attackTypes) == 1 {
+			return fmt.Sprintf("Coordinated Botnet of %d IPs", len(members))
+		}
+		return fmt.Sprintf("Coordinated Botnet of %d IPs (%s and %d others)", len(members), primaryAttack, len(attackTypes)-1)
+	}
+
 	if len(attackTypes) == 1 {
 		return fmt.Sprintf("%s from %s", primaryAttack, threats[0].IP)
 	} else {
@@ -777,6 +798,10 @@ func (ss *SecurityScorer) identifyThreatActor(threats []EnhancedThreat) string {
 		return "Unknown"
 	}
 
+	if id, members := incidentBotnet(threats); len(members) > 0 {
+		return fmt.Sprintf("Botnet %s (%d IPs)", id, len(members))
+	}
+
 	// Simple heuristic based on IP and attack patterns
 	ip := threats[0].IP
 	
@@ -848,6 +873,10 @@ func (ss *SecurityScorer) createIncidentTimeline(threats []EnhancedThreat) []Inc
 			attackType = t.String()
 		case InfrastructureAttackType:
 			attackType = t.String()
+		case CustomAttackType:
+			attackType = t.String()
+		case ExfiltrationType:
+			attackType = t.String()
 		default:
 			attackType = "Unknown Attack"
 		}
@@ -863,6 +892,8 @@ func (ss *SecurityScorer) createIncidentTimeline(threats []EnhancedThreat) []Inc
 				"confidence":    threat.Confidence,
 				"attack_vector": threat.AttackVector,
 				"payload":       threat.Payload,
+				"method":        threat.Method,
+				"url":           threat.URL,
 			},
 		}
 
@@ -912,19 +943,35 @@ func (ss *SecurityScorer) generateIncidentRecommendations(threats []EnhancedThre
 	}
 
 	// Immediate blocking recommendation
-	recommendations = append(recommendations, SecurityRecommendation{
-		Priority:    1,
-		Category:    "Immediate Response",
-		Title:       "Block Malicious IP",
-		Description: fmt.Sprintf("Immediately block IP %s to prevent further attacks", threats[0].IP),
-		Impact:      SeverityHigh,
-		Effort:      "Low",
-		Actions: []string{
-			fmt.Sprintf("Add firewall rule to block %s", threats[0].IP),
-			"Monitor for additional attacks from related IPs",
-			"Review logs for any successful attacks",
-		},
-	})
+	if id, members := incidentBotnet(threats); len(members) > 0 {
+		recommendations = append(recommendations, SecurityRecommendation{
+			Priority:    1,
+			Category:    "Immediate Response",
+			Title:       "Block Botnet IPs",
+			Description: fmt.Sprintf("Immediately block the %d IPs of %s together; blocking some leaves the rest attacking", len(members), id),
+			Impact:      SeverityHigh,
+			Effort:      "Low",
+			Actions: []string{
+				fmt.Sprintf("Add firewall rules to block %s", strings.Join(members, ", ")),
+				"Monitor for new IPs with the same user agent and request sequence",
+				"Review logs for any successful attacks",
+			},
+		})
+	} else {
+		recommendations = append(recommendations, SecurityRecommendation{
+			Priority:    1,
+			Category:    "Immediate Response",
+			Title:       "Block Malicious IP",
+			Description: fmt.Sprintf("Immediately block IP %s to prevent further attacks", threats[0].IP),
+			Impact:      SeverityHigh,
+			Effort:      "Low",
+			Actions: []string{
+				fmt.Sprintf("Add firewall rule to block %s", threats[0].IP),
+				"Monitor for additional attacks from related IPs",
+				"Review logs for any successful attacks",
+			},
+		})
+	}
 
 	// Attack-specific recommendations
 	attackTypes := make(map[interface{}]bool)
@@ -942,6 +989,8 @@ func (ss *SecurityScorer) generateIncidentRecommendations(threats []EnhancedThre
 					actions = []string{"Implement output encoding", "Review CSP headers", "Update XSS protections"}
 				case CommandInjection:
 					actions = []string{"Review command execution code", "Implement input validation", "Apply principle of least privilege"}
+				case KnownExploit, CMSVulnerabilityProbe:
+					actions = threat.MitigationAdvice
 				default:
 					actions = []string{"Review application security controls", "Update security signatures"}
 				}
@@ -949,11 +998,22 @@ func (ss *SecurityScorer) generateIncidentRecommendations(threats []EnhancedThre
 				switch t {
 				case BruteForceLogin:
 					actions = []string{"Implement account lockout", "Enable MFA", "Review authentication logs"}
+				case CredentialStuffing:
+					actions = []string{"Enable MFA", "Reject breached passwords", "Challenge logins from unfamiliar networks"}
+				case XMLRPCAbuse, PluginEnumeration:
+					actions = threat.MitigationAdvice
 				case DDoSAttack:
 					actions = []string{"Activate DDoS protection", "Scale infrastructure", "Monitor traffic patterns"}
 				default:
 					actions = []string{"Review infrastructure security", "Update monitoring rules"}
 				}
+			case CustomAttackType:
+				actions = threat.MitigationAdvice
+				if len(actions) == 0 {
+					actions = []string{"Review the requests matched by the custom rule", "Update monitoring rules"}
+				}
+			case ExfiltrationType:
+				actions = threat.MitigationAdvice
 			}
 
 			if len(actions) > 0 {
@@ -0,0 +1,339 @@
+package security
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"smart-log-analyser/pkg/mitre"
+)
+
+// DefaultSigmaMinHits is how often a payload must be seen before it goes
+// into a Sigma rule
+const DefaultSigmaMinHits = 2
+
+// sigmaMaxValues is the number of payloads kept per request field of a rule,
+// the most frequent first
+const sigmaMaxValues = 20
+
+// sigmaNamespace seeds the name based UUIDs of generated rules, so a rule
+// keeps its ID when it is generated again
+var sigmaNamespace = []byte("smart-log-analyser sigma rule")
+
+// Webserver fields of the Sigma taxonomy a payload can be matched in, in
+// rule order
+var sigmaFields = []string{"cs-uri-stem", "cs-uri-query", "cs-user-agent", "cs-referer"}
+
+// SigmaRule is a Sigma detection rule for the webserver log source
+type SigmaRule struct {
+	Title          string         `yaml:"title"`
+	ID             string         `yaml:"id"`
+	Status         string         `yaml:"status"`
+	Description    string         `yaml:"description"`
+	Author         string         `yaml:"author"`
+	Date           string         `yaml:"date"`
+	Tags           []string       `yaml:"tags,omitempty"`
+	LogSource      SigmaLogSource `yaml:"logsource"`
+	Detection      yaml.Node      `yaml:"detection"`
+	Fields         []string       `yaml:"fields,omitempty"`
+	FalsePositives []string       `yaml:"falsepositives"`
+	Level          string         `yaml:"level"`
+
+	attackType string // Attack type the rule detects, for its file name
+}
+
+// SigmaLogSource is the log source a Sigma rule applies to
+type SigmaLogSource struct {
+	Category string `yaml:"category"`
+}
+
+// sigmaSignature collects the payloads of one attack type seen in each
+// request field
+type sigmaSignature struct {
+	name       string
+	techniques []mitre.Technique
+	severity   ThreatSeverity
+	hits       map[string]map[string]int // field -> payload -> hits
+	casing     map[string]string         // lowercased payload -> first spelling seen
+	ips        map[string]bool
+	first      time.Time
+	last       time.Time
+}
+
+// GenerateSigmaRules turns the payloads seen at least minHits times into a
+// Sigma rule per attack type, matching them in the request field they were
+// seen in
+func GenerateSigmaRules(threats []EnhancedThreat, minHits int, generatedAt time.Time) []SigmaRule {
+	signatures := make(map[string]*sigmaSignature)
+	var order []string
+	for _, threat := range threats {
+		field := sigmaField(threat)
+		if field == "" {
+			continue
+		}
+		name := threatTypeName(threat)
+		signature, exists := signatures[name]
+		if !exists {
+			signature = &sigmaSignature{
+				name:       name,
+				techniques: threat.MITRETechniques(),
+				hits:       make(map[string]map[string]int),
+				casing:     make(map[string]string),
+				ips:        make(map[string]bool),
+				first:      threat.Timestamp,
+				last:       threat.Timestamp,
+			}
+			signatures[name] = signature
+			order = append(order, name)
+		}
+
+		payload := strings.ToLower(threat.Payload)
+		if _, seen := signature.casing[payload]; !seen {
+			signature.casing[payload] = threat.Payload
+		}
+		if signature.hits[field] == nil {
+			signature.hits[field] = make(map[string]int)
+		}
+		signature.hits[field][payload]++
+		signature.ips[threat.IP] = true
+		if threat.Severity > signature.severity {
+			signature.severity = threat.Severity
+		}
+		if threat.Timestamp.Before(signature.first) {
+			signature.first = threat.Timestamp
+		}
+		if threat.Timestamp.After(signature.last) {
+			signature.last = threat.Timestamp
+		}
+	}
+
+	var rules []SigmaRule
+	for _, name := range order {
+		if rule, ok := signatures[name].rule(minHits, generatedAt); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// sigmaField returns the webserver field the payload of a threat is in, or
+// "" when the threat has no payload found in the request, or one too weak
+// to match on, like the WAF rules
+func sigmaField(threat EnhancedThreat) string {
+	if !meaningfulPayload(threat.Payload) {
+		return ""
+	}
+	if strings.Contains(threat.URL, threat.Payload) {
+		path, query := threat.URL, ""
+		if i := strings.Index(threat.URL, "?"); i >= 0 {
+			path, query = threat.URL[:i], threat.URL[i+1:]
+		}
+		if strings.Contains(query, threat.Payload) && !strings.Contains(path, threat.Payload) {
+			return "cs-uri-query"
+		}
+		return "cs-uri-stem"
+	}
+	if threat.UserAgent != "" && strings.Contains(threat.UserAgent, threat.Payload) {
+		return "cs-user-agent"
+	}
+	if _, ok := threat.Type.(WebAttackType); ok {
+		// The web attack detectors also match the referer, which threats do
+		// not keep
+		return "cs-referer"
+	}
+	return ""
+}
+
+// threatTypeName returns the name of the attack type of a threat
+func threatTypeName(threat EnhancedThreat) string {
+	if name, ok := threat.Type.(fmt.Stringer); ok {
+		return name.String()
+	}
+	return "Unknown Threat"
+}
+
+// rule builds the Sigma rule of the signature from its recurring payloads;
+// false when none recurred
+func (s *sigmaSignature) rule(minHits int, generatedAt time.Time) (SigmaRule, bool) {
+	detection := yaml.Node{Kind: yaml.MappingNode}
+	var selections []string
+	var matched []string
+	for _, field := range sigmaFields {
+		values := recurringPayloads(s.hits[field], minHits)
+		if len(values) == 0 {
+			continue
+		}
+		key := "selection_" + strings.TrimPrefix(strings.ReplaceAll(field, "-", "_"), "cs_")
+		list := yaml.Node{Kind: yaml.SequenceNode}
+		for _, value := range values {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: s.casing[value]})
+		}
+		selection := yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: field + "|contains"}, &list,
+		}}
+		detection.Content = append(detection.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &selection)
+		selections = append(selections, key)
+		matched = append(matched, field)
+	}
+	if len(selections) == 0 {
+		return SigmaRule{}, false
+	}
+	condition := selections[0]
+	if len(selections) > 1 {
+		condition = "1 of selection_*"
+	}
+	detection.Content = append(detection.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: "condition"}, &yaml.Node{Kind: yaml.ScalarNode, Value: condition})
+
+	title := s.name + " Payloads Observed in Web Server Logs"
+	return SigmaRule{
+		Title:  title,
+		ID:     nameUUID(sigmaNamespace, title),
+		Status: "experimental",
+		Description: fmt.Sprintf("Detects %s payloads that recurred in the analysed access logs: seen from %d source IP(s) between %s and %s. Generated by smart-log-analyser; review before use.",
+			s.name, len(s.ips), s.first.Format("2006-01-02 15:04"), s.last.Format("2006-01-02 15:04")),
+		Author:         "smart-log-analyser",
+		Date:           generatedAt.Format("2006-01-02"),
+		Tags:           sigmaTags(s.techniques),
+		LogSource:      SigmaLogSource{Category: "webserver"},
+		Detection:      detection,
+		Fields:         append([]string{"c-ip", "cs-method", "sc-status"}, matched...),
+		FalsePositives: []string{"Security scanners run by the site owner", "Legitimate requests that contain the same strings"},
+		Level:          sigmaLevel(s.severity),
+		attackType:     s.name,
+	}, true
+}
+
+// recurringPayloads returns the payloads with at least minHits hits, the
+// most frequent first
+func recurringPayloads(hits map[string]int, minHits int) []string {
+	var payloads []string
+	for payload, count := range hits {
+		if count >= minHits {
+			payloads = append(payloads, payload)
+		}
+	}
+	sort.Slice(payloads, func(i, j int) bool {
+		if hits[payloads[i]] != hits[payloads[j]] {
+			return hits[payloads[i]] > hits[payloads[j]]
+		}
+		return payloads[i] < payloads[j]
+	})
+	if len(payloads) > sigmaMaxValues {
+		payloads = payloads[:sigmaMaxValues]
+	}
+	return payloads
+}
+
+// sigmaTags returns the ATT&CK tags of the techniques: the tactics, then the
+// techniques
+func sigmaTags(techniques []mitre.Technique) []string {
+	var tactics, ids []string
+	seen := make(map[string]bool)
+	for _, technique := range techniques {
+		tactic := "attack." + strings.ReplaceAll(strings.ToLower(technique.Tactic), " ", "-")
+		if !seen[tactic] {
+			seen[tactic] = true
+			tactics = append(tactics, tactic)
+		}
+		ids = append(ids, "attack."+strings.ToLower(technique.ID))
+	}
+	return append(tactics, ids...)
+}
+
+// sigmaLevel maps a threat severity to a Sigma level
+func sigmaLevel(severity ThreatSeverity) string {
+	switch severity {
+	case SeverityCritical:
+		return "critical"
+	case SeverityHigh:
+		return "high"
+	case SeverityMedium:
+		return "medium"
+	case SeverityLow:
+		return "low"
+	default:
+		return "informational"
+	}
+}
+
+// nameUUID returns a version 5 style UUID of the name in the namespace
+func nameUUID(namespace []byte, name string) string {
+	sum := sha1.Sum(append(append([]byte{}, namespace...), name...))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// MarshalSigmaRules encodes the rules as a multi-document YAML stream
+func MarshalSigmaRules(rules []SigmaRule) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(4)
+	for _, rule := range rules {
+		if err := encoder.Encode(rule); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// WriteSigmaRules writes the rules to path: a single YAML stream when path
+// ends in .yml or .yaml, otherwise a directory with a file per rule
+func WriteSigmaRules(path string, rules []SigmaRule) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		data, err := MarshalSigmaRules(rules)
+		if err != nil {
+			return err
+		}
+		if dir := filepath.Dir(path); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+		}
+		return os.WriteFile(path, data, 0644)
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		data, err := MarshalSigmaRules([]SigmaRule{rule})
+		if err != nil {
+			return err
+		}
+		filename := "web_" + sigmaSlug(rule.attackType) + ".yml"
+		if err := os.WriteFile(filepath.Join(path, filename), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sigmaSlug turns an attack type into a rule file name part, e.g.
+// "cross_site_scripting_xss"
+func sigmaSlug(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteRune('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
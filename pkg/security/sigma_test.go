@@ -0,0 +1,33 @@
+package security
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrowserTrafficBuildsNoSigmaRules(t *testing.T) {
+	entries := requests([]string{"/index.html", "/search?a=1&b=2", "/search?q=a%3Bb%26c%3Dd"}, chromeUserAgent, firefoxUserAgent, androidUserAgent, iPhoneUserAgent)
+
+	analysis, err := Analyse(entries, DefaultSecurityConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules := GenerateSigmaRules(analysis.Threats, 1, time.Now()); len(rules) != 0 {
+		t.Errorf("GenerateSigmaRules built %d rule(s) from browser traffic, first %q", len(rules), rules[0].Title)
+	}
+}
+
+func TestSigmaRulesSkipPunctuationPayloads(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	var threats []EnhancedThreat
+	for i := 0; i < 3; i++ {
+		threats = append(threats,
+			EnhancedThreat{Type: CommandInjection, Severity: SeverityMedium, URL: "/index.html", UserAgent: chromeUserAgent, Payload: ";", IP: "192.0.2.10", Timestamp: at},
+			EnhancedThreat{Type: CommandInjection, Severity: SeverityMedium, URL: "/search?a=1&b=2", UserAgent: firefoxUserAgent, Payload: "&", IP: "192.0.2.11", Timestamp: at},
+		)
+	}
+
+	if rules := GenerateSigmaRules(threats, 1, at); len(rules) != 0 {
+		t.Errorf("GenerateSigmaRules built %d rule(s) from punctuation payloads, first %q", len(rules), rules[0].Title)
+	}
+}
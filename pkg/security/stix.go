@@ -0,0 +1,189 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stixNamespace seeds the IDs of STIX objects, so an indicator keeps its ID
+// across exports and platforms can merge them
+var stixNamespace = []byte("smart-log-analyser stix")
+
+// stixTimeLayout is the STIX timestamp format, in UTC with milliseconds
+const stixTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// IOC prefixes of incident data
+const (
+	iocIP        = "IP: "
+	iocUserAgent = "User-Agent: "
+	iocPayload   = "Payload Pattern: "
+)
+
+// STIXBundle is a STIX 2.1 bundle
+type STIXBundle struct {
+	Type    string       `json:"type"`
+	ID      string       `json:"id"`
+	Objects []STIXObject `json:"objects"`
+}
+
+// STIXObject is a STIX 2.1 domain object: the identity of the tool, an
+// indicator or a grouping of the indicators of an incident
+type STIXObject struct {
+	Type           string   `json:"type"`
+	SpecVersion    string   `json:"spec_version"`
+	ID             string   `json:"id"`
+	Created        string   `json:"created"`
+	Modified       string   `json:"modified"`
+	CreatedByRef   string   `json:"created_by_ref,omitempty"`
+	Name           string   `json:"name,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	IdentityClass  string   `json:"identity_class,omitempty"`
+	IndicatorTypes []string `json:"indicator_types,omitempty"`
+	Pattern        string   `json:"pattern,omitempty"`
+	PatternType    string   `json:"pattern_type,omitempty"`
+	ValidFrom      string   `json:"valid_from,omitempty"`
+	Context        string   `json:"context,omitempty"`
+	ObjectRefs     []string `json:"object_refs,omitempty"`
+	Labels         []string `json:"labels,omitempty"`
+}
+
+// BuildSTIXBundle turns the IOCs of the incidents into STIX indicators, with
+// a grouping per incident that refers to its indicators. Indicators shared by
+// incidents are written once.
+func BuildSTIXBundle(incidents []IncidentData, generatedAt time.Time) STIXBundle {
+	now := generatedAt.UTC().Format(stixTimeLayout)
+	identity := STIXObject{
+		Type:          "identity",
+		SpecVersion:   "2.1",
+		ID:            "identity--" + nameUUID(stixNamespace, "identity"),
+		Created:       now,
+		Modified:      now,
+		Name:          "smart-log-analyser",
+		IdentityClass: "system",
+	}
+	bundle := STIXBundle{Type: "bundle", ID: "bundle--" + nameUUID(stixNamespace, "bundle "+now)}
+	bundle.Objects = append(bundle.Objects, identity)
+
+	indicators := make(map[string]*STIXObject)
+	var order []string
+	var groupings []STIXObject
+	for _, incident := range incidents {
+		iocs := append([]string{}, incident.IOCs...)
+		sort.Strings(iocs)
+
+		var refs []string
+		for _, ioc := range iocs {
+			pattern, name, ok := stixPattern(ioc)
+			if !ok {
+				continue
+			}
+			indicator, exists := indicators[pattern]
+			if !exists {
+				indicator = &STIXObject{
+					Type:           "indicator",
+					SpecVersion:    "2.1",
+					ID:             "indicator--" + nameUUID(stixNamespace, pattern),
+					Created:        now,
+					Modified:       now,
+					CreatedByRef:   identity.ID,
+					Name:           name,
+					IndicatorTypes: []string{"malicious-activity"},
+					Pattern:        pattern,
+					PatternType:    "stix",
+					ValidFrom:      incident.StartTime.UTC().Format(stixTimeLayout),
+				}
+				indicators[pattern] = indicator
+				order = append(order, pattern)
+			} else if from := incident.StartTime.UTC().Format(stixTimeLayout); from < indicator.ValidFrom {
+				indicator.ValidFrom = from
+			}
+			refs = append(refs, indicator.ID)
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		groupings = append(groupings, STIXObject{
+			Type:         "grouping",
+			SpecVersion:  "2.1",
+			ID:           "grouping--" + nameUUID(stixNamespace, incident.Title+incident.StartTime.UTC().Format(stixTimeLayout)),
+			Created:      now,
+			Modified:     now,
+			CreatedByRef: identity.ID,
+			Name:         incident.Title,
+			Description: fmt.Sprintf("%s severity incident from %s to %s via %s. %s", incident.Severity,
+				incident.StartTime.UTC().Format(time.RFC3339), incident.EndTime.UTC().Format(time.RFC3339), incident.AttackVector, incident.Impact),
+			Context:    "suspicious-activity",
+			ObjectRefs: refs,
+			Labels:     []string{strings.ToLower(incident.Severity.String())},
+		})
+	}
+
+	for _, pattern := range order {
+		bundle.Objects = append(bundle.Objects, *indicators[pattern])
+	}
+	bundle.Objects = append(bundle.Objects, groupings...)
+	return bundle
+}
+
+// stixPattern turns an incident IOC such as "IP: 203.0.113.7" into a STIX
+// pattern and indicator name; false for IOCs it cannot express
+func stixPattern(ioc string) (string, string, bool) {
+	switch {
+	case strings.HasPrefix(ioc, iocIP):
+		ip := net.ParseIP(strings.TrimPrefix(ioc, iocIP))
+		if ip == nil {
+			return "", "", false
+		}
+		if ip.To4() != nil {
+			return fmt.Sprintf("[ipv4-addr:value = '%s']", ip), "Malicious IP " + ip.String(), true
+		}
+		return fmt.Sprintf("[ipv6-addr:value = '%s']", ip), "Malicious IP " + ip.String(), true
+	case strings.HasPrefix(ioc, iocUserAgent):
+		agent := strings.TrimPrefix(ioc, iocUserAgent)
+		return fmt.Sprintf("[network-traffic:extensions.'http-request-ext'.request_header.'User-Agent' = '%s']", stixString(agent)),
+			"Malicious user agent " + agent, true
+	case strings.HasPrefix(ioc, iocPayload):
+		payload := strings.TrimPrefix(ioc, iocPayload)
+		if payload == "" {
+			return "", "", false
+		}
+		return fmt.Sprintf("[network-traffic:extensions.'http-request-ext'.request_value LIKE '%%%s%%']", stixString(payload)),
+			"Attack payload " + payload, true
+	default:
+		return "", "", false
+	}
+}
+
+// stixString escapes text for a quoted string of a STIX pattern
+func stixString(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(text)
+}
+
+// WriteSTIXBundle writes the bundle as indented JSON to filename
+func WriteSTIXBundle(filename string, bundle STIXBundle) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return err
+	}
+	return file.Close()
+}
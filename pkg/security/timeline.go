@@ -0,0 +1,195 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// The ASCII timeline report draws phases timelineBarWidth characters wide and
+// lists up to maxTimelineEvents events per incident
+const (
+	timelineBarWidth  = 30
+	maxTimelineEvents = 20
+)
+
+// TimelineExport is the JSON export of incident timelines
+type TimelineExport struct {
+	GeneratedAt time.Time          `json:"generated_at"`
+	Incidents   []IncidentTimeline `json:"incidents"`
+}
+
+// IncidentTimeline is the course of an incident: its attack phases, one per
+// attack type, and every event in order, to replay what the attacker did
+type IncidentTimeline struct {
+	ID           string          `json:"id"`
+	Title        string          `json:"title"`
+	Severity     string          `json:"severity"`
+	ThreatActor  string          `json:"threat_actor"`
+	AttackVector string          `json:"attack_vector,omitempty"`
+	Start        time.Time       `json:"start"`
+	End          time.Time       `json:"end"`
+	Duration     float64         `json:"duration_seconds"`
+	Phases       []TimelinePhase `json:"phases"`
+	Events       []TimelineEvent `json:"events"`
+}
+
+// TimelinePhase is the span of an incident's events of one attack type, a
+// bar of the Gantt view
+type TimelinePhase struct {
+	AttackType string    `json:"attack_type"`
+	Severity   string    `json:"severity"` // Highest among the events
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Offset     float64   `json:"offset_seconds"` // Since the start of the incident
+	Duration   float64   `json:"duration_seconds"`
+	Events     int       `json:"events"`
+	Targets    int       `json:"targets"` // Distinct URLs
+
+	severity ThreatSeverity
+}
+
+// TimelineEvent is an event of an incident timeline
+type TimelineEvent struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Offset      float64   `json:"offset_seconds"` // Since the start of the incident
+	Type        string    `json:"type"`
+	Severity    string    `json:"severity"`
+	Source      string    `json:"source"`
+	AttackType  string    `json:"attack_type,omitempty"`
+	Method      string    `json:"method,omitempty"`
+	URL         string    `json:"url,omitempty"`
+	Payload     string    `json:"payload,omitempty"`
+	Confidence  float64   `json:"confidence,omitempty"`
+	Description string    `json:"description"`
+}
+
+// BuildIncidentTimelines builds the timelines of the incidents, in the order
+// they started
+func BuildIncidentTimelines(incidents []IncidentData) []IncidentTimeline {
+	timelines := make([]IncidentTimeline, 0, len(incidents))
+	for _, incident := range incidents {
+		timelines = append(timelines, NewIncidentTimeline(incident))
+	}
+	sort.SliceStable(timelines, func(i, j int) bool {
+		return timelines[i].Start.Before(timelines[j].Start)
+	})
+	return timelines
+}
+
+// NewIncidentTimeline builds the timeline of an incident from its events
+func NewIncidentTimeline(incident IncidentData) IncidentTimeline {
+	events := make([]IncidentEvent, len(incident.Timeline))
+	copy(events, incident.Timeline)
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Timestamp.Equal(events[j].Timestamp) {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		}
+		return events[i].Description < events[j].Description
+	})
+
+	timeline := IncidentTimeline{
+		ID:           incident.ID,
+		Title:        incident.Title,
+		Severity:     incident.Severity.String(),
+		ThreatActor:  incident.ThreatActor,
+		AttackVector: incident.AttackVector,
+		Start:        incident.StartTime,
+		End:          incident.EndTime,
+		Phases:       []TimelinePhase{},
+		Events:       []TimelineEvent{},
+	}
+	if len(events) > 0 {
+		timeline.Start = events[0].Timestamp
+		timeline.End = events[len(events)-1].Timestamp
+	}
+	timeline.Duration = timeline.End.Sub(timeline.Start).Seconds()
+
+	phases := make(map[string]*TimelinePhase)
+	targets := make(map[string]map[string]bool)
+	var order []string
+	for _, event := range events {
+		entry := TimelineEvent{
+			Timestamp:   event.Timestamp,
+			Offset:      event.Timestamp.Sub(timeline.Start).Seconds(),
+			Type:        event.Type,
+			Severity:    event.Severity.String(),
+			Source:      event.Source,
+			Description: event.Description,
+		}
+		entry.AttackType, _ = event.Details["attack_type"].(string)
+		entry.Method, _ = event.Details["method"].(string)
+		entry.URL, _ = event.Details["url"].(string)
+		entry.Payload, _ = event.Details["payload"].(string)
+		entry.Confidence, _ = event.Details["confidence"].(float64)
+		timeline.Events = append(timeline.Events, entry)
+
+		name := entry.AttackType
+		if name == "" {
+			name = event.Type
+		}
+		phase, exists := phases[name]
+		if !exists {
+			phase = &TimelinePhase{AttackType: name, Start: event.Timestamp, severity: event.Severity}
+			phases[name] = phase
+			targets[name] = make(map[string]bool)
+			order = append(order, name)
+		}
+		phase.End = event.Timestamp
+		phase.Events++
+		if event.Severity > phase.severity {
+			phase.severity = event.Severity
+		}
+		if entry.URL != "" {
+			targets[name][entry.URL] = true
+		}
+	}
+
+	for _, name := range order {
+		phase := phases[name]
+		phase.Severity = phase.severity.String()
+		phase.Offset = phase.Start.Sub(timeline.Start).Seconds()
+		phase.Duration = phase.End.Sub(phase.Start).Seconds()
+		phase.Targets = len(targets[name])
+		timeline.Phases = append(timeline.Phases, *phase)
+	}
+	sort.SliceStable(timeline.Phases, func(i, j int) bool {
+		if !timeline.Phases[i].Start.Equal(timeline.Phases[j].Start) {
+			return timeline.Phases[i].Start.Before(timeline.Phases[j].Start)
+		}
+		return timeline.Phases[i].AttackType < timeline.Phases[j].AttackType
+	})
+	return timeline
+}
+
+// WriteTimelineFile writes the timelines of the incidents as JSON
+func WriteTimelineFile(filename string, incidents []IncidentData, generatedAt time.Time) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	export := TimelineExport{GeneratedAt: generatedAt, Incidents: BuildIncidentTimelines(incidents)}
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// FormatOffset formats a time since the start of an incident as +HH:MM:SS
+func FormatOffset(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("+%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
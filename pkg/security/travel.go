@@ -0,0 +1,246 @@
+package security
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/parser"
+)
+
+// GeoLocator looks up the country of an IP, such as the analyser's GeoIP
+// database
+type GeoLocator interface {
+	Lookup(ip string) (code string, name string, ok bool)
+}
+
+// Access to one account from two places is impossible travel when covering
+// the distance in between would take faster than an airliner flies
+const (
+	impossibleTravelWindow      = 6 * time.Hour
+	impossibleTravelMinDistance = 1000.0 // km; country centroids are rough, and neighbours are no sign
+	impossibleTravelMaxSpeed    = 1000.0 // km/h
+)
+
+// sessionParameters are query parameters that carry a session token
+var sessionParameters = regexp.MustCompile(`(?i)^(session(_?id)?|sess|sid|token|auth(_?token)?|access_token|jsessionid|phpsessid)$`)
+
+// jsessionPath is a Java servlet session token in the path
+var jsessionPath = regexp.MustCompile(`(?i);jsessionid=([^/?#;]+)`)
+
+// accountPaths are paths of one account, identified by a numeric or hex ID;
+// paths such as /dashboard are shared by every user and tell nothing
+var accountPaths = regexp.MustCompile(`(?i)^(?:/api(?:/v\d+)?)?(/(?:accounts?|profiles?|users?|customers?|members?)/(?:\d+|[0-9a-f-]{8,}))(?:/|$)`)
+
+// accountKey identifies the account or session of a successful request:
+// its session token, or else its account path up to the account ID; empty
+// for other requests
+func accountKey(entry *parser.LogEntry) (string, string) {
+	if entry.Status >= 400 {
+		return "", ""
+	}
+	if match := jsessionPath.FindStringSubmatch(entry.URL); match != nil {
+		return "token:" + match[1], "session token jsessionid=" + maskToken(match[1])
+	}
+	path, query := entry.URL, ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, query = path[:i], path[i+1:]
+	}
+	if values, err := url.ParseQuery(query); err == nil {
+		var names []string
+		for name := range values {
+			if sessionParameters.MatchString(name) && values.Get(name) != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			token := values.Get(names[0])
+			return "token:" + token, "session token " + names[0] + "=" + maskToken(token)
+		}
+	}
+	if match := accountPaths.FindStringSubmatch(path); match != nil {
+		account := strings.ToLower(match[1])
+		return "path:" + account, "account path " + account
+	}
+	return "", ""
+}
+
+// maskToken keeps enough of a token to tell tokens apart without leaking it
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return "…"
+	}
+	return token[:4] + "…"
+}
+
+// detectImpossibleTravel detects the same session token or account path
+// being used successfully from countries too far apart to travel between in
+// the time between the requests, a sign the account or session is
+// compromised. It needs a GeoIP database.
+func (td *ThreatDetector) detectImpossibleTravel(logs []*parser.LogEntry) []EnhancedThreat {
+	if td.config.GeoIP == nil {
+		return nil
+	}
+
+	type access struct {
+		entry   *parser.LogEntry
+		country string
+	}
+	accesses := make(map[string][]access)
+	labels := make(map[string]string)
+	var keys []string
+	for _, entry := range logs {
+		key, label := accountKey(entry)
+		if key == "" {
+			continue
+		}
+		country, _, ok := td.config.GeoIP.Lookup(entry.IP)
+		if !ok {
+			continue
+		}
+		if _, exists := accesses[key]; !exists {
+			keys = append(keys, key)
+			labels[key] = label
+		}
+		accesses[key] = append(accesses[key], access{entry, country})
+	}
+
+	var threats []EnhancedThreat
+	for _, key := range keys {
+		list := accesses[key]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].entry.Timestamp.Before(list[j].entry.Timestamp) })
+
+		// Report the fastest travel between consecutive accesses
+		var from, to access
+		fastest, distance := 0.0, 0.0
+		for i := 1; i < len(list); i++ {
+			previous, current := list[i-1], list[i]
+			if previous.country == current.country || previous.entry.IP == current.entry.IP {
+				continue
+			}
+			elapsed := current.entry.Timestamp.Sub(previous.entry.Timestamp)
+			if elapsed > impossibleTravelWindow {
+				continue
+			}
+			km, ok := countryDistance(previous.country, current.country)
+			if !ok || km < impossibleTravelMinDistance {
+				continue
+			}
+			speed := math.Inf(1)
+			if elapsed > 0 {
+				speed = km / elapsed.Hours()
+			}
+			if speed > impossibleTravelMaxSpeed && speed > fastest {
+				fastest, distance = speed, km
+				from, to = previous, current
+			}
+		}
+		if fastest == 0 {
+			continue
+		}
+
+		elapsed := to.entry.Timestamp.Sub(from.entry.Timestamp)
+		threats = append(threats, EnhancedThreat{
+			ID:           fmt.Sprintf("travel_%d_%s", time.Now().UnixNano(), to.entry.IP),
+			Type:         SessionHijacking,
+			Severity:     SeverityHigh,
+			Confidence:   0.7,
+			Pattern:      "Impossible travel",
+			URL:          to.entry.URL,
+			IP:           to.entry.IP,
+			UserAgent:    to.entry.UserAgent,
+			Timestamp:    to.entry.Timestamp,
+			Method:       to.entry.Method,
+			StatusCode:   to.entry.Status,
+			AttackVector: "Account access from distant locations",
+			Context: map[string]interface{}{
+				"description": fmt.Sprintf("Possible account compromise: %s used from %s (%s) and %s (%s), %.0f km apart, %s apart",
+					labels[key], from.entry.IP, from.country, to.entry.IP, to.country, distance, elapsed.Round(time.Second)),
+				"account":        labels[key],
+				"first_ip":       from.entry.IP,
+				"first_country":  from.country,
+				"second_country": to.country,
+				"distance_km":    math.Round(distance),
+				"interval":       elapsed.String(),
+				"accesses":       len(list),
+			},
+			MitigationAdvice: []string{"Revoke the session and force a password reset", "Require MFA for the account", "Review the account's recent activity"},
+		})
+	}
+
+	return threats
+}
+
+// countryDistance returns the great-circle distance in km between the
+// centroids of two countries; false when either is unknown
+func countryDistance(a, b string) (float64, bool) {
+	from, okFrom := countryCentroids[a]
+	to, okTo := countryCentroids[b]
+	if !okFrom || !okTo {
+		return 0, false
+	}
+	const earthRadius = 6371.0
+	lat1, lat2 := from[0]*math.Pi/180, to[0]*math.Pi/180
+	dLat := (to[0] - from[0]) * math.Pi / 180
+	dLon := (to[1] - from[1]) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h)), true
+}
+
+// countryCentroids are the approximate centres (latitude, longitude) of
+// countries by ISO 3166-1 alpha-2 code
+var countryCentroids = map[string][2]float64{
+	"AD": {42.5, 1.5}, "AE": {24.0, 54.0}, "AF": {33.0, 65.0}, "AG": {17.1, -61.8},
+	"AL": {41.0, 20.0}, "AM": {40.0, 45.0}, "AO": {-12.5, 18.5}, "AR": {-34.0, -64.0},
+	"AT": {47.3, 13.3}, "AU": {-25.0, 133.0}, "AZ": {40.5, 47.5}, "BA": {44.0, 18.0},
+	"BB": {13.2, -59.5}, "BD": {24.0, 90.0}, "BE": {50.8, 4.0}, "BF": {13.0, -2.0},
+	"BG": {43.0, 25.0}, "BH": {26.0, 50.6}, "BI": {-3.5, 30.0}, "BJ": {9.5, 2.3},
+	"BN": {4.5, 114.7}, "BO": {-17.0, -65.0}, "BR": {-10.0, -55.0}, "BS": {24.3, -76.0},
+	"BT": {27.5, 90.5}, "BW": {-22.0, 24.0}, "BY": {53.0, 28.0}, "BZ": {17.3, -88.8},
+	"CA": {60.0, -95.0}, "CD": {-2.5, 23.5}, "CF": {7.0, 21.0}, "CG": {-1.0, 15.0},
+	"CH": {47.0, 8.0}, "CI": {8.0, -5.0}, "CL": {-30.0, -71.0}, "CM": {6.0, 12.0},
+	"CN": {35.0, 105.0}, "CO": {4.0, -72.0}, "CR": {10.0, -84.0}, "CU": {21.5, -80.0},
+	"CV": {16.0, -24.0}, "CY": {35.0, 33.0}, "CZ": {49.8, 15.5}, "DE": {51.0, 9.0},
+	"DJ": {11.5, 43.0}, "DK": {56.0, 10.0}, "DM": {15.4, -61.4}, "DO": {19.0, -70.7},
+	"DZ": {28.0, 3.0}, "EC": {-2.0, -77.5}, "EE": {59.0, 26.0}, "EG": {27.0, 30.0},
+	"ER": {15.0, 39.0}, "ES": {40.0, -4.0}, "ET": {8.0, 38.0}, "FI": {64.0, 26.0},
+	"FJ": {-18.0, 178.0}, "FR": {46.0, 2.0}, "GA": {-1.0, 11.8}, "GB": {54.0, -2.0},
+	"GD": {12.1, -61.7}, "GE": {42.0, 43.5}, "GH": {8.0, -2.0}, "GM": {13.5, -15.5},
+	"GN": {11.0, -10.0}, "GQ": {2.0, 10.0}, "GR": {39.0, 22.0}, "GT": {15.5, -90.3},
+	"GW": {12.0, -15.0}, "GY": {5.0, -59.0}, "HK": {22.3, 114.2}, "HN": {15.0, -86.5},
+	"HR": {45.2, 15.5}, "HT": {19.0, -72.4}, "HU": {47.0, 20.0}, "ID": {-5.0, 120.0},
+	"IE": {53.0, -8.0}, "IL": {31.5, 34.8}, "IN": {20.0, 77.0}, "IQ": {33.0, 44.0},
+	"IR": {32.0, 53.0}, "IS": {65.0, -18.0}, "IT": {42.8, 12.8}, "JM": {18.3, -77.3},
+	"JO": {31.0, 36.0}, "JP": {36.0, 138.0}, "KE": {1.0, 38.0}, "KG": {41.0, 75.0},
+	"KH": {13.0, 105.0}, "KM": {-12.2, 44.3}, "KN": {17.3, -62.7}, "KP": {40.0, 127.0},
+	"KR": {37.0, 127.5}, "KW": {29.3, 47.7}, "KZ": {48.0, 68.0}, "LA": {18.0, 105.0},
+	"LB": {33.8, 35.8}, "LC": {13.9, -61.0}, "LI": {47.2, 9.5}, "LK": {7.0, 81.0},
+	"LR": {6.5, -9.5}, "LS": {-29.5, 28.5}, "LT": {56.0, 24.0}, "LU": {49.8, 6.2},
+	"LV": {57.0, 25.0}, "LY": {25.0, 17.0}, "MA": {32.0, -5.0}, "MC": {43.7, 7.4},
+	"MD": {47.0, 29.0}, "ME": {42.5, 19.3}, "MG": {-20.0, 47.0}, "MK": {41.8, 22.0},
+	"ML": {17.0, -4.0}, "MM": {22.0, 98.0}, "MN": {46.0, 105.0}, "MO": {22.2, 113.5},
+	"MR": {20.0, -12.0}, "MT": {35.9, 14.4}, "MU": {-20.3, 57.6}, "MV": {3.2, 73.0},
+	"MW": {-13.5, 34.0}, "MX": {23.0, -102.0}, "MY": {2.5, 112.5}, "MZ": {-18.3, 35.0},
+	"NA": {-22.0, 17.0}, "NE": {16.0, 8.0}, "NG": {10.0, 8.0}, "NI": {13.0, -85.0},
+	"NL": {52.5, 5.8}, "NO": {62.0, 10.0}, "NP": {28.0, 84.0}, "NZ": {-41.0, 174.0},
+	"OM": {21.0, 57.0}, "PA": {9.0, -80.0}, "PE": {-10.0, -76.0}, "PG": {-6.0, 147.0},
+	"PH": {13.0, 122.0}, "PK": {30.0, 70.0}, "PL": {52.0, 20.0}, "PR": {18.2, -66.5},
+	"PS": {32.0, 35.3}, "PT": {39.5, -8.0}, "PY": {-23.0, -58.0}, "QA": {25.5, 51.3},
+	"RO": {46.0, 25.0}, "RS": {44.0, 21.0}, "RU": {60.0, 100.0}, "RW": {-2.0, 30.0},
+	"SA": {25.0, 45.0}, "SB": {-8.0, 159.0}, "SC": {-4.6, 55.7}, "SD": {15.0, 30.0},
+	"SE": {62.0, 15.0}, "SG": {1.4, 103.8}, "SI": {46.1, 14.8}, "SK": {48.7, 19.5},
+	"SL": {8.5, -11.5}, "SM": {43.9, 12.4}, "SN": {14.0, -14.0}, "SO": {10.0, 49.0},
+	"SR": {4.0, -56.0}, "SS": {7.0, 30.0}, "SV": {13.8, -88.9}, "SY": {35.0, 38.0},
+	"SZ": {-26.5, 31.5}, "TD": {15.0, 19.0}, "TG": {8.0, 1.2}, "TH": {15.0, 100.0},
+	"TJ": {39.0, 71.0}, "TL": {-8.8, 125.9}, "TM": {40.0, 60.0}, "TN": {34.0, 9.0},
+	"TO": {-20.0, -175.0}, "TR": {39.0, 35.0}, "TT": {11.0, -61.0}, "TW": {23.5, 121.0},
+	"TZ": {-6.0, 35.0}, "UA": {49.0, 32.0}, "UG": {1.0, 32.0}, "US": {38.0, -97.0},
+	"UY": {-33.0, -56.0}, "UZ": {41.0, 64.0}, "VA": {41.9, 12.5}, "VC": {13.3, -61.2},
+	"VE": {8.0, -66.0}, "VN": {16.0, 106.0}, "VU": {-16.0, 167.0}, "WS": {-13.6, -172.3},
+	"YE": {15.0, 48.0}, "ZA": {-29.0, 24.0}, "ZM": {-15.0, 30.0}, "ZW": {-20.0, 30.0},
+}
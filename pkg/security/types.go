@@ -25,6 +25,8 @@ const (
 	Clickjacking
 	CSPBypass
 	HTTPSplitting
+	KnownExploit
+	CMSVulnerabilityProbe
 )
 
 // String returns the string representation of WebAttackType
@@ -60,6 +62,10 @@ func (wat WebAttackType) String() string {
 		return "Content Security Policy Bypass"
 	case HTTPSplitting:
 		return "HTTP Response Splitting"
+	case KnownExploit:
+		return "Known CVE Exploit"
+	case CMSVulnerabilityProbe:
+		return "CMS Vulnerability Probe"
 	default:
 		return "Unknown Attack"
 	}
@@ -84,6 +90,9 @@ const (
 	ForceBrowsing
 	RateLimitEvasion
 	CachePoison
+	CredentialStuffing
+	XMLRPCAbuse
+	PluginEnumeration
 )
 
 // String returns the string representation of InfrastructureAttackType
@@ -119,6 +128,12 @@ func (iat InfrastructureAttackType) String() string {
 		return "Rate Limit Evasion"
 	case CachePoison:
 		return "Cache Poisoning"
+	case CredentialStuffing:
+		return "Credential Stuffing"
+	case XMLRPCAbuse:
+		return "XML-RPC Abuse"
+	case PluginEnumeration:
+		return "CMS Plugin Enumeration"
 	default:
 		return "Unknown Infrastructure Attack"
 	}
@@ -286,10 +301,10 @@ type IPBehaviorProfile struct {
 
 // SecurityDimensions represents different aspects of security analysis
 type SecurityDimensions struct {
-	ThreatDetection   float64 // 40% weight - Direct threat identification
-	AnomalyDetection  float64 // 25% weight - Behavioral anomalies
-	TrafficIntegrity  float64 // 20% weight - Traffic pattern health
-	AccessControl     float64 // 15% weight - Authentication/authorization issues
+	ThreatDetection   float64 // 40% weight by default - Direct threat identification
+	AnomalyDetection  float64 // 25% weight by default - Behavioral anomalies
+	TrafficIntegrity  float64 // 20% weight by default - Traffic pattern health
+	AccessControl     float64 // 15% weight by default - Authentication/authorization issues
 }
 
 // ThreatIntelligence represents threat intelligence data
@@ -446,6 +461,12 @@ type SecurityConfig struct {
 	ThreatIntelligenceEnabled bool
 	IncidentResponseEnabled   bool
 	ComplianceReportingEnabled bool
+	CustomRules               []CustomRule   // Site-specific rules, see LoadCustomRules
+	Behavior                  *BehaviorStore // Failed logins of earlier runs, nil to use this run's only
+	GeoIP                     GeoLocator     // Countries of IPs for impossible travel detection, nil to skip it
+	CVESignatures             []CVESignature // Known exploit signatures, see LoadCVESignatures; nil for the built-in set
+	Reputation                *ReputationStore // IP reputation from earlier runs, nil to start every IP afresh
+	Scoring                   ScoringConfig  // Weights and risk level boundaries of the security score
 }
 
 // Default configuration
@@ -457,5 +478,6 @@ func DefaultSecurityConfig() SecurityConfig {
 		ThreatIntelligenceEnabled: true,
 		IncidentResponseEnabled:   true,
 		ComplianceReportingEnabled: true,
+		Scoring:                   DefaultScoringConfig(),
 	}
 }
\ No newline at end of file
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"smart-log-analyser/pkg/charts"
+	"smart-log-analyser/pkg/mitre"
 )
 
 // SecurityVisualizer implements security-focused visualization and reporting
@@ -18,7 +19,7 @@ type SecurityVisualizer struct {
 // NewSecurityVisualizer creates a new security visualizer
 func NewSecurityVisualizer(config SecurityConfig) *SecurityVisualizer {
 	return &SecurityVisualizer{
-		config: config,
+		config: withScoring(config),
 	}
 }
 
@@ -43,6 +44,11 @@ func (sv *SecurityVisualizer) GenerateSecurityDashboard(analysis *EnhancedSecuri
 	// Threat Distribution Chart
 	output.WriteString(sv.generateThreatDistributionChart(analysis.Threats))
 	
+	// CMS Attacks Summary
+	if cms := SummarizeCMSAttacks(analysis.Threats); len(cms) > 0 {
+		output.WriteString(sv.generateCMSSummary(cms))
+	}
+	
 	// High-Risk IPs Table
 	if len(analysis.Summary.HighRiskIPs) > 0 {
 		output.WriteString(sv.generateHighRiskIPsTable(analysis.IPProfiles, analysis.Summary.HighRiskIPs))
@@ -141,15 +147,16 @@ func (sv *SecurityVisualizer) generateSecurityDimensionsChart(dimensions Securit
 	
 	output.WriteString("┌─ SECURITY DIMENSIONS ───────────────────────────────────────┐\n")
 	
+	weights := sv.config.Scoring.DimensionWeights
 	dimensionData := []struct{
 		name  string
 		score float64
-		weight string
+		weight float64
 	}{
-		{"Threat Detection", dimensions.ThreatDetection, "40%"},
-		{"Anomaly Detection", dimensions.AnomalyDetection, "25%"},
-		{"Traffic Integrity", dimensions.TrafficIntegrity, "20%"},
-		{"Access Control", dimensions.AccessControl, "15%"},
+		{"Threat Detection", dimensions.ThreatDetection, weights.ThreatDetection},
+		{"Anomaly Detection", dimensions.AnomalyDetection, weights.AnomalyDetection},
+		{"Traffic Integrity", dimensions.TrafficIntegrity, weights.TrafficIntegrity},
+		{"Access Control", dimensions.AccessControl, weights.AccessControl},
 	}
 	
 	for _, dim := range dimensionData {
@@ -159,8 +166,8 @@ func (sv *SecurityVisualizer) generateSecurityDimensionsChart(dimensions Securit
 		bar += strings.Repeat("░", 40-barLength)
 		
 		color := sv.getScoreColor(int(dim.score))
-		output.WriteString(fmt.Sprintf("│ %-17s │%s%s%s│ %3.0f%% (%s) │\n", 
-			dim.name, color, bar, charts.ColorReset, dim.score, dim.weight))
+		output.WriteString(fmt.Sprintf("│ %-17s │%s%s%s│ %3.0f%% (%2.0f%%) │\n", 
+			dim.name, color, bar, charts.ColorReset, dim.score, dim.weight*100))
 	}
 	
 	output.WriteString("└─────────────────────────────────────────────────────────────┘\n\n")
@@ -187,6 +194,10 @@ func (sv *SecurityVisualizer) generateThreatDistributionChart(threats []Enhanced
 			threatType = t.String()
 		case InfrastructureAttackType:
 			threatType = t.String()
+		case CustomAttackType:
+			threatType = t.String()
+		case ExfiltrationType:
+			threatType = t.String()
 		default:
 			threatType = "Unknown"
 		}
@@ -252,6 +263,57 @@ func (sv *SecurityVisualizer) generateThreatDistributionChart(threats []Enhanced
 	return output.String()
 }
 
+// generateCMSSummary creates a card of the attacks on each CMS
+func (sv *SecurityVisualizer) generateCMSSummary(summaries []CMSSummary) string {
+	var output strings.Builder
+	
+	output.WriteString("┌─ CMS ATTACKS ───────────────────────────────────────────────┐\n")
+	
+	line := func(text string) {
+		output.WriteString(fmt.Sprintf("│ %-59s │\n", charts.TruncateString(text, 59)))
+	}
+	
+	for _, summary := range summaries {
+		line(fmt.Sprintf("%s: %d threats from %d IPs", summary.CMS, summary.Threats, summary.IPs))
+		
+		var attackTypes []string
+		for name := range summary.AttackTypes {
+			attackTypes = append(attackTypes, name)
+		}
+		sort.Slice(attackTypes, func(i, j int) bool {
+			if summary.AttackTypes[attackTypes[i]] != summary.AttackTypes[attackTypes[j]] {
+				return summary.AttackTypes[attackTypes[i]] > summary.AttackTypes[attackTypes[j]]
+			}
+			return attackTypes[i] < attackTypes[j]
+		})
+		for _, name := range attackTypes {
+			line(fmt.Sprintf("  %-40s %5d", name, summary.AttackTypes[name]))
+		}
+		
+		var calls []string
+		if summary.LoginAttempts > 0 {
+			calls = append(calls, fmt.Sprintf("Login attempts: %d", summary.LoginAttempts))
+		}
+		if summary.XMLRPCRequests > 0 {
+			calls = append(calls, fmt.Sprintf("XML-RPC calls: %d", summary.XMLRPCRequests))
+		}
+		if len(calls) > 0 {
+			line("  " + strings.Join(calls, "   "))
+		}
+		if len(summary.Targets) > 0 {
+			var targets []string
+			for _, target := range summary.Targets {
+				targets = append(targets, fmt.Sprintf("%s (%d)", target.Name, target.Probes))
+			}
+			line("  Probed: " + strings.Join(targets, ", "))
+		}
+	}
+	
+	output.WriteString("└─────────────────────────────────────────────────────────────┘\n\n")
+	
+	return output.String()
+}
+
 // generateHighRiskIPsTable creates a table of high-risk IP addresses
 func (sv *SecurityVisualizer) generateHighRiskIPsTable(profiles map[string]*IPBehaviorProfile, highRiskIPs []string) string {
 	var output strings.Builder
@@ -655,35 +717,41 @@ func (sv *SecurityVisualizer) GenerateDetailedThreatReport(threats []EnhancedThr
 	output.WriteString("║                    DETAILED THREAT REPORT                   ║\n")
 	output.WriteString("╚══════════════════════════════════════════════════════════════╝\n\n")
 	
-	// Group by severity
-	severityGroups := make(map[ThreatSeverity][]EnhancedThreat)
-	for _, threat := range threats {
-		severityGroups[threat.Severity] = append(severityGroups[threat.Severity], threat)
+	// Group near-identical threats, then by severity
+	severityGroups := make(map[ThreatSeverity][]ThreatGroup)
+	severityCounts := make(map[ThreatSeverity]int)
+	for _, group := range AggregateThreats(threats) {
+		severity := group.Threat.Severity
+		severityGroups[severity] = append(severityGroups[severity], group)
+		severityCounts[severity] += group.Count
 	}
 	
 	// Display by severity (highest first)
 	severityOrder := []ThreatSeverity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
 	
 	for _, severity := range severityOrder {
-		threatList, exists := severityGroups[severity]
-		if !exists || len(threatList) == 0 {
+		groupList, exists := severityGroups[severity]
+		if !exists || len(groupList) == 0 {
 			continue
 		}
 		
 		severityColor := sv.getSeverityColor(severity)
 		output.WriteString(fmt.Sprintf("┌─ %s%s THREATS (%d)%s", 
-			severityColor, severity.String(), len(threatList), charts.ColorReset))
-		output.WriteString(strings.Repeat("─", 62-len(fmt.Sprintf("%s THREATS (%d)", severity.String(), len(threatList)))))
+			severityColor, severity.String(), severityCounts[severity], charts.ColorReset))
+		output.WriteString(strings.Repeat("─", 62-len(fmt.Sprintf("%s THREATS (%d)", severity.String(), severityCounts[severity]))))
 		output.WriteString("┐\n")
 		
-		// Show top 5 threats of this severity
+		// Show the top 5 groups of this severity
 		displayCount := 5
-		if len(threatList) < displayCount {
-			displayCount = len(threatList)
+		if len(groupList) < displayCount {
+			displayCount = len(groupList)
 		}
 		
+		displayed := 0
 		for i := 0; i < displayCount; i++ {
-			threat := threatList[i]
+			group := groupList[i]
+			threat := group.Threat
+			displayed += group.Count
 			
 			var threatType string
 			switch t := threat.Type.(type) {
@@ -691,18 +759,30 @@ func (sv *SecurityVisualizer) GenerateDetailedThreatReport(threats []EnhancedThr
 				threatType = t.String()
 			case InfrastructureAttackType:
 				threatType = t.String()
+			case CustomAttackType:
+				threatType = t.String()
+			case ExfiltrationType:
+				threatType = t.String()
 			default:
 				threatType = "Unknown"
 			}
 			
-			output.WriteString(fmt.Sprintf("│ %s from %s at %s\n",
-				threatType, threat.IP, threat.Timestamp.Format("15:04:05")))
+			if group.Count > 1 {
+				output.WriteString(fmt.Sprintf("│ %s from %s, %d times from %s to %s\n",
+					threatType, threat.IP, group.Count, group.FirstSeen.Format("15:04:05"), group.LastSeen.Format("15:04:05")))
+			} else {
+				output.WriteString(fmt.Sprintf("│ %s from %s at %s\n",
+					threatType, threat.IP, threat.Timestamp.Format("15:04:05")))
+			}
 			
 			if threat.URL != "" {
 				url := threat.URL
 				if len(url) > 55 {
 					url = url[:52] + "..."
 				}
+				if group.URLs > 1 {
+					url += fmt.Sprintf(" (and %d more URLs)", group.URLs-1)
+				}
 				output.WriteString(fmt.Sprintf("│ Target: %s\n", url))
 			}
 			
@@ -717,14 +797,26 @@ func (sv *SecurityVisualizer) GenerateDetailedThreatReport(threats []EnhancedThr
 			output.WriteString(fmt.Sprintf("│ Confidence: %.0f%% │ Attack Vector: %s\n", 
 				threat.Confidence*100, threat.AttackVector))
 			
+			if techniques := threat.MITRETechniques(); len(techniques) > 0 {
+				output.WriteString(fmt.Sprintf("│ ATT&CK: %s\n", strings.Join(mitre.IDs(techniques), ", ")))
+			}
+			
+			if cves := ThreatCVEs(threat); len(cves) > 0 {
+				output.WriteString(fmt.Sprintf("│ CVE: %s\n", strings.Join(cves, ", ")))
+			}
+			
+			if category, ok := threat.OWASPCategory(); ok {
+				output.WriteString(fmt.Sprintf("│ OWASP: %s %s\n", category.ID, category.Name))
+			}
+			
 			if i < displayCount-1 {
 				output.WriteString("├─────────────────────────────────────────────────────────────┤\n")
 			}
 		}
 		
-		if len(threatList) > displayCount {
-			output.WriteString(fmt.Sprintf("│ ... and %d more %s threats\n", 
-				len(threatList)-displayCount, strings.ToLower(severity.String())))
+		if len(groupList) > displayCount {
+			output.WriteString(fmt.Sprintf("│ ... and %d more %s threats in %d groups\n", 
+				severityCounts[severity]-displayed, strings.ToLower(severity.String()), len(groupList)-displayCount))
 		}
 		
 		output.WriteString("└─────────────────────────────────────────────────────────────┘\n\n")
@@ -733,6 +825,234 @@ func (sv *SecurityVisualizer) GenerateDetailedThreatReport(threats []EnhancedThr
 	return output.String()
 }
 
+// GenerateOWASPReport creates a report of the threats per OWASP Top 10
+// category, with the attack types and most targeted endpoints of each
+func (sv *SecurityVisualizer) GenerateOWASPReport(threats []EnhancedThreat) string {
+	var output strings.Builder
+	
+	output.WriteString("╔══════════════════════════════════════════════════════════════╗\n")
+	output.WriteString("║                  OWASP TOP 10 (2021) REPORT                 ║\n")
+	output.WriteString("╚══════════════════════════════════════════════════════════════╝\n\n")
+	
+	line := func(text string) {
+		output.WriteString(fmt.Sprintf("│ %-59s │\n", charts.TruncateString(text, 59)))
+	}
+	
+	summaries := SummarizeOWASP(threats)
+	output.WriteString("┌─ CATEGORIES ────────────────────────────────────────────────┐\n")
+	line(fmt.Sprintf("%-46s %7s %4s", "Category", "Threats", "IPs"))
+	for _, summary := range summaries {
+		id := owaspShortID(summary.Category)
+		if summary.Threats == 0 {
+			line(fmt.Sprintf("%-3s %-42s %7s %4s", id, summary.Category.Name, "-", "-"))
+			continue
+		}
+		line(fmt.Sprintf("%-3s %-42s %7d %4d", id, summary.Category.Name, summary.Threats, summary.IPs))
+	}
+	
+	for _, summary := range summaries {
+		if summary.Threats == 0 {
+			continue
+		}
+		output.WriteString("├─────────────────────────────────────────────────────────────┤\n")
+		line(fmt.Sprintf("%s %s (%s)", owaspShortID(summary.Category), summary.Category.Name, summary.Severity))
+		
+		var attackTypes []string
+		for name := range summary.AttackTypes {
+			attackTypes = append(attackTypes, name)
+		}
+		sort.Slice(attackTypes, func(i, j int) bool {
+			if summary.AttackTypes[attackTypes[i]] != summary.AttackTypes[attackTypes[j]] {
+				return summary.AttackTypes[attackTypes[i]] > summary.AttackTypes[attackTypes[j]]
+			}
+			return attackTypes[i] < attackTypes[j]
+		})
+		for _, name := range attackTypes {
+			line(fmt.Sprintf("  %-49s %7d", charts.TruncateString(name, 49), summary.AttackTypes[name]))
+		}
+		
+		if summary.Endpoints > 0 {
+			line(fmt.Sprintf("  Endpoints: %d", summary.Endpoints))
+			for _, endpoint := range summary.Top {
+				line(fmt.Sprintf("    %-47s %7d", charts.TruncateString(endpoint.Path, 47), endpoint.Threats))
+			}
+		}
+	}
+	output.WriteString("└─────────────────────────────────────────────────────────────┘\n\n")
+	
+	return output.String()
+}
+
+// owaspShortID returns the ID of an OWASP category without its year, e.g. A03
+func owaspShortID(category OWASPCategory) string {
+	return strings.SplitN(category.ID, ":", 2)[0]
+}
+
+// GenerateBaselineDiff creates a report of what changed since a baseline
+// security report: the score, new and gone attacker IPs and attack types,
+// and new and resolved incidents
+func (sv *SecurityVisualizer) GenerateBaselineDiff(diff BaselineDiff) string {
+	var output strings.Builder
+	
+	output.WriteString("╔══════════════════════════════════════════════════════════════╗\n")
+	output.WriteString("║                  CHANGES SINCE BASELINE                     ║\n")
+	output.WriteString("╚══════════════════════════════════════════════════════════════╝\n\n")
+	
+	line := func(text string) {
+		output.WriteString(fmt.Sprintf("│ %-59s │\n", charts.TruncateString(text, 59)))
+	}
+	more := func(shown, total int) {
+		if total > shown {
+			line(fmt.Sprintf("  ... and %d more", total-shown))
+		}
+	}
+	
+	output.WriteString("┌─ SUMMARY ───────────────────────────────────────────────────┐\n")
+	line(fmt.Sprintf("Baseline: %s to %s", diff.BaselineStart.Format("2006-01-02 15:04"), diff.BaselineEnd.Format("2006-01-02 15:04")))
+	line(fmt.Sprintf("Security Score: %d → %d (%+d)", diff.ScoreBefore, diff.ScoreAfter, diff.ScoreDelta))
+	line(fmt.Sprintf("Risk Level: %s → %s", diff.RiskBefore, diff.RiskAfter))
+	line(fmt.Sprintf("Threats: %d → %d (%+d)", diff.ThreatsBefore, diff.ThreatsAfter, diff.ThreatsAfter-diff.ThreatsBefore))
+	
+	output.WriteString(fmt.Sprintf("├─ NEW ATTACKER IPS (%d) %s┤\n", len(diff.NewIPs), strings.Repeat("─", 39-len(fmt.Sprint(len(diff.NewIPs))))))
+	if len(diff.NewIPs) == 0 {
+		line("None")
+	}
+	for i, ip := range diff.NewIPs {
+		if i == maxBaselineDiffItems {
+			break
+		}
+		line(fmt.Sprintf("%-15s %-8s %5d  %s", ip.IP, ip.Severity, ip.Threats, strings.Join(ip.AttackTypes, ", ")))
+	}
+	more(maxBaselineDiffItems, len(diff.NewIPs))
+	
+	output.WriteString(fmt.Sprintf("├─ NEW ATTACK TYPES (%d) %s┤\n", len(diff.NewAttackTypes), strings.Repeat("─", 39-len(fmt.Sprint(len(diff.NewAttackTypes))))))
+	if len(diff.NewAttackTypes) == 0 {
+		line("None")
+	}
+	for i, attackType := range diff.NewAttackTypes {
+		if i == maxBaselineDiffItems {
+			break
+		}
+		line(fmt.Sprintf("%-36s %6d threats %3d IPs", charts.TruncateString(attackType.Type, 36), attackType.Threats, attackType.IPs))
+	}
+	more(maxBaselineDiffItems, len(diff.NewAttackTypes))
+	
+	output.WriteString(fmt.Sprintf("├─ NEW INCIDENTS (%d) %s┤\n", len(diff.NewIncidents), strings.Repeat("─", 42-len(fmt.Sprint(len(diff.NewIncidents))))))
+	if len(diff.NewIncidents) == 0 {
+		line("None")
+	}
+	for i, incident := range diff.NewIncidents {
+		if i == maxBaselineDiffItems {
+			break
+		}
+		line(fmt.Sprintf("[%s] %s", incident.Severity, incident.Title))
+	}
+	more(maxBaselineDiffItems, len(diff.NewIncidents))
+	
+	output.WriteString(fmt.Sprintf("├─ RESOLVED INCIDENTS (%d) %s┤\n", len(diff.ResolvedIncidents), strings.Repeat("─", 37-len(fmt.Sprint(len(diff.ResolvedIncidents))))))
+	if len(diff.ResolvedIncidents) == 0 {
+		line("None")
+	}
+	for i, incident := range diff.ResolvedIncidents {
+		if i == maxBaselineDiffItems {
+			break
+		}
+		line(fmt.Sprintf("[%s] %s", incident.Severity, incident.Title))
+	}
+	more(maxBaselineDiffItems, len(diff.ResolvedIncidents))
+	
+	output.WriteString(fmt.Sprintf("├─ NO LONGER SEEN %s┤\n", strings.Repeat("─", 44)))
+	line(fmt.Sprintf("Attacker IPs: %d", len(diff.GoneIPs)))
+	for i, ip := range diff.GoneIPs {
+		if i == maxBaselineDiffItems {
+			break
+		}
+		line("  " + ip)
+	}
+	more(maxBaselineDiffItems, len(diff.GoneIPs))
+	line(fmt.Sprintf("Attack types: %d", len(diff.GoneAttackTypes)))
+	for i, attackType := range diff.GoneAttackTypes {
+		if i == maxBaselineDiffItems {
+			break
+		}
+		line("  " + attackType)
+	}
+	more(maxBaselineDiffItems, len(diff.GoneAttackTypes))
+	output.WriteString("└─────────────────────────────────────────────────────────────┘\n\n")
+	
+	return output.String()
+}
+
+// GenerateIncidentTimelineReport creates a Gantt-style view of each incident:
+// a bar per attack phase over the course of the incident, then its events in
+// order
+func (sv *SecurityVisualizer) GenerateIncidentTimelineReport(incidents []IncidentData) string {
+	var output strings.Builder
+	
+	if len(incidents) == 0 {
+		return "No incidents to show.\n"
+	}
+	
+	output.WriteString("╔══════════════════════════════════════════════════════════════╗\n")
+	output.WriteString("║                  INCIDENT TIMELINE REPORT                   ║\n")
+	output.WriteString("╚══════════════════════════════════════════════════════════════╝\n\n")
+	
+	line := func(text string) {
+		output.WriteString(fmt.Sprintf("│ %-59s │\n", charts.TruncateString(text, 59)))
+	}
+	
+	for _, timeline := range BuildIncidentTimelines(incidents) {
+		title := fmt.Sprintf("%s (%s)", timeline.ID, timeline.Severity)
+		output.WriteString(fmt.Sprintf("┌─ %s %s┐\n", title, strings.Repeat("─", 58-len([]rune(title)))))
+		line(timeline.Title)
+		line(fmt.Sprintf("%d events over %s by %s", len(timeline.Events), FormatOffset(timeline.Duration)[1:], timeline.ThreatActor))
+		output.WriteString("├─────────────────────────────────────────────────────────────┤\n")
+		
+		line(fmt.Sprintf("%-20s %-30s %6s", "Phase", timeline.Start.Format("15:04:05")+strings.Repeat(" ", 14)+timeline.End.Format("15:04:05"), "Events"))
+		for _, phase := range timeline.Phases {
+			// The bar is already timelineBarWidth wide and would not survive
+			// truncation
+			output.WriteString(fmt.Sprintf("│ %-20s %s %6d │\n", charts.TruncateString(phase.AttackType, 20), timelineBar(phase, timeline.Duration, timelineBarWidth), phase.Events))
+		}
+		output.WriteString("├─────────────────────────────────────────────────────────────┤\n")
+		
+		for i, event := range timeline.Events {
+			if i == maxTimelineEvents {
+				line(fmt.Sprintf("... and %d more events", len(timeline.Events)-maxTimelineEvents))
+				break
+			}
+			target := event.URL
+			if event.Method != "" {
+				target = event.Method + " " + target
+			}
+			line(fmt.Sprintf("%s %-8s %s %s", FormatOffset(event.Offset), event.Severity, event.AttackType, target))
+		}
+		output.WriteString("└─────────────────────────────────────────────────────────────┘\n\n")
+	}
+	
+	return output.String()
+}
+
+// timelineBar draws a phase as a bar of width characters over the duration
+// of its incident
+func timelineBar(phase TimelinePhase, duration float64, width int) string {
+	start, end := 0, width
+	if duration > 0 {
+		start = int(phase.Offset / duration * float64(width))
+		end = int(math.Ceil((phase.Offset + phase.Duration) / duration * float64(width)))
+	}
+	if start >= width {
+		start = width - 1
+	}
+	if end <= start {
+		end = start + 1
+	}
+	if end > width {
+		end = width
+	}
+	return strings.Repeat("·", start) + strings.Repeat("█", end-start) + strings.Repeat("·", width-end)
+}
+
 // GenerateAnomalyReport creates a detailed anomaly analysis report
 func (sv *SecurityVisualizer) GenerateAnomalyReport(anomalies []Anomaly) string {
 	var output strings.Builder
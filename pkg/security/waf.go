@@ -0,0 +1,394 @@
+package security
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// WAFFormats lists the WAF rule formats
+var WAFFormats = []string{"modsecurity", "cloudflare"}
+
+// wafRuleIDStart is the ID of the first suggested ModSecurity rule, inside
+// the 1-99,999 range ModSecurity reserves for local rules
+const wafRuleIDStart = 10001
+
+// wafMaxSamples is the number of distinct payloads kept per suggestion
+const wafMaxSamples = 5
+
+// minPayloadAlphanumerics is the number of letters and digits a payload
+// needs before a rule is built from it. Below that it is an operator or
+// punctuation (";", "&", "../") that legitimate requests carry too.
+const minPayloadAlphanumerics = 3
+
+// Request parts a payload was seen in
+const (
+	wafTargetURI       = "uri"
+	wafTargetUserAgent = "user-agent"
+	wafTargetReferer   = "referer"
+)
+
+// WAFRuleSuggestion is a candidate WAF rule for one attack type on one path,
+// built from the detected payloads
+type WAFRuleSuggestion struct {
+	AttackType WebAttackType
+	Path       string         // URL path the attacks targeted
+	Target     string         // Request part the payloads were in: uri, user-agent or referer
+	Patterns   []string       // Detection patterns that matched, as regular expressions
+	Samples    []string       // Distinct payloads seen, lowercased
+	Severity   ThreatSeverity // Highest severity of the attacks
+	Hits       int
+	IPs        int
+	FirstSeen  time.Time
+	LastSeen   time.Time
+}
+
+// SuggestWAFRules groups the web attacks among the threats by attack type,
+// path and request part into rule suggestions, most hits first. Threats
+// whose payload is too weak to block on are left out.
+func SuggestWAFRules(threats []EnhancedThreat) []WAFRuleSuggestion {
+	type group struct {
+		suggestion *WAFRuleSuggestion
+		patterns   map[string]bool
+		samples    map[string]bool
+		ips        map[string]bool
+	}
+
+	groups := make(map[string]*group)
+	var order []string
+	for _, threat := range threats {
+		attackType, ok := threat.Type.(WebAttackType)
+		if !ok || !meaningfulPayload(threat.Payload) {
+			continue
+		}
+		path := requestPath(threat.URL)
+		target := payloadTarget(threat)
+		key := fmt.Sprintf("%d|%s|%s", attackType, path, target)
+
+		g, exists := groups[key]
+		if !exists {
+			g = &group{
+				suggestion: &WAFRuleSuggestion{AttackType: attackType, Path: path, Target: target, FirstSeen: threat.Timestamp, LastSeen: threat.Timestamp},
+				patterns:   make(map[string]bool),
+				samples:    make(map[string]bool),
+				ips:        make(map[string]bool),
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		suggestion := g.suggestion
+		suggestion.Hits++
+		if threat.Severity > suggestion.Severity {
+			suggestion.Severity = threat.Severity
+		}
+		if threat.Timestamp.Before(suggestion.FirstSeen) {
+			suggestion.FirstSeen = threat.Timestamp
+		}
+		if threat.Timestamp.After(suggestion.LastSeen) {
+			suggestion.LastSeen = threat.Timestamp
+		}
+		if !g.ips[threat.IP] {
+			g.ips[threat.IP] = true
+			suggestion.IPs++
+		}
+		if threat.Pattern != "" && !g.patterns[threat.Pattern] {
+			g.patterns[threat.Pattern] = true
+			suggestion.Patterns = append(suggestion.Patterns, threat.Pattern)
+		}
+		sample := strings.ToLower(threat.Payload)
+		if !g.samples[sample] && len(suggestion.Samples) < wafMaxSamples {
+			g.samples[sample] = true
+			suggestion.Samples = append(suggestion.Samples, sample)
+		}
+	}
+
+	suggestions := make([]WAFRuleSuggestion, 0, len(order))
+	for _, key := range order {
+		suggestions = append(suggestions, *groups[key].suggestion)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Hits > suggestions[j].Hits
+	})
+	return suggestions
+}
+
+// requestPath returns the path of a logged URL without its query
+func requestPath(rawURL string) string {
+	if parsed, err := url.ParseRequestURI(rawURL); err == nil && parsed.Path != "" {
+		return parsed.Path
+	}
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	if rawURL == "" {
+		return "/"
+	}
+	return rawURL
+}
+
+// payloadTarget tells which request part the payload of a threat was in. The
+// detectors match the URL, user agent and referer together, and only the
+// first two are kept on the threat.
+func payloadTarget(threat EnhancedThreat) string {
+	switch {
+	case strings.Contains(threat.URL, threat.Payload):
+		return wafTargetURI
+	case strings.Contains(threat.UserAgent, threat.Payload):
+		return wafTargetUserAgent
+	default:
+		return wafTargetReferer
+	}
+}
+
+// WriteWAFRules writes the suggestions as rules in the given format
+func WriteWAFRules(w io.Writer, format string, suggestions []WAFRuleSuggestion, generatedAt time.Time) error {
+	switch format {
+	case "modsecurity":
+		return writeModSecurity(w, suggestions, generatedAt)
+	case "cloudflare":
+		return writeCloudflareWAF(w, suggestions, generatedAt)
+	default:
+		return fmt.Errorf("unknown WAF rule format %q (use %s)", format, strings.Join(WAFFormats, ", "))
+	}
+}
+
+// WriteWAFRulesFile writes the suggestions as rules to filename
+func WriteWAFRulesFile(filename, format string, suggestions []WAFRuleSuggestion, generatedAt time.Time) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	if err := WriteWAFRules(buffered, format, suggestions, generatedAt); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// wafHeader returns the warning at the top of generated rules
+func wafHeader(suggestions []WAFRuleSuggestion, generatedAt time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by smart-log-analyser on %s\n", generatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&b, "# %d candidate rule(s) from the attack payloads seen in the logs\n", len(suggestions))
+	b.WriteString("#\n")
+	b.WriteString("# REVIEW BEFORE DEPLOY: these rules are suggestions, not tested policy.\n")
+	b.WriteString("# They may block legitimate requests to the same paths. Run them in a\n")
+	b.WriteString("# detection-only or log mode first and check what they would match.\n")
+	return b.String()
+}
+
+// wafSummary describes the attacks behind a suggestion
+func wafSummary(suggestion WAFRuleSuggestion) string {
+	return fmt.Sprintf("%s on %s (%s): %d hit(s) from %d IP(s), %s to %s, severity %s",
+		suggestion.AttackType, commentSafe(suggestion.Path), suggestion.Target, suggestion.Hits, suggestion.IPs,
+		suggestion.FirstSeen.Format("2006-01-02 15:04"), suggestion.LastSeen.Format("2006-01-02 15:04"), suggestion.Severity)
+}
+
+// writeModSecurity writes a chained SecRule per suggestion: the first rule
+// matches the path, the second the detection patterns in the request part
+func writeModSecurity(w io.Writer, suggestions []WAFRuleSuggestion, generatedAt time.Time) error {
+	var b strings.Builder
+	b.WriteString(wafHeader(suggestions, generatedAt))
+	b.WriteString("# Set SecRuleEngine DetectionOnly to only log matches.\n")
+	for i, suggestion := range suggestions {
+		if len(suggestion.Patterns) == 0 {
+			continue
+		}
+		b.WriteString("\n# REVIEW BEFORE DEPLOY: " + wafSummary(suggestion) + "\n")
+		b.WriteString("#   ATT&CK: " + techniqueList(suggestion.AttackType) + "\n")
+		for _, sample := range suggestion.Samples {
+			b.WriteString("#   payload: " + commentSafe(sample) + "\n")
+		}
+		if hasControl(suggestion.Path) {
+			b.WriteString("# The path has control characters and cannot be matched literally; write this rule by hand\n")
+			continue
+		}
+
+		variable := "REQUEST_URI"
+		switch suggestion.Target {
+		case wafTargetUserAgent:
+			variable = "REQUEST_HEADERS:User-Agent"
+		case wafTargetReferer:
+			variable = "REQUEST_HEADERS:Referer"
+		}
+		msg := fmt.Sprintf("smart-log-analyser: %s on %s", suggestion.AttackType, commentSafe(suggestion.Path))
+		fmt.Fprintf(&b, "SecRule REQUEST_FILENAME \"@streq %s\" \\\n", modSecurityQuote(suggestion.Path))
+		fmt.Fprintf(&b, "    \"id:%d,phase:2,deny,status:403,log,msg:'%s',tag:'smart-log-analyser',tag:'%s',severity:'%s',chain\"\n",
+			wafRuleIDStart+i, actionSafe(msg), actionSafe(modSecurityTag(suggestion.AttackType)), modSecuritySeverity(suggestion.Severity))
+		fmt.Fprintf(&b, "    SecRule %s \"@rx %s\" \"t:none\"\n", variable, modSecurityPattern(suggestion.Patterns))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// modSecurityPattern combines the detection patterns into one regular
+// expression. Each pattern stays in its own group, so inline flags such as
+// (?i) keep their scope. Quotes are written as \x22 so they cannot end the
+// operator argument.
+func modSecurityPattern(patterns []string) string {
+	groups := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		pattern = strings.ReplaceAll(pattern, `\"`, `"`)
+		groups[i] = "(?:" + strings.ReplaceAll(pattern, `"`, `\x22`) + ")"
+	}
+	return strings.Join(groups, "|")
+}
+
+// modSecurityQuote escapes text for a double quoted operator argument
+func modSecurityQuote(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text)
+}
+
+// modSecurityTag returns the OWASP CRS style tag of an attack type
+func modSecurityTag(attackType WebAttackType) string {
+	switch attackType {
+	case SQLInjection:
+		return "attack-sqli"
+	case CrossSiteScripting:
+		return "attack-xss"
+	case CommandInjection:
+		return "attack-rce"
+	case DirectoryTraversal, LocalFileInclusion:
+		return "attack-lfi"
+	case RemoteFileInclusion:
+		return "attack-rfi"
+	case HTTPHeaderInjection, HTTPSplitting:
+		return "attack-protocol"
+	default:
+		return "attack-generic"
+	}
+}
+
+// modSecuritySeverity maps a threat severity to a ModSecurity severity
+func modSecuritySeverity(severity ThreatSeverity) string {
+	switch severity {
+	case SeverityCritical:
+		return "CRITICAL"
+	case SeverityHigh:
+		return "ERROR"
+	case SeverityMedium:
+		return "WARNING"
+	default:
+		return "NOTICE"
+	}
+}
+
+// writeCloudflareWAF writes a Cloudflare WAF custom rule expression per
+// suggestion, matching the lowercased, URL decoded payloads on the path
+func writeCloudflareWAF(w io.Writer, suggestions []WAFRuleSuggestion, generatedAt time.Time) error {
+	var b strings.Builder
+	b.WriteString(wafHeader(suggestions, generatedAt))
+	b.WriteString("# Paste each expression into a custom rule; start with the Log or Managed Challenge action.\n")
+	for _, suggestion := range suggestions {
+		field := "lower(url_decode(http.request.uri))"
+		switch suggestion.Target {
+		case wafTargetUserAgent:
+			field = "lower(http.user_agent)"
+		case wafTargetReferer:
+			field = "lower(http.referer)"
+		}
+
+		var conditions []string
+		seen := make(map[string]bool)
+		for _, sample := range suggestion.Samples {
+			if suggestion.Target == wafTargetURI {
+				if decoded, err := url.QueryUnescape(sample); err == nil {
+					sample = decoded
+				}
+			}
+			if !meaningfulPayload(sample) || seen[sample] || hasControl(sample) {
+				continue
+			}
+			seen[sample] = true
+			conditions = append(conditions, fmt.Sprintf("%s contains %s", field, cloudflareQuote(sample)))
+		}
+
+		b.WriteString("\n# REVIEW BEFORE DEPLOY: " + wafSummary(suggestion) + "\n")
+		b.WriteString("# ATT&CK: " + techniqueList(suggestion.AttackType) + "\n")
+		if len(conditions) == 0 {
+			b.WriteString("# No payload can be matched literally; write this rule by hand\n")
+			continue
+		}
+		if hasControl(suggestion.Path) {
+			b.WriteString("# The path has control characters and cannot be matched literally; write this rule by hand\n")
+			continue
+		}
+		fmt.Fprintf(&b, "(http.request.uri.path eq %s and (%s))\n", cloudflareQuote(suggestion.Path), strings.Join(conditions, " or "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// techniqueList lists the ATT&CK techniques of an attack type
+func techniqueList(attackType WebAttackType) string {
+	techniques := EnhancedThreat{Type: attackType}.MITRETechniques()
+	names := make([]string, len(techniques))
+	for i, technique := range techniques {
+		names[i] = technique.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// cloudflareQuote quotes text as a string in the Cloudflare rules language
+func cloudflareQuote(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+// actionSafe drops the characters that would end a quoted action argument
+func actionSafe(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\'' || r == '"' || r == '\\' || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// meaningfulPayload reports whether a payload, URL decoded, has enough
+// letters and digits to block on
+func meaningfulPayload(payload string) bool {
+	if decoded, err := url.QueryUnescape(payload); err == nil {
+		payload = decoded
+	}
+	alphanumerics := 0
+	for _, r := range payload {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			alphanumerics++
+		}
+	}
+	return alphanumerics >= minPayloadAlphanumerics
+}
+
+// hasControl reports whether text has control characters, which no rule
+// can match literally
+func hasControl(text string) bool {
+	return strings.IndexFunc(text, unicode.IsControl) >= 0
+}
+
+// commentSafe keeps logged text on its comment line
+func commentSafe(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, text)
+}
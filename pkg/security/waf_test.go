@@ -0,0 +1,48 @@
+package security
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWAFRulesSkipPunctuationPayloads(t *testing.T) {
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	threats := []EnhancedThreat{
+		{Type: CommandInjection, Severity: SeverityMedium, Pattern: `(;|\||&)`, URL: "/index.html", UserAgent: chromeUserAgent, Payload: ";", IP: "192.0.2.10", Timestamp: at},
+		{Type: CommandInjection, Severity: SeverityMedium, Pattern: `(;|\||&)`, URL: "/search?a=1&b=2", UserAgent: firefoxUserAgent, Payload: "&", IP: "192.0.2.11", Timestamp: at},
+		{Type: DirectoryTraversal, Severity: SeverityHigh, Pattern: `\.\./`, URL: "/files/..%2f", UserAgent: chromeUserAgent, Payload: "..%2f", IP: "192.0.2.12", Timestamp: at},
+	}
+
+	if suggestions := SuggestWAFRules(threats); len(suggestions) != 0 {
+		t.Fatalf("SuggestWAFRules built %d suggestion(s) from punctuation payloads: %+v", len(suggestions), suggestions)
+	}
+
+	threats = append(threats, EnhancedThreat{Type: DirectoryTraversal, Severity: SeverityHigh, Pattern: `/etc/passwd`,
+		URL: "/files/../../etc/passwd", UserAgent: chromeUserAgent, Payload: "/etc/passwd", IP: "192.0.2.12", Timestamp: at})
+	suggestions := SuggestWAFRules(threats)
+	if len(suggestions) != 1 || len(suggestions[0].Samples) != 1 {
+		t.Fatalf("SuggestWAFRules = %+v, want one suggestion with the /etc/passwd payload", suggestions)
+	}
+	for _, format := range WAFFormats {
+		var b strings.Builder
+		if err := WriteWAFRules(&b, format, suggestions, at); err != nil {
+			t.Fatalf("WriteWAFRules(%s): %v", format, err)
+		}
+		if strings.Contains(b.String(), "User-Agent") || strings.Contains(b.String(), "user_agent") {
+			t.Errorf("%s rules match the user agent:\n%s", format, b.String())
+		}
+	}
+}
+
+func TestBrowserTrafficBuildsNoWAFRules(t *testing.T) {
+	entries := requests([]string{"/index.html", "/search?a=1&b=2", "/?q=cats+%26+dogs"}, chromeUserAgent, firefoxUserAgent, androidUserAgent)
+
+	analysis, err := Analyse(entries, DefaultSecurityConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suggestions := SuggestWAFRules(analysis.Threats); len(suggestions) != 0 {
+		t.Errorf("SuggestWAFRules = %+v, want none for browser traffic", suggestions)
+	}
+}
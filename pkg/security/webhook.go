@@ -0,0 +1,350 @@
+package security
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultWebhookCooldown is how long an alert is held back after the same one
+// was sent, unless it escalated
+const DefaultWebhookCooldown = 15 * time.Minute
+
+// webhookTimeout bounds each webhook request
+const webhookTimeout = 10 * time.Second
+
+// webhookQueueSize is how many alerts wait for a webhook before new ones are
+// dropped
+const webhookQueueSize = 100
+
+// Bounds of the delay before a failed alert is sent again; it doubles with
+// each failure
+const (
+	webhookRetryMin = time.Second
+	webhookRetryMax = 5 * time.Minute
+)
+
+// Alert kinds
+const (
+	AlertThreat    = "threat"
+	AlertRiskLevel = "risk_level"
+)
+
+// RiskLevels are the names accepted by ParseRiskLevel, lowest first
+var RiskLevels = []string{"minimal", "low", "medium", "high", "critical"}
+
+// ParseRiskLevel parses a risk level name such as "high", in any case
+func ParseRiskLevel(name string) (RiskLevel, error) {
+	for i, level := range RiskLevels {
+		if strings.EqualFold(strings.TrimSpace(name), level) {
+			return RiskLevel(i), nil
+		}
+	}
+	return RiskMinimal, fmt.Errorf("unknown risk level %q (use %s)", name, strings.Join(RiskLevels, ", "))
+}
+
+// AlertThresholds decide which alerts are raised: threats at or above
+// MinSeverity, and with RiskAlerts the overall risk level rising to MinRisk
+// or above. The same alert is raised again only with new activity after
+// Cooldown, or sooner when it escalated. Window is how far back the analyses
+// reach; an alert is forgotten once its cooldown passed and its activity is
+// older than that, as it can no longer be raised again without new activity.
+type AlertThresholds struct {
+	MinSeverity ThreatSeverity
+	RiskAlerts  bool
+	MinRisk     RiskLevel
+	Cooldown    time.Duration
+	Window      time.Duration
+}
+
+// Alert is a security alert, POSTed as JSON to the webhooks
+type Alert struct {
+	Kind          string    `json:"kind"` // AlertThreat or AlertRiskLevel
+	GeneratedAt   time.Time `json:"generated_at"`
+	Severity      string    `json:"severity"` // Of the threats, or the risk level
+	Summary       string    `json:"summary"`
+	SecurityScore int       `json:"security_score"`
+	RiskLevel     string    `json:"risk_level"`
+
+	// Set for threat alerts
+	IP          string     `json:"ip,omitempty"`
+	AttackType  string     `json:"attack_type,omitempty"`
+	Threats     int        `json:"threats,omitempty"`
+	URLs        []string   `json:"urls,omitempty"` // Up to five targeted URLs
+	FirstSeen   *time.Time `json:"first_seen,omitempty"`
+	LastSeen    *time.Time `json:"last_seen,omitempty"`
+	Description string     `json:"description,omitempty"`
+}
+
+// alertState is what was last alerted for a key
+type alertState struct {
+	sentAt   time.Time
+	lastSeen time.Time
+	level    int
+}
+
+// Alerter raises alerts from successive analyses of a live log, keeping
+// track of what was already alerted so each alert fires once per activity
+// and cooldown
+type Alerter struct {
+	thresholds AlertThresholds
+	sent       map[string]*alertState
+	riskAbove  bool // The risk level was at or above MinRisk at the last check
+}
+
+// NewAlerter creates an alerter with the thresholds
+func NewAlerter(thresholds AlertThresholds) *Alerter {
+	if thresholds.Cooldown < 0 {
+		thresholds.Cooldown = 0
+	}
+	return &Alerter{thresholds: thresholds, sent: make(map[string]*alertState)}
+}
+
+// Check returns the alerts an analysis raises that were not raised before.
+// Threats alert per IP and attack type, once they have activity newer than
+// the last alert and either the cooldown passed or their severity rose. The
+// risk level alerts when it crosses MinRisk, or rises further, with the same
+// cooldown.
+func (a *Alerter) Check(analysis *EnhancedSecurityAnalysis, now time.Time) []Alert {
+	a.forget(now)
+
+	var alerts []Alert
+	score := analysis.Summary.SecurityScore
+	risk := analysis.Summary.OverallRisk
+
+	type threatGroup struct {
+		ip, attackType string
+		severity       ThreatSeverity
+		threats        int
+		urls           map[string]bool
+		first, last    time.Time
+		description    string
+	}
+	groups := make(map[string]*threatGroup)
+	var keys []string
+	for _, threat := range analysis.Threats {
+		if threat.Severity < a.thresholds.MinSeverity {
+			continue
+		}
+		attackType := threatTypeName(threat)
+		key := AlertThreat + "|" + threat.IP + "|" + attackType
+		group, exists := groups[key]
+		if !exists {
+			group = &threatGroup{ip: threat.IP, attackType: attackType, urls: make(map[string]bool),
+				first: threat.Timestamp, last: threat.Timestamp}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+		group.threats++
+		if threat.Severity > group.severity || group.description == "" {
+			if description, ok := threat.Context["description"].(string); ok && description != "" {
+				group.description = description
+			}
+		}
+		if threat.Severity > group.severity {
+			group.severity = threat.Severity
+		}
+		if threat.URL != "" {
+			group.urls[threat.URL] = true
+		}
+		if threat.Timestamp.Before(group.first) {
+			group.first = threat.Timestamp
+		}
+		if threat.Timestamp.After(group.last) {
+			group.last = threat.Timestamp
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		group := groups[key]
+		if !a.due(key, group.last, int(group.severity), now) {
+			continue
+		}
+		urls := make([]string, 0, len(group.urls))
+		for url := range group.urls {
+			urls = append(urls, url)
+		}
+		sort.Strings(urls)
+		if len(urls) > 5 {
+			urls = urls[:5]
+		}
+		first, last := group.first, group.last
+		alerts = append(alerts, Alert{
+			Kind:          AlertThreat,
+			GeneratedAt:   now,
+			Severity:      group.severity.String(),
+			Summary:       fmt.Sprintf("%s %s from %s (%d threat(s))", group.severity, group.attackType, group.ip, group.threats),
+			SecurityScore: score,
+			RiskLevel:     risk.String(),
+			IP:            group.ip,
+			AttackType:    group.attackType,
+			Threats:       group.threats,
+			URLs:          urls,
+			FirstSeen:     &first,
+			LastSeen:      &last,
+			Description:   group.description,
+		})
+	}
+
+	above := a.thresholds.RiskAlerts && risk >= a.thresholds.MinRisk
+	if above {
+		state, exists := a.sent[AlertRiskLevel]
+		crossed := !a.riskAbove && (!exists || now.Sub(state.sentAt) >= a.thresholds.Cooldown)
+		if crossed || (exists && int(risk) > state.level) {
+			a.sent[AlertRiskLevel] = &alertState{sentAt: now, level: int(risk)}
+			alerts = append(alerts, Alert{
+				Kind:          AlertRiskLevel,
+				GeneratedAt:   now,
+				Severity:      risk.String(),
+				Summary:       fmt.Sprintf("Risk level %s (security score %d/100)", risk, score),
+				SecurityScore: score,
+				RiskLevel:     risk.String(),
+			})
+		}
+	}
+	a.riskAbove = above
+	return alerts
+}
+
+// forget drops the threat alerts whose cooldown passed and whose activity is
+// out of the window, so a long watch does not accumulate every alert it raised
+func (a *Alerter) forget(now time.Time) {
+	if a.thresholds.Window <= 0 {
+		return
+	}
+	for key, state := range a.sent {
+		if key == AlertRiskLevel {
+			continue
+		}
+		if now.Sub(state.sentAt) >= a.thresholds.Cooldown && now.Sub(state.lastSeen) > a.thresholds.Window {
+			delete(a.sent, key)
+		}
+	}
+}
+
+// due reports whether the alert of a key should fire for activity up to
+// lastSeen at a level, and records it if so
+func (a *Alerter) due(key string, lastSeen time.Time, level int, now time.Time) bool {
+	state, exists := a.sent[key]
+	if exists {
+		if !lastSeen.After(state.lastSeen) {
+			return false
+		}
+		if level <= state.level && now.Sub(state.sentAt) < a.thresholds.Cooldown {
+			return false
+		}
+	}
+	a.sent[key] = &alertState{sentAt: now, lastSeen: lastSeen, level: level}
+	return true
+}
+
+// Webhook POSTs alerts as JSON to a URL
+type Webhook struct {
+	URL    string
+	client *http.Client
+}
+
+// NewWebhook creates a webhook for an http or https URL
+func NewWebhook(url string) (*Webhook, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("webhook URL %q must start with http:// or https://", url)
+	}
+	return &Webhook{URL: url, client: &http.Client{Timeout: webhookTimeout}}, nil
+}
+
+// WebhookQueue delivers alerts to a webhook in the background, so a slow or
+// unreachable endpoint does not hold up the caller. An alert that fails is
+// sent again, with a growing delay, until it is delivered; the alerts queued
+// behind it wait, and new ones are dropped once webhookQueueSize are waiting.
+type WebhookQueue struct {
+	webhook *Webhook
+	alerts  chan Alert
+	stop    chan struct{}
+	onError func(err error, retryIn time.Duration)
+}
+
+// NewWebhookQueue starts delivering alerts to the webhook. onError is called,
+// from the queue's goroutine, for each failed attempt.
+func NewWebhookQueue(webhook *Webhook, onError func(err error, retryIn time.Duration)) *WebhookQueue {
+	q := &WebhookQueue{
+		webhook: webhook,
+		alerts:  make(chan Alert, webhookQueueSize),
+		stop:    make(chan struct{}),
+		onError: onError,
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue queues an alert for delivery without waiting for it; false when
+// the queue is full and the alert was dropped
+func (q *WebhookQueue) Enqueue(alert Alert) bool {
+	select {
+	case q.alerts <- alert:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops the delivery; alerts still queued are not sent
+func (q *WebhookQueue) Close() {
+	close(q.stop)
+}
+
+func (q *WebhookQueue) run() {
+	for {
+		select {
+		case <-q.stop:
+			return
+		case alert := <-q.alerts:
+			retryIn := webhookRetryMin
+			for {
+				err := q.webhook.Send(alert)
+				if err == nil {
+					break
+				}
+				if q.onError != nil {
+					q.onError(err, retryIn)
+				}
+				select {
+				case <-q.stop:
+					return
+				case <-time.After(retryIn):
+				}
+				if retryIn *= 2; retryIn > webhookRetryMax {
+					retryIn = webhookRetryMax
+				}
+			}
+		}
+	}
+}
+
+// Send POSTs an alert; a response other than 2xx is an error
+func (w *Webhook) Send(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "smart-log-analyser")
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", w.URL, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook %s: %s", w.URL, response.Status)
+	}
+	return nil
+}
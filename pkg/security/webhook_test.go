@@ -0,0 +1,50 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBrowserTrafficSendsNoWebhookAlerts(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+	webhook, err := NewWebhook(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The lowest thresholds security watch takes, over successive windows.
+	// Each window holds more than ten clients, as one client with over a
+	// tenth of the traffic is an anomaly of its own.
+	alerter := NewAlerter(AlertThresholds{MinSeverity: SeverityInfo, RiskAlerts: true, MinRisk: RiskLow, Cooldown: time.Minute, Window: 15 * time.Minute})
+	entries := requests([]string{
+		"/index.html",
+		"/search?a=1&b=2",
+		"/search?q=a%3Bb%26c%3Dd",
+		"/search?q=android%20phone&sort=price",
+	}, chromeUserAgent, firefoxUserAgent, androidUserAgent, iPhoneUserAgent)
+	now := time.Date(2026, 10, 18, 12, 5, 0, 0, time.UTC)
+	for end := 12; end <= len(entries); end += 4 {
+		analysis, err := Analyse(entries[:end], DefaultSecurityConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, alert := range alerter.Check(analysis, now) {
+			t.Errorf("browser traffic raised an alert: %s", alert.Summary)
+			if err := webhook.Send(alert); err != nil {
+				t.Fatal(err)
+			}
+		}
+		now = now.Add(10 * time.Second)
+	}
+
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("webhook called %d time(s) for browser traffic, want 0", n)
+	}
+}
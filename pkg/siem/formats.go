@@ -0,0 +1,339 @@
+package siem
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/mitre"
+)
+
+// writeCEF writes an ArcSight Common Event Format line per event
+func writeCEF(w io.Writer, events []Event, options Options) error {
+	var b strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|", cefHeader(vendor), cefHeader(product), cefHeader(version),
+			cefHeader(signatureID(event)), cefHeader(event.Name), severityLevel(event.Severity))
+
+		var fields []string
+		add := func(key, value string) {
+			if value != "" {
+				fields = append(fields, key+"="+cefValue(value))
+			}
+		}
+		add("rt", strconv.FormatInt(event.Start.UnixMilli(), 10))
+		if !event.End.IsZero() {
+			add("end", strconv.FormatInt(event.End.UnixMilli(), 10))
+		}
+		add("cat", event.Kind)
+		add("externalId", event.ID)
+		if event.Count > 0 {
+			add("cnt", strconv.Itoa(event.Count))
+		}
+		add("dvchost", options.Hostname)
+		add("src", event.SourceIP)
+		add("request", event.URL)
+		add("requestMethod", event.Method)
+		add("requestClientApplication", event.UserAgent)
+		if event.Status > 0 {
+			add("cn1Label", "HTTP Status")
+			add("cn1", strconv.Itoa(event.Status))
+		}
+		if event.Bytes > 0 {
+			add("out", strconv.FormatInt(event.Bytes, 10))
+		}
+		if event.Confidence > 0 {
+			add("cfp1Label", "Confidence")
+			add("cfp1", strconv.FormatFloat(event.Confidence, 'f', 2, 64))
+		}
+		if event.Payload != "" {
+			add("cs1Label", "Payload")
+			add("cs1", event.Payload)
+		}
+		if len(event.Techniques) > 0 {
+			add("cs2Label", "MITRE ATT&CK Techniques")
+			add("cs2", strings.Join(mitre.IDs(event.Techniques), ","))
+		}
+		if len(event.IOCs) > 0 {
+			add("cs3Label", "IOCs")
+			add("cs3", strings.Join(event.IOCs, "; "))
+		}
+		add("msg", event.Description)
+		b.WriteString(strings.Join(fields, " ") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// cefHeader escapes a CEF header field
+func cefHeader(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ").Replace(text)
+}
+
+// cefValue escapes a CEF extension value
+func cefValue(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`).Replace(text)
+}
+
+// LEEF version and the devTime format of its events, written in UTC
+const (
+	leefTimeFormat   = "MMM dd yyyy HH:mm:ss.SSS zzz"
+	leefTimeLayout   = "Jan 02 2006 15:04:05.000 MST"
+	leefEventVersion = "1.0"
+)
+
+// writeLEEF writes a QRadar Log Event Extended Format 1.0 line per event,
+// with tab separated attributes
+func writeLEEF(w io.Writer, events []Event, options Options) error {
+	var b strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&b, "LEEF:%s|%s|%s|%s|%s|", leefEventVersion, leefHeader(vendor), leefHeader(product), leefHeader(version),
+			leefHeader(signatureID(event)))
+
+		var fields []string
+		add := func(key, value string) {
+			if value != "" {
+				fields = append(fields, key+"="+leefValue(value))
+			}
+		}
+		add("devTime", event.Start.UTC().Format(leefTimeLayout))
+		add("devTimeFormat", leefTimeFormat)
+		if !event.End.IsZero() {
+			add("endTime", event.End.UTC().Format(leefTimeLayout))
+		}
+		add("cat", event.Kind)
+		add("sev", strconv.Itoa(severityLevel(event.Severity)))
+		add("eventName", event.Name)
+		add("externalId", event.ID)
+		if event.Count > 0 {
+			add("count", strconv.Itoa(event.Count))
+		}
+		add("identHostName", options.Hostname)
+		add("src", event.SourceIP)
+		add("url", event.URL)
+		add("httpMethod", event.Method)
+		if event.Status > 0 {
+			add("httpStatus", strconv.Itoa(event.Status))
+		}
+		if event.Bytes > 0 {
+			add("dstBytes", strconv.FormatInt(event.Bytes, 10))
+		}
+		add("userAgent", event.UserAgent)
+		if event.Confidence > 0 {
+			add("confidence", strconv.FormatFloat(event.Confidence, 'f', 2, 64))
+		}
+		add("payload", event.Payload)
+		add("mitreTechniques", strings.Join(mitre.IDs(event.Techniques), ","))
+		add("iocs", strings.Join(event.IOCs, "; "))
+		add("msg", event.Description)
+		b.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// leefHeader keeps a LEEF header field from ending early
+func leefHeader(text string) string {
+	return strings.NewReplacer("|", "", "\t", " ", "\r", " ", "\n", " ").Replace(text)
+}
+
+// leefValue keeps a LEEF attribute value on its line and in its attribute
+func leefValue(text string) string {
+	return strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(text)
+}
+
+// ecsVersion is the Elastic Common Schema version of the events
+const ecsVersion = "8.11.0"
+
+// ecsEvent is an Elastic Common Schema document
+type ecsEvent struct {
+	Timestamp time.Time     `json:"@timestamp"`
+	Message   string        `json:"message,omitempty"`
+	ECS       ecsVersionDoc `json:"ecs"`
+	Event     ecsEventDoc   `json:"event"`
+	Rule      ecsRule       `json:"rule"`
+	Observer  ecsObserver   `json:"observer"`
+	Source    *ecsSource    `json:"source,omitempty"`
+	URL       *ecsURL       `json:"url,omitempty"`
+	HTTP      *ecsHTTP      `json:"http,omitempty"`
+	UserAgent *ecsUserAgent `json:"user_agent,omitempty"`
+	Threat    *ecsThreat    `json:"threat,omitempty"`
+	Labels    ecsLabels     `json:"labels,omitempty"`
+}
+
+type ecsVersionDoc struct {
+	Version string `json:"version"`
+}
+
+type ecsEventDoc struct {
+	ID       string     `json:"id,omitempty"`
+	Kind     string     `json:"kind"`
+	Category []string   `json:"category"`
+	Type     []string   `json:"type"`
+	Dataset  string     `json:"dataset"`
+	Module   string     `json:"module"`
+	Severity int        `json:"severity"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+}
+
+type ecsRule struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type ecsObserver struct {
+	Vendor   string `json:"vendor"`
+	Product  string `json:"product"`
+	Version  string `json:"version"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+type ecsSource struct {
+	IP string `json:"ip"`
+}
+
+type ecsURL struct {
+	Original string `json:"original"`
+	Path     string `json:"path,omitempty"`
+	Query    string `json:"query,omitempty"`
+}
+
+type ecsHTTP struct {
+	Request  *ecsHTTPRequest  `json:"request,omitempty"`
+	Response *ecsHTTPResponse `json:"response,omitempty"`
+}
+
+type ecsHTTPRequest struct {
+	Method string `json:"method"`
+}
+
+type ecsHTTPResponse struct {
+	StatusCode int          `json:"status_code,omitempty"`
+	Body       *ecsHTTPBody `json:"body,omitempty"`
+}
+
+type ecsHTTPBody struct {
+	Bytes int64 `json:"bytes"`
+}
+
+type ecsUserAgent struct {
+	Original string `json:"original"`
+}
+
+type ecsThreat struct {
+	Framework string        `json:"framework"`
+	Technique ecsNamedIDs   `json:"technique"`
+	Tactic    ecsNamedNames `json:"tactic"`
+}
+
+type ecsNamedIDs struct {
+	ID        []string `json:"id"`
+	Name      []string `json:"name"`
+	Reference []string `json:"reference"`
+}
+
+type ecsNamedNames struct {
+	Name []string `json:"name"`
+}
+
+type ecsLabels map[string]string
+
+// writeECS writes an Elastic Common Schema document per line (NDJSON)
+func writeECS(w io.Writer, events []Event, options Options) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, event := range events {
+		if err := encoder.Encode(ecsDocument(event, options)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ecsDocument maps an event to ECS: threats and anomalies are alerts, and
+// incidents are signals correlating them
+func ecsDocument(event Event, options Options) ecsEvent {
+	kind := "alert"
+	if event.Kind == KindIncident {
+		kind = "signal"
+	}
+	doc := ecsEvent{
+		Timestamp: event.Start,
+		Message:   event.Description,
+		ECS:       ecsVersionDoc{Version: ecsVersion},
+		Event: ecsEventDoc{
+			ID:       event.ID,
+			Kind:     kind,
+			Category: []string{"intrusion_detection", "web"},
+			Type:     []string{"indicator"},
+			Dataset:  "smart_log_analyser." + event.Kind,
+			Module:   "smart_log_analyser",
+			Severity: severityLevel(event.Severity),
+			Start:    event.Start,
+		},
+		Rule:     ecsRule{ID: signatureID(event), Name: event.Name, Description: event.Description},
+		Observer: ecsObserver{Vendor: vendor, Product: product, Version: version, Hostname: options.Hostname},
+		Labels:   ecsLabels{"severity": event.Severity.String()},
+	}
+	if !event.End.IsZero() {
+		end := event.End
+		doc.Event.End = &end
+	}
+	if event.Confidence > 0 {
+		doc.Labels["confidence"] = strconv.FormatFloat(event.Confidence, 'f', 2, 64)
+	}
+	if event.Payload != "" {
+		doc.Labels["payload"] = event.Payload
+	}
+	if event.Count > 0 {
+		doc.Labels["count"] = strconv.Itoa(event.Count)
+	}
+	if event.SourceIP != "" {
+		doc.Source = &ecsSource{IP: event.SourceIP}
+	}
+	if event.URL != "" {
+		doc.URL = &ecsURL{Original: event.URL}
+		if parsed, err := url.ParseRequestURI(event.URL); err == nil {
+			doc.URL.Path = parsed.Path
+			doc.URL.Query = parsed.RawQuery
+		}
+	}
+	if event.Method != "" || event.Status > 0 {
+		doc.HTTP = &ecsHTTP{}
+		if event.Method != "" {
+			doc.HTTP.Request = &ecsHTTPRequest{Method: event.Method}
+		}
+		if event.Status > 0 {
+			doc.HTTP.Response = &ecsHTTPResponse{StatusCode: event.Status}
+			if event.Bytes > 0 {
+				doc.HTTP.Response.Body = &ecsHTTPBody{Bytes: event.Bytes}
+			}
+		}
+	}
+	if event.UserAgent != "" {
+		doc.UserAgent = &ecsUserAgent{Original: event.UserAgent}
+	}
+	if len(event.IOCs) > 0 {
+		doc.Labels["iocs"] = strings.Join(event.IOCs, "; ")
+	}
+	if len(event.Techniques) > 0 {
+		doc.Threat = &ecsThreat{Framework: "MITRE ATT&CK"}
+		tactics := make(map[string]bool)
+		for _, technique := range event.Techniques {
+			doc.Threat.Technique.ID = append(doc.Threat.Technique.ID, technique.ID)
+			doc.Threat.Technique.Name = append(doc.Threat.Technique.Name, technique.Name)
+			doc.Threat.Technique.Reference = append(doc.Threat.Technique.Reference, technique.URL())
+			if !tactics[technique.Tactic] {
+				tactics[technique.Tactic] = true
+				doc.Threat.Tactic.Name = append(doc.Threat.Tactic.Name, technique.Tactic)
+			}
+		}
+	}
+	return doc
+}
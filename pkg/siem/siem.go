@@ -0,0 +1,269 @@
+// Package siem exports the threats, anomalies and incidents of a security
+// analysis as CEF, LEEF or Elastic Common Schema events, to feed Splunk,
+// QRadar or Elastic pipelines
+package siem
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/mitre"
+	"smart-log-analyser/pkg/security"
+)
+
+// Device fields of the CEF and LEEF headers and the ECS observer
+const (
+	vendor  = "smart-log-analyser"
+	product = "Smart Log Analyser"
+	version = "1.0"
+)
+
+// Event kinds
+const (
+	KindThreat   = "threat"
+	KindAnomaly  = "anomaly"
+	KindIncident = "incident"
+)
+
+// Formats lists the SIEM event formats
+var Formats = []string{"cef", "leef", "ecs"}
+
+// Event is a threat, anomaly or incident in the form the formats share
+type Event struct {
+	Kind        string
+	ID          string
+	Name        string // Attack type, anomaly type or incident title
+	Severity    security.ThreatSeverity
+	Confidence  float64 // 0.0-1.0, zero for incidents
+	Start       time.Time
+	End         time.Time // Zero unless the event spans a period
+	SourceIP    string
+	URL         string
+	Method      string
+	Status      int
+	Bytes       int64
+	UserAgent   string
+	Payload     string
+	Description string
+	Techniques  []mitre.Technique
+	IOCs        []string
+	Count       int // Threats an aggregated threat event stands for, zero otherwise
+}
+
+// Options control the exported events
+type Options struct {
+	Hostname string // Host the logs came from, reported as the device host
+}
+
+// FormatFromFilename picks the event format from a file extension: .cef,
+// .leef, or .json and .ndjson for ECS
+func FormatFromFilename(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".cef":
+		return "cef", nil
+	case ".leef":
+		return "leef", nil
+	case ".json", ".ndjson":
+		return "ecs", nil
+	default:
+		return "", fmt.Errorf("cannot tell the SIEM format of %s: use a .cef, .leef, .json or .ndjson extension, or choose one of %s",
+			filename, strings.Join(Formats, ", "))
+	}
+}
+
+// Events returns the threats, anomalies and incidents of the analysis in
+// time order
+func Events(analysis *security.EnhancedSecurityAnalysis) []Event {
+	var events []Event
+	for _, threat := range analysis.Threats {
+		events = append(events, threatEvent(threat))
+	}
+	return inTimeOrder(append(events, otherEvents(analysis)...))
+}
+
+// AggregatedEvents is Events with one event per group of near-identical
+// threats (see security.AggregateThreats), spanning the group's first and
+// last sighting
+func AggregatedEvents(analysis *security.EnhancedSecurityAnalysis) []Event {
+	var events []Event
+	for _, group := range security.AggregateThreats(analysis.Threats) {
+		event := threatEvent(group.Threat)
+		event.Start = group.FirstSeen
+		if group.Count > 1 {
+			event.End = group.LastSeen
+		}
+		event.Count = group.Count
+		events = append(events, event)
+	}
+	return inTimeOrder(append(events, otherEvents(analysis)...))
+}
+
+// threatEvent returns the event of a threat
+func threatEvent(threat security.EnhancedThreat) Event {
+	return Event{
+		Kind:        KindThreat,
+		ID:          threat.ID,
+		Name:        threatName(threat),
+		Severity:    threat.Severity,
+		Confidence:  threat.Confidence,
+		Start:       threat.Timestamp,
+		SourceIP:    threat.IP,
+		URL:         threat.URL,
+		Method:      threat.Method,
+		Status:      threat.StatusCode,
+		Bytes:       threat.ResponseSize,
+		UserAgent:   threat.UserAgent,
+		Payload:     threat.Payload,
+		Description: threatDescription(threat),
+		Techniques:  threat.MITRETechniques(),
+	}
+}
+
+// otherEvents returns the anomalies and incidents of the analysis
+func otherEvents(analysis *security.EnhancedSecurityAnalysis) []Event {
+	var events []Event
+	for _, anomaly := range analysis.Anomalies {
+		events = append(events, Event{
+			Kind:        KindAnomaly,
+			ID:          anomaly.ID,
+			Name:        anomaly.Type.String(),
+			Severity:    anomaly.Severity,
+			Confidence:  anomaly.Confidence,
+			Start:       anomaly.Timestamp,
+			SourceIP:    anomaly.IP,
+			Description: anomaly.Description,
+		})
+	}
+	for _, incident := range analysis.Incidents {
+		events = append(events, Event{
+			Kind:        KindIncident,
+			ID:          incident.ID,
+			Name:        incident.Title,
+			Severity:    incident.Severity,
+			Start:       incident.StartTime,
+			End:         incident.EndTime,
+			SourceIP:    incidentSourceIP(incident),
+			Description: incident.Impact,
+			IOCs:        incident.IOCs,
+		})
+	}
+	return events
+}
+
+// inTimeOrder sorts the events by start time, keeping the order of equals
+func inTimeOrder(events []Event) []Event {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Start.Before(events[j].Start)
+	})
+	return events
+}
+
+func threatName(threat security.EnhancedThreat) string {
+	if name, ok := threat.Type.(fmt.Stringer); ok {
+		return name.String()
+	}
+	return "Unknown Threat"
+}
+
+// threatDescription uses the detector's description of the match when it
+// has one
+func threatDescription(threat security.EnhancedThreat) string {
+	if description, ok := threat.Context["description"].(string); ok && description != "" {
+		return description
+	}
+	return threatName(threat) + " via " + threat.AttackVector
+}
+
+// incidentSourceIP takes the source IP from the incident's IOCs, which list
+// each attacking IP as "IP: address"; empty when there are several
+func incidentSourceIP(incident security.IncidentData) string {
+	ip := ""
+	for _, ioc := range incident.IOCs {
+		if strings.HasPrefix(ioc, "IP: ") {
+			if ip != "" {
+				return ""
+			}
+			ip = strings.TrimPrefix(ioc, "IP: ")
+		}
+	}
+	return ip
+}
+
+// Write writes the events in the given format, one per line
+func Write(w io.Writer, format string, events []Event, options Options) error {
+	switch format {
+	case "cef":
+		return writeCEF(w, events, options)
+	case "leef":
+		return writeLEEF(w, events, options)
+	case "ecs":
+		return writeECS(w, events, options)
+	default:
+		return fmt.Errorf("unknown SIEM format %q (use %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteFile writes the events to filename
+func WriteFile(filename, format string, events []Event, options Options) error {
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	if err := Write(buffered, format, events, options); err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// severityLevel maps a severity to the 0-10 scale of CEF and LEEF
+func severityLevel(severity security.ThreatSeverity) int {
+	switch severity {
+	case security.SeverityCritical:
+		return 10
+	case security.SeverityHigh:
+		return 8
+	case security.SeverityMedium:
+		return 5
+	case security.SeverityLow:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// signatureID identifies the kind of event, e.g. "threat:sql-injection"
+func signatureID(event Event) string {
+	name := event.Name
+	if event.Kind == KindIncident {
+		name = "correlated"
+	}
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return event.Kind + ":" + strings.TrimSuffix(b.String(), "-")
+}
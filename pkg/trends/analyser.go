@@ -43,6 +43,7 @@ func (ta *TrendAnalyser) ComparePeriods(baselineLogs, currentLogs []*parser.LogE
 	
 	// Calculate trend changes
 	trendChanges := ta.calculateTrendChanges(baselineMetrics, currentMetrics)
+	securityChanges := ta.calculateSecurityChanges(baselineMetrics, currentMetrics)
 	
 	// Determine overall trend
 	overallTrend := ta.calculateOverallTrend(trendChanges)
@@ -54,12 +55,14 @@ func (ta *TrendAnalyser) ComparePeriods(baselineLogs, currentLogs []*parser.LogE
 	summary := ta.generateComparisonSummary(overallTrend, riskScore, trendChanges)
 	
 	return &PeriodComparison{
-		BaselinePeriod: baselineMetrics,
-		CurrentPeriod:  currentMetrics,
-		TrendChanges:   trendChanges,
-		OverallTrend:   overallTrend,
-		RiskScore:      riskScore,
-		Summary:        summary,
+		BaselinePeriod:  baselineMetrics,
+		CurrentPeriod:   currentMetrics,
+		TrendChanges:    trendChanges,
+		OverallTrend:    overallTrend,
+		SecurityChanges: securityChanges,
+		SecurityTrend:   ta.calculateOverallTrend(securityChanges),
+		RiskScore:       riskScore,
+		Summary:         summary,
 	}, nil
 }
 
@@ -81,13 +84,16 @@ func (ta *TrendAnalyser) DetectDegradation(logs []*parser.LogEntry) (*TrendAnaly
 	}
 	
 	// Detect specific degradation alerts
-	alerts := ta.generateDegradationAlerts(comparison.TrendChanges)
+	alerts := ta.generateDegradationAlerts(comparison)
 	
 	// Determine overall health
 	overallHealth := ta.calculateOverallHealth(alerts, comparison.RiskScore)
 	
+	// Track the busiest endpoints individually
+	endpointTrends := ta.compareEndpoints(comparison.BaselinePeriod.Endpoints, comparison.CurrentPeriod.Endpoints)
+	
 	// Generate recommendations
-	recommendations := ta.generateRecommendations(alerts, comparison.TrendChanges)
+	recommendations := ta.generateRecommendations(alerts, comparison.TrendChanges, endpointTrends)
 	
 	// Create trend summary
 	trendSummary := ta.generateTrendSummary(comparison, alerts)
@@ -97,6 +103,7 @@ func (ta *TrendAnalyser) DetectDegradation(logs []*parser.LogEntry) (*TrendAnaly
 		GeneratedAt:       time.Now(),
 		PeriodComparisons: []PeriodComparison{*comparison},
 		DegradationAlerts: alerts,
+		EndpointTrends:    endpointTrends,
 		OverallHealth:     overallHealth,
 		Recommendations:   recommendations,
 		TrendSummary:      trendSummary,
@@ -106,7 +113,7 @@ func (ta *TrendAnalyser) DetectDegradation(logs []*parser.LogEntry) (*TrendAnaly
 // convertToPeriodMetrics converts analyser.Results to PeriodMetrics
 func (ta *TrendAnalyser) convertToPeriodMetrics(periodName string, results *analyser.Results) PeriodMetrics {
 	// Calculate error rate
-	totalErrors := results.StatusCodes["4xx"] + results.StatusCodes["5xx"]
+	totalErrors := results.StatusCodes["4xx Client Error"] + results.StatusCodes["5xx Server Error"]
 	errorRate := 0.0
 	if results.TotalRequests > 0 {
 		errorRate = (float64(totalErrors) / float64(results.TotalRequests)) * 100
@@ -148,6 +155,8 @@ func (ta *TrendAnalyser) convertToPeriodMetrics(periodName string, results *anal
 		TopErrorURLs:         results.ErrorURLs,
 		BotTrafficPercent:    botTrafficPercent,
 		GeographicDistrib:    geoDistrib,
+		Endpoints:            endpointMetrics(results),
+		Security:             securityMetrics(results),
 	}
 }
 
@@ -222,23 +231,24 @@ func (ta *TrendAnalyser) calculateMetricChange(metricName string, oldValue, newV
 	absPercentChange := math.Abs(percentChange)
 	
 	// Determine direction based on metric type and change
-	if metricName == "Error Rate" || metricName == "Average Response Size" {
-		// Higher is worse for these metrics
-		if percentChange > 5 {
+	minimumChange := ta.config.MinimumChange
+	if higherIsWorse(metricName) {
+		// Higher is worse for errors, response sizes and attacks
+		if percentChange > minimumChange {
 			direction = TrendDegrading
-			if percentChange > ta.config.ErrorRateThreshold {
+			if percentChange > ta.getThresholdForMetric(metricName) {
 				direction = TrendCritical
 			}
-		} else if percentChange < -5 {
+		} else if percentChange < -minimumChange {
 			direction = TrendImproving
 		}
 	} else {
-		// Higher is better for volume/traffic metrics
-		if percentChange > 5 {
+		// Higher is better for volume/traffic metrics and the security score
+		if percentChange > minimumChange {
 			direction = TrendImproving
-		} else if percentChange < -5 {
+		} else if percentChange < -minimumChange {
 			direction = TrendDegrading
-			if absPercentChange > ta.config.TrafficDropThreshold {
+			if absPercentChange > ta.getThresholdForMetric(metricName) {
 				direction = TrendCritical
 			}
 		}
@@ -329,11 +339,15 @@ func (ta *TrendAnalyser) calculateOverallTrend(changes []TrendChange) TrendDirec
 	return TrendStable
 }
 
-// calculateRiskScore calculates an overall risk score (0-100)
+// calculateRiskScore calculates an overall risk score (0-100) from the
+// watched metrics
 func (ta *TrendAnalyser) calculateRiskScore(changes []TrendChange) int {
 	riskScore := 0
 	
 	for _, change := range changes {
+		if !ta.watchesMetric(change.MetricName) {
+			continue
+		}
 		if change.Direction == TrendDegrading || change.Direction == TrendCritical {
 			risk := int(math.Abs(change.PercentChange))
 			
@@ -400,12 +414,24 @@ func (ta *TrendAnalyser) generateComparisonSummary(trend TrendDirection, riskSco
 	return summary
 }
 
-// generateDegradationAlerts creates alerts for detected degradation
-func (ta *TrendAnalyser) generateDegradationAlerts(changes []TrendChange) []DegradationAlert {
+// generateDegradationAlerts creates alerts for detected degradation of the
+// watched metrics, when both periods have enough requests to be meaningful
+func (ta *TrendAnalyser) generateDegradationAlerts(comparison *PeriodComparison) []DegradationAlert {
 	var alerts []DegradationAlert
 	alertID := 1
 	
+	if !ta.config.EnableAlerts ||
+		comparison.BaselinePeriod.TotalRequests < ta.config.MinimumAlertRequests ||
+		comparison.CurrentPeriod.TotalRequests < ta.config.MinimumAlertRequests {
+		return alerts
+	}
+	
+	changes := append([]TrendChange{}, comparison.TrendChanges...)
+	changes = append(changes, alertingSecurityChanges(comparison.SecurityChanges)...)
 	for _, change := range changes {
+		if !ta.watchesMetric(change.MetricName) {
+			continue
+		}
 		if change.Direction == TrendDegrading || change.Direction == TrendCritical {
 			severity := "warning"
 			if change.Direction == TrendCritical || change.Significance == "high" {
@@ -439,9 +465,16 @@ func (ta *TrendAnalyser) getThresholdForMetric(metricName string) float64 {
 		return ta.config.ErrorRateThreshold
 	case "Average Response Size":
 		return ta.config.ResponseTimeThreshold
-	case "Traffic Volume", "Request Volume", "Unique Visitors":
+	case "Traffic Volume", "Request Volume", "Unique Visitors", "Bot Traffic":
 		return ta.config.TrafficDropThreshold
+	case "Security Score":
+		return securityScoreDrop
+	case "Threat Rate", "Suspicious IPs":
+		return threatRateRise
 	default:
+		if isSecurityMetric(metricName) {
+			return threatRateRise
+		}
 		return 10.0 // Default 10% threshold
 	}
 }
@@ -472,6 +505,16 @@ func (ta *TrendAnalyser) getImpactDescription(metricName string, percentChange f
 			return "Significant traffic reduction"
 		}
 		return "Moderate traffic changes"
+	case "Security Score":
+		if absChange > 30 {
+			return "Security posture has weakened substantially"
+		}
+		return "Security posture has weakened"
+	case "Threat Rate":
+		if absChange > 100 {
+			return "Attack pressure has more than doubled"
+		}
+		return "Attack pressure is rising"
 	default:
 		return "Impact requires investigation"
 	}
@@ -491,6 +534,11 @@ func (ta *TrendAnalyser) getRecommendation(metricName string, direction TrendDir
 		return "Investigate traffic sources, check for outages or routing issues"
 	case "Unique Visitors":
 		return "Analyze user behavior patterns and check marketing campaigns"
+	case "Security Score", "Threat Rate":
+		if direction == TrendCritical {
+			return "Review the top attackers and block or rate limit them"
+		}
+		return "Review the security findings and tighten WAF and rate limiting rules"
 	default:
 		return "Monitor metric closely and investigate root causes"
 	}
@@ -514,7 +562,7 @@ func (ta *TrendAnalyser) calculateOverallHealth(alerts []DegradationAlert, riskS
 }
 
 // generateRecommendations creates actionable recommendations
-func (ta *TrendAnalyser) generateRecommendations(alerts []DegradationAlert, changes []TrendChange) []string {
+func (ta *TrendAnalyser) generateRecommendations(alerts []DegradationAlert, changes []TrendChange, endpointTrends []EndpointTrend) []string {
 	recommendations := make(map[string]bool) // Use map to avoid duplicates
 	
 	// Add recommendations from alerts
@@ -527,6 +575,11 @@ func (ta *TrendAnalyser) generateRecommendations(alerts []DegradationAlert, chan
 		recommendations["Perform comprehensive system health check"] = true
 	}
 	
+	// Point at the worst endpoint; endpoint trends are sorted worst first
+	if len(endpointTrends) > 0 && endpointTrends[0].Direction != TrendImproving {
+		recommendations[fmt.Sprintf("Investigate %s first, the endpoint that degraded most against the baseline", endpointTrends[0].Endpoint)] = true
+	}
+	
 	// Convert map to slice
 	var result []string
 	for rec := range recommendations {
@@ -560,5 +613,9 @@ func (ta *TrendAnalyser) generateTrendSummary(comparison *PeriodComparison, aler
 		summary += "No significant changes detected in key metrics."
 	}
 	
+	if len(comparison.SecurityChanges) > 0 {
+		summary += fmt.Sprintf(" Security posture is %s.", comparison.SecurityTrend.String())
+	}
+	
 	return summary
 }
\ No newline at end of file
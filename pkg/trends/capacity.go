@@ -0,0 +1,232 @@
+package trends
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// Capacity projection settings
+const (
+	capacityMinRuns      = 3  // Runs, including this one, needed to fit a growth rate
+	capacityCriticalDays = 30 // Projected days until a limit that are critical
+	capacityWarningDays  = 90 // Projected days until a limit that are a warning
+)
+
+// Capacity resources
+const (
+	ResourceRPS       = "Peak RPS"
+	ResourceBandwidth = "Peak Bandwidth"
+)
+
+// CapacityMetrics are the peak load of one analysis run
+type CapacityMetrics struct {
+	PeakRPS       float64 `json:"peak_rps"`       // Highest 10 second average request rate
+	PeakBandwidth float64 `json:"peak_bandwidth"` // Bytes per second in the busiest minute
+}
+
+// CapacityLimits are the load the servers can handle; zero limits are not
+// projected
+type CapacityLimits struct {
+	MaxRPS       float64 // Requests per second
+	MaxBandwidth float64 // Bytes per second
+}
+
+// CapacityProjection projects the growth of the peak load of a resource to
+// the day it reaches its limit
+type CapacityProjection struct {
+	Resource       string    `json:"resource"`            // ResourceRPS or ResourceBandwidth
+	Limit          float64   `json:"limit"`               // Requests or bytes per second
+	Current        float64   `json:"current"`             // Peak load of this run
+	Utilization    float64   `json:"utilization_percent"` // Current as a percentage of the limit
+	GrowthPerDay   float64   `json:"growth_per_day"`      // Fitted growth of the peak load per day
+	DaysUntilLimit float64   `json:"days_until_limit"`    // -1 when the limit is not being approached
+	LimitDate      time.Time `json:"limit_date"`          // Projected day the limit is reached (zero when not approached)
+	Runs           int       `json:"runs"`                // Runs the growth rate was fitted on
+	Status         string    `json:"status"`              // "exceeded", "critical", "warning", "ok", "not growing" or "insufficient history"
+	Description    string    `json:"description"`
+}
+
+// ParseBandwidth parses a bandwidth per second such as "100MB" or "1.5GB/s"
+func ParseBandwidth(value string) (float64, error) {
+	trimmed := strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(value, "/s"), "/S"))
+	bytes, err := analyser.ParseByteSize(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth %q (expected e.g. 100MB or 1.5GB/s)", value)
+	}
+	return float64(bytes), nil
+}
+
+// capacityMetrics takes the peak load of an analysis; the peak bandwidth is
+// zero when the results have no request timeline
+func capacityMetrics(results *analyser.Results) *CapacityMetrics {
+	metrics := &CapacityMetrics{PeakRPS: results.Capacity.Peak10sRPS}
+	if results.Timeline != nil {
+		for _, bucket := range results.Timeline.Buckets(time.Minute) {
+			if bandwidth := float64(bucket.Bytes) / 60; bandwidth > metrics.PeakBandwidth {
+				metrics.PeakBandwidth = bandwidth
+			}
+		}
+	}
+	return metrics
+}
+
+// ProjectCapacity fits the growth of the peak load over the earlier runs
+// and this one, and projects when it reaches the limits
+func ProjectCapacity(snapshots []Snapshot, results *analyser.Results, limits CapacityLimits) []CapacityProjection {
+	current := capacityMetrics(results)
+	runs := capacityRuns(snapshots, results.TimeRange.Start, results.TimeRange.End, current)
+
+	var projections []CapacityProjection
+	if limits.MaxRPS > 0 {
+		projections = append(projections, projectResource(ResourceRPS, limits.MaxRPS, current.PeakRPS, results.TimeRange.End,
+			runs, func(metrics *CapacityMetrics) float64 { return metrics.PeakRPS }))
+	}
+	if limits.MaxBandwidth > 0 {
+		projections = append(projections, projectResource(ResourceBandwidth, limits.MaxBandwidth, current.PeakBandwidth, results.TimeRange.End,
+			runs, func(metrics *CapacityMetrics) float64 { return metrics.PeakBandwidth }))
+	}
+	return projections
+}
+
+// capacityRun is the peak load of a run at its midpoint
+type capacityRun struct {
+	at      time.Time
+	metrics *CapacityMetrics
+}
+
+// capacityRuns returns the runs with capacity metrics in time order, ending
+// with this run
+func capacityRuns(snapshots []Snapshot, start, end time.Time, current *CapacityMetrics) []capacityRun {
+	var runs []capacityRun
+	for _, snapshot := range snapshots {
+		if snapshot.Capacity == nil || !snapshot.EndTime.Before(start) {
+			continue
+		}
+		runs = append(runs, capacityRun{at: midpoint(snapshot.StartTime, snapshot.EndTime), metrics: snapshot.Capacity})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].at.Before(runs[j].at) })
+	return append(runs, capacityRun{at: midpoint(start, end), metrics: current})
+}
+
+// projectResource projects one resource from the runs that measured it
+func projectResource(resource string, limit, current float64, end time.Time, runs []capacityRun, value func(*CapacityMetrics) float64) CapacityProjection {
+	projection := CapacityProjection{
+		Resource:       resource,
+		Limit:          limit,
+		Current:        current,
+		Utilization:    current / limit * 100,
+		DaysUntilLimit: -1,
+	}
+
+	var days, values []float64
+	for _, run := range runs {
+		if v := value(run.metrics); v > 0 {
+			days = append(days, run.at.Sub(runs[0].at).Hours()/24)
+			values = append(values, v)
+		}
+	}
+	projection.Runs = len(values)
+
+	switch {
+	case current >= limit:
+		projection.Status = "exceeded"
+		projection.DaysUntilLimit = 0
+		projection.LimitDate = end
+		projection.Description = fmt.Sprintf("%s of %s already exceeds the limit of %s",
+			resource, FormatCapacityValue(resource, current), FormatCapacityValue(resource, limit))
+		if len(values) >= capacityMinRuns {
+			projection.GrowthPerDay = growthPerDay(days, values)
+		}
+		return projection
+	case len(values) < capacityMinRuns || days[len(days)-1] < 1:
+		projection.Status = "insufficient history"
+		projection.Description = fmt.Sprintf("%s is at %.0f%% of the limit; recording %d daily runs with --record-trends allows a projection",
+			resource, projection.Utilization, capacityMinRuns)
+		return projection
+	}
+
+	projection.GrowthPerDay = growthPerDay(days, values)
+	if projection.GrowthPerDay <= 0 {
+		projection.Status = "not growing"
+		projection.Description = fmt.Sprintf("%s is at %.0f%% of the limit and not growing over %d runs",
+			resource, projection.Utilization, projection.Runs)
+		return projection
+	}
+
+	projection.DaysUntilLimit = (limit - current) / projection.GrowthPerDay
+	projection.LimitDate = end.Add(time.Duration(projection.DaysUntilLimit * 24 * float64(time.Hour))).Truncate(time.Second)
+	switch {
+	case projection.DaysUntilLimit < capacityCriticalDays:
+		projection.Status = "critical"
+	case projection.DaysUntilLimit < capacityWarningDays:
+		projection.Status = "warning"
+	default:
+		projection.Status = "ok"
+	}
+	projection.Description = fmt.Sprintf("%s grows %s per day and reaches the limit of %s in about %.0f days (%s)",
+		resource, FormatCapacityValue(resource, projection.GrowthPerDay), FormatCapacityValue(resource, limit),
+		math.Ceil(projection.DaysUntilLimit), projection.LimitDate.Format("2006-01-02"))
+	return projection
+}
+
+// growthPerDay is the least squares slope of values over days
+func growthPerDay(days, values []float64) float64 {
+	var meanDay, meanValue float64
+	for i := range days {
+		meanDay += days[i]
+		meanValue += values[i]
+	}
+	n := float64(len(days))
+	meanDay /= n
+	meanValue /= n
+
+	var covariance, variance float64
+	for i := range days {
+		covariance += (days[i] - meanDay) * (values[i] - meanValue)
+		variance += (days[i] - meanDay) * (days[i] - meanDay)
+	}
+	if variance == 0 {
+		return 0
+	}
+	return covariance / variance
+}
+
+// AddCapacityProjections adds capacity projections to the analysis, with a
+// recommendation for each limit that is exceeded or near
+func (analysis *TrendAnalysis) AddCapacityProjections(projections []CapacityProjection) {
+	analysis.CapacityProjections = projections
+	for _, projection := range projections {
+		switch projection.Status {
+		case "exceeded":
+			analysis.Recommendations = append(analysis.Recommendations,
+				fmt.Sprintf("Add capacity now: %s exceeds its limit", projection.Resource))
+		case "critical", "warning":
+			analysis.Recommendations = append(analysis.Recommendations,
+				fmt.Sprintf("Plan capacity: %s reaches its limit in about %.0f days", projection.Resource, math.Ceil(projection.DaysUntilLimit)))
+		}
+	}
+}
+
+// FormatCapacityValue formats a load or limit of a resource in its unit
+func FormatCapacityValue(resource string, value float64) string {
+	if resource != ResourceBandwidth {
+		return fmt.Sprintf("%.1f req/s", value)
+	}
+
+	units := []string{"B", "KB", "MB", "GB"}
+	unit := 0
+	for math.Abs(value) >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.1f %s/s", value, units[unit])
+}
+
+func midpoint(start, end time.Time) time.Time {
+	return start.Add(end.Sub(start) / 2)
+}
@@ -0,0 +1,45 @@
+package trends
+
+import (
+	"strings"
+
+	"smart-log-analyser/pkg/config"
+)
+
+// ConfigurationFrom returns the default trend configuration with the
+// configured alert thresholds on top; zero thresholds keep the defaults
+func ConfigurationFrom(thresholds config.TrendThresholds) TrendConfiguration {
+	configuration := DefaultTrendConfiguration()
+	if thresholds.MinChange > 0 {
+		configuration.MinimumChange = thresholds.MinChange
+	}
+	if thresholds.ErrorRateThreshold > 0 {
+		configuration.ErrorRateThreshold = thresholds.ErrorRateThreshold
+	}
+	if thresholds.ResponseTimeThreshold > 0 {
+		configuration.ResponseTimeThreshold = thresholds.ResponseTimeThreshold
+	}
+	if thresholds.TrafficDropThreshold > 0 {
+		configuration.TrafficDropThreshold = thresholds.TrafficDropThreshold
+	}
+	if thresholds.MinRequests > 0 {
+		configuration.MinimumAlertRequests = thresholds.MinRequests
+	}
+	configuration.AlertMetrics = thresholds.Metrics
+	return configuration
+}
+
+// watchesMetric reports whether a metric, by its display name such as
+// "Error Rate", may raise alerts
+func (ta *TrendAnalyser) watchesMetric(metricName string) bool {
+	if len(ta.config.AlertMetrics) == 0 {
+		return true
+	}
+	key := strings.ToLower(strings.ReplaceAll(metricName, " ", "_"))
+	for _, metric := range ta.config.AlertMetrics {
+		if metric == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package trends
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// Endpoint trend settings
+const (
+	trackedEndpoints    = 20  // Busiest endpoints tracked per period
+	endpointMinRequests = 20  // Requests an endpoint needs in both periods to be compared
+	endpointErrorPoints = 5.0 // Error rate change in percentage points that counts as a trend
+)
+
+// EndpointMetrics are the metrics of one endpoint during a period
+type EndpointMetrics struct {
+	Endpoint  string  `json:"endpoint"` // Method and normalised path
+	Requests  int     `json:"requests"`
+	ErrorRate float64 `json:"error_rate"`
+	P95Size   int64   `json:"p95_size"`
+	P95TimeMs float64 `json:"p95_time_ms,omitempty"` // Zero when the logs have no $request_time
+}
+
+// EndpointTrend is the change of one metric of one endpoint between the
+// baseline and current period
+type EndpointTrend struct {
+	Endpoint      string         `json:"endpoint"`       // Method and normalised path
+	MetricName    string         `json:"metric"`         // "P95 Request Time", "P95 Response Size" or "Error Rate"
+	OldValue      float64        `json:"baseline_value"` // Value in the baseline period
+	NewValue      float64        `json:"current_value"`  // Value in the current period
+	PercentChange float64        `json:"percent_change"` // Percentage change (0 when the baseline value is 0)
+	Direction     TrendDirection `json:"direction"`      // Direction of change
+	Description   string         `json:"description"`    // Human readable description
+}
+
+// endpointMetrics returns the metrics of the busiest endpoints, with their
+// P95 request time when the latency heatmap timed them
+func endpointMetrics(results *analyser.Results) []EndpointMetrics {
+	stats := make([]analyser.EndpointStat, len(results.EndpointStats))
+	copy(stats, results.EndpointStats)
+	analyser.SortEndpointStats(stats, "requests")
+	if len(stats) > trackedEndpoints {
+		stats = stats[:trackedEndpoints]
+	}
+
+	p95Times := make(map[string]time.Duration)
+	if heatmap := results.LatencyHeatmap; heatmap != nil {
+		for i, endpoint := range heatmap.Endpoints {
+			p95Times[endpoint] = heatmap.EndpointP95[i]
+		}
+	}
+
+	metrics := make([]EndpointMetrics, len(stats))
+	for i, stat := range stats {
+		endpoint := stat.Method + " " + stat.Endpoint
+		metrics[i] = EndpointMetrics{
+			Endpoint:  endpoint,
+			Requests:  stat.Count,
+			ErrorRate: stat.ErrorRate,
+			P95Size:   stat.P95Size,
+			P95TimeMs: float64(p95Times[endpoint]) / float64(time.Millisecond),
+		}
+	}
+	return metrics
+}
+
+// averageEndpoints combines the endpoint metrics of several runs. Requests
+// are averaged over all runs, rates and percentiles over the runs that saw
+// the endpoint.
+func averageEndpoints(runs [][]EndpointMetrics) []EndpointMetrics {
+	type totals struct {
+		metrics EndpointMetrics
+		runs    int
+		timed   int
+	}
+
+	byEndpoint := make(map[string]*totals)
+	var order []string
+	for _, endpoints := range runs {
+		for _, endpoint := range endpoints {
+			total, exists := byEndpoint[endpoint.Endpoint]
+			if !exists {
+				total = &totals{metrics: EndpointMetrics{Endpoint: endpoint.Endpoint}}
+				byEndpoint[endpoint.Endpoint] = total
+				order = append(order, endpoint.Endpoint)
+			}
+			total.metrics.Requests += endpoint.Requests
+			total.metrics.ErrorRate += endpoint.ErrorRate
+			total.metrics.P95Size += endpoint.P95Size
+			total.metrics.P95TimeMs += endpoint.P95TimeMs
+			total.runs++
+			if endpoint.P95TimeMs > 0 {
+				total.timed++
+			}
+		}
+	}
+
+	averaged := make([]EndpointMetrics, 0, len(order))
+	for _, name := range order {
+		total := byEndpoint[name]
+		metrics := total.metrics
+		metrics.Requests /= len(runs)
+		metrics.ErrorRate /= float64(total.runs)
+		metrics.P95Size /= int64(total.runs)
+		if total.timed > 0 {
+			metrics.P95TimeMs /= float64(total.timed)
+		}
+		averaged = append(averaged, metrics)
+	}
+	sort.SliceStable(averaged, func(i, j int) bool {
+		return averaged[i].Requests > averaged[j].Requests
+	})
+	return averaged
+}
+
+// compareEndpoints finds the endpoints whose P95 or error rate changed beyond
+// the thresholds. P95 request time is compared when both periods timed the
+// endpoint, otherwise P95 response size as its proxy.
+func (ta *TrendAnalyser) compareEndpoints(baseline, current []EndpointMetrics) []EndpointTrend {
+	before := make(map[string]EndpointMetrics, len(baseline))
+	for _, endpoint := range baseline {
+		before[endpoint.Endpoint] = endpoint
+	}
+
+	var endpointTrends []EndpointTrend
+	for _, now := range current {
+		then, exists := before[now.Endpoint]
+		if !exists || now.Requests < endpointMinRequests || then.Requests < endpointMinRequests {
+			continue
+		}
+
+		if then.P95TimeMs > 0 && now.P95TimeMs > 0 {
+			if trend, changed := ta.endpointLatencyTrend(now.Endpoint, "P95 Request Time", then.P95TimeMs, now.P95TimeMs); changed {
+				endpointTrends = append(endpointTrends, trend)
+			}
+		} else if trend, changed := ta.endpointLatencyTrend(now.Endpoint, "P95 Response Size", float64(then.P95Size), float64(now.P95Size)); changed {
+			endpointTrends = append(endpointTrends, trend)
+		}
+
+		if trend, changed := endpointErrorTrend(now.Endpoint, then.ErrorRate, now.ErrorRate); changed {
+			endpointTrends = append(endpointTrends, trend)
+		}
+	}
+
+	// Worst first: critical, then degrading, then improving, by size of change
+	rank := map[TrendDirection]int{TrendCritical: 0, TrendDegrading: 1, TrendImproving: 2}
+	sort.SliceStable(endpointTrends, func(i, j int) bool {
+		if rank[endpointTrends[i].Direction] != rank[endpointTrends[j].Direction] {
+			return rank[endpointTrends[i].Direction] < rank[endpointTrends[j].Direction]
+		}
+		return endpointTrends[i].relativeChange() > endpointTrends[j].relativeChange()
+	})
+	return endpointTrends
+}
+
+// relativeChange is the size of the change relative to the baseline value,
+// also for baseline values of zero
+func (t EndpointTrend) relativeChange() float64 {
+	return math.Abs(t.NewValue-t.OldValue) / math.Max(t.OldValue, 1)
+}
+
+// endpointLatencyTrend compares a P95 of an endpoint against the response
+// time threshold; twice the threshold is critical
+func (ta *TrendAnalyser) endpointLatencyTrend(endpoint, metricName string, oldValue, newValue float64) (EndpointTrend, bool) {
+	if oldValue == 0 {
+		return EndpointTrend{}, false
+	}
+	percentChange := (newValue - oldValue) / oldValue * 100
+	threshold := ta.config.ResponseTimeThreshold
+
+	direction := TrendStable
+	switch {
+	case percentChange > 2*threshold:
+		direction = TrendCritical
+	case percentChange > threshold:
+		direction = TrendDegrading
+	case percentChange < -threshold:
+		direction = TrendImproving
+	default:
+		return EndpointTrend{}, false
+	}
+
+	verb := "degraded"
+	if direction == TrendImproving {
+		verb = "improved"
+	}
+	return EndpointTrend{
+		Endpoint:      endpoint,
+		MetricName:    metricName,
+		OldValue:      oldValue,
+		NewValue:      newValue,
+		PercentChange: percentChange,
+		Direction:     direction,
+		Description: fmt.Sprintf("P95 %s for %s %s %.0f%% vs baseline (%s → %s)",
+			strings.ToLower(strings.TrimPrefix(metricName, "P95 ")), endpoint, verb,
+			math.Abs(percentChange), formatEndpointValue(metricName, oldValue), formatEndpointValue(metricName, newValue)),
+	}, true
+}
+
+// endpointErrorTrend compares the error rate of an endpoint in percentage
+// points, so an endpoint that had no errors can still degrade
+func endpointErrorTrend(endpoint string, oldRate, newRate float64) (EndpointTrend, bool) {
+	points := newRate - oldRate
+
+	direction := TrendStable
+	switch {
+	case points >= 2*endpointErrorPoints:
+		direction = TrendCritical
+	case points >= endpointErrorPoints:
+		direction = TrendDegrading
+	case points <= -endpointErrorPoints:
+		direction = TrendImproving
+	default:
+		return EndpointTrend{}, false
+	}
+
+	percentChange := 0.0
+	if oldRate != 0 {
+		percentChange = points / oldRate * 100
+	}
+	verb := "rose"
+	if points < 0 {
+		verb = "fell"
+	}
+	return EndpointTrend{
+		Endpoint:      endpoint,
+		MetricName:    "Error Rate",
+		OldValue:      oldRate,
+		NewValue:      newRate,
+		PercentChange: percentChange,
+		Direction:     direction,
+		Description:   fmt.Sprintf("Error rate for %s %s from %.1f%% to %.1f%% vs baseline", endpoint, verb, oldRate, newRate),
+	}, true
+}
+
+// formatEndpointValue formats an endpoint metric in its unit
+func formatEndpointValue(metricName string, value float64) string {
+	switch metricName {
+	case "P95 Request Time":
+		if value < 10 {
+			return fmt.Sprintf("%.1fms", value)
+		}
+		return fmt.Sprintf("%.0fms", value)
+	case "P95 Response Size":
+		return fmt.Sprintf("%.0f B", value)
+	default:
+		return fmt.Sprintf("%.1f%%", value)
+	}
+}
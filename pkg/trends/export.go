@@ -0,0 +1,142 @@
+package trends
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalText writes a trend direction as its name, e.g. "degrading"
+func (td TrendDirection) MarshalText() ([]byte, error) {
+	return []byte(td.String()), nil
+}
+
+// UnmarshalText reads a trend direction written by MarshalText
+func (td *TrendDirection) UnmarshalText(text []byte) error {
+	for _, direction := range []TrendDirection{TrendStable, TrendImproving, TrendDegrading, TrendCritical} {
+		if direction.String() == string(text) {
+			*td = direction
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown trend direction %q", text)
+}
+
+// ExportFormat returns the trend export format of a file name: json or csv
+func ExportFormat(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return "json", nil
+	case ".csv":
+		return "csv", nil
+	default:
+		return "", fmt.Errorf("cannot tell the export format of %s: use a .json or .csv extension", filename)
+	}
+}
+
+// WriteFile writes the trend analysis as JSON or CSV, chosen by the extension
+func WriteFile(analysis *TrendAnalysis, filename string) error {
+	format, err := ExportFormat(filename)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	if format == "csv" {
+		return writeCSV(analysis, filename)
+	}
+	return writeJSON(analysis, filename)
+}
+
+func writeJSON(analysis *TrendAnalysis, filename string) error {
+	data, err := json.MarshalIndent(analysis, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// writeCSV writes the trend analysis as one table, with a Section column
+// telling summary, period metric, change, security, alert, endpoint,
+// capacity and recommendation rows apart so each can be filtered and charted
+func writeCSV(analysis *TrendAnalysis, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Section", "Item", "Baseline", "Current", "Change %", "Direction", "Details"})
+	writer.Write([]string{"Summary", "Analysis Type", "", analysis.AnalysisType, "", "", ""})
+	writer.Write([]string{"Summary", "Generated", "", analysis.GeneratedAt.Format("2006-01-02 15:04:05"), "", "", ""})
+	writer.Write([]string{"Summary", "Overall Health", "", analysis.OverallHealth, "", "", analysis.TrendSummary})
+
+	for _, comparison := range analysis.PeriodComparisons {
+		baseline, current := comparison.BaselinePeriod, comparison.CurrentPeriod
+		writer.Write([]string{"Comparison", "Risk Score", "", strconv.Itoa(comparison.RiskScore), "", comparison.OverallTrend.String(), comparison.Summary})
+		writer.Write([]string{"Period", "Name", baseline.Period, current.Period, "", "", ""})
+		writer.Write([]string{"Period", "Start", csvTime(baseline.StartTime), csvTime(current.StartTime), "", "", ""})
+		writer.Write([]string{"Period", "End", csvTime(baseline.EndTime), csvTime(current.EndTime), "", "", ""})
+		writer.Write([]string{"Period", "Peak Hour Requests", strconv.Itoa(baseline.PeakHourRequests), strconv.Itoa(current.PeakHourRequests), "", "", ""})
+
+		for _, change := range comparison.TrendChanges {
+			writer.Write([]string{"Change", change.MetricName, csvFloat(change.OldValue), csvFloat(change.NewValue),
+				csvFloat(change.PercentChange), change.Direction.String(), change.Description})
+		}
+
+		if len(comparison.SecurityChanges) > 0 {
+			writer.Write([]string{"Security", "Security Posture", "", "", "", comparison.SecurityTrend.String(), ""})
+		}
+		for _, change := range comparison.SecurityChanges {
+			writer.Write([]string{"Security", change.MetricName, csvFloat(change.OldValue), csvFloat(change.NewValue),
+				csvFloat(change.PercentChange), change.Direction.String(), change.Description})
+		}
+	}
+
+	for _, alert := range analysis.DegradationAlerts {
+		writer.Write([]string{"Alert", alert.AlertID + " " + alert.MetricName, csvFloat(alert.BaselineValue), csvFloat(alert.CurrentValue),
+			"", alert.Trend.String(), fmt.Sprintf("%s (threshold %.0f%%): %s. %s", alert.Severity, alert.Threshold, alert.Impact, alert.Recommendation)})
+	}
+
+	for _, trend := range analysis.EndpointTrends {
+		writer.Write([]string{"Endpoint", trend.Endpoint + " " + trend.MetricName, csvFloat(trend.OldValue), csvFloat(trend.NewValue),
+			csvFloat(trend.PercentChange), trend.Direction.String(), trend.Description})
+	}
+
+	// Capacity rows give the limit as baseline and the utilization as change
+	for _, projection := range analysis.CapacityProjections {
+		writer.Write([]string{"Capacity", projection.Resource, csvFloat(projection.Limit), csvFloat(projection.Current),
+			csvFloat(projection.Utilization), projection.Status, projection.Description})
+	}
+
+	for _, recommendation := range analysis.Recommendations {
+		writer.Write([]string{"Recommendation", "", "", "", "", "", recommendation})
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func csvFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// csvTime leaves the times of periods without a time range empty
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
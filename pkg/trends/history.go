@@ -0,0 +1,240 @@
+package trends
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// DefaultHistoryFile is the trend history file name inside the config directory
+const DefaultHistoryFile = "trend_history.json"
+
+// Snapshot holds the aggregate metrics of one analysis run
+type Snapshot struct {
+	RecordedAt          time.Time      `json:"recorded_at"`
+	Sources             []string       `json:"sources,omitempty"`
+	StartTime           time.Time      `json:"start_time"`
+	EndTime             time.Time      `json:"end_time"`
+	TotalRequests       int            `json:"total_requests"`
+	ErrorRate           float64        `json:"error_rate"`
+	AverageResponseSize int64          `json:"average_response_size"`
+	TrafficVolume       int64          `json:"traffic_volume"`
+	UniqueVisitors      int            `json:"unique_visitors"`
+	PeakHourRequests    int            `json:"peak_hour_requests"`
+	BotTrafficPercent   float64        `json:"bot_traffic_percent"`
+	StatusCodes         map[string]int `json:"status_codes,omitempty"`
+
+	Endpoints []EndpointMetrics `json:"endpoints,omitempty"`
+	Security  *SecurityMetrics  `json:"security,omitempty"`
+	Capacity  *CapacityMetrics  `json:"capacity,omitempty"`
+}
+
+// History is the rolling store of analysis run snapshots, kept as a JSON
+// file so trends can be compared against weeks of earlier runs
+type History struct {
+	Snapshots []Snapshot `json:"snapshots"`
+
+	filename string
+}
+
+// LoadHistory reads the history file. A missing file is an empty history.
+func LoadHistory(filename string) (*History, error) {
+	history := &History{filename: filename}
+
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trend history: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse trend history %s: %w", filename, err)
+	}
+	return history, nil
+}
+
+// Save writes the history back to its file
+func (h *History) Save() error {
+	if dir := filepath.Dir(h.filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create history directory: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.filename, data, 0644)
+}
+
+// Record adds a snapshot. A snapshot of the same period replaces the earlier
+// one, so analysing the same logs twice does not count them twice.
+func (h *History) Record(snapshot Snapshot) {
+	for i, existing := range h.Snapshots {
+		if existing.StartTime.Equal(snapshot.StartTime) && existing.EndTime.Equal(snapshot.EndTime) {
+			h.Snapshots[i] = snapshot
+			return
+		}
+	}
+	h.Snapshots = append(h.Snapshots, snapshot)
+	sort.Slice(h.Snapshots, func(i, j int) bool {
+		return h.Snapshots[i].EndTime.Before(h.Snapshots[j].EndTime)
+	})
+}
+
+// Prune drops snapshots that ended more than retention before the newest
+// one and returns how many were dropped
+func (h *History) Prune(retention time.Duration) int {
+	if len(h.Snapshots) == 0 || retention <= 0 {
+		return 0
+	}
+	cutoff := h.Snapshots[len(h.Snapshots)-1].EndTime.Add(-retention)
+
+	kept := h.Snapshots[:0]
+	for _, snapshot := range h.Snapshots {
+		if !snapshot.EndTime.Before(cutoff) {
+			kept = append(kept, snapshot)
+		}
+	}
+	dropped := len(h.Snapshots) - len(kept)
+	h.Snapshots = kept
+	return dropped
+}
+
+// Before returns the snapshots that ended within window before t
+func (h *History) Before(t time.Time, window time.Duration) []Snapshot {
+	var snapshots []Snapshot
+	for _, snapshot := range h.Snapshots {
+		if snapshot.EndTime.After(t) {
+			continue
+		}
+		if window > 0 && snapshot.EndTime.Before(t.Add(-window)) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots
+}
+
+// NewSnapshot takes a snapshot of the results of an analysis run
+func NewSnapshot(results *analyser.Results, sources []string) Snapshot {
+	metrics := New().convertToPeriodMetrics("", results)
+	return Snapshot{
+		RecordedAt:          time.Now(),
+		Sources:             sources,
+		StartTime:           metrics.StartTime,
+		EndTime:             metrics.EndTime,
+		TotalRequests:       metrics.TotalRequests,
+		ErrorRate:           metrics.ErrorRate,
+		AverageResponseSize: metrics.AverageResponseSize,
+		TrafficVolume:       metrics.TrafficVolume,
+		UniqueVisitors:      metrics.UniqueVisitors,
+		PeakHourRequests:    metrics.PeakHourRequests,
+		BotTrafficPercent:   metrics.BotTrafficPercent,
+		StatusCodes:         metrics.StatusCodeDistrib,
+		Endpoints:           metrics.Endpoints,
+		Security:            metrics.Security,
+		Capacity:            capacityMetrics(results),
+	}
+}
+
+// CompareWithHistory compares the results of this run with the average of
+// earlier runs. Runs are compared as a whole, so the history is most useful
+// when each run covers a similar period, such as a daily log.
+func (ta *TrendAnalyser) CompareWithHistory(results *analyser.Results, history []Snapshot) (*TrendAnalysis, error) {
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no earlier runs in the trend history")
+	}
+	if results.TotalRequests < ta.config.MinimumSampleSize {
+		return nil, fmt.Errorf("insufficient data: need at least %d log entries", ta.config.MinimumSampleSize)
+	}
+
+	return ta.compareMetrics("historical", averageSnapshots(history), ta.convertToPeriodMetrics("Current Run", results)), nil
+}
+
+// compareMetrics compares the metrics of a baseline and a current period and
+// builds the trend analysis with its alerts and recommendations
+func (ta *TrendAnalyser) compareMetrics(analysisType string, baseline, current PeriodMetrics) *TrendAnalysis {
+	trendChanges := ta.calculateTrendChanges(baseline, current)
+	securityChanges := ta.calculateSecurityChanges(baseline, current)
+	overallTrend := ta.calculateOverallTrend(trendChanges)
+	riskScore := ta.calculateRiskScore(trendChanges)
+	comparison := &PeriodComparison{
+		BaselinePeriod:  baseline,
+		CurrentPeriod:   current,
+		TrendChanges:    trendChanges,
+		OverallTrend:    overallTrend,
+		SecurityChanges: securityChanges,
+		SecurityTrend:   ta.calculateOverallTrend(securityChanges),
+		RiskScore:       riskScore,
+		Summary:         ta.generateComparisonSummary(overallTrend, riskScore, trendChanges),
+	}
+
+	alerts := ta.generateDegradationAlerts(comparison)
+	endpointTrends := ta.compareEndpoints(baseline.Endpoints, current.Endpoints)
+	return &TrendAnalysis{
+		AnalysisType:      analysisType,
+		GeneratedAt:       time.Now(),
+		PeriodComparisons: []PeriodComparison{*comparison},
+		DegradationAlerts: alerts,
+		EndpointTrends:    endpointTrends,
+		OverallHealth:     ta.calculateOverallHealth(alerts, comparison.RiskScore),
+		Recommendations:   ta.generateRecommendations(alerts, comparison.TrendChanges, endpointTrends),
+		TrendSummary:      ta.generateTrendSummary(comparison, alerts),
+	}
+}
+
+// averageSnapshots combines snapshots into the metrics of an average run
+func averageSnapshots(snapshots []Snapshot) PeriodMetrics {
+	metrics := PeriodMetrics{
+		Period:            fmt.Sprintf("Average of %d earlier runs", len(snapshots)),
+		StartTime:         snapshots[0].StartTime,
+		EndTime:           snapshots[0].EndTime,
+		StatusCodeDistrib: make(map[string]int),
+	}
+
+	var requests, size, volume, visitors, peak float64
+	var endpoints [][]EndpointMetrics
+	var security []*SecurityMetrics
+	for _, snapshot := range snapshots {
+		endpoints = append(endpoints, snapshot.Endpoints)
+		security = append(security, snapshot.Security)
+		if snapshot.StartTime.Before(metrics.StartTime) {
+			metrics.StartTime = snapshot.StartTime
+		}
+		if snapshot.EndTime.After(metrics.EndTime) {
+			metrics.EndTime = snapshot.EndTime
+		}
+		requests += float64(snapshot.TotalRequests)
+		size += float64(snapshot.AverageResponseSize)
+		volume += float64(snapshot.TrafficVolume)
+		visitors += float64(snapshot.UniqueVisitors)
+		peak += float64(snapshot.PeakHourRequests)
+		metrics.ErrorRate += snapshot.ErrorRate
+		metrics.BotTrafficPercent += snapshot.BotTrafficPercent
+		for code, count := range snapshot.StatusCodes {
+			metrics.StatusCodeDistrib[code] += count
+		}
+	}
+
+	n := float64(len(snapshots))
+	metrics.TotalRequests = int(requests / n)
+	metrics.AverageResponseSize = int64(size / n)
+	metrics.TrafficVolume = int64(volume / n)
+	metrics.UniqueVisitors = int(visitors / n)
+	metrics.PeakHourRequests = int(peak / n)
+	metrics.ErrorRate /= n
+	metrics.BotTrafficPercent /= n
+	for code, count := range metrics.StatusCodeDistrib {
+		metrics.StatusCodeDistrib[code] = count / len(snapshots)
+	}
+	metrics.Endpoints = averageEndpoints(endpoints)
+	metrics.Security = averageSecurity(security)
+	return metrics
+}
@@ -0,0 +1,83 @@
+package trends
+
+import (
+	"fmt"
+	"time"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// Period is a named time range [Start, End)
+type Period struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// String describes the period with its dates
+func (p Period) String() string {
+	first, last := p.Start.Format("2006-01-02"), p.End.Add(-time.Nanosecond).Format("2006-01-02")
+	if first == last {
+		if p.Name == first {
+			return first
+		}
+		return fmt.Sprintf("%s (%s)", p.Name, first)
+	}
+	return fmt.Sprintf("%s (%s to %s)", p.Name, first, last)
+}
+
+// ResolvePeriod turns a period name into a time range relative to reference,
+// the start of the logs being compared: previous-day is the calendar day
+// before it, previous-week the seven days before it, and a YYYY-MM-DD date
+// that day
+func ResolvePeriod(name string, reference time.Time) (Period, error) {
+	day := time.Date(reference.Year(), reference.Month(), reference.Day(), 0, 0, 0, 0, reference.Location())
+
+	switch name {
+	case "previous-day":
+		return Period{Name: name, Start: day.AddDate(0, 0, -1), End: day}, nil
+	case "previous-week":
+		return Period{Name: name, Start: day.AddDate(0, 0, -7), End: day}, nil
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", name, reference.Location())
+	if err != nil {
+		return Period{}, fmt.Errorf("unknown period %q (use previous-day, previous-week or a YYYY-MM-DD date)", name)
+	}
+	return Period{Name: name, Start: date, End: date.AddDate(0, 0, 1)}, nil
+}
+
+// Between returns the snapshots whose midpoint falls within the period, so a
+// daily log rotated in the early morning belongs to the day it mostly covers
+func (h *History) Between(period Period) []Snapshot {
+	var snapshots []Snapshot
+	for _, snapshot := range h.Snapshots {
+		mid := snapshot.StartTime.Add(snapshot.EndTime.Sub(snapshot.StartTime) / 2)
+		if !mid.Before(period.Start) && mid.Before(period.End) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots
+}
+
+// CompareWithSnapshots compares the results of this run with the average of
+// the runs recorded for a prior period
+func (ta *TrendAnalyser) CompareWithSnapshots(results *analyser.Results, snapshots []Snapshot, period Period) (*TrendAnalysis, error) {
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no runs recorded in the trend history for %s", period)
+	}
+	baseline := averageSnapshots(snapshots)
+	baseline.Period = fmt.Sprintf("%s, average of %d recorded run(s)", period, len(snapshots))
+	return ta.compareMetrics("comparison", baseline, ta.convertToPeriodMetrics("Current Run", results)), nil
+}
+
+// CompareWithResults compares the results of this run with the results of
+// the logs of a prior period
+func (ta *TrendAnalyser) CompareWithResults(results, baseline *analyser.Results, period Period) (*TrendAnalysis, error) {
+	if baseline.TotalRequests == 0 {
+		return nil, fmt.Errorf("no log entries for %s", period)
+	}
+	return ta.compareMetrics("comparison",
+		ta.convertToPeriodMetrics(period.String(), baseline),
+		ta.convertToPeriodMetrics("Current Run", results)), nil
+}
@@ -0,0 +1,187 @@
+package trends
+
+import (
+	"fmt"
+	"math"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// Security trend settings
+const (
+	securityScoreDrop = 15.0 // Security score drop (%) that is critical
+	threatRateRise    = 50.0 // Threat or attack rate rise (%) that is critical
+)
+
+// attackTypes are the attack types tracked per period, in display order
+var attackTypes = []string{"SQL Injection", "XSS", "Directory Traversal", "Brute Force", "Scanning", "Method Probing"}
+
+// SecurityMetrics are the security findings of a period. Threats and
+// attacks are counted per 1,000 requests so periods of different sizes
+// compare fairly.
+type SecurityMetrics struct {
+	SecurityScore int                `json:"security_score"` // 0-100, higher is better
+	Threats       int                `json:"threats"`
+	ThreatRate    float64            `json:"threat_rate"` // Threats per 1,000 requests
+	SuspiciousIPs int                `json:"suspicious_ips"`
+	AttackRates   map[string]float64 `json:"attack_rates,omitempty"` // Attempts per 1,000 requests by attack type
+}
+
+// securityMetrics takes the security findings of an analysis
+func securityMetrics(results *analyser.Results) *SecurityMetrics {
+	security := results.SecurityAnalysis
+	attempts := map[string]int{
+		"SQL Injection":       security.SQLInjectionAttempts,
+		"XSS":                 security.XSSAttempts,
+		"Directory Traversal": security.DirectoryTraversal,
+		"Brute Force":         security.BruteForceAttempts,
+		"Scanning":            security.ScanningActivity,
+		"Method Probing":      security.MethodProbing,
+	}
+
+	attackRates := make(map[string]float64)
+	for attackType, count := range attempts {
+		if count > 0 {
+			attackRates[attackType] = perThousand(count, results.TotalRequests)
+		}
+	}
+
+	return &SecurityMetrics{
+		SecurityScore: security.SecurityScore,
+		Threats:       security.TotalThreats,
+		ThreatRate:    perThousand(security.TotalThreats, results.TotalRequests),
+		SuspiciousIPs: len(security.SuspiciousIPs),
+		AttackRates:   attackRates,
+	}
+}
+
+// averageSecurity combines the security metrics of the runs that recorded
+// them; nil when none did
+func averageSecurity(runs []*SecurityMetrics) *SecurityMetrics {
+	var score, threats, suspicious float64
+	average := &SecurityMetrics{AttackRates: make(map[string]float64)}
+	n := 0
+	for _, run := range runs {
+		if run == nil {
+			continue
+		}
+		n++
+		score += float64(run.SecurityScore)
+		threats += float64(run.Threats)
+		suspicious += float64(run.SuspiciousIPs)
+		average.ThreatRate += run.ThreatRate
+		for attackType, rate := range run.AttackRates {
+			average.AttackRates[attackType] += rate
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+
+	average.SecurityScore = int(math.Round(score / float64(n)))
+	average.Threats = int(math.Round(threats / float64(n)))
+	average.SuspiciousIPs = int(math.Round(suspicious / float64(n)))
+	average.ThreatRate /= float64(n)
+	for attackType := range average.AttackRates {
+		average.AttackRates[attackType] /= float64(n)
+	}
+	return average
+}
+
+// calculateSecurityChanges compares the security posture and attack pressure
+// of two periods, with a change per attack type seen in either period. It
+// returns nil unless both periods have security metrics.
+func (ta *TrendAnalyser) calculateSecurityChanges(baseline, current PeriodMetrics) []TrendChange {
+	if baseline.Security == nil || current.Security == nil {
+		return nil
+	}
+	before, now := baseline.Security, current.Security
+
+	changes := []TrendChange{
+		ta.securityChange("Security Score", float64(before.SecurityScore), float64(now.SecurityScore), "points"),
+		ta.securityChange("Threat Rate", before.ThreatRate, now.ThreatRate, "per 1k requests"),
+		ta.securityChange("Suspicious IPs", float64(before.SuspiciousIPs), float64(now.SuspiciousIPs), "IPs"),
+	}
+	for _, attackType := range attackTypes {
+		oldRate, newRate := before.AttackRates[attackType], now.AttackRates[attackType]
+		if oldRate == 0 && newRate == 0 {
+			continue
+		}
+		changes = append(changes, ta.securityChange(attackType+" Rate", oldRate, newRate, "per 1k requests"))
+	}
+	return changes
+}
+
+// securityChange calculates the change of a security metric. Attacks that
+// were absent from the baseline have no percentage change but still degrade
+// the security trend.
+func (ta *TrendAnalyser) securityChange(metricName string, oldValue, newValue float64, unit string) TrendChange {
+	change := ta.calculateMetricChange(metricName, oldValue, newValue, unit)
+	if oldValue == 0 && newValue > 0 && higherIsWorse(metricName) {
+		change.Direction = TrendDegrading
+		change.Significance = "medium"
+		change.Description = fmt.Sprintf("%s rose from none in the baseline to %s", metricName, FormatSecurityValue(metricName, newValue))
+	}
+	return change
+}
+
+// securityAlertMetrics are the security changes that may raise alerts; the
+// per attack type rates are too noisy to alert on individually
+var securityAlertMetrics = []string{"Security Score", "Threat Rate"}
+
+// alertingSecurityChanges returns the security changes that may raise alerts
+func alertingSecurityChanges(changes []TrendChange) []TrendChange {
+	var alerting []TrendChange
+	for _, change := range changes {
+		for _, metricName := range securityAlertMetrics {
+			if change.MetricName == metricName {
+				alerting = append(alerting, change)
+			}
+		}
+	}
+	return alerting
+}
+
+// isSecurityMetric reports whether a metric is one of the security changes
+func isSecurityMetric(metricName string) bool {
+	switch metricName {
+	case "Security Score", "Threat Rate", "Suspicious IPs":
+		return true
+	}
+	for _, attackType := range attackTypes {
+		if metricName == attackType+" Rate" {
+			return true
+		}
+	}
+	return false
+}
+
+// higherIsWorse reports whether an increase of the metric is a degradation
+func higherIsWorse(metricName string) bool {
+	switch metricName {
+	case "Error Rate", "Average Response Size":
+		return true
+	case "Security Score":
+		return false
+	}
+	return isSecurityMetric(metricName)
+}
+
+// FormatSecurityValue formats a security metric in its unit
+func FormatSecurityValue(metricName string, value float64) string {
+	switch metricName {
+	case "Security Score":
+		return fmt.Sprintf("%.0f/100", value)
+	case "Suspicious IPs":
+		return fmt.Sprintf("%.0f", value)
+	default:
+		return fmt.Sprintf("%.2f per 1k requests", value)
+	}
+}
+
+func perThousand(count, requests int) float64 {
+	if requests == 0 {
+		return 0
+	}
+	return float64(count) / float64(requests) * 1000
+}
@@ -8,19 +8,21 @@ import (
 
 // PeriodMetrics contains key metrics for a specific time period
 type PeriodMetrics struct {
-	Period               string    // Human readable period description
-	StartTime            time.Time // Start of period
-	EndTime              time.Time // End of period
-	TotalRequests        int       // Total number of requests
-	AverageResponseSize  int64     // Average response size (proxy for response time)
-	ErrorRate            float64   // Percentage of 4xx/5xx responses
-	TrafficVolume        int64     // Total bytes transferred
-	UniqueVisitors       int       // Unique IP addresses
-	PeakHourRequests     int       // Requests during peak hour
-	StatusCodeDistrib    map[string]int // Status code distribution
-	TopErrorURLs         []analyser.URLStat // URLs with most errors
-	BotTrafficPercent    float64   // Percentage of bot traffic
-	GeographicDistrib    map[string]int // Country distribution
+	Period              string             `json:"period"`                   // Human readable period description
+	StartTime           time.Time          `json:"start_time"`               // Start of period
+	EndTime             time.Time          `json:"end_time"`                 // End of period
+	TotalRequests       int                `json:"total_requests"`           // Total number of requests
+	AverageResponseSize int64              `json:"average_response_size"`    // Average response size (proxy for response time)
+	ErrorRate           float64            `json:"error_rate"`               // Percentage of 4xx/5xx responses
+	TrafficVolume       int64              `json:"traffic_volume"`           // Total bytes transferred
+	UniqueVisitors      int                `json:"unique_visitors"`          // Unique IP addresses
+	PeakHourRequests    int                `json:"peak_hour_requests"`       // Requests during peak hour
+	StatusCodeDistrib   map[string]int     `json:"status_codes,omitempty"`   // Status code distribution
+	TopErrorURLs        []analyser.URLStat `json:"top_error_urls,omitempty"` // URLs with most errors
+	BotTrafficPercent   float64            `json:"bot_traffic_percent"`      // Percentage of bot traffic
+	GeographicDistrib   map[string]int     `json:"countries,omitempty"`      // Country distribution
+	Endpoints           []EndpointMetrics  `json:"endpoints,omitempty"`      // Busiest endpoints
+	Security            *SecurityMetrics   `json:"security,omitempty"`       // Security findings (nil for runs recorded without them)
 }
 
 // TrendDirection indicates the direction of change between periods
@@ -35,54 +37,59 @@ const (
 
 // TrendChange represents a change in a metric between two periods
 type TrendChange struct {
-	MetricName     string         // Name of the metric
-	OldValue       float64        // Value in previous period
-	NewValue       float64        // Value in current period
-	AbsoluteChange float64        // Absolute difference
-	PercentChange  float64        // Percentage change
-	Direction      TrendDirection // Direction of change
-	Significance   string         // "low", "medium", "high"
-	Description    string         // Human readable description
+	MetricName     string         `json:"metric"`          // Name of the metric
+	OldValue       float64        `json:"baseline_value"`  // Value in previous period
+	NewValue       float64        `json:"current_value"`   // Value in current period
+	AbsoluteChange float64        `json:"absolute_change"` // Absolute difference
+	PercentChange  float64        `json:"percent_change"`  // Percentage change
+	Direction      TrendDirection `json:"direction"`       // Direction of change
+	Significance   string         `json:"significance"`    // "low", "medium", "high"
+	Description    string         `json:"description"`     // Human readable description
 }
 
 // DegradationAlert represents a detected performance degradation
 type DegradationAlert struct {
-	AlertID      string         // Unique alert identifier
-	Severity     string         // "warning", "error", "critical"
-	MetricName   string         // Affected metric
-	CurrentValue float64        // Current metric value
-	BaselineValue float64       // Expected/baseline value
-	Threshold    float64        // Threshold that was exceeded
-	Impact       string         // Description of potential impact
-	Recommendation string       // Suggested action
-	DetectedAt   time.Time      // When the degradation was detected
-	Trend        TrendDirection // Overall trend direction
+	AlertID        string         `json:"id"`             // Unique alert identifier
+	Severity       string         `json:"severity"`       // "warning", "error", "critical"
+	MetricName     string         `json:"metric"`         // Affected metric
+	CurrentValue   float64        `json:"current_value"`  // Current metric value
+	BaselineValue  float64        `json:"baseline_value"` // Expected/baseline value
+	Threshold      float64        `json:"threshold"`      // Threshold that was exceeded
+	Impact         string         `json:"impact"`         // Description of potential impact
+	Recommendation string         `json:"recommendation"` // Suggested action
+	DetectedAt     time.Time      `json:"detected_at"`    // When the degradation was detected
+	Trend          TrendDirection `json:"trend"`          // Overall trend direction
 }
 
 // PeriodComparison contains the results of comparing two time periods
 type PeriodComparison struct {
-	BaselinePeriod PeriodMetrics   // Earlier/baseline period
-	CurrentPeriod  PeriodMetrics   // Later/current period
-	TrendChanges   []TrendChange   // Changes in metrics
-	OverallTrend   TrendDirection  // Overall trend direction
-	RiskScore      int             // Risk score (0-100, higher is worse)
-	Summary        string          // Human readable summary
+	BaselinePeriod  PeriodMetrics  `json:"baseline"`                   // Earlier/baseline period
+	CurrentPeriod   PeriodMetrics  `json:"current"`                    // Later/current period
+	TrendChanges    []TrendChange  `json:"changes"`                    // Changes in metrics
+	OverallTrend    TrendDirection `json:"overall_trend"`              // Overall trend direction
+	SecurityChanges []TrendChange  `json:"security_changes,omitempty"` // Changes in security posture and attack pressure
+	SecurityTrend   TrendDirection `json:"security_trend"`             // Direction of the security posture
+	RiskScore       int            `json:"risk_score"`                 // Risk score (0-100, higher is worse)
+	Summary         string         `json:"summary"`                    // Human readable summary
 }
 
 // TrendAnalysis contains comprehensive trend analysis results
 type TrendAnalysis struct {
-	AnalysisType      string               // "comparison", "degradation", "historical"
-	GeneratedAt       time.Time            // When analysis was performed
-	PeriodComparisons []PeriodComparison   // Period-to-period comparisons
-	DegradationAlerts []DegradationAlert   // Detected degradation issues
-	OverallHealth     string               // "healthy", "warning", "critical"
-	Recommendations   []string             // Actionable recommendations
-	TrendSummary      string               // Executive summary of trends
+	AnalysisType        string               `json:"analysis_type"`                  // "comparison", "degradation", "historical"
+	GeneratedAt         time.Time            `json:"generated_at"`                   // When analysis was performed
+	PeriodComparisons   []PeriodComparison   `json:"comparisons"`                    // Period-to-period comparisons
+	DegradationAlerts   []DegradationAlert   `json:"alerts"`                         // Detected degradation issues
+	EndpointTrends      []EndpointTrend      `json:"endpoint_trends"`                // Endpoints whose P95 or error rate changed, worst first
+	CapacityProjections []CapacityProjection `json:"capacity_projections,omitempty"` // Days until the capacity limits, when configured
+	OverallHealth       string               `json:"overall_health"`                 // "healthy", "warning", "critical"
+	Recommendations     []string             `json:"recommendations"`                // Actionable recommendations
+	TrendSummary        string               `json:"summary"`                        // Executive summary of trends
 }
 
 // TrendConfiguration defines parameters for trend analysis
 type TrendConfiguration struct {
 	// Degradation thresholds
+	MinimumChange           float64 // Change below which a metric is stable (%)
 	ErrorRateThreshold      float64 // Error rate increase threshold (%)
 	ResponseTimeThreshold   float64 // Response time increase threshold (%)
 	TrafficDropThreshold    float64 // Traffic drop threshold (%)
@@ -96,12 +103,15 @@ type TrendConfiguration struct {
 	
 	// Alert settings
 	EnableAlerts            bool    // Whether to generate alerts
+	MinimumAlertRequests    int     // Requests both periods need before alerts are raised
+	AlertMetrics            []string // Metrics that may raise alerts, e.g. error_rate (all when empty)
 	AlertCooldownHours      int     // Hours between similar alerts
 }
 
 // DefaultTrendConfiguration returns sensible default configuration
 func DefaultTrendConfiguration() TrendConfiguration {
 	return TrendConfiguration{
+		MinimumChange:           5.0,  // Changes within 5% are stable
 		ErrorRateThreshold:      10.0, // 10% increase triggers alert
 		ResponseTimeThreshold:   20.0, // 20% increase triggers alert
 		TrafficDropThreshold:    30.0, // 30% drop triggers alert
@@ -109,6 +119,7 @@ func DefaultTrendConfiguration() TrendConfiguration {
 		SignificanceLevel:       0.05, // 95% confidence level
 		DefaultComparisonPeriod: "previous-day",
 		EnableAlerts:            true,
+		MinimumAlertRequests:    100,
 		AlertCooldownHours:      4, // 4 hours between similar alerts
 	}
 }
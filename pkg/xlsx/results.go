@@ -0,0 +1,152 @@
+package xlsx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"smart-log-analyser/pkg/analyser"
+)
+
+// ExportResults writes analysis results to an .xlsx file with one worksheet
+// per section, plus a groups sheet when groups are given
+func ExportResults(results *analyser.Results, groups []analyser.GroupResult, filename string) error {
+	return ResultsWorkbook(results, groups).Save(filename)
+}
+
+// ResultsWorkbook builds the workbook of analysis results: overview, status
+// codes, top IPs, top URLs, errors and security, each on its own sheet
+func ResultsWorkbook(results *analyser.Results, groups []analyser.GroupResult) *Workbook {
+	workbook := NewWorkbook()
+	addOverview(workbook, results)
+	addStatusCodes(workbook, results)
+	addTopIPs(workbook, results)
+	addTopURLs(workbook, results)
+	addErrors(workbook, results)
+	addSecurity(workbook, results)
+	if len(groups) > 0 {
+		addGroups(workbook, results, groups)
+	}
+	return workbook
+}
+
+func addOverview(workbook *Workbook, results *analyser.Results) {
+	sheet := workbook.AddSheet("Overview", "Metric", "Value", "Percentage")
+	sheet.AddRow("Total Requests", results.TotalRequests)
+	sheet.AddRow("Start Time", results.TimeRange.Start)
+	sheet.AddRow("End Time", results.TimeRange.End)
+	sheet.AddRow("Unique IPs", results.UniqueIPs)
+	sheet.AddRow("Unique URLs", results.UniqueURLs)
+	sheet.AddRow("Total Bytes", results.TotalBytes)
+	sheet.AddRow("Average Size (bytes)", results.AverageSize)
+	sheet.AddRow("Human Requests", results.HumanRequests, share(results.HumanRequests, results.TotalRequests))
+	sheet.AddRow("Bot Requests", results.BotRequests, share(results.BotRequests, results.TotalRequests))
+	sheet.AddRow("Average Requests per Hour", results.AverageRequestsPerHour)
+	sheet.AddRow("Peak Hour", results.PeakHour)
+	sheet.AddRow("Quietest Hour", results.QuietestHour)
+}
+
+func addStatusCodes(workbook *Workbook, results *analyser.Results) {
+	classes := make([]string, 0, len(results.StatusCodes))
+	for class := range results.StatusCodes {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	sheet := workbook.AddSheet("Status Codes", "Status", "Class", "Requests", "Percentage")
+	for _, status := range results.DetailedStatusCodes {
+		sheet.AddRow(status.Code, statusClass(status.Code, classes), status.Count, share(status.Count, results.TotalRequests))
+	}
+}
+
+func addTopIPs(workbook *Workbook, results *analyser.Results) {
+	sheet := workbook.AddSheet("Top IPs", "Rank", "IP", "Requests", "Percentage")
+	for i, ip := range results.TopIPs {
+		sheet.AddRow(i+1, ip.IP, ip.Count, share(ip.Count, results.TotalRequests))
+	}
+}
+
+func addTopURLs(workbook *Workbook, results *analyser.Results) {
+	sheet := workbook.AddSheet("Top URLs", "Rank", "URL", "Requests", "Percentage")
+	for i, url := range results.TopURLs {
+		sheet.AddRow(i+1, url.URL, url.Count, share(url.Count, results.TotalRequests))
+	}
+}
+
+func addErrors(workbook *Workbook, results *analyser.Results) {
+	sheet := workbook.AddSheet("Errors", "URL", "Errors", "Percentage", "Status Codes")
+	for _, url := range results.ErrorURLs {
+		sheet.AddRow(url.URL, url.Count, share(url.Count, results.TotalRequests), statusBreakdown(url.StatusCodes))
+	}
+}
+
+func addSecurity(workbook *Workbook, results *analyser.Results) {
+	security := results.SecurityAnalysis
+
+	sheet := workbook.AddSheet("Security", "Metric", "Value")
+	sheet.AddRow("Threat Level", security.ThreatLevel)
+	sheet.AddRow("Security Score", security.SecurityScore)
+	sheet.AddRow("Total Threats", security.TotalThreats)
+	sheet.AddRow("SQL Injection Attempts", security.SQLInjectionAttempts)
+	sheet.AddRow("XSS Attempts", security.XSSAttempts)
+	sheet.AddRow("Directory Traversal Attempts", security.DirectoryTraversal)
+	sheet.AddRow("Brute Force Attempts", security.BruteForceAttempts)
+	sheet.AddRow("Scanning Activity", security.ScanningActivity)
+	sheet.AddRow("Method Probing", security.MethodProbing)
+	sheet.AddRow("Suspicious IPs", len(security.SuspiciousIPs))
+
+	threats := workbook.AddSheet("Threats", "Time", "Type", "Severity", "IP", "URL", "Pattern", "User Agent")
+	for _, threat := range security.ThreatsDetected {
+		threats.AddRow(threat.Timestamp, threat.Type, threat.Severity, threat.IP, threat.URL, threat.Pattern, threat.UserAgent)
+	}
+
+	suspicious := workbook.AddSheet("Suspicious IPs", "IP", "Requests", "Threat Score", "Categories", "ATT&CK Techniques", "Unique URLs", "Error Rate", "First Seen", "Last Seen")
+	for _, ip := range security.SuspiciousIPs {
+		suspicious.AddRow(ip.IP, ip.RequestCount, ip.ThreatScore, strings.Join(ip.ThreatCategories, ", "),
+			strings.Join(ip.MITRETechniques, ", "), ip.UniqueURLs, Percent(ip.ErrorRate/100), ip.FirstSeen, ip.LastSeen)
+	}
+}
+
+func addGroups(workbook *Workbook, results *analyser.Results, groups []analyser.GroupResult) {
+	sheet := workbook.AddSheet("Groups", "Group", "Files", "Requests", "Percentage", "Error Rate", "Unique IPs", "Total Bytes")
+	for _, group := range groups {
+		sheet.AddRow(group.Name, strings.Join(group.Files, ", "), group.Results.TotalRequests,
+			share(group.Results.TotalRequests, results.TotalRequests), Percent(group.ErrorRate/100),
+			group.Results.UniqueIPs, group.Results.TotalBytes)
+	}
+}
+
+// share returns count as a fraction of total
+func share(count, total int) Percent {
+	if total == 0 {
+		return 0
+	}
+	return Percent(float64(count) / float64(total))
+}
+
+// statusClass returns the status code class ("4xx Client Error", ...) a
+// detailed status code belongs to
+func statusClass(code int, classes []string) string {
+	prefix := fmt.Sprintf("%dxx", code/100)
+	for _, class := range classes {
+		if strings.HasPrefix(class, prefix) {
+			return class
+		}
+	}
+	return prefix
+}
+
+// statusBreakdown lists the status codes of an error URL with their counts
+func statusBreakdown(codes map[int]int) string {
+	sorted := make([]int, 0, len(codes))
+	for code := range codes {
+		sorted = append(sorted, code)
+	}
+	sort.Ints(sorted)
+
+	parts := make([]string, len(sorted))
+	for i, code := range sorted {
+		parts[i] = fmt.Sprintf("%d (%d)", code, codes[code])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,325 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of the XLSX format
+const (
+	maxSheetName = 31
+	maxCellText  = 32767
+)
+
+// Cell styles, by their index in styles.xml
+const (
+	styleDefault = iota
+	styleHeader
+	styleInteger
+	styleDecimal
+	stylePercent
+	styleDateTime
+)
+
+// Percent is a number shown as a percentage, given as a fraction (0.25 is 25%)
+type Percent float64
+
+// Workbook is a spreadsheet of one or more worksheets, each a table with a
+// header row
+type Workbook struct {
+	sheets []*Sheet
+}
+
+// Sheet is a worksheet of a workbook
+type Sheet struct {
+	name   string
+	rows   [][]interface{}
+	widths []int
+}
+
+// NewWorkbook creates an empty workbook
+func NewWorkbook() *Workbook {
+	return &Workbook{}
+}
+
+// AddSheet adds a worksheet with the given column headers. Names are cut to
+// the 31 characters the format allows.
+func (w *Workbook) AddSheet(name string, headers ...string) *Sheet {
+	if utf8.RuneCountInString(name) > maxSheetName {
+		name = string([]rune(name)[:maxSheetName])
+	}
+
+	sheet := &Sheet{name: name}
+	row := make([]interface{}, len(headers))
+	for i, header := range headers {
+		row[i] = header
+	}
+	sheet.AddRow(row...)
+	w.sheets = append(w.sheets, sheet)
+	return sheet
+}
+
+// AddRow appends a row to the sheet. Integers, floats, Percent and time.Time
+// values are written as typed numbers, anything else as text.
+func (s *Sheet) AddRow(values ...interface{}) {
+	s.rows = append(s.rows, values)
+	for i, value := range values {
+		width := utf8.RuneCountInString(displayText(value))
+		if i >= len(s.widths) {
+			s.widths = append(s.widths, width)
+		} else if width > s.widths[i] {
+			s.widths[i] = width
+		}
+	}
+}
+
+// Len returns the number of data rows of the sheet, not counting the header
+func (s *Sheet) Len() int {
+	return len(s.rows) - 1
+}
+
+// Save writes the workbook to an .xlsx file
+func (w *Workbook) Save(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Write writes the workbook in XLSX format
+func (w *Workbook) Write(out io.Writer) error {
+	if len(w.sheets) == 0 {
+		return fmt.Errorf("workbook has no sheets")
+	}
+
+	archive := zip.NewWriter(out)
+	parts := []part{
+		{"[Content_Types].xml", w.contentTypes()},
+		{"_rels/.rels", []byte(xmlHeader + rootRels)},
+		{"xl/workbook.xml", w.workbook()},
+		{"xl/_rels/workbook.xml.rels", w.workbookRels()},
+		{"xl/styles.xml", []byte(xmlHeader + styles)},
+	}
+	for i, sheet := range w.sheets {
+		parts = append(parts, part{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheet.xml()})
+	}
+
+	for _, part := range parts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(part.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// part is a file of the XLSX package
+type part struct {
+	name    string
+	content []byte
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const rootRels = `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// styles defines the cell styles in the order of the style constants
+const styles = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="2"><numFmt numFmtId="164" formatCode="0.0%"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/><bgColor indexed="64"/></patternFill></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="6">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/>` +
+	`<xf numFmtId="3" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+func (w *Workbook) contentTypes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xmlHeader)
+	buf.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	buf.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	buf.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	buf.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	buf.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := range w.sheets {
+		fmt.Fprintf(&buf, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	buf.WriteString(`</Types>`)
+	return buf.Bytes()
+}
+
+func (w *Workbook) workbook() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xmlHeader)
+	buf.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, sheet := range w.sheets {
+		fmt.Fprintf(&buf, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(sheet.name), i+1, i+1)
+	}
+	buf.WriteString(`</sheets></workbook>`)
+	return buf.Bytes()
+}
+
+func (w *Workbook) workbookRels() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xmlHeader)
+	buf.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range w.sheets {
+		fmt.Fprintf(&buf, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&buf, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(w.sheets)+1)
+	buf.WriteString(`</Relationships>`)
+	return buf.Bytes()
+}
+
+// xml renders the sheet with a frozen, bold header row and columns sized to
+// their content
+func (s *Sheet) xml() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xmlHeader)
+	buf.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	buf.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+
+	if len(s.widths) > 0 {
+		buf.WriteString(`<cols>`)
+		for i, width := range s.widths {
+			fmt.Fprintf(&buf, `<col min="%d" max="%d" width="%d" customWidth="1"/>`, i+1, i+1, columnWidth(width))
+		}
+		buf.WriteString(`</cols>`)
+	}
+
+	buf.WriteString(`<sheetData>`)
+	for r, row := range s.rows {
+		fmt.Fprintf(&buf, `<row r="%d">`, r+1)
+		for c, value := range row {
+			writeCell(&buf, cellRef(c, r), value, r == 0)
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData></worksheet>`)
+	return buf.Bytes()
+}
+
+// writeCell writes a typed cell; empty values leave the cell out
+func writeCell(buf *bytes.Buffer, ref string, value interface{}, header bool) {
+	if header {
+		fmt.Fprintf(buf, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`, ref, styleHeader, escape(displayText(value)))
+		return
+	}
+
+	switch v := value.(type) {
+	case nil:
+	case int:
+		fmt.Fprintf(buf, `<c r="%s" s="%d"><v>%d</v></c>`, ref, styleInteger, v)
+	case int64:
+		fmt.Fprintf(buf, `<c r="%s" s="%d"><v>%d</v></c>`, ref, styleInteger, v)
+	case float64:
+		writeNumber(buf, ref, styleDecimal, v)
+	case Percent:
+		writeNumber(buf, ref, stylePercent, float64(v))
+	case time.Time:
+		if !v.IsZero() {
+			writeNumber(buf, ref, styleDateTime, serialDate(v))
+		}
+	default:
+		text := displayText(v)
+		if text != "" {
+			fmt.Fprintf(buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(text))
+		}
+	}
+}
+
+// writeNumber writes a number cell, leaving out values a spreadsheet cannot hold
+func writeNumber(buf *bytes.Buffer, ref string, style int, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+	fmt.Fprintf(buf, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// displayText returns a value as it reads in the sheet, used for text cells
+// and column widths
+func displayText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case Percent:
+		return strconv.FormatFloat(float64(v)*100, 'f', 1, 64) + "%"
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case time.Time:
+		return "2006-01-02 15:04:05"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// serialDate converts a time to a spreadsheet date serial (days since
+// 1899-12-30), keeping the wall clock time of its zone
+func serialDate(t time.Time) float64 {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// cellRef returns the A1-style reference of a zero-based column and row
+func cellRef(column, row int) string {
+	name := ""
+	for column >= 0 {
+		name = string(rune('A'+column%26)) + name
+		column = column/26 - 1
+	}
+	return name + strconv.Itoa(row+1)
+}
+
+// columnWidth fits a column to its longest value, within sensible bounds
+func columnWidth(characters int) int {
+	width := characters + 2
+	if width < 8 {
+		return 8
+	}
+	if width > 80 {
+		return 80
+	}
+	return width
+}
+
+// escape makes text safe for XML, replacing characters XML cannot hold and
+// cutting it to the length a cell allows
+func escape(text string) string {
+	if utf8.RuneCountInString(text) > maxCellText {
+		text = string([]rune(text)[:maxCellText])
+	}
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}